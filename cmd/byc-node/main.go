@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
 	"byc/internal/api"
 	"byc/internal/blockchain"
@@ -14,6 +17,9 @@ import (
 	"byc/internal/network"
 )
 
+// shutdownTimeout bounds the wait for peer handlers to return on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// Initialize logger
 	if err := logger.Init(); err != nil {
@@ -23,6 +29,9 @@ func main() {
 
 	// Command line flags
 	configPath := flag.String("config", "config/config.yaml", "Path to config file")
+	dataDir := flag.String("data-dir", "", "Directory holding wallets, backups, db and logs (overrides config)")
+	maxConnections := flag.Int("max-connections", 0, "Maximum inbound and outbound peer connections (overrides config)")
+	maxConnectionsPerIP := flag.Int("max-connections-per-ip", 0, "Maximum peer connections to or from one address (overrides config)")
 	flag.Parse()
 
 	// Load configuration
@@ -31,15 +40,81 @@ func main() {
 		fmt.Printf("Failed to load config: %v\n", err)
 		os.Exit(1)
 	}
+	if *dataDir != "" {
+		cfg.DataDir = *dataDir
+	}
+	if *maxConnections > 0 {
+		cfg.P2P.MaxConnections = *maxConnections
+	}
+	if *maxConnectionsPerIP > 0 {
+		cfg.P2P.MaxConnectionsPerIP = *maxConnectionsPerIP
+	}
+
+	// Lay out the data directory
+	paths := cfg.Paths()
+	if err := paths.Ensure(); err != nil {
+		fmt.Printf("Failed to prepare data directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Copy log entries to the logs directory; an unwritable file leaves the
+	// logger on stderr
+	logger.InitWithConfig(logger.Config{
+		File:       filepath.Join(paths.Logs, logger.DefaultFile),
+		MaxSize:    cfg.Logging.MaxSize,
+		MaxBackups: cfg.Logging.MaxBackups,
+		MaxAge:     cfg.Logging.MaxAge,
+		Compress:   cfg.Logging.Compress,
+	})
+
+	lock, err := config.LockDataDir(paths.Root)
+	if err != nil {
+		fmt.Printf("Failed to start node: %v\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
 
-	// Create blockchain instance
+	// Create blockchain instance, keeping backups under the data directory
 	bc := blockchain.NewBlockchain()
+	bc.BackupDir = paths.Backups
+	bc.DataDir = paths.Root
+
+	// Resume from the last chain state checkpoint, so a crash loses at most
+	// the blocks since then
+	state, err := bc.LoadChainState(cfg.DataDir)
+	if err != nil {
+		fmt.Printf("Failed to load chain state: %v\n", err)
+	} else if state.Height > 0 {
+		fmt.Printf("Resumed from checkpoint at height %d (tip %s)\n", state.Height, state.TipHash)
+	}
+	stopCheckpoints := make(chan struct{})
+	checkpointsDone := make(chan struct{})
+	go func() {
+		defer close(checkpointsDone)
+		bc.RunCheckpoints(cfg.DataDir, blockchain.DefaultCheckpointInterval, stopCheckpoints, func(err error) {
+			fmt.Printf("Failed to checkpoint chain state: %v\n", err)
+		})
+	}()
+
+	// Restore pending transactions saved on the last shutdown
+	mempoolPath := filepath.Join(cfg.DataDir, blockchain.MempoolFile)
+	restored, dropped, err := bc.LoadMempool(mempoolPath)
+	if err != nil {
+		fmt.Printf("Failed to load mempool: %v\n", err)
+	} else if restored > 0 || dropped > 0 {
+		fmt.Printf("Restored %d pending transactions (%d no longer valid)\n", restored, dropped)
+	}
 
 	// Create node with P2P address
 	node, err := network.NewNode(&network.Config{
-		Address:        cfg.P2P.Address,
-		BlockType:      cfg.Blockchain.BlockType,
-		BootstrapPeers: cfg.P2P.BootstrapPeers,
+		Address:             cfg.P2P.Address,
+		BlockType:           cfg.Blockchain.BlockType,
+		BootstrapPeers:      cfg.P2P.BootstrapPeers,
+		Blockchain:          bc,
+		DataDir:             cfg.DataDir,
+		IdentityPassphrase:  os.Getenv("BYC_NODE_PASSPHRASE"),
+		MaxConnections:      cfg.P2P.MaxConnections,
+		MaxConnectionsPerIP: cfg.P2P.MaxConnectionsPerIP,
 	})
 	if err != nil {
 		fmt.Printf("Failed to create node: %v\n", err)
@@ -60,8 +135,10 @@ func main() {
 
 	// Start mining if configured
 	if cfg.Mining.Enabled && cfg.Mining.AutoStart {
-		coinType := blockchain.CoinType(cfg.Mining.CoinType)
-		if err := node.StartMining(coinType); err != nil {
+		coinType, err := blockchain.ParseCoinType(cfg.Mining.CoinType)
+		if err != nil {
+			fmt.Printf("Failed to start mining: %v\n", err)
+		} else if err := node.StartMining(coinType); err != nil {
 			fmt.Printf("Failed to start mining: %v\n", err)
 		} else {
 			fmt.Printf("Started mining %s coins\n", cfg.Mining.CoinType)
@@ -80,11 +157,24 @@ func main() {
 
 	fmt.Println("Shutting down node...")
 
-	// Graceful shutdown
+	// Shut down in dependency order, each stage finishing before the next:
+	// the node stops mining, then stops taking peers and drains their
+	// handlers, so nothing adds blocks while the API server stops and the
+	// chain is flushed
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	if err := node.Shutdown(ctx); err != nil {
+		fmt.Printf("Error during node shutdown: %v\n", err)
+	}
+	cancel()
 	if err := server.Stop(); err != nil {
 		fmt.Printf("Error during server shutdown: %v\n", err)
 	}
-	if err := node.Stop(); err != nil {
-		fmt.Printf("Error during node shutdown: %v\n", err)
+	close(stopCheckpoints)
+	<-checkpointsDone
+	if err := bc.SaveMempool(mempoolPath); err != nil {
+		fmt.Printf("Error saving mempool: %v\n", err)
+	}
+	if err := bc.Close(); err != nil {
+		fmt.Printf("Error closing blockchain: %v\n", err)
 	}
 }
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"byc/internal/blockchain"
+	"byc/internal/network"
+	"byc/internal/security"
+	"byc/internal/wallet"
+)
+
+// Exit codes returned by non-interactive commands
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// usageError reports a malformed command line
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// commandUsage describes the non-interactive subcommands
+const commandUsage = `Usage:
+  byc [-data-dir dir] <command>         root wallets, backups, db and logs under dir (default .)
+  byc                                   start the interactive menu
+  byc wallet create
+  byc wallet balance [-address addr] [-coin name] [-json]
+  byc wallet history [-coin name] [-json]
+  byc wallet estimate-fee -amount n [-coin name] [-json]
+  byc wallet send
+  byc node start [-address host:port] [-peer host:port] [-block golden|silver] [-retries n] [-retry-delay d]
+                 [-max-connections n] [-max-connections-per-ip n] [-tx-fanout n]
+  byc mine [-coin name] [-block golden|silver] [-coins name,...] [-address host:port]
+           [-reward-address addr] [-coinbase-tag text] [-network mainnet|testnet|regtest]
+           [-difficulty n] [-mining-timeout d]
+  byc tx verify -raw hex [-prev hex,...] [-json]
+`
+
+// runCommand runs a non-interactive subcommand and returns the process exit code
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	var err error
+	switch args[0] {
+	case "wallet":
+		err = runWalletCommand(args[1:], stdout, stderr)
+	case "node":
+		err = runNodeCommand(args[1:], stdout, stderr)
+	case "mine":
+		err = runMineCommand(args[1:], stderr)
+	case "tx":
+		err = runTxCommand(args[1:], stdout, stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, commandUsage)
+		return exitOK
+	default:
+		err = &usageError{fmt.Sprintf("unknown command %q", args[0])}
+	}
+
+	if err == nil {
+		return exitOK
+	}
+
+	var usage *usageError
+	if errors.As(err, &usage) {
+		fmt.Fprintf(stderr, "Error: %v\n\n%s", err, commandUsage)
+		return exitUsage
+	}
+	fmt.Fprintf(stderr, "Error: %v\n", err)
+	return exitError
+}
+
+// parseFlags parses args into cmd, turning flag errors into usage errors
+func parseFlags(cmd *flag.FlagSet, args []string, stderr io.Writer) error {
+	cmd.SetOutput(stderr)
+	if err := cmd.Parse(args); err != nil {
+		return &usageError{err.Error()}
+	}
+	if cmd.NArg() > 0 {
+		return &usageError{fmt.Sprintf("unexpected arguments: %v", cmd.Args())}
+	}
+	return nil
+}
+
+// runWalletCommand handles `byc wallet <action> [flags]`
+func runWalletCommand(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return &usageError{"wallet requires an action"}
+	}
+
+	cmd := newWalletFlagSet()
+	if err := parseFlags(cmd, args[1:], stderr); err != nil {
+		return err
+	}
+	return handleWallet(args[0], cmd, stdout)
+}
+
+// runNodeCommand handles `byc node start [flags]`
+func runNodeCommand(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] != "start" {
+		return &usageError{"node requires the start action"}
+	}
+
+	cmd := flag.NewFlagSet("node", flag.ContinueOnError)
+	address := cmd.String("address", "localhost:3000", "Address to listen on")
+	peer := cmd.String("peer", "", "Peer to connect to after starting")
+	block := cmd.String("block", "golden", "Block type: golden or silver")
+	retries := cmd.Int("retries", network.DefaultConnectRetries, "Dial attempts per peer before backing off")
+	retryDelay := cmd.Duration("retry-delay", network.DefaultConnectBaseDelay, "Delay before the first dial retry, doubled on each attempt")
+	maxConnections := cmd.Int("max-connections", security.DefaultMaxConnections, "Maximum inbound and outbound peer connections")
+	maxConnectionsPerIP := cmd.Int("max-connections-per-ip", security.DefaultMaxConnectionsPerIP, "Maximum peer connections to or from one address")
+	txFanout := cmd.Int("tx-fanout", 0, "Number of random peers each transaction is relayed to (0 relays to all)")
+	if err := parseFlags(cmd, args[1:], stderr); err != nil {
+		return err
+	}
+
+	var blockType blockchain.BlockType
+	switch *block {
+	case "golden":
+		blockType = blockchain.GoldenBlock
+	case "silver":
+		blockType = blockchain.SilverBlock
+	default:
+		return &usageError{fmt.Sprintf("invalid block type %q (valid: golden, silver)", *block)}
+	}
+
+	if *maxConnections <= 0 || *maxConnectionsPerIP <= 0 {
+		return &usageError{"-max-connections and -max-connections-per-ip must be positive"}
+	}
+	if *txFanout < 0 {
+		return &usageError{"-tx-fanout must not be negative"}
+	}
+
+	listenAddress, err := normalizeNodeAddress(*address)
+	if err != nil {
+		return &usageError{fmt.Sprintf("invalid -address: %v", err)}
+	}
+
+	config := &network.Config{
+		Address:             listenAddress,
+		BlockType:           blockType,
+		BootstrapPeers:      []string{},
+		ConnectRetries:      *retries,
+		ConnectBaseDelay:    *retryDelay,
+		MaxConnections:      *maxConnections,
+		MaxConnectionsPerIP: *maxConnectionsPerIP,
+		TxRelayFanout:       *txFanout,
+	}
+	if *peer != "" {
+		peerAddress, err := normalizeNodeAddress(*peer)
+		if err != nil {
+			return &usageError{fmt.Sprintf("invalid -peer: %v", err)}
+		}
+		config.BootstrapPeers = append(config.BootstrapPeers, peerAddress)
+	}
+
+	node, err := network.NewNode(config)
+	if err != nil {
+		return fmt.Errorf("failed to start node: %v", err)
+	}
+	setNode(node)
+	fmt.Fprintf(stdout, "Node started on %s\n", node.GetAddress())
+
+	// Keep trying bootstrap peers in the background
+	node.ConnectToBootstrapPeers()
+
+	// Run until interrupted
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+
+	fmt.Fprintln(stdout, "Shutting down node...")
+	return node.Stop()
+}
+
+// runMineCommand handles `byc mine [flags]`
+func runMineCommand(args []string, stderr io.Writer) error {
+	cmd := newMiningFlagSet()
+	if err := parseFlags(cmd, args, stderr); err != nil {
+		return err
+	}
+
+	if coins := cmd.Lookup("coins").Value.String(); coins != "" {
+		if _, err := parseMiningCoins(coins); err != nil {
+			return &usageError{err.Error()}
+		}
+	} else if _, _, err := parseMiningTarget(cmd.Lookup("coin").Value.String(), cmd.Lookup("block").Value.String()); err != nil {
+		return &usageError{err.Error()}
+	}
+	if timeout := cmd.Lookup("mining-timeout").Value.(flag.Getter).Get().(time.Duration); timeout < 0 {
+		return &usageError{fmt.Sprintf("invalid -mining-timeout %s: must not be negative", timeout)}
+	}
+	if difficulty := cmd.Lookup("difficulty").Value.(flag.Getter).Get().(int); difficulty < 0 {
+		return &usageError{fmt.Sprintf("invalid -difficulty %d: must not be negative", difficulty)}
+	}
+	if address := cmd.Lookup("reward-address").Value.String(); address != "" {
+		if _, err := wallet.ParseAddress(address); err != nil {
+			return &usageError{fmt.Sprintf("invalid -reward-address: %v", err)}
+		}
+	}
+
+	if err := blockchain.CheckCoinbaseTag(cmd.Lookup("coinbase-tag").Value.String()); err != nil {
+		return &usageError{fmt.Sprintf("invalid -coinbase-tag: %v", err)}
+	}
+
+	if _, err := blockchain.ParamsForMode(blockchain.NetworkMode(cmd.Lookup("network").Value.String())); err != nil {
+		return &usageError{fmt.Sprintf("invalid -network: %v", err)}
+	}
+
+	return handleMining(cmd)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"strings"
+	"testing"
+
+	"byc/internal/blockchain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRunCommandWalletCreate tests the wallet create subcommand
+func TestRunCommandWalletCreate(t *testing.T) {
+	withMiningWallet(t, miningWalletInfo{})
+
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"wallet", "create"}, &stdout, &stderr)
+
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stdout.String(), "Address: ")
+	assert.Empty(t, stderr.String())
+
+	// The key is saved so the wallet can be loaded again, and never overwritten
+	w, err := loadUserWallet()
+	require.NoError(t, err)
+	assert.Contains(t, stdout.String(), w.Address)
+	assert.Equal(t, exitError, runCommand([]string{"wallet", "create"}, &stdout, &stderr))
+}
+
+// TestRunCommandWalletBalance tests the wallet balance subcommand for an address
+func TestRunCommandWalletBalance(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"wallet", "balance", "-address", "some-address", "-json"}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+
+	var result struct {
+		Address  string             `json:"address"`
+		Balances map[string]float64 `json:"balances"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	assert.Equal(t, "some-address", result.Address)
+	assert.Empty(t, result.Balances)
+}
+
+// TestRunCommandEstimateFee tests that estimate-fee takes amounts written
+// with thousands separators and prints them the same way
+func TestRunCommandEstimateFee(t *testing.T) {
+	withMiningWallet(t, miningWalletInfo{})
+
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"wallet", "estimate-fee", "-amount", "1,000,000", "-coin", "leah"}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Amount: 1,000,000.00 Leah")
+}
+
+// TestRunCommandUsageErrors tests that malformed command lines exit with the usage code
+func TestRunCommandUsageErrors(t *testing.T) {
+	tests := [][]string{
+		{"bogus"},
+		{"wallet"},
+		{"wallet", "explode"},
+		{"wallet", "balance", "-nope"},
+		{"wallet", "balance", "-address"},
+		{"wallet", "balance", "some-address"},
+		{"wallet", "balance", "-coin"},
+		{"wallet", "balance", "-coin", "gold"},
+		{"wallet", "history", "-coin"},
+		{"wallet", "history", "-coin", "gold"},
+		{"wallet", "history", "LEAH"},
+		{"wallet", "estimate-fee", "-amount", "1", "-coin", "gold"},
+		{"node", "restart"},
+		{"mine", "-coin", "gold"},
+		{"mine", "-coin", "leah", "-block", "bronze"},
+		{"mine", "-mining-timeout", "-1s"},
+		{"mine", "-difficulty", "-1"},
+		{"mine", "-reward-address", "miner_address"},
+		{"mine", "-coins", "leah,ephraim"},
+		{"mine", "-coins", "leah,leah"},
+		{"mine", "-coinbase-tag", strings.Repeat("x", blockchain.MaxCoinbaseTagLength+1)},
+		{"mine", "-network", "moonnet"},
+	}
+
+	for _, args := range tests {
+		var stdout, stderr bytes.Buffer
+		code := runCommand(args, &stdout, &stderr)
+		assert.Equal(t, exitUsage, code, "args: %v", args)
+		assert.Contains(t, stderr.String(), "Usage:", "args: %v", args)
+	}
+}
+
+// TestRunCommandMineSetupError tests that a miner that cannot be set up
+// exits with the error code instead of ending the process
+func TestRunCommandMineSetupError(t *testing.T) {
+	withMiningWallet(t, miningWalletInfo{})
+
+	// Pinning the difficulty is refused on mainnet
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"mine", "-difficulty", "4"}, &stdout, &stderr)
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "failed to create miner")
+	assert.NotContains(t, stderr.String(), "Usage:")
+}
+
+// TestCommandUsageListsFlags tests that the usage text lists every flag the
+// node and mine commands accept
+func TestCommandUsageListsFlags(t *testing.T) {
+	for _, name := range []string{"max-connections", "max-connections-per-ip", "tx-fanout"} {
+		assert.Contains(t, commandUsage, "-"+name+" ", "node flag %s", name)
+	}
+	newMiningFlagSet().VisitAll(func(f *flag.Flag) {
+		assert.Contains(t, commandUsage, "-"+f.Name+" ", "mine flag %s", f.Name)
+	})
+}
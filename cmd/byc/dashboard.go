@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"byc/internal/blockchain"
+	"byc/internal/coin"
 	"byc/internal/monitoring"
 	"byc/internal/network"
 )
@@ -29,7 +30,7 @@ func handleDashboard(cmd *flag.FlagSet) {
 	done := make(chan bool)
 
 	// Initialize blockchain and node
-	bc := blockchain.NewBlockchain()
+	bc := newBlockchain()
 	node, err := network.NewNode(&network.Config{})
 	if err != nil {
 		fmt.Printf("Error initializing node: %v\n", err)
@@ -46,7 +47,7 @@ func handleDashboard(cmd *flag.FlagSet) {
 			case <-done:
 				return
 			default:
-				showDashboard(monitor)
+				showDashboard(monitor, bc)
 				time.Sleep(5 * time.Second)
 				fmt.Println("\n---")
 			}
@@ -66,7 +67,7 @@ func handleDashboard(cmd *flag.FlagSet) {
 	time.Sleep(1 * time.Second) // Give user time to read the message
 }
 
-func showDashboard(monitor *monitoring.Monitor) {
+func showDashboard(monitor *monitoring.Monitor, bc *blockchain.Blockchain) {
 	// Get system metrics
 	metrics := getSystemMetrics()
 	health := monitor.GetHealth()
@@ -95,7 +96,6 @@ func showDashboard(monitor *monitoring.Monitor) {
 
 	details := health["details"].(map[string]interface{})
 	network := details["network"].(map[string]interface{})
-	blockchain := details["blockchain"].(map[string]interface{})
 	system := details["system"].(map[string]interface{})
 
 	// Enhanced peer information
@@ -116,30 +116,24 @@ func showDashboard(monitor *monitoring.Monitor) {
 		}
 	}
 
-	// Enhanced blockchain status
+	// Enhanced blockchain status, from one snapshot so the figures agree
+	stats := bc.Stats()
 	fmt.Println("\nBlockchain Status:")
 	fmt.Println("-----------------")
-	goldenBlocks := int(blockchain["golden_blocks"].(int))
-	silverBlocks := int(blockchain["silver_blocks"].(int))
-	totalBlocks := goldenBlocks + silverBlocks
+	totalBlocks := stats.GoldenBlocks + stats.SilverBlocks
 
+	fmt.Printf("Height: %d\n", stats.Height)
 	fmt.Printf("Total Blocks: %d\n", totalBlocks)
-	fmt.Printf("Golden Blocks: %d (%.1f%%)\n", goldenBlocks, float64(goldenBlocks)/float64(totalBlocks)*100)
-	fmt.Printf("Silver Blocks: %d (%.1f%%)\n", silverBlocks, float64(silverBlocks)/float64(totalBlocks)*100)
-
-	// Show recent block information
-	if recentBlocks, ok := blockchain["recent_blocks"].([]interface{}); ok {
-		fmt.Println("\nRecent Blocks:")
-		for i, b := range recentBlocks {
-			if i >= 3 { // Show only last 3 blocks
-				break
-			}
-			block := b.(map[string]interface{})
-			fmt.Printf("- Block %d: %s (Type: %s, Txs: %d)\n",
-				block["height"],
-				block["hash"],
-				block["type"],
-				block["transactions"])
+	fmt.Printf("Golden Blocks: %d (%.1f%%)\n", stats.GoldenBlocks, float64(stats.GoldenBlocks)/float64(totalBlocks)*100)
+	fmt.Printf("Silver Blocks: %d (%.1f%%)\n", stats.SilverBlocks, float64(stats.SilverBlocks)/float64(totalBlocks)*100)
+	fmt.Printf("Golden Tip: %s\n", stats.GoldenTip)
+	fmt.Printf("Silver Tip: %s\n", stats.SilverTip)
+	fmt.Printf("Mempool: %d transactions\n", stats.MempoolSize)
+
+	fmt.Println("\nTotal Supply:")
+	for _, coinType := range blockchain.AllCoinTypes {
+		if supply := stats.TotalSupply[coinType]; supply > 0 {
+			fmt.Printf("- %s: %s\n", coinType, coin.FormatAmount(supply, coinType))
 		}
 	}
 
@@ -170,12 +164,33 @@ func showDashboard(monitor *monitoring.Monitor) {
 		fmt.Printf("Status: %s\n", mining["status"])
 		fmt.Printf("Hash Rate: %d H/s\n", mining["hash_rate"])
 		fmt.Printf("Current Block: %d\n", mining["current_block"])
-		fmt.Printf("Rewards: %.2f %s\n", mining["rewards"], mining["coin_type"])
+		rewards, _ := mining["rewards"].(float64)
+		coinType := blockchain.CoinType(fmt.Sprint(mining["coin_type"]))
+		fmt.Printf("Rewards: %s %s\n", coin.FormatAmount(rewards, coinType), coinType)
+	}
+
+	// Show when the mining wallet's block rewards become spendable
+	if walletInfo, err := loadMiningWallet(); err == nil {
+		showImmatureRewards(bc, walletInfo.Address)
 	}
 
 	fmt.Println("\nPress Ctrl+C to return to main menu...")
 }
 
+// showImmatureRewards lists the block rewards of address that cannot be spent yet
+func showImmatureRewards(bc *blockchain.Blockchain, address string) {
+	infos, err := bc.GetCoinbaseMaturityInfo(address)
+	if err != nil || len(infos) == 0 {
+		return
+	}
+	fmt.Println("\nImmature Rewards:")
+	fmt.Println("----------------")
+	for _, info := range infos {
+		fmt.Printf("- %s %s: %d confirmations, spendable at height %d (%d blocks)\n",
+			coin.FormatAmount(info.UTXO.Amount, info.UTXO.CoinType), info.UTXO.CoinType, info.Confirmations, info.MaturityHeight, info.BlocksRemaining)
+	}
+}
+
 // Helper function to get color based on metric value
 func getColorForMetric(value float64, warningThreshold, criticalThreshold float64) string {
 	if value >= criticalThreshold {
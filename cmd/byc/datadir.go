@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"io"
+
+	"byc/internal/blockchain"
+	"byc/internal/config"
+	"byc/internal/mining"
+)
+
+// dataPaths is the layout under the data directory given by -data-dir
+var dataPaths = config.NewPaths(".")
+
+// parseGlobalFlags consumes the flags given before the command, such as
+// -data-dir, and returns the data directory and the remaining arguments
+func parseGlobalFlags(args []string, stderr io.Writer) (string, []string, error) {
+	cmd := flag.NewFlagSet("byc", flag.ContinueOnError)
+	cmd.SetOutput(stderr)
+	dataDir := cmd.String("data-dir", ".", "Directory holding wallets, backups, db and logs")
+	if err := cmd.Parse(args); err != nil {
+		return "", nil, &usageError{err.Error()}
+	}
+	return *dataDir, cmd.Args(), nil
+}
+
+// applyDataDir roots the CLI's wallets, backups and health checks under dataDir.
+// Subdirectories are created when first written.
+func applyDataDir(dataDir string) {
+	dataPaths = config.NewPaths(dataDir)
+}
+
+// newBlockchain creates a chain writing its backups under the data directory
+// and checking the free space of the data directory's filesystem
+func newBlockchain() *blockchain.Blockchain {
+	bc := blockchain.NewBlockchain()
+	bc.BackupDir = dataPaths.Backups
+	bc.DataDir = dataPaths.Root
+	return bc
+}
+
+// loadBlockchain returns the chain as the node last checkpointed it to the
+// data directory, or just the genesis blocks when it never has
+func loadBlockchain() (*blockchain.Blockchain, error) {
+	bc := newBlockchain()
+	if _, err := bc.LoadChainState(dataPaths.Root); err != nil {
+		return nil, err
+	}
+	return bc, nil
+}
+
+// newMiner creates a miner keeping its wallet under the data directory
+func newMiner(bc *blockchain.Blockchain, blockType blockchain.BlockType, coinType blockchain.CoinType, address string) (*mining.Miner, error) {
+	return mining.NewMinerWithWalletsDir(dataPaths.Wallets, bc, blockType, coinType, address)
+}
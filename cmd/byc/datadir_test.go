@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"byc/internal/blockchain"
+	"byc/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseGlobalFlags tests that -data-dir is consumed before the command
+func TestParseGlobalFlags(t *testing.T) {
+	dataDir, args, err := parseGlobalFlags([]string{"-data-dir", "/tmp/node", "wallet", "balance"}, &bytes.Buffer{})
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/node", dataDir)
+	assert.Equal(t, []string{"wallet", "balance"}, args)
+
+	dataDir, args, err = parseGlobalFlags([]string{"wallet", "create"}, &bytes.Buffer{})
+	require.NoError(t, err)
+	assert.Equal(t, ".", dataDir)
+	assert.Equal(t, []string{"wallet", "create"}, args)
+
+	_, _, err = parseGlobalFlags([]string{"-bogus"}, &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+// TestDataDirsAreIsolated tests that two nodes with different data dirs
+// don't share wallets or backups
+func TestDataDirsAreIsolated(t *testing.T) {
+	defaults := dataPaths
+	t.Cleanup(func() { dataPaths = defaults })
+
+	dirs := []string{filepath.Join(t.TempDir(), "a"), filepath.Join(t.TempDir(), "b")}
+	for i, dir := range dirs {
+		applyDataDir(dir)
+
+		// The miner creates its wallets directory under the data dir
+		_, err := newMiner(newBlockchain(), blockchain.GoldenBlock, blockchain.Leah, "")
+		require.NoError(t, err)
+		data, err := json.Marshal(miningWalletInfo{Address: dir})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dataPaths.Wallets, "mining_wallet.json"), data, 0644))
+
+		require.NoError(t, newBlockchain().CreateBackup("node"+string(rune('a'+i))))
+	}
+
+	for i, dir := range dirs {
+		applyDataDir(dir)
+		paths := config.NewPaths(dir)
+		bc := newBlockchain()
+		assert.Equal(t, paths.Backups, bc.BackupDir)
+		assert.Equal(t, paths.Root, bc.DataDir)
+
+		info, err := loadMiningWallet()
+		require.NoError(t, err)
+		assert.Equal(t, dir, info.Address, "wallet read from another data dir")
+
+		backups := bc.ListBackups()
+		assert.Equal(t, []string{"node" + string(rune('a'+i))}, backups)
+		assert.FileExists(t, filepath.Join(paths.Wallets, "mining_wallet.json"))
+		assert.DirExists(t, paths.Backups)
+	}
+}
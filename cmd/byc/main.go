@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -15,8 +16,10 @@ import (
 	"time"
 
 	"byc/internal/blockchain"
+	"byc/internal/coin"
+	"byc/internal/config"
+	"byc/internal/interfaces"
 	"byc/internal/logger"
-	"byc/internal/mining"
 	"byc/internal/wallet"
 
 	"golang.org/x/term"
@@ -45,7 +48,44 @@ func main() {
 		os.Exit(1)
 	}
 
-	bc := blockchain.NewBlockchain()
+	// Root wallets, backups and other state under --data-dir
+	dataDir, args, err := parseGlobalFlags(os.Args[1:], os.Stderr)
+	if err != nil {
+		fmt.Fprint(os.Stderr, commandUsage)
+		os.Exit(exitUsage)
+	}
+	applyDataDir(dataDir)
+
+	// A node or another CLI using the same data directory would race on
+	// its wallets and chain files
+	lock, err := config.LockDataDir(dataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	defer lock.Release()
+
+	// Keep a log file under the data directory; if it can't be opened the
+	// logger stays on stderr
+	logger.InitWithConfig(logger.Config{
+		File:       filepath.Join(config.NewPaths(dataDir).Logs, logger.DefaultFile),
+		MaxSize:    logger.DefaultMaxSize,
+		MaxBackups: logger.DefaultMaxBackups,
+		MaxAge:     logger.DefaultMaxAge,
+	})
+
+	// Run a single command and exit when arguments are given
+	if len(args) > 0 {
+		code := runCommand(args, os.Stdout, os.Stderr)
+		lock.Release()
+		os.Exit(code)
+	}
+
+	bc, err := loadBlockchain()
+	if err != nil {
+		fmt.Printf("Failed to load chain state: %v\n", err)
+		os.Exit(1)
+	}
 
 	reader := bufio.NewReader(os.Stdin)
 	for {
@@ -97,34 +137,15 @@ func main() {
 }
 
 func startMining(bc *blockchain.Blockchain, blockType, coinType, address *string) {
-	// Validate block type
-	var bt blockchain.BlockType
-	switch *blockType {
-	case "golden":
-		bt = blockchain.GoldenBlock
-	case "silver":
-		bt = blockchain.SilverBlock
-	default:
-		fmt.Printf("Invalid block type: %s\n", *blockType)
-		return
-	}
-
-	// Validate coin type
-	var ct blockchain.CoinType
-	switch *coinType {
-	case "leah":
-		ct = blockchain.Leah
-	case "shiblum":
-		ct = blockchain.Shiblum
-	case "shiblon":
-		ct = blockchain.Shiblon
-	default:
-		fmt.Printf("Invalid coin type: %s\n", *coinType)
+	// Validate coin and block type
+	ct, bt, err := parseMiningTarget(*coinType, *blockType)
+	if err != nil {
+		fmt.Printf("Invalid mining target: %v\n", err)
 		return
 	}
 
 	// Create miner
-	miner, err := mining.NewMiner(bc, bt, ct, *address)
+	miner, err := newMiner(bc, bt, ct, *address)
 	if err != nil {
 		fmt.Printf("Error creating miner: %v\n", err)
 		return
@@ -199,20 +220,20 @@ func handleNetworkMenu(bc *blockchain.Blockchain) {
 	case 1:
 		fmt.Print("Enter node address (default: localhost:3000): ")
 		address, _ := reader.ReadString('\n')
-		address = strings.TrimSpace(address)
-		if address == "" {
-			address = "localhost:3000"
-		} else if !strings.Contains(address, ":") {
-			// If only port is provided, add localhost
-			address = "localhost:" + address
+		address, err := normalizeNodeAddress(address)
+		if err != nil {
+			fmt.Printf("Invalid node address: %v\n", err)
+			return
 		}
 
 		fmt.Print("Enter peer address (optional, format: host:port): ")
 		peer, _ := reader.ReadString('\n')
 		peer = strings.TrimSpace(peer)
-		if peer != "" && !strings.Contains(peer, ":") {
-			fmt.Println("Invalid peer address format. Please use host:port format.")
-			return
+		if peer != "" {
+			if peer, err = normalizeNodeAddress(peer); err != nil {
+				fmt.Printf("Invalid peer address: %v\n", err)
+				return
+			}
 		}
 
 		fmt.Printf("Starting node on %s...\n", address)
@@ -291,8 +312,7 @@ func handleDashboardMenu(bc *blockchain.Blockchain) {
 	fmt.Println("\n=== Dashboard ===")
 
 	// Get mining wallet info
-	walletsDir := "wallets"
-	walletFile := filepath.Join(walletsDir, "mining_wallet.json")
+	walletFile := filepath.Join(dataPaths.Wallets, "mining_wallet.json")
 
 	// Network Status
 	fmt.Println("\nNetwork Status:")
@@ -319,7 +339,7 @@ func handleDashboardMenu(bc *blockchain.Blockchain) {
 				fmt.Printf("Mining Address: %s\n", walletInfo.Address)
 				fmt.Println("\nMining Rewards:")
 				for coinType, amount := range walletInfo.Rewards {
-					fmt.Printf("%s: %.2f\n", coinType, amount)
+					fmt.Printf("%s: %s\n", coinType, coin.FormatAmount(amount, blockchain.CoinType(coinType)))
 				}
 			}
 		}
@@ -329,16 +349,14 @@ func handleDashboardMenu(bc *blockchain.Blockchain) {
 
 	// Recent Blocks
 	fmt.Println("\nRecent Blocks:")
-	blocks := bc.Blocks
+	height := bc.GetCurrentHeight()
+	blocks := bc.RecentBlocks(5)
 	if len(blocks) > 0 {
-		// Show last 5 blocks
-		start := len(blocks) - 5
-		if start < 0 {
-			start = 0
-		}
-		for i := len(blocks) - 1; i >= start; i-- {
+		// Show last 5 blocks, newest first
+		first := height - int64(len(blocks))
+		for i := len(blocks) - 1; i >= 0; i-- {
 			block := blocks[i]
-			fmt.Printf("Block %d: %x\n", i, block.Hash)
+			fmt.Printf("Block %d: %x\n", first+int64(i), block.Hash)
 			fmt.Printf("  Timestamp: %s\n", time.Unix(block.Timestamp, 0).Format("2006-01-02 15:04:05"))
 			fmt.Printf("  Transactions: %d\n", len(block.Transactions))
 			fmt.Printf("  Block Type: %s\n", block.BlockType)
@@ -439,23 +457,17 @@ func handleMiningMenu() {
 		fmt.Println("Invalid block type")
 		return
 	}
-	switch coin {
-	case "leah":
-		coinType = blockchain.Leah
-	case "shiblum":
-		coinType = blockchain.Shiblum
-	case "shiblon":
-		coinType = blockchain.Shiblon
-	default:
-		fmt.Println("Invalid coin type")
+	coinType, err = blockchain.ParseCoinType(coin)
+	if err != nil {
+		fmt.Printf("Invalid coin type: %v\n", err)
 		return
 	}
 
 	// Create blockchain instance
-	bc := blockchain.NewBlockchain()
+	bc := newBlockchain()
 
 	// Create miner
-	miner, err := mining.NewMiner(bc, blockType, coinType, nodeAddress)
+	miner, err := newMiner(bc, blockType, coinType, nodeAddress)
 	if err != nil {
 		log.Fatalf("Failed to create miner: %v", err)
 	}
@@ -468,32 +480,40 @@ func handleMiningMenu() {
 }
 
 func runNode(bc *blockchain.Blockchain, address, peerAddress string) {
-	// Get or create node instance
-	node, err := getNode()
-	if err != nil {
-		log.Fatalf("Failed to get node: %v", err)
+	var peers []string
+	if peerAddress != "" {
+		peers = append(peers, peerAddress)
 	}
 
-	// Connect to peer if specified
-	if peerAddress != "" {
-		if err := node.ConnectToPeer(peerAddress); err != nil {
-			log.Printf("Failed to connect to peer: %v", err)
+	// Start the node, or reuse the one that is already running
+	node, err := startNodeAt(address, blockchain.GoldenBlock, peers)
+	if err != nil {
+		if node == nil {
+			fmt.Printf("Failed to start node: %v\n", err)
+			return
 		}
+		fmt.Printf("Using running node: %v\n", err)
+		if peerAddress != "" {
+			go func() {
+				if err := node.ConnectToPeerWithRetry(peerAddress, node.Config.ConnectRetries, node.Config.ConnectBaseDelay); err != nil {
+					log.Printf("Failed to connect to peer: %v", err)
+				}
+			}()
+		}
+		return
 	}
+
+	fmt.Printf("Node started on %s\n", node.GetAddress())
 }
 
 func runMining(bc *blockchain.Blockchain, coinType, blockType string) {
 	// Convert string to CoinType
-	var coin blockchain.CoinType
-	switch coinType {
-	case "leah":
-		coin = blockchain.Leah
-	case "shiblum":
-		coin = blockchain.Shiblum
-	case "shiblon":
-		coin = blockchain.Shiblon
-	default:
-		log.Fatalf("Invalid coin type: %s", coinType)
+	coin, err := blockchain.ParseCoinType(coinType)
+	if err != nil {
+		log.Fatalf("Invalid coin type: %v", err)
+	}
+	if !blockchain.IsMineable(coin) {
+		log.Fatalf("Coin type %s cannot be mined", coin)
 	}
 
 	// Convert string to BlockType
@@ -508,7 +528,7 @@ func runMining(bc *blockchain.Blockchain, coinType, blockType string) {
 	}
 
 	// Create miner
-	miner, err := mining.NewMiner(bc, block, coin, "localhost:3000")
+	miner, err := newMiner(bc, block, coin, "localhost:3000")
 	if err != nil {
 		log.Fatalf("Failed to create miner: %v", err)
 	}
@@ -537,39 +557,10 @@ func runWallet(bc *blockchain.Blockchain, action string) {
 		fmt.Printf("Created new wallet with address: %s\n", w.Address)
 
 	case "balance":
-		// Get the mining wallet
-		walletsDir := "wallets"
-		walletFile := filepath.Join(walletsDir, "mining_wallet.json")
-
-		if _, err := os.Stat(walletFile); err != nil {
-			fmt.Println("No wallet found. Please mine some coins first.")
-			return
-		}
-
-		// Read wallet file
-		data, err := os.ReadFile(walletFile)
-		if err != nil {
-			fmt.Printf("Error reading wallet file: %v\n", err)
-			return
-		}
-
-		var walletInfo struct {
-			Address string
-			Rewards map[string]float64
-		}
-		if err := json.Unmarshal(data, &walletInfo); err != nil {
-			fmt.Printf("Error parsing wallet file: %v\n", err)
-			return
+		if err := showBalance(os.Stdout, bc, "", false); err != nil {
+			fmt.Printf("Error: %v\n", err)
 		}
 
-		fmt.Println("\n=== Wallet Balance ===")
-		fmt.Printf("Address: %s\n", walletInfo.Address)
-		fmt.Println("\nRewards:")
-		for coinType, amount := range walletInfo.Rewards {
-			fmt.Printf("%s: %.2f\n", coinType, amount)
-		}
-		fmt.Println("=====================\n")
-
 	case "send":
 		// TODO: Implement sending coins
 		fmt.Println("Sending coins not implemented yet")
@@ -580,6 +571,23 @@ func runWallet(bc *blockchain.Blockchain, action string) {
 	}
 }
 
+// progressBar returns a progress callback that redraws a bar on out, ending
+// the line once the operation completes
+func progressBar(out io.Writer, label string) blockchain.ProgressFunc {
+	const width = 30
+	return func(done, total int64) {
+		if total <= 0 {
+			return
+		}
+		filled := int(done * width / total)
+		fmt.Fprintf(out, "\r%s [%s%s] %3d%% (%d/%d)", label,
+			strings.Repeat("=", filled), strings.Repeat(" ", width-filled), done*100/total, done, total)
+		if done >= total {
+			fmt.Fprintln(out)
+		}
+	}
+}
+
 func handleBackupMenu(bc *blockchain.Blockchain) {
 	fmt.Println("\n=== Backup & Restore ===")
 	fmt.Println("1. Create Backup")
@@ -603,7 +611,10 @@ func handleBackupMenu(bc *blockchain.Blockchain) {
 		fmt.Print("Enter backup name: ")
 		name, _ := reader.ReadString('\n')
 		name = strings.TrimSpace(name)
-		if err := bc.CreateBackup(name); err != nil {
+		fmt.Print("Enter passphrase to encrypt the backup (empty for none): ")
+		passphrase, _ := reader.ReadString('\n')
+		passphrase = strings.TrimSpace(passphrase)
+		if err := bc.CreateBackupWithProgress(name, passphrase, progressBar(os.Stdout, "Backing up")); err != nil {
 			fmt.Printf("Error creating backup: %v\n", err)
 		} else {
 			fmt.Println("Backup created successfully")
@@ -612,7 +623,10 @@ func handleBackupMenu(bc *blockchain.Blockchain) {
 		fmt.Print("Enter backup name to restore: ")
 		name, _ := reader.ReadString('\n')
 		name = strings.TrimSpace(name)
-		if err := bc.RestoreBackup(name); err != nil {
+		fmt.Print("Enter the backup's passphrase (empty if not encrypted): ")
+		passphrase, _ := reader.ReadString('\n')
+		passphrase = strings.TrimSpace(passphrase)
+		if err := bc.RestoreBackupWithProgress(name, passphrase, progressBar(os.Stdout, "Restoring")); err != nil {
 			fmt.Printf("Error restoring backup: %v\n", err)
 		} else {
 			fmt.Println("Backup restored successfully")
@@ -665,7 +679,7 @@ func handleMaintenanceMenu(bc *blockchain.Blockchain) {
 		fmt.Printf("Last Check: %s\n", health.LastCheck.Format(time.RFC3339))
 		fmt.Println("\nComponents:")
 		for name, comp := range health.Components {
-			fmt.Printf("- %s: %s\n", name, comp.Status)
+			fmt.Printf("- %s: %s (%s)\n", name, comp.Status, comp.Message)
 		}
 	case 2:
 		fmt.Println("Running maintenance tasks...")
@@ -730,37 +744,48 @@ func handleAdvancedWalletMenu(bc *blockchain.Blockchain) {
 		fmt.Println("Invalid choice")
 		return
 	}
+	if choice == 5 {
+		return
+	}
+
+	w, err := loadUserWallet()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if err := runAdvancedWalletAction(os.Stdout, bc, w.AsInterface(), choice); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
 
+// runAdvancedWalletAction runs an Advanced Wallet menu choice against w
+func runAdvancedWalletAction(out io.Writer, bc *blockchain.Blockchain, w interfaces.Wallet, choice int) error {
 	switch choice {
 	case 1:
-		if err := bc.CreateEphraimCoin(); err != nil {
-			fmt.Printf("Error creating Ephraim coin: %v\n", err)
-		} else {
-			fmt.Println("Ephraim coin created successfully")
+		if err := bc.CreateEphraimCoin(w); err != nil {
+			return fmt.Errorf("creating Ephraim coin: %v", err)
 		}
+		fmt.Fprintln(out, "Ephraim coin created successfully")
 	case 2:
-		if err := bc.CreateManassehCoin(); err != nil {
-			fmt.Printf("Error creating Manasseh coin: %v\n", err)
-		} else {
-			fmt.Println("Manasseh coin created successfully")
+		if err := bc.CreateManassehCoin(w); err != nil {
+			return fmt.Errorf("creating Manasseh coin: %v", err)
 		}
+		fmt.Fprintln(out, "Manasseh coin created successfully")
 	case 3:
-		if err := bc.CreateJosephCoin(); err != nil {
-			fmt.Printf("Error creating Joseph coin: %v\n", err)
-		} else {
-			fmt.Println("Joseph coin created successfully")
+		if err := bc.CreateJosephCoin(w); err != nil {
+			return fmt.Errorf("creating Joseph coin: %v", err)
 		}
+		fmt.Fprintln(out, "Joseph coin created successfully")
 	case 4:
-		coins := bc.GetSpecialCoins()
-		fmt.Println("\nSpecial Coins:")
-		for _, coin := range coins {
-			fmt.Printf("- %s: %d\n", coin.Type, coin.Amount)
+		coins := w.GetSpecialCoins(bc)
+		fmt.Fprintln(out, "\nSpecial Coins:")
+		for _, special := range coins {
+			fmt.Fprintf(out, "- %s: %d\n", special.Type, special.Amount)
 		}
-	case 5:
-		return
 	default:
-		fmt.Println("Invalid choice")
+		return fmt.Errorf("invalid choice %d", choice)
 	}
+	return nil
 }
 
 func handleVersionMenu(bc *blockchain.Blockchain) {
@@ -4,57 +4,135 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"byc/internal/blockchain"
+	"byc/internal/coin"
 	"byc/internal/mining"
 )
 
-func handleMining(cmd *flag.FlagSet) {
-	// Get values from flags
-	coinType := cmd.Lookup("coin").Value.String()
-	blockType := cmd.Lookup("block").Value.String()
-	nodeAddress := cmd.Lookup("address").Value.String()
+// newMiningFlagSet creates the flag set used by the mine command
+func newMiningFlagSet() *flag.FlagSet {
+	cmd := flag.NewFlagSet("mine", flag.ContinueOnError)
+	cmd.String("coin", "leah", "Coin type to mine")
+	cmd.String("block", "golden", "Block type to mine: golden or silver")
+	cmd.String("coins", "", "Comma-separated coins to mine in turn, one block each on its own chain (overrides -coin and -block)")
+	cmd.String("address", "localhost:3000", "Node address")
+	cmd.String("reward-address", "", "Address to pay block rewards to (default: the mining wallet)")
+	cmd.String("coinbase-tag", "", fmt.Sprintf("Text stamped on each mined block's coinbase, at most %d bytes", blockchain.MaxCoinbaseTagLength))
+	cmd.String("network", string(blockchain.Mainnet), "Network to mine on: mainnet, testnet or regtest")
+	cmd.Int("difficulty", 0, "Pin the difficulty every block is mined at, for benchmarks on testnet and regtest (0 uses the chain's)")
+	cmd.Duration("mining-timeout", blockchain.DefaultMiningTimeout, "How long to search for a block before starting over; 0 never gives up")
+	return cmd
+}
 
-	// Validate coin type
-	var coin blockchain.CoinType
-	switch coinType {
-	case "leah":
-		coin = blockchain.Leah
-	case "shiblum":
-		coin = blockchain.Shiblum
-	case "shiblon":
-		coin = blockchain.Shiblon
-	default:
-		fmt.Printf("Invalid coin type: %s\n", coinType)
-		fmt.Println("Valid coin types: leah, shiblum, shiblon")
-		os.Exit(1)
+// parseMiningTarget validates the coin and block names given to the miner
+func parseMiningTarget(coinType, blockType string) (blockchain.CoinType, blockchain.BlockType, error) {
+	coin, err := blockchain.ParseCoinType(coinType)
+	if err != nil {
+		return "", "", err
+	}
+	if !blockchain.IsMineable(coin) {
+		return "", "", fmt.Errorf("coin type %s cannot be mined", coin)
 	}
 
-	// Validate block type
 	var block blockchain.BlockType
-	switch blockType {
+	switch strings.ToLower(blockType) {
 	case "golden":
 		block = blockchain.GoldenBlock
 	case "silver":
 		block = blockchain.SilverBlock
 	default:
-		fmt.Printf("Invalid block type: %s\n", blockType)
-		fmt.Println("Valid block types: golden, silver")
-		os.Exit(1)
+		return "", "", fmt.Errorf("invalid block type %q (valid: golden, silver)", blockType)
+	}
+	if err := blockchain.CheckMiningTarget(coin, block); err != nil {
+		return "", "", err
+	}
+
+	return coin, block, nil
+}
+
+// parseMiningCoins validates the comma-separated coins given to -coins
+func parseMiningCoins(list string) ([]blockchain.CoinType, error) {
+	var coins []blockchain.CoinType
+	for _, name := range strings.Split(list, ",") {
+		c, err := blockchain.ParseCoinType(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		if !blockchain.IsMineable(c) {
+			return nil, fmt.Errorf("coin type %s cannot be mined", c)
+		}
+		for _, seen := range coins {
+			if seen == c {
+				return nil, fmt.Errorf("coin type %s is listed twice", c)
+			}
+		}
+		coins = append(coins, c)
+	}
+	return coins, nil
+}
+
+// handleMining mines with the settings in cmd until interrupted. It returns
+// an error, rather than exiting, when the miner cannot be set up.
+func handleMining(cmd *flag.FlagSet) error {
+	// Get values from flags
+	coinType := cmd.Lookup("coin").Value.String()
+	blockType := cmd.Lookup("block").Value.String()
+	nodeAddress := cmd.Lookup("address").Value.String()
+	rewardAddress := cmd.Lookup("reward-address").Value.String()
+	coinList := cmd.Lookup("coins").Value.String()
+	coinbaseTag := cmd.Lookup("coinbase-tag").Value.String()
+	network := cmd.Lookup("network").Value.String()
+	difficulty := cmd.Lookup("difficulty").Value.(flag.Getter).Get().(int)
+	miningTimeout := cmd.Lookup("mining-timeout").Value.(flag.Getter).Get().(time.Duration)
+
+	// Validate coin and block type
+	mined, block, err := parseMiningTarget(coinType, blockType)
+	coins := []blockchain.CoinType{mined}
+	if coinList != "" {
+		if coins, err = parseMiningCoins(coinList); err == nil {
+			mined, block = coins[0], blockchain.GetBlockType(coins[0])
+			coinType, blockType = coinList, "their own"
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid mining target: %v", err)
 	}
 
 	// Create blockchain instance
-	bc := blockchain.NewBlockchain()
+	bc := newBlockchain()
+	if err := bc.SetNetworkMode(blockchain.NetworkMode(network)); err != nil {
+		return fmt.Errorf("invalid network: %v", err)
+	}
+	bc.MiningConfig.MiningTimeout = miningTimeout
 
 	// Create miner
-	miner, err := mining.NewMiner(bc, block, coin, nodeAddress)
+	miner, err := newMiner(bc, block, mined, nodeAddress)
 	if err != nil {
-		log.Fatalf("Failed to create miner: %v", err)
+		return fmt.Errorf("failed to create miner: %v", err)
+	}
+	if rewardAddress != "" {
+		if err := miner.SetRewardAddress(rewardAddress); err != nil {
+			return fmt.Errorf("failed to create miner: %v", err)
+		}
+	}
+	if err := miner.SetCoinbaseTag(coinbaseTag); err != nil {
+		return fmt.Errorf("failed to create miner: %v", err)
+	}
+	if difficulty > 0 {
+		if err := miner.SetTargetBits(difficulty); err != nil {
+			return fmt.Errorf("failed to create miner: %v", err)
+		}
+	}
+	if len(coins) > 1 {
+		if err := miner.SetCoins(coins...); err != nil {
+			return fmt.Errorf("failed to create miner: %v", err)
+		}
 	}
 
 	// Clear screen and show header
@@ -62,6 +140,9 @@ func handleMining(cmd *flag.FlagSet) {
 	fmt.Println("=== BYC Mining Dashboard ===")
 	fmt.Printf("Mining %s coins using %s blocks\n", coinType, blockType)
 	fmt.Printf("Connected to node at %s\n", nodeAddress)
+	if miningTimeout > 0 {
+		fmt.Printf("Each block attempt gives up after %s (-mining-timeout)\n", miningTimeout)
+	}
 	fmt.Println("===========================")
 	fmt.Println("Press Ctrl+C to stop mining and return to the main menu")
 	fmt.Println("--------------------------------------------------------")
@@ -104,7 +185,7 @@ func handleMining(cmd *flag.FlagSet) {
 			case status := <-statusChan:
 				now := time.Now()
 				elapsed := now.Sub(lastUpdate).Seconds()
-				runtime := now.Sub(startTime)
+				runtime := status.Uptime
 
 				// Calculate rates
 				sharesPerSecond := float64(status.Shares-lastShares) / elapsed
@@ -120,9 +201,9 @@ func handleMining(cmd *flag.FlagSet) {
 				fmt.Println("\nMining Status:")
 				fmt.Println("-------------")
 				fmt.Printf("Status: %s\n", getMiningStatus(status))
-				fmt.Printf("Hash Rate: %s\n", formatHashRate(status.HashRate))
+				fmt.Printf("Hash Rate: %s (%d hashes)\n", formatHashRate(status.HashRate), status.Hashes)
 				fmt.Printf("Difficulty: %d\n", status.Difficulty)
-				fmt.Printf("Current Block: %d\n", status.CurrentBlock)
+				fmt.Printf("Current Block: %s\n", status.CurrentBlock.Format("2006-01-02 15:04:05"))
 
 				// Performance Metrics
 				fmt.Println("\nPerformance Metrics:")
@@ -134,9 +215,9 @@ func handleMining(cmd *flag.FlagSet) {
 				// Rewards
 				fmt.Println("\nRewards:")
 				fmt.Println("--------")
-				fmt.Printf("Current Block Reward: %.2f %s\n", status.CurrentReward, coinType)
-				fmt.Printf("Total Rewards: %.2f %s\n", status.TotalRewards, coinType)
-				fmt.Printf("Estimated Daily: %.2f %s\n", calculateDailyEstimate(status), coinType)
+				fmt.Printf("Current Block Reward: %s %s\n", coin.FormatAmount(status.CurrentReward, mined), mined)
+				fmt.Printf("Total Rewards: %s %s\n", coin.FormatAmount(status.TotalRewards, mined), mined)
+				fmt.Printf("Estimated Daily: %s %s\n", coin.FormatAmount(calculateDailyEstimate(status), mined), mined)
 
 				// Network Status
 				fmt.Println("\nNetwork Status:")
@@ -172,10 +253,14 @@ func handleMining(cmd *flag.FlagSet) {
 	fmt.Printf("Total Shares: %d\n", stats["shares"])
 	fmt.Printf("Average Hash Rate: %s\n", formatHashRate(stats["hash_rate"].(int64)))
 	fmt.Printf("Mining Address: %s\n", stats["address"])
-	fmt.Printf("Total Rewards: %.2f %s\n", stats["rewards"], coinType)
+	rewards, _ := stats["rewards"].(map[blockchain.CoinType]float64)
+	for _, c := range coins {
+		fmt.Printf("Total Rewards: %s %s\n", coin.FormatAmount(rewards[c], c), c)
+	}
 
 	fmt.Println("\nReturning to main menu...")
 	time.Sleep(1 * time.Second)
+	return nil
 }
 
 // Helper functions for formatting
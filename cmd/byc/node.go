@@ -5,7 +5,14 @@ import (
 	"net"
 	"sync"
 
+	"byc/internal/blockchain"
 	"byc/internal/network"
+	"byc/internal/utils"
+)
+
+const (
+	defaultNodeHost = "localhost"
+	defaultNodePort = 3000
 )
 
 var (
@@ -30,6 +37,12 @@ func setNode(node *network.Node) {
 	currentNode = node
 }
 
+// normalizeNodeAddress turns user input such as "3001", "example.com" or
+// "[::1]:3001" into a host:port address, applying the default host and port
+func normalizeNodeAddress(address string) (string, error) {
+	return utils.NormalizeAddress(address, defaultNodeHost, defaultNodePort)
+}
+
 // findAvailablePort searches for an available port starting from 3000
 func findAvailablePort() (string, error) {
 	for port := 3000; port < 4000; port++ {
@@ -64,6 +77,29 @@ func initializeNode() (*network.Node, error) {
 	return node, nil
 }
 
+// startNodeAt starts the CLI's node on address, or returns the node that is already running
+func startNodeAt(address string, blockType blockchain.BlockType, bootstrapPeers []string) (*network.Node, error) {
+	nodeMutex.Lock()
+	defer nodeMutex.Unlock()
+
+	if currentNode != nil {
+		return currentNode, fmt.Errorf("node is already running on %s", currentNode.GetAddress())
+	}
+
+	node, err := network.NewNode(&network.Config{
+		Address:        address,
+		BlockType:      blockType,
+		BootstrapPeers: bootstrapPeers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	currentNode = node
+	node.ConnectToBootstrapPeers()
+	return node, nil
+}
+
 // ensureNode ensures that a node is initialized
 func ensureNode() (*network.Node, error) {
 	node, err := getNode()
@@ -4,16 +4,19 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"byc/internal/blockchain"
 	"byc/internal/network"
 )
 
-// handleNodeManagement handles node management operations
+// handleNodeManagement handles node management operations against the CLI's node
 func handleNodeManagement(bc *blockchain.Blockchain) {
 	reader := bufio.NewReader(os.Stdin)
 	for {
@@ -21,19 +24,61 @@ func handleNodeManagement(bc *blockchain.Blockchain) {
 		fmt.Println("1. Start Node")
 		fmt.Println("2. Stop Node")
 		fmt.Println("3. Node Status")
-		fmt.Println("4. Back to Main Menu")
+		fmt.Println("4. List Peers")
+		fmt.Println("5. Add Peer")
+		fmt.Println("6. Disconnect Peer")
+		fmt.Println("7. Start/Stop Mining")
+		fmt.Println("8. Back to Main Menu")
 		fmt.Print("Enter your choice: ")
 		input, _ := reader.ReadString('\n')
 		input = strings.TrimSpace(input)
-		switch input {
-		case "1":
+
+		if input == "8" {
+			return
+		}
+		if input == "1" {
 			startNode(reader)
-		case "2":
+			continue
+		}
+		if input == "2" {
 			stopNodeOperation()
+			continue
+		}
+
+		node, err := getNode()
+		if err != nil {
+			fmt.Println("No node is running. Please start a node first.")
+			continue
+		}
+
+		switch input {
 		case "3":
-			showNodeStatus()
+			printNodeStatus(os.Stdout, node)
 		case "4":
-			return
+			printPeerTable(os.Stdout, node)
+		case "5":
+			fmt.Print("Enter peer address (host:port): ")
+			address, _ := reader.ReadString('\n')
+			if err := addPeer(os.Stdout, node, strings.TrimSpace(address)); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+		case "6":
+			fmt.Print("Enter peer address to disconnect: ")
+			address, _ := reader.ReadString('\n')
+			if err := removePeer(os.Stdout, node, strings.TrimSpace(address)); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+		case "7":
+			coin := "leah"
+			if !node.IsMining() {
+				fmt.Print("Enter coin to mine (default: leah): ")
+				if input, _ := reader.ReadString('\n'); strings.TrimSpace(input) != "" {
+					coin = strings.TrimSpace(input)
+				}
+			}
+			if err := toggleMining(os.Stdout, node, coin); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
 		default:
 			fmt.Println("Invalid choice. Please try again.")
 		}
@@ -42,55 +87,42 @@ func handleNodeManagement(bc *blockchain.Blockchain) {
 
 // startNode handles starting the node
 func startNode(reader *bufio.Reader) {
-	node, err := getNode()
-	if err == nil {
+	if _, err := getNode(); err == nil {
 		fmt.Println("Node is already running")
 		return
 	}
 
 	fmt.Print("Enter node address (default: localhost:3000): ")
 	address, _ := reader.ReadString('\n')
-	address = strings.TrimSpace(address)
-
-	if address == "" {
-		address = "localhost:3000"
+	address, err := normalizeNodeAddress(address)
+	if err != nil {
+		fmt.Printf("Invalid node address: %v\n", err)
+		return
 	}
 
 	fmt.Print("Enter peer address (optional, format: host:port): ")
 	peerAddress, _ := reader.ReadString('\n')
 	peerAddress = strings.TrimSpace(peerAddress)
 
-	fmt.Printf("Starting node on %s...\n", address)
-	config := &network.Config{
-		Address:        address,
-		BlockType:      blockchain.GoldenBlock,
-		BootstrapPeers: []string{},
+	var peers []string
+	if peerAddress != "" {
+		if peerAddress, err = normalizeNodeAddress(peerAddress); err != nil {
+			fmt.Printf("Invalid peer address: %v\n", err)
+			return
+		}
+		peers = append(peers, peerAddress)
+		fmt.Printf("Connecting to peer %s in the background...\n", peerAddress)
 	}
 
-	node, err = network.NewNode(config)
+	fmt.Printf("Starting node on %s...\n", address)
+	node, err := startNodeAt(address, blockchain.GoldenBlock, peers)
 	if err != nil {
 		fmt.Printf("Failed to start node: %v\n", err)
 		return
 	}
 
-	setNode(node)
-	fmt.Printf("Node started successfully on %s\n", address)
-
-	if peerAddress != "" {
-		fmt.Printf("Connecting to peer %s...\n", peerAddress)
-		if err := node.ConnectToPeer(peerAddress); err != nil {
-			fmt.Printf("Failed to connect to peer: %v\n", err)
-		} else {
-			fmt.Printf("Successfully connected to peer at %s\n", peerAddress)
-		}
-	}
-
-	// Show node status using the local node variable
-	fmt.Println("\nNode Status:")
-	fmt.Println("------------")
-	fmt.Printf("Address: %s\n", node.GetAddress())
-	fmt.Printf("Connected Peers: %d\n", len(node.GetPeerAddresses()))
-	fmt.Printf("Block Type: %s\n", node.Config.BlockType)
+	fmt.Printf("Node started successfully on %s\n", node.GetAddress())
+	printNodeStatus(os.Stdout, node)
 }
 
 // stopNode handles stopping the node
@@ -101,6 +133,9 @@ func stopNode() {
 		return
 	}
 
+	if node.IsMining() {
+		node.StopMining()
+	}
 	if err := node.Stop(); err != nil {
 		fmt.Printf("Error stopping node: %v\n", err)
 		return
@@ -117,12 +152,95 @@ func showNodeStatus() {
 		fmt.Println("No node is running")
 		return
 	}
+	printNodeStatus(os.Stdout, node)
+}
 
-	fmt.Println("\nNode Status:")
-	fmt.Println("------------")
-	fmt.Printf("Address: %s\n", node.GetAddress())
-	fmt.Printf("Connected Peers: %d\n", len(node.GetPeerAddresses()))
-	fmt.Printf("Block Type: %s\n", node.Config.BlockType)
+// printNodeStatus writes a summary of the node to out
+func printNodeStatus(out io.Writer, node *network.Node) {
+	mining := "stopped"
+	if node.IsMining() {
+		mining = "running"
+	}
+
+	fmt.Fprintln(out, "\nNode Status:")
+	fmt.Fprintln(out, "------------")
+	fmt.Fprintf(out, "Address: %s\n", node.GetAddress())
+	fmt.Fprintf(out, "Connected Peers: %d\n", len(node.GetPeerAddresses()))
+	fmt.Fprintf(out, "Block Type: %s\n", node.Config.BlockType)
+	fmt.Fprintf(out, "Mining: %s\n", mining)
+}
+
+// printPeerTable writes the node's peers to out as a table
+func printPeerTable(out io.Writer, node *network.Node) {
+	peers := node.GetPeers()
+	if len(peers) == 0 {
+		fmt.Fprintln(out, "No peers are currently connected")
+		return
+	}
+
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Address < peers[j].Address
+	})
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tLAST SEEN\tHEIGHT\tLATENCY")
+	for _, peer := range peers {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n",
+			peer.Address,
+			peer.LastSeen.Format("2006-01-02 15:04:05"),
+			peer.Height,
+			peer.Latency)
+	}
+	tw.Flush()
+}
+
+// addPeer connects the node to a new peer
+func addPeer(out io.Writer, node *network.Node, address string) error {
+	address, err := normalizeNodeAddress(address)
+	if err != nil {
+		return fmt.Errorf("invalid peer address: %v", err)
+	}
+
+	if err := node.ConnectToPeer(address); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Successfully connected to peer at %s\n", address)
+	return nil
+}
+
+// removePeer disconnects the node from a peer
+func removePeer(out io.Writer, node *network.Node, address string) error {
+	if err := node.DisconnectPeer(address); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Successfully disconnected from peer at %s\n", address)
+	return nil
+}
+
+// toggleMining starts mining coin on the node, or stops it if already mining
+func toggleMining(out io.Writer, node *network.Node, coin string) error {
+	if node.IsMining() {
+		node.StopMining()
+		fmt.Fprintln(out, "Mining stopped")
+		return nil
+	}
+
+	coinType, err := blockchain.ParseCoinType(coin)
+	if err != nil {
+		return err
+	}
+	if !blockchain.IsMineable(coinType) {
+		return fmt.Errorf("coin type %s cannot be mined", coinType)
+	}
+
+	if err := node.StartMining(coinType); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Mining %s started\n", coinType)
+	return nil
 }
 
 // stopNodeOperation handles stopping the node
@@ -157,12 +275,12 @@ func resetNodeOperation() {
 
 // backupNodeOperation handles backing up node data
 func backupNodeOperation(bc *blockchain.Blockchain) {
-	fmt.Print("Enter backup directory path (default: ./backups): ")
+	fmt.Printf("Enter backup directory path (default: %s): ", bc.BackupDir)
 	reader := bufio.NewReader(os.Stdin)
 	backupDir, _ := reader.ReadString('\n')
 	backupDir = strings.TrimSpace(backupDir)
 	if backupDir == "" {
-		backupDir = "./backups"
+		backupDir = bc.BackupDir
 	}
 
 	// Create backup directory if it doesn't exist
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"byc/internal/blockchain"
+	"byc/internal/logger"
+	"byc/internal/network"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestNode(t *testing.T) *network.Node {
+	require.NoError(t, logger.Init())
+
+	node, err := network.NewNode(&network.Config{
+		Address:   "localhost:3000",
+		BlockType: blockchain.GoldenBlock,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { node.Stop() })
+	return node
+}
+
+// TestNodeManagementPeers tests adding, listing and removing peers on one node
+func TestNodeManagementPeers(t *testing.T) {
+	node := newTestNode(t)
+	remote := newTestNode(t)
+
+	var out bytes.Buffer
+	printPeerTable(&out, node)
+	assert.Contains(t, out.String(), "No peers")
+
+	out.Reset()
+	require.NoError(t, addPeer(&out, node, remote.GetAddress()))
+	assert.Contains(t, out.String(), remote.GetAddress())
+	assert.Equal(t, []string{remote.GetAddress()}, node.GetPeerAddresses())
+
+	out.Reset()
+	printPeerTable(&out, node)
+	assert.Contains(t, out.String(), "ADDRESS")
+	assert.Contains(t, out.String(), remote.GetAddress())
+
+	out.Reset()
+	require.NoError(t, removePeer(&out, node, remote.GetAddress()))
+	assert.Empty(t, node.GetPeerAddresses())
+
+	// Invalid and unknown peers are rejected
+	assert.Error(t, addPeer(&out, node, "not-an-address"))
+	assert.Error(t, removePeer(&out, node, remote.GetAddress()))
+}
+
+// TestNodeManagementMining tests toggling mining on the node
+func TestNodeManagementMining(t *testing.T) {
+	node := newTestNode(t)
+
+	var out bytes.Buffer
+	assert.Error(t, toggleMining(&out, node, "gold"))
+	assert.False(t, node.IsMining())
+
+	require.NoError(t, toggleMining(&out, node, "leah"))
+	assert.True(t, node.IsMining())
+
+	printNodeStatus(&out, node)
+	assert.Contains(t, out.String(), "Mining: running")
+
+	require.NoError(t, toggleMining(&out, node, "leah"))
+	assert.False(t, node.IsMining())
+}
@@ -42,16 +42,7 @@ func listPeers() {
 		return
 	}
 
-	peers := node.GetPeers()
-	if len(peers) == 0 {
-		fmt.Println("No peers are currently connected")
-		return
-	}
-
-	fmt.Println("\nConnected Peers:")
-	for _, peer := range peers {
-		fmt.Printf("- %s (Last seen: %s)\n", peer.Address, peer.LastSeen.Format("2006-01-02 15:04:05"))
-	}
+	printPeerTable(os.Stdout, node)
 }
 
 func connectToPeer(reader *bufio.Reader) {
@@ -65,12 +56,9 @@ func connectToPeer(reader *bufio.Reader) {
 	address, _ := reader.ReadString('\n')
 	address = strings.TrimSpace(address)
 
-	if err := node.ConnectToPeer(address); err != nil {
+	if err := addPeer(os.Stdout, node, address); err != nil {
 		fmt.Printf("Failed to connect to peer: %v\n", err)
-		return
 	}
-
-	fmt.Printf("Successfully connected to peer at %s\n", address)
 }
 
 func disconnectFromPeer(reader *bufio.Reader) {
@@ -84,10 +72,7 @@ func disconnectFromPeer(reader *bufio.Reader) {
 	address, _ := reader.ReadString('\n')
 	address = strings.TrimSpace(address)
 
-	if err := node.DisconnectPeer(address); err != nil {
+	if err := removePeer(os.Stdout, node, address); err != nil {
 		fmt.Printf("Failed to disconnect from peer: %v\n", err)
-		return
 	}
-
-	fmt.Printf("Successfully disconnected from peer at %s\n", address)
 }
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"byc/internal/blockchain"
+	"byc/internal/coin"
+)
+
+// verifyReport is the output of `byc tx verify`
+type verifyReport struct {
+	TxID     string `json:"tx_id"`
+	IDValid  bool   `json:"id_valid"`
+	Coinbase bool   `json:"coinbase"`
+	Inputs   int    `json:"inputs"`
+	Outputs  int    `json:"outputs"`
+	VSize    int    `json:"vsize"`
+	// SignaturesChecked counts the inputs carrying a public key; the others
+	// cannot be verified offline
+	SignaturesChecked int `json:"signatures_checked"`
+	SignaturesValid   int `json:"signatures_valid"`
+	// Fee and FeeRate are only known when the spent outputs are supplied.
+	// They are paid in FeeCoin, the coin of the transaction's outputs.
+	Fee     *float64            `json:"fee,omitempty"`
+	FeeRate *float64            `json:"fee_rate,omitempty"`
+	FeeCoin blockchain.CoinType `json:"fee_coin,omitempty"`
+	// Context is true when the inputs were checked against -prev transactions
+	Context bool     `json:"context"`
+	Valid   bool     `json:"valid"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// errInvalidTransaction is returned by `byc tx verify` for a transaction that
+// fails verification, after the report is printed
+var errInvalidTransaction = errors.New("transaction is invalid")
+
+// runTxCommand handles `byc tx verify [flags]`
+func runTxCommand(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] != "verify" {
+		return &usageError{"tx requires the verify action"}
+	}
+
+	cmd := flag.NewFlagSet("tx", flag.ContinueOnError)
+	raw := cmd.String("raw", "", "Hex-encoded transaction to verify")
+	prev := cmd.String("prev", "", "Comma-separated hex-encoded transactions whose outputs the inputs spend")
+	asJSON := cmd.Bool("json", false, "Print machine-readable JSON output")
+	if err := parseFlags(cmd, args[1:], stderr); err != nil {
+		return err
+	}
+	if *raw == "" {
+		return &usageError{"tx verify requires -raw"}
+	}
+
+	tx, err := blockchain.DecodeRawTransaction(*raw)
+	if err != nil {
+		return err
+	}
+	var utxoSet *blockchain.UTXOSet
+	if *prev != "" {
+		utxoSet = blockchain.NewUTXOSet()
+		for _, rawPrev := range strings.Split(*prev, ",") {
+			prevTx, err := blockchain.DecodeRawTransaction(rawPrev)
+			if err != nil {
+				return fmt.Errorf("invalid -prev transaction: %v", err)
+			}
+			utxoSet.AddOutputs(prevTx, 0)
+		}
+	}
+
+	report := verifyTransaction(tx, utxoSet)
+	if *asJSON {
+		if err := writeJSON(stdout, report); err != nil {
+			return err
+		}
+	} else {
+		printVerifyReport(stdout, report)
+	}
+	if !report.Valid {
+		return errInvalidTransaction
+	}
+	return nil
+}
+
+// verifyTransaction checks tx offline: its ID, its structure and the
+// signatures of inputs that carry a public key. With utxoSet it also checks
+// the inputs spend outputs in the set that their keys own, and computes the fee.
+func verifyTransaction(tx *blockchain.Transaction, utxoSet *blockchain.UTXOSet) verifyReport {
+	report := verifyReport{
+		TxID:     fmt.Sprintf("%x", tx.ID),
+		IDValid:  true,
+		Coinbase: tx.IsCoinbase(),
+		Inputs:   len(tx.Inputs),
+		Outputs:  len(tx.Outputs),
+		VSize:    tx.VirtualSize(),
+		Context:  utxoSet != nil,
+	}
+	fail := func(format string, args ...interface{}) {
+		report.Errors = append(report.Errors, fmt.Sprintf(format, args...))
+	}
+
+	if err := tx.CheckID(); err != nil {
+		report.IDValid = false
+		fail("%v", err)
+	}
+	if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+		fail("transaction must have inputs and outputs")
+	}
+	for i, output := range tx.Outputs {
+		if output.Value <= 0 {
+			fail("output %d has non-positive value %f", i, output.Value)
+		}
+		if output.CoinType == "" {
+			fail("output %d has no coin type", i)
+		}
+	}
+
+	if !report.Coinbase {
+		for i, input := range tx.Inputs {
+			if len(input.PublicKey) == 0 {
+				continue
+			}
+			report.SignaturesChecked++
+			if tx.VerifyInput(i) {
+				report.SignaturesValid++
+			} else {
+				fail("input %d has an invalid signature", i)
+			}
+		}
+	}
+
+	if utxoSet != nil && !report.Coinbase {
+		if err := tx.Validate(utxoSet); err != nil {
+			fail("%v", err)
+		}
+		var in float64
+		for _, input := range tx.Inputs {
+			in += utxoSet.GetUTXO(input.TxID, input.OutputIndex).Amount
+		}
+		fee := in - tx.GetTotalOutput()
+		if fee < 0 {
+			fail("outputs exceed inputs by %f", -fee)
+		}
+		feeRate := fee / float64(report.VSize)
+		report.Fee, report.FeeRate = &fee, &feeRate
+		if len(tx.Outputs) > 0 {
+			report.FeeCoin = tx.Outputs[0].CoinType
+		}
+	}
+
+	report.Valid = len(report.Errors) == 0
+	return report
+}
+
+// printVerifyReport writes a human-readable verification report
+func printVerifyReport(out io.Writer, report verifyReport) {
+	fmt.Fprintln(out, "\n=== Transaction Verification ===")
+	fmt.Fprintf(out, "TxID: %s\n", report.TxID)
+	fmt.Fprintf(out, "ID matches content: %t\n", report.IDValid)
+	fmt.Fprintf(out, "Inputs: %d, Outputs: %d, Virtual size: %d\n", report.Inputs, report.Outputs, report.VSize)
+	if report.Coinbase {
+		fmt.Fprintln(out, "Coinbase: true")
+	}
+	fmt.Fprintf(out, "Signatures: %d of %d checked valid\n", report.SignaturesValid, report.SignaturesChecked)
+	if report.Fee != nil {
+		fmt.Fprintf(out, "Fee: %s %s (%s per vbyte)\n", coin.FormatAmount(*report.Fee, report.FeeCoin),
+			report.FeeCoin, coin.FormatAmount(*report.FeeRate, report.FeeCoin))
+	} else if !report.Context {
+		fmt.Fprintln(out, "Fee: unknown (pass -prev to check inputs)")
+	}
+	for _, msg := range report.Errors {
+		fmt.Fprintf(out, "Error: %s\n", msg)
+	}
+	if report.Valid {
+		fmt.Fprintln(out, "Result: VALID")
+	} else {
+		fmt.Fprintln(out, "Result: INVALID")
+	}
+	fmt.Fprintln(out, "================================")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+	"byc/internal/crypto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedVerifyTestTx returns a funding transaction and a signed spend of it
+// paying 7 of its 10 Leah, both raw-encoded
+func signedVerifyTestTx(t *testing.T) (*blockchain.Transaction, string, string) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	owner := crypto.HashPublicKey(&key.PublicKey)
+
+	funding := &blockchain.Transaction{
+		Outputs:   []blockchain.TxOutput{{Value: 10, CoinType: blockchain.Leah, PublicKeyHash: owner, Address: "owner"}},
+		Timestamp: time.Now(),
+		Nonce:     blockchain.NewTxNonce(),
+	}
+	funding.RecomputeID()
+
+	spend := &blockchain.Transaction{
+		Inputs: []blockchain.TxInput{{
+			TxID:      funding.ID,
+			Amount:    10,
+			PublicKey: crypto.PublicKeyToBytes(&key.PublicKey),
+		}},
+		Outputs:   []blockchain.TxOutput{{Value: 7, CoinType: blockchain.Leah, PublicKeyHash: []byte("recipient"), Address: "recipient"}},
+		Timestamp: time.Now(),
+		Nonce:     blockchain.NewTxNonce(),
+	}
+	spend.RecomputeID()
+	require.NoError(t, spend.Sign(key.D.Bytes()))
+
+	rawSpend, err := blockchain.EncodeRawTransaction(spend)
+	require.NoError(t, err)
+	rawFunding, err := blockchain.EncodeRawTransaction(funding)
+	require.NoError(t, err)
+	return spend, rawSpend, rawFunding
+}
+
+// TestRunCommandTxVerify tests that tx verify passes a signed transaction and
+// fails it once tampered with
+func TestRunCommandTxVerify(t *testing.T) {
+	spend, rawSpend, rawFunding := signedVerifyTestTx(t)
+
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"tx", "verify", "-raw", rawSpend, "-prev", rawFunding, "-json"}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+
+	var report verifyReport
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.True(t, report.Valid)
+	assert.True(t, report.IDValid)
+	assert.Equal(t, 1, report.SignaturesChecked)
+	assert.Equal(t, 1, report.SignaturesValid)
+	require.NotNil(t, report.Fee)
+	assert.InDelta(t, 3.0, *report.Fee, 1e-9)
+
+	// Without the spent outputs only the structure and signatures are checked
+	stdout.Reset()
+	code = runCommand([]string{"tx", "verify", "-raw", rawSpend}, &stdout, &stderr)
+	assert.Equal(t, exitOK, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Result: VALID")
+
+	// Paying more to the recipient changes the content under the ID and signature
+	tampered := spend.Copy()
+	tampered.Outputs[0].Value = 9.5
+	rawTampered, err := blockchain.EncodeRawTransaction(tampered)
+	require.NoError(t, err)
+
+	stdout.Reset()
+	stderr.Reset()
+	code = runCommand([]string{"tx", "verify", "-raw", rawTampered, "-prev", rawFunding, "-json"}, &stdout, &stderr)
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), errInvalidTransaction.Error())
+
+	report = verifyReport{}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.False(t, report.Valid)
+	assert.False(t, report.IDValid)
+	assert.NotEmpty(t, report.Errors)
+
+	// Recomputing the ID still leaves the signature committing to the old content
+	tampered.RecomputeID()
+	rawTampered, err = blockchain.EncodeRawTransaction(tampered)
+	require.NoError(t, err)
+
+	stdout.Reset()
+	stderr.Reset()
+	code = runCommand([]string{"tx", "verify", "-raw", rawTampered, "-json"}, &stdout, &stderr)
+	assert.Equal(t, exitError, code)
+
+	report = verifyReport{}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.True(t, report.IDValid)
+	assert.Equal(t, 0, report.SignaturesValid)
+}
+
+// TestRunCommandTxVerifyUsage tests that tx verify requires a raw transaction
+func TestRunCommandTxVerifyUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitUsage, runCommand([]string{"tx", "verify"}, &stdout, &stderr))
+	assert.Equal(t, exitUsage, runCommand([]string{"tx", "explode"}, &stdout, &stderr))
+	assert.Equal(t, exitError, runCommand([]string{"tx", "verify", "-raw", "zz"}, &stdout, &stderr))
+}
@@ -2,110 +2,460 @@ package main
 
 import (
 	"bufio"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"byc/internal/blockchain"
+	"byc/internal/coin"
+	"byc/internal/crypto"
 	"byc/internal/wallet"
 )
 
-func handleWallet(cmd *flag.FlagSet) {
-	// Get the action from the flag
-	action := cmd.Lookup("action").Value.String()
+// miningWalletInfo is the on-disk format of wallets/mining_wallet.json
+type miningWalletInfo struct {
+	Address string
+	Rewards map[string]float64
+}
+
+// historyEntry is a single row of wallet history output
+type historyEntry struct {
+	TxID      string    `json:"tx_id"`
+	Direction string    `json:"direction"`
+	Amount    float64   `json:"amount"`
+	CoinType  string    `json:"coin_type"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// feeEstimate is the output of the estimate-fee action
+type feeEstimate struct {
+	Amount   float64 `json:"amount"`
+	CoinType string  `json:"coin_type"`
+	Fee      float64 `json:"fee"`
+	Total    float64 `json:"total"`
+}
+
+// newWalletFlagSet creates the flag set used by the wallet command
+func newWalletFlagSet() *flag.FlagSet {
+	cmd := flag.NewFlagSet("wallet", flag.ContinueOnError)
+	cmd.String("address", "", "Address to query instead of the mining wallet")
+	cmd.String("coin", "", "Coin type to show, or to estimate the fee for (default leah)")
+	cmd.String("amount", "", "Amount used by estimate-fee")
+	cmd.Bool("json", false, "Print machine-readable JSON output")
+	return cmd
+}
+
+// handleWallet runs a wallet action with flags already parsed into cmd
+func handleWallet(action string, cmd *flag.FlagSet, out io.Writer) error {
+	asJSON, _ := strconv.ParseBool(cmd.Lookup("json").Value.String())
+	address := cmd.Lookup("address").Value.String()
+
+	// An empty coin type shows every coin
+	var coinType blockchain.CoinType
+	if name := cmd.Lookup("coin").Value.String(); name != "" {
+		parsed, err := blockchain.ParseCoinType(name)
+		if err != nil {
+			return &usageError{err.Error()}
+		}
+		coinType = parsed
+	}
 
 	switch action {
 	case "create":
-		createWallet()
+		return createWallet(out)
 	case "balance":
-		showBalance()
+		bc, err := loadBlockchain()
+		if err != nil {
+			return err
+		}
+		if address != "" {
+			return showAddressBalance(out, bc, address, coinType, asJSON)
+		}
+		return showBalance(out, bc, coinType, asJSON)
+	case "history":
+		bc, err := loadBlockchain()
+		if err != nil {
+			return err
+		}
+		return showHistory(out, bc, coinType, asJSON)
+	case "estimate-fee":
+		if coinType == "" {
+			coinType = blockchain.Leah
+		}
+		amount, err := coin.ParseAmount(cmd.Lookup("amount").Value.String(), coinType)
+		if err != nil {
+			return err
+		}
+		bc, err := loadBlockchain()
+		if err != nil {
+			return err
+		}
+		return showFeeEstimate(out, bc, amount, string(coinType), asJSON)
 	case "send":
 		handleSendCoins()
+		return nil
 	default:
-		fmt.Println("Please specify an action: create, balance, or send")
-		os.Exit(1)
+		return &usageError{fmt.Sprintf("unknown wallet action %q (valid: create, balance, history, estimate-fee, send)", action)}
 	}
 }
 
-func createWallet() {
+// walletKeyFile is the file in the wallets directory holding the user
+// wallet's private key, hex encoded
+const walletKeyFile = "wallet.key"
+
+func createWallet(out io.Writer) error {
+	path := filepath.Join(dataPaths.Wallets, walletKeyFile)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("a wallet already exists at %s", path)
+	}
+
 	// Create a new wallet
 	w, err := wallet.NewWallet()
 	if err != nil {
-		fmt.Printf("Error creating wallet: %v\n", err)
-		return
+		return fmt.Errorf("failed to create wallet: %v", err)
+	}
+	if err := saveWalletKey(path, w); err != nil {
+		return err
 	}
 
-	fmt.Println("\n=== New Wallet Created ===")
-	fmt.Printf("Address: %s\n", w.Address)
-	fmt.Println("Please save this address securely!")
-	fmt.Println("===========================\n")
+	fmt.Fprintln(out, "\n=== New Wallet Created ===")
+	fmt.Fprintf(out, "Address: %s\n", w.Address)
+	fmt.Fprintf(out, "Key saved to: %s\n", path)
+	fmt.Fprintln(out, "Please back up this file securely!")
+	fmt.Fprintln(out, "===========================")
+	return nil
 }
 
-func showBalance() {
-	// Get the mining wallet
-	walletsDir := "wallets"
-	walletFile := filepath.Join(walletsDir, "mining_wallet.json")
+// saveWalletKey writes w's private key to path, readable only by its owner
+func saveWalletKey(path string, w *wallet.Wallet) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create wallets directory: %v", err)
+	}
+	key := hex.EncodeToString(crypto.PrivateKeyToBytes(w.PrivateKey))
+	if err := os.WriteFile(path, []byte(key+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write wallet key: %v", err)
+	}
+	return nil
+}
+
+// loadUserWallet loads the wallet whose key `byc wallet create` saved
+func loadUserWallet() (*wallet.Wallet, error) {
+	path := filepath.Join(dataPaths.Wallets, walletKeyFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no wallet found, create one with `byc wallet create`")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wallet key: %v", err)
+	}
+
+	keyBytes, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse wallet key %s: %v", path, err)
+	}
+	key, err := crypto.BytesToPrivateKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse wallet key %s: %v", path, err)
+	}
+	return wallet.NewWalletFromKey(key), nil
+}
+
+// loadMiningWallet reads the mining wallet written by the miner
+func loadMiningWallet() (*miningWalletInfo, error) {
+	walletFile := filepath.Join(dataPaths.Wallets, "mining_wallet.json")
 
 	if _, err := os.Stat(walletFile); err != nil {
-		fmt.Println("No wallet found. Please mine some coins first.")
-		return
+		return nil, fmt.Errorf("no wallet found, please mine some coins first")
 	}
 
 	// Read wallet file
 	data, err := os.ReadFile(walletFile)
 	if err != nil {
-		fmt.Printf("Error reading wallet file: %v\n", err)
-		return
+		return nil, fmt.Errorf("failed to read wallet file: %v", err)
 	}
 
-	var walletInfo struct {
-		Address string
-		Rewards map[string]float64
-	}
+	var walletInfo miningWalletInfo
 	if err := json.Unmarshal(data, &walletInfo); err != nil {
-		fmt.Printf("Error parsing wallet file: %v\n", err)
-		return
+		return nil, fmt.Errorf("failed to parse wallet file: %v", err)
+	}
+	return &walletInfo, nil
+}
+
+// writeJSON writes v to out as indented JSON
+func writeJSON(out io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// showBalance prints the mining wallet's balance, of only coinType unless
+// it is empty
+func showBalance(out io.Writer, bc *blockchain.Blockchain, coinType blockchain.CoinType, asJSON bool) error {
+	walletInfo, err := loadMiningWallet()
+	if err != nil {
+		return err
+	}
+
+	balances := filterBalances(walletInfo.Rewards, coinType)
+	pending := pendingBalances(bc, walletInfo.Address, coinType)
+
+	if asJSON {
+		return writeJSON(out, struct {
+			Address  string             `json:"address"`
+			Balances map[string]float64 `json:"balances"`
+			pendingBalanceJSON
+		}{walletInfo.Address, balances, pending})
 	}
 
-	fmt.Println("\n=== Wallet Balance ===")
-	fmt.Printf("Address: %s\n", walletInfo.Address)
-	fmt.Println("\nRewards:")
-	for coinType, amount := range walletInfo.Rewards {
-		fmt.Printf("%s: %.2f\n", coinType, amount)
+	fmt.Fprintln(out, "\n=== Wallet Balance ===")
+	fmt.Fprintf(out, "Address: %s\n", walletInfo.Address)
+	fmt.Fprintln(out, "\nRewards:")
+	for coinType, amount := range balances {
+		fmt.Fprintf(out, "%s: %s\n", coinType, coin.FormatAmount(amount, blockchain.CoinType(coinType)))
 	}
-	fmt.Println("=====================\n")
+	printPendingBalances(out, pending)
+	fmt.Fprintln(out, "=====================")
+	return nil
 }
 
-func handleSendCoins() {
-	// Create a new blockchain instance
-	bc := blockchain.NewBlockchain()
+// showAddressBalance prints the UTXO balance of an arbitrary address, of
+// only coinType unless it is empty
+func showAddressBalance(out io.Writer, bc *blockchain.Blockchain, address string, coinType blockchain.CoinType, asJSON bool) error {
+	balances := make(map[string]float64)
+	for _, ct := range blockchain.AllCoinTypes {
+		if coinType != "" && ct != coinType {
+			continue
+		}
+		if balance := bc.UTXOSet.GetBalance(address, ct); balance > 0 {
+			balances[string(ct)] = balance
+		}
+	}
+	pending := pendingBalances(bc, address, coinType)
 
-	// Get the mining wallet
-	walletsDir := "wallets"
-	walletFile := filepath.Join(walletsDir, "mining_wallet.json")
+	if asJSON {
+		return writeJSON(out, struct {
+			Address  string             `json:"address"`
+			Balances map[string]float64 `json:"balances"`
+			pendingBalanceJSON
+		}{address, balances, pending})
+	}
 
-	if _, err := os.Stat(walletFile); err != nil {
-		fmt.Println("No wallet found. Please mine some coins first.")
+	fmt.Fprintln(out, "\n=== Address Balance ===")
+	fmt.Fprintf(out, "Address: %s\n", address)
+	if len(balances) == 0 {
+		fmt.Fprintln(out, "No funds")
+	}
+	for coinType, amount := range balances {
+		fmt.Fprintf(out, "%s: %s\n", coinType, coin.FormatAmount(amount, blockchain.CoinType(coinType)))
+	}
+	printPendingBalances(out, pending)
+	fmt.Fprintln(out, "=======================")
+	return nil
+}
+
+// pendingBalanceJSON holds the unconfirmed part of a balance, keyed by coin type
+type pendingBalanceJSON struct {
+	PendingIncoming map[string]float64 `json:"pending_incoming,omitempty"`
+	PendingOutgoing map[string]float64 `json:"pending_outgoing,omitempty"`
+	Immature        map[string]float64 `json:"immature,omitempty"`
+}
+
+// filterBalances returns the balances of coinType alone, or all of them if
+// coinType is empty. The result is never nil.
+func filterBalances(balances map[string]float64, coinType blockchain.CoinType) map[string]float64 {
+	filtered := make(map[string]float64)
+	for name, amount := range balances {
+		if coinType == "" || name == string(coinType) {
+			filtered[name] = amount
+		}
+	}
+	return filtered
+}
+
+// pendingBalances collects pending incoming and outgoing amounts for address,
+// of only coinType unless it is empty
+func pendingBalances(bc *blockchain.Blockchain, address string, only blockchain.CoinType) pendingBalanceJSON {
+	var pending pendingBalanceJSON
+	if bc == nil {
+		return pending
+	}
+
+	w := &wallet.Wallet{Address: address}
+	for _, coinType := range blockchain.AllCoinTypes {
+		if only != "" && coinType != only {
+			continue
+		}
+		detail := w.GetBalanceDetailed(coinType, bc)
+		if detail.PendingIncoming > 0 {
+			if pending.PendingIncoming == nil {
+				pending.PendingIncoming = make(map[string]float64)
+			}
+			pending.PendingIncoming[string(coinType)] = detail.PendingIncoming
+		}
+		if detail.PendingOutgoing > 0 {
+			if pending.PendingOutgoing == nil {
+				pending.PendingOutgoing = make(map[string]float64)
+			}
+			pending.PendingOutgoing[string(coinType)] = detail.PendingOutgoing
+		}
+		if detail.Immature > 0 {
+			if pending.Immature == nil {
+				pending.Immature = make(map[string]float64)
+			}
+			pending.Immature[string(coinType)] = detail.Immature
+		}
+	}
+	return pending
+}
+
+// printPendingBalances prints the pending section of a balance view
+func printPendingBalances(out io.Writer, pending pendingBalanceJSON) {
+	if len(pending.PendingIncoming) == 0 && len(pending.PendingOutgoing) == 0 && len(pending.Immature) == 0 {
 		return
 	}
+	fmt.Fprintln(out, "\nPending:")
+	for coinType, amount := range pending.PendingIncoming {
+		fmt.Fprintf(out, "%s incoming: %s\n", coinType, coin.FormatAmount(amount, blockchain.CoinType(coinType)))
+	}
+	for coinType, amount := range pending.PendingOutgoing {
+		fmt.Fprintf(out, "%s outgoing (locked): %s\n", coinType, coin.FormatAmount(amount, blockchain.CoinType(coinType)))
+	}
+	for coinType, amount := range pending.Immature {
+		fmt.Fprintf(out, "%s immature coinbase: %s\n", coinType, coin.FormatAmount(amount, blockchain.CoinType(coinType)))
+	}
+}
 
-	// Read wallet file
-	data, err := os.ReadFile(walletFile)
+// showHistory prints the mining wallet's transactions, those in coinType
+// only unless it is empty
+func showHistory(out io.Writer, bc *blockchain.Blockchain, coinType blockchain.CoinType, asJSON bool) error {
+	walletInfo, err := loadMiningWallet()
 	if err != nil {
-		fmt.Printf("Error reading wallet file: %v\n", err)
-		return
+		return err
+	}
+
+	txs, err := bc.GetTransactions(walletInfo.Address)
+	if err != nil {
+		return fmt.Errorf("failed to get transactions: %v", err)
 	}
 
-	var walletInfo struct {
-		Address string
-		Rewards map[string]float64
+	entries := make([]historyEntry, 0, len(txs))
+	seen := make(map[string]bool)
+	for _, tx := range txs {
+		txID := hex.EncodeToString(tx.ID)
+		if seen[txID] {
+			continue
+		}
+		seen[txID] = true
+		if coinType != "" && !paysCoinType(tx, coinType) {
+			continue
+		}
+
+		entry := historyEntry{
+			TxID:      txID,
+			Direction: "received",
+			Timestamp: tx.Timestamp,
+		}
+		for _, input := range tx.Inputs {
+			if input.Address == walletInfo.Address {
+				entry.Direction = "sent"
+				break
+			}
+		}
+		for _, output := range tx.Outputs {
+			if entry.CoinType == "" {
+				entry.CoinType = string(output.CoinType)
+			}
+			toSelf := output.Address == walletInfo.Address
+			if (entry.Direction == "received") == toSelf {
+				entry.Amount += output.Value
+			}
+		}
+		entries = append(entries, entry)
 	}
-	if err := json.Unmarshal(data, &walletInfo); err != nil {
-		fmt.Printf("Error parsing wallet file: %v\n", err)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+
+	if asJSON {
+		return writeJSON(out, entries)
+	}
+
+	fmt.Fprintln(out, "\n=== Transaction History ===")
+	fmt.Fprintf(out, "Address: %s\n", walletInfo.Address)
+	if len(entries) == 0 {
+		fmt.Fprintln(out, "No transactions found")
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(out, "%s %-8s %s %s (%s)\n", entry.Timestamp.Format("2006-01-02 15:04:05"),
+			entry.Direction, coin.FormatAmount(entry.Amount, blockchain.CoinType(entry.CoinType)), entry.CoinType, entry.TxID)
+	}
+	fmt.Fprintln(out, "===========================")
+	return nil
+}
+
+// paysCoinType reports whether any output of tx is in coinType
+func paysCoinType(tx *blockchain.Transaction, coinType blockchain.CoinType) bool {
+	for _, output := range tx.Outputs {
+		if output.CoinType == coinType {
+			return true
+		}
+	}
+	return false
+}
+
+func showFeeEstimate(out io.Writer, bc *blockchain.Blockchain, amount float64, coinName string, asJSON bool) error {
+	if amount <= 0 {
+		return fmt.Errorf("amount must be greater than 0")
+	}
+	coinType, err := blockchain.ParseCoinType(coinName)
+	if err != nil {
+		return err
+	}
+
+	// Estimate from the mining wallet's outputs when there is one
+	w := &wallet.Wallet{}
+	if walletInfo, err := loadMiningWallet(); err == nil {
+		w.Address = walletInfo.Address
+	}
+	estimate := feeEstimate{
+		Amount:   amount,
+		CoinType: string(coinType),
+		Fee:      w.EstimateTransactionFee(amount, coinType, bc),
+	}
+	estimate.Total = estimate.Amount + estimate.Fee
+
+	if asJSON {
+		return writeJSON(out, estimate)
+	}
+
+	fmt.Fprintln(out, "\n=== Fee Estimate ===")
+	fmt.Fprintf(out, "Amount: %s %s\n", coin.FormatAmount(estimate.Amount, coinType), coinType)
+	fmt.Fprintf(out, "Fee: %s %s\n", coin.FormatAmount(estimate.Fee, coinType), coinType)
+	fmt.Fprintf(out, "Total: %s %s\n", coin.FormatAmount(estimate.Total, coinType), coinType)
+	fmt.Fprintln(out, "====================")
+	return nil
+}
+
+func handleSendCoins() {
+	// Spend from the chain the node checkpointed
+	bc, err := loadBlockchain()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	// Get the mining wallet
+	walletInfo, err := loadMiningWallet()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
@@ -118,12 +468,6 @@ func handleSendCoins() {
 	// Get amount
 	fmt.Print("Enter amount to send: ")
 	amountStr, _ := reader.ReadString('\n')
-	amountStr = strings.TrimSpace(amountStr)
-	amount, err := strconv.ParseFloat(amountStr, 64)
-	if err != nil {
-		fmt.Println("Invalid amount")
-		return
-	}
 
 	// Get coin type
 	fmt.Println("\nSelect coin type:")
@@ -147,9 +491,15 @@ func handleSendCoins() {
 		return
 	}
 
+	amount, err := coin.ParseAmount(amountStr, coinType)
+	if err != nil {
+		fmt.Printf("Invalid amount: %v\n", err)
+		return
+	}
+
 	// Check if we have enough balance
 	if walletInfo.Rewards[string(coinType)] < amount {
-		fmt.Printf("Insufficient balance. You have %.2f %s\n", walletInfo.Rewards[string(coinType)], coinType)
+		fmt.Printf("Insufficient balance. You have %s %s\n", coin.FormatAmount(walletInfo.Rewards[string(coinType)], coinType), coinType)
 		return
 	}
 
@@ -161,6 +511,12 @@ func handleSendCoins() {
 		return
 	}
 
+	// Check the transaction would be accepted before submitting it
+	if err := w.TestAccept(tx, bc); err != nil {
+		fmt.Printf("Transaction rejected: %v\n", err)
+		return
+	}
+
 	// Add transaction to blockchain
 	if err := bc.AddTransaction(*tx); err != nil {
 		fmt.Printf("Error adding transaction: %v\n", err)
@@ -168,7 +524,7 @@ func handleSendCoins() {
 	}
 
 	fmt.Printf("\nTransaction sent successfully!\n")
-	fmt.Printf("Amount: %.2f %s\n", amount, coinType)
+	fmt.Printf("Amount: %s %s\n", coin.FormatAmount(amount, coinType), coinType)
 	fmt.Printf("To: %s\n", recipient)
 	fmt.Printf("Transaction ID: %x\n", tx.ID)
 }
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+	"byc/internal/crypto"
+	"byc/internal/wallet"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withMiningWallet runs the test from a temp dir containing a mining wallet
+func withMiningWallet(t *testing.T, info miningWalletInfo) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "wallets"), 0755))
+	data, err := json.Marshal(info)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "wallets", "mining_wallet.json"), data, 0644))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// TestShowBalanceJSON tests the JSON output of the balance action
+func TestShowBalanceJSON(t *testing.T) {
+	withMiningWallet(t, miningWalletInfo{
+		Address: "miner-address",
+		Rewards: map[string]float64{"LEAH": 12.5},
+	})
+
+	var out bytes.Buffer
+	require.NoError(t, showBalance(&out, blockchain.NewBlockchain(), "", true))
+
+	var result struct {
+		Address  string             `json:"address"`
+		Balances map[string]float64 `json:"balances"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	assert.Equal(t, "miner-address", result.Address)
+	assert.Equal(t, 12.5, result.Balances["LEAH"])
+}
+
+// TestShowBalanceCoinFilter tests that a coin type limits the balance
+// shown to that coin
+func TestShowBalanceCoinFilter(t *testing.T) {
+	withMiningWallet(t, miningWalletInfo{
+		Address: "miner-address",
+		Rewards: map[string]float64{"LEAH": 12.5, "SHIBLUM": 2},
+	})
+
+	var out bytes.Buffer
+	require.NoError(t, showBalance(&out, blockchain.NewBlockchain(), blockchain.Shiblum, true))
+
+	var result struct {
+		Balances map[string]float64 `json:"balances"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	assert.Equal(t, map[string]float64{"SHIBLUM": 2}, result.Balances)
+
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"wallet", "balance", "-coin", "leah", "-json"}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+	var leah struct {
+		Balances map[string]float64 `json:"balances"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &leah))
+	assert.Equal(t, map[string]float64{"LEAH": 12.5}, leah.Balances)
+}
+
+// TestShowHistoryJSON tests the JSON output of the history action
+func TestShowHistoryJSON(t *testing.T) {
+	withMiningWallet(t, miningWalletInfo{Address: "miner-address"})
+
+	bc := blockchain.NewBlockchain()
+	bc.GoldenBlocks[0].Transactions = append(bc.GoldenBlocks[0].Transactions, blockchain.Transaction{
+		ID:      []byte{0x01},
+		Outputs: []blockchain.TxOutput{{Value: 3, CoinType: blockchain.Leah, Address: "miner-address"}},
+	})
+
+	var out bytes.Buffer
+	require.NoError(t, showHistory(&out, bc, "", true))
+
+	var entries []map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entries))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "01", entries[0]["tx_id"])
+	assert.Equal(t, "received", entries[0]["direction"])
+	assert.Equal(t, 3.0, entries[0]["amount"])
+	assert.Equal(t, "LEAH", entries[0]["coin_type"])
+	assert.Contains(t, entries[0], "timestamp")
+}
+
+// TestWalletHistoryReadsCheckpointedChain tests that the history command
+// shows the transactions of the chain the node saved to the data directory
+func TestWalletHistoryReadsCheckpointedChain(t *testing.T) {
+	withMiningWallet(t, miningWalletInfo{Address: "miner-address"})
+
+	bc := blockchain.NewBlockchain()
+	template, err := bc.GetBlockTemplate(blockchain.GoldenBlock, blockchain.Leah, "miner-address")
+	require.NoError(t, err)
+	var block blockchain.Block
+	for nonce := uint64(0); ; nonce++ {
+		if block = template.Assemble(nonce); bytes.Compare(block.Hash, template.Target) <= 0 {
+			break
+		}
+	}
+	require.NoError(t, bc.AddBlock(block))
+	_, err = bc.SaveChainState(dataPaths.Root)
+	require.NoError(t, err)
+
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"wallet", "history", "-json"}, &stdout, &stderr)
+	require.Equal(t, exitOK, code, stderr.String())
+	var entries []historyEntry
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &entries))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "received", entries[0].Direction)
+	assert.Equal(t, string(blockchain.Leah), entries[0].CoinType)
+}
+
+// TestShowFeeEstimateJSON tests the JSON output of the estimate-fee action
+func TestShowFeeEstimateJSON(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, showFeeEstimate(&out, blockchain.NewBlockchain(), 10, "shiblum", true))
+
+	var estimate map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &estimate))
+	assert.Equal(t, 10.0, estimate["amount"])
+	assert.Equal(t, "SHIBLUM", estimate["coin_type"])
+	assert.Greater(t, estimate["fee"], 0.0)
+	assert.Contains(t, estimate, "total")
+
+	// Unknown coins are rejected
+	assert.Error(t, showFeeEstimate(&out, blockchain.NewBlockchain(), 10, "gold", true))
+}
+
+// TestRunAdvancedWalletAction tests that the Advanced Wallet menu mints
+// special coins from a funded wallet's outputs and fails for an empty one
+func TestRunAdvancedWalletAction(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+
+	empty, err := wallet.NewWallet()
+	require.NoError(t, err)
+	var out bytes.Buffer
+	for choice := 1; choice <= 3; choice++ {
+		assert.Error(t, runAdvancedWalletAction(&out, bc, empty.AsInterface(), choice))
+	}
+	assert.Empty(t, bc.GetPendingTransactions())
+	assert.ErrorIs(t, bc.CreateEphraimCoin(nil), blockchain.ErrNoWallet)
+
+	w, err := wallet.NewWallet()
+	require.NoError(t, err)
+	owner := crypto.HashPublicKey(w.PublicKey)
+	for _, funding := range []struct {
+		coinType blockchain.CoinType
+		value    float64
+	}{
+		// Ephraim's requirements, with Leah for its fee and Joseph's
+		{blockchain.Leah, 2},
+		{blockchain.Leah, 1},
+		{blockchain.Shiblum, 1},
+		{blockchain.Shiblon, 2},
+		{blockchain.Senine, 3},
+		{blockchain.Seon, 5},
+		{blockchain.Shum, 8},
+		{blockchain.Limnah, 13},
+		{blockchain.Antion, 21},
+		// Manasseh's, with Senum for its fee
+		{blockchain.Senum, 2},
+		{blockchain.Amnor, 1},
+		{blockchain.Ezrom, 2},
+		{blockchain.Onti, 3},
+		{blockchain.Antion, 21},
+		// Joseph's
+		{blockchain.Ephraim, 1},
+		{blockchain.Manasseh, 1},
+	} {
+		tx := &blockchain.Transaction{
+			Outputs:   []blockchain.TxOutput{{Value: funding.value, CoinType: funding.coinType, Address: w.Address, PublicKeyHash: owner}},
+			Timestamp: time.Now(),
+			Nonce:     blockchain.NewTxNonce(),
+		}
+		tx.RecomputeID()
+		require.NoError(t, bc.UTXOSet.UpdateWithTransaction(tx))
+	}
+	bc.GoldenBlocks = append(bc.GoldenBlocks, blockchain.Block{})
+
+	minted := map[int]blockchain.CoinType{1: blockchain.Ephraim, 2: blockchain.Manasseh, 3: blockchain.Joseph}
+	for choice := 1; choice <= 3; choice++ {
+		require.NoError(t, runAdvancedWalletAction(&out, bc, w.AsInterface(), choice))
+
+		// The minting transaction spends the wallet's outputs into the pool
+		pending := bc.GetPendingTransactions()
+		require.Len(t, pending, choice)
+		tx := pending[choice-1]
+		assert.Equal(t, minted[choice], tx.Outputs[0].CoinType)
+		assert.Equal(t, 1.0, tx.Outputs[0].Value)
+		assert.True(t, tx.Verify())
+		assert.Greater(t, tx.GetFee(), 0.0)
+	}
+	assert.Contains(t, out.String(), "Joseph coin created successfully")
+
+	out.Reset()
+	require.NoError(t, runAdvancedWalletAction(&out, bc, w.AsInterface(), 4))
+	assert.Contains(t, out.String(), "- Ephraim: 1")
+	assert.Contains(t, out.String(), "- Manasseh: 1")
+	assert.Error(t, runAdvancedWalletAction(&out, bc, w.AsInterface(), 9))
+}
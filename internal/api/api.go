@@ -4,9 +4,12 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
+	"strconv"
 
+	"byc/internal/api/middleware"
 	"byc/internal/blockchain"
 	"byc/internal/logger"
 	"byc/internal/network"
@@ -51,10 +54,15 @@ func NewServer(bc *blockchain.Blockchain, config *Config) *Server {
 
 // registerRoutes registers all API routes
 func (s *Server) registerRoutes() {
+	// Tag every request's log lines with its ID
+	s.router.Use(middleware.RequestID)
+
 	// Blockchain routes
 	s.router.HandleFunc("/api/blocks", s.getBlocks).Methods("GET")
 	s.router.HandleFunc("/api/blocks/{hash}", s.getBlock).Methods("GET")
+	s.router.HandleFunc("/api/blocks/{hash}/summary", s.getBlockSummary).Methods("GET")
 	s.router.HandleFunc("/api/blocks/latest", s.getLatestBlock).Methods("GET")
+	s.router.HandleFunc("/api/difficulty", s.getDifficultyHistory).Methods("GET")
 
 	// Transaction routes
 	s.router.HandleFunc("/api/transactions", s.getTransactions).Methods("GET")
@@ -83,6 +91,17 @@ func (s *Server) registerRoutes() {
 
 	// Mine route
 	s.router.HandleFunc("/mine", s.mine).Methods("POST")
+
+	// Mempool routes
+	s.router.HandleFunc("/api/mempool/fees", s.getMempoolFees).Methods("GET")
+	s.router.HandleFunc("/api/mempool/dependencies", s.getMempoolDependencies).Methods("GET")
+	s.router.HandleFunc("/api/fee/estimate", s.estimateFee).Methods("GET")
+
+	// Transaction tools
+	s.router.HandleFunc("/api/tx/decode", s.decodeTransaction).Methods("POST")
+
+	// Debug routes
+	s.router.HandleFunc("/api/debug/orphans", s.getOrphans).Methods("GET")
 }
 
 // Start starts the API server
@@ -106,6 +125,7 @@ func (s *Server) Start() error {
 		Address:        p2pAddress,
 		BlockType:      s.config.BlockType,
 		BootstrapPeers: s.config.BootstrapPeers,
+		Blockchain:     s.blockchain,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to start node: %v", err)
@@ -173,7 +193,9 @@ func (s *Server) sendResponse(w http.ResponseWriter, status int, data interface{
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		logger.Error("Failed to encode response", zap.Error(err))
+		logger.Error("Failed to encode response",
+			zap.String("request_id", w.Header().Get(middleware.RequestIDHeader)),
+			zap.Error(err))
 	}
 }
 
@@ -218,6 +240,62 @@ func (s *Server) getBlock(w http.ResponseWriter, r *http.Request) {
 	s.sendResponse(w, http.StatusOK, block, nil)
 }
 
+// getBlockSummary returns the explorer summary of a specific block
+func (s *Server) getBlockSummary(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	hashHex := vars["hash"]
+
+	hash, err := hex.DecodeString(hashHex)
+	if err != nil {
+		s.sendResponse(w, http.StatusBadRequest, nil, fmt.Errorf("invalid hash encoding: %v", err))
+		return
+	}
+
+	summary, err := s.blockchain.GetBlockSummary(hash)
+	if err != nil {
+		s.sendResponse(w, http.StatusNotFound, nil, err)
+		return
+	}
+
+	s.sendResponse(w, http.StatusOK, summary, nil)
+}
+
+// getDifficultyHistory returns the difficulty of each block in a height range.
+// from defaults to the genesis block and to to the chain tip.
+func (s *Server) getDifficultyHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var blockType blockchain.BlockType
+	switch query.Get("type") {
+	case "golden":
+		blockType = blockchain.GoldenBlock
+	case "silver":
+		blockType = blockchain.SilverBlock
+	default:
+		s.sendResponse(w, http.StatusBadRequest, nil, fmt.Errorf("invalid block type"))
+		return
+	}
+
+	from, to := int64(0), int64(math.MaxInt64)
+	if v := query.Get("from"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.sendResponse(w, http.StatusBadRequest, nil, fmt.Errorf("invalid from height: %v", err))
+			return
+		}
+		from = n
+	}
+	if v := query.Get("to"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.sendResponse(w, http.StatusBadRequest, nil, fmt.Errorf("invalid to height: %v", err))
+			return
+		}
+		to = n
+	}
+
+	s.sendResponse(w, http.StatusOK, s.blockchain.GetDifficultyHistory(blockType, from, to), nil)
+}
+
 // getLatestBlock returns the latest block
 func (s *Server) getLatestBlock(w http.ResponseWriter, r *http.Request) {
 	blockType := r.URL.Query().Get("type")
@@ -269,7 +347,7 @@ func (s *Server) createTransaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.blockchain.AddTransaction(&tx); err != nil {
+	if err := s.blockchain.AddTransaction(tx); err != nil {
 		s.sendResponse(w, http.StatusBadRequest, nil, err)
 		return
 	}
@@ -354,8 +432,8 @@ func (s *Server) getMiningStatus(w http.ResponseWriter, r *http.Request) {
 		IsMining bool   `json:"is_mining"`
 		CoinType string `json:"coin_type,omitempty"`
 	}{
-		IsMining: s.node.Config.BlockType != "",
-		CoinType: string(s.node.Config.BlockType),
+		IsMining: s.node.IsMining(),
+		CoinType: string(s.node.MiningChain()),
 	}
 
 	s.sendResponse(w, http.StatusOK, status, nil)
@@ -384,9 +462,10 @@ func (s *Server) addPeer(w http.ResponseWriter, r *http.Request) {
 
 // handleWebSocket handles WebSocket connections
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		logger.Error("Failed to upgrade connection", zap.Error(err))
+		log.Error("Failed to upgrade connection", zap.Error(err))
 		return
 	}
 	defer conn.Close()
@@ -395,13 +474,13 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	for {
 		messageType, p, err := conn.ReadMessage()
 		if err != nil {
-			logger.Error("Failed to read message", zap.Error(err))
+			log.Error("Failed to read message", zap.Error(err))
 			return
 		}
 
 		// Echo the message back
 		if err := conn.WriteMessage(messageType, p); err != nil {
-			logger.Error("Failed to write message", zap.Error(err))
+			log.Error("Failed to write message", zap.Error(err))
 			return
 		}
 	}
@@ -428,6 +507,77 @@ func (s *Server) handleGetNodeInfo(w http.ResponseWriter, r *http.Request) {
 	s.sendResponse(w, http.StatusOK, info, nil)
 }
 
+// getMempoolFees returns the pending transactions bucketed by fee rate
+func (s *Server) getMempoolFees(w http.ResponseWriter, r *http.Request) {
+	s.sendResponse(w, http.StatusOK, s.blockchain.MempoolFeeHistogram(), nil)
+}
+
+// getMempoolDependencies returns the pending parents of each pending transaction
+func (s *Server) getMempoolDependencies(w http.ResponseWriter, r *http.Request) {
+	s.sendResponse(w, http.StatusOK, s.blockchain.MempoolDependencies(), nil)
+}
+
+// feeEstimate is the fee rate, per virtual byte, estimated to confirm a
+// transaction within Blocks blocks
+type feeEstimate struct {
+	Blocks  int     `json:"blocks"`
+	FeeRate float64 `json:"fee_rate"`
+}
+
+// estimateFee returns the fee rate estimated to confirm within the blocks
+// query parameter, blockchain.DefaultFeeEstimateTarget when omitted
+func (s *Server) estimateFee(w http.ResponseWriter, r *http.Request) {
+	blocks := blockchain.DefaultFeeEstimateTarget
+	if v := r.URL.Query().Get("blocks"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			s.sendResponse(w, http.StatusBadRequest, nil, fmt.Errorf("invalid blocks: %v", err))
+			return
+		}
+		blocks = n
+	}
+
+	rate, err := s.blockchain.EstimateSmartFee(blocks)
+	if err != nil {
+		s.sendResponse(w, http.StatusBadRequest, nil, err)
+		return
+	}
+	s.sendResponse(w, http.StatusOK, feeEstimate{Blocks: blocks, FeeRate: rate}, nil)
+}
+
+// decodeTransactionRequest carries a transaction encoded by
+// blockchain.EncodeRawTransaction
+type decodeTransactionRequest struct {
+	Hex string `json:"hex"`
+}
+
+// decodeTransaction returns the parsed form of a raw transaction without
+// validating it or adding it to the mempool
+func (s *Server) decodeTransaction(w http.ResponseWriter, r *http.Request) {
+	var req decodeTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendResponse(w, http.StatusBadRequest, nil, fmt.Errorf("invalid request: %v", err))
+		return
+	}
+
+	tx, err := blockchain.DecodeRawTransaction(req.Hex)
+	if err != nil {
+		s.sendResponse(w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	s.sendResponse(w, http.StatusOK, blockchain.DescribeTransaction(tx), nil)
+}
+
+// getOrphans returns the blocks waiting for their parent, for diagnosing a stalled sync
+func (s *Server) getOrphans(w http.ResponseWriter, r *http.Request) {
+	orphans := s.blockchain.GetOrphanBlocks()
+	s.sendResponse(w, http.StatusOK, map[string]interface{}{
+		"count":  len(orphans),
+		"blocks": orphans,
+	}, nil)
+}
+
 // mine starts mining
 func (s *Server) mine(w http.ResponseWriter, r *http.Request) {
 	if err := s.node.StartMining(blockchain.Leah); err != nil {
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+
+	"byc/internal/logger"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the ID tying together the log lines of one request
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds a client-supplied ID so it can't bloat every log line
+const maxRequestIDLength = 128
+
+// RequestID takes the request ID from the X-Request-ID header, or generates
+// one, echoes it in the response and puts it in the request context so
+// logger.FromContext tags the handler's log lines with it
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), id)))
+	})
+}
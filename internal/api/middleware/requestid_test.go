@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"byc/internal/logger"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestIDInLogsAndResponse(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	defer logger.Replace(zap.New(core))()
+
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).Info("handling request")
+	}))
+
+	for _, supplied := range []string{"client-chosen-id", ""} {
+		logs.TakeAll()
+		req := httptest.NewRequest(http.MethodGet, "/api/blocks", nil)
+		if supplied != "" {
+			req.Header.Set(RequestIDHeader, supplied)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(RequestIDHeader)
+		if id == "" {
+			t.Fatalf("Expected a request ID in the response header")
+		}
+		if supplied != "" && id != supplied {
+			t.Errorf("Expected the supplied request ID %q, got %q", supplied, id)
+		}
+
+		entries := logs.TakeAll()
+		if len(entries) != 1 {
+			t.Fatalf("Expected one log line, got %d", len(entries))
+		}
+		if got := entries[0].ContextMap()["request_id"]; got != id {
+			t.Errorf("Expected log line tagged with %q, got %v", id, got)
+		}
+	}
+}
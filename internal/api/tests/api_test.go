@@ -11,6 +11,7 @@ import (
 
 	"byc/internal/api"
 	"byc/internal/blockchain"
+	"byc/internal/crypto"
 	"byc/internal/wallet"
 
 	"github.com/stretchr/testify/assert"
@@ -71,13 +72,38 @@ func TestCreateTransaction(t *testing.T) {
 	}
 	server := api.NewServer(bc, config)
 
+	// Fund a wallet and post a signed spend of its output
+	w, err := wallet.NewWallet()
+	assert.NoError(t, err)
+	funding := &blockchain.Transaction{
+		ID: []byte("funding-tx"),
+		Outputs: []blockchain.TxOutput{{
+			Value:         10,
+			CoinType:      blockchain.Leah,
+			PublicKeyHash: crypto.HashPublicKey(w.PublicKey),
+			Address:       w.Address,
+		}},
+	}
+	assert.NoError(t, bc.UTXOSet.UpdateWithTransaction(funding))
+
 	tx := blockchain.Transaction{
-		ID:        []byte("testid"),
-		Inputs:    []blockchain.TxInput{},
-		Outputs:   []blockchain.TxOutput{},
+		Inputs: []blockchain.TxInput{{
+			TxID:        funding.ID,
+			OutputIndex: 0,
+			Amount:      10,
+			PublicKey:   crypto.PublicKeyToBytes(w.PublicKey),
+			Address:     w.Address,
+		}},
+		Outputs: []blockchain.TxOutput{{
+			Value:         9,
+			CoinType:      blockchain.Leah,
+			PublicKeyHash: []byte("recipient"),
+			Address:       "recipient",
+		}},
 		Timestamp: time.Now(),
-		BlockType: blockchain.GoldenBlock,
 	}
+	tx.ID = tx.CalculateHash()
+	assert.NoError(t, tx.Sign(w.PrivateKey.D.Bytes()))
 	body, _ := json.Marshal(tx)
 	req := httptest.NewRequest("POST", "/api/transactions", bytes.NewBuffer(body))
 	rr := httptest.NewRecorder()
@@ -86,9 +112,10 @@ func TestCreateTransaction(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, rr.Code)
 
 	var resp api.Response
-	err := json.NewDecoder(rr.Body).Decode(&resp)
+	err = json.NewDecoder(rr.Body).Decode(&resp)
 	assert.NoError(t, err)
 	assert.True(t, resp.Success)
+	assert.Len(t, bc.GetPendingTransactions(), 1)
 }
 
 func TestGetBlock(t *testing.T) {
@@ -116,3 +143,99 @@ func TestGetBlock(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, resp.Success)
 }
+
+func TestDecodeTransaction(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	config := &api.Config{
+		NodeAddress:    ":0",
+		BlockType:      blockchain.GoldenBlock,
+		BootstrapPeers: []string{},
+	}
+	server := api.NewServer(bc, config)
+
+	tx := &blockchain.Transaction{
+		Inputs: []blockchain.TxInput{{
+			TxID:        []byte("funding"),
+			OutputIndex: 1,
+			Amount:      10,
+		}},
+		Outputs: []blockchain.TxOutput{
+			{Value: 7, CoinType: blockchain.Leah, PublicKeyHash: []byte("recipient"), Address: "recipient"},
+			{Value: 2.5, CoinType: blockchain.Leah, PublicKeyHash: []byte("change"), Address: "change"},
+		},
+		Timestamp: time.Unix(1700000000, 0),
+		Nonce:     7,
+	}
+	tx.RecomputeID()
+	raw, err := blockchain.EncodeRawTransaction(tx)
+	assert.NoError(t, err)
+
+	decode := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/tx/decode", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := decode(`{"hex":"` + raw + `"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp struct {
+		Success bool                          `json:"success"`
+		Data    blockchain.DecodedTransaction `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.True(t, resp.Success)
+	assert.Equal(t, hex.EncodeToString(tx.ID), resp.Data.TxID)
+	if assert.Len(t, resp.Data.Inputs, 1) {
+		assert.Equal(t, hex.EncodeToString([]byte("funding")), resp.Data.Inputs[0].TxID)
+		assert.Equal(t, 1, resp.Data.Inputs[0].OutputIndex)
+	}
+	if assert.Len(t, resp.Data.Outputs, 2) {
+		assert.Equal(t, "recipient", resp.Data.Outputs[0].Address)
+		assert.Equal(t, 2.5, resp.Data.Outputs[1].Value)
+	}
+	assert.Equal(t, []blockchain.CoinType{blockchain.Leah}, resp.Data.CoinTypes)
+	assert.InDelta(t, 0.5, resp.Data.Fee, 1e-9)
+	assert.Equal(t, tx.VirtualSize(), resp.Data.VSize)
+
+	// Decoding leaves the mempool alone
+	assert.Empty(t, bc.GetPendingTransactions())
+
+	for _, body := range []string{`not json`, `{"hex":"zz"}`, `{"hex":"deadbeef"}`} {
+		assert.Equal(t, http.StatusBadRequest, decode(body).Code, "body %s", body)
+	}
+}
+
+func TestEstimateFee(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	config := &api.Config{
+		NodeAddress:    ":0",
+		BlockType:      blockchain.GoldenBlock,
+		BootstrapPeers: []string{},
+	}
+	server := api.NewServer(bc, config)
+
+	estimate := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/fee/estimate"+query, nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := estimate("?blocks=2")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Blocks  int     `json:"blocks"`
+			FeeRate float64 `json:"fee_rate"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.True(t, resp.Success)
+	assert.Equal(t, 2, resp.Data.Blocks)
+	assert.Equal(t, bc.ConsensusParams().MinRelayFeeRate, resp.Data.FeeRate)
+
+	assert.Equal(t, http.StatusBadRequest, estimate("?blocks=soon").Code)
+	assert.Equal(t, http.StatusBadRequest, estimate("?blocks=0").Code)
+}
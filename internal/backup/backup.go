@@ -2,11 +2,22 @@ package backup
 
 import (
 	"archive/zip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/crypto/argon2"
 )
 
 // BackupConfig represents backup configuration
@@ -19,6 +30,11 @@ type BackupConfig struct {
 	IncludeLogs   bool
 	IncludeDB     bool
 	IncludeConfig bool
+
+	// SaveDatabase writes the database into a backup when IncludeDB is set,
+	// and RestoreDatabase reads it back
+	SaveDatabase    func(w io.Writer) error
+	RestoreDatabase func(r io.Reader) error
 }
 
 // BackupInfo represents information about a backup
@@ -31,42 +47,94 @@ type BackupInfo struct {
 	Error      string    `json:"error,omitempty"`
 	Checksum   string    `json:"checksum"`
 	Components []string  `json:"components"`
+	Compressed bool      `json:"compressed"`
+	Encrypted  bool      `json:"encrypted"`
 }
 
-// BackupManager handles backup operations
+const (
+	// manifestExt is the extension of the file recording a completed backup
+	manifestExt = ".backup.json"
+	// databaseFile is the name of the database component within a backup
+	databaseFile = "database"
+	// saltSize is the size of the random salt an encryption key is derived with
+	saltSize = 16
+)
+
+// BackupManager handles backup operations. A backup is a directory of its
+// components in BackupDir, or a zip archive of it when compressed, with the
+// archive or each component file encrypted when encryption is enabled. A
+// manifest written once the backup completes records how it was made, so
+// backups outlive the manager that created them.
 type BackupManager struct {
 	config     BackupConfig
+	mu         sync.Mutex
 	backups    map[string]*BackupInfo
 	lastBackup time.Time
 }
 
-// NewBackupManager creates a new backup manager
+// NewBackupManager creates a new backup manager, loading the backups
+// already completed in the backup directory
 func NewBackupManager(config BackupConfig) (*BackupManager, error) {
 	if err := os.MkdirAll(config.BackupDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create backup directory: %v", err)
 	}
 
-	return &BackupManager{
+	bm := &BackupManager{
 		config:  config,
 		backups: make(map[string]*BackupInfo),
-	}, nil
+	}
+	if err := bm.loadBackups(); err != nil {
+		return nil, err
+	}
+	return bm, nil
 }
 
 // CreateBackup creates a new backup
 func (bm *BackupManager) CreateBackup() (*BackupInfo, error) {
-	backupID := fmt.Sprintf("backup-%s", time.Now().Format("20060102-150405"))
+	return bm.CreateNamedBackup(fmt.Sprintf("backup-%s", time.Now().Format("20060102-150405")))
+}
+
+// CreateNamedBackup creates a new backup with the given ID
+func (bm *BackupManager) CreateNamedBackup(backupID string) (*BackupInfo, error) {
+	if err := validateID(backupID); err != nil {
+		return nil, err
+	}
+	if bm.config.Encrypt && len(bm.config.EncryptionKey) == 0 {
+		return nil, errors.New("backup encryption requires a key")
+	}
+
+	bm.mu.Lock()
+	_, exists := bm.backups[backupID]
+	bm.mu.Unlock()
+	if exists {
+		return nil, fmt.Errorf("backup %s already exists", backupID)
+	}
+
 	backupPath := filepath.Join(bm.config.BackupDir, backupID)
 
+	// Clear what a failed attempt at this backup left behind
+	if err := bm.removeArtifacts(backupID); err != nil {
+		return nil, err
+	}
+
 	// Create backup directory
 	if err := os.MkdirAll(backupPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create backup directory: %v", err)
 	}
 
 	backup := &BackupInfo{
-		ID:        backupID,
-		Timestamp: time.Now(),
-		Type:      "full",
-		Status:    "in_progress",
+		ID:         backupID,
+		Timestamp:  time.Now(),
+		Type:       "full",
+		Status:     "in_progress",
+		Compressed: bm.config.Compress,
+		Encrypted:  bm.config.Encrypt,
+	}
+	fail := func(err error) (*BackupInfo, error) {
+		backup.Status = "failed"
+		backup.Error = err.Error()
+		bm.removeArtifacts(backupID)
+		return backup, err
 	}
 
 	// Backup components
@@ -74,55 +142,48 @@ func (bm *BackupManager) CreateBackup() (*BackupInfo, error) {
 
 	if bm.config.IncludeDB {
 		if err := bm.backupDatabase(backupPath); err != nil {
-			backup.Status = "failed"
-			backup.Error = err.Error()
-			return backup, err
+			return fail(err)
 		}
 		components = append(components, "database")
 	}
 
 	if bm.config.IncludeLogs {
 		if err := bm.backupLogs(backupPath); err != nil {
-			backup.Status = "failed"
-			backup.Error = err.Error()
-			return backup, err
+			return fail(err)
 		}
 		components = append(components, "logs")
 	}
 
 	if bm.config.IncludeConfig {
 		if err := bm.backupConfig(backupPath); err != nil {
-			backup.Status = "failed"
-			backup.Error = err.Error()
-			return backup, err
+			return fail(err)
 		}
 		components = append(components, "config")
 	}
 
-	// Compress backup if enabled
+	// Compress backup if enabled, leaving only the archive
+	artifact := backupPath
 	if bm.config.Compress {
 		if err := bm.compressBackup(backupPath); err != nil {
-			backup.Status = "failed"
-			backup.Error = err.Error()
-			return backup, err
+			return fail(err)
+		}
+		if err := os.RemoveAll(backupPath); err != nil {
+			return fail(err)
 		}
+		artifact = backupPath + ".zip"
 	}
 
 	// Encrypt backup if enabled
 	if bm.config.Encrypt {
-		if err := bm.encryptBackup(backupPath); err != nil {
-			backup.Status = "failed"
-			backup.Error = err.Error()
-			return backup, err
+		if err := bm.encryptBackup(artifact); err != nil {
+			return fail(err)
 		}
 	}
 
 	// Calculate backup size and checksum
-	size, checksum, err := bm.calculateBackupInfo(backupPath)
+	size, checksum, err := bm.calculateBackupInfo(artifact)
 	if err != nil {
-		backup.Status = "failed"
-		backup.Error = err.Error()
-		return backup, err
+		return fail(err)
 	}
 
 	backup.Size = size
@@ -130,48 +191,75 @@ func (bm *BackupManager) CreateBackup() (*BackupInfo, error) {
 	backup.Status = "completed"
 	backup.Components = components
 
+	if err := bm.writeManifest(backup); err != nil {
+		return fail(err)
+	}
+
+	bm.mu.Lock()
 	bm.backups[backupID] = backup
 	bm.lastBackup = time.Now()
+	bm.mu.Unlock()
 
 	return backup, nil
 }
 
 // RestoreBackup restores from a backup
 func (bm *BackupManager) RestoreBackup(backupID string) error {
+	bm.mu.Lock()
 	backup, exists := bm.backups[backupID]
+	bm.mu.Unlock()
 	if !exists {
 		return fmt.Errorf("backup %s not found", backupID)
 	}
 
-	backupPath := filepath.Join(bm.config.BackupDir, backupID)
+	// Refuse a backup that changed since it was made
+	artifact := bm.artifactPath(backup)
+	_, checksum, err := bm.calculateBackupInfo(artifact)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %v", err)
+	}
+	if checksum != backup.Checksum {
+		return fmt.Errorf("backup %s is corrupt: checksum mismatch", backupID)
+	}
 
-	// Decrypt backup if encrypted
-	if bm.config.Encrypt {
-		if err := bm.decryptBackup(backupPath); err != nil {
+	// Decrypt and decompress into a scratch directory, leaving the backup as it is
+	work, err := os.MkdirTemp(bm.config.BackupDir, "."+backupID+"-restore-")
+	if err != nil {
+		return fmt.Errorf("failed to create restore directory: %v", err)
+	}
+	defer os.RemoveAll(work)
+
+	restorePath := artifact
+	if backup.Encrypted {
+		if err := bm.decryptBackup(artifact, work); err != nil {
 			return fmt.Errorf("failed to decrypt backup: %v", err)
 		}
+		restorePath = filepath.Join(work, backupID)
 	}
-
-	// Decompress backup if compressed
-	if bm.config.Compress {
-		if err := bm.decompressBackup(backupPath); err != nil {
+	if backup.Compressed {
+		archive := artifact
+		if backup.Encrypted {
+			archive = filepath.Join(work, filepath.Base(artifact))
+		}
+		if err := bm.decompressBackup(archive, work); err != nil {
 			return fmt.Errorf("failed to decompress backup: %v", err)
 		}
+		restorePath = work
 	}
 
 	// Restore components
 	for _, component := range backup.Components {
 		switch component {
 		case "database":
-			if err := bm.restoreDatabase(backupPath); err != nil {
+			if err := bm.restoreDatabase(restorePath); err != nil {
 				return fmt.Errorf("failed to restore database: %v", err)
 			}
 		case "logs":
-			if err := bm.restoreLogs(backupPath); err != nil {
+			if err := bm.restoreLogs(restorePath); err != nil {
 				return fmt.Errorf("failed to restore logs: %v", err)
 			}
 		case "config":
-			if err := bm.restoreConfig(backupPath); err != nil {
+			if err := bm.restoreConfig(restorePath); err != nil {
 				return fmt.Errorf("failed to restore config: %v", err)
 			}
 		}
@@ -182,6 +270,9 @@ func (bm *BackupManager) RestoreBackup(backupID string) error {
 
 // ListBackups returns a list of available backups
 func (bm *BackupManager) ListBackups() []*BackupInfo {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
 	backups := make([]*BackupInfo, 0, len(bm.backups))
 	for _, backup := range bm.backups {
 		backups = append(backups, backup)
@@ -189,17 +280,30 @@ func (bm *BackupManager) ListBackups() []*BackupInfo {
 	return backups
 }
 
+// DeleteBackup removes a backup and its manifest
+func (bm *BackupManager) DeleteBackup(backupID string) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	if _, exists := bm.backups[backupID]; !exists {
+		return fmt.Errorf("backup %s not found", backupID)
+	}
+	if err := bm.removeArtifacts(backupID); err != nil {
+		return fmt.Errorf("failed to remove backup %s: %v", backupID, err)
+	}
+	delete(bm.backups, backupID)
+	return nil
+}
+
 // CleanupOldBackups removes backups older than retention period
 func (bm *BackupManager) CleanupOldBackups() error {
 	cutoff := time.Now().AddDate(0, 0, -bm.config.RetentionDays)
 
-	for id, backup := range bm.backups {
+	for _, backup := range bm.ListBackups() {
 		if backup.Timestamp.Before(cutoff) {
-			backupPath := filepath.Join(bm.config.BackupDir, id)
-			if err := os.RemoveAll(backupPath); err != nil {
-				return fmt.Errorf("failed to remove old backup %s: %v", id, err)
+			if err := bm.DeleteBackup(backup.ID); err != nil {
+				return fmt.Errorf("failed to remove old backup %s: %v", backup.ID, err)
 			}
-			delete(bm.backups, id)
 		}
 	}
 
@@ -208,8 +312,95 @@ func (bm *BackupManager) CleanupOldBackups() error {
 
 // Helper functions
 
+// validateID rejects backup IDs that would name a file outside the backup directory
+func validateID(backupID string) error {
+	if backupID == "" || backupID != filepath.Base(backupID) || strings.HasPrefix(backupID, ".") {
+		return fmt.Errorf("invalid backup name: %q", backupID)
+	}
+	return nil
+}
+
+// artifactPath returns the directory or archive a backup is stored in
+func (bm *BackupManager) artifactPath(backup *BackupInfo) string {
+	path := filepath.Join(bm.config.BackupDir, backup.ID)
+	if backup.Compressed {
+		path += ".zip"
+	}
+	return path
+}
+
+// removeArtifacts deletes everything stored for a backup ID, manifest first
+// so an interrupted removal never leaves a manifest without its backup
+func (bm *BackupManager) removeArtifacts(backupID string) error {
+	backupPath := filepath.Join(bm.config.BackupDir, backupID)
+	for _, path := range []string{backupPath + manifestExt, backupPath + ".zip", backupPath} {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadBackups reads the manifests of the completed backups in the backup directory
+func (bm *BackupManager) loadBackups() error {
+	entries, err := os.ReadDir(bm.config.BackupDir)
+	if err != nil {
+		return fmt.Errorf("failed to read backup directory: %v", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), manifestExt) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(bm.config.BackupDir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read backup manifest %s: %v", entry.Name(), err)
+		}
+		var backup BackupInfo
+		if err := json.Unmarshal(data, &backup); err != nil {
+			return fmt.Errorf("failed to decode backup manifest %s: %v", entry.Name(), err)
+		}
+		if backup.ID+manifestExt != entry.Name() || backup.Status != "completed" {
+			continue
+		}
+		bm.backups[backup.ID] = &backup
+		if backup.Timestamp.After(bm.lastBackup) {
+			bm.lastBackup = backup.Timestamp
+		}
+	}
+	return nil
+}
+
+// writeManifest records a completed backup, via a temporary file so a
+// manifest is only ever found whole
+func (bm *BackupManager) writeManifest(backup *BackupInfo) error {
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup manifest: %v", err)
+	}
+	path := filepath.Join(bm.config.BackupDir, backup.ID+manifestExt)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %v", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
 func (bm *BackupManager) backupDatabase(backupPath string) error {
-	// Implement database backup logic
+	if bm.config.SaveDatabase == nil {
+		return errors.New("no database to back up")
+	}
+
+	file, err := os.OpenFile(filepath.Join(backupPath, databaseFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create database backup: %v", err)
+	}
+	if err := bm.config.SaveDatabase(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write database backup: %v", err)
+	}
 	return nil
 }
 
@@ -232,21 +423,25 @@ func (bm *BackupManager) compressBackup(backupPath string) error {
 	defer zipFile.Close()
 
 	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
 
-	return filepath.Walk(backupPath, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(backupPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if path == backupPath {
+			return nil
+		}
 
 		header, err := zip.FileInfoHeader(info)
 		if err != nil {
 			return err
 		}
 
-		header.Name = path[len(backupPath)+1:]
+		header.Name = filepath.ToSlash(path[len(backupPath)+1:])
 		if info.IsDir() {
 			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
 		}
 
 		writer, err := zipWriter.CreateHeader(header)
@@ -267,31 +462,211 @@ func (bm *BackupManager) compressBackup(backupPath string) error {
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	if err := zipWriter.Close(); err != nil {
+		return err
+	}
+	return zipFile.Close()
 }
 
+// encryptBackup encrypts a backup archive, or each file of a backup
+// directory, in place
 func (bm *BackupManager) encryptBackup(backupPath string) error {
-	// Implement backup encryption logic
-	return nil
+	return walkFiles(backupPath, func(path, rel string) error {
+		plaintext, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		ciphertext, err := bm.seal(plaintext)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path+".tmp", ciphertext, 0600); err != nil {
+			return err
+		}
+		return os.Rename(path+".tmp", path)
+	})
 }
 
-func (bm *BackupManager) decryptBackup(backupPath string) error {
-	// Implement backup decryption logic
-	return nil
+// decryptBackup decrypts a backup archive, or each file of a backup
+// directory, into dst under the same names
+func (bm *BackupManager) decryptBackup(backupPath, dst string) error {
+	if len(bm.config.EncryptionKey) == 0 {
+		return errors.New("backup is encrypted and no key was given")
+	}
+
+	return walkFiles(backupPath, func(path, rel string) error {
+		ciphertext, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		plaintext, err := bm.open(ciphertext)
+		if err != nil {
+			return err
+		}
+		out := filepath.Join(dst, rel)
+		if err := os.MkdirAll(filepath.Dir(out), 0700); err != nil {
+			return err
+		}
+		return os.WriteFile(out, plaintext, 0600)
+	})
 }
 
-func (bm *BackupManager) decompressBackup(backupPath string) error {
-	// Implement backup decompression logic
+// seal encrypts data with AES-GCM under a key derived from EncryptionKey
+// and a fresh salt, prefixing the salt and nonce
+func (bm *BackupManager) seal(data []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	gcm, err := bm.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	out := append(salt, nonce...)
+	return gcm.Seal(out, nonce, data, nil), nil
+}
+
+// open decrypts data written by seal
+func (bm *BackupManager) open(data []byte) ([]byte, error) {
+	if len(data) < saltSize {
+		return nil, errors.New("encrypted data too short")
+	}
+	gcm, err := bm.aead(data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltSize:]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted data too short")
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("wrong key or corrupt data")
+	}
+	return plaintext, nil
+}
+
+// aead derives the AES-256-GCM cipher for a salt from EncryptionKey
+func (bm *BackupManager) aead(salt []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey(bm.config.EncryptionKey, salt, 1, 64*1024, 4, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %v", err)
+	}
+	return gcm, nil
+}
+
+// decompressBackup extracts a backup archive into dst
+func (bm *BackupManager) decompressBackup(archivePath, dst string) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		path := filepath.Join(dst, filepath.FromSlash(file.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dst)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid archive entry: %q", file.Name)
+		}
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0700); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := extractFile(file, path); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// extractFile writes one archive entry to path
+func extractFile(file *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// calculateBackupInfo returns the size of a backup archive or directory and
+// a SHA-256 over its file names and contents
 func (bm *BackupManager) calculateBackupInfo(backupPath string) (int64, string, error) {
-	// Implement backup size and checksum calculation
-	return 0, "", nil
+	var size int64
+	hash := sha256.New()
+	err := walkFiles(backupPath, func(path, rel string) error {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		io.WriteString(hash, filepath.ToSlash(rel)+"\x00")
+		n, err := io.Copy(hash, file)
+		size += n
+		return err
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// walkFiles calls fn for path if it is a file, or for each file below it in
+// lexical order, with its path relative to path's directory
+func walkFiles(path string, fn func(path, rel string) error) error {
+	base := filepath.Dir(path)
+	return filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return err
+		}
+		return fn(p, rel)
+	})
 }
 
 func (bm *BackupManager) restoreDatabase(backupPath string) error {
-	// Implement database restore logic
-	return nil
+	if bm.config.RestoreDatabase == nil {
+		return errors.New("no database to restore into")
+	}
+
+	file, err := os.Open(filepath.Join(backupPath, databaseFile))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return bm.config.RestoreDatabase(file)
 }
 
 func (bm *BackupManager) restoreLogs(backupPath string) error {
@@ -0,0 +1,88 @@
+package blockchain
+
+import (
+	"io"
+	"sort"
+
+	"byc/internal/backup"
+)
+
+// DefaultBackupDir is where a new Blockchain writes its backups
+const DefaultBackupDir = "./backups"
+
+// backupManager opens the backups in bc.BackupDir. A chain backup holds an
+// ExportChain stream as its database, compressed, and encrypted with a key
+// derived from passphrase unless it is empty.
+func (bc *Blockchain) backupManager(passphrase string, progress ProgressFunc) (*backup.BackupManager, error) {
+	return backup.NewBackupManager(backup.BackupConfig{
+		BackupDir:     bc.BackupDir,
+		Compress:      true,
+		Encrypt:       passphrase != "",
+		EncryptionKey: []byte(passphrase),
+		IncludeDB:     true,
+		SaveDatabase: func(w io.Writer) error {
+			return bc.ExportChainWithProgress(w, progress)
+		},
+		RestoreDatabase: func(r io.Reader) error {
+			return bc.ImportChainWithProgress(r, progress)
+		},
+	})
+}
+
+// CreateBackup exports both chains to an unencrypted named backup in BackupDir
+func (bc *Blockchain) CreateBackup(name string) error {
+	return bc.CreateBackupWithProgress(name, "", nil)
+}
+
+// CreateBackupWithProgress exports both chains to a named backup in
+// BackupDir, encrypted under passphrase unless it is empty, reporting
+// progress after each block written
+func (bc *Blockchain) CreateBackupWithProgress(name, passphrase string, progress ProgressFunc) error {
+	bm, err := bc.backupManager(passphrase, progress)
+	if err != nil {
+		return err
+	}
+	_, err = bm.CreateNamedBackup(name)
+	return err
+}
+
+// RestoreBackup imports the blocks of an unencrypted named backup that are
+// not already on the chains
+func (bc *Blockchain) RestoreBackup(name string) error {
+	return bc.RestoreBackupWithProgress(name, "", nil)
+}
+
+// RestoreBackupWithProgress is RestoreBackup for a backup encrypted under
+// passphrase, or not encrypted when it is empty, reporting progress after
+// each block applied
+func (bc *Blockchain) RestoreBackupWithProgress(name, passphrase string, progress ProgressFunc) error {
+	bm, err := bc.backupManager(passphrase, progress)
+	if err != nil {
+		return err
+	}
+	return bm.RestoreBackup(name)
+}
+
+// ListBackups returns the names of the backups in BackupDir
+func (bc *Blockchain) ListBackups() []string {
+	bm, err := bc.backupManager("", nil)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, info := range bm.ListBackups() {
+		names = append(names, info.ID)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DeleteBackup removes a named backup from BackupDir
+func (bc *Blockchain) DeleteBackup(name string) error {
+	bm, err := bc.backupManager("", nil)
+	if err != nil {
+		return err
+	}
+	return bm.DeleteBackup(name)
+}
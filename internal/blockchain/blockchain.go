@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"byc/internal/crypto"
 	"byc/internal/interfaces"
 )
 
@@ -18,36 +19,91 @@ import (
 type Blockchain struct {
 	GoldenBlocks []Block
 	SilverBlocks []Block
-	PendingTxs   []Transaction
-	UTXOSet      *UTXOSet
-	Difficulty   int
-	MiningConfig *MiningConfig
-	MiningPool   *MiningPool
-	Blocks       []*Block
-	mu           sync.RWMutex
+	// PendingTxs holds the pending pool, one queue per chain
+	PendingTxs map[BlockType][]Transaction
+	UTXOSet    *UTXOSet
+	// Difficulty is the difficulty both chains start at, before any retarget
+	Difficulty    int
+	MiningConfig  *MiningConfig
+	MiningPool    *MiningPool
+	MempoolConfig *MempoolConfig
+	Blocks        []*Block
+	// BackupDir is the directory chain backups are written to
+	BackupDir string
+	// DataDir is the directory holding the chain data; CheckSystemHealth
+	// watches the free space on its filesystem
+	DataDir string
+	params  ConsensusParams
+	// undo holds the outputs each connected block spent, keyed by block
+	// hash, for the blocks within MaxReorgDepth of their chain's tip
+	undo map[string][][]UTXO
+	// issued is the amount of each coin the connected blocks issued, kept
+	// as blocks connect and disconnect
+	issued map[CoinType]float64
+	// reorgedOut records the chain and height of blocks disconnected by a
+	// reorganization, until their height is buried past MaxReorgDepth
+	reorgedOut map[string]chainPosition
+	reorgAlert func(*ReorgDepthError)
+	// retargets caches the difficulty of each retarget period
+	retargets retargetCache
+	// orphans holds blocks whose parent is unknown, keyed by block hash
+	orphans    map[string]Block
+	events     blockEvents
+	powMetrics PoWMetrics
+	// index holds the hashes of the connected blocks and transactions
+	index chainIndex
+	// peerHeights holds the chain heights peers advertise, for IsSynced
+	peerHeights peerHeights
+	closeOnce   sync.Once
+	mu          sync.RWMutex
 }
 
 // NewBlockchain creates a new blockchain
 func NewBlockchain() *Blockchain {
+	params := DefaultConsensusParams()
 	bc := &Blockchain{
-		GoldenBlocks: make([]Block, 0),
-		SilverBlocks: make([]Block, 0),
-		PendingTxs:   make([]Transaction, 0),
-		UTXOSet:      NewUTXOSet(),
-		Difficulty:   1,
-		MiningConfig: NewMiningConfig(),
-		MiningPool:   NewMiningPool("main", "pool.byc"),
-		Blocks:       make([]*Block, 0),
+		GoldenBlocks:  make([]Block, 0),
+		SilverBlocks:  make([]Block, 0),
+		PendingTxs:    make(map[BlockType][]Transaction),
+		UTXOSet:       NewUTXOSet(),
+		Difficulty:    params.GenesisDifficulty,
+		MiningConfig:  NewMiningConfig(),
+		MiningPool:    NewMiningPool("main", "pool.byc"),
+		MempoolConfig: NewMempoolConfig(),
+		Blocks:        make([]*Block, 0),
+		BackupDir:     DefaultBackupDir,
+		DataDir:       ".",
+		params:        params,
+		undo:          make(map[string][][]UTXO),
+		reorgedOut:    make(map[string]chainPosition),
+		orphans:       make(map[string]Block),
 	}
 
 	// Use the hardcoded genesis blocks
 	bc.GoldenBlocks = append(bc.GoldenBlocks, GoldenGenesisBlock)
 	bc.SilverBlocks = append(bc.SilverBlocks, SilverGenesisBlock)
 	bc.Blocks = append(bc.Blocks, &GoldenGenesisBlock, &SilverGenesisBlock)
+	bc.index.add(&GoldenGenesisBlock)
+	bc.index.add(&SilverGenesisBlock)
+	bc.addIssued(blockIssuance(GoldenGenesisBlock, nil), 1)
+	bc.addIssued(blockIssuance(SilverGenesisBlock, nil), 1)
 
 	return bc
 }
 
+// Close stops the miners of the mining pool and ends every block event
+// subscription, closing the subscribers' channels. The chain holds no files
+// or background tasks of its own. Close is safe to call more than once.
+func (bc *Blockchain) Close() error {
+	bc.closeOnce.Do(func() {
+		if bc.MiningPool != nil {
+			bc.MiningPool.Stop()
+		}
+		bc.events.closeAll()
+	})
+	return nil
+}
+
 // createGenesisBlock creates the first block in a chain
 func createGenesisBlock(blockType BlockType) Block {
 	block := Block{
@@ -56,7 +112,7 @@ func createGenesisBlock(blockType BlockType) Block {
 		PrevHash:     make([]byte, 32), // Empty previous hash
 		Nonce:        0,
 		BlockType:    blockType,
-		Difficulty:   1,
+		Difficulty:   DefaultGenesisDifficulty,
 	}
 	block.Hash = calculateHash(block)
 	return block
@@ -77,17 +133,83 @@ func (bc *Blockchain) AddBlock(block interface{}) error {
 		return fmt.Errorf("invalid block type: %T", block)
 	}
 
+	return bc.addBlock(b)
+}
+
+// AddBlockBatch validates and adds blocks in order under a single lock,
+// stopping at the first block that fails
+func (bc *Blockchain) AddBlockBatch(blocks []Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	for i, b := range blocks {
+		if err := bc.addBlock(b); err != nil {
+			return fmt.Errorf("block %d (%x): %v", i, b.Hash, err)
+		}
+	}
+	return nil
+}
+
+// ProcessBlock adds a block like AddBlock and returns copies of the orphans
+// that connected behind it, in chain order, so they can be relayed
+func (bc *Blockchain) ProcessBlock(b Block) ([]*Block, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	connected, err := bc.processBlock(b)
+	if err != nil {
+		return nil, err
+	}
+	orphans := make([]*Block, len(connected))
+	for i := range connected {
+		orphans[i] = connected[i].Copy()
+	}
+	return orphans, nil
+}
+
+// addBlock adds a block to its chain, or to the orphan pool when its parent
+// is unknown, then connects any orphans waiting on it. Callers must hold bc.mu.
+func (bc *Blockchain) addBlock(b Block) error {
+	_, err := bc.processBlock(b)
+	return err
+}
+
+// processBlock is addBlock returning the orphans that connected behind b.
+// Callers must hold bc.mu.
+func (bc *Blockchain) processBlock(b Block) ([]Block, error) {
+	if !bc.hasBlock(b.BlockType, b.PrevHash) {
+		return nil, bc.addOrphan(b)
+	}
+	if err := bc.connectBlock(b); err != nil {
+		return nil, err
+	}
+	return bc.connectOrphans(b), nil
+}
+
+// connectBlock validates a block and appends it to its chain. Callers must hold bc.mu.
+func (bc *Blockchain) connectBlock(b Block) error {
 	// Validate block
 	if err := bc.validateBlock(b); err != nil {
 		return err
 	}
 
-	// Update UTXO set
-	for _, tx := range b.Transactions {
-		if err := bc.UTXOSet.UpdateWithTransaction(&tx); err != nil {
+	// Update UTXO set, recording the height the outputs were confirmed at and
+	// the outputs each transaction spends so the block can be disconnected
+	height := bc.nextHeight(b.BlockType)
+	undo := make([][]UTXO, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		if !tx.IsCoinbase() {
+			for _, input := range tx.Inputs {
+				undo[i] = append(undo[i], bc.UTXOSet.GetUTXO(input.TxID, input.OutputIndex))
+			}
+		}
+		if err := bc.UTXOSet.UpdateWithTransactionAtHeight(&tx, b.BlockType, height); err != nil {
 			return err
 		}
 	}
+	bc.undo[string(b.Hash)] = undo
+	bc.addIssued(blockIssuance(b, undo), 1)
+	delete(bc.reorgedOut, string(b.Hash))
 
 	// Add block to the appropriate chain
 	if b.BlockType == GoldenBlock {
@@ -95,37 +217,32 @@ func (bc *Blockchain) AddBlock(block interface{}) error {
 	} else {
 		bc.SilverBlocks = append(bc.SilverBlocks, b)
 	}
+	bc.index.add(&b)
+	bc.pruneReorgData(b.BlockType)
 
 	// Also add to the Blocks slice for backward compatibility
 	bc.Blocks = append(bc.Blocks, &b)
+	bc.publishBlock(BlockConnected, &b)
+
+	// Confirmed transactions and their conflicts leave the pending pool
+	bc.removeFromMempool(b.Transactions)
 	return nil
 }
 
-// validateBlock validates a block before adding it to the blockchain
+// validateBlock validates a block before adding it to the blockchain. Callers must hold bc.mu.
 func (bc *Blockchain) validateBlock(block Block) error {
-	bc.mu.RLock()
-	defer bc.mu.RUnlock()
-
 	// Get the previous block
-	var prevBlock Block
-	if block.BlockType == GoldenBlock {
-		if len(bc.GoldenBlocks) == 0 {
-			return errors.New("no previous block found for golden chain")
-		}
-		prevBlock = bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
-	} else {
-		if len(bc.SilverBlocks) == 0 {
-			return errors.New("no previous block found for silver chain")
-		}
-		prevBlock = bc.SilverBlocks[len(bc.SilverBlocks)-1]
+	prevBlock, ok := bc.chainTip(block.BlockType)
+	if !ok {
+		return fmt.Errorf("%w for %s chain", ErrChainNotInitialized, block.BlockType)
 	}
 
 	// 1. Validate block structure
-	if block.Timestamp <= prevBlock.Timestamp {
-		return errors.New("block timestamp must be greater than previous block")
+	if mtp := bc.medianTimePast(block.BlockType); block.Timestamp <= mtp {
+		return fmt.Errorf("block timestamp %d is not after the median time past %d", block.Timestamp, mtp)
 	}
 
-	if block.Timestamp > time.Now().Unix()+60 { // Allow 60 seconds of future time
+	if block.Timestamp > time.Now().Unix()+MaxFutureBlockTime {
 		return errors.New("block timestamp is too far in the future")
 	}
 
@@ -135,7 +252,7 @@ func (bc *Blockchain) validateBlock(block Block) error {
 	}
 
 	// 3. Validate proof of work
-	if !bc.isValidProof(block) {
+	if !bc.checkProof(block) {
 		return errors.New("invalid proof of work")
 	}
 
@@ -144,52 +261,153 @@ func (bc *Blockchain) validateBlock(block Block) error {
 		return errors.New("block must contain at least one transaction")
 	}
 
+	// Only genesis blocks, which are never validated here, go without a root
+	if len(block.MerkleRoot) == 0 {
+		return errors.New("block has no merkle root")
+	}
+	if !bytes.Equal(block.MerkleRoot, MerkleRoot(block.Transactions)) {
+		return errors.New("merkle root does not match transactions")
+	}
+
 	// 5. Validate coinbase transaction
-	coinbaseFound := false
-	for _, tx := range block.Transactions {
+	var coinbase *Transaction
+	for i, tx := range block.Transactions {
 		if tx.IsCoinbase() {
-			if coinbaseFound {
+			if coinbase != nil {
 				return errors.New("multiple coinbase transactions found")
 			}
-			coinbaseFound = true
+			coinbase = &block.Transactions[i]
 		}
 	}
-	if !coinbaseFound {
+	if coinbase == nil {
 		return errors.New("block must contain exactly one coinbase transaction")
 	}
 
-	// 6. Validate transaction signatures and amounts
-	for _, tx := range block.Transactions {
-		if !tx.Verify() {
-			return fmt.Errorf("invalid transaction signature: %x", tx.ID)
+	// The block must be mined at its chain's difficulty for the coin it mints,
+	// unless the network lets miners pin it
+	var mined CoinType
+	if len(coinbase.Outputs) > 0 {
+		mined = coinbase.Outputs[0].CoinType
+	}
+	want := bc.nextDifficulty(block.BlockType) * MiningDifficulty(mined)
+	pinned := bc.params.AllowPinnedDifficulty && block.Difficulty >= bc.MiningConfig.MinDifficulty
+	if block.Difficulty != want && !pinned {
+		return fmt.Errorf("block difficulty %d does not match the required %d for %s on the %s chain",
+			block.Difficulty, want, mined, block.BlockType)
+	}
+
+	// 6. Validate transaction signatures and amounts, applying each transaction
+	// to a copy of the UTXO set so later ones may spend earlier outputs. A
+	// block may therefore confirm a pending parent and its children together,
+	// as the pending pool's ancestor limits allow; earlier versions required
+	// every input to be confirmed before the block.
+	view := bc.UTXOSet.Clone()
+	height := bc.nextHeight(block.BlockType)
+	spent := make([][]UTXO, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		if err := tx.CheckID(); err != nil {
+			return fmt.Errorf("invalid transaction: %x: %w", tx.ID, err)
+		}
+		if err := checkTransactionSanity(&tx, view); err != nil {
+			return fmt.Errorf("invalid transaction: %x: %w", tx.ID, err)
+		}
+		if tx.ExpiredAt(height) {
+			return fmt.Errorf("invalid transaction: %x: expired at height %d, block height is %d", tx.ID, tx.ExpiryHeight, height)
+		}
+		if err := bc.params.checkActiveRules(&tx, height); err != nil {
+			return fmt.Errorf("invalid transaction: %x: %w", tx.ID, err)
 		}
 
-		// Skip validation for coinbase transaction
+		// Skip validation for coinbase transaction. Its one input spends
+		// nothing and carries no signature, so Verify would fail every
+		// block with a reward.
 		if !tx.IsCoinbase() {
+			if !tx.Verify() {
+				return fmt.Errorf("invalid transaction signature: %x", tx.ID)
+			}
+
 			// Validate transaction against UTXO set
-			if err := tx.Validate(bc.UTXOSet); err != nil {
+			if err := tx.Validate(view); err != nil {
 				return fmt.Errorf("invalid transaction: %x: %v", tx.ID, err)
 			}
 
-			// Check for double spending
+			// Check for double spending. The UTXO set is keyed by hex
+			// transaction ID, as addOutputs stores it.
 			for _, input := range tx.Inputs {
-				if !bc.UTXOSet.HasUTXO(string(input.TxID), input.OutputIndex) {
+				if !view.HasUTXO(fmt.Sprintf("%x", input.TxID), input.OutputIndex) {
 					return fmt.Errorf("double spending detected in transaction: %x", tx.ID)
 				}
 			}
+
+			// Check the spent outputs are mature
+			if err := bc.checkInputsSpendable(tx, view); err != nil {
+				return fmt.Errorf("invalid transaction: %x: %v", tx.ID, err)
+			}
+			for _, input := range tx.Inputs {
+				spent[i] = append(spent[i], view.GetUTXO(input.TxID, input.OutputIndex))
+			}
+		}
+		if err := view.UpdateWithTransaction(&tx); err != nil {
+			return err
 		}
 	}
 
+	// Every coin the block creates, by coinbase, mint or conversion, counts
+	// toward its supply cap
+	if err := bc.checkSupplyCaps(blockIssuance(block, spent)); err != nil {
+		return err
+	}
+
 	// 7. Validate block size
 	blockSize := bc.calculateBlockSize(block)
-	if blockSize > MaxBlockSize {
-		return fmt.Errorf("block size exceeds maximum allowed size: %d > %d", blockSize, MaxBlockSize)
+	if maxSize := bc.params.MaxBlockSizeFor(block.BlockType); blockSize > maxSize {
+		return fmt.Errorf("block size exceeds maximum allowed size: %d > %d", blockSize, maxSize)
 	}
 
 	return nil
 }
 
-// calculateBlockSize calculates the size of a block in bytes
+// checkTransactionSanity checks the shape of a transaction in a block: it must
+// have outputs, distinct inputs unless it is the coinbase, and must conserve
+// each coin type it spends in view, save what checkConservation lets mints
+// and conversions create. Only the coinbase may carry a tag, of at most
+// MaxCoinbaseTagLength bytes.
+func checkTransactionSanity(tx *Transaction, view *UTXOSet) error {
+	if len(tx.Outputs) == 0 {
+		return &ValidationError{Field: "outputs", Reason: "transaction has no outputs"}
+	}
+	if tx.IsCoinbase() {
+		if err := CheckCoinbaseTag(tx.CoinbaseTag); err != nil {
+			return &ValidationError{Field: "coinbase_tag", Reason: err.Error()}
+		}
+		return nil
+	}
+	if tx.CoinbaseTag != "" {
+		return &ValidationError{Field: "coinbase_tag", Reason: "only a coinbase may carry a tag"}
+	}
+	if len(tx.Inputs) == 0 {
+		return &ValidationError{Field: "inputs", Reason: "non-coinbase transaction has no inputs"}
+	}
+
+	spent := make(map[CoinType]float64)
+	seen := make(map[string]bool)
+	for _, input := range tx.Inputs {
+		outpoint := fmt.Sprintf("%x:%d", input.TxID, input.OutputIndex)
+		if seen[outpoint] {
+			return &ValidationError{Field: "inputs", Reason: fmt.Sprintf("input %s spent twice", outpoint)}
+		}
+		seen[outpoint] = true
+		utxo := view.GetUTXO(input.TxID, input.OutputIndex)
+		spent[utxo.CoinType] += utxo.Amount
+	}
+	created := make(map[CoinType]float64)
+	for _, output := range tx.Outputs {
+		created[output.CoinType] += output.Value
+	}
+	return checkConservation(spent, created)
+}
+
+// calculateBlockSize calculates the size of a block in virtual bytes
 func (bc *Blockchain) calculateBlockSize(block Block) int64 {
 	var size int64
 
@@ -200,24 +418,11 @@ func (bc *Blockchain) calculateBlockSize(block Block) int64 {
 	size += 8  // Nonce
 	size += int64(len(block.BlockType))
 	size += 4 // Difficulty
+	size += int64(len(block.MerkleRoot))
 
 	// Add transactions size
 	for _, tx := range block.Transactions {
-		size += int64(len(tx.ID))
-		for _, input := range tx.Inputs {
-			size += int64(len(input.TxID))
-			size += 8 // OutputIndex
-			size += 8 // Amount
-			size += int64(len(input.Signature))
-			size += int64(len(input.PublicKey))
-			size += int64(len(input.Address))
-		}
-		for _, output := range tx.Outputs {
-			size += 8 // Value
-			size += int64(len(output.CoinType))
-			size += int64(len(output.PublicKeyHash))
-			size += int64(len(output.Address))
-		}
+		size += int64(tx.VirtualSize())
 	}
 
 	return size
@@ -229,7 +434,7 @@ func (bc *Blockchain) isValidBlock(block, prevBlock Block) bool {
 		return false
 	}
 
-	if !bc.isValidProof(block) {
+	if !bc.checkProof(block) {
 		return false
 	}
 
@@ -260,6 +465,7 @@ func calculateHash(block Block) []byte {
 		[]byte(strconv.Itoa(block.Difficulty)),
 		[]byte(strconv.FormatUint(block.Nonce, 10)),
 		[]byte(strconv.FormatInt(block.Timestamp, 10)),
+		block.MerkleRoot,
 	}, []byte{})
 
 	h := sha256.New()
@@ -267,38 +473,82 @@ func calculateHash(block Block) []byte {
 	return h.Sum(nil)
 }
 
-// MineBlock mines a new block with the given transactions
-func (bc *Blockchain) MineBlock(transactions []Transaction, blockType BlockType, coinType CoinType) (Block, error) {
-	if !IsMineable(coinType) {
-		return Block{}, errors.New("coin type is not mineable")
-	}
+// ErrChainNotInitialized is returned when a chain has no genesis block to
+// build on
+var ErrChainNotInitialized = errors.New("chain not initialized: missing genesis")
 
-	var prevBlock Block
+// chainTip returns the last block of the blockType chain, or false if the
+// chain has no blocks. Callers must hold bc.mu.
+func (bc *Blockchain) chainTip(blockType BlockType) (Block, bool) {
+	blocks := bc.SilverBlocks
 	if blockType == GoldenBlock {
-		prevBlock = bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
-	} else {
-		prevBlock = bc.SilverBlocks[len(bc.SilverBlocks)-1]
+		blocks = bc.GoldenBlocks
+	}
+	if len(blocks) == 0 {
+		return Block{}, false
 	}
+	return blocks[len(blocks)-1], true
+}
+
+// mineDeadlineInterval is how many nonces solve tries between checks of
+// the mining timeout and whether the block being mined is stale
+const mineDeadlineInterval = 1024
 
+// MineBlock mines a new block with the given transactions. It returns an error
+// wrapping ErrMiningTimeout if MiningConfig.MiningTimeout passes first.
+func (bc *Blockchain) MineBlock(transactions []Transaction, blockType BlockType, coinType CoinType) (Block, error) {
+	header, err := bc.nextBlockHeader(blockType, coinType)
+	if err != nil {
+		return Block{}, err
+	}
 	block := Block{
-		Timestamp:    time.Now().Unix(),
+		Timestamp:    header.Timestamp,
 		Transactions: transactions,
-		PrevHash:     prevBlock.Hash,
-		Nonce:        0,
+		PrevHash:     header.PrevHash,
 		BlockType:    blockType,
-		Difficulty:   bc.Difficulty * MiningDifficulty(coinType),
+		Difficulty:   header.Difficulty,
+		MerkleRoot:   MerkleRoot(transactions),
 	}
 
-	// Proof of work
+	if err := bc.solve(&block, nil); err != nil {
+		return Block{}, err
+	}
+	return block, nil
+}
+
+// solve searches nonces from zero until the block's hash meets its
+// difficulty, setting its nonce and hash, and gives up once the mining
+// timeout passes or stale, if not nil, is closed or receives
+func (bc *Blockchain) solve(block *Block, stale <-chan struct{}) error {
+	var deadline time.Time
+	if bc.MiningConfig != nil && bc.MiningConfig.MiningTimeout > 0 {
+		deadline = time.Now().Add(bc.MiningConfig.MiningTimeout)
+	}
+	block.Nonce = 0
 	for {
-		block.Hash = calculateHash(block)
-		if bc.isValidProof(block) {
+		block.Hash = calculateHash(*block)
+		if bc.isValidProof(*block) {
 			break
 		}
 		block.Nonce++
+		if block.Nonce%mineDeadlineInterval != 0 {
+			continue
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			bc.powMetrics.recordAttempts(block.Nonce)
+			return fmt.Errorf("%w after %s at difficulty %d (%d nonces tried)",
+				ErrMiningTimeout, bc.MiningConfig.MiningTimeout, block.Difficulty, block.Nonce)
+		}
+		select {
+		case <-stale:
+			bc.powMetrics.recordAttempts(block.Nonce)
+			return fmt.Errorf("%w after %d nonces", ErrTemplateStale, block.Nonce)
+		default:
+		}
 	}
-
-	return block, nil
+	// The nonce starts at zero, so it counts the hashes tried
+	bc.powMetrics.recordAttempts(block.Nonce + 1)
+	return nil
 }
 
 // GetBalance returns the balance of a wallet for a specific coin type
@@ -329,7 +579,17 @@ func (bc *Blockchain) GetBalance(address string, coinType CoinType) float64 {
 	return balance
 }
 
-// CreateTransaction creates a new transaction
+// GetAddressBalances returns the unspent balance of each coin type held by
+// address, scanning the UTXO set once rather than once per coin
+func (bc *Blockchain) GetAddressBalances(address string) map[CoinType]float64 {
+	return bc.UTXOSet.GetBalances(address)
+}
+
+// CreateTransaction creates an unsigned transaction sending amount of
+// coinType from one address to another, spending the outputs
+// FindUTXOsForAmount selects and returning any change to from. The change is
+// short the minimum relay fee for the transaction's size once signed. The
+// owner of from sets each input's public key when signing.
 func (bc *Blockchain) CreateTransaction(from, to string, amount float64, coinType CoinType) (Transaction, error) {
 	if amount <= 0 {
 		return Transaction{}, errors.New("amount must be positive")
@@ -343,59 +603,353 @@ func (bc *Blockchain) CreateTransaction(from, to string, amount float64, coinTyp
 		}
 	}
 
-	// Create transaction
-	tx := Transaction{
-		ID:        []byte{},
-		Inputs:    []TxInput{},
-		Outputs:   []TxOutput{},
-		Timestamp: time.Now(),
-		BlockType: GetBlockType(coinType),
+	// Outputs are locked to the hash each address commits to
+	_, toHash, err := crypto.DecodeAddress(to)
+	if err != nil {
+		return Transaction{}, err
+	}
+	_, fromHash, err := crypto.DecodeAddress(from)
+	if err != nil {
+		return Transaction{}, err
 	}
 
-	// TODO: Implement input/output creation logic
-	// This would involve finding unspent transaction outputs
-	// and creating new outputs for the recipient
+	// Each input selected adds to the fee, so select again until the
+	// outputs cover it
+	feeRate := bc.ConsensusParams().MinRelayFeeRate
+	var fee float64
+	for {
+		utxos, change, err := bc.FindUTXOsForAmount(from, coinType, amount+fee)
+		if err != nil {
+			return Transaction{}, err
+		}
 
-	return tx, nil
+		inputs := make([]TxInput, 0, len(utxos))
+		for _, utxo := range utxos {
+			inputs = append(inputs, TxInput{
+				TxID:        []byte(utxo.TxID),
+				OutputIndex: utxo.Index,
+				Amount:      utxo.Amount,
+			})
+		}
+		outputs := []TxOutput{{
+			Value:         amount,
+			CoinType:      coinType,
+			PublicKeyHash: toHash,
+			Address:       to,
+		}}
+		if change > 0 {
+			outputs = append(outputs, TxOutput{
+				Value:         change,
+				CoinType:      coinType,
+				PublicKeyHash: fromHash,
+				Address:       from,
+			})
+		}
+
+		tx := NewTransaction(from, to, amount, coinType, inputs, outputs)
+		needed := float64(tx.signedVirtualSize()) * feeRate
+		if needed <= fee {
+			return *tx, nil
+		}
+		fee = needed
+	}
 }
 
 // GetPendingTransactions returns the list of pending transactions
 func (bc *Blockchain) GetPendingTransactions() []Transaction {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
-	return bc.PendingTxs
+	return bc.pending()
 }
 
-// AddTransaction adds a transaction to the pending transactions
+// AddTransaction adds a transaction to the pending transactions. A refused
+// transaction returns a *RejectError.
 func (bc *Blockchain) AddTransaction(tx Transaction) error {
+	_, err := bc.AddTransactionDetailed(tx)
+	return err
+}
+
+// AddTransactionDetailed adds a transaction to the pending transactions and
+// reports its ID, fee and the transactions it replaced. A transaction without
+// an ID is given its hash. A refused transaction returns a *RejectError
+// naming the check that failed.
+func (bc *Blockchain) AddTransactionDetailed(tx Transaction) (*TxAcceptance, error) {
+	if len(tx.ID) == 0 {
+		tx.RecomputeID()
+	}
+
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
 	// Validate transaction
-	if err := tx.Validate(bc.UTXOSet); err != nil {
-		return err
+	replaced, err := bc.checkTransaction(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	acceptance := &TxAcceptance{
+		TxID:    append([]byte(nil), tx.ID...),
+		Fee:     tx.GetFee(),
+		FeeRate: tx.FeeRate(),
 	}
 
-	bc.PendingTxs = append(bc.PendingTxs, tx)
+	// Evict the transactions this one replaces by fee, with their
+	// descendants, which spend outputs that no longer exist
+	if len(replaced) > 0 {
+		all := bc.pending()
+		pending, removed := withoutDescendants(all, func(i int, _ Transaction) bool {
+			return replaced[i]
+		})
+		acceptance.Replaced = transactionIDs(all, removed)
+		bc.setPending(pending)
+	}
+
+	// Make room in a full queue by evicting its lowest fee rate
+	if bc.queueFull(tx.Chain()) {
+		all := bc.pending()
+		if victim, ok := evictionCandidate(all, tx); ok {
+			pending, removed := withoutDescendants(all, func(i int, _ Transaction) bool {
+				return i == victim
+			})
+			acceptance.Evicted = transactionIDs(all, removed)
+			bc.setPending(pending)
+		}
+	}
+
+	bc.enqueue(tx)
+	return acceptance, nil
+}
+
+// CheckTransaction runs the pending-pool acceptance checks without adding the transaction
+func (bc *Blockchain) CheckTransaction(tx Transaction) error {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	_, err := bc.checkTransaction(tx)
+	return err
+}
+
+// checkInputsSpendable rejects transactions spending outputs that are in
+// neither the confirmed UTXO set nor view, and confirmed outputs that have not
+// reached the confirmations required by the consensus parameters. Outputs in
+// view but not yet confirmed belong to an unconfirmed parent, pending or
+// earlier in the same block, and are spent as a package with it. Callers
+// must hold bc.mu.
+func (bc *Blockchain) checkInputsSpendable(tx Transaction, view *UTXOSet) error {
+	heights := bc.tipHeights()
+	for _, input := range tx.Inputs {
+		txID := fmt.Sprintf("%x", input.TxID)
+		if !bc.UTXOSet.HasUTXO(txID, input.OutputIndex) {
+			if view.HasUTXO(txID, input.OutputIndex) {
+				continue
+			}
+			return &ValidationError{
+				Field:  "input",
+				Reason: fmt.Sprintf("output %x:%d is not on chain", input.TxID, input.OutputIndex),
+			}
+		}
+		utxo := bc.UTXOSet.GetUTXO(input.TxID, input.OutputIndex)
+		if remaining := bc.params.BlocksUntilSpendable(utxo, heights.Of(utxo)); remaining > 0 {
+			return &ValidationError{
+				Field:  "input",
+				Reason: fmt.Sprintf("output %x:%d needs %d more confirmations", input.TxID, input.OutputIndex, remaining),
+			}
+		}
+	}
 	return nil
 }
 
+// checkTransaction validates a transaction for the pending pool and returns the
+// indexes of pending transactions it replaces by fee. Failures are
+// *RejectErrors. Callers must hold bc.mu.
+func (bc *Blockchain) checkTransaction(tx Transaction) (map[int]bool, error) {
+	// Check standardness
+	if tx.IsCoinbase() {
+		return nil, reject(RejectCoinbase, &ValidationError{
+			Field:  "transaction",
+			Reason: "coinbase transactions are not accepted into the pending pool",
+		})
+	}
+	if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+		return nil, reject(RejectMalformed, &ValidationError{
+			Field:  "transaction",
+			Reason: "transaction must have at least one input and one output",
+		})
+	}
+	if tx.CoinbaseTag != "" {
+		return nil, reject(RejectMalformed, &ValidationError{
+			Field:  "coinbase_tag",
+			Reason: "only a coinbase may carry a tag",
+		})
+	}
+	if err := tx.CheckID(); err != nil {
+		return nil, reject(RejectMalformed, err)
+	}
+	// The next block of its chain is the earliest the transaction could be
+	// included in
+	next := bc.nextHeight(tx.Chain())
+	if tx.ExpiredAt(next) {
+		return nil, reject(RejectExpired, &ValidationError{
+			Field:  "expiry_height",
+			Reason: fmt.Sprintf("transaction expired at height %d, next block height is %d", tx.ExpiryHeight, next),
+		})
+	}
+	if err := bc.params.checkActiveRules(&tx, next); err != nil {
+		return nil, reject(RejectInvalid, err)
+	}
+	maxSize := bc.params.MaxBlockSizeFor(tx.BlockType)
+	if tx.BlockType == "" && bc.params.MaxGoldenBlockSize > maxSize {
+		maxSize = bc.params.MaxGoldenBlockSize
+	}
+	if vsize := int64(tx.VirtualSize()); vsize > maxSize {
+		return nil, reject(RejectOversize, &ValidationError{
+			Field:  "transaction",
+			Reason: fmt.Sprintf("transaction virtual size %d exceeds maximum %d", vsize, maxSize),
+		})
+	}
+
+	// Validate signatures, ownership and balances, allowing unconfirmed parents
+	view := bc.mempoolView(tx)
+	if err := tx.Validate(view); err != nil {
+		return nil, reject(RejectInvalid, err)
+	}
+	if err := bc.checkInputsSpendable(tx, view); err != nil {
+		return nil, reject(RejectImmature, err)
+	}
+	if err := bc.checkPackageLimits(tx); err != nil {
+		return nil, reject(RejectPackageLimit, err)
+	}
+
+	// Check fee
+	fee := tx.GetFee()
+	if fee < 0 {
+		return nil, reject(RejectInvalid, &ValidationError{
+			Field:  "fee",
+			Reason: fmt.Sprintf("inputs do not cover outputs (fee %.8f)", fee),
+		})
+	}
+	// A fee can be nonzero yet spread too thin over a large transaction, so
+	// the rate is checked rather than the amount
+	rate := tx.FeeRate()
+	if rate < bc.params.MinRelayFeeRate {
+		if fee > 0 {
+			return nil, reject(RejectFee, fmt.Errorf("%w: fee %.8f over %d vbytes is %.8f per vbyte, minimum %.8f",
+				ErrFeeTooLow, fee, tx.VirtualSize(), rate, bc.params.MinRelayFeeRate))
+		}
+		return nil, reject(RejectFee, fmt.Errorf("%w: %.8f per vbyte, minimum %.8f", ErrFeeTooLow, rate, bc.params.MinRelayFeeRate))
+	}
+
+	// Check for double spends against the UTXO set and pending pool
+	pool := bc.pending()
+	spent := make(map[string]int)
+	for idx, pending := range pool {
+		if bytes.Equal(pending.ID, tx.ID) {
+			return nil, reject(RejectDuplicate, &ValidationError{
+				Field:  "transaction",
+				Reason: fmt.Sprintf("transaction %x already in pending pool", tx.ID),
+			})
+		}
+		for _, input := range pending.Inputs {
+			spent[fmt.Sprintf("%x:%d", input.TxID, input.OutputIndex)] = idx
+		}
+	}
+	seen := make(map[string]bool)
+	conflicts := make(map[int]bool)
+	for i, input := range tx.Inputs {
+		key := fmt.Sprintf("%x:%d", input.TxID, input.OutputIndex)
+		if seen[key] {
+			return nil, reject(RejectMalformed, &ValidationError{
+				Field:  fmt.Sprintf("input[%d]", i),
+				Reason: "duplicate input",
+			})
+		}
+		seen[key] = true
+
+		if bc.UTXOSet.GetUTXO(input.TxID, input.OutputIndex).Spent {
+			return nil, reject(RejectDoubleSpend, &ValidationError{
+				Field:  fmt.Sprintf("input[%d]", i),
+				Reason: "UTXO already spent",
+			})
+		}
+		if idx, exists := spent[key]; exists {
+			conflict := pool[idx]
+			if !conflict.Replaceable {
+				return nil, reject(RejectDoubleSpend, &ValidationError{
+					Field:  fmt.Sprintf("input[%d]", i),
+					Reason: fmt.Sprintf("double spend: conflicts with pending transaction %x", conflict.ID),
+				})
+			}
+			conflicts[idx] = true
+		}
+	}
+
+	// Replace-by-fee: the replacement must pay more than everything it
+	// evicts, conflicts and their descendants alike, and at a higher rate
+	// than any of it, or miners would earn less per byte. It cannot spend
+	// from what it evicts.
+	if len(conflicts) > 0 {
+		_, removed := withoutDescendants(pool, func(i int, _ Transaction) bool {
+			return conflicts[i]
+		})
+		ancestors := pendingAncestors(tx, pool, pendingIndex(pool))
+		var replacedFees, replacedRate float64
+		for _, idx := range removed {
+			if ancestors[idx] {
+				return nil, reject(RejectDoubleSpend, &ValidationError{
+					Field:  "transaction",
+					Reason: fmt.Sprintf("replacement spends from pending transaction %x it would evict", pool[idx].ID),
+				})
+			}
+			replacedFees += pool[idx].GetFee()
+			if r := pool[idx].FeeRate(); r > replacedRate {
+				replacedRate = r
+			}
+		}
+		if fee <= replacedFees {
+			return nil, reject(RejectReplacementFee, &ValidationError{
+				Field:  "fee",
+				Reason: fmt.Sprintf("replacement fee %.8f must exceed replaced fees %.8f", fee, replacedFees),
+			})
+		}
+		if rate <= replacedRate {
+			return nil, reject(RejectReplacementFee, &ValidationError{
+				Field:  "fee",
+				Reason: fmt.Sprintf("replacement fee rate %.8f must exceed replaced fee rate %.8f", rate, replacedRate),
+			})
+		}
+	}
+
+	// A full queue only takes replacements, which free the room they need,
+	// and transactions paying a higher fee rate than one it can evict
+	if bc.queueFull(tx.Chain()) && len(conflicts) == 0 {
+		victim, ok := evictionCandidate(pool, tx)
+		if !ok || pool[victim].FeeRate() >= rate {
+			return nil, reject(RejectMempoolFull, &ValidationError{
+				Field:  "transaction",
+				Reason: fmt.Sprintf("%s pending queue is full (%d transactions) with nothing paying less than %.8f per vbyte", tx.Chain(), bc.MempoolConfig.MaxQueueSize, rate),
+			})
+		}
+	}
+
+	return conflicts, nil
+}
+
 // GetBlock retrieves a block by its hash
 func (bc *Blockchain) GetBlock(hash []byte) (*Block, error) {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
 
-	// Search in golden blocks
-	for _, block := range bc.GoldenBlocks {
-		if bytes.Equal(block.Hash, hash) {
-			return &block, nil
+	// Search in golden blocks, returning a copy so callers can't alter the chain
+	for i := range bc.GoldenBlocks {
+		if bytes.Equal(bc.GoldenBlocks[i].Hash, hash) {
+			return bc.GoldenBlocks[i].Copy(), nil
 		}
 	}
 
 	// Search in silver blocks
-	for _, block := range bc.SilverBlocks {
-		if bytes.Equal(block.Hash, hash) {
-			return &block, nil
+	for i := range bc.SilverBlocks {
+		if bytes.Equal(bc.SilverBlocks[i].Hash, hash) {
+			return bc.SilverBlocks[i].Copy(), nil
 		}
 	}
 
@@ -407,20 +961,20 @@ func (bc *Blockchain) GetTransaction(id []byte) (*Transaction, error) {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
 
-	// Search in golden blocks
+	// Search in golden blocks, returning a copy so callers can't alter the chain
 	for _, block := range bc.GoldenBlocks {
-		for _, tx := range block.Transactions {
-			if bytes.Equal(tx.ID, id) {
-				return &tx, nil
+		for i := range block.Transactions {
+			if bytes.Equal(block.Transactions[i].ID, id) {
+				return block.Transactions[i].Copy(), nil
 			}
 		}
 	}
 
 	// Search in silver blocks
 	for _, block := range bc.SilverBlocks {
-		for _, tx := range block.Transactions {
-			if bytes.Equal(tx.ID, id) {
-				return &tx, nil
+		for i := range block.Transactions {
+			if bytes.Equal(block.Transactions[i].ID, id) {
+				return block.Transactions[i].Copy(), nil
 			}
 		}
 	}
@@ -428,6 +982,25 @@ func (bc *Blockchain) GetTransaction(id []byte) (*Transaction, error) {
 	return nil, fmt.Errorf("transaction not found")
 }
 
+// GetTransactionBlock returns a copy of the block holding the transaction
+// with the given ID and the block's height in its chain
+func (bc *Blockchain) GetTransactionBlock(id []byte) (*Block, int, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	for _, chain := range [][]Block{bc.GoldenBlocks, bc.SilverBlocks} {
+		for height := range chain {
+			for i := range chain[height].Transactions {
+				if bytes.Equal(chain[height].Transactions[i].ID, id) {
+					return chain[height].Copy(), height, nil
+				}
+			}
+		}
+	}
+
+	return nil, 0, fmt.Errorf("transaction not found")
+}
+
 // GetTransactions retrieves all transactions for a given address
 func (bc *Blockchain) GetTransactions(address string) ([]*Transaction, error) {
 	bc.mu.RLock()
@@ -495,32 +1068,14 @@ func (bc *Blockchain) Size() int64 {
 	return size
 }
 
-// GetTotalSupply returns the total supply of a specific coin type
+// GetTotalSupply returns the total issued of a specific coin type across
+// both chains: the outputs of coinbase and genesis transactions, and the
+// coins mints and conversions create. Coins moved by ordinary transactions
+// are not counted again.
 func (bc *Blockchain) GetTotalSupply(coinType CoinType) float64 {
-	var total float64
-
-	// Check both chains for the balance
-	for _, block := range bc.GoldenBlocks {
-		for _, tx := range block.Transactions {
-			for _, output := range tx.Outputs {
-				if output.CoinType == coinType {
-					total += output.Value
-				}
-			}
-		}
-	}
-
-	for _, block := range bc.SilverBlocks {
-		for _, tx := range block.Transactions {
-			for _, output := range tx.Outputs {
-				if output.CoinType == coinType {
-					total += output.Value
-				}
-			}
-		}
-	}
-
-	return total
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.issued[coinType]
 }
 
 // GetCurrentHeight returns the current height of the blockchain
@@ -530,14 +1085,132 @@ func (bc *Blockchain) GetCurrentHeight() int64 {
 	return int64(len(bc.Blocks))
 }
 
-// GetLatestBlock returns the latest block in the blockchain
+// GetLatestBlock returns a copy of the latest block in the blockchain
 func (bc *Blockchain) GetLatestBlock() *Block {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
 	if len(bc.Blocks) == 0 {
 		return nil
 	}
-	return bc.Blocks[len(bc.Blocks)-1]
+	return bc.Blocks[len(bc.Blocks)-1].Copy()
+}
+
+// Tip returns copies of the golden and silver chain tips read under a single
+// lock, so the pair reflects one state of the chain. A tip is nil if its
+// chain has no blocks.
+func (bc *Blockchain) Tip() (golden, silver *Block) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	if block, ok := bc.chainTip(GoldenBlock); ok {
+		golden = block.Copy()
+	}
+	if block, ok := bc.chainTip(SilverBlock); ok {
+		silver = block.Copy()
+	}
+	return golden, silver
+}
+
+// RecentBlocks returns copies of the last n blocks added across both chains,
+// oldest first
+func (bc *Blockchain) RecentBlocks(n int) []*Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	start := len(bc.Blocks) - n
+	if start < 0 {
+		start = 0
+	}
+	blocks := make([]*Block, 0, len(bc.Blocks)-start)
+	for _, b := range bc.Blocks[start:] {
+		block := *b
+		blocks = append(blocks, &block)
+	}
+	return blocks
+}
+
+// ChainBlocks returns copies of the blocks of blockType's chain, genesis first
+func (bc *Blockchain) ChainBlocks(blockType BlockType) []*Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	chain := bc.chain(blockType)
+	blocks := make([]*Block, len(chain))
+	for i := range chain {
+		blocks[i] = chain[i].Copy()
+	}
+	return blocks
+}
+
+// AllBlockHashes returns the hashes of all blocks in the order they were added
+func (bc *Blockchain) AllBlockHashes() [][]byte {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	hashes := make([][]byte, len(bc.Blocks))
+	for i, b := range bc.Blocks {
+		hashes[i] = append([]byte(nil), b.Hash...)
+	}
+	return hashes
+}
+
+// BlocksInRange returns copies of the blocks at heights from through to,
+// inclusive, in the order they were added across both chains. A negative to
+// means the latest block.
+func (bc *Blockchain) BlocksInRange(from, to int64) ([]Block, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	latest := int64(len(bc.Blocks)) - 1
+	if to < 0 || to > latest {
+		to = latest
+	}
+	if from < 0 || from > to {
+		return nil, fmt.Errorf("invalid height range: %d to %d", from, to)
+	}
+
+	blocks := make([]Block, 0, to-from+1)
+	for _, b := range bc.Blocks[from : to+1] {
+		blocks = append(blocks, *b)
+	}
+	return blocks, nil
+}
+
+// ChainBlock is a block with its height on its own chain
+type ChainBlock struct {
+	Block
+	Height int64
+}
+
+// ChainBlocksInRange returns copies of the blocks of either chain at heights
+// from through to on that chain, inclusive, in the order they were added
+// across both chains, so a block always follows the blocks whose outputs it
+// spends. A negative to means each chain's tip.
+func (bc *Blockchain) ChainBlocksInRange(from, to int64) ([]ChainBlock, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	tallest := int64(len(bc.GoldenBlocks))
+	if silver := int64(len(bc.SilverBlocks)); silver > tallest {
+		tallest = silver
+	}
+	if to < 0 || to >= tallest {
+		to = tallest - 1
+	}
+	if from < 0 || from > to {
+		return nil, fmt.Errorf("invalid height range: %d to %d", from, to)
+	}
+
+	var blocks []ChainBlock
+	heights := make(map[BlockType]int64)
+	for _, b := range bc.Blocks {
+		height := heights[b.BlockType]
+		heights[b.BlockType]++
+		if height >= from && height <= to {
+			blocks = append(blocks, ChainBlock{Block: *b, Height: height})
+		}
+	}
+	return blocks, nil
 }
 
 // RevertToHeight reverts the blockchain to a specific height
@@ -654,60 +1327,26 @@ type VersionInfo struct {
 	Date   time.Time
 }
 
-// Backup methods
-func (bc *Blockchain) CreateBackup(name string) error {
-	backupManager, err := interfaces.NewBackupManager(&interfaces.BackupConfig{
-		BackupDir: "./backups",
-		Encrypt:   true,
-		Compress:  true,
-	})
-	if err != nil {
-		return err
-	}
-	_, err = backupManager.CreateBackup()
-	return err
-}
-
-func (bc *Blockchain) RestoreBackup(name string) error {
-	backupManager, err := interfaces.NewBackupManager(&interfaces.BackupConfig{
-		BackupDir: "./backups",
-		Encrypt:   true,
-		Compress:  true,
-	})
-	if err != nil {
-		return err
+// Maintenance methods
+func (bc *Blockchain) CheckSystemHealth() *interfaces.SystemHealth {
+	now := time.Now()
+	health := &interfaces.SystemHealth{
+		Status:     HealthStatusHealthy,
+		LastCheck:  now,
+		Components: make(map[string]interfaces.ComponentHealth),
 	}
-	return backupManager.RestoreBackup(name)
-}
 
-func (bc *Blockchain) ListBackups() []string {
-	backupManager, err := interfaces.NewBackupManager(&interfaces.BackupConfig{
-		BackupDir: "./backups",
-		Encrypt:   true,
-		Compress:  true,
-	})
-	if err != nil {
-		return nil
-	}
-	return backupManager.ListBackups()
-}
+	health.Components["disk"] = checkDiskHealth(bc.DataDir, now)
+	health.Components["file_descriptors"] = checkFDHealth(now)
 
-func (bc *Blockchain) DeleteBackup(name string) error {
-	backupManager, err := interfaces.NewBackupManager(&interfaces.BackupConfig{
-		BackupDir: "./backups",
-		Encrypt:   true,
-		Compress:  true,
-	})
-	if err != nil {
-		return err
+	for _, component := range health.Components {
+		if healthSeverity(component.Status) > healthSeverity(health.Status) {
+			health.Status = component.Status
+			health.LastError = component.Error
+		}
 	}
-	return backupManager.DeleteBackup(name)
-}
 
-// Maintenance methods
-func (bc *Blockchain) CheckSystemHealth() *interfaces.SystemHealth {
-	maintenanceManager := interfaces.NewMaintenanceManager()
-	return maintenanceManager.GetHealth()
+	return health
 }
 
 func (bc *Blockchain) RunMaintenance() error {
@@ -735,25 +1374,49 @@ func (bc *Blockchain) SetMaintenanceAlert(email string) error {
 	return maintenanceManager.SetAlert(email)
 }
 
-// Special coin methods
-func (bc *Blockchain) CreateEphraimCoin() error {
-	wallet := interfaces.NewWallet()
-	return wallet.CreateEphraimCoin(bc)
+// Special coin methods. They act on the given wallet's keys and balances,
+// so the minting transaction spends its real outputs.
+
+// ErrNoWallet is returned by the special coin methods without a wallet
+var ErrNoWallet = errors.New("no wallet loaded")
+
+// CreateEphraimCoin mints an Ephraim coin from w's Golden Block coins
+func (bc *Blockchain) CreateEphraimCoin(w interfaces.Wallet) error {
+	if w == nil {
+		return ErrNoWallet
+	}
+	return w.CreateEphraimCoin(bc)
 }
 
-func (bc *Blockchain) CreateManassehCoin() error {
-	wallet := interfaces.NewWallet()
-	return wallet.CreateManassehCoin(bc)
+// CreateManassehCoin mints a Manasseh coin from w's Silver Block coins and Antion
+func (bc *Blockchain) CreateManassehCoin(w interfaces.Wallet) error {
+	if w == nil {
+		return ErrNoWallet
+	}
+	return w.CreateManassehCoin(bc)
 }
 
-func (bc *Blockchain) CreateJosephCoin() error {
-	wallet := interfaces.NewWallet()
-	return wallet.CreateJosephCoin(bc)
+// CreateJosephCoin mints a Joseph coin from one of w's Ephraim and one of
+// its Manasseh
+func (bc *Blockchain) CreateJosephCoin(w interfaces.Wallet) error {
+	if w == nil {
+		return ErrNoWallet
+	}
+	return w.CreateJosephCoin(bc)
 }
 
-func (bc *Blockchain) GetSpecialCoins() []interfaces.SpecialCoin {
-	wallet := interfaces.NewWallet()
-	return wallet.GetSpecialCoins(bc)
+// GetSpecialCoins returns the Ephraim, Manasseh and Joseph address holds in
+// the UTXO set. Special coins are only held whole.
+func (bc *Blockchain) GetSpecialCoins(address string) []interfaces.SpecialCoin {
+	held := bc.UTXOSet.GetBalances(address)
+	coins := make([]interfaces.SpecialCoin, 0, len(SpecialCoinTypes))
+	for _, coinType := range SpecialCoinTypes {
+		coins = append(coins, interfaces.SpecialCoin{
+			Type:   coinType.String(),
+			Amount: int64(held[coinType]),
+		})
+	}
+	return coins
 }
 
 // Version methods
@@ -1,8 +1,26 @@
 package blockchain
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
+
+	"byc/internal/crypto"
+	"byc/internal/logger"
 )
 
 func TestNewBlockchain(t *testing.T) {
@@ -123,6 +141,43 @@ func TestGetBlockType(t *testing.T) {
 	}
 }
 
+func TestParseCoinType(t *testing.T) {
+	tests := []struct {
+		input string
+		want  CoinType
+	}{
+		{"leah", Leah},
+		{"LEAH", Leah},
+		{"Shiblum", Shiblum},
+		{" shiblon ", Shiblon},
+		{"senum", Senum},
+		{"Joseph", Joseph},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCoinType(tt.input)
+		if err != nil {
+			t.Errorf("ParseCoinType(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseCoinType(%q) = %s; want %s", tt.input, got, tt.want)
+		}
+		if !got.IsValid() {
+			t.Errorf("%s.IsValid() = false; want true", got)
+		}
+	}
+
+	for _, input := range []string{"", "leha", "BTC", "gold"} {
+		if _, err := ParseCoinType(input); err == nil {
+			t.Errorf("ParseCoinType(%q) expected error", input)
+		}
+		if CoinType(input).IsValid() {
+			t.Errorf("CoinType(%q).IsValid() = true; want false", input)
+		}
+	}
+}
+
 func TestMineBlock(t *testing.T) {
 	bc := NewBlockchain()
 
@@ -258,6 +313,38 @@ func TestCreateTransaction(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for invalid coin type")
 	}
+
+	// The change pays the minimum relay fee and outputs lock to the hashes
+	// the addresses commit to
+	fromHash, toHash := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	from, to := crypto.EncodeAddress(crypto.LegacyAddressVersion, fromHash), crypto.EncodeAddress(0, toHash)
+	funding := &Transaction{ID: []byte("create-funding")}
+	for _, value := range []float64{3, 4, 5} {
+		funding.Outputs = append(funding.Outputs, TxOutput{Value: value, CoinType: Leah, Address: from, PublicKeyHash: fromHash})
+	}
+	if err := bc.UTXOSet.UpdateWithTransaction(funding); err != nil {
+		t.Fatalf("Failed to fund address: %v", err)
+	}
+	tx, err := bc.CreateTransaction(from, to, 6, Leah)
+	if err != nil {
+		t.Fatalf("CreateTransaction failed: %v", err)
+	}
+	var in float64
+	for _, input := range tx.Inputs {
+		in += input.Amount
+	}
+	fee := in - tx.GetTotalOutput()
+	if want := float64(tx.signedVirtualSize()) * bc.ConsensusParams().MinRelayFeeRate; fee <= 0 || fee < want-1e-9 {
+		t.Errorf("Fee = %f, want at least the minimum relay fee %f", fee, want)
+	}
+	if len(tx.Outputs) != 2 || tx.Outputs[0].Value != 6 || !bytes.Equal(tx.Outputs[0].PublicKeyHash, toHash) || !bytes.Equal(tx.Outputs[1].PublicKeyHash, fromHash) {
+		t.Errorf("Unexpected outputs %+v", tx.Outputs)
+	}
+
+	// Everything the address holds leaves nothing for the fee
+	if _, err := bc.CreateTransaction(from, to, 12, Leah); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("CreateTransaction of the whole balance error = %v, want ErrInsufficientFunds", err)
+	}
 }
 
 func TestBlockSize(t *testing.T) {
@@ -311,3 +398,3564 @@ func TestConcurrentBlockOperations(t *testing.T) {
 		t.Error("Expected multiple blocks after concurrent additions")
 	}
 }
+
+func TestCheckSystemHealthDisk(t *testing.T) {
+	origDisk, origFDs := statDisk, statFDs
+	defer func() { statDisk, statFDs = origDisk, origFDs }()
+	statFDs = func() (FDUsage, error) { return FDUsage{Open: 10, Limit: 1024}, nil }
+
+	tests := []struct {
+		name   string
+		free   uint64
+		status string
+	}{
+		{"plenty of space", 500 << 20, HealthStatusHealthy},
+		{"low space", 50 << 20, HealthStatusDegraded},
+		{"critical space", 10 << 20, HealthStatusDown},
+	}
+
+	bc := NewBlockchain()
+	for _, tt := range tests {
+		statDisk = func(dir string) (DiskUsage, error) {
+			return DiskUsage{Total: 1000 << 20, Free: tt.free}, nil
+		}
+
+		health := bc.CheckSystemHealth()
+		disk := health.Components["disk"]
+		if disk.Status != tt.status {
+			t.Errorf("%s: expected disk status %s, got %s (%s)", tt.name, tt.status, disk.Status, disk.Message)
+		}
+		if health.Status != tt.status {
+			t.Errorf("%s: expected overall status %s, got %s", tt.name, tt.status, health.Status)
+		}
+		if !strings.Contains(disk.Message, fmt.Sprintf("%d of 1000 MB", tt.free>>20)) {
+			t.Errorf("%s: expected disk numbers in message, got %q", tt.name, disk.Message)
+		}
+	}
+}
+
+func TestCheckSystemHealthFileDescriptors(t *testing.T) {
+	origDisk, origFDs := statDisk, statFDs
+	defer func() { statDisk, statFDs = origDisk, origFDs }()
+	statDisk = func(dir string) (DiskUsage, error) { return DiskUsage{Total: 1000, Free: 900}, nil }
+
+	tests := []struct {
+		open   uint64
+		status string
+	}{
+		{100, HealthStatusHealthy},
+		{850, HealthStatusDegraded},
+		{1000, HealthStatusDown},
+	}
+
+	bc := NewBlockchain()
+	for _, tt := range tests {
+		statFDs = func() (FDUsage, error) { return FDUsage{Open: tt.open, Limit: 1000}, nil }
+
+		fds := bc.CheckSystemHealth().Components["file_descriptors"]
+		if fds.Status != tt.status {
+			t.Errorf("%d open: expected status %s, got %s (%s)", tt.open, tt.status, fds.Status, fds.Message)
+		}
+	}
+}
+
+func TestCheckSystemHealthStatError(t *testing.T) {
+	origDisk := statDisk
+	defer func() { statDisk = origDisk }()
+	statDisk = func(dir string) (DiskUsage, error) { return DiskUsage{}, errors.New("no such device") }
+
+	disk := NewBlockchain().CheckSystemHealth().Components["disk"]
+	if disk.Status != HealthStatusUnknown || disk.Error == nil {
+		t.Errorf("expected unknown status with error, got %s (%v)", disk.Status, disk.Error)
+	}
+}
+
+func TestCalculateDifficultyPerChainTarget(t *testing.T) {
+	bc := NewBlockchain()
+	params := bc.ConsensusParams()
+	params.RetargetInterval = 5
+	bc.SetConsensusParams(params)
+	bc.Difficulty = 10
+	if err := bc.SetTargetBlockTime(GoldenBlock, 60*time.Second); err != nil {
+		t.Fatalf("SetTargetBlockTime failed: %v", err)
+	}
+	if err := bc.SetTargetBlockTime(SilverBlock, 600*time.Second); err != nil {
+		t.Fatalf("SetTargetBlockTime failed: %v", err)
+	}
+	if err := bc.SetTargetBlockTime(SilverBlock, 0); err == nil {
+		t.Error("Expected error for zero target block time")
+	}
+
+	// Both chains see identical 300s block times
+	start := time.Now().Unix()
+	bc.GoldenBlocks = []Block{{Timestamp: start}}
+	bc.SilverBlocks = []Block{{Timestamp: start}}
+	for i := int64(1); i <= 5; i++ {
+		bc.GoldenBlocks = append(bc.GoldenBlocks, Block{Timestamp: start + i*300, BlockType: GoldenBlock})
+		bc.SilverBlocks = append(bc.SilverBlocks, Block{Timestamp: start + i*300, BlockType: SilverBlock})
+	}
+
+	// 300s blocks are too slow for a 60s target, so difficulty drops
+	if golden := bc.CalculateDifficulty(GoldenBlock); golden >= bc.Difficulty {
+		t.Errorf("Expected golden difficulty below %d, got %d", bc.Difficulty, golden)
+	}
+	// 300s blocks are too fast for a 600s target, so difficulty rises
+	if silver := bc.CalculateDifficulty(SilverBlock); silver <= bc.Difficulty {
+		t.Errorf("Expected silver difficulty above %d, got %d", bc.Difficulty, silver)
+	}
+}
+
+// mineTestBlock builds a valid coinbase-only block extending the given chain
+func mineTestBlock(t *testing.T, bc *Blockchain, blockType BlockType, address string) Block {
+	return mineTestBlockWith(t, bc, blockType, address)
+}
+
+// mineTestBlockWith mines and adds a block holding a coinbase followed by txs
+func mineTestBlockWith(t *testing.T, bc *Blockchain, blockType BlockType, address string, txs ...Transaction) Block {
+	prev := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+	if blockType == SilverBlock {
+		prev = bc.SilverBlocks[len(bc.SilverBlocks)-1]
+	}
+
+	block := buildTestBlock(bc, prev, blockType, address, 60, txs...)
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+	return block
+}
+
+// buildTestBlock mines a block on top of prev, spacing seconds later, without adding it
+func buildTestBlock(bc *Blockchain, prev Block, blockType BlockType, address string, spacing int64, txs ...Transaction) Block {
+	coinbase := Transaction{
+		Inputs:    []TxInput{{OutputIndex: -1}},
+		Outputs:   []TxOutput{{Value: 50, CoinType: Leah, Address: address, PublicKeyHash: []byte(address)}},
+		Timestamp: time.Unix(prev.Timestamp+spacing, 0),
+		BlockType: blockType,
+	}
+	coinbase.ID = coinbase.CalculateHash()
+
+	block := Block{
+		Timestamp:    prev.Timestamp + spacing,
+		Transactions: append([]Transaction{coinbase}, txs...),
+		PrevHash:     prev.Hash,
+		BlockType:    blockType,
+		Difficulty:   1,
+	}
+	block.MerkleRoot = MerkleRoot(block.Transactions)
+	for !bc.isValidProof(block) {
+		block.Nonce++
+	}
+	block.Hash = calculateHash(block)
+	return block
+}
+
+func TestExportImportChain(t *testing.T) {
+	src := NewBlockchain()
+	for i := 0; i < 3; i++ {
+		mineTestBlock(t, src, GoldenBlock, fmt.Sprintf("golden-miner-%d", i))
+	}
+	for i := 0; i < 2; i++ {
+		mineTestBlock(t, src, SilverBlock, fmt.Sprintf("silver-miner-%d", i))
+	}
+
+	var buf bytes.Buffer
+	if err := src.ExportChain(&buf); err != nil {
+		t.Fatalf("ExportChain failed: %v", err)
+	}
+
+	dst := NewBlockchain()
+	if err := dst.ImportChain(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("ImportChain failed: %v", err)
+	}
+
+	if len(dst.GoldenBlocks) != len(src.GoldenBlocks) || len(dst.SilverBlocks) != len(src.SilverBlocks) {
+		t.Fatalf("Expected %d/%d blocks, got %d/%d", len(src.GoldenBlocks), len(src.SilverBlocks),
+			len(dst.GoldenBlocks), len(dst.SilverBlocks))
+	}
+	if !bytes.Equal(dst.GoldenBlocks[len(dst.GoldenBlocks)-1].Hash, src.GoldenBlocks[len(src.GoldenBlocks)-1].Hash) {
+		t.Error("Golden tips differ after import")
+	}
+	if !bytes.Equal(dst.SilverBlocks[len(dst.SilverBlocks)-1].Hash, src.SilverBlocks[len(src.SilverBlocks)-1].Hash) {
+		t.Error("Silver tips differ after import")
+	}
+
+	utxoKeys := func(bc *Blockchain) map[string]float64 {
+		keys := make(map[string]float64)
+		for _, utxo := range bc.UTXOSet.GetAll() {
+			keys[fmt.Sprintf("%x:%d:%s:%s", utxo.TxID, utxo.Index, utxo.Address, utxo.CoinType)] = utxo.Amount
+		}
+		return keys
+	}
+	srcUTXOs, dstUTXOs := utxoKeys(src), utxoKeys(dst)
+	if len(srcUTXOs) != 5 || len(dstUTXOs) != len(srcUTXOs) {
+		t.Fatalf("Expected 5 UTXOs on both sides, got %d and %d", len(srcUTXOs), len(dstUTXOs))
+	}
+	for key, amount := range srcUTXOs {
+		if dstUTXOs[key] != amount {
+			t.Errorf("UTXO %s: expected %f, got %f", key, amount, dstUTXOs[key])
+		}
+	}
+
+	// Importing the same stream again is a no-op
+	if err := dst.ImportChain(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Errorf("Re-import failed: %v", err)
+	}
+
+	// A corrupted header is rejected
+	if err := NewBlockchain().ImportChain(bytes.NewReader([]byte("garbage"))); err == nil {
+		t.Error("Expected error importing invalid stream")
+	}
+}
+
+func TestCoinbaseTransactionsHaveDistinctIDs(t *testing.T) {
+	first := NewCoinbaseTransaction("miner", []byte("miner"), 50, Leah, GoldenBlock)
+	second := NewCoinbaseTransaction("miner", []byte("miner"), 50, Leah, GoldenBlock)
+	second.Timestamp = first.Timestamp
+	second.ID = second.CalculateHash()
+
+	if !first.IsCoinbase() || !second.IsCoinbase() {
+		t.Fatal("Expected coinbase transactions")
+	}
+	if first.Nonce == second.Nonce {
+		t.Error("Expected distinct nonces")
+	}
+	if bytes.Equal(first.ID, second.ID) {
+		t.Error("Coinbases for the same height and reward share an ID")
+	}
+
+	// The nonce is covered by the hash
+	hash := first.CalculateHash()
+	first.Nonce++
+	if bytes.Equal(hash, first.CalculateHash()) {
+		t.Error("Changing the nonce did not change the hash")
+	}
+}
+
+// fundTestKey adds a UTXO paying amount to key and returns the funding transaction
+func fundTestKey(t *testing.T, bc *Blockchain, key *ecdsa.PrivateKey, id string, amount float64) *Transaction {
+	funding := &Transaction{
+		ID: []byte(id),
+		Outputs: []TxOutput{{
+			Value:         amount,
+			CoinType:      Leah,
+			PublicKeyHash: crypto.HashPublicKey(&key.PublicKey),
+			Address:       id,
+		}},
+	}
+	if err := bc.UTXOSet.UpdateWithTransaction(funding); err != nil {
+		t.Fatalf("Failed to fund key: %v", err)
+	}
+	return funding
+}
+
+// signedTestSpend spends the first output of funding, paying send and leaving the rest as fee
+func signedTestSpend(t *testing.T, key *ecdsa.PrivateKey, funding *Transaction, send float64) Transaction {
+	tx := Transaction{
+		Inputs: []TxInput{{
+			TxID:      funding.ID,
+			Amount:    funding.Outputs[0].Value,
+			PublicKey: crypto.PublicKeyToBytes(&key.PublicKey),
+		}},
+		Outputs: []TxOutput{{
+			Value:         send,
+			CoinType:      Leah,
+			PublicKeyHash: []byte("recipient"),
+			Address:       "recipient",
+		}},
+		Timestamp: time.Now(),
+		Nonce:     NewTxNonce(),
+	}
+	tx.ID = tx.CalculateHash()
+	if err := tx.Sign(key.D.Bytes()); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	return tx
+}
+
+func TestMempoolPersistence(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	bc := NewBlockchain()
+	first := fundTestKey(t, bc, key, "funding-1", 10)
+	second := fundTestKey(t, bc, key, "funding-2", 5)
+	keep := signedTestSpend(t, key, first, 9)
+	lose := signedTestSpend(t, key, second, 4)
+	for _, tx := range []Transaction{keep, lose} {
+		if err := bc.AddTransaction(tx); err != nil {
+			t.Fatalf("AddTransaction failed: %v", err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), MempoolFile)
+	if err := bc.SaveMempool(path); err != nil {
+		t.Fatalf("SaveMempool failed: %v", err)
+	}
+
+	// Restart: the same UTXOs, except a block mined meanwhile spent the second one
+	restarted := NewBlockchain()
+	fundTestKey(t, restarted, key, "funding-1", 10)
+	fundTestKey(t, restarted, key, "funding-2", 5)
+	conflict := signedTestSpend(t, key, second, 4.5)
+	if err := restarted.UTXOSet.UpdateWithTransaction(&conflict); err != nil {
+		t.Fatalf("Failed to apply conflicting spend: %v", err)
+	}
+
+	restored, dropped, err := restarted.LoadMempool(path)
+	if err != nil {
+		t.Fatalf("LoadMempool failed: %v", err)
+	}
+	if restored != 1 || dropped != 1 {
+		t.Errorf("Expected 1 restored and 1 dropped, got %d and %d", restored, dropped)
+	}
+	pending := restarted.GetPendingTransactions()
+	if len(pending) != 1 || !bytes.Equal(pending[0].ID, keep.ID) {
+		t.Errorf("Expected only the still-valid transaction to be restored, got %d pending", len(pending))
+	}
+
+	// A missing mempool file is not an error
+	if _, _, err := NewBlockchain().LoadMempool(filepath.Join(t.TempDir(), MempoolFile)); err != nil {
+		t.Errorf("Expected no error for missing mempool, got %v", err)
+	}
+}
+
+func TestRegtestCoinbaseMaturity(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	bc := NewBlockchain()
+	if err := bc.SetNetworkMode(Regtest); err != nil {
+		t.Fatalf("SetNetworkMode failed: %v", err)
+	}
+
+	// Each network mode matures rewards at its own depth
+	for mode, want := range map[NetworkMode]uint64{Mainnet: CoinbaseMaturity, Testnet: TestnetCoinbaseMaturity, Regtest: RegtestCoinbaseMaturity} {
+		params, err := ParamsForMode(mode)
+		if err != nil {
+			t.Fatalf("ParamsForMode(%s) failed: %v", mode, err)
+		}
+		if params.CoinbaseMaturity != want {
+			t.Errorf("%s coinbase maturity = %d, want %d", mode, params.CoinbaseMaturity, want)
+		}
+	}
+	if bc.ConsensusParams().CoinbaseMaturity != RegtestCoinbaseMaturity {
+		t.Errorf("Expected SetNetworkMode to apply the regtest coinbase maturity")
+	}
+
+	params := bc.ConsensusParams()
+	params.CoinbaseMaturity = 3
+	bc.SetConsensusParams(params)
+
+	coinbase := NewCoinbaseTransaction("miner", crypto.HashPublicKey(&key.PublicKey), 50, Leah, GoldenBlock)
+	if err := bc.UTXOSet.UpdateWithTransactionAtHeight(coinbase, GoldenBlock, bc.TipHeights()[GoldenBlock]); err != nil {
+		t.Fatalf("Failed to add coinbase: %v", err)
+	}
+	spend := signedTestSpend(t, key, coinbase, 49)
+
+	// One confirmation is not enough, and silver blocks do not add to it
+	bc.SilverBlocks = append(bc.SilverBlocks, Block{}, Block{})
+	if err := bc.CheckTransaction(spend); err == nil {
+		t.Error("Expected immature coinbase spend to be rejected")
+	}
+
+	bc.GoldenBlocks = append(bc.GoldenBlocks, Block{}, Block{})
+	if err := bc.CheckTransaction(spend); err != nil {
+		t.Errorf("Expected coinbase to be spendable after 3 confirmations, got %v", err)
+	}
+
+	// The same depth is still immature under the default parameters
+	bc.SetConsensusParams(DefaultConsensusParams())
+	if err := bc.CheckTransaction(spend); err == nil {
+		t.Error("Expected default maturity to reject the spend")
+	}
+
+	if err := bc.SetNetworkMode("unknown"); err == nil {
+		t.Error("Expected error for unknown network mode")
+	}
+}
+
+func TestGetCoinbaseMaturityInfo(t *testing.T) {
+	bc := NewBlockchain()
+	params := bc.ConsensusParams()
+	params.CoinbaseMaturity = 2
+	bc.SetConsensusParams(params)
+
+	first := mineTestBlock(t, bc, GoldenBlock, "miner")
+	infos, err := bc.GetCoinbaseMaturityInfo("miner")
+	if err != nil {
+		t.Fatalf("GetCoinbaseMaturityInfo failed: %v", err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("Expected 1 immature coinbase, got %d", len(infos))
+	}
+	firstHeight := bc.TipHeights()[GoldenBlock]
+	if infos[0].UTXO.TxID != string(first.Transactions[0].ID) {
+		t.Errorf("Reported output %x, want the first coinbase", infos[0].UTXO.TxID)
+	}
+	if infos[0].MaturityHeight != firstHeight+params.CoinbaseMaturity {
+		t.Errorf("MaturityHeight = %d, want %d", infos[0].MaturityHeight, firstHeight+params.CoinbaseMaturity)
+	}
+	if infos[0].Confirmations != 1 || infos[0].BlocksRemaining != 1 {
+		t.Errorf("Confirmations = %d, BlocksRemaining = %d, want 1 and 1", infos[0].Confirmations, infos[0].BlocksRemaining)
+	}
+
+	// A silver block does not mature a golden reward
+	mineTestBlock(t, bc, SilverBlock, "silver-miner")
+	if infos, _ := bc.GetCoinbaseMaturityInfo("miner"); len(infos) != 1 || infos[0].BlocksRemaining != 1 {
+		t.Errorf("Expected a silver block to leave the golden reward 1 block from maturity, got %+v", infos)
+	}
+
+	// The next golden block matures the first reward and adds an immature one
+	second := mineTestBlock(t, bc, GoldenBlock, "miner")
+	infos, err = bc.GetCoinbaseMaturityInfo("miner")
+	if err != nil {
+		t.Fatalf("GetCoinbaseMaturityInfo failed: %v", err)
+	}
+	if len(infos) != 1 || infos[0].UTXO.TxID != string(second.Transactions[0].ID) {
+		t.Fatalf("Expected only the second coinbase to be immature, got %+v", infos)
+	}
+	if infos[0].MaturityHeight != firstHeight+1+params.CoinbaseMaturity {
+		t.Errorf("MaturityHeight = %d, want %d", infos[0].MaturityHeight, firstHeight+1+params.CoinbaseMaturity)
+	}
+
+	// Outputs that are not block rewards are never reported
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	fundTestKey(t, bc, key, "funded", 10)
+	if infos, _ := bc.GetCoinbaseMaturityInfo("funded"); len(infos) != 0 {
+		t.Errorf("Expected no immature coinbases for a funded address, got %d", len(infos))
+	}
+}
+
+func TestGetBlockSummary(t *testing.T) {
+	bc := NewBlockchain()
+
+	genesis, err := bc.GetBlockSummary(GoldenGenesisBlock.Hash)
+	if err != nil {
+		t.Fatalf("GetBlockSummary failed for genesis: %v", err)
+	}
+	if genesis.Height != 0 || genesis.TransactionCount != len(GoldenGenesisBlock.Transactions) {
+		t.Errorf("Unexpected genesis summary: %+v", genesis)
+	}
+	if genesis.TotalFees != 0 {
+		t.Errorf("Expected genesis to pay no fees, got %f", genesis.TotalFees)
+	}
+	if genesis.TotalOutput != GoldenGenesisBlock.Transactions[0].GetTotalOutput() {
+		t.Errorf("Expected genesis output %f, got %f", GoldenGenesisBlock.Transactions[0].GetTotalOutput(), genesis.TotalOutput)
+	}
+	if genesis.MerkleRoot != fmt.Sprintf("%x", MerkleRoot(GoldenGenesisBlock.Transactions)) {
+		t.Errorf("Unexpected genesis merkle root %s", genesis.MerkleRoot)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	first := signedTestSpend(t, key, fundTestKey(t, bc, key, "summary-1", 10), 9)
+	second := signedTestSpend(t, key, fundTestKey(t, bc, key, "summary-2", 5), 4.5)
+	block := mineTestBlockWith(t, bc, GoldenBlock, "miner", first, second)
+
+	summary, err := bc.GetBlockSummary(block.Hash)
+	if err != nil {
+		t.Fatalf("GetBlockSummary failed: %v", err)
+	}
+	if summary.Height != 1 || summary.TransactionCount != 3 {
+		t.Errorf("Expected height 1 with 3 transactions, got %d with %d", summary.Height, summary.TransactionCount)
+	}
+	if summary.TotalFees != 1.5 {
+		t.Errorf("Expected total fees 1.5, got %f", summary.TotalFees)
+	}
+	if summary.TotalOutput != 50+9+4.5 {
+		t.Errorf("Expected total output 63.5, got %f", summary.TotalOutput)
+	}
+	coinbaseOnly := block
+	coinbaseOnly.Transactions = block.Transactions[:1]
+	if summary.Size != bc.calculateBlockSize(block) || summary.Size <= bc.calculateBlockSize(coinbaseOnly) {
+		t.Errorf("Unexpected block size %d", summary.Size)
+	}
+
+	if _, err := bc.GetBlockSummary([]byte("missing")); err == nil {
+		t.Error("Expected error for unknown block")
+	}
+}
+
+// forkTestBranch builds n blocks on top of prev without adding them
+func forkTestBranch(bc *Blockchain, prev Block, blockType BlockType, n int) []Block {
+	branch := make([]Block, 0, n)
+	for i := 0; i < n; i++ {
+		prev = buildTestBlock(bc, prev, blockType, fmt.Sprintf("fork-miner-%d", i), 30)
+		branch = append(branch, prev)
+	}
+	return branch
+}
+
+func TestConfirmations(t *testing.T) {
+	bc := NewBlockchain()
+	var mined []Block
+	for i := 0; i < 3; i++ {
+		mined = append(mined, mineTestBlock(t, bc, GoldenBlock, fmt.Sprintf("miner-%d", i)))
+	}
+
+	tests := []struct {
+		name string
+		hash []byte
+		want int
+	}{
+		{"tip", mined[2].Hash, 1},
+		{"buried", mined[0].Hash, 3},
+		{"genesis", GoldenGenesisBlock.Hash, 4},
+	}
+	for _, tt := range tests {
+		got, err := bc.Confirmations(tt.hash)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: expected %d confirmations, got %d (%v)", tt.name, tt.want, got, err)
+		}
+	}
+
+	// Replace the last two blocks with a longer branch
+	branch := forkTestBranch(bc, mined[0], GoldenBlock, 3)
+	if err := bc.Reorganize(GoldenBlock, 1, branch); err != nil {
+		t.Fatalf("Reorganize failed: %v", err)
+	}
+
+	for _, orphaned := range mined[1:] {
+		got, err := bc.Confirmations(orphaned.Hash)
+		if err != nil || got != -1 {
+			t.Errorf("Expected -1 for reorged-out block, got %d (%v)", got, err)
+		}
+	}
+	if got, _ := bc.Confirmations(branch[2].Hash); got != 1 {
+		t.Errorf("Expected new tip to have 1 confirmation, got %d", got)
+	}
+	if got, _ := bc.Confirmations(mined[0].Hash); got != 4 {
+		t.Errorf("Expected fork point to have 4 confirmations, got %d", got)
+	}
+	for _, b := range bc.Blocks {
+		if bytes.Equal(b.Hash, mined[2].Hash) {
+			t.Error("Expected reorged-out block to be removed from Blocks")
+		}
+	}
+
+	if got, err := bc.Confirmations([]byte("missing")); err == nil || got != 0 {
+		t.Errorf("Expected 0 and an error for unknown block, got %d (%v)", got, err)
+	}
+}
+
+func TestChainBlocksInRange(t *testing.T) {
+	bc := NewBlockchain()
+	golden := mineTestBlock(t, bc, GoldenBlock, "range-miner")
+	silver := mineTestBlock(t, bc, SilverBlock, "range-miner")
+	mineTestBlock(t, bc, GoldenBlock, "range-miner")
+
+	// Height 1 of each chain, in the order the blocks were added
+	blocks, err := bc.ChainBlocksInRange(1, 1)
+	if err != nil {
+		t.Fatalf("ChainBlocksInRange failed: %v", err)
+	}
+	if len(blocks) != 2 || !bytes.Equal(blocks[0].Hash, golden.Hash) || !bytes.Equal(blocks[1].Hash, silver.Hash) {
+		t.Fatalf("Expected the first golden then the first silver block, got %d blocks", len(blocks))
+	}
+	for _, b := range blocks {
+		if b.Height != 1 {
+			t.Errorf("Expected height 1 on the %s chain, got %d", b.BlockType, b.Height)
+		}
+	}
+
+	// A negative end runs to each chain's tip
+	if blocks, _ := bc.ChainBlocksInRange(0, -1); len(blocks) != 5 {
+		t.Errorf("Expected all 5 blocks, got %d", len(blocks))
+	}
+	if _, err := bc.ChainBlocksInRange(3, -1); err == nil {
+		t.Error("Expected a range above both tips to be rejected")
+	}
+}
+
+func TestReorganizeRefusesDeepReorg(t *testing.T) {
+	if err := logger.Init(); err != nil {
+		t.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	bc := NewBlockchain()
+	params := bc.ConsensusParams()
+	params.MaxReorgDepth = 2
+	bc.SetConsensusParams(params)
+
+	var alerted *ReorgDepthError
+	bc.OnReorgRejected(func(err *ReorgDepthError) { alerted = err })
+
+	var mined []Block
+	for i := 0; i < 4; i++ {
+		mined = append(mined, mineTestBlock(t, bc, GoldenBlock, fmt.Sprintf("miner-%d", i)))
+	}
+	tip := mined[3]
+
+	// Disconnecting three blocks exceeds the limit of two
+	err := bc.Reorganize(GoldenBlock, 1, forkTestBranch(bc, mined[0], GoldenBlock, 4))
+	var depthErr *ReorgDepthError
+	if !errors.As(err, &depthErr) {
+		t.Fatalf("Expected ReorgDepthError, got %v", err)
+	}
+	if depthErr.Depth != 3 || depthErr.MaxDepth != 2 {
+		t.Errorf("Expected depth 3 over max 2, got %d over %d", depthErr.Depth, depthErr.MaxDepth)
+	}
+	if alerted == nil || alerted.Depth != 3 {
+		t.Error("Expected the rejected reorg to be alerted")
+	}
+	if latest := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]; !bytes.Equal(latest.Hash, tip.Hash) {
+		t.Error("Expected the current tip to be retained")
+	}
+
+	// A reorg within the limit is accepted
+	if err := bc.Reorganize(GoldenBlock, 2, forkTestBranch(bc, mined[1], GoldenBlock, 3)); err != nil {
+		t.Errorf("Expected reorg of depth 2 to be accepted, got %v", err)
+	}
+
+	// Undo data is kept only for the blocks a reorg may still disconnect, and
+	// reorged-out blocks are forgotten once buried past the limit
+	for i := 0; i < 3; i++ {
+		mineTestBlock(t, bc, GoldenBlock, "burying-miner")
+	}
+	if len(bc.undo) != 2 {
+		t.Errorf("Expected undo data for the top 2 blocks only, got %d", len(bc.undo))
+	}
+	for _, orphaned := range mined[2:] {
+		if _, err := bc.Confirmations(orphaned.Hash); err == nil {
+			t.Errorf("Expected block %x buried past the reorg limit to be forgotten", orphaned.Hash)
+		}
+	}
+	if len(bc.reorgedOut) != 0 {
+		t.Errorf("Expected no reorged-out blocks to be kept, got %d", len(bc.reorgedOut))
+	}
+}
+
+func TestTransactionWeight(t *testing.T) {
+	newTx := func(signatureSize, keySize int) Transaction {
+		return Transaction{
+			ID: make([]byte, 32),
+			Inputs: []TxInput{{
+				TxID:      make([]byte, 32),
+				Signature: make([]byte, signatureSize),
+				PublicKey: make([]byte, keySize),
+			}},
+			Outputs: []TxOutput{{
+				Value:         1,
+				CoinType:      Leah,
+				PublicKeyHash: make([]byte, 20),
+			}},
+		}
+	}
+	legacyTx := newTx(64, 65)
+	segwitTx := newTx(64, crypto.CompressedPublicKeyLength)
+	taprootTx := newTx(crypto.SchnorrSignatureLength, crypto.XOnlyPublicKeyLength)
+
+	tests := []struct {
+		name   string
+		tx     Transaction
+		size   int
+		weight int
+		vsize  int
+	}{
+		// 32 ID + (32 TxID + 8 index + 8 amount + 129 signature data) + (8 value + 4 coin + 20 hash)
+		{"legacy", legacyTx, 241, 964, 241},
+		// A compressed key spends a SegWit output; its 97 signature bytes
+		// move to the witness: 112*4 + 97 = 545, 545/4 rounds up to 137
+		{"segwit", segwitTx, 209, 545, 137},
+		// A Taproot key-path spend: 112*4 + 96 = 544
+		{"taproot", taprootTx, 208, 544, 136},
+	}
+	for _, tt := range tests {
+		if got := tt.tx.Size(); got != tt.size {
+			t.Errorf("%s: expected size %d, got %d", tt.name, tt.size, got)
+		}
+		if got := tt.tx.Weight(); got != tt.weight {
+			t.Errorf("%s: expected weight %d, got %d", tt.name, tt.weight, got)
+		}
+		if got := tt.tx.VirtualSize(); got != tt.vsize {
+			t.Errorf("%s: expected vsize %d, got %d", tt.name, tt.vsize, got)
+		}
+	}
+
+	// Block size accounting uses virtual size
+	bc := NewBlockchain()
+	legacy := Block{BlockType: GoldenBlock, Transactions: []Transaction{legacyTx}}
+	segwit := Block{BlockType: GoldenBlock, Transactions: []Transaction{segwitTx}}
+	if diff := bc.calculateBlockSize(legacy) - bc.calculateBlockSize(segwit); diff != 241-137 {
+		t.Errorf("Expected SegWit block to be 104 virtual bytes smaller, got %d", diff)
+	}
+
+	// Unsigned inputs are sized by the address of the output they spend
+	var sizes []int
+	for _, address := range []string{
+		hex.EncodeToString(make([]byte, 32)),
+		crypto.EncodeAddress(0, make([]byte, 32)),
+		crypto.EncodeAddress(1, make([]byte, 32)),
+	} {
+		unsigned := newTx(0, 0)
+		unsigned.Inputs[0].Address = address
+		signed := newTx(0, 0)
+		signed.Inputs[0].Address = address
+		signed.Inputs[0].Signature, signed.Inputs[0].PublicKey = SignaturePlaceholder(address)
+		if got, want := unsigned.signedVirtualSize(), signed.VirtualSize(); got != want {
+			t.Errorf("%s: expected a signed vsize of %d, got %d", address, want, got)
+		}
+		sizes = append(sizes, unsigned.signedVirtualSize())
+	}
+	if !(sizes[0] > sizes[1] && sizes[1] > sizes[2]) {
+		t.Errorf("Expected legacy, SegWit and Taproot spends to shrink in that order, got %v", sizes)
+	}
+}
+
+// signedTestChild spends output index of parent back to key, one output per value
+func signedTestChild(t *testing.T, key *ecdsa.PrivateKey, parent Transaction, index int, values ...float64) Transaction {
+	tx := Transaction{
+		Inputs: []TxInput{{
+			TxID:        parent.ID,
+			OutputIndex: index,
+			Amount:      parent.Outputs[index].Value,
+			PublicKey:   crypto.PublicKeyToBytes(&key.PublicKey),
+		}},
+		Timestamp: time.Now(),
+		Nonce:     NewTxNonce(),
+	}
+	for _, value := range values {
+		tx.Outputs = append(tx.Outputs, TxOutput{
+			Value:         value,
+			CoinType:      Leah,
+			PublicKeyHash: crypto.HashPublicKey(&key.PublicKey),
+			Address:       "self",
+		})
+	}
+	tx.ID = tx.CalculateHash()
+	if err := tx.Sign(key.D.Bytes()); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	return tx
+}
+
+func TestMempoolAncestorLimits(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	bc := NewBlockchain()
+	bc.MempoolConfig.MaxAncestorCount = 3
+	funding := fundTestKey(t, bc, key, "chain-funding", 10)
+
+	// A chain of three pending transactions is at the limit
+	var chain []Transaction
+	parent := *funding
+	for i := 0; i < 3; i++ {
+		child := signedTestChild(t, key, parent, 0, 9-float64(i))
+		if err := bc.AddTransaction(child); err != nil {
+			t.Fatalf("Expected transaction %d within the ancestor limit to be accepted, got %v", i, err)
+		}
+		chain = append(chain, child)
+		parent = child
+	}
+
+	// A fourth link would have three pending ancestors
+	err = bc.AddTransaction(signedTestChild(t, key, parent, 0, 5))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "ancestors" {
+		t.Errorf("Expected ancestor limit rejection, got %v", err)
+	}
+
+	// The size limit applies to the whole ancestor package
+	bc.MempoolConfig.MaxAncestorCount = 25
+	bc.MempoolConfig.MaxAncestorSize = parent.VirtualSize() * 3
+	err = bc.AddTransaction(signedTestChild(t, key, parent, 0, 5))
+	if !errors.As(err, &validationErr) || validationErr.Field != "ancestors" {
+		t.Errorf("Expected ancestor size rejection, got %v", err)
+	}
+
+	// One block may confirm the whole chain, each spending the one before it
+	mineTestBlockWith(t, bc, GoldenBlock, "chain-miner", chain...)
+	if pending := bc.GetPendingTransactions(); len(pending) != 0 {
+		t.Errorf("Expected the confirmed chain to leave the pending pool, got %d pending", len(pending))
+	}
+}
+
+func TestCheckInputsSpendable(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	bc := NewBlockchain()
+	funding := fundTestKey(t, bc, key, "spendable-funding", 10)
+	parent := signedTestChild(t, key, *funding, 0, 9)
+	child := signedTestChild(t, key, parent, 0, 8)
+
+	// An output on neither the chain nor the view is not counted as confirmed
+	if err := bc.checkInputsSpendable(child, bc.UTXOSet); err == nil || !strings.Contains(err.Error(), "not on chain") {
+		t.Errorf("Expected an output missing from the chain to be rejected, got %v", err)
+	}
+
+	// An unconfirmed parent in the view is spent as a package with it
+	view := bc.UTXOSet.Clone()
+	view.AddOutputs(&parent, bc.nextHeight(GoldenBlock))
+	if err := bc.checkInputsSpendable(child, view); err != nil {
+		t.Errorf("Expected the output of an unconfirmed parent in the view to be spendable, got %v", err)
+	}
+	if err := bc.checkInputsSpendable(parent, bc.UTXOSet); err != nil {
+		t.Errorf("Expected a confirmed output to be spendable, got %v", err)
+	}
+}
+
+func TestMempoolDescendantLimits(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	bc := NewBlockchain()
+	bc.MempoolConfig.MaxDescendantCount = 3
+	funding := fundTestKey(t, bc, key, "fanout-funding", 10)
+
+	parent := signedTestChild(t, key, *funding, 0, 3, 3, 3)
+	if err := bc.AddTransaction(parent); err != nil {
+		t.Fatalf("AddTransaction failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := bc.AddTransaction(signedTestChild(t, key, parent, i, 2)); err != nil {
+			t.Fatalf("Expected child %d within the descendant limit to be accepted, got %v", i, err)
+		}
+	}
+
+	err = bc.AddTransaction(signedTestChild(t, key, parent, 2, 2))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "descendants" {
+		t.Errorf("Expected descendant limit rejection, got %v", err)
+	}
+}
+
+// recordProgress returns a progress callback that fails the test unless done
+// increases monotonically against a fixed total, with pointers to the last done and total
+func recordProgress(t *testing.T) (ProgressFunc, *int64, *int64) {
+	var lastDone, lastTotal int64
+	return func(done, total int64) {
+		if done <= lastDone || done > total || (lastTotal != 0 && total != lastTotal) {
+			t.Errorf("Unexpected progress %d/%d after %d/%d", done, total, lastDone, lastTotal)
+		}
+		lastDone, lastTotal = done, total
+	}, &lastDone, &lastTotal
+}
+
+func TestBackupProgress(t *testing.T) {
+	backupDir := t.TempDir()
+	src := NewBlockchain()
+	src.BackupDir = backupDir
+	for i := 0; i < 3; i++ {
+		mineTestBlock(t, src, GoldenBlock, fmt.Sprintf("miner-%d", i))
+	}
+
+	// Progress is reported with the chain unlocked
+	unlocked := func(bc *Blockchain, progress ProgressFunc) ProgressFunc {
+		return func(done, total int64) {
+			if !bc.mu.TryLock() {
+				t.Errorf("Progress %d/%d reported while holding the chain lock", done, total)
+			} else {
+				bc.mu.Unlock()
+			}
+			progress(done, total)
+		}
+	}
+
+	progress, done, total := recordProgress(t)
+	if err := src.CreateBackupWithProgress("snapshot", "secret", unlocked(src, progress)); err != nil {
+		t.Fatalf("CreateBackupWithProgress failed: %v", err)
+	}
+	if *done != 5 || *total != 5 {
+		t.Errorf("Expected export progress to reach 5/5, got %d/%d", *done, *total)
+	}
+	if backups := src.ListBackups(); len(backups) != 1 || backups[0] != "snapshot" {
+		t.Errorf("Expected one backup named snapshot, got %v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(backupDir, "snapshot.zip")); err != nil {
+		t.Errorf("Expected a compressed backup: %v", err)
+	}
+	if err := src.CreateBackup("snapshot"); err == nil {
+		t.Error("Expected error replacing an existing backup")
+	}
+
+	// An encrypted backup needs its passphrase
+	dst := NewBlockchain()
+	dst.BackupDir = backupDir
+	for _, passphrase := range []string{"", "wrong"} {
+		if err := dst.RestoreBackupWithProgress("snapshot", passphrase, nil); err == nil {
+			t.Errorf("Expected error restoring with passphrase %q", passphrase)
+		}
+	}
+
+	// Restoring skips the genesis blocks the new chain already has
+	progress, done, total = recordProgress(t)
+	if err := dst.RestoreBackupWithProgress("snapshot", "secret", unlocked(dst, progress)); err != nil {
+		t.Fatalf("RestoreBackupWithProgress failed: %v", err)
+	}
+	if *done != 3 || *total != 3 {
+		t.Errorf("Expected import progress to reach 3/3, got %d/%d", *done, *total)
+	}
+	if len(dst.GoldenBlocks) != 4 {
+		t.Errorf("Expected 4 golden blocks after restore, got %d", len(dst.GoldenBlocks))
+	}
+
+	if err := src.DeleteBackup("snapshot"); err != nil {
+		t.Errorf("DeleteBackup failed: %v", err)
+	}
+	if backups := src.ListBackups(); len(backups) != 0 {
+		t.Errorf("Expected no backups after delete, got %v", backups)
+	}
+	if err := src.CreateBackup("../escape"); err == nil {
+		t.Error("Expected error for backup name with a path")
+	}
+}
+
+// TestRecentBlocksConcurrentAccess reads blocks while others are added; run
+// with -race to check the accessors hold the lock
+func TestRecentBlocksConcurrentAccess(t *testing.T) {
+	logger.Init()
+	bc := NewBlockchain()
+
+	const added = 20
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < added; i++ {
+			prev := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+			if err := bc.AddBlock(buildTestBlock(bc, prev, GoldenBlock, "miner", 60)); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	for reading := true; reading; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("AddBlock failed: %v", err)
+			}
+			reading = false
+		default:
+		}
+
+		recent := bc.RecentBlocks(5)
+		if len(recent) == 0 || len(recent) > 5 {
+			t.Fatalf("RecentBlocks(5) returned %d blocks", len(recent))
+		}
+		hashes := bc.AllBlockHashes()
+		if len(hashes) < 2 {
+			t.Fatalf("AllBlockHashes returned %d hashes", len(hashes))
+		}
+	}
+
+	hashes := bc.AllBlockHashes()
+	if len(hashes) != added+2 {
+		t.Fatalf("Expected %d block hashes, got %d", added+2, len(hashes))
+	}
+	recent := bc.RecentBlocks(3)
+	if len(recent) != 3 || !bytes.Equal(recent[2].Hash, hashes[len(hashes)-1]) {
+		t.Errorf("RecentBlocks should end with the latest block")
+	}
+	if got := bc.RecentBlocks(100); len(got) != len(hashes) {
+		t.Errorf("RecentBlocks(100) returned %d blocks, want %d", len(got), len(hashes))
+	}
+}
+
+// TestGetBlockReturnsCopy tests that GetBlock, GetTransaction and
+// GetLatestBlock return the stored data and that mutating the result leaves the chain untouched
+func TestGetBlockReturnsCopy(t *testing.T) {
+	logger.Init()
+	bc := NewBlockchain()
+	first := mineTestBlockWith(t, bc, GoldenBlock, "first")
+	second := mineTestBlockWith(t, bc, GoldenBlock, "second")
+
+	got, err := bc.GetBlock(first.Hash)
+	if err != nil {
+		t.Fatalf("GetBlock failed: %v", err)
+	}
+	other, err := bc.GetBlock(second.Hash)
+	if err != nil {
+		t.Fatalf("GetBlock failed: %v", err)
+	}
+	if !bytes.Equal(got.Hash, first.Hash) || got.Timestamp != first.Timestamp {
+		t.Errorf("GetBlock returned the wrong block")
+	}
+	if got == other || !bytes.Equal(other.Hash, second.Hash) {
+		t.Errorf("GetBlock results should be distinct copies of their blocks")
+	}
+
+	got.Nonce++
+	got.Transactions[0].Outputs[0].Value = 1000
+	again, _ := bc.GetBlock(first.Hash)
+	if again.Nonce != first.Nonce || again.Transactions[0].Outputs[0].Value != 50 {
+		t.Errorf("Mutating the returned block changed the stored block")
+	}
+
+	coinbaseID := second.Transactions[0].ID
+	tx, err := bc.GetTransaction(coinbaseID)
+	if err != nil {
+		t.Fatalf("GetTransaction failed: %v", err)
+	}
+	if !bytes.Equal(tx.ID, coinbaseID) || tx.Outputs[0].Address != "second" {
+		t.Errorf("GetTransaction returned the wrong transaction")
+	}
+	tx.Outputs[0].Address = "thief"
+	again2, _ := bc.GetTransaction(coinbaseID)
+	if again2.Outputs[0].Address != "second" {
+		t.Errorf("Mutating the returned transaction changed the stored transaction")
+	}
+
+	latest := bc.GetLatestBlock()
+	if !bytes.Equal(latest.Hash, second.Hash) {
+		t.Fatalf("GetLatestBlock returned the wrong block")
+	}
+	latest.Nonce++
+	latest.Transactions[0].Outputs[0].Value = 1000
+	if again := bc.GetLatestBlock(); again.Nonce != second.Nonce || again.Transactions[0].Outputs[0].Value != 50 {
+		t.Errorf("Mutating the latest block changed the stored block")
+	}
+}
+
+// TestAddBlockPrunesMempool tests that adding a block removes its
+// transactions and anything conflicting with them from the pending pool
+func TestAddBlockPrunesMempool(t *testing.T) {
+	logger.Init()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	mineTestBlockWith(t, bc, GoldenBlock, "miner")
+
+	included := signedTestSpend(t, key, fundTestKey(t, bc, key, "funding-a", 10), 9)
+	contested := fundTestKey(t, bc, key, "funding-b", 10)
+	conflict := signedTestChild(t, key, *contested, 0, 9)
+	child := signedTestChild(t, key, conflict, 0, 8)
+	for _, tx := range []Transaction{included, conflict, child} {
+		if err := bc.AddTransaction(tx); err != nil {
+			t.Fatalf("AddTransaction failed: %v", err)
+		}
+	}
+
+	// The block confirms one pending transaction and double spends another
+	doubleSpend := signedTestSpend(t, key, contested, 7)
+	mineTestBlockWith(t, bc, GoldenBlock, "miner", included, doubleSpend)
+
+	if pending := bc.GetPendingTransactions(); len(pending) != 0 {
+		t.Errorf("Expected an empty mempool after the block, got %d transactions", len(pending))
+	}
+
+	// Unrelated transactions stay pending
+	other := signedTestSpend(t, key, fundTestKey(t, bc, key, "funding-c", 10), 9)
+	if err := bc.AddTransaction(other); err != nil {
+		t.Fatalf("AddTransaction failed: %v", err)
+	}
+	if removed := bc.RemoveTransactionsFromMempool([]Transaction{included}); removed != 0 {
+		t.Errorf("Expected nothing removed for an already confirmed transaction, got %d", removed)
+	}
+	if pending := bc.GetPendingTransactions(); len(pending) != 1 || !bytes.Equal(pending[0].ID, other.ID) {
+		t.Errorf("Unrelated pending transaction should remain")
+	}
+}
+
+// TestOrphanBlocks tests that a block arriving before its parent is held
+// and connected, with its own descendants, once the parent arrives
+func TestOrphanBlocks(t *testing.T) {
+	logger.Init()
+	bc := NewBlockchain()
+
+	parent := buildTestBlock(bc, bc.GoldenBlocks[0], GoldenBlock, "miner", 60)
+	child := buildTestBlock(bc, parent, GoldenBlock, "miner", 60)
+	grandchild := buildTestBlock(bc, child, GoldenBlock, "miner", 60)
+
+	for _, b := range []Block{grandchild, child} {
+		if err := bc.AddBlock(b); !errors.Is(err, ErrOrphanBlock) {
+			t.Fatalf("Expected ErrOrphanBlock for a block without its parent, got %v", err)
+		}
+	}
+	if len(bc.GoldenBlocks) != 1 || bc.OrphanCount() != 2 {
+		t.Fatalf("Orphans should be held off the chain, got %d blocks and %d orphans", len(bc.GoldenBlocks), bc.OrphanCount())
+	}
+	orphans := bc.GetOrphanBlocks()
+	if len(orphans) != 2 || !bytes.Equal(orphans[0].Hash, child.Hash) || !bytes.Equal(orphans[1].Hash, grandchild.Hash) {
+		t.Fatalf("Expected the child and grandchild in the orphan pool, oldest first, got %d orphans", len(orphans))
+	}
+
+	connected, err := bc.ProcessBlock(parent)
+	if err != nil {
+		t.Fatalf("ProcessBlock failed for the parent: %v", err)
+	}
+	if len(connected) != 2 || !bytes.Equal(connected[0].Hash, child.Hash) || !bytes.Equal(connected[1].Hash, grandchild.Hash) {
+		t.Errorf("Expected the child and grandchild reported as connected, in order, got %d blocks", len(connected))
+	}
+	if len(bc.GoldenBlocks) != 4 {
+		t.Fatalf("Expected the parent and both orphans on the chain, got %d blocks", len(bc.GoldenBlocks))
+	}
+	for i, b := range []Block{parent, child, grandchild} {
+		if !bytes.Equal(bc.GoldenBlocks[i+1].Hash, b.Hash) {
+			t.Errorf("Block %d on the chain is out of order", i+1)
+		}
+	}
+	if bc.OrphanCount() != 0 || len(bc.GetOrphanBlocks()) != 0 {
+		t.Errorf("Expected the orphan pool to be empty, got %d", bc.OrphanCount())
+	}
+}
+
+// TestParallelMinerStop tests that stopping the miner stops every worker;
+// run with -race to check workers and Stop don't race
+func TestParallelMinerStop(t *testing.T) {
+	config := *NewMiningConfig()
+	config.NumWorkers = 4
+	config.MaxNonce = 1 << 62
+	config.TargetDifficulty = big.NewInt(0) // never satisfied
+	miner := NewMiner(config)
+
+	block := &Block{Timestamp: time.Now().Unix(), BlockType: GoldenBlock}
+	miner.Start(block)
+	time.Sleep(20 * time.Millisecond)
+	if got := miner.ActiveWorkers(); got != 4 {
+		t.Fatalf("Expected 4 running workers, got %d", got)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		miner.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return; workers are still running")
+	}
+	if got := miner.ActiveWorkers(); got != 0 {
+		t.Errorf("Expected all workers to return, %d still running", got)
+	}
+	if miner.Wait() {
+		t.Error("No block should have been found")
+	}
+	miner.Stop() // stopping twice is harmless
+
+	// A found block stops the other workers without Stop
+	config.TargetDifficulty = new(big.Int).Lsh(big.NewInt(1), 256)
+	miner = NewMiner(config)
+	miner.Start(block)
+	if !miner.Wait() {
+		t.Fatal("Expected a worker to find a block")
+	}
+	if got := miner.ActiveWorkers(); got != 0 {
+		t.Errorf("Expected all workers to return after a find, %d still running", got)
+	}
+	if miner.GetStats().BlocksFound != 1 {
+		t.Errorf("Expected exactly one block found, got %d", miner.GetStats().BlocksFound)
+	}
+}
+
+// addressBalanceTestSet fills a UTXO set with outputs of every coin type
+// spread over several addresses, some of them spent
+func addressBalanceTestSet(outputs int) *UTXOSet {
+	set := NewUTXOSet()
+	for i := 0; i < outputs; i++ {
+		set.Add(UTXO{
+			TxID:     fmt.Sprintf("tx%d:0", i),
+			Amount:   float64(i%7 + 1),
+			Address:  fmt.Sprintf("addr%d", i%3),
+			CoinType: AllCoinTypes[i%len(AllCoinTypes)],
+			Spent:    i%5 == 0,
+		})
+	}
+	return set
+}
+
+func TestGetAddressBalances(t *testing.T) {
+	bc := NewBlockchain()
+	bc.UTXOSet = addressBalanceTestSet(500)
+
+	balances := bc.GetAddressBalances("addr1")
+	for _, coinType := range AllCoinTypes {
+		if got, want := balances[coinType], bc.UTXOSet.GetBalance("addr1", coinType); got != want {
+			t.Errorf("GetAddressBalances[%s] = %v; want %v", coinType, got, want)
+		}
+	}
+	if len(bc.GetAddressBalances("nobody")) != 0 {
+		t.Error("Expected no balances for an unknown address")
+	}
+}
+
+func BenchmarkGetAddressBalances(b *testing.B) {
+	bc := NewBlockchain()
+	bc.UTXOSet = addressBalanceTestSet(10000)
+
+	b.Run("SinglePass", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			bc.GetAddressBalances("addr1")
+		}
+	})
+	b.Run("PerCoin", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, coinType := range AllCoinTypes {
+				bc.UTXOSet.GetBalance("addr1", coinType)
+			}
+		}
+	})
+}
+
+func TestMinRelayFeeRate(t *testing.T) {
+	logger.Init()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	mineTestBlockWith(t, bc, GoldenBlock, "miner")
+
+	tx := signedTestSpend(t, key, fundTestKey(t, bc, key, "funding", 10), 9.99)
+	atMin := tx.GetFee() / float64(tx.VirtualSize())
+
+	// Paying just below the minimum is rejected
+	params := bc.ConsensusParams()
+	params.MinRelayFeeRate = atMin * 1.01
+	bc.SetConsensusParams(params)
+	if err := bc.AddTransaction(tx); !errors.Is(err, ErrFeeTooLow) {
+		t.Fatalf("Expected ErrFeeTooLow below the minimum, got %v", err)
+	}
+
+	// Paying exactly the minimum is accepted
+	params.MinRelayFeeRate = atMin
+	bc.SetConsensusParams(params)
+	if err := bc.AddTransaction(tx); err != nil {
+		t.Fatalf("Expected a transaction at the minimum fee rate to be accepted: %v", err)
+	}
+
+	// Zero-fee transactions are rejected by mainnet defaults but relayed on regtest
+	free := signedTestSpend(t, key, fundTestKey(t, bc, key, "free", 10), 10)
+	bc.SetConsensusParams(DefaultConsensusParams())
+	if err := bc.AddTransaction(free); !errors.Is(err, ErrFeeTooLow) {
+		t.Errorf("Expected mainnet to reject a zero-fee transaction, got %v", err)
+	}
+	if err := bc.SetNetworkMode(Regtest); err != nil {
+		t.Fatalf("SetNetworkMode failed: %v", err)
+	}
+	if err := bc.AddTransaction(free); err != nil {
+		t.Errorf("Expected regtest to accept a zero-fee transaction: %v", err)
+	}
+}
+
+func TestMineBlockWithoutGenesis(t *testing.T) {
+	bc := NewBlockchain()
+	bc.GoldenBlocks = nil
+	bc.SilverBlocks = nil
+
+	for _, blockType := range []BlockType{GoldenBlock, SilverBlock} {
+		coin := Leah
+		if blockType == SilverBlock {
+			coin = Senum
+		}
+		_, err := bc.MineBlock(nil, blockType, coin)
+		if !errors.Is(err, ErrChainNotInitialized) {
+			t.Errorf("Expected ErrChainNotInitialized mining on an empty %s chain, got %v", blockType, err)
+		}
+	}
+
+	block := Block{BlockType: GoldenBlock, Timestamp: time.Now().Unix()}
+	if err := bc.validateBlock(block); !errors.Is(err, ErrChainNotInitialized) {
+		t.Errorf("Expected ErrChainNotInitialized validating against an empty chain, got %v", err)
+	}
+}
+
+func TestPerChainMaxBlockSize(t *testing.T) {
+	bc := NewBlockchain()
+	golden := buildTestBlock(bc, bc.GoldenBlocks[len(bc.GoldenBlocks)-1], GoldenBlock, "golden-miner", 60)
+	silver := buildTestBlock(bc, bc.SilverBlocks[len(bc.SilverBlocks)-1], SilverBlock, "silver-miner", 60)
+	size := bc.calculateBlockSize(golden)
+
+	// A block exactly at its chain's limit is accepted
+	params := bc.ConsensusParams()
+	params.MaxGoldenBlockSize = size
+	params.MaxSilverBlockSize = size - 1
+	bc.SetConsensusParams(params)
+	if err := bc.validateBlock(golden); err != nil {
+		t.Errorf("Expected a golden block at the limit to validate: %v", err)
+	}
+
+	// The same block one byte over the other chain's limit is rejected
+	if bc.calculateBlockSize(silver) != size {
+		t.Fatalf("Expected golden and silver test blocks of equal size")
+	}
+	err := bc.validateBlock(silver)
+	if err == nil || !strings.Contains(err.Error(), "block size exceeds maximum") {
+		t.Errorf("Expected a silver block over the limit to be rejected, got %v", err)
+	}
+	if got := params.MaxBlockWeightFor(SilverBlock); got != (size-1)*WitnessScaleFactor {
+		t.Errorf("Expected silver weight limit %d, got %d", (size-1)*WitnessScaleFactor, got)
+	}
+}
+
+func TestValidateBlockTransactionSanity(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	funding := fundTestKey(t, bc, key, "sanity-funding", 10)
+	tip := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+
+	noOutputs := signedTestChild(t, key, *funding, 0)
+	inflating := signedTestSpend(t, key, funding, 15)
+	doubled := signedTestSpend(t, key, funding, 5)
+	doubled.Inputs = append(doubled.Inputs, doubled.Inputs[0])
+	doubled.RecomputeID()
+
+	// Paying out another coin must go through a conversion or a mint, even
+	// when the coin is worth less in Leah than the inputs
+	pays := func(outputs ...TxOutput) Transaction {
+		tx := signedTestSpend(t, key, funding, 0)
+		tx.Outputs = outputs
+		tx.RecomputeID()
+		if err := tx.Sign(key.D.Bytes()); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		return tx
+	}
+	recast := pays(TxOutput{Value: 1, CoinType: Senine, PublicKeyHash: []byte("recipient")})
+	converted := pays(
+		TxOutput{Value: 4, CoinType: Shiblum, PublicKeyHash: []byte("recipient")},
+		TxOutput{Value: 1, CoinType: Leah, PublicKeyHash: []byte("recipient")},
+	)
+	overConverted := pays(
+		TxOutput{Value: 5, CoinType: Shiblum, PublicKeyHash: []byte("recipient")},
+		TxOutput{Value: 1, CoinType: Leah, PublicKeyHash: []byte("recipient")},
+	)
+
+	tests := []struct {
+		name   string
+		tx     Transaction
+		reason string
+	}{
+		{"zero outputs", noOutputs, "no outputs"},
+		{"outputs exceed inputs", inflating, "exceed inputs"},
+		{"duplicate input", doubled, "spent twice"},
+		{"coin created without a rule", recast, "insufficient balance for Senine"},
+		{"conversion short of its inputs", overConverted, "insufficient balance for Shiblum"},
+	}
+	for _, tt := range tests {
+		block := buildTestBlock(bc, tip, GoldenBlock, "sanity-miner", 60, tt.tx)
+		err := bc.validateBlock(block)
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || !strings.Contains(validationErr.Reason, tt.reason) {
+			t.Errorf("%s: expected a validation error containing %q, got %v", tt.name, tt.reason, err)
+		}
+	}
+
+	// Spending less than the inputs is accepted, the rest being fee
+	valid := buildTestBlock(bc, tip, GoldenBlock, "sanity-miner", 60, signedTestSpend(t, key, funding, 9))
+	if err := bc.validateBlock(valid); err != nil {
+		t.Errorf("Expected a fee-paying spend to validate: %v", err)
+	}
+	valid = buildTestBlock(bc, tip, GoldenBlock, "sanity-miner", 60, converted)
+	if err := bc.validateBlock(valid); err != nil {
+		t.Errorf("Expected a conversion at two Leah per Shiblum to validate: %v", err)
+	}
+}
+
+func TestTipConcurrentAccess(t *testing.T) {
+	logger.Init()
+	bc := NewBlockchain()
+
+	// Each batch extends both chains at once, so a consistent view always
+	// sees tips from the same round
+	round := func(b *Block) string {
+		_, round, found := strings.Cut(b.Transactions[0].Outputs[0].Address, "-")
+		if !found {
+			return "genesis"
+		}
+		return round
+	}
+
+	const rounds = 20
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < rounds; i++ {
+			golden := buildTestBlock(bc, bc.GoldenBlocks[len(bc.GoldenBlocks)-1], GoldenBlock, fmt.Sprintf("golden-%d", i), 1)
+			silver := buildTestBlock(bc, bc.SilverBlocks[len(bc.SilverBlocks)-1], SilverBlock, fmt.Sprintf("silver-%d", i), 1)
+			if err := bc.AddBlockBatch([]Block{golden, silver}); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	for reading := true; reading; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("AddBlockBatch failed: %v", err)
+			}
+			reading = false
+		default:
+		}
+
+		golden, silver := bc.Tip()
+		if golden == nil || silver == nil {
+			t.Fatalf("Tip returned a nil tip")
+		}
+		if round(golden) != round(silver) {
+			t.Fatalf("Tip returned tips from different rounds: %s and %s", round(golden), round(silver))
+		}
+	}
+
+	golden, silver := bc.Tip()
+	if round(golden) != fmt.Sprint(rounds-1) || round(silver) != fmt.Sprint(rounds-1) {
+		t.Errorf("Expected both tips from the last round, got %s and %s", round(golden), round(silver))
+	}
+}
+
+func TestSubscribeBlocks(t *testing.T) {
+	logger.Init()
+	bc := NewBlockchain()
+	events, unsubscribe := bc.SubscribeBlocks()
+
+	block := buildTestBlock(bc, bc.GoldenBlocks[0], GoldenBlock, "subscriber", 1)
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != BlockConnected {
+			t.Errorf("Expected a connected event, got %v", event.Type)
+		}
+		if !bytes.Equal(event.Block.Hash, block.Hash) {
+			t.Errorf("Expected event for block %x, got %x", block.Hash, event.Block.Hash)
+		}
+	default:
+		t.Fatal("Expected an event for the connected block")
+	}
+
+	unsubscribe()
+	if _, open := <-events; open {
+		t.Error("Expected the channel to be closed after unsubscribing")
+	}
+	unsubscribe()
+}
+
+func TestValidateChecksInputOwnership(t *testing.T) {
+	owner, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	thief, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	bc := NewBlockchain()
+	funding := fundTestKey(t, bc, owner, "owned-funding", 10)
+
+	// A valid signature from another key does not unlock the output
+	stolen := signedTestSpend(t, thief, funding, 5)
+	if !stolen.Verify() {
+		t.Fatal("Expected the thief's signature to verify on its own")
+	}
+	var validationErr *ValidationError
+	if err := stolen.Validate(bc.UTXOSet); !errors.As(err, &validationErr) || !strings.Contains(validationErr.Reason, "unauthorized input") {
+		t.Errorf("Expected unauthorized input error, got %v", err)
+	}
+
+	spend := signedTestSpend(t, owner, funding, 5)
+	if err := spend.Validate(bc.UTXOSet); err != nil {
+		t.Errorf("Expected the owner's spend to validate, got %v", err)
+	}
+
+	// Outputs recorded only by address are locked to the owner's address
+	byAddress := &Transaction{
+		ID: []byte("address-funding"),
+		Outputs: []TxOutput{{
+			Value:    10,
+			CoinType: Leah,
+			Address:  hex.EncodeToString(crypto.HashPublicKey(&owner.PublicKey)),
+		}},
+	}
+	if err := bc.UTXOSet.UpdateWithTransaction(byAddress); err != nil {
+		t.Fatalf("Failed to fund address: %v", err)
+	}
+	stolen = signedTestSpend(t, thief, byAddress, 5)
+	if err := stolen.Validate(bc.UTXOSet); err == nil {
+		t.Error("Expected a spend by another key to be rejected")
+	}
+	spend = signedTestSpend(t, owner, byAddress, 5)
+	if err := spend.Validate(bc.UTXOSet); err != nil {
+		t.Errorf("Expected the owner's spend by address to validate, got %v", err)
+	}
+}
+
+func TestValidateCrossBlockTransfers(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	funding := fundTestKey(t, bc, key, "block-funding", 10)
+
+	tests := []struct {
+		name      string
+		blockType BlockType
+		wantErr   bool
+	}{
+		{"unspecified block", "", false},
+		{"own block", GoldenBlock, false},
+		{"other block", SilverBlock, true},
+	}
+	for _, tt := range tests {
+		tx := signedTestSpend(t, key, funding, 5)
+		tx.BlockType = tt.blockType
+		tx.ID = tx.CalculateHash()
+		if err := tx.Sign(key.D.Bytes()); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		var validationErr *ValidationError
+		err := tx.Validate(bc.UTXOSet)
+		if tt.wantErr && (!errors.As(err, &validationErr) || validationErr.Field != "block_type") {
+			t.Errorf("%s: expected a block_type error paying Leah, got %v", tt.name, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("%s: expected paying Leah to validate, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestGetDifficultyHistory(t *testing.T) {
+	logger.Init()
+	bc := NewBlockchain()
+
+	// Blocks must be mined at the chain's difficulty, so move it between blocks
+	difficulties := []int{1, 2, 1, 2}
+	for i, difficulty := range difficulties {
+		bc.Difficulty = difficulty
+		block := buildTestBlock(bc, bc.GoldenBlocks[len(bc.GoldenBlocks)-1], GoldenBlock, fmt.Sprintf("difficulty-%d", i), 1)
+		block.Difficulty = difficulty
+		block.Nonce = 0
+		for !bc.isValidProof(block) {
+			block.Nonce++
+		}
+		block.Hash = calculateHash(block)
+		if err := bc.AddBlock(block); err != nil {
+			t.Fatalf("AddBlock failed: %v", err)
+		}
+	}
+
+	history := bc.GetDifficultyHistory(GoldenBlock, 0, int64(len(bc.GoldenBlocks)-1))
+	if len(history) != len(bc.GoldenBlocks) {
+		t.Fatalf("Expected %d points, got %d", len(bc.GoldenBlocks), len(history))
+	}
+	for i, point := range history {
+		block := bc.GoldenBlocks[i]
+		if point.Height != int64(i) || point.Timestamp != block.Timestamp || point.Difficulty != block.Difficulty {
+			t.Errorf("Point %d is %+v, block has timestamp %d and difficulty %d", i, point, block.Timestamp, block.Difficulty)
+		}
+	}
+	for i, difficulty := range difficulties {
+		if history[i+1].Difficulty != difficulty {
+			t.Errorf("Expected difficulty %d at height %d, got %d", difficulty, i+1, history[i+1].Difficulty)
+		}
+	}
+
+	// Ranges are clamped to the chain
+	if tail := bc.GetDifficultyHistory(GoldenBlock, 3, 100); len(tail) != 2 || tail[0].Height != 3 || tail[1].Height != 4 {
+		t.Errorf("Expected heights 3 and 4, got %+v", tail)
+	}
+	if empty := bc.GetDifficultyHistory(GoldenBlock, 10, 20); len(empty) != 0 {
+		t.Errorf("Expected no points past the tip, got %+v", empty)
+	}
+	if silver := bc.GetDifficultyHistory(SilverBlock, 0, 100); len(silver) != len(bc.SilverBlocks) {
+		t.Errorf("Expected %d silver points, got %d", len(bc.SilverBlocks), len(silver))
+	}
+}
+
+func TestMempoolFeeHistogram(t *testing.T) {
+	logger.Init()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	mineTestBlockWith(t, bc, GoldenBlock, "miner")
+
+	// Fees of 1x, 3x, 12x and 15x the cheapest, at nearly equal sizes
+	var txs []Transaction
+	for i, fee := range []float64{0.001, 0.003, 0.012, 0.015} {
+		txs = append(txs, signedTestSpend(t, key, fundTestKey(t, bc, key, fmt.Sprintf("fees-%d", i), 10), 10-fee))
+	}
+	params := bc.ConsensusParams()
+	params.MinRelayFeeRate = txs[0].FeeRate()
+	bc.SetConsensusParams(params)
+	for _, tx := range txs {
+		if err := bc.AddTransaction(tx); err != nil {
+			t.Fatalf("AddTransaction failed: %v", err)
+		}
+	}
+
+	histogram := bc.MempoolFeeHistogram()
+	if len(histogram) != len(feeHistogramBands) {
+		t.Fatalf("Expected %d buckets, got %d", len(feeHistogramBands), len(histogram))
+	}
+	if histogram[0].MaxFeeRate != 0 {
+		t.Errorf("Expected the top bucket to be unbounded, got %+v", histogram[0])
+	}
+
+	vsize := func(txs ...Transaction) int64 {
+		var total int64
+		for _, tx := range txs {
+			total += int64(tx.VirtualSize())
+		}
+		return total
+	}
+	unit := params.MinRelayFeeRate
+	want := map[float64]struct {
+		count      int
+		size       int64
+		cumulative int64
+	}{
+		10 * unit: {2, vsize(txs[2], txs[3]), vsize(txs[2], txs[3])},
+		2 * unit:  {1, vsize(txs[1]), vsize(txs[1], txs[2], txs[3])},
+		unit:      {1, vsize(txs[0]), vsize(txs...)},
+	}
+	for i, bucket := range histogram {
+		if i > 0 && bucket.MinFeeRate >= histogram[i-1].MinFeeRate {
+			t.Errorf("Expected buckets in descending fee rate order, got %+v", histogram)
+		}
+		expected, ok := want[bucket.MinFeeRate]
+		if !ok {
+			if bucket.Count != 0 {
+				t.Errorf("Expected bucket from %f to be empty, got %+v", bucket.MinFeeRate, bucket)
+			}
+			continue
+		}
+		if bucket.Count != expected.count || bucket.VSize != expected.size || bucket.CumulativeVSize != expected.cumulative {
+			t.Errorf("Bucket from %f is %+v, expected %+v", bucket.MinFeeRate, bucket, expected)
+		}
+	}
+}
+
+func TestMempoolDependencies(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	bc := NewBlockchain()
+	funding := fundTestKey(t, bc, key, "deps-funding", 10)
+
+	// A chain of three pending transactions, each spending the previous one
+	var chain []Transaction
+	parent := *funding
+	for i := 0; i < 3; i++ {
+		child := signedTestChild(t, key, parent, 0, 9-float64(i))
+		if err := bc.AddTransaction(child); err != nil {
+			t.Fatalf("AddTransaction %d failed: %v", i, err)
+		}
+		chain = append(chain, child)
+		parent = child
+	}
+
+	deps := bc.MempoolDependencies()
+	if len(deps) != 3 {
+		t.Fatalf("Expected 3 pending transactions, got %v", deps)
+	}
+	id := func(tx Transaction) string { return fmt.Sprintf("%x", tx.ID) }
+
+	// The first spends a confirmed output, so it depends on nothing pending
+	if parents := deps[id(chain[0])]; len(parents) != 0 {
+		t.Errorf("Expected no pending parents for the first transaction, got %v", parents)
+	}
+	for i := 1; i < 3; i++ {
+		parents := deps[id(chain[i])]
+		if len(parents) != 1 || parents[0] != id(chain[i-1]) {
+			t.Errorf("Expected transaction %d to depend on %s, got %v", i, id(chain[i-1]), parents)
+		}
+	}
+}
+
+func TestRetargetIntervalFromParams(t *testing.T) {
+	bc := NewBlockchain()
+	if err := bc.SetNetworkMode(Testnet); err != nil {
+		t.Fatalf("SetNetworkMode failed: %v", err)
+	}
+	params := bc.ConsensusParams()
+	if params.RetargetInterval != 10 {
+		t.Fatalf("Expected testnet to retarget every 10 blocks, got %d", params.RetargetInterval)
+	}
+	params.GenesisDifficulty = 3
+	bc.SetConsensusParams(params)
+	if bc.Difficulty != 3 {
+		t.Fatalf("Expected a fresh chain to start at the genesis difficulty 3, got %d", bc.Difficulty)
+	}
+
+	// Blocks arrive every second against a ten minute target
+	start := time.Now().Unix()
+	bc.GoldenBlocks = []Block{{Timestamp: start, BlockType: GoldenBlock}}
+	for i := int64(1); i < 10; i++ {
+		bc.GoldenBlocks = append(bc.GoldenBlocks, Block{Timestamp: start + i, BlockType: GoldenBlock})
+		if difficulty := bc.CalculateDifficulty(GoldenBlock); difficulty != 3 {
+			t.Fatalf("Expected no retarget at height %d, got difficulty %d", i, difficulty)
+		}
+	}
+	bc.GoldenBlocks = append(bc.GoldenBlocks, Block{Timestamp: start + 10, BlockType: GoldenBlock})
+	if difficulty := bc.CalculateDifficulty(GoldenBlock); difficulty <= 3 {
+		t.Errorf("Expected difficulty to rise after 10 fast blocks, got %d", difficulty)
+	}
+
+	// Mainnet waits for the full 2016 block interval
+	mainnet := NewBlockchain()
+	mainnet.GoldenBlocks = bc.GoldenBlocks
+	if difficulty := mainnet.CalculateDifficulty(GoldenBlock); difficulty != DefaultGenesisDifficulty {
+		t.Errorf("Expected mainnet not to retarget after 10 blocks, got difficulty %d", difficulty)
+	}
+}
+
+func TestRetargetEnforcedOnConnect(t *testing.T) {
+	bc := NewBlockchain()
+	if err := bc.SetNetworkMode(Testnet); err != nil {
+		t.Fatalf("SetNetworkMode failed: %v", err)
+	}
+	params := bc.ConsensusParams()
+	params.AllowPinnedDifficulty = false
+	bc.SetConsensusParams(params)
+	// Blocks a second apart against a five second target double the difficulty
+	if err := bc.SetTargetBlockTime(GoldenBlock, 5*time.Second); err != nil {
+		t.Fatalf("SetTargetBlockTime failed: %v", err)
+	}
+	for i := 0; i < TestnetRetargetInterval; i++ {
+		block := buildTestBlock(bc, bc.GoldenBlocks[len(bc.GoldenBlocks)-1], GoldenBlock, fmt.Sprintf("retarget-%d", i), 1)
+		if err := bc.AddBlock(block); err != nil {
+			t.Fatalf("AddBlock %d failed: %v", i, err)
+		}
+	}
+	if got := bc.CalculateDifficulty(GoldenBlock); got != 2 {
+		t.Fatalf("Expected the golden chain to retarget to 2, got %d", got)
+	}
+	if got := bc.CalculateDifficulty(SilverBlock); got != 1 {
+		t.Errorf("Expected the silver chain to keep difficulty 1, got %d", got)
+	}
+
+	atDifficulty := func(difficulty int) Block {
+		block := buildTestBlock(bc, bc.GoldenBlocks[len(bc.GoldenBlocks)-1], GoldenBlock, "retarget-next", 1)
+		block.Difficulty = difficulty
+		block.Nonce = 0
+		for !bc.isValidProof(block) {
+			block.Nonce++
+		}
+		block.Hash = calculateHash(block)
+		return block
+	}
+	if err := bc.AddBlock(atDifficulty(1)); err == nil || !strings.Contains(err.Error(), "does not match the required 2") {
+		t.Errorf("Expected a block at the old difficulty to be rejected, got %v", err)
+	}
+	if err := bc.AddBlock(atDifficulty(2)); err != nil {
+		t.Errorf("Expected a block at the retargeted difficulty to be accepted: %v", err)
+	}
+
+	// Test networks may pin the difficulty, but not below the mining minimum
+	params.AllowPinnedDifficulty = true
+	bc.SetConsensusParams(params)
+	if err := bc.AddBlock(atDifficulty(1)); err != nil {
+		t.Errorf("Expected a pinned difficulty block to be accepted: %v", err)
+	}
+	if err := bc.AddBlock(atDifficulty(0)); err == nil {
+		t.Error("Expected a block below the minimum difficulty to be rejected")
+	}
+
+	// A block template is built at the difficulty the chain requires
+	template, err := bc.GetBlockTemplate(GoldenBlock, Leah, "retarget-miner")
+	if err != nil {
+		t.Fatalf("GetBlockTemplate failed: %v", err)
+	}
+	if template.Difficulty != 2 {
+		t.Errorf("Expected a template at difficulty 2, got %d", template.Difficulty)
+	}
+}
+
+func TestMedianTimePastBoundsBlockTimestamps(t *testing.T) {
+	bc := NewBlockchain()
+	for i := 0; i < MedianTimeSpan; i++ {
+		mineTestBlock(t, bc, GoldenBlock, "miner")
+	}
+	// The last 11 blocks are 60 seconds apart, so the median is the sixth
+	tip := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+	medianTime := tip.Timestamp - 5*60
+	if got := bc.MedianTimePast(GoldenBlock); got != medianTime {
+		t.Fatalf("Expected median time past %d, got %d", medianTime, got)
+	}
+
+	// A block behind its parent is accepted as long as it is after the median
+	backdated := buildTestBlock(bc, tip, GoldenBlock, "miner", medianTime+1-tip.Timestamp)
+	if err := bc.validateBlock(backdated); err != nil {
+		t.Errorf("Expected a block after the median time past to be accepted: %v", err)
+	}
+
+	// One timestamped at the median is rejected
+	atMedian := buildTestBlock(bc, tip, GoldenBlock, "miner", medianTime-tip.Timestamp)
+	if err := bc.validateBlock(atMedian); err == nil || !strings.Contains(err.Error(), "median time past") {
+		t.Errorf("Expected a block at the median time past to be rejected, got %v", err)
+	}
+
+	// A manipulated tip timestamped far back no longer drags the lower bound
+	// down: a child after it but before the median is still rejected
+	manipulated := buildTestBlock(bc, tip, GoldenBlock, "miner", 60)
+	manipulated.Timestamp = bc.GoldenBlocks[1].Timestamp
+	bc.GoldenBlocks = append(bc.GoldenBlocks, manipulated)
+	child := buildTestBlock(bc, manipulated, GoldenBlock, "miner", 60)
+	if child.Timestamp >= bc.MedianTimePast(GoldenBlock) {
+		t.Fatalf("Test child timestamp %d is not before the median time past", child.Timestamp)
+	}
+	if err := bc.validateBlock(child); err == nil || !strings.Contains(err.Error(), "median time past") {
+		t.Errorf("Expected a block before the median time past to be rejected, got %v", err)
+	}
+}
+
+// TestFastBlockBurst tests that blocks mined faster than one a second are
+// accepted until the median time past runs MaxFutureBlockTime ahead of the
+// clock, and that the template then refuses rather than build a block
+// validation would reject
+func TestFastBlockBurst(t *testing.T) {
+	bc := NewBlockchain()
+	for mined := 0; ; mined++ {
+		template, err := bc.GetBlockTemplate(GoldenBlock, Leah, "burst-miner")
+		if errors.Is(err, ErrTimestampAhead) {
+			if ahead := bc.MedianTimePast(GoldenBlock) + 1 - time.Now().Unix(); ahead <= MaxFutureBlockTime {
+				t.Errorf("Expected the template to refuse only past %ds ahead of the clock, refused at %ds", MaxFutureBlockTime, ahead)
+			}
+			if mined <= MaxFutureBlockTime {
+				t.Errorf("Expected more than %d blocks in the burst, got %d", MaxFutureBlockTime, mined)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("GetBlockTemplate failed after %d blocks: %v", mined, err)
+		}
+		if mined > 100*MaxFutureBlockTime {
+			t.Fatalf("Expected the template to refuse within %d blocks", mined)
+		}
+
+		block, err := bc.MineTemplate(template)
+		if err != nil {
+			t.Fatalf("MineTemplate failed after %d blocks: %v", mined, err)
+		}
+		if err := bc.AddBlock(block); err != nil {
+			t.Fatalf("Block %d of the burst was refused: %v", mined, err)
+		}
+	}
+}
+
+func TestStatsConcurrentMining(t *testing.T) {
+	logger.Init()
+	bc := NewBlockchain()
+	genesisSupply := bc.Stats().TotalSupply[Leah]
+
+	// Each batch mines a block on both chains, so a consistent snapshot
+	// always sees chains of equal length whose coinbases add up to the supply
+	const rounds = 20
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < rounds; i++ {
+			golden := buildTestBlock(bc, bc.GoldenBlocks[len(bc.GoldenBlocks)-1], GoldenBlock, "golden-miner", 1)
+			silver := buildTestBlock(bc, bc.SilverBlocks[len(bc.SilverBlocks)-1], SilverBlock, "silver-miner", 1)
+			if err := bc.AddBlockBatch([]Block{golden, silver}); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	check := func(stats ChainStats) {
+		t.Helper()
+		if stats.GoldenBlocks != stats.SilverBlocks {
+			t.Fatalf("Stats saw %d golden and %d silver blocks", stats.GoldenBlocks, stats.SilverBlocks)
+		}
+		if stats.Height != int64(stats.GoldenBlocks+stats.SilverBlocks) {
+			t.Fatalf("Stats height %d does not match %d blocks", stats.Height, stats.GoldenBlocks+stats.SilverBlocks)
+		}
+		mined := stats.GoldenBlocks + stats.SilverBlocks - 2
+		if want := genesisSupply + float64(50*mined); stats.TotalSupply[Leah] != want {
+			t.Fatalf("Stats supply %v does not match %d mined blocks, want %v", stats.TotalSupply[Leah], mined, want)
+		}
+	}
+
+	for reading := true; reading; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("AddBlockBatch failed: %v", err)
+			}
+			reading = false
+		default:
+		}
+		check(bc.Stats())
+	}
+
+	stats := bc.Stats()
+	check(stats)
+	golden, silver := bc.Tip()
+	if stats.GoldenTip != hex.EncodeToString(golden.Hash) || stats.SilverTip != hex.EncodeToString(silver.Hash) {
+		t.Errorf("Stats tips %s and %s do not match the chain tips", stats.GoldenTip, stats.SilverTip)
+	}
+	if stats.GoldenBlocks != rounds+1 {
+		t.Errorf("Expected %d golden blocks, got %d", rounds+1, stats.GoldenBlocks)
+	}
+}
+
+func TestCloseReleasesResources(t *testing.T) {
+	logger.Init()
+	bc := NewBlockchain()
+	baseline := runtime.NumGoroutine()
+
+	// A pool miner that will never find a block and a subscriber draining events
+	miner := NewMiner(MiningConfig{NumWorkers: 2, TargetDifficulty: big.NewInt(0), MaxNonce: math.MaxUint64})
+	bc.MiningPool.Miners["busy"] = miner
+	miner.Start(&Block{BlockType: GoldenBlock})
+
+	events, unsubscribe := bc.SubscribeBlocks()
+	drained := make(chan struct{})
+	go func() {
+		for range events {
+		}
+		close(drained)
+	}()
+
+	if err := bc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := bc.Close(); err != nil {
+		t.Fatalf("Second Close failed: %v", err)
+	}
+	unsubscribe()
+
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close did not end the block subscription")
+	}
+	if miner.ActiveWorkers() != 0 {
+		t.Errorf("Expected Close to stop the pool's miners, %d workers still running", miner.ActiveWorkers())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > baseline {
+		t.Errorf("Expected goroutines to return to %d after Close, got %d", baseline, n)
+	}
+}
+
+func TestSupplyCapRejectsOverIssuance(t *testing.T) {
+	logger.Init()
+	bc := NewBlockchain()
+
+	// Cap Leah at what genesis issued plus two block rewards
+	params := DefaultConsensusParams()
+	params.MaxSupply[Leah] = bc.GetTotalSupply(Leah) + 100
+	bc.SetConsensusParams(params)
+
+	mineTestBlock(t, bc, GoldenBlock, "miner")
+	last := mineTestBlock(t, bc, GoldenBlock, "miner")
+	if supply := bc.GetTotalSupply(Leah); supply != params.MaxSupply[Leah] {
+		t.Fatalf("Expected supply at the cap %v, got %v", params.MaxSupply[Leah], supply)
+	}
+
+	over := buildTestBlock(bc, last, GoldenBlock, "miner", 60)
+	err := bc.AddBlock(over)
+	if err == nil || !strings.Contains(err.Error(), "past its cap") {
+		t.Fatalf("Expected a coinbase past the supply cap to be rejected, got %v", err)
+	}
+	if len(bc.GoldenBlocks) != 3 {
+		t.Errorf("Expected the over-issuing block not to be added, chain has %d blocks", len(bc.GoldenBlocks))
+	}
+}
+
+func TestWaitForConfirmation(t *testing.T) {
+	logger.Init()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	defer bc.Close()
+	spend := signedTestSpend(t, key, fundTestKey(t, bc, key, "confirm-funding", 10), 9)
+
+	expect := func(ch <-chan int, want int) {
+		t.Helper()
+		select {
+		case got := <-ch:
+			if got != want {
+				t.Errorf("Expected notification at %d confirmations, got %d", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected notification at %d confirmations", want)
+		}
+	}
+	expectNone := func(ch <-chan int) {
+		t.Helper()
+		select {
+		case got := <-ch:
+			t.Fatalf("Expected no notification yet, got %d", got)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	confirmed := bc.WaitForConfirmation(context.Background(), spend.ID, 3)
+	fork := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+	mineTestBlockWith(t, bc, GoldenBlock, "miner-0", spend)
+	mineTestBlock(t, bc, GoldenBlock, "miner-1")
+	expectNone(confirmed)
+	mineTestBlock(t, bc, GoldenBlock, "miner-2")
+	expect(confirmed, 3)
+	if _, open := <-confirmed; open {
+		t.Error("Expected the channel to close after notifying")
+	}
+
+	// A reorg dropping the transaction resets the wait, even though the
+	// chain grows past the target
+	reconfirmed := bc.WaitForConfirmation(context.Background(), spend.ID, 2)
+	if err := bc.Reorganize(GoldenBlock, len(bc.GoldenBlocks)-4, forkTestBranch(bc, fork, GoldenBlock, 4)); err != nil {
+		t.Fatalf("Reorganize failed: %v", err)
+	}
+	expectNone(reconfirmed)
+	mineTestBlockWith(t, bc, GoldenBlock, "miner-3", spend)
+	expectNone(reconfirmed)
+	mineTestBlock(t, bc, GoldenBlock, "miner-4")
+	expect(reconfirmed, 2)
+
+	// Cancelling the context abandons the wait
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := bc.WaitForConfirmation(ctx, []byte("never-mined"), 1)
+	cancel()
+	if _, open := <-abandoned; open {
+		t.Error("Expected the channel to close without a value when the context ends")
+	}
+
+	// Closing the chain ends outstanding waits
+	pending := bc.WaitForConfirmation(context.Background(), []byte("never-mined"), 1)
+	bc.Close()
+	if _, open := <-pending; open {
+		t.Error("Expected the channel to close without a value when the chain closes")
+	}
+}
+
+func TestPoWMetricsSnapshotAndReset(t *testing.T) {
+	logger.Init()
+	bc := NewBlockchain()
+	metrics := bc.PoWMetrics()
+
+	mined, err := bc.MineBlock(nil, GoldenBlock, Leah)
+	if err != nil {
+		t.Fatalf("MineBlock failed: %v", err)
+	}
+	if got := metrics.Snapshot().Attempts; got != mined.Nonce+1 {
+		t.Errorf("Expected %d attempts, got %d", mined.Nonce+1, got)
+	}
+
+	block := buildTestBlock(bc, bc.GoldenBlocks[0], GoldenBlock, "metrics-miner", 60)
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+	invalid := buildTestBlock(bc, block, GoldenBlock, "metrics-miner", 60)
+	for bc.isValidProof(invalid) {
+		invalid.Nonce++
+	}
+	if err := bc.AddBlock(invalid); err == nil {
+		t.Fatal("Expected a block without proof of work to be rejected")
+	}
+
+	snapshot := metrics.Snapshot()
+	if snapshot.Validations < 2 {
+		t.Errorf("Expected both blocks' proofs to be checked, got %d validations", snapshot.Validations)
+	}
+	if snapshot.InvalidProofs != 1 {
+		t.Errorf("Expected 1 invalid proof, got %d", snapshot.InvalidProofs)
+	}
+
+	metrics.Reset()
+	if got := metrics.Snapshot(); got != (PoWMetricsSnapshot{}) {
+		t.Errorf("Expected zero counters after reset, got %+v", got)
+	}
+	// The snapshot taken before the reset is unaffected
+	if snapshot.Attempts != mined.Nonce+1 {
+		t.Errorf("Expected the earlier snapshot to keep %d attempts, got %d", mined.Nonce+1, snapshot.Attempts)
+	}
+}
+
+func TestMineBlockTimeout(t *testing.T) {
+	bc := NewBlockchain()
+	bc.MiningConfig.MiningTimeout = 20 * time.Millisecond
+	// No hash has 30 leading zero bytes
+	bc.Difficulty = 30
+
+	start := time.Now()
+	_, err := bc.MineBlock(nil, GoldenBlock, Leah)
+	if !errors.Is(err, ErrMiningTimeout) {
+		t.Fatalf("Expected ErrMiningTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected mining to give up promptly, took %s", elapsed)
+	}
+	if bc.PoWMetrics().Snapshot().Attempts == 0 {
+		t.Error("Expected the abandoned attempt's hashes to be counted")
+	}
+}
+
+func TestBlockTemplateSubmit(t *testing.T) {
+	logger.Init()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	mineTestBlock(t, bc, GoldenBlock, "miner")
+	spend := signedTestSpend(t, key, fundTestKey(t, bc, key, "template-funding", 10), 9)
+	if err := bc.AddTransaction(spend); err != nil {
+		t.Fatalf("AddTransaction failed: %v", err)
+	}
+
+	template, err := bc.GetBlockTemplate(GoldenBlock, Leah, "external-miner")
+	if err != nil {
+		t.Fatalf("GetBlockTemplate failed: %v", err)
+	}
+	if template.Height != 2 || !bytes.Equal(template.PrevHash, bc.GoldenBlocks[1].Hash) {
+		t.Errorf("Expected a template at height 2 on the tip, got height %d on %x", template.Height, template.PrevHash)
+	}
+	if len(template.Transactions) != 1 || !bytes.Equal(template.Transactions[0].ID, spend.ID) {
+		t.Fatalf("Expected the pending spend to be selected, got %d transactions", len(template.Transactions))
+	}
+	if template.Coinbase.Outputs[0].Value != template.CoinbaseValue || template.CoinbaseValue != BlockSubsidy(Leah) {
+		t.Errorf("Expected the coinbase to pay the Leah subsidy, got %v", template.Coinbase.Outputs[0].Value)
+	}
+
+	// The miner works from the serialized template alone
+	data, err := json.Marshal(template)
+	if err != nil {
+		t.Fatalf("Failed to encode template: %v", err)
+	}
+	var received BlockTemplate
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatalf("Failed to decode template: %v", err)
+	}
+	if root := MerkleRootFromBranch(received.Coinbase.ID, received.MerkleBranch); !bytes.Equal(root, received.MerkleRoot) {
+		t.Fatalf("Expected the Merkle branch to reproduce root %x, got %x", received.MerkleRoot, root)
+	}
+	var block Block
+	for nonce := uint64(0); ; nonce++ {
+		if block = received.Assemble(nonce); bytes.Compare(block.Hash, received.Target) <= 0 {
+			break
+		}
+	}
+	serialized, err := json.Marshal(block)
+	if err != nil {
+		t.Fatalf("Failed to encode block: %v", err)
+	}
+
+	tampered := block
+	tampered.Nonce++
+	tamperedData, _ := json.Marshal(tampered)
+	if err := bc.SubmitBlock(tamperedData); err == nil {
+		t.Error("Expected a block whose hash does not match its header to be rejected")
+	}
+
+	if err := bc.SubmitBlock(serialized); err != nil {
+		t.Fatalf("SubmitBlock failed: %v", err)
+	}
+	if tip := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]; !bytes.Equal(tip.Hash, block.Hash) {
+		t.Error("Expected the submitted block to be the new tip")
+	}
+	if len(bc.GetPendingTransactions()) != 0 {
+		t.Error("Expected the mined spend to leave the mempool")
+	}
+	if err := bc.SubmitBlock(serialized); err == nil {
+		t.Error("Expected a block on a stale tip to be rejected")
+	}
+}
+
+func TestAddTransactionDetailed(t *testing.T) {
+	logger.Init()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	// An accepted transaction reports its ID and fee
+	bc := NewBlockchain()
+	mineTestBlock(t, bc, GoldenBlock, "miner")
+	tx := signedTestSpend(t, key, fundTestKey(t, bc, key, "accepted-funding", 10), 9)
+	accepted, err := bc.AddTransactionDetailed(tx)
+	if err != nil {
+		t.Fatalf("AddTransactionDetailed failed: %v", err)
+	}
+	if !bytes.Equal(accepted.TxID, tx.ID) || accepted.Fee != 1 {
+		t.Errorf("Expected txid %x with fee 1, got %x with fee %v", tx.ID, accepted.TxID, accepted.Fee)
+	}
+	if accepted.FeeRate != 1/float64(tx.VirtualSize()) || len(accepted.Replaced) != 0 {
+		t.Errorf("Unexpected acceptance detail %+v", accepted)
+	}
+
+	// A replacement reports what it evicted
+	replaceable := func(tx Transaction) Transaction {
+		tx.Replaceable = true
+		tx.ID = tx.CalculateHash()
+		if err := tx.Sign(key.D.Bytes()); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		return tx
+	}
+	funding := fundTestKey(t, bc, key, "rbf-funding", 10)
+	original := replaceable(signedTestSpend(t, key, funding, 9))
+	if err := bc.AddTransaction(original); err != nil {
+		t.Fatalf("AddTransaction failed: %v", err)
+	}
+	bumped, err := bc.AddTransactionDetailed(replaceable(signedTestSpend(t, key, funding, 8)))
+	if err != nil {
+		t.Fatalf("Expected the higher-fee replacement to be accepted: %v", err)
+	}
+	if len(bumped.Replaced) != 1 || !bytes.Equal(bumped.Replaced[0], original.ID) {
+		t.Errorf("Expected the original to be reported replaced, got %x", bumped.Replaced)
+	}
+
+	// A replacement evicts the descendants of what it replaces, so it must
+	// outbid them too
+	funding = fundTestKey(t, bc, key, "rbf-descendant-funding", 10)
+	parent := replaceable(signedTestChild(t, key, *funding, 0, 9))
+	child := signedTestChild(t, key, parent, 0, 8)
+	for _, tx := range []Transaction{parent, child} {
+		if err := bc.AddTransaction(tx); err != nil {
+			t.Fatalf("AddTransaction failed: %v", err)
+		}
+	}
+	var rejected *RejectError
+	_, err = bc.AddTransactionDetailed(replaceable(signedTestChild(t, key, *funding, 0, 8.5)))
+	if !errors.As(err, &rejected) || rejected.Reason != RejectReplacementFee {
+		t.Errorf("Expected a replacement outbidding only the parent to be rejected, got %v", err)
+	}
+	bumped, err = bc.AddTransactionDetailed(replaceable(signedTestChild(t, key, *funding, 0, 7.5)))
+	if err != nil {
+		t.Fatalf("Expected a replacement outbidding the parent and child to be accepted: %v", err)
+	}
+	if len(bumped.Replaced) != 2 || !bytes.Equal(bumped.Replaced[0], parent.ID) || !bytes.Equal(bumped.Replaced[1], child.ID) {
+		t.Errorf("Expected the parent and child to be reported replaced, got %x", bumped.Replaced)
+	}
+	for _, ptx := range bc.GetPendingTransactions() {
+		if bytes.Equal(ptx.ID, child.ID) {
+			t.Error("Expected the child of the replaced transaction to leave the pending pool")
+		}
+	}
+
+	tests := []struct {
+		name string
+		want RejectReason
+		// setup returns the transaction to submit to a fresh chain
+		setup func(bc *Blockchain) Transaction
+	}{
+		{"coinbase", RejectCoinbase, func(bc *Blockchain) Transaction {
+			return *NewCoinbaseTransaction("miner", nil, 1, Leah, GoldenBlock)
+		}},
+		{"no inputs", RejectMalformed, func(bc *Blockchain) Transaction {
+			return Transaction{ID: []byte("empty"), Outputs: []TxOutput{{Value: 1, CoinType: Leah, Address: "a"}}}
+		}},
+		{"oversize", RejectOversize, func(bc *Blockchain) Transaction {
+			params := bc.ConsensusParams()
+			params.MaxGoldenBlockSize, params.MaxSilverBlockSize = 10, 10
+			bc.SetConsensusParams(params)
+			return signedTestSpend(t, key, fundTestKey(t, bc, key, "funding", 10), 9)
+		}},
+		{"bad signature", RejectInvalid, func(bc *Blockchain) Transaction {
+			tx := signedTestSpend(t, key, fundTestKey(t, bc, key, "funding", 10), 9)
+			tx.Outputs[0].Value = 5
+			tx.RecomputeID()
+			return tx
+		}},
+		{"mismatched id", RejectMalformed, func(bc *Blockchain) Transaction {
+			tx := signedTestSpend(t, key, fundTestKey(t, bc, key, "funding", 10), 9)
+			tx.Outputs[0].Value = 5
+			return tx
+		}},
+		{"immature", RejectImmature, func(bc *Blockchain) Transaction {
+			params := bc.ConsensusParams()
+			params.MinConfirmations = 10
+			bc.SetConsensusParams(params)
+			return signedTestSpend(t, key, fundTestKey(t, bc, key, "funding", 10), 9)
+		}},
+		{"ancestor limit", RejectPackageLimit, func(bc *Blockchain) Transaction {
+			bc.MempoolConfig.MaxAncestorCount = 1
+			parent := signedTestChild(t, key, *fundTestKey(t, bc, key, "funding", 10), 0, 9)
+			if err := bc.AddTransaction(parent); err != nil {
+				t.Fatalf("AddTransaction failed: %v", err)
+			}
+			return signedTestChild(t, key, parent, 0, 8)
+		}},
+		{"low fee", RejectFee, func(bc *Blockchain) Transaction {
+			return signedTestSpend(t, key, fundTestKey(t, bc, key, "funding", 10), 10)
+		}},
+		{"duplicate", RejectDuplicate, func(bc *Blockchain) Transaction {
+			tx := signedTestSpend(t, key, fundTestKey(t, bc, key, "funding", 10), 9)
+			if err := bc.AddTransaction(tx); err != nil {
+				t.Fatalf("AddTransaction failed: %v", err)
+			}
+			return tx
+		}},
+		{"double spend", RejectDoubleSpend, func(bc *Blockchain) Transaction {
+			funding := fundTestKey(t, bc, key, "funding", 10)
+			if err := bc.AddTransaction(signedTestSpend(t, key, funding, 9)); err != nil {
+				t.Fatalf("AddTransaction failed: %v", err)
+			}
+			return signedTestSpend(t, key, funding, 8)
+		}},
+		{"replacement fee", RejectReplacementFee, func(bc *Blockchain) Transaction {
+			funding := fundTestKey(t, bc, key, "funding", 10)
+			if err := bc.AddTransaction(replaceable(signedTestSpend(t, key, funding, 8))); err != nil {
+				t.Fatalf("AddTransaction failed: %v", err)
+			}
+			return replaceable(signedTestSpend(t, key, funding, 9))
+		}},
+	}
+	for _, tt := range tests {
+		bc := NewBlockchain()
+		mineTestBlock(t, bc, GoldenBlock, "miner")
+		acceptance, err := bc.AddTransactionDetailed(tt.setup(bc))
+		var rejectErr *RejectError
+		if !errors.As(err, &rejectErr) {
+			t.Errorf("%s: expected a RejectError, got %v", tt.name, err)
+			continue
+		}
+		if rejectErr.Reason != tt.want {
+			t.Errorf("%s: expected reason %s, got %s (%v)", tt.name, tt.want, rejectErr.Reason, err)
+		}
+		if acceptance != nil {
+			t.Errorf("%s: expected no acceptance detail for a rejected transaction", tt.name)
+		}
+	}
+}
+
+func TestFindUTXOsForAmount(t *testing.T) {
+	bc := NewBlockchain()
+	funding := &Transaction{ID: []byte("select-funding")}
+	for _, value := range []float64{3, 4, 5} {
+		funding.Outputs = append(funding.Outputs, TxOutput{Value: value, CoinType: Leah, Address: "selector"})
+	}
+	if err := bc.UTXOSet.UpdateWithTransaction(funding); err != nil {
+		t.Fatalf("Failed to fund address: %v", err)
+	}
+
+	utxos, change, err := bc.FindUTXOsForAmount("selector", Leah, 6)
+	if err != nil {
+		t.Fatalf("FindUTXOsForAmount failed: %v", err)
+	}
+	var total float64
+	for _, utxo := range utxos {
+		if utxo.Address != "selector" || utxo.CoinType != Leah {
+			t.Errorf("Selected foreign output %+v", utxo)
+		}
+		total += utxo.Amount
+	}
+	if total < 6 {
+		t.Errorf("Selected %f, want at least 6", total)
+	}
+	if change != total-6 {
+		t.Errorf("Change = %f, want %f", change, total-6)
+	}
+
+	// Everything the address holds is exactly enough
+	if _, change, err := bc.FindUTXOsForAmount("selector", Leah, 12); err != nil || change != 0 {
+		t.Errorf("FindUTXOsForAmount(12) = change %f, %v; want 0, nil", change, err)
+	}
+
+	if _, _, err := bc.FindUTXOsForAmount("selector", Leah, 13); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("FindUTXOsForAmount(13) error = %v, want ErrInsufficientFunds", err)
+	}
+	if _, _, err := bc.FindUTXOsForAmount("selector", Shiblum, 1); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("FindUTXOsForAmount of another coin error = %v, want ErrInsufficientFunds", err)
+	}
+}
+
+func TestMempoolQueuesPerChain(t *testing.T) {
+	bc := NewBlockchain()
+	bc.MempoolConfig.MaxQueueSize = 3
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	// spend funds key with value of coinType and returns a signed spend of it
+	// paying fee
+	spend := func(id string, coinType CoinType, fee float64) Transaction {
+		funding := &Transaction{
+			ID: []byte(id),
+			Outputs: []TxOutput{{
+				Value:         10,
+				CoinType:      coinType,
+				PublicKeyHash: crypto.HashPublicKey(&key.PublicKey),
+				Address:       id,
+			}},
+		}
+		if err := bc.UTXOSet.UpdateWithTransaction(funding); err != nil {
+			t.Fatalf("Failed to fund key: %v", err)
+		}
+		tx := Transaction{
+			Inputs: []TxInput{{
+				TxID:      funding.ID,
+				Amount:    10,
+				PublicKey: crypto.PublicKeyToBytes(&key.PublicKey),
+			}},
+			Outputs: []TxOutput{{
+				Value:         10 - fee,
+				CoinType:      coinType,
+				PublicKeyHash: []byte("recipient"),
+				Address:       "recipient",
+			}},
+			Timestamp: time.Now(),
+			Nonce:     NewTxNonce(),
+		}
+		tx.ID = tx.CalculateHash()
+		if err := tx.Sign(key.D.Bytes()); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		return tx
+	}
+
+	// Fill the silver queue
+	for i := 0; i < 3; i++ {
+		if err := bc.AddTransaction(spend(fmt.Sprintf("silver-funding-%d", i), Senum, 1)); err != nil {
+			t.Fatalf("Failed to queue silver transaction %d: %v", i, err)
+		}
+	}
+	err = bc.AddTransaction(spend("silver-funding-full", Senum, 1))
+	var rejected *RejectError
+	if !errors.As(err, &rejected) || rejected.Reason != RejectMempoolFull {
+		t.Fatalf("Expected a full silver queue to reject with %q, got %v", RejectMempoolFull, err)
+	}
+
+	// A higher fee rate evicts the lowest from the full queue
+	silver := bc.PendingTransactions(SilverBlock)
+	lowest := silver[0]
+	for _, tx := range silver[1:] {
+		if tx.FeeRate() < lowest.FeeRate() {
+			lowest = tx
+		}
+	}
+	richer := spend("silver-funding-richer", Senum, 2)
+	acceptance, err := bc.AddTransactionDetailed(richer)
+	if err != nil {
+		t.Fatalf("Expected a higher fee rate to make room in the full queue, got %v", err)
+	}
+	if len(acceptance.Evicted) != 1 || !bytes.Equal(acceptance.Evicted[0], lowest.ID) {
+		t.Errorf("Evicted %x, want only the lowest fee rate %x", acceptance.Evicted, lowest.ID)
+	}
+
+	// The golden queue is unaffected
+	golden := spend("golden-funding", Leah, 1)
+	if err := bc.AddTransaction(golden); err != nil {
+		t.Fatalf("Failed to queue golden transaction: %v", err)
+	}
+	if n := len(bc.PendingTransactions(GoldenBlock)); n != 1 {
+		t.Errorf("Golden queue holds %d transactions, want 1", n)
+	}
+	if n := len(bc.PendingTransactions(SilverBlock)); n != 3 {
+		t.Errorf("Silver queue holds %d transactions, want 3", n)
+	}
+
+	template, err := bc.GetBlockTemplate(GoldenBlock, Leah, "miner")
+	if err != nil {
+		t.Fatalf("GetBlockTemplate failed: %v", err)
+	}
+	if len(template.Transactions) != 1 || !bytes.Equal(template.Transactions[0].ID, golden.ID) {
+		t.Fatalf("Golden template selected %d transactions, want only the golden one", len(template.Transactions))
+	}
+	for _, tx := range bc.SelectTransactions(SilverBlock, *NewCoinbaseTransaction("miner", nil, 1, Senum, SilverBlock))[1:] {
+		if tx.Chain() != SilverBlock {
+			t.Errorf("Silver selection included %x from the %s queue", tx.ID, tx.Chain())
+		}
+	}
+}
+
+func TestTransactionFeeRate(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	funding := fundTestKey(t, bc, key, "fee-rate-funding", 10)
+
+	// padded spends funding in many outputs, so it is large for its fee
+	padded := func(outputs int, fee float64) Transaction {
+		tx := signedTestSpend(t, key, funding, 0)
+		tx.Outputs = nil
+		for i := 0; i < outputs; i++ {
+			tx.Outputs = append(tx.Outputs, TxOutput{
+				Value:         (10 - fee) / float64(outputs),
+				CoinType:      Leah,
+				PublicKeyHash: []byte(fmt.Sprintf("recipient-%d", i)),
+				Address:       fmt.Sprintf("recipient-%d", i),
+			})
+		}
+		tx.Replaceable = true
+		tx.ID = tx.CalculateHash()
+		if err := tx.Sign(key.D.Bytes()); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		return tx
+	}
+
+	for _, tx := range []Transaction{
+		signedTestSpend(t, key, funding, 9),
+		signedTestSpend(t, key, funding, 5),
+		signedTestSpend(t, key, funding, 9.99),
+		padded(10, 1),
+		{Outputs: []TxOutput{{Value: 1, CoinType: Leah}}},
+	} {
+		want := tx.GetFee() / float64(tx.VirtualSize())
+		if got := tx.FeeRate(); got != want {
+			t.Errorf("FeeRate of %x = %v, want %v", tx.ID, got, want)
+		}
+	}
+
+	// A replacement paying more in total but less per byte is an inversion
+	original := padded(1, 1)
+	if err := bc.AddTransaction(original); err != nil {
+		t.Fatalf("AddTransaction failed: %v", err)
+	}
+	inverted := padded(20, 1.1)
+	if inverted.GetFee() <= original.GetFee() || inverted.FeeRate() >= original.FeeRate() {
+		t.Fatalf("Test replacement must pay a higher fee at a lower rate")
+	}
+	err = bc.AddTransaction(inverted)
+	var rejected *RejectError
+	if !errors.As(err, &rejected) || rejected.Reason != RejectReplacementFee {
+		t.Errorf("Expected a lower fee rate replacement to be rejected for %q, got %v", RejectReplacementFee, err)
+	}
+
+	// A nonzero fee spread below the minimum rate is refused
+	thin := padded(20, 20*bc.ConsensusParams().MinRelayFeeRate)
+	if thin.GetFee() <= 0 || thin.FeeRate() >= bc.ConsensusParams().MinRelayFeeRate {
+		t.Fatalf("Test transaction must pay a nonzero fee below the minimum rate")
+	}
+	if err := bc.CheckTransaction(thin); !errors.Is(err, ErrFeeTooLow) {
+		t.Errorf("Expected ErrFeeTooLow for a nonzero fee below the minimum rate, got %v", err)
+	}
+}
+
+func TestTransactionIDMatchesContent(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	tx := signedTestSpend(t, key, fundTestKey(t, bc, key, "id-funding", 10), 9)
+
+	// Signing does not change the ID
+	if err := tx.CheckID(); err != nil {
+		t.Fatalf("Signed transaction has a mismatched ID: %v", err)
+	}
+	id := append([]byte(nil), tx.ID...)
+	tx.RecomputeID()
+	if !bytes.Equal(tx.ID, id) {
+		t.Errorf("RecomputeID changed the ID of an unmodified transaction")
+	}
+
+	// Changing an output without recomputing the ID is caught
+	tampered := tx
+	tampered.Outputs = append([]TxOutput(nil), tx.Outputs...)
+	tampered.Outputs[0].Value = 8
+	if err := tampered.CheckID(); err == nil {
+		t.Error("Expected a mismatched ID after changing an output")
+	}
+	if err := bc.CheckTransaction(tampered); err == nil {
+		t.Error("Expected the pending pool to refuse a transaction with a mismatched ID")
+	}
+	tampered.RecomputeID()
+	if err := tampered.CheckID(); err != nil {
+		t.Errorf("RecomputeID left a mismatched ID: %v", err)
+	}
+
+	for _, tx := range []*Transaction{
+		NewTransaction("from", "to", 1, Leah, nil, []TxOutput{{Value: 1, CoinType: Leah}}),
+		NewCoinbaseTransaction("miner", nil, 1, Leah, GoldenBlock),
+	} {
+		if err := tx.CheckID(); err != nil {
+			t.Errorf("Constructor left a mismatched ID: %v", err)
+		}
+	}
+
+	// Blocks are held to the same rule, even when the Merkle root commits to
+	// the mismatched IDs
+	block := buildTestBlock(bc, bc.GoldenBlocks[len(bc.GoldenBlocks)-1], GoldenBlock, "id-miner", 60)
+	block.Transactions[0].Outputs[0].Value = 40
+	block.MerkleRoot = MerkleRoot(block.Transactions)
+	block.Nonce = 0
+	for !bc.isValidProof(block) {
+		block.Nonce++
+	}
+	block.Hash = calculateHash(block)
+	if err := bc.AddBlock(block); err == nil || !strings.Contains(err.Error(), "does not match its hash") {
+		t.Errorf("Expected a block holding a mismatched transaction ID to be rejected, got %v", err)
+	}
+}
+
+func TestReorganizeDropsSupersededTransactions(t *testing.T) {
+	logger.Init()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	funding := fundTestKey(t, bc, key, "reorg-funding", 10)
+	superseded := signedTestChild(t, key, *funding, 0, 9)
+	winner := signedTestChild(t, key, *funding, 0, 8)
+	kept := signedTestSpend(t, key, fundTestKey(t, bc, key, "reorg-other-funding", 10), 9)
+	child := signedTestChild(t, key, superseded, 0, 8)
+
+	fork := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+	forkHeight := len(bc.GoldenBlocks) - 1
+	mineTestBlockWith(t, bc, GoldenBlock, "miner", superseded, kept, child)
+
+	// The winning branch spends the same funding differently
+	first := buildTestBlock(bc, fork, GoldenBlock, "fork-miner-0", 30, winner)
+	branch := []Block{first, buildTestBlock(bc, first, GoldenBlock, "fork-miner-1", 30)}
+	if err := bc.Reorganize(GoldenBlock, forkHeight, branch); err != nil {
+		t.Fatalf("Reorganize failed: %v", err)
+	}
+
+	pending := make(map[string]bool)
+	for _, tx := range bc.GetPendingTransactions() {
+		pending[string(tx.ID)] = true
+	}
+	if !pending[string(kept.ID)] {
+		t.Error("Expected the unconflicted transaction to return to the pending pool")
+	}
+	if pending[string(superseded.ID)] {
+		t.Error("Expected the transaction double spent by the new branch to be dropped")
+	}
+	if pending[string(child.ID)] {
+		t.Error("Expected the child of a dropped transaction to be dropped")
+	}
+	if pending[string(winner.ID)] {
+		t.Error("Expected the confirmed winner not to be pending")
+	}
+}
+
+// TestChainIndex tests that HasBlock and HasTransaction follow blocks as
+// they connect and as a reorganization disconnects them
+func TestChainIndex(t *testing.T) {
+	bc := NewBlockchain()
+	if !bc.HasBlock(bc.GoldenBlocks[0].Hash) || !bc.HasBlock(bc.SilverBlocks[0].Hash) {
+		t.Fatal("Expected the genesis blocks to be indexed")
+	}
+
+	fork := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+	forkHeight := len(bc.GoldenBlocks) - 1
+	old := mineTestBlock(t, bc, GoldenBlock, "miner")
+	if !bc.HasBlock(old.Hash) || !bc.HasTransaction(old.Transactions[0].ID) {
+		t.Fatal("Expected a connected block and its transactions to be indexed")
+	}
+	if bc.HasBlock([]byte("unknown")) || bc.HasTransaction([]byte("unknown")) {
+		t.Error("Expected unknown items not to be indexed")
+	}
+
+	first := buildTestBlock(bc, fork, GoldenBlock, "fork-miner-0", 30)
+	branch := []Block{first, buildTestBlock(bc, first, GoldenBlock, "fork-miner-1", 30)}
+	if err := bc.Reorganize(GoldenBlock, forkHeight, branch); err != nil {
+		t.Fatalf("Reorganize failed: %v", err)
+	}
+	if bc.HasBlock(old.Hash) || bc.HasTransaction(old.Transactions[0].ID) {
+		t.Error("Expected a disconnected block and its transactions to leave the index")
+	}
+	for _, b := range branch {
+		if !bc.HasBlock(b.Hash) || !bc.HasTransaction(b.Transactions[0].ID) {
+			t.Errorf("Expected block %x of the new branch to be indexed", b.Hash)
+		}
+	}
+}
+
+// TestIsSynced tests that a chain behind the height its peers advertise is
+// not synced until its tip catches up
+func TestIsSynced(t *testing.T) {
+	bc := NewBlockchain()
+	if !bc.IsSynced() {
+		t.Fatal("Expected a chain without peers to count as synced")
+	}
+
+	start := bc.GetCurrentHeight()
+	bc.SetPeerHeight("peer-a", start+2)
+	bc.SetPeerHeight("peer-b", start+3)
+
+	current, best, pct := bc.SyncProgress()
+	if current != start || best != start+3 {
+		t.Errorf("Expected progress %d of %d, got %d of %d", start, start+3, current, best)
+	}
+	if want := float64(start) / float64(start+3) * 100; pct != want {
+		t.Errorf("Expected %.2f%% synced, got %.2f%%", want, pct)
+	}
+
+	for i := 0; i < 2; i++ {
+		if bc.IsSynced() {
+			t.Fatalf("Expected chain at height %d to be behind peers at %d", bc.GetCurrentHeight(), best)
+		}
+		mineTestBlock(t, bc, GoldenBlock, "miner")
+	}
+	if !bc.IsSynced() {
+		t.Errorf("Expected chain within %d block of its best peer to be synced", MaxSyncLag)
+	}
+
+	mineTestBlock(t, bc, GoldenBlock, "miner")
+	if _, _, pct := bc.SyncProgress(); pct != 100 {
+		t.Errorf("Expected 100%% once caught up, got %.2f%%", pct)
+	}
+
+	// A peer far ahead disconnecting no longer holds the chain back
+	bc.SetPeerHeight("peer-c", start+50)
+	if bc.IsSynced() {
+		t.Error("Expected a new peer far ahead to put the chain behind")
+	}
+	bc.RemovePeerHeight("peer-c")
+	if !bc.IsSynced() {
+		t.Error("Expected the chain to be synced after the peer ahead left")
+	}
+}
+
+// TestUnprovenPeerHeightExpires tests that a height a peer advertises but
+// never delivers blocks towards stops holding the chain back, and that
+// blocks from a peer back its claim
+func TestUnprovenPeerHeightExpires(t *testing.T) {
+	bc := NewBlockchain()
+	start := bc.GetCurrentHeight()
+
+	bc.SetPeerHeight("liar", start+1000)
+	if bc.IsSynced() {
+		t.Fatal("Expected a fresh claim far ahead to put the chain behind")
+	}
+
+	// Claiming the height again does not renew the grace period
+	age := func(peer string) {
+		bc.peerHeights.mu.Lock()
+		bc.peerHeights.heights[peer].claimedAt = time.Now().Add(-UnprovenHeightGrace - time.Second)
+		bc.peerHeights.mu.Unlock()
+	}
+	age("liar")
+	bc.SetPeerHeight("liar", start+1001)
+	if !bc.IsSynced() {
+		t.Error("Expected a claim no block backed to expire")
+	}
+
+	// A peer whose blocks connect keeps its claim while it delivers
+	bc.SetPeerHeight("honest", start+10)
+	age("honest")
+	mineTestBlock(t, bc, GoldenBlock, "miner")
+	bc.ConfirmPeerHeight("honest", bc.GetCurrentHeight())
+	if _, best, _ := bc.SyncProgress(); best != start+10 {
+		t.Errorf("Expected a peer delivering blocks to be believed at %d, got %d", start+10, best)
+	}
+
+	// Blocks from a peer prove it is at least as high as they took the chain
+	bc.ConfirmPeerHeight("relay", start+20)
+	age("relay")
+	if _, best, _ := bc.SyncProgress(); best != start+20 {
+		t.Errorf("Expected a proven height of %d, got %d", start+20, best)
+	}
+}
+
+func TestValidateChecksInputAmounts(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	funding := fundTestKey(t, bc, key, "amount-funding", 10)
+
+	spend := signedTestSpend(t, key, funding, 9)
+	if err := spend.Validate(bc.UTXOSet); err != nil {
+		t.Errorf("Expected a spend claiming the output's value to validate, got %v", err)
+	}
+
+	// Claiming more than the output holds would inflate the fee
+	forged := signedTestSpend(t, key, funding, 9)
+	forged.Inputs[0].Amount = 50
+	forged.Inputs[0].Signature = nil
+	forged.ID = forged.CalculateHash()
+	if err := forged.Sign(key.D.Bytes()); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	var validationErr *ValidationError
+	if err := forged.Validate(bc.UTXOSet); !errors.As(err, &validationErr) || validationErr.Field != "input[0].Amount" {
+		t.Errorf("Expected a forged input amount to be rejected, got %v", err)
+	}
+	if err := bc.CheckTransaction(forged); err == nil {
+		t.Error("Expected the pending pool to reject a forged input amount")
+	}
+}
+
+func TestChainStateCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	bc := NewBlockchain()
+	events, unsubscribe := bc.SubscribeBlocks()
+	defer unsubscribe()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bc.RunCheckpoints(dir, time.Hour, stop, func(err error) { t.Errorf("Checkpoint failed: %v", err) })
+	}()
+
+	// Each new tip is checkpointed without waiting for the interval
+	for i := 0; i < 3; i++ {
+		mineTestBlock(t, bc, GoldenBlock, fmt.Sprintf("checkpoint-miner-%d", i))
+		<-events
+	}
+	mineTestBlock(t, bc, SilverBlock, "checkpoint-silver-miner")
+	<-events
+	deadline := time.Now().Add(5 * time.Second)
+	want := hex.EncodeToString(bc.GetLatestBlock().Hash)
+	for {
+		if data, err := os.ReadFile(filepath.Join(dir, ChainStateFile)); err == nil && strings.Contains(string(data), want) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the new tip to be checkpointed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	<-done
+	saved := bc.GetCurrentHeight()
+
+	// Blocks mined after the last checkpoint are lost in a crash
+	lost := mineTestBlock(t, bc, GoldenBlock, "lost-miner")
+
+	recovered := NewBlockchain()
+	state, err := recovered.LoadChainState(dir)
+	if err != nil {
+		t.Fatalf("LoadChainState failed: %v", err)
+	}
+	if state.Height != saved || state.TipHash != want {
+		t.Errorf("Expected checkpoint at height %d tip %s, got height %d tip %s", saved, want, state.Height, state.TipHash)
+	}
+	if recovered.GetCurrentHeight() != saved {
+		t.Errorf("Expected the recovered chain at height %d, got %d", saved, recovered.GetCurrentHeight())
+	}
+	if latest := recovered.GetLatestBlock(); latest == nil || hex.EncodeToString(latest.Hash) != want {
+		t.Error("Expected the recovered chain to end at the checkpointed tip")
+	}
+	if _, err := recovered.GetBlock(lost.Hash); err == nil {
+		t.Error("Expected the block mined after the checkpoint to be lost")
+	}
+
+	// Without a checkpoint there is nothing to recover
+	state, err = NewBlockchain().LoadChainState(t.TempDir())
+	if err != nil || state.Height != 0 {
+		t.Errorf("Expected an empty state without a checkpoint, got %+v, %v", state, err)
+	}
+}
+
+func TestGetTopHolders(t *testing.T) {
+	bc := NewBlockchain()
+	fund := func(id, address string, amount float64, coinType CoinType, spent bool) {
+		bc.UTXOSet.Add(UTXO{TxID: id, Amount: amount, Address: address, CoinType: coinType, Spent: spent})
+	}
+	fund("a1", "alice", 30, Leah, false)
+	fund("a2", "alice", 20, Leah, false)
+	fund("b1", "bob", 40, Leah, false)
+	fund("c1", "carol", 50, Leah, false)
+	fund("d1", "dave", 50, Leah, false)
+	fund("e1", "erin", 100, Leah, true)
+	fund("e2", "erin", 5, Leah, false)
+	fund("f1", "frank", 500, Shiblum, false)
+
+	want := []AddressBalance{
+		{Address: "alice", Balance: 50},
+		{Address: "carol", Balance: 50},
+		{Address: "dave", Balance: 50},
+		{Address: "bob", Balance: 40},
+	}
+	got := bc.GetTopHolders(Leah, 4)
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d holders, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Holder %d = %+v; want %+v", i, got[i], want[i])
+		}
+	}
+
+	// Spent outputs and other coins do not count, and n larger than the
+	// number of holders returns them all
+	if all := bc.GetTopHolders(Leah, 10); len(all) != 5 || all[4] != (AddressBalance{Address: "erin", Balance: 5}) {
+		t.Errorf("Expected erin last with her unspent 5, got %+v", all)
+	}
+	if holders := bc.GetTopHolders(Shiblum, 10); len(holders) != 1 || holders[0].Address != "frank" {
+		t.Errorf("Expected only frank to hold Shiblum, got %+v", holders)
+	}
+	if holders := bc.GetTopHolders(Leah, 0); len(holders) != 0 {
+		t.Errorf("Expected no holders for n = 0, got %+v", holders)
+	}
+}
+
+func TestDescribeTransaction(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	tx := signedTestSpend(t, key, fundTestKey(t, bc, key, "describe-funding", 10), 9.5)
+
+	raw, err := EncodeRawTransaction(&tx)
+	if err != nil {
+		t.Fatalf("EncodeRawTransaction failed: %v", err)
+	}
+	decodedTx, err := DecodeRawTransaction(raw)
+	if err != nil {
+		t.Fatalf("DecodeRawTransaction failed: %v", err)
+	}
+	decoded := DescribeTransaction(decodedTx)
+	if decoded.TxID != hex.EncodeToString(tx.ID) || decoded.Coinbase {
+		t.Errorf("Expected txid %x and no coinbase, got %s %v", tx.ID, decoded.TxID, decoded.Coinbase)
+	}
+	if len(decoded.Inputs) != 1 || decoded.Inputs[0].TxID != hex.EncodeToString([]byte("describe-funding")) || !decoded.Inputs[0].Signed {
+		t.Errorf("Unexpected inputs %+v", decoded.Inputs)
+	}
+	if len(decoded.Outputs) != 1 || decoded.Outputs[0].Value != 9.5 || decoded.Outputs[0].Address != "recipient" {
+		t.Errorf("Unexpected outputs %+v", decoded.Outputs)
+	}
+	if len(decoded.CoinTypes) != 1 || decoded.CoinTypes[0] != Leah {
+		t.Errorf("Expected coin types [Leah], got %v", decoded.CoinTypes)
+	}
+	if decoded.Fee != 0.5 || decoded.VSize != tx.VirtualSize() {
+		t.Errorf("Expected fee 0.5 and vsize %d, got %v and %d", tx.VirtualSize(), decoded.Fee, decoded.VSize)
+	}
+
+	coinbase := DescribeTransaction(NewCoinbaseTransaction("miner", nil, 50, Leah, GoldenBlock))
+	if !coinbase.Coinbase || len(coinbase.Inputs) != 0 || coinbase.Fee != 0 {
+		t.Errorf("Expected a coinbase without inputs or fee, got %+v", coinbase)
+	}
+}
+
+// TestAddBlockVerifiesManySignatures tests that a block carrying many signed
+// transactions validates, with every signature checked
+func TestAddBlockVerifiesManySignatures(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	bc := NewBlockchain()
+	const count = 100
+	txs := make([]Transaction, 0, count)
+	for i := 0; i < count; i++ {
+		funding := fundTestKey(t, bc, key, fmt.Sprintf("many-sigs-%d", i), 10)
+		txs = append(txs, signedTestSpend(t, key, funding, 9))
+	}
+
+	block := mineTestBlockWith(t, bc, GoldenBlock, "many-sigs-miner", txs...)
+	if got := len(block.Transactions); got != count+1 {
+		t.Errorf("Expected %d transactions in the block, got %d", count+1, got)
+	}
+
+	// A single bad signature still rejects the block
+	bad := NewBlockchain()
+	txs = txs[:0]
+	for i := 0; i < count; i++ {
+		funding := fundTestKey(t, bad, key, fmt.Sprintf("many-sigs-%d", i), 10)
+		txs = append(txs, signedTestSpend(t, key, funding, 9))
+	}
+	txs[count-1].Inputs[0].Signature[0] ^= 0xff
+	prev := bad.GoldenBlocks[len(bad.GoldenBlocks)-1]
+	if err := bad.AddBlock(buildTestBlock(bad, prev, GoldenBlock, "many-sigs-miner", 60, txs...)); err == nil {
+		t.Error("Expected a block with one bad signature among many to be rejected")
+	}
+}
+
+// TestCoinbaseTagLimits tests that blocks are rejected with an over-long
+// coinbase tag or a tag on a transaction other than the coinbase
+func TestCoinbaseTagLimits(t *testing.T) {
+	bc := NewBlockchain()
+	prev := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+
+	tagged := func(tag string) Block {
+		block := buildTestBlock(bc, prev, GoldenBlock, "tag-miner", 60)
+		block.Transactions[0].CoinbaseTag = tag
+		block.Transactions[0].RecomputeID()
+		block.MerkleRoot = MerkleRoot(block.Transactions)
+		block.Nonce = 0
+		for !bc.isValidProof(block) {
+			block.Nonce++
+		}
+		block.Hash = calculateHash(block)
+		return block
+	}
+
+	if err := bc.AddBlock(tagged(strings.Repeat("x", MaxCoinbaseTagLength+1))); err == nil {
+		t.Error("Expected a block with an over-long coinbase tag to be rejected")
+	}
+	block := tagged(strings.Repeat("x", MaxCoinbaseTagLength))
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock with a maximum length tag failed: %v", err)
+	}
+	summary, err := bc.GetBlockSummary(block.Hash)
+	if err != nil {
+		t.Fatalf("GetBlockSummary failed: %v", err)
+	}
+	if summary.CoinbaseTag != block.Transactions[0].CoinbaseTag {
+		t.Errorf("Expected the summary to show the coinbase tag, got %q", summary.CoinbaseTag)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	funding := fundTestKey(t, bc, key, "tag-funding", 10)
+	spend := signedTestSpend(t, key, funding, 9)
+	spend.CoinbaseTag = "not a coinbase"
+	spend.RecomputeID()
+	if err := bc.AddTransaction(spend); err == nil || !strings.Contains(err.Error(), "only a coinbase may carry a tag") {
+		t.Errorf("Expected a tagged transaction other than a coinbase to be refused, got %v", err)
+	}
+}
+
+// TestBlockMerkleRoot tests that a block is rejected without a Merkle root
+// or with one that does not commit to its transactions
+func TestBlockMerkleRoot(t *testing.T) {
+	bc := NewBlockchain()
+	prev := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+
+	withRoot := func(root []byte) Block {
+		block := buildTestBlock(bc, prev, GoldenBlock, "merkle-miner", 60)
+		block.MerkleRoot = root
+		block.Nonce = 0
+		for !bc.isValidProof(block) {
+			block.Nonce++
+		}
+		block.Hash = calculateHash(block)
+		return block
+	}
+
+	if err := bc.AddBlock(withRoot(nil)); err == nil || !strings.Contains(err.Error(), "no merkle root") {
+		t.Errorf("Expected a block without a merkle root to be rejected, got %v", err)
+	}
+	if err := bc.AddBlock(withRoot(make([]byte, 32))); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("Expected a block with a wrong merkle root to be rejected, got %v", err)
+	}
+	if len(bc.GoldenBlocks) != 1 {
+		t.Errorf("Expected the rejected blocks to leave only genesis, got %d blocks", len(bc.GoldenBlocks))
+	}
+}
+
+// TestTransactionExpiry tests that a transaction is accepted and mined up to
+// its expiry height, and refused by the pending pool and by blocks after it
+func TestTransactionExpiry(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	expiring := func(funding *Transaction, expiry uint64) Transaction {
+		tx := signedTestSpend(t, key, funding, 9)
+		tx.ExpiryHeight = expiry
+		tx.RecomputeID()
+		if err := tx.Sign(key.D.Bytes()); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		return tx
+	}
+
+	bc := NewBlockchain()
+	next := bc.TipHeights()[GoldenBlock] + 1
+
+	// Mined at its expiry height
+	onTime := expiring(fundTestKey(t, bc, key, "expiry-on-time", 10), next)
+	if err := bc.AddTransaction(onTime); err != nil {
+		t.Fatalf("AddTransaction before expiry failed: %v", err)
+	}
+	mineTestBlockWith(t, bc, GoldenBlock, "expiry-miner", onTime)
+
+	// Past its expiry height
+	late := expiring(fundTestKey(t, bc, key, "expiry-late", 10), next)
+	err = bc.AddTransaction(late)
+	var rejected *RejectError
+	if !errors.As(err, &rejected) || rejected.Reason != RejectExpired {
+		t.Errorf("Expected an expired transaction to be refused as %q, got %v", RejectExpired, err)
+	}
+	prev := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+	if err := bc.AddBlock(buildTestBlock(bc, prev, GoldenBlock, "expiry-miner", 60, late)); err == nil {
+		t.Error("Expected a block including an expired transaction to be rejected")
+	}
+
+	// Pending transactions that can no longer be mined are dropped
+	pending := expiring(fundTestKey(t, bc, key, "expiry-pending", 10), bc.TipHeights()[GoldenBlock]+1)
+	if err := bc.AddTransaction(pending); err != nil {
+		t.Fatalf("AddTransaction before expiry failed: %v", err)
+	}
+	// Silver blocks do not advance the golden chain's height
+	mineTestBlock(t, bc, SilverBlock, "expiry-miner")
+	if len(bc.GetPendingTransactions()) != 1 {
+		t.Error("Expected a silver block to leave the golden transaction pending")
+	}
+	mineTestBlock(t, bc, GoldenBlock, "expiry-miner")
+	for _, tx := range bc.GetPendingTransactions() {
+		if bytes.Equal(tx.ID, pending.ID) {
+			t.Error("Expected the expired transaction to leave the pending pool")
+		}
+	}
+}
+
+// TestRuleActivation tests that a consensus rule only applies from its
+// activation height: a block breaking RuleLowS is valid one block before
+// activation and the same block is rejected at it
+func TestRuleActivation(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	// Both chains start identically and differ only in when the rule activates
+	newChain := func(activation uint64) *Blockchain {
+		bc := NewBlockchain()
+		params := bc.ConsensusParams()
+		params.Activations = map[ConsensusRule]uint64{RuleLowS: activation}
+		bc.SetConsensusParams(params)
+		return bc
+	}
+	next := NewBlockchain().TipHeights()[GoldenBlock] + 1
+	before, after := newChain(next+1), newChain(next)
+	if before.ConsensusParams().RuleActive(RuleLowS, next) || !after.ConsensusParams().RuleActive(RuleLowS, next) {
+		t.Fatal("Expected RuleLowS to activate at its activation height")
+	}
+
+	// Sign, then swap the signature for its equally valid high-S twin
+	funding := fundTestKey(t, before, key, "activation-funding", 10)
+	fundTestKey(t, after, key, "activation-funding", 10)
+	tx := signedTestSpend(t, key, funding, 9)
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(tx.Inputs[0].Signature, &sig); err != nil {
+		t.Fatalf("Failed to parse signature: %v", err)
+	}
+	high, err := asn1.Marshal(struct {
+		R, S *big.Int
+	}{sig.R, new(big.Int).Sub(crypto.S256().Params().N, sig.S)})
+	if err != nil {
+		t.Fatalf("Failed to encode signature: %v", err)
+	}
+	tx.Inputs[0].Signature = high
+	if !tx.Verify() {
+		t.Fatal("Expected the high-S signature to verify")
+	}
+
+	block := buildTestBlock(before, before.GoldenBlocks[len(before.GoldenBlocks)-1], GoldenBlock, "activation-miner", 60, tx)
+	if err := after.AddBlock(block); err == nil || !strings.Contains(err.Error(), "low-S") {
+		t.Errorf("Expected the block to break RuleLowS once active, got %v", err)
+	}
+	if err := before.AddBlock(block); err != nil {
+		t.Errorf("Expected the block to be valid before RuleLowS activates, got %v", err)
+	}
+
+	// The pending pool applies the rules active for the next block
+	if err := after.AddTransaction(tx); err == nil || !strings.Contains(err.Error(), "low-S") {
+		t.Errorf("Expected the pending pool to refuse a high-S signature once RuleLowS is active, got %v", err)
+	}
+
+	// Schnorr signatures are not ECDSA and are not held to low-S
+	tapKey, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	outputKey, err := crypto.TaprootOutputKey(&tapKey.PublicKey, nil)
+	if err != nil {
+		t.Fatalf("Failed to derive the output key: %v", err)
+	}
+	tapFunding := &Transaction{
+		ID:      []byte("taproot-activation-funding"),
+		Outputs: []TxOutput{{Value: 10, CoinType: Leah, PublicKeyHash: outputKey}},
+	}
+	if err := after.UTXOSet.UpdateWithTransaction(tapFunding); err != nil {
+		t.Fatalf("Failed to fund the output key: %v", err)
+	}
+	tapSpend := Transaction{
+		Inputs:    []TxInput{{TxID: tapFunding.ID, Amount: 10, PublicKey: outputKey}},
+		Outputs:   []TxOutput{{Value: 9, CoinType: Leah, PublicKeyHash: []byte("recipient")}},
+		Timestamp: time.Now(),
+		Nonce:     NewTxNonce(),
+	}
+	tapSpend.RecomputeID()
+	if err := tapSpend.SignWith(func(hash []byte, _ int) ([]byte, error) {
+		return crypto.SignTaproot(hash, tapKey, nil)
+	}); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if err := after.AddTransaction(tapSpend); err != nil {
+		t.Errorf("Expected a Schnorr-signed spend to pass once RuleLowS is active, got %v", err)
+	}
+}
+
+// TestEstimateSmartFee tests that fee estimates come from recently mined
+// fee rates, rise for shorter confirmation targets and keep to the floor
+func TestEstimateSmartFee(t *testing.T) {
+	bc := NewBlockchain()
+	floor := bc.ConsensusParams().MinRelayFeeRate
+
+	if _, err := bc.EstimateSmartFee(0); err == nil {
+		t.Error("Expected a target of 0 blocks to be refused")
+	}
+	if _, err := bc.EstimateSmartFee(FeeEstimateWindow + 1); err == nil {
+		t.Error("Expected a target beyond the window to be refused")
+	}
+	if rate, err := bc.EstimateSmartFee(1); err != nil || rate != floor {
+		t.Errorf("Expected the minimum relay fee rate without history, got %v, %v", rate, err)
+	}
+
+	// Five blocks of four transactions, each paying a different fee
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	for b := 0; b < 5; b++ {
+		var txs []Transaction
+		for i := 0; i < 4; i++ {
+			funding := fundTestKey(t, bc, key, fmt.Sprintf("fee-estimate-%d-%d", b, i), 10)
+			txs = append(txs, signedTestSpend(t, key, funding, 10-0.01*float64(1+b*4+i)))
+		}
+		mineTestBlockWith(t, bc, GoldenBlock, "fee-estimate-miner", txs...)
+	}
+
+	next, err := bc.EstimateSmartFee(1)
+	if err != nil {
+		t.Fatalf("EstimateSmartFee(1) failed: %v", err)
+	}
+	later, err := bc.EstimateSmartFee(6)
+	if err != nil {
+		t.Fatalf("EstimateSmartFee(6) failed: %v", err)
+	}
+	if next <= later {
+		t.Errorf("Expected the 1-block estimate %v to exceed the 6-block estimate %v", next, later)
+	}
+	if later < floor {
+		t.Errorf("Expected estimates of at least the minimum relay fee rate %v, got %v", floor, later)
+	}
+
+	// A floor above every mined rate wins
+	params := bc.ConsensusParams()
+	params.MinRelayFeeRate = 1
+	bc.SetConsensusParams(params)
+	if rate, _ := bc.EstimateSmartFee(1); rate != 1 {
+		t.Errorf("Expected the estimate to be floored at 1, got %v", rate)
+	}
+}
+
+// TestPruneMempool tests that raising the minimum relay fee rate evicts only
+// the pending transactions paying below it, and their descendants
+func TestPruneMempool(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+
+	// One transaction in each of three fee bands
+	var bands []Transaction
+	for i, fee := range []float64{0.01, 0.1, 1} {
+		tx := signedTestSpend(t, key, fundTestKey(t, bc, key, fmt.Sprintf("prune-%d", i), 10), 10-fee)
+		if i == 0 {
+			// The cheapest one pays back to the key so a child can spend it
+			tx.Outputs[0].PublicKeyHash = crypto.HashPublicKey(&key.PublicKey)
+			tx.RecomputeID()
+			if err := tx.Sign(key.D.Bytes()); err != nil {
+				t.Fatalf("Failed to sign: %v", err)
+			}
+		}
+		if err := bc.AddTransaction(tx); err != nil {
+			t.Fatalf("AddTransaction failed: %v", err)
+		}
+		bands = append(bands, tx)
+	}
+	low, mid, high := bands[0], bands[1], bands[2]
+
+	// A well-paying child cannot be mined without its evicted parent
+	child := signedTestSpend(t, key, &low, low.Outputs[0].Value-0.5)
+	if err := bc.AddTransaction(child); err != nil {
+		t.Fatalf("AddTransaction of the child failed: %v", err)
+	}
+
+	if evicted := bc.PruneMempool(low.FeeRate()); evicted != 0 {
+		t.Errorf("Expected nothing evicted at the lowest fee rate, got %d", evicted)
+	}
+
+	params := bc.ConsensusParams()
+	params.MinRelayFeeRate = mid.FeeRate()
+	bc.SetConsensusParams(params)
+
+	pending := make(map[string]bool)
+	for _, tx := range bc.GetPendingTransactions() {
+		pending[string(tx.ID)] = true
+	}
+	if len(pending) != 2 || !pending[string(mid.ID)] || !pending[string(high.ID)] {
+		t.Errorf("Expected only the transactions at or above the new minimum to stay pending, got %d", len(pending))
+	}
+
+	if evicted := bc.PruneMempool(high.FeeRate() * 2); evicted != 2 {
+		t.Errorf("Expected PruneMempool to report 2 evictions, got %d", evicted)
+	}
+}
+
+// TestMempoolEvictsDescendantsAcrossQueues tests that evicting a silver parent
+// also evicts its golden descendants, which are pending ahead of it
+func TestMempoolEvictsDescendantsAcrossQueues(t *testing.T) {
+	// spend pays value of the output of parent on blockType, leaving the rest as fee
+	spend := func(parent []byte, amount, value float64, blockType BlockType) Transaction {
+		tx := Transaction{
+			Inputs:    []TxInput{{TxID: parent, Amount: amount}},
+			Outputs:   []TxOutput{{Value: value, CoinType: Leah, PublicKeyHash: []byte("recipient"), Address: "recipient"}},
+			BlockType: blockType,
+			Timestamp: time.Now(),
+			Nonce:     NewTxNonce(),
+		}
+		tx.ID = tx.CalculateHash()
+		return tx
+	}
+	parent := spend([]byte("funding"), 10, 9.999, SilverBlock)
+	child := spend(parent.ID, 9.999, 8, GoldenBlock)
+	grandchild := spend(child.ID, 8, 7, GoldenBlock)
+	unrelated := spend([]byte("other-funding"), 10, 9, SilverBlock)
+
+	queue := func(bc *Blockchain) {
+		bc.PendingTxs = map[BlockType][]Transaction{
+			GoldenBlock: {grandchild, child},
+			SilverBlock: {parent, unrelated},
+		}
+	}
+	onlyUnrelated := func(bc *Blockchain) {
+		pending := bc.GetPendingTransactions()
+		if len(pending) != 1 || !bytes.Equal(pending[0].ID, unrelated.ID) {
+			t.Errorf("Expected only the unrelated transaction to stay pending, got %d transactions", len(pending))
+		}
+	}
+
+	// Only the parent pays below the minimum; its descendants go with it
+	bc := NewBlockchain()
+	queue(bc)
+	if evicted := bc.PruneMempool(parent.FeeRate() * 2); evicted != 3 {
+		t.Errorf("Expected PruneMempool to evict the parent and both descendants, got %d", evicted)
+	}
+	onlyUnrelated(bc)
+
+	// A block spending the parent's input conflicts with the whole chain
+	bc = NewBlockchain()
+	queue(bc)
+	double := spend([]byte("funding"), 10, 9.5, SilverBlock)
+	if removed := bc.RemoveTransactionsFromMempool([]Transaction{double}); removed != 3 {
+		t.Errorf("Expected the conflict to remove the parent and both descendants, got %d", removed)
+	}
+	onlyUnrelated(bc)
+}
+
+// TestTxBuilder tests that the builder assembles spendable transactions and
+// coinbases and refuses incomplete or contradictory ones
+func TestTxBuilder(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	funding := fundTestKey(t, bc, key, "builder-funding", 10)
+	pubKey := crypto.PublicKeyToBytes(&key.PublicKey)
+
+	tx, err := NewTxBuilder().
+		AddInput(funding.ID, 0, 10, pubKey).
+		SetCoinType(Leah).
+		AddOutput(9, "recipient", []byte("recipient")).
+		SetExpiryHeight(bc.TipHeights()[GoldenBlock] + 10).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if err := tx.CheckID(); err != nil {
+		t.Errorf("Expected the built transaction to carry its ID: %v", err)
+	}
+	if tx.Nonce == 0 || tx.Timestamp.IsZero() {
+		t.Error("Expected the built transaction to have a nonce and timestamp")
+	}
+	if err := tx.Sign(key.D.Bytes()); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if err := bc.AddTransaction(*tx); err != nil {
+		t.Errorf("Expected the built transaction to be accepted, got %v", err)
+	}
+
+	coinbase, err := NewTxBuilder().
+		Coinbase("builder/1").
+		SetCoinType(Leah).
+		AddOutput(BlockSubsidy(Leah), "miner", []byte("miner")).
+		Build()
+	if err != nil {
+		t.Fatalf("Build of a coinbase failed: %v", err)
+	}
+	if !coinbase.IsCoinbase() || coinbase.CoinbaseTag != "builder/1" {
+		t.Error("Expected a tagged coinbase")
+	}
+
+	invalid := map[string]*TxBuilder{
+		"no inputs":       NewTxBuilder().SetCoinType(Leah).AddOutput(1, "a", []byte("a")),
+		"no outputs":      NewTxBuilder().AddInput(funding.ID, 0, 10, pubKey),
+		"no coin type":    NewTxBuilder().AddInput(funding.ID, 0, 10, pubKey).AddOutput(1, "a", []byte("a")),
+		"unknown coin":    NewTxBuilder().AddInput(funding.ID, 0, 10, pubKey).SetCoinType("GOLD").AddOutput(1, "a", []byte("a")),
+		"zero output":     NewTxBuilder().AddInput(funding.ID, 0, 10, pubKey).SetCoinType(Leah).AddOutput(0, "a", []byte("a")),
+		"unlocked output": NewTxBuilder().AddInput(funding.ID, 0, 10, pubKey).SetCoinType(Leah).AddOutput(1, "a", nil),
+		"overspend":       NewTxBuilder().AddInput(funding.ID, 0, 10, pubKey).SetCoinType(Leah).AddOutput(11, "a", []byte("a")),
+		"double spend":    NewTxBuilder().AddInput(funding.ID, 0, 5, pubKey).AddInput(funding.ID, 0, 5, pubKey),
+		"negative index":  NewTxBuilder().AddInput(funding.ID, -1, 10, pubKey),
+		"coinbase inputs": NewTxBuilder().AddInput(funding.ID, 0, 10, pubKey).Coinbase(""),
+		"long tag":        NewTxBuilder().Coinbase(strings.Repeat("x", MaxCoinbaseTagLength+1)),
+	}
+	for name, b := range invalid {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("%s: expected Build to fail", name)
+		}
+	}
+}
+
+// TestGetBlockFeeStats tests the fee statistics of a block with known fees
+func TestGetBlockFeeStats(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+
+	var txs []Transaction
+	for i, fee := range []float64{0.5, 0.1, 0.3, 0.2} {
+		funding := fundTestKey(t, bc, key, fmt.Sprintf("fee-stats-%d", i), 10)
+		txs = append(txs, signedTestSpend(t, key, funding, 10-fee))
+	}
+	mineTestBlockWith(t, bc, GoldenBlock, "fee-stats-miner", txs...)
+	block := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+
+	minFee, maxFee, median, total, err := bc.GetBlockFeeStats(block.Hash)
+	if err != nil {
+		t.Fatalf("GetBlockFeeStats failed: %v", err)
+	}
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	if !near(minFee, 0.1) || !near(maxFee, 0.5) || !near(median, 0.25) || !near(total, 1.1) {
+		t.Errorf("GetBlockFeeStats = %v, %v, %v, %v; want 0.1, 0.5, 0.25, 1.1", minFee, maxFee, median, total)
+	}
+
+	// The genesis block has no fee paying transactions
+	if minFee, maxFee, median, total, err := bc.GetBlockFeeStats(bc.GoldenBlocks[0].Hash); err != nil || minFee != 0 || maxFee != 0 || median != 0 || total != 0 {
+		t.Errorf("Expected zero fee stats for the genesis block, got %v, %v, %v, %v, %v", minFee, maxFee, median, total, err)
+	}
+	if _, _, _, _, err := bc.GetBlockFeeStats([]byte("missing")); err == nil {
+		t.Error("Expected an unknown block to be an error")
+	}
+}
+
+// fundTestMint adds UTXOs paying key each of minted's mint requirements and
+// returns the funding transaction
+func fundTestMint(t *testing.T, bc *Blockchain, key *ecdsa.PrivateKey, minted CoinType) *Transaction {
+	funding := &Transaction{ID: []byte("mint-funding-" + string(minted))}
+	for _, r := range MintRequirements(minted) {
+		funding.Outputs = append(funding.Outputs, TxOutput{Value: r.Amount, CoinType: r.CoinType, PublicKeyHash: crypto.HashPublicKey(&key.PublicKey)})
+	}
+	if err := bc.UTXOSet.UpdateWithTransaction(funding); err != nil {
+		t.Fatalf("Failed to fund key: %v", err)
+	}
+	return funding
+}
+
+// signedTestMint mints one minted coin to key from the first spend outputs of funding
+func signedTestMint(t *testing.T, key *ecdsa.PrivateKey, funding *Transaction, spend int, minted CoinType) Transaction {
+	tx := Transaction{
+		Outputs:   []TxOutput{{Value: 1, CoinType: minted, PublicKeyHash: crypto.HashPublicKey(&key.PublicKey)}},
+		Timestamp: time.Now(),
+		Nonce:     NewTxNonce(),
+	}
+	for i, output := range funding.Outputs[:spend] {
+		tx.Inputs = append(tx.Inputs, TxInput{
+			TxID:        funding.ID,
+			OutputIndex: i,
+			Amount:      output.Value,
+			PublicKey:   crypto.PublicKeyToBytes(&key.PublicKey),
+		})
+	}
+	tx.ID = tx.CalculateHash()
+	if err := tx.Sign(key.D.Bytes()); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	return tx
+}
+
+func TestMintRequiresFibonacciCoins(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	funding := fundTestMint(t, bc, key, Manasseh)
+
+	// Spending every requirement but the Antion does not mint
+	short := signedTestMint(t, key, funding, len(funding.Outputs)-1, Manasseh)
+	var validationErr *ValidationError
+	if err := short.Validate(bc.UTXOSet); !errors.As(err, &validationErr) || validationErr.Field != "balance" {
+		t.Errorf("Expected a mint short of its requirements to be rejected, got %v", err)
+	}
+
+	full := signedTestMint(t, key, funding, len(funding.Outputs), Manasseh)
+	if err := full.Validate(bc.UTXOSet); err != nil {
+		t.Errorf("Expected a mint spending its requirements to validate, got %v", err)
+	}
+}
+
+func TestSupplyCountsMintsAndDisconnects(t *testing.T) {
+	logger.Init()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	bc := NewBlockchain()
+	funding := fundTestMint(t, bc, key, Manasseh)
+	mint := signedTestMint(t, key, funding, len(funding.Outputs), Manasseh)
+	leah := bc.GetTotalSupply(Leah)
+
+	// A mint past the cap is rejected like an over-issuing coinbase
+	params := DefaultConsensusParams()
+	params.MaxSupply[Manasseh] = 0
+	bc.SetConsensusParams(params)
+	prev := bc.SilverBlocks[len(bc.SilverBlocks)-1]
+	block := buildTestBlock(bc, prev, SilverBlock, "miner", 60, mint)
+	if err := bc.AddBlock(block); err == nil || !strings.Contains(err.Error(), "past its cap") {
+		t.Fatalf("Expected a mint past the supply cap to be rejected, got %v", err)
+	}
+
+	params.MaxSupply[Manasseh] = 1
+	bc.SetConsensusParams(params)
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+	if got := bc.GetTotalSupply(Manasseh); got != 1 {
+		t.Errorf("Expected the mint to issue 1 Manasseh, got %v", got)
+	}
+	if got := bc.GetTotalSupply(Leah); got != leah+50 {
+		t.Errorf("Expected the coinbase to issue 50 Leah, got %v", got-leah)
+	}
+
+	// Disconnecting the block takes its issuance back off the supply
+	bc.mu.Lock()
+	bc.disconnectBlocks(SilverBlock, len(bc.SilverBlocks)-2)
+	bc.mu.Unlock()
+	if got := bc.GetTotalSupply(Manasseh); got != 0 {
+		t.Errorf("Expected no Manasseh once the mint is disconnected, got %v", got)
+	}
+	if got := bc.GetTotalSupply(Leah); got != leah {
+		t.Errorf("Expected Leah supply %v once the block is disconnected, got %v", leah, got)
+	}
+}
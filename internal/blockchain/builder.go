@@ -0,0 +1,151 @@
+package blockchain
+
+import (
+	"fmt"
+	"time"
+)
+
+// TxBuilder assembles a transaction step by step and checks it is complete
+// before computing its ID, so callers need not fill in the struct by hand.
+// The first mistake is kept and returned by Build; later calls are ignored.
+type TxBuilder struct {
+	tx       Transaction
+	coinType CoinType
+	coinbase bool
+	err      error
+}
+
+// NewTxBuilder starts an empty transaction
+func NewTxBuilder() *TxBuilder {
+	return &TxBuilder{}
+}
+
+// fail records the first error
+func (b *TxBuilder) fail(field, reason string) *TxBuilder {
+	if b.err == nil {
+		b.err = &ValidationError{Field: field, Reason: reason}
+	}
+	return b
+}
+
+// AddInput spends output outputIndex of transaction txID, worth amount, with
+// the key publicKey
+func (b *TxBuilder) AddInput(txID []byte, outputIndex int, amount float64, publicKey []byte) *TxBuilder {
+	field := fmt.Sprintf("input[%d]", len(b.tx.Inputs))
+	switch {
+	case b.coinbase:
+		return b.fail(field, "a coinbase spends no outputs")
+	case len(txID) == 0:
+		return b.fail(field+".TxID", "empty transaction ID")
+	case outputIndex < 0:
+		return b.fail(field+".OutputIndex", "invalid output index")
+	case amount <= 0:
+		return b.fail(field+".Amount", "invalid amount")
+	}
+	for _, input := range b.tx.Inputs {
+		if string(input.TxID) == string(txID) && input.OutputIndex == outputIndex {
+			return b.fail(field, fmt.Sprintf("output %x:%d is spent twice", txID, outputIndex))
+		}
+	}
+
+	b.tx.Inputs = append(b.tx.Inputs, TxInput{
+		TxID:        txID,
+		OutputIndex: outputIndex,
+		Amount:      amount,
+		PublicKey:   publicKey,
+	})
+	return b
+}
+
+// Coinbase makes the transaction a coinbase, which spends no outputs and
+// carries tag, empty for none
+func (b *TxBuilder) Coinbase(tag string) *TxBuilder {
+	if len(b.tx.Inputs) > 0 {
+		return b.fail("inputs", "a coinbase spends no outputs")
+	}
+	if err := CheckCoinbaseTag(tag); err != nil {
+		return b.fail("coinbase_tag", err.Error())
+	}
+	b.coinbase = true
+	b.tx.CoinbaseTag = tag
+	return b
+}
+
+// SetCoinType sets the coin paid by the outputs added after it
+func (b *TxBuilder) SetCoinType(coinType CoinType) *TxBuilder {
+	if !coinType.IsValid() {
+		return b.fail("coin_type", fmt.Sprintf("unknown coin type %q", coinType))
+	}
+	b.coinType = coinType
+	return b
+}
+
+// AddOutput pays value of the current coin type to address, locked to the
+// public key hash the address commits to
+func (b *TxBuilder) AddOutput(value float64, address string, publicKeyHash []byte) *TxBuilder {
+	field := fmt.Sprintf("output[%d]", len(b.tx.Outputs))
+	switch {
+	case b.coinType == "":
+		return b.fail(field+".CoinType", "no coin type set; call SetCoinType first")
+	case value <= 0:
+		return b.fail(field+".Value", "invalid amount")
+	case len(publicKeyHash) == 0:
+		return b.fail(field+".PublicKeyHash", "empty public key hash")
+	}
+
+	b.tx.Outputs = append(b.tx.Outputs, TxOutput{
+		Value:         value,
+		CoinType:      b.coinType,
+		PublicKeyHash: publicKeyHash,
+		Address:       address,
+	})
+	return b
+}
+
+// SetExpiryHeight sets the last block height the transaction may be
+// included at; 0 never expires
+func (b *TxBuilder) SetExpiryHeight(height uint64) *TxBuilder {
+	b.tx.ExpiryHeight = height
+	return b
+}
+
+// SetReplaceable opts the transaction into replace-by-fee while it is pending
+func (b *TxBuilder) SetReplaceable(replaceable bool) *TxBuilder {
+	b.tx.Replaceable = replaceable
+	return b
+}
+
+// Build checks the transaction is complete and returns it with its
+// timestamp, nonce and ID set, ready to sign. A transaction needs outputs,
+// and inputs unless it is a coinbase; it may not pay out more than its
+// inputs are worth.
+func (b *TxBuilder) Build() (*Transaction, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.tx.Outputs) == 0 {
+		return nil, &ValidationError{Field: "outputs", Reason: "transaction has no outputs"}
+	}
+
+	tx := b.tx
+	tx.Inputs = append([]TxInput(nil), b.tx.Inputs...)
+	tx.Outputs = append([]TxOutput(nil), b.tx.Outputs...)
+	if b.coinbase {
+		tx.Inputs = []TxInput{{OutputIndex: -1}}
+	} else {
+		if len(tx.Inputs) == 0 {
+			return nil, &ValidationError{Field: "inputs", Reason: "a transaction other than a coinbase needs inputs"}
+		}
+		if fee := tx.GetFee(); fee < 0 {
+			return nil, &ValidationError{
+				Field:  "fee",
+				Reason: fmt.Sprintf("inputs do not cover outputs (fee %.8f)", fee),
+			}
+		}
+	}
+
+	tx.Timestamp = time.Now()
+	tx.Nonce = NewTxNonce()
+	tx.RecomputeID()
+	return &tx, nil
+}
@@ -0,0 +1,169 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// ChainStateFile is the file in a data directory recording the last
+	// checkpointed height and tip
+	ChainStateFile = "chainstate.json"
+	// chainStateBlocksFile holds the checkpointed blocks, as ExportChain writes them
+	chainStateBlocksFile = "chainstate.bycchain"
+)
+
+// DefaultCheckpointInterval is how often RunCheckpoints saves the chain state
+// when no new block prompts it sooner
+const DefaultCheckpointInterval = 5 * time.Minute
+
+// ChainState is the position of the chain at a checkpoint
+type ChainState struct {
+	Height  int64     `json:"height"`
+	TipHash string    `json:"tip_hash"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// chainState returns the current height and tip. Callers must hold bc.mu.
+func (bc *Blockchain) chainState() ChainState {
+	state := ChainState{Height: int64(len(bc.Blocks))}
+	if len(bc.Blocks) > 0 {
+		state.TipHash = hex.EncodeToString(bc.Blocks[len(bc.Blocks)-1].Hash)
+	}
+	return state
+}
+
+// SaveChainState checkpoints the chain to dir: the blocks of both chains, then
+// the height and tip they reach. Both are written to temporary files first,
+// so a crash mid-save leaves the previous checkpoint intact.
+func (bc *Blockchain) SaveChainState(dir string) (ChainState, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return ChainState{}, fmt.Errorf("failed to create chain state directory: %v", err)
+	}
+
+	// Read the blocks and the state they reach under one lock
+	bc.mu.RLock()
+	state := bc.chainState()
+	err := writeFileAtomic(filepath.Join(dir, chainStateBlocksFile), func(f *os.File) error {
+		return bc.exportChain(f)
+	})
+	bc.mu.RUnlock()
+	if err != nil {
+		return ChainState{}, fmt.Errorf("failed to save chain state: %v", err)
+	}
+
+	state.SavedAt = time.Now()
+	data, err := json.Marshal(state)
+	if err != nil {
+		return ChainState{}, fmt.Errorf("failed to encode chain state: %v", err)
+	}
+	err = writeFileAtomic(filepath.Join(dir, ChainStateFile), func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	})
+	if err != nil {
+		return ChainState{}, fmt.Errorf("failed to save chain state: %v", err)
+	}
+	return state, nil
+}
+
+// LoadChainState restores the checkpoint SaveChainState wrote to dir,
+// applying the saved blocks not already on the chains, and returns the state
+// it recorded. A missing checkpoint is not an error and returns the zero
+// state.
+func (bc *Blockchain) LoadChainState(dir string) (ChainState, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ChainStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ChainState{}, nil
+		}
+		return ChainState{}, fmt.Errorf("failed to read chain state: %v", err)
+	}
+	var state ChainState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return ChainState{}, fmt.Errorf("failed to decode chain state: %v", err)
+	}
+
+	file, err := os.Open(filepath.Join(dir, chainStateBlocksFile))
+	if err != nil {
+		return ChainState{}, fmt.Errorf("failed to open checkpointed blocks: %v", err)
+	}
+	defer file.Close()
+	if err := bc.ImportChain(file); err != nil {
+		return ChainState{}, fmt.Errorf("failed to restore checkpointed blocks: %v", err)
+	}
+
+	// A crash between writing the blocks and the state leaves blocks past
+	// the saved tip, but never fewer
+	tip, err := hex.DecodeString(state.TipHash)
+	if err != nil {
+		return ChainState{}, fmt.Errorf("invalid checkpoint tip %q: %v", state.TipHash, err)
+	}
+	if _, err := bc.GetBlock(tip); err != nil {
+		return ChainState{}, fmt.Errorf("checkpoint tip %s is not on the restored chain", state.TipHash)
+	}
+	return state, nil
+}
+
+// RunCheckpoints saves the chain state to dir on start, whenever a block is
+// connected and every interval, until stop is closed, then saves it a last
+// time.
+// Failed saves are passed to onError if it is set; they do not stop the
+// checkpoints.
+func (bc *Blockchain) RunCheckpoints(dir string, interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	if interval <= 0 {
+		interval = DefaultCheckpointInterval
+	}
+	events, unsubscribe := bc.SubscribeBlocks()
+	defer unsubscribe()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	save := func() {
+		if _, err := bc.SaveChainState(dir); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+	save()
+	for {
+		select {
+		case <-stop:
+			save()
+			return
+		case event, ok := <-events:
+			if !ok {
+				// The chain was closed
+				return
+			}
+			if event.Type == BlockConnected {
+				save()
+			}
+		case <-ticker.C:
+			save()
+		}
+	}
+}
+
+// writeFileAtomic writes path through write into a temporary file that
+// replaces path only once complete
+func writeFileAtomic(path string, write func(*os.File) error) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
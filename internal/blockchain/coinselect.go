@@ -0,0 +1,54 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInsufficientFunds is returned when an address cannot cover an amount
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// SelectUTXOs picks the outputs among utxos that can fund coinType when the
+// chains are at heights under params, in order, until they cover amount. It
+// returns them with their total, which is below amount if they cannot cover
+// it. Only outputs of coinType itself fund it, so coins of one chain never
+// fund the other's; moving a cross-transferable coin between chains is
+// checked on the transaction by Validate.
+func SelectUTXOs(utxos []UTXO, amount float64, coinType CoinType, params ConsensusParams, heights ChainHeights) ([]UTXO, float64) {
+	var selected []UTXO
+	var total float64
+	for _, utxo := range utxos {
+		if utxo.Spent || utxo.CoinType != coinType || !params.IsSpendable(utxo, heights.Of(utxo)) {
+			continue
+		}
+		selected = append(selected, utxo)
+		total += utxo.Amount
+		if total >= amount {
+			break
+		}
+	}
+	return selected, total
+}
+
+// FindUTXOsForAmount selects spendable outputs of address that cover amount of
+// coinType and returns them with the change left over. It returns
+// ErrInsufficientFunds if the address cannot cover amount.
+func (bc *Blockchain) FindUTXOsForAmount(address string, coinType CoinType, amount float64) ([]UTXO, float64, error) {
+	if amount <= 0 {
+		return nil, 0, errors.New("amount must be positive")
+	}
+
+	bc.mu.RLock()
+	params, heights := bc.params, bc.tipHeights()
+	bc.mu.RUnlock()
+
+	utxos, err := bc.UTXOSet.GetUTXOs(address)
+	if err != nil {
+		return nil, 0, err
+	}
+	selected, total := SelectUTXOs(utxos, amount, coinType, params, heights)
+	if total < amount {
+		return nil, 0, fmt.Errorf("%w: %s has %f %s spendable, need %f", ErrInsufficientFunds, address, total, coinType, amount)
+	}
+	return selected, total - amount, nil
+}
@@ -0,0 +1,141 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"sync"
+)
+
+// BlockEventType says whether a block joined or left its chain
+type BlockEventType int
+
+const (
+	// BlockConnected is sent when a block extends its chain
+	BlockConnected BlockEventType = iota
+	// BlockDisconnected is sent when a reorganization removes a block
+	BlockDisconnected
+)
+
+// BlockEvent describes a change to the active chains
+type BlockEvent struct {
+	Type  BlockEventType
+	Block *Block
+}
+
+// blockEventBuffer is how many events a subscriber may fall behind by before
+// newer events to it are dropped
+const blockEventBuffer = 64
+
+// blockEvents fans block events out to subscribers
+type blockEvents struct {
+	mu          sync.Mutex
+	nextID      int
+	subscribers map[int]chan BlockEvent
+}
+
+// SubscribeBlocks returns a channel receiving an event for every block
+// connected or disconnected, and a function ending the subscription and
+// closing the channel. Events are sent before the call that caused them
+// returns, without blocking the chain: a subscriber more than
+// blockEventBuffer events behind misses the newest ones, so a full channel
+// should be read as "the chain changed".
+func (bc *Blockchain) SubscribeBlocks() (<-chan BlockEvent, func()) {
+	e := &bc.events
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.subscribers == nil {
+		e.subscribers = make(map[int]chan BlockEvent)
+	}
+	id := e.nextID
+	e.nextID++
+	ch := make(chan BlockEvent, blockEventBuffer)
+	e.subscribers[id] = ch
+
+	return ch, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if _, ok := e.subscribers[id]; ok {
+			delete(e.subscribers, id)
+			close(ch)
+		}
+	}
+}
+
+// closeAll ends every subscription, closing the subscribers' channels
+func (e *blockEvents) closeAll() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id, ch := range e.subscribers {
+		delete(e.subscribers, id)
+		close(ch)
+	}
+}
+
+// publishBlock sends an event for b to every subscriber. Callers must hold bc.mu.
+func (bc *Blockchain) publishBlock(eventType BlockEventType, b *Block) {
+	e := &bc.events
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, ch := range e.subscribers {
+		select {
+		case ch <- BlockEvent{Type: eventType, Block: b.Copy()}:
+		default:
+		}
+	}
+}
+
+// WaitForConfirmation returns a channel that receives the transaction's
+// confirmation count once the block holding txid is confs blocks deep, then
+// closes. The count is rechecked on every block event, so a transaction
+// reorganized out of its chain is waited for again from zero. A confs below
+// one waits for one confirmation. The channel closes without a value if ctx
+// ends or the blockchain is closed first.
+func (bc *Blockchain) WaitForConfirmation(ctx context.Context, txid []byte, confs int) <-chan int {
+	if confs < 1 {
+		confs = 1
+	}
+	id := append([]byte(nil), txid...)
+	// Subscribe before the first check so no block in between is missed
+	events, unsubscribe := bc.SubscribeBlocks()
+	confirmed := make(chan int, 1)
+
+	go func() {
+		defer close(confirmed)
+		defer unsubscribe()
+		for {
+			bc.mu.RLock()
+			got := bc.txConfirmations(id)
+			bc.mu.RUnlock()
+			if got >= confs {
+				confirmed <- got
+				return
+			}
+			select {
+			case _, open := <-events:
+				if !open {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return confirmed
+}
+
+// txConfirmations returns how many blocks of its chain confirm the block
+// holding txid, or 0 if no active chain holds it. Callers must hold bc.mu.
+func (bc *Blockchain) txConfirmations(txid []byte) int {
+	for _, chain := range [][]Block{bc.GoldenBlocks, bc.SilverBlocks} {
+		for height := len(chain) - 1; height >= 0; height-- {
+			for _, tx := range chain[height].Transactions {
+				if bytes.Equal(tx.ID, txid) {
+					return len(chain) - height
+				}
+			}
+		}
+	}
+	return 0
+}
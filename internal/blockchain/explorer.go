@@ -0,0 +1,209 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+)
+
+// BlockSummary is the explorer view of a single block
+type BlockSummary struct {
+	Hash             string    `json:"hash"`
+	BlockType        BlockType `json:"block_type"`
+	Height           int       `json:"height"`
+	Timestamp        int64     `json:"timestamp"`
+	Difficulty       int       `json:"difficulty"`
+	TransactionCount int       `json:"transaction_count"`
+	TotalFees        float64   `json:"total_fees"`
+	TotalOutput      float64   `json:"total_output"`
+	Size             int64     `json:"size"`
+	MerkleRoot       string    `json:"merkle_root"`
+	CoinbaseTag      string    `json:"coinbase_tag,omitempty"`
+}
+
+// AddressBalance is an address and its unspent balance of one coin
+type AddressBalance struct {
+	Address string  `json:"address"`
+	Balance float64 `json:"balance"`
+}
+
+// GetTopHolders returns the n addresses holding the most unspent coinType,
+// largest balance first. Equal balances are ordered by address so the list
+// is the same on every call.
+func (bc *Blockchain) GetTopHolders(coinType CoinType, n int) []AddressBalance {
+	if n <= 0 {
+		return nil
+	}
+
+	var holders []AddressBalance
+	for address, balance := range bc.UTXOSet.balancesByAddress(coinType) {
+		if balance > 0 {
+			holders = append(holders, AddressBalance{Address: address, Balance: balance})
+		}
+	}
+	sort.Slice(holders, func(i, j int) bool {
+		if holders[i].Balance != holders[j].Balance {
+			return holders[i].Balance > holders[j].Balance
+		}
+		return holders[i].Address < holders[j].Address
+	})
+
+	if len(holders) > n {
+		holders = holders[:n]
+	}
+	return holders
+}
+
+// GetBlockSummary returns the explorer summary of the block with the given hash.
+// Height is the block's position in its own chain, genesis being 0.
+func (bc *Blockchain) GetBlockSummary(hash []byte) (*BlockSummary, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	for _, chain := range [][]Block{bc.GoldenBlocks, bc.SilverBlocks} {
+		for height, block := range chain {
+			if bytes.Equal(block.Hash, hash) {
+				return bc.summarizeBlock(block, height), nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("block not found")
+}
+
+// GetBlockFeeStats returns the smallest, largest, median and total fee paid
+// by the transactions of the block with the given hash. Coinbase and genesis
+// allocations pay no fee and are left out; a block without other
+// transactions has all four at zero. The median of an even count is the
+// mean of the middle two.
+func (bc *Blockchain) GetBlockFeeStats(hash []byte) (minFee, maxFee, median, total float64, err error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	for _, chain := range [][]Block{bc.GoldenBlocks, bc.SilverBlocks} {
+		for _, block := range chain {
+			if bytes.Equal(block.Hash, hash) {
+				minFee, maxFee, median, total = blockFeeStats(block)
+				return minFee, maxFee, median, total, nil
+			}
+		}
+	}
+	return 0, 0, 0, 0, fmt.Errorf("block not found")
+}
+
+// blockFeeStats computes GetBlockFeeStats for block
+func blockFeeStats(block Block) (minFee, maxFee, median, total float64) {
+	var fees []float64
+	for _, tx := range block.Transactions {
+		if len(tx.Inputs) > 0 && !tx.IsCoinbase() {
+			fees = append(fees, tx.GetFee())
+		}
+	}
+	if len(fees) == 0 {
+		return 0, 0, 0, 0
+	}
+
+	sort.Float64s(fees)
+	for _, fee := range fees {
+		total += fee
+	}
+	mid := len(fees) / 2
+	median = fees[mid]
+	if len(fees)%2 == 0 {
+		median = (fees[mid-1] + fees[mid]) / 2
+	}
+	return fees[0], fees[len(fees)-1], median, total
+}
+
+// summarizeBlock aggregates the fees, outputs and size of a block
+func (bc *Blockchain) summarizeBlock(block Block, height int) *BlockSummary {
+	summary := &BlockSummary{
+		Hash:             fmt.Sprintf("%x", block.Hash),
+		BlockType:        block.BlockType,
+		Height:           height,
+		Timestamp:        block.Timestamp,
+		Difficulty:       block.Difficulty,
+		TransactionCount: len(block.Transactions),
+		Size:             bc.calculateBlockSize(block),
+		MerkleRoot:       fmt.Sprintf("%x", MerkleRoot(block.Transactions)),
+	}
+
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			summary.CoinbaseTag = tx.CoinbaseTag
+		}
+		// Coinbase and genesis allocations create their outputs, so they pay no fee
+		if len(tx.Inputs) > 0 && !tx.IsCoinbase() {
+			summary.TotalFees += tx.GetFee()
+		}
+		summary.TotalOutput += tx.GetTotalOutput()
+	}
+
+	return summary
+}
+
+// DifficultyPoint is the difficulty of one block, for charting
+type DifficultyPoint struct {
+	Height     int64 `json:"height"`
+	Timestamp  int64 `json:"timestamp"`
+	Difficulty int   `json:"difficulty"`
+}
+
+// GetDifficultyHistory returns the difficulty of each block of blockType's
+// chain from fromHeight to toHeight inclusive. The range is clamped to the
+// chain, so a toHeight past the tip ends at the tip.
+func (bc *Blockchain) GetDifficultyHistory(blockType BlockType, fromHeight, toHeight int64) []DifficultyPoint {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	chain := bc.chain(blockType)
+	if fromHeight < 0 {
+		fromHeight = 0
+	}
+	if toHeight >= int64(len(chain)) {
+		toHeight = int64(len(chain)) - 1
+	}
+	if fromHeight > toHeight {
+		return nil
+	}
+
+	points := make([]DifficultyPoint, 0, toHeight-fromHeight+1)
+	for height := fromHeight; height <= toHeight; height++ {
+		block := chain[height]
+		points = append(points, DifficultyPoint{
+			Height:     height,
+			Timestamp:  block.Timestamp,
+			Difficulty: block.Difficulty,
+		})
+	}
+	return points
+}
+
+// MerkleRoot returns the Merkle root of the transaction IDs, duplicating the
+// last hash on levels with an odd count. An empty list yields 32 zero bytes.
+func MerkleRoot(txs []Transaction) []byte {
+	if len(txs) == 0 {
+		return make([]byte, sha256.Size)
+	}
+
+	level := make([][]byte, len(txs))
+	for i, tx := range txs {
+		sum := sha256.Sum256(tx.ID)
+		level[i] = sum[:]
+	}
+
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		next := make([][]byte, 0, len(level)/2)
+		for i := 0; i < len(level); i += 2 {
+			sum := sha256.Sum256(append(append([]byte{}, level[i]...), level[i+1]...))
+			next = append(next, sum[:])
+		}
+		level = next
+	}
+
+	return level[0]
+}
@@ -0,0 +1,177 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// chainExportMagic identifies a chain export stream
+var chainExportMagic = []byte("BYCCHAIN")
+
+const (
+	// chainExportVersion is the current export stream format version
+	chainExportVersion uint32 = 1
+	// maxExportRecordSize bounds a single encoded block in an export stream
+	maxExportRecordSize = 8 * MaxBlockSize
+)
+
+// ProgressFunc receives the progress of a long operation as units done out of total
+type ProgressFunc func(done, total int64)
+
+// report calls p if it is set
+func (p ProgressFunc) report(done, total int64) {
+	if p != nil {
+		p(done, total)
+	}
+}
+
+// ExportChain writes both chains to w as a portable stream: a magic header and
+// format version followed by one length-prefixed JSON record per block, golden
+// chain first and each chain from genesis to tip.
+func (bc *Blockchain) ExportChain(w io.Writer) error {
+	return bc.ExportChainWithProgress(w, nil)
+}
+
+// ExportChainWithProgress is ExportChain reporting progress after each block
+// written. The chains are copied under bc.mu and written without it, so
+// neither progress nor a slow writer holds up the chain.
+func (bc *Blockchain) ExportChainWithProgress(w io.Writer, progress ProgressFunc) error {
+	bc.mu.RLock()
+	chains := [][]Block{
+		append([]Block(nil), bc.GoldenBlocks...),
+		append([]Block(nil), bc.SilverBlocks...),
+	}
+	bc.mu.RUnlock()
+	return writeChainExport(w, chains, progress)
+}
+
+// exportChain is ExportChain. Callers must hold bc.mu.
+func (bc *Blockchain) exportChain(w io.Writer) error {
+	return writeChainExport(w, [][]Block{bc.GoldenBlocks, bc.SilverBlocks}, nil)
+}
+
+// writeChainExport writes the export stream of chains to w
+func writeChainExport(w io.Writer, chains [][]Block, progress ProgressFunc) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(chainExportMagic); err != nil {
+		return fmt.Errorf("failed to write export header: %v", err)
+	}
+	if err := binary.Write(bw, binary.BigEndian, chainExportVersion); err != nil {
+		return fmt.Errorf("failed to write export header: %v", err)
+	}
+
+	var total, done int64
+	for _, chain := range chains {
+		total += int64(len(chain))
+	}
+	for _, chain := range chains {
+		for _, block := range chain {
+			data, err := json.Marshal(block)
+			if err != nil {
+				return fmt.Errorf("failed to encode block %x: %v", block.Hash, err)
+			}
+			if err := binary.Write(bw, binary.BigEndian, uint32(len(data))); err != nil {
+				return fmt.Errorf("failed to write block %x: %v", block.Hash, err)
+			}
+			if _, err := bw.Write(data); err != nil {
+				return fmt.Errorf("failed to write block %x: %v", block.Hash, err)
+			}
+			done++
+			progress.report(done, total)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ImportChain reads a stream written by ExportChain and applies its blocks via
+// AddBlockBatch. Blocks already on the local chains, such as genesis, are
+// skipped; every other block must extend its chain and pass validation.
+func (bc *Blockchain) ImportChain(r io.Reader) error {
+	return bc.ImportChainWithProgress(r, nil)
+}
+
+// ImportChainWithProgress is ImportChain reporting progress after each block
+// applied, out of the blocks not already on the local chains. Unlike
+// AddBlockBatch it takes bc.mu for one block at a time, and reports progress
+// with the lock released.
+func (bc *Blockchain) ImportChainWithProgress(r io.Reader, progress ProgressFunc) error {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(chainExportMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return fmt.Errorf("failed to read export header: %v", err)
+	}
+	if !bytes.Equal(magic, chainExportMagic) {
+		return errors.New("not a chain export stream")
+	}
+	var version uint32
+	if err := binary.Read(br, binary.BigEndian, &version); err != nil {
+		return fmt.Errorf("failed to read export header: %v", err)
+	}
+	if version != chainExportVersion {
+		return fmt.Errorf("unsupported export version %d", version)
+	}
+
+	var blocks []Block
+	for {
+		var size uint32
+		if err := binary.Read(br, binary.BigEndian, &size); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("failed to read block %d: %v", len(blocks), err)
+		}
+		if size > maxExportRecordSize {
+			return fmt.Errorf("block %d record size %d exceeds maximum %d", len(blocks), size, maxExportRecordSize)
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return fmt.Errorf("failed to read block %d: %v", len(blocks), err)
+		}
+		var block Block
+		if err := json.Unmarshal(data, &block); err != nil {
+			return fmt.Errorf("failed to decode block %d: %v", len(blocks), err)
+		}
+		blocks = append(blocks, block)
+	}
+
+	blocks = bc.unknownBlocks(blocks)
+	for i, block := range blocks {
+		bc.mu.Lock()
+		err := bc.addBlock(block)
+		bc.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("block %d (%x): %v", i, block.Hash, err)
+		}
+		progress.report(int64(i+1), int64(len(blocks)))
+	}
+	return nil
+}
+
+// unknownBlocks filters out blocks already present on their chain
+func (bc *Blockchain) unknownBlocks(blocks []Block) []Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	known := make(map[string]bool)
+	for _, chain := range [][]Block{bc.GoldenBlocks, bc.SilverBlocks} {
+		for _, block := range chain {
+			known[fmt.Sprintf("%s:%x", block.BlockType, block.Hash)] = true
+		}
+	}
+
+	var unknown []Block
+	for _, block := range blocks {
+		if !known[fmt.Sprintf("%s:%x", block.BlockType, block.Hash)] {
+			unknown = append(unknown, block)
+		}
+	}
+	return unknown
+}
@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// FeeEstimateWindow is how many of the latest blocks EstimateSmartFee
+	// draws fee rates from
+	FeeEstimateWindow = 24
+	// DefaultFeeEstimateTarget is the confirmation target, in blocks, used
+	// when none is given
+	DefaultFeeEstimateTarget = 6
+)
+
+// EstimateSmartFee estimates the fee rate, per virtual byte, a transaction
+// needs to confirm within blocks blocks. It pools the fee rates paid in the
+// last FeeEstimateWindow blocks and returns the one at the 100/(blocks+1)
+// percentile: the median for the next block and ever lower rates for longer
+// targets. The estimate never falls below the minimum
+// relay fee rate, which is also the estimate while recent blocks carry no
+// fee paying transactions.
+func (bc *Blockchain) EstimateSmartFee(blocks int) (float64, error) {
+	if blocks < 1 || blocks > FeeEstimateWindow {
+		return 0, fmt.Errorf("confirmation target must be between 1 and %d blocks, got %d", FeeEstimateWindow, blocks)
+	}
+
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	floor := bc.params.MinRelayFeeRate
+	rates := bc.recentFeeRates(FeeEstimateWindow)
+	if len(rates) == 0 {
+		return floor, nil
+	}
+
+	// rates is sorted ascending, so a longer target reaches less far up them
+	percentile := 1 / float64(blocks+1)
+	i := int(math.Ceil(percentile*float64(len(rates)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return math.Max(rates[i], floor), nil
+}
+
+// recentFeeRates returns, in ascending order, the fee rates of the
+// transactions in the latest window blocks across both chains. Coinbase and
+// genesis transactions pay no fee and are left out. Callers must hold bc.mu.
+func (bc *Blockchain) recentFeeRates(window int) []float64 {
+	var recent []Block
+	for _, chain := range [][]Block{bc.GoldenBlocks, bc.SilverBlocks} {
+		if len(chain) > window {
+			chain = chain[len(chain)-window:]
+		}
+		recent = append(recent, chain...)
+	}
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].Timestamp > recent[j].Timestamp })
+	if len(recent) > window {
+		recent = recent[:window]
+	}
+
+	var rates []float64
+	for _, block := range recent {
+		for _, tx := range block.Transactions {
+			if len(tx.Inputs) == 0 || tx.IsCoinbase() {
+				continue
+			}
+			rates = append(rates, tx.FeeRate())
+		}
+	}
+	sort.Float64s(rates)
+	return rates
+}
@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"fmt"
+	"time"
+
+	"byc/internal/interfaces"
+)
+
+// Component health states reported by CheckSystemHealth
+const (
+	HealthStatusHealthy  = "healthy"
+	HealthStatusDegraded = "degraded"
+	HealthStatusDown     = "down"
+	HealthStatusUnknown  = "unknown"
+)
+
+// Health thresholds for disk space and file descriptors
+const (
+	// DiskDegradedFreePercent marks the disk degraded below this much free space
+	DiskDegradedFreePercent = 10.0
+	// DiskDownFreePercent marks the disk down below this much free space
+	DiskDownFreePercent = 2.0
+	// FDDegradedUsedPercent marks file descriptors degraded above this usage
+	FDDegradedUsedPercent = 80.0
+	// FDDownUsedPercent marks file descriptors down above this usage
+	FDDownUsedPercent = 95.0
+)
+
+// DiskUsage describes the space on the filesystem holding a directory
+type DiskUsage struct {
+	Total uint64
+	Free  uint64
+}
+
+// FDUsage describes the open file descriptors of the process
+type FDUsage struct {
+	Open  uint64
+	Limit uint64
+}
+
+// statDisk and statFDs are replaced in tests to simulate resource exhaustion
+var (
+	statDisk = diskUsage
+	statFDs  = fdUsage
+)
+
+// checkDiskHealth reports the free space on the filesystem holding dir
+func checkDiskHealth(dir string, now time.Time) interfaces.ComponentHealth {
+	usage, err := statDisk(dir)
+	if err != nil {
+		return interfaces.ComponentHealth{
+			Status:    HealthStatusUnknown,
+			Message:   fmt.Sprintf("failed to stat %s", dir),
+			LastCheck: now,
+			Error:     err,
+		}
+	}
+	if usage.Total == 0 {
+		return interfaces.ComponentHealth{
+			Status:    HealthStatusUnknown,
+			Message:   fmt.Sprintf("filesystem for %s reports zero size", dir),
+			LastCheck: now,
+		}
+	}
+
+	freePercent := float64(usage.Free) / float64(usage.Total) * 100
+	component := interfaces.ComponentHealth{
+		Status: HealthStatusHealthy,
+		Message: fmt.Sprintf("%.1f%% free (%d of %d MB) on %s",
+			freePercent, usage.Free>>20, usage.Total>>20, dir),
+		LastCheck: now,
+	}
+
+	switch {
+	case freePercent < DiskDownFreePercent:
+		component.Status = HealthStatusDown
+		component.Error = fmt.Errorf("disk space critical: %s", component.Message)
+	case freePercent < DiskDegradedFreePercent:
+		component.Status = HealthStatusDegraded
+		component.Error = fmt.Errorf("disk space low: %s", component.Message)
+	}
+
+	return component
+}
+
+// checkFDHealth reports how many of the process's file descriptors are in use
+func checkFDHealth(now time.Time) interfaces.ComponentHealth {
+	usage, err := statFDs()
+	if err != nil {
+		return interfaces.ComponentHealth{
+			Status:    HealthStatusUnknown,
+			Message:   "failed to read file descriptor usage",
+			LastCheck: now,
+			Error:     err,
+		}
+	}
+	if usage.Limit == 0 {
+		return interfaces.ComponentHealth{
+			Status:    HealthStatusHealthy,
+			Message:   fmt.Sprintf("%d open, no limit", usage.Open),
+			LastCheck: now,
+		}
+	}
+
+	usedPercent := float64(usage.Open) / float64(usage.Limit) * 100
+	component := interfaces.ComponentHealth{
+		Status:    HealthStatusHealthy,
+		Message:   fmt.Sprintf("%d of %d open (%.1f%%)", usage.Open, usage.Limit, usedPercent),
+		LastCheck: now,
+	}
+
+	switch {
+	case usedPercent >= FDDownUsedPercent:
+		component.Status = HealthStatusDown
+		component.Error = fmt.Errorf("file descriptors critical: %s", component.Message)
+	case usedPercent >= FDDegradedUsedPercent:
+		component.Status = HealthStatusDegraded
+		component.Error = fmt.Errorf("file descriptors high: %s", component.Message)
+	}
+
+	return component
+}
+
+// healthSeverity orders health states so the worst component wins
+func healthSeverity(status string) int {
+	switch status {
+	case HealthStatusDown:
+		return 3
+	case HealthStatusDegraded:
+		return 2
+	case HealthStatusUnknown:
+		return 1
+	default:
+		return 0
+	}
+}
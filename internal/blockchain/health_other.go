@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package blockchain
+
+import (
+	"errors"
+	"runtime"
+)
+
+// diskUsage is not supported on this platform
+func diskUsage(dir string) (DiskUsage, error) {
+	return DiskUsage{}, errors.New("disk usage check not supported on " + runtime.GOOS)
+}
+
+// fdUsage is not supported on this platform
+func fdUsage() (FDUsage, error) {
+	return FDUsage{}, errors.New("file descriptor check not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,40 @@
+//go:build linux || darwin
+
+package blockchain
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// diskUsage returns the total and available space on the filesystem holding dir
+func diskUsage(dir string) (DiskUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return DiskUsage{}, err
+	}
+	return DiskUsage{
+		Total: uint64(st.Blocks) * uint64(st.Bsize),
+		Free:  uint64(st.Bavail) * uint64(st.Bsize),
+	}, nil
+}
+
+// fdUsage counts the process's open file descriptors against its soft limit
+func fdUsage() (FDUsage, error) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return FDUsage{}, err
+	}
+
+	fdDir := "/proc/self/fd"
+	if runtime.GOOS == "darwin" {
+		fdDir = "/dev/fd"
+	}
+	entries, err := os.ReadDir(fdDir)
+	if err != nil {
+		return FDUsage{}, err
+	}
+
+	return FDUsage{Open: uint64(len(entries)), Limit: uint64(limit.Cur)}, nil
+}
@@ -0,0 +1,51 @@
+package blockchain
+
+// chainIndex maps the hashes of the connected blocks, and the IDs of the
+// transactions they confirm, so the node can tell whether it holds an item
+// without scanning its chains. The zero value is ready to use.
+type chainIndex struct {
+	blocks map[string]bool
+	// txs counts the connected blocks confirming each transaction; a
+	// coinbase can repeat across blocks
+	txs map[string]int
+}
+
+// add indexes b and its transactions
+func (ix *chainIndex) add(b *Block) {
+	if ix.blocks == nil {
+		ix.blocks = make(map[string]bool)
+		ix.txs = make(map[string]int)
+	}
+	ix.blocks[string(b.Hash)] = true
+	for i := range b.Transactions {
+		ix.txs[string(b.Transactions[i].ID)]++
+	}
+}
+
+// remove drops b and its transactions from the index
+func (ix *chainIndex) remove(b *Block) {
+	delete(ix.blocks, string(b.Hash))
+	for i := range b.Transactions {
+		id := string(b.Transactions[i].ID)
+		if ix.txs[id] <= 1 {
+			delete(ix.txs, id)
+		} else {
+			ix.txs[id]--
+		}
+	}
+}
+
+// HasBlock reports whether the block with hash is connected to either chain
+func (bc *Blockchain) HasBlock(hash []byte) bool {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.index.blocks[string(hash)]
+}
+
+// HasTransaction reports whether the transaction with id is confirmed in a
+// connected block of either chain
+func (bc *Blockchain) HasTransaction(id []byte) bool {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.index.txs[string(id)] > 0
+}
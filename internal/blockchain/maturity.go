@@ -0,0 +1,49 @@
+package blockchain
+
+import "sort"
+
+// MaturityInfo describes a coinbase output that cannot be spent yet
+type MaturityInfo struct {
+	UTXO UTXO
+	// Confirmations is how many blocks have confirmed the output so far
+	Confirmations uint64
+	// MaturityHeight is the height of the first block on the output's chain
+	// that may spend it
+	MaturityHeight uint64
+	// BlocksRemaining is how many more blocks are needed until it can be spent
+	BlocksRemaining uint64
+}
+
+// GetCoinbaseMaturityInfo returns the immature coinbase outputs of address,
+// soonest to mature first
+func (bc *Blockchain) GetCoinbaseMaturityInfo(address string) ([]MaturityInfo, error) {
+	bc.mu.RLock()
+	params, heights := bc.params, bc.tipHeights()
+	bc.mu.RUnlock()
+
+	utxos, err := bc.UTXOSet.GetUTXOs(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []MaturityInfo
+	for _, utxo := range utxos {
+		if !utxo.IsCoinbase || utxo.Spent {
+			continue
+		}
+		remaining := params.BlocksUntilSpendable(utxo, heights.Of(utxo))
+		if remaining == 0 {
+			continue
+		}
+		infos = append(infos, MaturityInfo{
+			UTXO:            utxo,
+			Confirmations:   utxo.Confirmations(heights.Of(utxo)),
+			MaturityHeight:  utxo.Height + params.CoinbaseMaturity,
+			BlocksRemaining: remaining,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].MaturityHeight < infos[j].MaturityHeight
+	})
+	return infos, nil
+}
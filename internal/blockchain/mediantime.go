@@ -0,0 +1,48 @@
+package blockchain
+
+import (
+	"errors"
+	"sort"
+)
+
+// MedianTimeSpan is the number of blocks whose timestamps make up the median
+// time past
+const MedianTimeSpan = 11
+
+// MaxFutureBlockTime is how many seconds past the local clock a block may be
+// timestamped
+const MaxFutureBlockTime = 60
+
+// ErrTimestampAhead is returned when building a block whose timestamp must be
+// later than MaxFutureBlockTime allows. Blocks mined faster than one a second
+// push the median time past ahead of the clock; mining resumes once the clock
+// catches up.
+var ErrTimestampAhead = errors.New("median time past is too far ahead of the clock")
+
+// MedianTimePast returns the median timestamp of the last MedianTimeSpan
+// blocks of blockType's chain, or 0 if the chain is empty. A new block must be
+// timestamped after it. Unlike the parent's timestamp alone, the median cannot
+// be dragged by one miner setting a single block's clock.
+func (bc *Blockchain) MedianTimePast(blockType BlockType) int64 {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.medianTimePast(blockType)
+}
+
+// medianTimePast is MedianTimePast for callers holding bc.mu
+func (bc *Blockchain) medianTimePast(blockType BlockType) int64 {
+	chain := bc.chain(blockType)
+	if len(chain) == 0 {
+		return 0
+	}
+	if len(chain) > MedianTimeSpan {
+		chain = chain[len(chain)-MedianTimeSpan:]
+	}
+
+	timestamps := make([]int64, len(chain))
+	for i, b := range chain {
+		timestamps[i] = b.Timestamp
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2]
+}
@@ -0,0 +1,623 @@
+package blockchain
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ErrFeeTooLow is returned when a transaction pays less than the minimum relay fee rate
+var ErrFeeTooLow = errors.New("fee below minimum relay fee rate")
+
+// MempoolFile is the file name used to persist pending transactions in a data dir
+const MempoolFile = "mempool.json"
+
+// MempoolConfig holds the pending pool's limits on chains of unconfirmed transactions
+type MempoolConfig struct {
+	// Maximum number of pending ancestors of a transaction, itself included
+	MaxAncestorCount int
+	// Maximum total virtual size of a transaction and its pending ancestors
+	MaxAncestorSize int
+	// Maximum number of pending descendants of a transaction, itself included
+	MaxDescendantCount int
+	// Maximum total virtual size of a transaction and its pending descendants
+	MaxDescendantSize int
+	// Maximum number of pending transactions queued for each chain, so a
+	// flood on one chain cannot crowd out the other
+	MaxQueueSize int
+}
+
+// NewMempoolConfig creates a new mempool configuration
+func NewMempoolConfig() *MempoolConfig {
+	return &MempoolConfig{
+		MaxAncestorCount:   25,
+		MaxAncestorSize:    101000,
+		MaxDescendantCount: 25,
+		MaxDescendantSize:  101000,
+		MaxQueueSize:       50000,
+	}
+}
+
+// Chain returns the chain tx belongs to, whose mempool queue holds it: its
+// block type, or the chain of the coin it pays when it has none
+func (tx *Transaction) Chain() BlockType {
+	blockType := tx.BlockType
+	if blockType == "" && len(tx.Outputs) > 0 {
+		blockType = GetBlockType(tx.Outputs[0].CoinType)
+	}
+	if blockType == SilverBlock {
+		return SilverBlock
+	}
+	return GoldenBlock
+}
+
+// pending returns every pending transaction, the golden queue first. Callers must hold bc.mu.
+func (bc *Blockchain) pending() []Transaction {
+	golden, silver := bc.PendingTxs[GoldenBlock], bc.PendingTxs[SilverBlock]
+	pending := make([]Transaction, 0, len(golden)+len(silver))
+	pending = append(pending, golden...)
+	return append(pending, silver...)
+}
+
+// setPending replaces the pending pool with txs, queued by chain in their
+// order. Callers must hold bc.mu.
+func (bc *Blockchain) setPending(txs []Transaction) {
+	bc.PendingTxs = make(map[BlockType][]Transaction)
+	for _, tx := range txs {
+		bc.enqueue(tx)
+	}
+}
+
+// enqueue appends tx to its chain's queue. Callers must hold bc.mu.
+func (bc *Blockchain) enqueue(tx Transaction) {
+	chain := tx.Chain()
+	bc.PendingTxs[chain] = append(bc.PendingTxs[chain], tx)
+}
+
+// queueFull reports whether chain's pending queue holds the most transactions
+// the mempool configuration allows. Callers must hold bc.mu.
+func (bc *Blockchain) queueFull(chain BlockType) bool {
+	limits := bc.MempoolConfig
+	return limits != nil && limits.MaxQueueSize > 0 && len(bc.PendingTxs[chain]) >= limits.MaxQueueSize
+}
+
+// PendingTransactions returns a copy of the queue of transactions pending
+// for blockType's chain
+func (bc *Blockchain) PendingTransactions(blockType BlockType) []Transaction {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return append([]Transaction(nil), bc.PendingTxs[blockType]...)
+}
+
+// GetPendingTransaction returns a copy of the pending transaction with id
+func (bc *Blockchain) GetPendingTransaction(id []byte) (*Transaction, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	for _, queue := range bc.PendingTxs {
+		for i := range queue {
+			if bytes.Equal(queue[i].ID, id) {
+				return queue[i].Copy(), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("transaction not found")
+}
+
+// mempoolView returns the UTXO set tx should be validated against: the
+// confirmed set, overlaid with pending outputs when tx spends any of them.
+// Callers must hold bc.mu.
+func (bc *Blockchain) mempoolView(tx Transaction) *UTXOSet {
+	pending := bc.pending()
+	parents := pendingParents(tx, pendingIndex(pending))
+	if len(parents) == 0 {
+		return bc.UTXOSet
+	}
+
+	view := bc.UTXOSet.Clone()
+	for _, ptx := range pending {
+		view.AddOutputs(&ptx, bc.nextHeight(ptx.Chain()))
+	}
+	return view
+}
+
+// pendingIndex maps the hex ID of each transaction in pending to its index
+func pendingIndex(pending []Transaction) map[string]int {
+	index := make(map[string]int, len(pending))
+	for i, ptx := range pending {
+		index[fmt.Sprintf("%x", ptx.ID)] = i
+	}
+	return index
+}
+
+// pendingParents returns the indexes of pending transactions whose outputs tx spends
+func pendingParents(tx Transaction, index map[string]int) []int {
+	var parents []int
+	seen := make(map[int]bool)
+	for _, input := range tx.Inputs {
+		if i, ok := index[fmt.Sprintf("%x", input.TxID)]; ok && !seen[i] {
+			seen[i] = true
+			parents = append(parents, i)
+		}
+	}
+	return parents
+}
+
+// pendingAncestors returns the indexes of the pending transactions tx spends
+// from, directly or through other pending transactions
+func pendingAncestors(tx Transaction, pending []Transaction, index map[string]int) map[int]bool {
+	ancestors := make(map[int]bool)
+	queue := pendingParents(tx, index)
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		if ancestors[i] {
+			continue
+		}
+		ancestors[i] = true
+		queue = append(queue, pendingParents(pending[i], index)...)
+	}
+	return ancestors
+}
+
+// outpointKey names output index of the transaction with id
+func outpointKey(id []byte, index int) string {
+	return fmt.Sprintf("%x:%d", id, index)
+}
+
+// withDescendants returns the indexes of roots and of every pending
+// transaction spending their outputs, directly or through other pending
+// transactions. The order of pending plays no part: it holds the golden
+// queue before the silver one, so a child may come before its parent.
+func withDescendants(pending []Transaction, roots []int) map[int]bool {
+	spenders := make(map[string][]int)
+	for i, ptx := range pending {
+		for _, input := range ptx.Inputs {
+			key := outpointKey(input.TxID, input.OutputIndex)
+			spenders[key] = append(spenders[key], i)
+		}
+	}
+
+	found := make(map[int]bool)
+	queue := append([]int(nil), roots...)
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		if found[i] {
+			continue
+		}
+		found[i] = true
+		for out := range pending[i].Outputs {
+			queue = append(queue, spenders[outpointKey(pending[i].ID, out)]...)
+		}
+	}
+	return found
+}
+
+// withoutDescendants returns pending less the transactions evict selects and
+// their descendants, which could not be mined without them, along with the
+// indexes of everything removed
+func withoutDescendants(pending []Transaction, evict func(int, Transaction) bool) ([]Transaction, []int) {
+	var roots []int
+	for i, ptx := range pending {
+		if evict(i, ptx) {
+			roots = append(roots, i)
+		}
+	}
+	evicted := withDescendants(pending, roots)
+
+	var removed []int
+	kept := make([]Transaction, 0, len(pending))
+	for i, ptx := range pending {
+		if evicted[i] {
+			removed = append(removed, i)
+			continue
+		}
+		kept = append(kept, ptx)
+	}
+	return kept, removed
+}
+
+// transactionIDs returns the IDs of the transactions at indexes in txs
+func transactionIDs(txs []Transaction, indexes []int) [][]byte {
+	ids := make([][]byte, len(indexes))
+	for i, index := range indexes {
+		ids[i] = txs[index].ID
+	}
+	return ids
+}
+
+// evictionCandidate returns the index in pending of the transaction a full
+// queue gives up to make room for tx: the lowest fee rate in tx's chain
+// queue that tx does not spend from
+func evictionCandidate(pending []Transaction, tx Transaction) (int, bool) {
+	ancestors := pendingAncestors(tx, pending, pendingIndex(pending))
+	chain := tx.Chain()
+	lowest := -1
+	for i, ptx := range pending {
+		if ptx.Chain() != chain || ancestors[i] {
+			continue
+		}
+		if lowest < 0 || ptx.FeeRate() < pending[lowest].FeeRate() {
+			lowest = i
+		}
+	}
+	return lowest, lowest >= 0
+}
+
+// MempoolDependencies maps the hex ID of each pending transaction to the hex
+// IDs of the pending transactions whose outputs it spends. Transactions
+// spending only confirmed outputs map to an empty list.
+func (bc *Blockchain) MempoolDependencies() map[string][]string {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	pending := bc.pending()
+	index := pendingIndex(pending)
+	deps := make(map[string][]string, len(pending))
+	for _, tx := range pending {
+		parents := []string{}
+		for _, i := range pendingParents(tx, index) {
+			parents = append(parents, fmt.Sprintf("%x", pending[i].ID))
+		}
+		deps[fmt.Sprintf("%x", tx.ID)] = parents
+	}
+	return deps
+}
+
+// checkPackageLimits rejects tx if it, or any of its pending ancestors, would
+// exceed the configured ancestor or descendant limits. Callers must hold bc.mu.
+func (bc *Blockchain) checkPackageLimits(tx Transaction) error {
+	limits := bc.MempoolConfig
+	if limits == nil {
+		return nil
+	}
+	pending := bc.pending()
+	index := pendingIndex(pending)
+
+	ancestors := pendingAncestors(tx, pending, index)
+	count, size := 1, tx.VirtualSize()
+	for i := range ancestors {
+		count++
+		size += pending[i].VirtualSize()
+	}
+	if count > limits.MaxAncestorCount {
+		return &ValidationError{
+			Field:  "ancestors",
+			Reason: fmt.Sprintf("%d pending ancestors including itself exceeds limit %d", count, limits.MaxAncestorCount),
+		}
+	}
+	if size > limits.MaxAncestorSize {
+		return &ValidationError{
+			Field:  "ancestors",
+			Reason: fmt.Sprintf("ancestor package size %d exceeds limit %d", size, limits.MaxAncestorSize),
+		}
+	}
+
+	if len(ancestors) == 0 {
+		return nil
+	}
+
+	// Each ancestor gains tx as a descendant
+	children := make(map[int][]int)
+	for j, ptx := range pending {
+		for _, parent := range pendingParents(ptx, index) {
+			children[parent] = append(children[parent], j)
+		}
+	}
+	for a := range ancestors {
+		descendants := make(map[int]bool)
+		queue := []int{a}
+		for len(queue) > 0 {
+			i := queue[0]
+			queue = queue[1:]
+			if descendants[i] {
+				continue
+			}
+			descendants[i] = true
+			queue = append(queue, children[i]...)
+		}
+
+		count, size := 1, tx.VirtualSize()
+		for i := range descendants {
+			count++
+			size += pending[i].VirtualSize()
+		}
+		if count > limits.MaxDescendantCount {
+			return &ValidationError{
+				Field:  "descendants",
+				Reason: fmt.Sprintf("pending transaction %x would have %d descendants including itself, exceeding limit %d", pending[a].ID, count, limits.MaxDescendantCount),
+			}
+		}
+		if size > limits.MaxDescendantSize {
+			return &ValidationError{
+				Field:  "descendants",
+				Reason: fmt.Sprintf("pending transaction %x descendant package size %d exceeds limit %d", pending[a].ID, size, limits.MaxDescendantSize),
+			}
+		}
+	}
+
+	return nil
+}
+
+// SelectTransactions builds the transaction list for a new block: coinbase
+// followed by fee-paying transactions from blockType's pending queue, highest
+// fee rate first, that fit within the chain's maximum block size. A
+// transaction is only selected after its pending parents, so children never
+// precede the outputs they spend.
+func (bc *Blockchain) SelectTransactions(blockType BlockType, coinbase Transaction) []Transaction {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	selected := []Transaction{coinbase}
+	budget := bc.params.MaxBlockSizeFor(blockType) - bc.calculateBlockSize(Block{
+		Transactions: selected,
+		BlockType:    blockType,
+		MerkleRoot:   make([]byte, sha256.Size),
+	})
+
+	// Parents are looked up across both queues, so a transaction whose parent
+	// is pending on the other chain waits for it. A transaction is ready once
+	// none of its pending parents is still waiting to be selected.
+	pending := bc.pending()
+	index := pendingIndex(pending)
+	candidate := make([]bool, len(pending))
+	waiting := make([]int, len(pending))
+	children := make(map[int][]int)
+	ready := &feeRateHeap{rates: make([]float64, len(pending))}
+	for i, ptx := range pending {
+		ready.rates[i] = ptx.FeeRate()
+		candidate[i] = ptx.Chain() == blockType && ptx.GetFee() > 0
+		parents := pendingParents(ptx, index)
+		waiting[i] = len(parents)
+		for _, parent := range parents {
+			children[parent] = append(children[parent], i)
+		}
+		if candidate[i] && waiting[i] == 0 {
+			ready.items = append(ready.items, i)
+		}
+	}
+	heap.Init(ready)
+
+	for ready.Len() > 0 {
+		i := heap.Pop(ready).(int)
+		size := int64(pending[i].VirtualSize())
+		if size > budget {
+			continue
+		}
+		budget -= size
+		selected = append(selected, pending[i])
+		for _, child := range children[i] {
+			waiting[child]--
+			if candidate[child] && waiting[child] == 0 {
+				heap.Push(ready, child)
+			}
+		}
+	}
+
+	return selected
+}
+
+// feeRateHeap orders pending transactions, by index, highest fee rate first
+// and in pool order among equal rates
+type feeRateHeap struct {
+	rates []float64
+	items []int
+}
+
+func (h *feeRateHeap) Len() int { return len(h.items) }
+
+func (h *feeRateHeap) Less(a, b int) bool {
+	i, j := h.items[a], h.items[b]
+	if h.rates[i] != h.rates[j] {
+		return h.rates[i] > h.rates[j]
+	}
+	return i < j
+}
+
+func (h *feeRateHeap) Swap(a, b int) { h.items[a], h.items[b] = h.items[b], h.items[a] }
+
+func (h *feeRateHeap) Push(x any) { h.items = append(h.items, x.(int)) }
+
+func (h *feeRateHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// feeHistogramBands are the lower bounds of the fee histogram buckets, in
+// multiples of the minimum relay fee rate
+var feeHistogramBands = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// FeeBucket counts the pending transactions paying a fee rate in
+// [MinFeeRate, MaxFeeRate). The highest bucket has no upper bound and a
+// MaxFeeRate of 0; the lowest also holds anything below its MinFeeRate.
+type FeeBucket struct {
+	MinFeeRate float64 `json:"min_fee_rate"`
+	MaxFeeRate float64 `json:"max_fee_rate"`
+	Count      int     `json:"count"`
+	VSize      int64   `json:"vsize"`
+	// CumulativeVSize is the virtual size of this bucket and every higher
+	// paying one: how much block space is claimed ahead of a new
+	// transaction at MinFeeRate
+	CumulativeVSize int64 `json:"cumulative_vsize"`
+}
+
+// MempoolFeeHistogram buckets the pending transactions by fee rate, highest
+// fee rate first. Bands are multiples of the minimum relay fee rate, or of
+// DefaultMinRelayFeeRate on networks that relay free transactions.
+func (bc *Blockchain) MempoolFeeHistogram() []FeeBucket {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	unit := bc.params.MinRelayFeeRate
+	if unit <= 0 {
+		unit = DefaultMinRelayFeeRate
+	}
+
+	buckets := make([]FeeBucket, len(feeHistogramBands))
+	for i := range buckets {
+		band := feeHistogramBands[len(feeHistogramBands)-1-i]
+		buckets[i].MinFeeRate = band * unit
+		if i > 0 {
+			buckets[i].MaxFeeRate = buckets[i-1].MinFeeRate
+		}
+	}
+
+	for _, tx := range bc.pending() {
+		rate := tx.FeeRate()
+		i := sort.Search(len(buckets), func(i int) bool { return rate >= buckets[i].MinFeeRate })
+		if i == len(buckets) {
+			i--
+		}
+		buckets[i].Count++
+		buckets[i].VSize += int64(tx.VirtualSize())
+	}
+
+	var cumulative int64
+	for i := range buckets {
+		cumulative += buckets[i].VSize
+		buckets[i].CumulativeVSize = cumulative
+	}
+	return buckets
+}
+
+// RemoveTransactionsFromMempool drops txs from the pending pool along with
+// any pending transaction that double spends one of their inputs or has
+// expired before the next block, and the descendants of those. It returns
+// the number of transactions removed.
+func (bc *Blockchain) RemoveTransactionsFromMempool(txs []Transaction) int {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.removeFromMempool(txs)
+}
+
+// removeFromMempool is RemoveTransactionsFromMempool for callers holding bc.mu
+func (bc *Blockchain) removeFromMempool(txs []Transaction) int {
+	included := make(map[string]bool, len(txs))
+	spent := make(map[string]bool)
+	for _, tx := range txs {
+		included[string(tx.ID)] = true
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, input := range tx.Inputs {
+			spent[outpointKey(input.TxID, input.OutputIndex)] = true
+		}
+	}
+
+	// Drop the pending transactions that conflict with txs or have
+	// expired, and everything spending from them
+	all := bc.pending()
+	var conflicts []int
+	for i, ptx := range all {
+		if included[string(ptx.ID)] {
+			continue
+		}
+		conflict := ptx.ExpiredAt(bc.nextHeight(ptx.Chain()))
+		for _, input := range ptx.Inputs {
+			if spent[outpointKey(input.TxID, input.OutputIndex)] {
+				conflict = true
+				break
+			}
+		}
+		if conflict {
+			conflicts = append(conflicts, i)
+		}
+	}
+	conflicted := withDescendants(all, conflicts)
+
+	pending := make([]Transaction, 0, len(all))
+	for i, ptx := range all {
+		if included[string(ptx.ID)] || conflicted[i] {
+			continue
+		}
+		pending = append(pending, ptx)
+	}
+
+	removed := len(all) - len(pending)
+	bc.setPending(pending)
+	return removed
+}
+
+// PruneMempool evicts the pending transactions paying less than minFeeRate
+// per virtual byte, along with their descendants, which could not be mined
+// without them. It returns the number of transactions evicted.
+func (bc *Blockchain) PruneMempool(minFeeRate float64) int {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.pruneMempool(minFeeRate)
+}
+
+// pruneMempool is PruneMempool for callers holding bc.mu
+func (bc *Blockchain) pruneMempool(minFeeRate float64) int {
+	pending, evicted := withoutDescendants(bc.pending(), func(_ int, ptx Transaction) bool {
+		return ptx.FeeRate() < minFeeRate
+	})
+	if len(evicted) > 0 {
+		bc.setPending(pending)
+	}
+	return len(evicted)
+}
+
+// SaveMempool writes the pending transactions to path so they survive a restart
+func (bc *Blockchain) SaveMempool(path string) error {
+	bc.mu.RLock()
+	pending := bc.pending()
+	bc.mu.RUnlock()
+
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("failed to encode mempool: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create mempool directory: %v", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a torn mempool
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write mempool: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write mempool: %v", err)
+	}
+
+	return nil
+}
+
+// LoadMempool restores pending transactions saved by SaveMempool. Each one is
+// revalidated against the current UTXO set and pending pool; transactions that
+// are no longer valid, such as those double spent by a block mined while the
+// node was down, are dropped. A missing file is not an error.
+func (bc *Blockchain) LoadMempool(path string) (restored int, dropped int, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, fmt.Errorf("failed to read mempool: %v", err)
+	}
+
+	var pending []Transaction
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode mempool: %v", err)
+	}
+
+	for _, tx := range pending {
+		if err := bc.AddTransaction(tx); err != nil {
+			dropped++
+			continue
+		}
+		restored++
+	}
+
+	return restored, dropped, nil
+}
@@ -3,9 +3,12 @@ package blockchain
 import (
 	"crypto/sha256"
 	"encoding/binary"
-	"math"
+	"errors"
+	"fmt"
 	"math/big"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,8 +32,8 @@ type MiningConfig struct {
 	MaxNonce uint64
 	// Target block time
 	TargetBlockTime time.Duration
-	// Difficulty window
-	DifficultyWindow int
+	// Per-chain target block time, overriding TargetBlockTime
+	TargetBlockTimes map[BlockType]time.Duration
 	// Maximum difficulty
 	MaxDifficulty int
 	// Minimum difficulty
@@ -43,8 +46,18 @@ type MiningConfig struct {
 	PoolFee float64
 	// Pool minimum payout
 	PoolMinPayout float64
+	// MiningTimeout bounds how long MineBlock searches for a nonce before
+	// giving up with ErrMiningTimeout; zero searches until one is found
+	MiningTimeout time.Duration
 }
 
+// DefaultMiningTimeout is how long MineBlock searches for a nonce by default
+const DefaultMiningTimeout = 5 * time.Minute
+
+// ErrMiningTimeout is returned when MineBlock finds no valid nonce within the
+// configured MiningTimeout
+var ErrMiningTimeout = errors.New("mining timed out")
+
 // MiningPool represents a mining pool
 type MiningPool struct {
 	ID            string
@@ -79,8 +92,13 @@ type Miner struct {
 	config MiningConfig
 	// Mining statistics
 	stats MiningStats
-	// Channel for stopping mining
+	// Channel for stopping mining, closed once by Stop or the first worker to find a block
 	stopChan chan struct{}
+	stopOnce *sync.Once
+	// Whether a worker found a valid nonce in the current run
+	found bool
+	// Number of workers still running
+	active int32
 	// Wait group for worker synchronization
 	wg sync.WaitGroup
 	// Mutex for thread-safe stats updates
@@ -102,17 +120,52 @@ type Miner struct {
 // NewMiningConfig creates a new mining configuration
 func NewMiningConfig() *MiningConfig {
 	return &MiningConfig{
-		TargetBlockTime:  10 * time.Minute,
-		DifficultyWindow: 2016, // Similar to Bitcoin
+		TargetBlockTime: 10 * time.Minute,
+		TargetBlockTimes: map[BlockType]time.Duration{
+			GoldenBlock: 10 * time.Minute,
+			SilverBlock: 10 * time.Minute,
+		},
 		MaxDifficulty:    32,
 		MinDifficulty:    1,
 		AdjustmentFactor: 0.25, // 25% adjustment per window
 		PoolShare:        0.95, // 95% to miners, 5% to pool
 		PoolFee:          0.05, // 5% pool fee
 		PoolMinPayout:    0.1,  // Minimum payout in base coin
+		MiningTimeout:    DefaultMiningTimeout,
 	}
 }
 
+// TargetBlockTimeFor returns the target block time for a chain
+func (c *MiningConfig) TargetBlockTimeFor(blockType BlockType) time.Duration {
+	if target, ok := c.TargetBlockTimes[blockType]; ok && target > 0 {
+		return target
+	}
+	return c.TargetBlockTime
+}
+
+// SetTargetBlockTime sets the target block time for a chain. It is not safe
+// to call once the config is in use by a blockchain; use
+// Blockchain.SetTargetBlockTime instead.
+func (c *MiningConfig) SetTargetBlockTime(blockType BlockType, target time.Duration) error {
+	if target <= 0 {
+		return fmt.Errorf("invalid target block time %s for %s chain", target, blockType)
+	}
+	if c.TargetBlockTimes == nil {
+		c.TargetBlockTimes = make(map[BlockType]time.Duration)
+	}
+	c.TargetBlockTimes[blockType] = target
+	return nil
+}
+
+// SetTargetBlockTime sets the target block time the chain's difficulty
+// retargets toward, under the lock CalculateDifficulty reads it with
+func (bc *Blockchain) SetTargetBlockTime(blockType BlockType, target time.Duration) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.retargets.reset()
+	return bc.MiningConfig.SetTargetBlockTime(blockType, target)
+}
+
 // NewMiningPool creates a new mining pool
 func NewMiningPool(id, address string) *MiningPool {
 	return &MiningPool{
@@ -151,6 +204,20 @@ func (p *MiningPool) RemoveMiner(id string) {
 	delete(p.Miners, id)
 }
 
+// Stop halts every miner in the pool and waits for their workers to return
+func (p *MiningPool) Stop() {
+	p.mu.RLock()
+	miners := make([]*Miner, 0, len(p.Miners))
+	for _, miner := range p.Miners {
+		miners = append(miners, miner)
+	}
+	p.mu.RUnlock()
+
+	for _, miner := range miners {
+		miner.Stop()
+	}
+}
+
 // UpdateMinerStats updates a miner's statistics
 func (p *MiningPool) UpdateMinerStats(id string, hashrate float64, shares float64) {
 	p.mu.Lock()
@@ -164,42 +231,62 @@ func (p *MiningPool) UpdateMinerStats(id string, hashrate float64, shares float6
 	}
 }
 
-// CalculateDifficulty calculates the new difficulty based on recent block times
+// CalculateDifficulty returns the difficulty, before the mined coin's
+// multiplier, the next blockType block must be mined at
 func (bc *Blockchain) CalculateDifficulty(blockType BlockType) int {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
+	return bc.nextDifficulty(blockType)
+}
 
-	var blocks []Block
-	if blockType == GoldenBlock {
-		blocks = bc.GoldenBlocks
-	} else {
-		blocks = bc.SilverBlocks
+// nextDifficulty is CalculateDifficulty: the chain's starting Difficulty,
+// retargeted from the block times of each RetargetInterval blocks up to the
+// tip. Each retarget is cached by the hash of the block it follows, so only
+// the newest is ever computed. Callers must hold bc.mu.
+func (bc *Blockchain) nextDifficulty(blockType BlockType) int {
+	blocks := bc.chain(blockType)
+	interval := bc.params.RetargetInterval
+	difficulty := bc.Difficulty
+	if interval <= 0 {
+		return difficulty
 	}
 
-	if len(blocks) < 2 {
-		return bc.Difficulty
+	for height := interval; height < len(blocks); height += interval {
+		key := string(blocks[height].Hash)
+		if cached, ok := bc.retargets.get(blockType, key); ok {
+			difficulty = cached
+			continue
+		}
+		// The interval's blocks and the one before them give interval block times
+		difficulty = bc.retarget(blockType, blocks[height-interval:height+1], difficulty)
+		bc.retargets.put(blockType, key, difficulty)
 	}
+	return difficulty
+}
 
-	// Get the last N blocks for difficulty calculation
-	windowSize := int(math.Min(float64(len(blocks)), float64(bc.MiningConfig.DifficultyWindow)))
-	recentBlocks := blocks[len(blocks)-windowSize:]
-
+// retarget adjusts difficulty toward the chain's target block time by the
+// average time between blocks. Callers must hold bc.mu.
+func (bc *Blockchain) retarget(blockType BlockType, blocks []Block, difficulty int) int {
 	// Calculate average block time
 	var totalTime int64
-	for i := 1; i < len(recentBlocks); i++ {
-		totalTime += recentBlocks[i].Timestamp - recentBlocks[i-1].Timestamp
+	for i := 1; i < len(blocks); i++ {
+		totalTime += blocks[i].Timestamp - blocks[i-1].Timestamp
+	}
+	avgBlockTime := float64(totalTime) / float64(len(blocks)-1)
+	if avgBlockTime < 1 {
+		// Timestamps have one-second resolution
+		avgBlockTime = 1
 	}
-	avgBlockTime := float64(totalTime) / float64(len(recentBlocks)-1)
 
-	// Calculate difficulty adjustment
-	targetTime := float64(bc.MiningConfig.TargetBlockTime.Seconds())
+	// Calculate difficulty adjustment toward this chain's cadence
+	targetTime := bc.MiningConfig.TargetBlockTimeFor(blockType).Seconds()
 	adjustment := targetTime / avgBlockTime
 
 	// Apply adjustment factor to prevent large swings
 	adjustment = 1 + (adjustment-1)*bc.MiningConfig.AdjustmentFactor
 
 	// Calculate new difficulty
-	newDifficulty := int(float64(bc.Difficulty) * adjustment)
+	newDifficulty := int(float64(difficulty) * adjustment)
 
 	// Ensure difficulty stays within bounds
 	if newDifficulty < bc.MiningConfig.MinDifficulty {
@@ -211,6 +298,49 @@ func (bc *Blockchain) CalculateDifficulty(blockType BlockType) int {
 	return newDifficulty
 }
 
+// retargetCache holds the difficulty each retarget produced, keyed by chain
+// and the hash of the block it follows. It has its own lock, since
+// nextDifficulty fills it under bc.mu's read lock.
+type retargetCache struct {
+	mu         sync.Mutex
+	difficulty map[BlockType]map[string]int
+}
+
+// get returns the cached retarget after the block with hash key, ignoring
+// blocks without a hash
+func (c *retargetCache) get(blockType BlockType, key string) (int, bool) {
+	if key == "" {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	difficulty, ok := c.difficulty[blockType][key]
+	return difficulty, ok
+}
+
+// put caches the retarget after the block with hash key
+func (c *retargetCache) put(blockType BlockType, key string, difficulty int) {
+	if key == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.difficulty == nil {
+		c.difficulty = make(map[BlockType]map[string]int)
+	}
+	if c.difficulty[blockType] == nil {
+		c.difficulty[blockType] = make(map[string]int)
+	}
+	c.difficulty[blockType][key] = difficulty
+}
+
+// reset forgets every retarget, for when the rules they were computed by change
+func (c *retargetCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.difficulty = nil
+}
+
 // CalculateMinerReward calculates the reward for a miner in the pool
 func (p *MiningPool) CalculateMinerReward(minerID string, blockReward float64) float64 {
 	p.mu.RLock()
@@ -279,31 +409,67 @@ func (p *MiningPool) GetMinerStats(minerID string) map[string]interface{} {
 	}
 }
 
-// NewMiner creates a new miner instance
+// NewMiner creates a new miner instance. NumWorkers defaults to the number of CPUs.
 func NewMiner(config MiningConfig) *Miner {
+	if config.NumWorkers <= 0 {
+		config.NumWorkers = runtime.NumCPU()
+	}
 	return &Miner{
 		config:   config,
 		stopChan: make(chan struct{}),
+		stopOnce: &sync.Once{},
 	}
 }
 
-// Start begins the mining process
+// Start begins mining block on NumWorkers goroutines, each searching its own
+// nonce range. All workers stop as soon as one finds a valid nonce or Stop
+// is called.
 func (m *Miner) Start(block *Block) {
+	m.mu.Lock()
+	m.stopChan = make(chan struct{})
+	m.stopOnce = &sync.Once{}
+	m.found = false
+	stopChan, stopOnce := m.stopChan, m.stopOnce
+	m.mu.Unlock()
+
 	m.wg.Add(m.config.NumWorkers)
+	atomic.StoreInt32(&m.active, int32(m.config.NumWorkers))
 	startTime := time.Now()
 
-	// Start worker goroutines
+	// Workers copy from a snapshot, as the winner writes its nonce to block
+	template := block.Copy()
 	for i := 0; i < m.config.NumWorkers; i++ {
-		go m.worker(i, block, startTime)
+		go m.worker(i, block, template, startTime, stopChan, stopOnce)
 	}
 }
 
-// Stop halts the mining process
+// Stop halts the mining process and waits for every worker to return. It is
+// safe to call more than once.
 func (m *Miner) Stop() {
-	close(m.stopChan)
+	m.mu.RLock()
+	stopChan, stopOnce := m.stopChan, m.stopOnce
+	m.mu.RUnlock()
+
+	if stopOnce != nil {
+		stopOnce.Do(func() { close(stopChan) })
+	}
 	m.wg.Wait()
 }
 
+// Wait blocks until every worker has returned and reports whether a valid
+// nonce was found
+func (m *Miner) Wait() bool {
+	m.wg.Wait()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.found
+}
+
+// ActiveWorkers returns the number of workers still mining
+func (m *Miner) ActiveWorkers() int {
+	return int(atomic.LoadInt32(&m.active))
+}
+
 // GetStats returns current mining statistics
 func (m *Miner) GetStats() MiningStats {
 	m.mu.RLock()
@@ -311,16 +477,19 @@ func (m *Miner) GetStats() MiningStats {
 	return m.stats
 }
 
-// worker performs the actual mining work
-func (m *Miner) worker(id int, block *Block, startTime time.Time) {
+// worker searches its share of the nonces below MaxNonce, one of NumWorkers
+// equal ranges, hashing template's header with each nonce. The first worker
+// whose hash is at most TargetDifficulty writes its nonce and hash to block.
+func (m *Miner) worker(id int, block, template *Block, startTime time.Time, stopChan chan struct{}, stopOnce *sync.Once) {
 	defer m.wg.Done()
+	defer atomic.AddInt32(&m.active, -1)
 
 	// Calculate nonce range for this worker
 	startNonce := uint64(id) * m.config.MaxNonce / uint64(m.config.NumWorkers)
 	endNonce := startNonce + m.config.MaxNonce/uint64(m.config.NumWorkers)
 
 	// Create a copy of the block for this worker
-	workerBlock := block.Copy()
+	workerBlock := template.Copy()
 	workerBlock.Nonce = startNonce
 
 	// Pre-compute block header hash
@@ -328,7 +497,7 @@ func (m *Miner) worker(id int, block *Block, startTime time.Time) {
 
 	for {
 		select {
-		case <-m.stopChan:
+		case <-stopChan:
 			return
 		default:
 			// Update nonce
@@ -352,14 +521,23 @@ func (m *Miner) worker(id int, block *Block, startTime time.Time) {
 			// Check if we found a valid block
 			if hashInt.Cmp(m.config.TargetDifficulty) <= 0 {
 				m.mu.Lock()
+				if m.found {
+					// Another worker got there first
+					m.mu.Unlock()
+					return
+				}
+				m.found = true
 				m.stats.BlocksFound++
 				m.stats.LastBlockTime = time.Now()
 				m.stats.MiningTime = time.Since(startTime)
-				m.mu.Unlock()
 
 				// Update the original block
 				block.Nonce = workerBlock.Nonce
 				block.Hash = hash[:]
+				m.mu.Unlock()
+
+				// Stop the other workers
+				stopOnce.Do(func() { close(stopChan) })
 				return
 			}
 		}
@@ -389,14 +567,19 @@ func (m *Miner) AdjustDifficulty(actualBlockTime time.Duration) {
 
 // Copy creates a deep copy of a block
 func (b *Block) Copy() *Block {
+	txs := make([]Transaction, len(b.Transactions))
+	for i := range b.Transactions {
+		txs[i] = *b.Transactions[i].Copy()
+	}
 	return &Block{
 		Timestamp:    b.Timestamp,
-		Transactions: b.Transactions,
+		Transactions: txs,
 		PrevHash:     b.PrevHash,
 		Hash:         b.Hash,
 		Nonce:        b.Nonce,
 		BlockType:    b.BlockType,
 		Difficulty:   b.Difficulty,
+		MerkleRoot:   b.MerkleRoot,
 	}
 }
 
@@ -0,0 +1,100 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+)
+
+// MaxOrphanBlocks bounds the number of blocks held while waiting for their parent
+const MaxOrphanBlocks = 100
+
+// ErrOrphanBlock is returned by AddBlock when the block's parent is unknown.
+// The block is held in the orphan pool and connected once the parent arrives.
+var ErrOrphanBlock = errors.New("orphan block: parent not found")
+
+// hasBlock reports whether a block with hash is on the chain for blockType.
+// Callers must hold bc.mu.
+func (bc *Blockchain) hasBlock(blockType BlockType, hash []byte) bool {
+	for _, b := range bc.chain(blockType) {
+		if bytes.Equal(b.Hash, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// addOrphan holds a block whose parent is unknown, evicting the oldest orphan
+// when the pool is full. Callers must hold bc.mu.
+func (bc *Blockchain) addOrphan(b Block) error {
+	if !bc.checkProof(b) {
+		return errors.New("invalid proof of work")
+	}
+	if bc.orphans == nil {
+		bc.orphans = make(map[string]Block)
+	}
+	if _, ok := bc.orphans[string(b.Hash)]; ok {
+		return ErrOrphanBlock
+	}
+
+	if len(bc.orphans) >= MaxOrphanBlocks {
+		var oldest string
+		for hash, orphan := range bc.orphans {
+			if oldest == "" || orphan.Timestamp < bc.orphans[oldest].Timestamp {
+				oldest = hash
+			}
+		}
+		delete(bc.orphans, oldest)
+	}
+	bc.orphans[string(b.Hash)] = b
+	return ErrOrphanBlock
+}
+
+// connectOrphans adds the orphans descending from parent, walking down the
+// orphan pool as each one connects, and returns them in the order they
+// connected. Orphans that fail validation are dropped. Callers must hold bc.mu.
+func (bc *Blockchain) connectOrphans(parent Block) []Block {
+	var connected []Block
+	queue := []Block{parent}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for hash, orphan := range bc.orphans {
+			if orphan.BlockType != p.BlockType || !bytes.Equal(orphan.PrevHash, p.Hash) {
+				continue
+			}
+			delete(bc.orphans, hash)
+			if err := bc.connectBlock(orphan); err == nil {
+				connected = append(connected, orphan)
+				queue = append(queue, orphan)
+			}
+		}
+	}
+	return connected
+}
+
+// OrphanCount returns the number of blocks waiting for their parent
+func (bc *Blockchain) OrphanCount() int {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return len(bc.orphans)
+}
+
+// GetOrphanBlocks returns copies of the blocks waiting for their parent,
+// oldest first, for diagnosing a stalled sync
+func (bc *Blockchain) GetOrphanBlocks() []*Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	orphans := make([]*Block, 0, len(bc.orphans))
+	for _, orphan := range bc.orphans {
+		orphans = append(orphans, orphan.Copy())
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		if orphans[i].Timestamp != orphans[j].Timestamp {
+			return orphans[i].Timestamp < orphans[j].Timestamp
+		}
+		return bytes.Compare(orphans[i].Hash, orphans[j].Hash) < 0
+	})
+	return orphans
+}
@@ -0,0 +1,210 @@
+package blockchain
+
+import "fmt"
+
+// NetworkMode selects the consensus parameters a node runs with
+type NetworkMode string
+
+const (
+	Mainnet NetworkMode = "mainnet"
+	Testnet NetworkMode = "testnet"
+	Regtest NetworkMode = "regtest"
+)
+
+// ConsensusParams holds the maturity and confirmation rules shared by the
+// wallet, the pending pool and block validation
+type ConsensusParams struct {
+	Mode NetworkMode
+	// CoinbaseMaturity is the number of confirmations a coinbase output needs before it can be spent
+	CoinbaseMaturity uint64
+	// MinConfirmations is the number of confirmations any confirmed output
+	// needs before it can be spent. Outputs of an unconfirmed parent may be
+	// spent together with it, pending or in the same block, within the
+	// pending pool's ancestor limits.
+	MinConfirmations uint64
+	// MaxReorgDepth is the deepest reorganization, in blocks disconnected, the node accepts
+	MaxReorgDepth uint64
+	// MinRelayFeeRate is the lowest fee per virtual byte the pending pool accepts
+	MinRelayFeeRate float64
+	// MaxGoldenBlockSize and MaxSilverBlockSize cap the virtual size of a block
+	// on each chain; the equivalent weight limit is WitnessScaleFactor times this
+	MaxGoldenBlockSize int64
+	MaxSilverBlockSize int64
+	// GenesisDifficulty is the difficulty a new chain starts mining at
+	GenesisDifficulty int
+	// RetargetInterval is how many blocks pass between difficulty
+	// adjustments; 0 never adjusts
+	RetargetInterval int
+	// AllowPinnedDifficulty accepts blocks mined at any difficulty from the
+	// mining minimum up, rather than only the retargeted one, so test
+	// networks can pin the difficulty for benchmarks
+	AllowPinnedDifficulty bool
+	// MaxSupply caps the total of a coin that may ever be issued; a block
+	// whose coinbase, mints or conversions would take a coin past its cap is
+	// rejected
+	MaxSupply map[CoinType]float64
+	// Activations holds the height each consensus rule added after launch
+	// is enforced from; see ConsensusRule
+	Activations map[ConsensusRule]uint64
+}
+
+const (
+	// DefaultMaxReorgDepth is the default limit on blocks a reorganization may disconnect
+	DefaultMaxReorgDepth uint64 = 100
+	// DefaultMinRelayFeeRate is the default minimum fee per virtual byte on mainnet and testnet
+	DefaultMinRelayFeeRate = 0.00001
+	// DefaultGenesisDifficulty is the starting difficulty of every network
+	DefaultGenesisDifficulty = 1
+	// DefaultRetargetInterval is the mainnet difficulty adjustment interval in blocks
+	DefaultRetargetInterval = 2016
+	// TestnetRetargetInterval lets test networks follow hash rate changes within minutes
+	TestnetRetargetInterval = 10
+)
+
+// ParamsForMode returns the default consensus parameters for a network mode
+func ParamsForMode(mode NetworkMode) (ConsensusParams, error) {
+	switch mode {
+	case Mainnet, Testnet, Regtest:
+		params := ConsensusParams{
+			Mode:               mode,
+			CoinbaseMaturity:   CoinbaseMaturity,
+			MinConfirmations:   MinConfirmations,
+			MaxReorgDepth:      DefaultMaxReorgDepth,
+			MinRelayFeeRate:    DefaultMinRelayFeeRate,
+			MaxGoldenBlockSize: MaxBlockSize,
+			MaxSilverBlockSize: MaxBlockSize,
+			GenesisDifficulty:  DefaultGenesisDifficulty,
+			RetargetInterval:   DefaultRetargetInterval,
+			MaxSupply:          DefaultMaxSupply(),
+			Activations:        defaultActivations(mode),
+		}
+		if mode != Mainnet {
+			params.CoinbaseMaturity = TestnetCoinbaseMaturity
+			params.RetargetInterval = TestnetRetargetInterval
+			params.AllowPinnedDifficulty = true
+		}
+		// Local test networks relay anything that validates
+		if mode == Regtest {
+			params.CoinbaseMaturity = RegtestCoinbaseMaturity
+			params.MinRelayFeeRate = 0
+		}
+		return params, nil
+	default:
+		return ConsensusParams{}, fmt.Errorf("unknown network mode: %s", mode)
+	}
+}
+
+// DefaultConsensusParams returns the mainnet consensus parameters
+func DefaultConsensusParams() ConsensusParams {
+	params, _ := ParamsForMode(Mainnet)
+	return params
+}
+
+// MaxBlockSizeFor returns the largest virtual size a blockType block may have
+func (p ConsensusParams) MaxBlockSizeFor(blockType BlockType) int64 {
+	if blockType == GoldenBlock {
+		return p.MaxGoldenBlockSize
+	}
+	return p.MaxSilverBlockSize
+}
+
+// MaxBlockWeightFor returns the largest weight a blockType block may have
+func (p ConsensusParams) MaxBlockWeightFor(blockType BlockType) int64 {
+	return p.MaxBlockSizeFor(blockType) * WitnessScaleFactor
+}
+
+// BlocksUntilSpendable returns how many more blocks are needed before the
+// output can be spent when the tip of its chain is at currentHeight, or 0 if
+// it already can be
+func (p ConsensusParams) BlocksUntilSpendable(u UTXO, currentHeight uint64) uint64 {
+	required := p.MinConfirmations
+	if u.IsCoinbase && p.CoinbaseMaturity > required {
+		required = p.CoinbaseMaturity
+	}
+	confirmations := u.Confirmations(currentHeight)
+	if confirmations >= required {
+		return 0
+	}
+	return required - confirmations
+}
+
+// IsSpendable reports whether the output can be spent when the tip of its
+// chain is at currentHeight
+func (p ConsensusParams) IsSpendable(u UTXO, currentHeight uint64) bool {
+	return !u.Spent && p.BlocksUntilSpendable(u, currentHeight) == 0
+}
+
+// ConsensusParams returns the consensus parameters the blockchain enforces
+func (bc *Blockchain) ConsensusParams() ConsensusParams {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.params
+}
+
+// SetConsensusParams replaces the consensus parameters the blockchain enforces.
+// A chain that has not grown past its genesis blocks also restarts at the
+// new genesis difficulty. Raising the minimum relay fee rate evicts the
+// pending transactions that no longer pay it.
+func (bc *Blockchain) SetConsensusParams(params ConsensusParams) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	raised := params.MinRelayFeeRate > bc.params.MinRelayFeeRate
+	bc.params = params
+	bc.retargets.reset()
+	if raised {
+		bc.pruneMempool(params.MinRelayFeeRate)
+	}
+	if len(bc.GoldenBlocks) <= 1 && len(bc.SilverBlocks) <= 1 {
+		bc.Difficulty = params.GenesisDifficulty
+	}
+}
+
+// SetNetworkMode switches the blockchain to the default parameters of mode
+func (bc *Blockchain) SetNetworkMode(mode NetworkMode) error {
+	params, err := ParamsForMode(mode)
+	if err != nil {
+		return err
+	}
+	bc.SetConsensusParams(params)
+	return nil
+}
+
+// ChainHeights holds the height of the latest block on each chain. Blocks
+// of one chain never confirm the outputs of the other, so an output's
+// confirmations are counted against the tip of the chain it was created on.
+type ChainHeights map[BlockType]uint64
+
+// Of returns the height of the tip of the chain u was created on
+func (h ChainHeights) Of(u UTXO) uint64 {
+	return h[u.BlockType]
+}
+
+// TipHeights returns the height of the latest block on each chain
+func (bc *Blockchain) TipHeights() ChainHeights {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.tipHeights()
+}
+
+// tipHeights is TipHeights for callers holding bc.mu
+func (bc *Blockchain) tipHeights() ChainHeights {
+	return ChainHeights{
+		GoldenBlock: bc.tipHeight(GoldenBlock),
+		SilverBlock: bc.tipHeight(SilverBlock),
+	}
+}
+
+// tipHeight returns the height of the latest block on blockType's chain.
+// Callers must hold bc.mu.
+func (bc *Blockchain) tipHeight(blockType BlockType) uint64 {
+	if height := bc.nextHeight(blockType); height > 0 {
+		return height - 1
+	}
+	return 0
+}
+
+// nextHeight returns the height the next block on blockType's chain will
+// have. Callers must hold bc.mu.
+func (bc *Blockchain) nextHeight(blockType BlockType) uint64 {
+	return uint64(len(bc.chain(blockType)))
+}
@@ -0,0 +1,70 @@
+package blockchain
+
+import "sync"
+
+// PoWMetrics counts proof-of-work activity: the nonces MineBlock hashes and
+// the proofs checked on blocks being validated. The zero value is ready to
+// use and it is safe for concurrent use.
+type PoWMetrics struct {
+	mu            sync.Mutex
+	attempts      uint64
+	validations   uint64
+	invalidProofs uint64
+}
+
+// PoWMetricsSnapshot is a copy of the PoWMetrics counters at one moment
+type PoWMetricsSnapshot struct {
+	// Attempts is the number of nonces hashed while mining
+	Attempts uint64
+	// Validations is the number of block proofs checked
+	Validations uint64
+	// InvalidProofs is how many of the checked proofs failed
+	InvalidProofs uint64
+}
+
+// Snapshot returns the current counters
+func (m *PoWMetrics) Snapshot() PoWMetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return PoWMetricsSnapshot{
+		Attempts:      m.attempts,
+		Validations:   m.validations,
+		InvalidProofs: m.invalidProofs,
+	}
+}
+
+// Reset zeroes the counters, for example between mining sessions
+func (m *PoWMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempts = 0
+	m.validations = 0
+	m.invalidProofs = 0
+}
+
+func (m *PoWMetrics) recordAttempts(n uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempts += n
+}
+
+func (m *PoWMetrics) recordValidation(valid bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validations++
+	if !valid {
+		m.invalidProofs++
+	}
+}
+
+// PoWMetrics returns the chain's proof-of-work counters
+func (bc *Blockchain) PoWMetrics() *PoWMetrics {
+	return &bc.powMetrics
+}
+
+// checkProof validates a block's proof of work, counting the check
+func (bc *Blockchain) checkProof(block Block) bool {
+	valid := bc.isValidProof(block)
+	bc.powMetrics.recordValidation(valid)
+	return valid
+}
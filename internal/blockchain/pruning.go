@@ -105,6 +105,7 @@ func (pm *PruningManager) pruneBlockBatch(start, end int) error {
 			idx := i - len(pm.blockchain.GoldenBlocks)
 			pm.blockchain.SilverBlocks = append(pm.blockchain.SilverBlocks[:idx], pm.blockchain.SilverBlocks[idx+1:]...)
 		}
+		pm.blockchain.index.remove(&block)
 
 		// Update UTXO set
 		for _, tx := range block.Transactions {
@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EncodeRawTransaction returns tx as a hex string that can be handed around
+// and decoded back with DecodeRawTransaction. The encoding keeps every field
+// the transaction ID commits to, so a decoded transaction has the same ID.
+func EncodeRawTransaction(tx *Transaction) (string, error) {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode transaction: %v", err)
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// DecodeRawTransaction decodes a transaction encoded by EncodeRawTransaction.
+// It does not check the transaction is valid.
+func DecodeRawTransaction(raw string) (*Transaction, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("raw transaction is not hex: %v", err)
+	}
+	var tx Transaction
+	if err := json.Unmarshal(data, &tx); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %v", err)
+	}
+	return &tx, nil
+}
+
+// DecodedInput is the inspection view of a transaction input
+type DecodedInput struct {
+	TxID        string  `json:"txid"`
+	OutputIndex int     `json:"output_index"`
+	Amount      float64 `json:"amount"`
+	Address     string  `json:"address,omitempty"`
+	PublicKey   string  `json:"public_key,omitempty"`
+	Signed      bool    `json:"signed"`
+	SegWit      bool    `json:"segwit,omitempty"`
+}
+
+// DecodedOutput is the inspection view of a transaction output
+type DecodedOutput struct {
+	Value         float64  `json:"value"`
+	CoinType      CoinType `json:"coin_type"`
+	Address       string   `json:"address"`
+	PublicKeyHash string   `json:"public_key_hash"`
+}
+
+// DecodedTransaction is the inspection view of a transaction: its parts,
+// the coins it moves, its fee and its virtual size
+type DecodedTransaction struct {
+	TxID      string          `json:"txid"`
+	Coinbase  bool            `json:"coinbase"`
+	BlockType BlockType       `json:"block_type,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	Inputs    []DecodedInput  `json:"inputs"`
+	Outputs   []DecodedOutput `json:"outputs"`
+	CoinTypes []CoinType      `json:"coin_types"`
+	Fee       float64         `json:"fee"`
+	VSize     int             `json:"vsize"`
+}
+
+// DescribeTransaction returns the inspection view of tx. The fee is taken
+// from the amounts the inputs claim, so it is only right for a transaction
+// that validates.
+func DescribeTransaction(tx *Transaction) *DecodedTransaction {
+	decoded := &DecodedTransaction{
+		TxID:      hex.EncodeToString(tx.ID),
+		Coinbase:  tx.IsCoinbase(),
+		BlockType: tx.BlockType,
+		Timestamp: tx.Timestamp,
+		Inputs:    make([]DecodedInput, 0, len(tx.Inputs)),
+		Outputs:   make([]DecodedOutput, 0, len(tx.Outputs)),
+		CoinTypes: []CoinType{},
+		VSize:     tx.VirtualSize(),
+	}
+	// A coinbase's input spends nothing, so it is not listed
+	if !decoded.Coinbase {
+		decoded.Fee = tx.GetFee()
+		for _, input := range tx.Inputs {
+			decoded.Inputs = append(decoded.Inputs, DecodedInput{
+				TxID:        hex.EncodeToString(input.TxID),
+				OutputIndex: input.OutputIndex,
+				Amount:      input.Amount,
+				Address:     input.Address,
+				PublicKey:   hex.EncodeToString(input.PublicKey),
+				Signed:      len(input.Signature) > 0,
+				SegWit:      input.IsWitness(),
+			})
+		}
+	}
+
+	seen := make(map[CoinType]bool)
+	for _, output := range tx.Outputs {
+		decoded.Outputs = append(decoded.Outputs, DecodedOutput{
+			Value:         output.Value,
+			CoinType:      output.CoinType,
+			Address:       output.Address,
+			PublicKeyHash: hex.EncodeToString(output.PublicKeyHash),
+		})
+		if !seen[output.CoinType] {
+			seen[output.CoinType] = true
+			decoded.CoinTypes = append(decoded.CoinTypes, output.CoinType)
+		}
+	}
+	return decoded
+}
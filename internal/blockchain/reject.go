@@ -0,0 +1,69 @@
+package blockchain
+
+// RejectReason names the pending-pool check a transaction failed
+type RejectReason string
+
+const (
+	// RejectCoinbase is for coinbase transactions, which only blocks may hold
+	RejectCoinbase RejectReason = "coinbase"
+	// RejectMalformed is for transactions missing inputs or outputs or
+	// spending an output twice
+	RejectMalformed RejectReason = "malformed"
+	// RejectOversize is for transactions larger than a block allows
+	RejectOversize RejectReason = "oversize"
+	// RejectInvalid is for bad signatures, unowned or unknown inputs and
+	// inputs that do not cover the outputs
+	RejectInvalid RejectReason = "invalid"
+	// RejectExpired is for transactions past their expiry height
+	RejectExpired RejectReason = "expired"
+	// RejectImmature is for spends of outputs without enough confirmations
+	RejectImmature RejectReason = "immature"
+	// RejectPackageLimit is for transactions exceeding the ancestor or
+	// descendant limits
+	RejectPackageLimit RejectReason = "package-limit"
+	// RejectFee is for transactions paying less than the minimum relay fee rate
+	RejectFee RejectReason = "insufficient-fee"
+	// RejectDuplicate is for transactions already pending
+	RejectDuplicate RejectReason = "duplicate"
+	// RejectDoubleSpend is for spends of outputs already spent on chain or by
+	// a pending transaction that cannot be replaced
+	RejectDoubleSpend RejectReason = "double-spend"
+	// RejectReplacementFee is for replacements not paying more than the
+	// transactions they would evict
+	RejectReplacementFee RejectReason = "replacement-fee"
+	// RejectMempoolFull is for transactions arriving when their chain's
+	// pending queue is full
+	RejectMempoolFull RejectReason = "mempool-full"
+)
+
+// RejectError is returned when the pending pool refuses a transaction. Its
+// message is that of the underlying error, which it unwraps to.
+type RejectError struct {
+	Reason RejectReason
+	Err    error
+}
+
+func (e *RejectError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RejectError) Unwrap() error {
+	return e.Err
+}
+
+// reject wraps err as a rejection for reason
+func reject(reason RejectReason, err error) *RejectError {
+	return &RejectError{Reason: reason, Err: err}
+}
+
+// TxAcceptance describes a transaction accepted into the pending pool
+type TxAcceptance struct {
+	TxID    []byte
+	Fee     float64
+	FeeRate float64
+	// Replaced holds the IDs of pending transactions evicted by fee
+	Replaced [][]byte
+	// Evicted holds the IDs of the pending transactions given up to make
+	// room in a full queue: the lowest fee rate and its descendants
+	Evicted [][]byte
+}
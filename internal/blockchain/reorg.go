@@ -0,0 +1,252 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"byc/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// ReorgDepthError is returned when a reorganization would disconnect more
+// blocks than the consensus parameters allow
+type ReorgDepthError struct {
+	BlockType BlockType
+	Depth     uint64
+	MaxDepth  uint64
+}
+
+func (e *ReorgDepthError) Error() string {
+	return fmt.Sprintf("refusing %s chain reorganization of depth %d: exceeds maximum of %d", e.BlockType, e.Depth, e.MaxDepth)
+}
+
+// chainPosition is the chain and height a block was connected at
+type chainPosition struct {
+	blockType BlockType
+	height    uint64
+}
+
+// OnReorgRejected registers a callback invoked whenever a reorganization is
+// refused for exceeding the maximum depth, so operators can be alerted
+func (bc *Blockchain) OnReorgRejected(alert func(*ReorgDepthError)) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.reorgAlert = alert
+}
+
+// Reorganize replaces the blocks of blockType's chain above forkHeight with
+// blocks, which must extend the block at forkHeight and be longer than the
+// branch they replace. Reorganizations deeper than MaxReorgDepth are refused
+// with a *ReorgDepthError. If any replacement block is invalid the original
+// branch is restored. Non-coinbase transactions from the disconnected blocks
+// are returned to the pending pool unless they conflict with the new branch.
+func (bc *Blockchain) Reorganize(blockType BlockType, forkHeight int, blocks []Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	chain := bc.chain(blockType)
+	if forkHeight < 0 || forkHeight >= len(chain) {
+		return fmt.Errorf("invalid fork height: %d", forkHeight)
+	}
+	if len(blocks) <= len(chain)-1-forkHeight {
+		return fmt.Errorf("replacement branch of %d blocks is not longer than the current %d", len(blocks), len(chain)-1-forkHeight)
+	}
+	if depth := uint64(len(chain) - 1 - forkHeight); bc.params.MaxReorgDepth > 0 && depth > bc.params.MaxReorgDepth {
+		err := &ReorgDepthError{BlockType: blockType, Depth: depth, MaxDepth: bc.params.MaxReorgDepth}
+		logger.Warn("Rejected deep chain reorganization",
+			zap.String("block_type", string(blockType)),
+			zap.Uint64("depth", depth),
+			zap.Uint64("max_depth", bc.params.MaxReorgDepth))
+		if bc.reorgAlert != nil {
+			bc.reorgAlert(err)
+		}
+		return err
+	}
+
+	disconnected := bc.disconnectBlocks(blockType, forkHeight)
+	for i, b := range blocks {
+		if err := bc.addBlock(b); err != nil {
+			// Restore the original branch
+			bc.disconnectBlocks(blockType, forkHeight)
+			for _, old := range disconnected {
+				if restoreErr := bc.addBlock(old); restoreErr != nil {
+					return fmt.Errorf("replacement block %d: %v; failed to restore original branch: %v", i, err, restoreErr)
+				}
+			}
+			return fmt.Errorf("replacement block %d (%x): %v", i, b.Hash, err)
+		}
+	}
+
+	bc.restorePending(disconnected)
+	return nil
+}
+
+// restorePending returns the non-coinbase transactions of disconnected blocks
+// to the pending pool in chain order. Transactions the new branch confirmed
+// are skipped. So are those spending an output the new branch spent or never
+// created, including outputs of skipped transactions. Callers must hold bc.mu.
+func (bc *Blockchain) restorePending(disconnected []Block) {
+	spent := make(map[string]bool)
+	for _, ptx := range bc.pending() {
+		for _, input := range ptx.Inputs {
+			spent[fmt.Sprintf("%x:%d", input.TxID, input.OutputIndex)] = true
+		}
+	}
+	restored := make(map[string]bool)
+
+	for _, b := range disconnected {
+		for _, tx := range b.Transactions {
+			if tx.IsCoinbase() || len(tx.Inputs) == 0 || bc.isConfirmed(tx.ID) {
+				continue
+			}
+			if input, ok := bc.restorable(tx, spent, restored); !ok {
+				logger.Debug("Dropping reorganized transaction that conflicts with the new branch",
+					zap.String("tx", fmt.Sprintf("%x", tx.ID)),
+					zap.String("input", input))
+				continue
+			}
+			for _, input := range tx.Inputs {
+				spent[fmt.Sprintf("%x:%d", input.TxID, input.OutputIndex)] = true
+			}
+			restored[string(tx.ID)] = true
+			bc.enqueue(tx)
+		}
+	}
+}
+
+// restorable reports whether every input of tx is an unspent output of the
+// chain or of a restored transaction, not spent by anything pending. If not,
+// it returns the first input that is not. Callers must hold bc.mu.
+func (bc *Blockchain) restorable(tx Transaction, spent, restored map[string]bool) (string, bool) {
+	for _, input := range tx.Inputs {
+		key := fmt.Sprintf("%x:%d", input.TxID, input.OutputIndex)
+		if spent[key] {
+			return key, false
+		}
+		if restored[string(input.TxID)] {
+			continue
+		}
+		if utxo := bc.UTXOSet.GetUTXO(input.TxID, input.OutputIndex); utxo.TxID == "" || utxo.Spent {
+			return key, false
+		}
+	}
+	return "", true
+}
+
+// Confirmations returns the number of blocks on the main chain confirming the
+// block with blockHash: 1 for the tip of its chain, tip height - block height + 1
+// for buried blocks and -1 for blocks removed by a reorganization, until the
+// chain has grown MaxReorgDepth blocks past their height. Unknown blocks
+// return 0 and an error.
+func (bc *Blockchain) Confirmations(blockHash []byte) (int, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	for _, chain := range [][]Block{bc.GoldenBlocks, bc.SilverBlocks} {
+		for height, block := range chain {
+			if bytes.Equal(block.Hash, blockHash) {
+				return len(chain) - height, nil
+			}
+		}
+	}
+
+	if _, ok := bc.reorgedOut[string(blockHash)]; ok {
+		return -1, nil
+	}
+
+	return 0, fmt.Errorf("block not found")
+}
+
+// chain returns the blocks of blockType's chain. Callers must hold bc.mu.
+func (bc *Blockchain) chain(blockType BlockType) []Block {
+	if blockType == GoldenBlock {
+		return bc.GoldenBlocks
+	}
+	return bc.SilverBlocks
+}
+
+// disconnectBlocks removes the blocks of blockType's chain above height,
+// reverting their UTXO changes, and returns them in chain order. Callers must hold bc.mu.
+func (bc *Blockchain) disconnectBlocks(blockType BlockType, height int) []Block {
+	chain := bc.chain(blockType)
+	removed := append([]Block(nil), chain[height+1:]...)
+
+	for i := len(removed) - 1; i >= 0; i-- {
+		b := removed[i]
+		bc.reorgedOut[string(b.Hash)] = chainPosition{blockType: blockType, height: uint64(height + 1 + i)}
+		undo := bc.undo[string(b.Hash)]
+		for j := len(b.Transactions) - 1; j >= 0; j-- {
+			var spent []UTXO
+			if j < len(undo) {
+				spent = undo[j]
+			}
+			bc.UTXOSet.RevertTransaction(&b.Transactions[j], spent)
+		}
+		bc.addIssued(blockIssuance(b, undo), -1)
+		delete(bc.undo, string(b.Hash))
+		bc.index.remove(&b)
+	}
+
+	if blockType == GoldenBlock {
+		bc.GoldenBlocks = chain[:height+1]
+	} else {
+		bc.SilverBlocks = chain[:height+1]
+	}
+
+	// Keep the backward compatible Blocks slice in step
+	kept := bc.Blocks[:0]
+	for _, b := range bc.Blocks {
+		if _, out := bc.reorgedOut[string(b.Hash)]; !(b.BlockType == blockType && out) {
+			kept = append(kept, b)
+		}
+	}
+	bc.Blocks = kept
+
+	for i := len(removed) - 1; i >= 0; i-- {
+		bc.publishBlock(BlockDisconnected, &removed[i])
+	}
+	return removed
+}
+
+// pruneReorgData drops the undo data of blocks on blockType's chain buried
+// deeper than MaxReorgDepth, which no reorganization may disconnect, and
+// forgets the blocks reorganized out at those heights. A MaxReorgDepth of 0
+// keeps everything. Callers must hold bc.mu.
+func (bc *Blockchain) pruneReorgData(blockType BlockType) {
+	depth := bc.params.MaxReorgDepth
+	chain := bc.chain(blockType)
+	if depth == 0 || uint64(len(chain)) <= depth {
+		return
+	}
+
+	// Reorganize disconnects at most depth blocks, so only the top depth
+	// blocks of the chain need undo data
+	floor := uint64(len(chain)) - depth
+	for height := int(floor) - 1; height >= 0; height-- {
+		hash := string(chain[height].Hash)
+		if _, ok := bc.undo[hash]; !ok {
+			break
+		}
+		delete(bc.undo, hash)
+	}
+	for hash, pos := range bc.reorgedOut {
+		if pos.blockType == blockType && pos.height < floor {
+			delete(bc.reorgedOut, hash)
+		}
+	}
+}
+
+// isConfirmed reports whether a transaction is in either main chain. Callers must hold bc.mu.
+func (bc *Blockchain) isConfirmed(txID []byte) bool {
+	for _, chain := range [][]Block{bc.GoldenBlocks, bc.SilverBlocks} {
+		for _, block := range chain {
+			for _, tx := range block.Transactions {
+				if bytes.Equal(tx.ID, txID) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"byc/internal/crypto"
+)
+
+// ConsensusRule names a consensus rule added after launch. Each is enforced
+// from an activation height on, so blocks mined before an upgrade stay valid
+// under the rules they were mined with and upgraded nodes do not split from
+// the chain.
+type ConsensusRule string
+
+const (
+	// RuleLowS requires every ECDSA input signature to be in low-S form, so
+	// a third party cannot alter a transaction's signatures. BIP-340
+	// Schnorr signatures are not malleable and are left alone.
+	RuleLowS ConsensusRule = "low-s"
+)
+
+const (
+	// LowSActivationHeight is the mainnet height RuleLowS is enforced from
+	LowSActivationHeight uint64 = 100000
+	// TestnetLowSActivationHeight is the testnet height RuleLowS is
+	// enforced from
+	TestnetLowSActivationHeight uint64 = 1000
+)
+
+// defaultActivations returns the heights mode enforces each rule from.
+// Regtest chains start fresh, so every rule is active from genesis.
+func defaultActivations(mode NetworkMode) map[ConsensusRule]uint64 {
+	switch mode {
+	case Mainnet:
+		return map[ConsensusRule]uint64{RuleLowS: LowSActivationHeight}
+	case Testnet:
+		return map[ConsensusRule]uint64{RuleLowS: TestnetLowSActivationHeight}
+	default:
+		return map[ConsensusRule]uint64{RuleLowS: 0}
+	}
+}
+
+// RuleActive reports whether rule is enforced for a block at height. A rule
+// without an activation height is never enforced.
+func (p ConsensusParams) RuleActive(rule ConsensusRule, height uint64) bool {
+	activation, ok := p.Activations[rule]
+	return ok && height >= activation
+}
+
+// checkActiveRules checks tx against the rules active for a block at height
+func (p ConsensusParams) checkActiveRules(tx *Transaction, height uint64) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+	if p.RuleActive(RuleLowS, height) {
+		for i, input := range tx.Inputs {
+			if !input.IsSchnorr() && !crypto.IsLowS(input.Signature, input.PublicKey) {
+				return &ValidationError{
+					Field:  fmt.Sprintf("input[%d].Signature", i),
+					Reason: fmt.Sprintf("signature is not in low-S form, required from height %d", p.Activations[RuleLowS]),
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package blockchain
+
+import "encoding/hex"
+
+// ChainStats is a consistent snapshot of the chain for status displays
+type ChainStats struct {
+	// Height is the number of blocks across both chains
+	Height       int64
+	GoldenBlocks int
+	SilverBlocks int
+	// GoldenTip and SilverTip are the hex hashes of the chain tips, empty
+	// for a chain without blocks
+	GoldenTip   string
+	SilverTip   string
+	MempoolSize int
+	// TotalSupply is the amount of each coin issued, as GetTotalSupply
+	TotalSupply map[CoinType]float64
+}
+
+// Stats returns the chain statistics read under a single lock, so the
+// values agree with each other
+func (bc *Blockchain) Stats() ChainStats {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	stats := ChainStats{
+		Height:       int64(len(bc.Blocks)),
+		GoldenBlocks: len(bc.GoldenBlocks),
+		SilverBlocks: len(bc.SilverBlocks),
+		MempoolSize:  len(bc.pending()),
+		TotalSupply:  bc.issuedSupply(),
+	}
+	if tip, ok := bc.chainTip(GoldenBlock); ok {
+		stats.GoldenTip = hex.EncodeToString(tip.Hash)
+	}
+	if tip, ok := bc.chainTip(SilverBlock); ok {
+		stats.SilverTip = hex.EncodeToString(tip.Hash)
+	}
+	return stats
+}
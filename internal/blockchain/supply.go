@@ -0,0 +1,77 @@
+package blockchain
+
+import "fmt"
+
+// issuance returns the amount of each coin tx creates rather than moves.
+// A coinbase or genesis transaction creates all of its outputs; any other
+// transaction creates whatever of a coin its outputs hold beyond the spent
+// outputs of that coin, which is how mints and conversions issue coins.
+// spent are the outputs tx's inputs spend.
+func issuance(tx Transaction, spent []UTXO) map[CoinType]float64 {
+	created := make(map[CoinType]float64)
+	for _, output := range tx.Outputs {
+		created[output.CoinType] += output.Value
+	}
+	if !tx.IsCoinbase() {
+		for _, utxo := range spent {
+			created[utxo.CoinType] -= utxo.Amount
+		}
+	}
+	for coinType, amount := range created {
+		if amount <= 0 {
+			delete(created, coinType)
+		}
+	}
+	return created
+}
+
+// blockIssuance sums the issuance of block's transactions, where spent[i]
+// are the outputs its i'th transaction spends
+func blockIssuance(block Block, spent [][]UTXO) map[CoinType]float64 {
+	issued := make(map[CoinType]float64)
+	for i, tx := range block.Transactions {
+		var inputs []UTXO
+		if i < len(spent) {
+			inputs = spent[i]
+		}
+		for coinType, amount := range issuance(tx, inputs) {
+			issued[coinType] += amount
+		}
+	}
+	return issued
+}
+
+// addIssued adds the coins issued by a connected block to the running
+// supply, or takes them away again when sign is -1 for a disconnected block.
+// Callers must hold bc.mu.
+func (bc *Blockchain) addIssued(issued map[CoinType]float64, sign float64) {
+	if bc.issued == nil {
+		bc.issued = make(map[CoinType]float64)
+	}
+	for coinType, amount := range issued {
+		bc.issued[coinType] += sign * amount
+	}
+}
+
+// issuedSupply returns a copy of the amount of each coin issued across both
+// chains. Callers must hold bc.mu.
+func (bc *Blockchain) issuedSupply() map[CoinType]float64 {
+	supply := make(map[CoinType]float64, len(bc.issued))
+	for coinType, amount := range bc.issued {
+		supply[coinType] = amount
+	}
+	return supply
+}
+
+// checkSupplyCaps rejects a block issuing the given coins if they would take
+// a coin past its MaxSupply. Callers must hold bc.mu.
+func (bc *Blockchain) checkSupplyCaps(issued map[CoinType]float64) error {
+	for coinType, amount := range issued {
+		maxSupply, capped := bc.params.MaxSupply[coinType]
+		if capped && bc.issued[coinType]+amount > maxSupply {
+			return fmt.Errorf("block issues %.8f %s, taking supply %.8f past its cap of %.8f",
+				amount, coinType, bc.issued[coinType], maxSupply)
+		}
+	}
+	return nil
+}
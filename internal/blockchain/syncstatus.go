@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"sync"
+	"time"
+)
+
+// MaxSyncLag is how many blocks the local chain may trail the best height a
+// peer advertises and still count as synced
+const MaxSyncLag = 1
+
+// UnprovenHeightGrace is how long a peer's advertised height above what its
+// blocks have shown is believed. A peer claiming a height it never delivers
+// blocks towards would otherwise hold the node unsynced for as long as it
+// stays connected.
+var UnprovenHeightGrace = 2 * time.Minute
+
+// peerHeights records the chain height each connected peer advertises
+type peerHeights struct {
+	mu      sync.Mutex
+	heights map[string]*peerHeight
+}
+
+// peerHeight is what one peer has claimed and shown of its chain height
+type peerHeight struct {
+	// advertised is the height the peer last claimed
+	advertised int64
+	// proven is the local height a block from the peer last took the chain to
+	proven int64
+	// claimedAt is when the advertised height last rose above proven without
+	// blocks following it
+	claimedAt time.Time
+}
+
+// height returns the peer's height as believed at now: its advertised height
+// within UnprovenHeightGrace of claiming it, and only what it proved after
+func (h *peerHeight) height(now time.Time) int64 {
+	if h.advertised <= h.proven || now.Sub(h.claimedAt) <= UnprovenHeightGrace {
+		return h.advertised
+	}
+	return h.proven
+}
+
+// peer returns the record of peer, creating it. Callers must hold p.mu.
+func (p *peerHeights) peer(peer string) *peerHeight {
+	if p.heights == nil {
+		p.heights = make(map[string]*peerHeight)
+	}
+	h, ok := p.heights[peer]
+	if !ok {
+		h = &peerHeight{}
+		p.heights[peer] = h
+	}
+	return h
+}
+
+// SetPeerHeight records the chain height peer advertises. The network layer
+// calls it whenever a peer reports its height. A claim above what the peer's
+// blocks have shown counts for UnprovenHeightGrace; claiming again does not
+// extend it.
+func (bc *Blockchain) SetPeerHeight(peer string, height int64) {
+	p := &bc.peerHeights
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h := p.peer(peer)
+	if height > h.proven && h.advertised <= h.proven {
+		h.claimedAt = time.Now()
+	}
+	h.advertised = height
+}
+
+// ConfirmPeerHeight records that a block from peer took the local chain to
+// height, so the peer's chain is at least that high. The network layer calls
+// it whenever a block a peer announced connects. A peer delivering blocks
+// towards a higher advertised height is believed for another
+// UnprovenHeightGrace.
+func (bc *Blockchain) ConfirmPeerHeight(peer string, height int64) {
+	p := &bc.peerHeights
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h := p.peer(peer)
+	if height <= h.proven {
+		return
+	}
+	h.proven = height
+	if h.advertised < height {
+		h.advertised = height
+	}
+	h.claimedAt = time.Now()
+}
+
+// RemovePeerHeight forgets the height of a peer that disconnected
+func (bc *Blockchain) RemovePeerHeight(peer string) {
+	p := &bc.peerHeights
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.heights, peer)
+}
+
+// bestPeerHeight returns the highest height any peer is believed to have, or
+// 0 without peers
+func (bc *Blockchain) bestPeerHeight() int64 {
+	p := &bc.peerHeights
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var best int64
+	for _, h := range p.heights {
+		if height := h.height(now); height > best {
+			best = height
+		}
+	}
+	return best
+}
+
+// SyncProgress returns the local chain height, the best height known from
+// peers or the local height if no peer is ahead, and the percentage of the
+// best height the local chain has reached
+func (bc *Blockchain) SyncProgress() (current, best int64, pct float64) {
+	current = bc.GetCurrentHeight()
+	best = bc.bestPeerHeight()
+	if best <= current {
+		return current, current, 100
+	}
+	return current, best, float64(current) / float64(best) * 100
+}
+
+// IsSynced reports whether the local chain is within MaxSyncLag blocks of
+// the best height a peer is believed to have. A node that knows of no peer
+// ahead of it counts as synced.
+func (bc *Blockchain) IsSynced() bool {
+	current, best, _ := bc.SyncProgress()
+	return best-current <= MaxSyncLag
+}
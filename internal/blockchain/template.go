@@ -0,0 +1,235 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BlockTemplate is everything an external miner needs to assemble a block:
+// the header fields, the coinbase and the transactions after it. The Merkle
+// branch lets a miner that rewrites the coinbase recompute the Merkle root
+// without rehashing the other transactions.
+type BlockTemplate struct {
+	BlockType BlockType `json:"block_type"`
+	CoinType  CoinType  `json:"coin_type"`
+	// Height is the height the block would have in its chain
+	Height   int    `json:"height"`
+	PrevHash []byte `json:"prev_hash"`
+	// Difficulty is the number of leading zero bytes the block hash needs;
+	// Target is the largest hash that has them
+	Difficulty int    `json:"difficulty"`
+	Target     []byte `json:"target"`
+	// Timestamp is a valid block time now; blocks must be timestamped after
+	// MinTimestamp - 1, the median time past
+	Timestamp     int64       `json:"timestamp"`
+	MinTimestamp  int64       `json:"min_timestamp"`
+	CoinbaseValue float64     `json:"coinbase_value"`
+	Coinbase      Transaction `json:"coinbase"`
+	// Transactions are the selected pending transactions, in block order
+	// after the coinbase
+	Transactions []Transaction `json:"transactions"`
+	// MerkleBranch holds the sibling hashes from the coinbase leaf up to
+	// MerkleRoot
+	MerkleBranch [][]byte `json:"merkle_branch"`
+	MerkleRoot   []byte   `json:"merkle_root"`
+}
+
+// GetBlockTemplate builds a template for the next blockType block, mining
+// coinType with the block subsidy paid to payTo
+func (bc *Blockchain) GetBlockTemplate(blockType BlockType, coinType CoinType, payTo string) (*BlockTemplate, error) {
+	if payTo == "" {
+		return nil, errors.New("coinbase address is required")
+	}
+	coinbase := NewCoinbaseTransaction(payTo, nil, BlockSubsidy(coinType), coinType, blockType)
+	return bc.NewBlockTemplate(blockType, coinType, *coinbase)
+}
+
+// NewBlockTemplate assembles the next blockType block around coinbase,
+// filling it from the mempool as SelectTransactions does. It is the one
+// place blocks are assembled for mining, so solo miners, pool miners and the
+// template API all build identically structured blocks.
+func (bc *Blockchain) NewBlockTemplate(blockType BlockType, coinType CoinType, coinbase Transaction) (*BlockTemplate, error) {
+	if !coinbase.IsCoinbase() {
+		return nil, errors.New("template coinbase is not a coinbase transaction")
+	}
+	if err := CheckCoinbaseTag(coinbase.CoinbaseTag); err != nil {
+		return nil, err
+	}
+	t, err := bc.nextBlockHeader(blockType, coinType)
+	if err != nil {
+		return nil, err
+	}
+
+	txs := bc.SelectTransactions(blockType, coinbase)
+	t.CoinbaseValue = coinbase.GetTotalOutput()
+	t.Coinbase = txs[0]
+	t.Transactions = txs[1:]
+	t.MerkleBranch = coinbaseMerkleBranch(txs)
+	t.MerkleRoot = MerkleRoot(txs)
+	return t, nil
+}
+
+// nextBlockHeader returns a template holding only the header fields of the
+// next blockType block mining coinType: its parent, height, difficulty and a
+// timestamp valid now, the later of the clock and the median time past plus
+// one. It returns ErrTimestampAhead rather than a template validation would
+// refuse. Every way of building a block starts from it.
+func (bc *Blockchain) nextBlockHeader(blockType BlockType, coinType CoinType) (*BlockTemplate, error) {
+	if !IsMineable(coinType) {
+		return nil, errors.New("coin type is not mineable")
+	}
+	if err := CheckMiningTarget(coinType, blockType); err != nil {
+		return nil, err
+	}
+
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	prevBlock, ok := bc.chainTip(blockType)
+	if !ok {
+		return nil, fmt.Errorf("%w for %s chain", ErrChainNotInitialized, blockType)
+	}
+	difficulty := bc.nextDifficulty(blockType) * MiningDifficulty(coinType)
+	t := &BlockTemplate{
+		BlockType:    blockType,
+		CoinType:     coinType,
+		Height:       len(bc.chain(blockType)),
+		PrevHash:     prevBlock.Hash,
+		Difficulty:   difficulty,
+		Target:       difficultyTarget(difficulty),
+		Timestamp:    time.Now().Unix(),
+		MinTimestamp: bc.medianTimePast(blockType) + 1,
+	}
+	// Blocks must be timestamped after the median time past, even within one
+	// second, but no further ahead of the clock than validation accepts
+	if t.Timestamp < t.MinTimestamp {
+		t.Timestamp = t.MinTimestamp
+	}
+	if t.Timestamp > time.Now().Unix()+MaxFutureBlockTime {
+		return nil, fmt.Errorf("%w: %s blocks must be timestamped after %d", ErrTimestampAhead, blockType, t.MinTimestamp-1)
+	}
+	return t, nil
+}
+
+// SetDifficulty replaces the template's difficulty and the target that goes
+// with it, so a miner can pin the difficulty it mines at
+func (t *BlockTemplate) SetDifficulty(difficulty int) {
+	t.Difficulty = difficulty
+	t.Target = difficultyTarget(difficulty)
+}
+
+// ErrTemplateStale is returned by MineTemplateUntil when the template is
+// replaced before a nonce completing it is found
+var ErrTemplateStale = errors.New("block template is stale")
+
+// MineTemplate searches for a nonce completing the template's block, as
+// MineBlock does
+func (bc *Blockchain) MineTemplate(t *BlockTemplate) (Block, error) {
+	return bc.MineTemplateUntil(t, nil)
+}
+
+// MineTemplateUntil searches for a nonce completing the template's block
+// like MineTemplate, but gives up with ErrTemplateStale once stale is
+// closed or receives, so a miner can move to a template built on a block
+// found meanwhile. A nil stale never fires.
+func (bc *Blockchain) MineTemplateUntil(t *BlockTemplate, stale <-chan struct{}) (Block, error) {
+	block := t.Assemble(0)
+	if err := bc.solve(&block, stale); err != nil {
+		return Block{}, err
+	}
+	return block, nil
+}
+
+// Assemble returns the template's block with the given nonce and its hash.
+// The block is only valid if that hash is at most Target.
+func (t *BlockTemplate) Assemble(nonce uint64) Block {
+	block := Block{
+		Timestamp:    t.Timestamp,
+		Transactions: append([]Transaction{t.Coinbase}, t.Transactions...),
+		PrevHash:     t.PrevHash,
+		Nonce:        nonce,
+		BlockType:    t.BlockType,
+		Difficulty:   t.Difficulty,
+		MerkleRoot:   MerkleRootFromBranch(t.Coinbase.ID, t.MerkleBranch),
+	}
+	block.Hash = calculateHash(block)
+	return block
+}
+
+// SubmitBlock decodes a JSON-encoded block assembled from a template and adds
+// it to its chain. The block must build on the current tip and commit to its
+// transactions with a Merkle root.
+func (bc *Blockchain) SubmitBlock(serialized []byte) error {
+	var block Block
+	if err := json.Unmarshal(serialized, &block); err != nil {
+		return fmt.Errorf("failed to decode block: %v", err)
+	}
+	if len(block.MerkleRoot) == 0 {
+		return errors.New("block has no merkle root")
+	}
+	if !bytes.Equal(block.Hash, calculateHash(block)) {
+		return errors.New("block hash does not match its header")
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	tip, ok := bc.chainTip(block.BlockType)
+	if !ok {
+		return fmt.Errorf("%w for %s chain", ErrChainNotInitialized, block.BlockType)
+	}
+	if !bytes.Equal(block.PrevHash, tip.Hash) {
+		return fmt.Errorf("stale block: builds on %x, %s tip is %x", block.PrevHash, block.BlockType, tip.Hash)
+	}
+	return bc.addBlock(block)
+}
+
+// difficultyTarget returns the largest hash with difficulty leading zero bytes
+func difficultyTarget(difficulty int) []byte {
+	target := bytes.Repeat([]byte{0xff}, sha256.Size)
+	for i := 0; i < difficulty && i < len(target); i++ {
+		target[i] = 0
+	}
+	return target
+}
+
+// coinbaseMerkleBranch returns the sibling hashes on the path from the first
+// transaction's leaf to the root of MerkleRoot(txs)
+func coinbaseMerkleBranch(txs []Transaction) [][]byte {
+	level := make([][]byte, len(txs))
+	for i, tx := range txs {
+		sum := sha256.Sum256(tx.ID)
+		level[i] = sum[:]
+	}
+
+	var branch [][]byte
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		branch = append(branch, level[1])
+		next := make([][]byte, 0, len(level)/2)
+		for i := 0; i < len(level); i += 2 {
+			sum := sha256.Sum256(append(append([]byte{}, level[i]...), level[i+1]...))
+			next = append(next, sum[:])
+		}
+		level = next
+	}
+	return branch
+}
+
+// MerkleRootFromBranch returns the Merkle root of a block whose first
+// transaction has coinbaseID, given the branch from a BlockTemplate
+func MerkleRootFromBranch(coinbaseID []byte, branch [][]byte) []byte {
+	sum := sha256.Sum256(coinbaseID)
+	root := sum[:]
+	for _, sibling := range branch {
+		sum = sha256.Sum256(append(append([]byte{}, root...), sibling...))
+		root = sum[:]
+	}
+	return root
+}
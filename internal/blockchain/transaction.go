@@ -2,7 +2,9 @@ package blockchain
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"sort"
@@ -82,18 +84,37 @@ func (tx *Transaction) Validate(utxoSet *UTXOSet) error {
 			}
 		}
 
-		// Verify input ownership
-		pubKey, err := crypto.BytesToPublicKey(input.PublicKey)
-		if err != nil {
+		// Verify input ownership. An x-only key spends a Taproot output by
+		// its key path and a compressed key a SegWit output; any other key
+		// is a full public key.
+		var owns bool
+		if len(input.PublicKey) == crypto.XOnlyPublicKeyLength {
+			owns = ownsTaprootOutput(utxo, input.PublicKey)
+		} else if len(input.PublicKey) == crypto.CompressedPublicKeyLength {
+			owns = ownsWitnessOutput(utxo, input.PublicKey)
+		} else {
+			pubKey, err := crypto.BytesToPublicKey(input.PublicKey)
+			if err != nil {
+				return &ValidationError{
+					Field:  fmt.Sprintf("input[%d]", i),
+					Reason: "invalid public key",
+				}
+			}
+			owns = ownsOutput(utxo, pubKey)
+		}
+		if !owns {
 			return &ValidationError{
 				Field:  fmt.Sprintf("input[%d]", i),
-				Reason: "invalid public key",
+				Reason: "unauthorized input: public key does not own the spent output",
 			}
 		}
-		if !bytes.Equal(utxo.PublicKeyHash, crypto.HashPublicKey(pubKey)) {
+
+		// The fee is taken from the claimed input amounts, so they must be
+		// the values of the outputs actually spent
+		if input.Amount != utxo.Amount {
 			return &ValidationError{
-				Field:  fmt.Sprintf("input[%d]", i),
-				Reason: "unauthorized input",
+				Field:  fmt.Sprintf("input[%d].Amount", i),
+				Reason: fmt.Sprintf("claimed amount %f does not match the spent output's %f", input.Amount, utxo.Amount),
 			}
 		}
 	}
@@ -137,42 +158,15 @@ func (tx *Transaction) Validate(utxoSet *UTXOSet) error {
 		outputBalances[output.CoinType] += output.Value
 	}
 
-	// Validate balances and handle coin conversions
-	for coinType, outputAmount := range outputBalances {
-		inputAmount := inputBalances[coinType]
-
-		// Check if we need to convert from other coins
-		if inputAmount < outputAmount {
-			// Try to convert from lower denomination coins
-			switch coinType {
-			case Shiblum:
-				leahAmount := inputBalances[Leah]
-				if leahAmount >= (outputAmount-inputAmount)*2 {
-					continue
-				}
-			case Shiblon:
-				shiblumAmount := inputBalances[Shiblum]
-				if shiblumAmount >= (outputAmount-inputAmount)*2 {
-					continue
-				}
-			case Senum:
-				shiblonAmount := inputBalances[Shiblon]
-				if shiblonAmount >= (outputAmount-inputAmount)/2 {
-					continue
-				}
-			}
-
-			return &ValidationError{
-				Field:  "balance",
-				Reason: fmt.Sprintf("insufficient balance for %s", coinType),
-			}
-		}
+	// Each coin must balance, save what mints and conversions create
+	if err := checkConservation(inputBalances, outputBalances); err != nil {
+		return err
 	}
 
-	// Validate cross-block transfers
+	// Only coins that cross between blocks may be paid out of another block
 	if tx.BlockType != "" {
 		for _, output := range tx.Outputs {
-			if !CanTransferBetweenBlocks(output.CoinType) {
+			if GetBlockType(output.CoinType) != tx.BlockType && !CanTransferBetweenBlocks(output.CoinType) {
 				return &ValidationError{
 					Field:  "block_type",
 					Reason: fmt.Sprintf("coin type %s cannot be transferred between blocks", output.CoinType),
@@ -184,19 +178,112 @@ func (tx *Transaction) Validate(utxoSet *UTXOSet) error {
 	return nil
 }
 
-// Verify verifies the transaction signature
-func (tx *Transaction) Verify() bool {
-	txCopy := tx.TrimmedCopy()
+// conversions lists the coins a transaction may create from a lower
+// denomination, with the amount of it each coin created consumes. Every
+// conversion keeps the value in Leah.
+var conversions = []struct {
+	to, from CoinType
+	rate     float64
+}{
+	{Shiblum, Leah, 2},
+	{Shiblon, Shiblum, 2},
+	{Senum, Shiblon, 2},
+}
 
-	for i, input := range tx.Inputs {
-		// Set the public key for this input
-		txCopy.Inputs[i].PublicKey = input.PublicKey
+// checkConservation checks that a transaction spending spent and creating
+// created conserves each coin type. It may create more of a coin than it
+// spends only by minting a special coin from its MintRequirements or by a
+// conversion from a lower denomination, paid for out of what it spends of
+// that coin beyond its own outputs.
+func checkConservation(spent, created map[CoinType]float64) error {
+	surplus := make(map[CoinType]float64)
+	for coinType, amount := range spent {
+		surplus[coinType] += amount
+	}
+	for coinType, amount := range created {
+		surplus[coinType] -= amount
+	}
+
+	// Coins one mint or conversion consumes are not left for another
+	for _, minted := range []CoinType{Ephraim, Manasseh, Joseph} {
+		count := -surplus[minted]
+		if count <= 0 || !canMint(minted, count, surplus) {
+			continue
+		}
+		for _, r := range MintRequirements(minted) {
+			surplus[r.CoinType] -= r.Amount * count
+		}
+		surplus[minted] = 0
+	}
+	for _, c := range conversions {
+		needed := -surplus[c.to] * c.rate
+		if needed <= 0 || surplus[c.from] < needed {
+			continue
+		}
+		surplus[c.from] -= needed
+		surplus[c.to] = 0
+	}
+
+	coinTypes := make([]CoinType, 0, len(surplus))
+	for coinType := range surplus {
+		coinTypes = append(coinTypes, coinType)
+	}
+	sort.Slice(coinTypes, func(i, j int) bool { return coinTypes[i] < coinTypes[j] })
+	for _, coinType := range coinTypes {
+		if surplus[coinType] < -1e-9 {
+			return &ValidationError{
+				Field: "balance",
+				Reason: fmt.Sprintf("insufficient balance for %s: outputs of %.8f exceed inputs of %.8f",
+					coinType, created[coinType], spent[coinType]),
+			}
+		}
+	}
+	return nil
+}
+
+// ownsOutput reports whether pubKey may spend utxo. Outputs are locked to the
+// hash of their owner's public key; outputs recorded with only an address are
+// locked to the address derived from that hash.
+func ownsOutput(utxo UTXO, pubKey *ecdsa.PublicKey) bool {
+	owner := crypto.HashPublicKey(pubKey)
+	if len(utxo.PublicKeyHash) > 0 {
+		return bytes.Equal(utxo.PublicKeyHash, owner)
+	}
+	return utxo.Address != "" && utxo.Address == hex.EncodeToString(owner)
+}
+
+// ownsWitnessOutput reports whether the compressed publicKey may spend
+// utxo. SegWit outputs are locked to the hash of the compressed key, which
+// outputs recorded with only an address hold as the SegWit address's program.
+func ownsWitnessOutput(utxo UTXO, publicKey []byte) bool {
+	if _, err := crypto.BytesToPublicKey(publicKey); err != nil {
+		return false
+	}
+	owner := sha256.Sum256(publicKey)
+	if len(utxo.PublicKeyHash) > 0 {
+		return bytes.Equal(utxo.PublicKeyHash, owner[:])
+	}
+	version, program, err := crypto.DecodeAddress(utxo.Address)
+	return err == nil && version == 0 && bytes.Equal(program, owner[:])
+}
 
-		// Calculate the hash of the transaction
-		hash := txCopy.CalculateHash()
+// ownsTaprootOutput reports whether the x-only outputKey may spend utxo by
+// its key path. Taproot outputs are locked to the output key itself, which
+// outputs recorded with only an address hold as the Taproot address's program.
+func ownsTaprootOutput(utxo UTXO, outputKey []byte) bool {
+	if len(utxo.PublicKeyHash) > 0 {
+		return bytes.Equal(utxo.PublicKeyHash, outputKey)
+	}
+	version, program, err := crypto.DecodeAddress(utxo.Address)
+	return err == nil && version == 1 && bytes.Equal(program, outputKey)
+}
 
-		// Verify the signature
-		if !crypto.Verify(hash, input.Signature, input.PublicKey) {
+// Verify verifies the transaction signature. Verification is never rate
+// limited, unlike the wallet operations that sign, so block validation may
+// check any number of signatures.
+func (tx *Transaction) Verify() bool {
+	for i := range tx.Inputs {
+		if !tx.VerifyInput(i) {
 			return false
 		}
 	}
@@ -204,6 +291,32 @@ func (tx *Transaction) Verify() bool {
 	return true
 }
 
+// VerifyInput verifies the signature of input i against its public key
+func (tx *Transaction) VerifyInput(i int) bool {
+	if i < 0 || i >= len(tx.Inputs) {
+		return false
+	}
+	input := tx.Inputs[i]
+	txCopy := tx.TrimmedCopy()
+
+	// Set the public key for this input
+	txCopy.Inputs[i].PublicKey = input.PublicKey
+
+	// Calculate the hash of the transaction and verify the signature
+	hash := txCopy.CalculateHash()
+	if input.IsSchnorr() {
+		return crypto.VerifySchnorr(hash, input.Signature, input.PublicKey)
+	}
+	return crypto.Verify(hash, input.Signature, input.PublicKey)
+}
+
+// IsSchnorr reports whether the input spends a Taproot output by its key
+// path: a 64-byte BIP-340 signature under a 32-byte x-only key. Any other
+// input is signed with ECDSA.
+func (in TxInput) IsSchnorr() bool {
+	return len(in.Signature) == crypto.SchnorrSignatureLength && len(in.PublicKey) == crypto.XOnlyPublicKeyLength
+}
+
 // TransactionBatch represents a batch of transactions
 type TransactionBatch struct {
 	Transactions []*Transaction
@@ -1,10 +1,14 @@
 package blockchain
 
 import (
+	"bytes"
 	"crypto/ecdsa"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"byc/internal/crypto"
@@ -53,15 +57,49 @@ type Block struct {
 	Nonce        uint64
 	BlockType    BlockType
 	Difficulty   int
+	// MerkleRoot commits the proof of work to the transactions. Every block
+	// but genesis must carry it.
+	MerkleRoot []byte `json:",omitempty"`
 }
 
 // Transaction represents a transaction in the blockchain
 type Transaction struct {
+	// ID is the hash of the transaction without its ID, signatures and
+	// public keys. It is computed once the inputs and outputs are final and
+	// before signing, so signing never changes it; see RecomputeID.
 	ID        []byte
 	Inputs    []TxInput
 	Outputs   []TxOutput
 	Timestamp time.Time
 	BlockType BlockType
+	// Replaceable opts the transaction into replace-by-fee while it is pending
+	Replaceable bool `json:",omitempty"`
+	// Nonce is random and part of the hash, so otherwise identical transactions get distinct IDs
+	Nonce uint64 `json:",omitempty"`
+	// CoinbaseTag is free text a miner stamps on its coinbase, such as a
+	// pool name or software version. Only a coinbase may carry one.
+	CoinbaseTag string `json:",omitempty"`
+	// ExpiryHeight, if set, is the last block height the transaction may be
+	// included at. It is part of the hash, so it cannot be changed without
+	// signing again.
+	ExpiryHeight uint64 `json:",omitempty"`
+}
+
+// ExpiredAt reports whether the transaction may no longer be included in a
+// block at height
+func (tx *Transaction) ExpiredAt(height uint64) bool {
+	return tx.ExpiryHeight != 0 && height > tx.ExpiryHeight
+}
+
+// MaxCoinbaseTagLength is the longest coinbase tag, in bytes, a block may carry
+const MaxCoinbaseTagLength = 100
+
+// CheckCoinbaseTag checks tag fits in a coinbase
+func CheckCoinbaseTag(tag string) error {
+	if len(tag) > MaxCoinbaseTagLength {
+		return fmt.Errorf("coinbase tag is %d bytes, longer than the maximum of %d", len(tag), MaxCoinbaseTagLength)
+	}
+	return nil
 }
 
 // TxInput represents a transaction input
@@ -105,18 +143,49 @@ func NewTransaction(from, to string, amount float64, coinType CoinType, inputs [
 		Outputs:   outputs,
 		Timestamp: time.Now(),
 		BlockType: GetBlockType(coinType),
+		Nonce:     NewTxNonce(),
 	}
 
-	// Calculate transaction ID
-	tx.ID = tx.CalculateHash()
+	tx.RecomputeID()
+	return tx
+}
 
+// NewCoinbaseTransaction creates a coinbase paying reward to address. Each
+// coinbase gets a random nonce, so two coinbases with the same reward and
+// recipient still have distinct IDs.
+func NewCoinbaseTransaction(address string, publicKeyHash []byte, reward float64, coinType CoinType, blockType BlockType) *Transaction {
+	tx := &Transaction{
+		Inputs: []TxInput{{OutputIndex: -1}},
+		Outputs: []TxOutput{{
+			Value:         reward,
+			CoinType:      coinType,
+			PublicKeyHash: publicKeyHash,
+			Address:       address,
+		}},
+		Timestamp: time.Now(),
+		BlockType: blockType,
+		Nonce:     NewTxNonce(),
+	}
+	tx.RecomputeID()
 	return tx
 }
 
-// CalculateHash calculates the hash of a transaction
+// NewTxNonce returns a random transaction nonce
+func NewTxNonce() uint64 {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		// Fall back to the clock; uniqueness still holds within this process
+		return uint64(time.Now().UnixNano())
+	}
+	return binary.BigEndian.Uint64(buf[:])
+}
+
+// CalculateHash calculates the hash of a transaction, leaving out its ID,
+// signatures and public keys
 func (tx *Transaction) CalculateHash() []byte {
-	// Create a copy of the transaction without signatures
+	// Create a copy of the transaction without its ID and signatures
 	txCopy := *tx
+	txCopy.ID = nil
 	txCopy.Inputs = make([]TxInput, len(tx.Inputs))
 	copy(txCopy.Inputs, tx.Inputs)
 
@@ -137,8 +206,34 @@ func (tx *Transaction) CalculateHash() []byte {
 	return hash[:]
 }
 
+// RecomputeID sets the ID to the transaction's hash. Call it after any change
+// to the inputs or outputs, before signing.
+func (tx *Transaction) RecomputeID() {
+	tx.ID = tx.CalculateHash()
+}
+
+// CheckID verifies the ID is the transaction's hash, so it cannot be changed
+// without changing the ID
+func (tx *Transaction) CheckID() error {
+	if want := tx.CalculateHash(); !bytes.Equal(tx.ID, want) {
+		return &ValidationError{
+			Field:  "id",
+			Reason: fmt.Sprintf("transaction ID %x does not match its hash %x", tx.ID, want),
+		}
+	}
+	return nil
+}
+
 // Sign signs a transaction with the given private key
 func (tx *Transaction) Sign(privateKey []byte) error {
+	return tx.SignWith(func(hash []byte, _ int) ([]byte, error) {
+		return crypto.Sign(hash, privateKey)
+	})
+}
+
+// SignWith signs each input with the signature sign returns for the hash that
+// input commits to and the input's index, so the key can be held elsewhere
+func (tx *Transaction) SignWith(sign func(hash []byte, index int) ([]byte, error)) error {
 	txCopy := tx.TrimmedCopy()
 
 	for i, input := range txCopy.Inputs {
@@ -148,8 +243,8 @@ func (tx *Transaction) Sign(privateKey []byte) error {
 		// Calculate the hash of the transaction
 		hash := txCopy.CalculateHash()
 
-		// Sign the hash with the private key
-		signature, err := crypto.Sign(hash, privateKey)
+		// Sign the hash
+		signature, err := sign(hash, i)
 		if err != nil {
 			return err
 		}
@@ -203,6 +298,27 @@ func MiningDifficulty(coinType CoinType) int {
 	}
 }
 
+// BlockSubsidy returns the base reward for mining a block of coinType, in
+// that coin: one Leah's worth for the Leah denominations and 1 otherwise
+func BlockSubsidy(coinType CoinType) float64 {
+	switch coinType {
+	case Shiblum:
+		return 0.5 // 1 Shiblum = 2 Leah
+	case Shiblon:
+		return 0.25 // 1 Shiblon = 4 Leah
+	case Senum:
+		return 0.125 // 1 Senum = 8 Leah
+	case Amnor:
+		return 0.0625 // 1 Amnor = 16 Leah
+	case Ezrom:
+		return 0.03125 // 1 Ezrom = 32 Leah
+	case Onti:
+		return 0.015625 // 1 Onti = 64 Leah
+	default:
+		return 1.0
+	}
+}
+
 // IsMineable checks if a coin type is mineable
 func IsMineable(coinType CoinType) bool {
 	switch coinType {
@@ -235,6 +351,75 @@ func GetBlockType(coinType CoinType) BlockType {
 	}
 }
 
+// MintRequirement is an amount of a coin consumed for each special coin minted
+type MintRequirement struct {
+	CoinType CoinType
+	Amount   float64
+}
+
+// MintRequirements returns what minting one of a special coin consumes: the
+// Fibonacci amounts of every Golden Block coin for an Ephraim, of every
+// Silver Block coin and Antion for a Manasseh, and one Ephraim and one
+// Manasseh for a Joseph. Other coins are not minted.
+func MintRequirements(coinType CoinType) []MintRequirement {
+	switch coinType {
+	case Ephraim:
+		return []MintRequirement{
+			{Leah, RequiredLeah},
+			{Shiblum, RequiredShiblum},
+			{Shiblon, RequiredShiblon},
+			{Senine, RequiredSenine},
+			{Seon, RequiredSeon},
+			{Shum, RequiredShum},
+			{Limnah, RequiredLimnah},
+			{Antion, RequiredAntion},
+		}
+	case Manasseh:
+		return []MintRequirement{
+			{Senum, RequiredSenum},
+			{Amnor, RequiredAmnor},
+			{Ezrom, RequiredEzrom},
+			{Onti, RequiredOnti},
+			{Antion, RequiredAntion},
+		}
+	case Joseph:
+		return []MintRequirement{{Ephraim, 1}, {Manasseh, 1}}
+	default:
+		return nil
+	}
+}
+
+// canMint reports whether balances hold what minting count of coinType consumes
+func canMint(coinType CoinType, count float64, balances map[CoinType]float64) bool {
+	requirements := MintRequirements(coinType)
+	for _, r := range requirements {
+		if balances[r.CoinType] < r.Amount*count {
+			return false
+		}
+	}
+	return len(requirements) > 0
+}
+
+// CheckMiningTarget rejects mining coinType on a chain it does not belong to,
+// since the coinbase pays out in the mined coin
+func CheckMiningTarget(coinType CoinType, blockType BlockType) error {
+	if !IsMineable(coinType) {
+		return fmt.Errorf("coin type %s is not mineable", coinType)
+	}
+	if chain := GetBlockType(coinType); chain != blockType {
+		return fmt.Errorf("coin type %s is mined on %s blocks, not %s", coinType, chain, blockType)
+	}
+	return nil
+}
+
+// Copy creates a copy of the transaction that shares no inputs or outputs with it
+func (tx *Transaction) Copy() *Transaction {
+	txCopy := *tx
+	txCopy.Inputs = append([]TxInput(nil), tx.Inputs...)
+	txCopy.Outputs = append([]TxOutput(nil), tx.Outputs...)
+	return &txCopy
+}
+
 // TrimmedCopy creates a copy of the transaction without signatures
 func (tx *Transaction) TrimmedCopy() *Transaction {
 	txCopy := *tx
@@ -288,6 +473,42 @@ func (c CoinType) String() string {
 	}
 }
 
+// AllCoinTypes lists every coin type known to the system
+var AllCoinTypes = []CoinType{
+	Leah, Shiblum, Shiblon, Senine, Seon, Shum, Limnah, Antion,
+	Senum, Amnor, Ezrom, Onti,
+	Ephraim, Manasseh, Joseph,
+}
+
+// SpecialCoinTypes lists the coins minted from other coins rather than mined
+var SpecialCoinTypes = []CoinType{Ephraim, Manasseh, Joseph}
+
+// IsValid reports whether the coin type is one of the known coins
+func (c CoinType) IsValid() bool {
+	for _, ct := range AllCoinTypes {
+		if c == ct {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCoinType converts a case-insensitive coin name into a CoinType
+func ParseCoinType(s string) (CoinType, error) {
+	name := strings.TrimSpace(s)
+	for _, ct := range AllCoinTypes {
+		if strings.EqualFold(name, string(ct)) {
+			return ct, nil
+		}
+	}
+
+	valid := make([]string, len(AllCoinTypes))
+	for i, ct := range AllCoinTypes {
+		valid[i] = strings.ToLower(string(ct))
+	}
+	return "", fmt.Errorf("unknown coin type %q (valid: %s)", s, strings.Join(valid, ", "))
+}
+
 // ConvertLeahToShiblum converts Leah to Shiblum (1 Shiblum = 2 Leah)
 func ConvertLeahToShiblum(leah float64) float64 {
 	return leah / 2
@@ -399,23 +620,12 @@ const (
 
 // CanCreateEphraim checks if the user has enough of each Golden Block coin to create an Ephraim
 func CanCreateEphraim(balances map[CoinType]float64) bool {
-	return balances[Leah] >= RequiredLeah &&
-		balances[Shiblum] >= RequiredShiblum &&
-		balances[Shiblon] >= RequiredShiblon &&
-		balances[Senine] >= RequiredSenine &&
-		balances[Seon] >= RequiredSeon &&
-		balances[Shum] >= RequiredShum &&
-		balances[Limnah] >= RequiredLimnah &&
-		balances[Antion] >= RequiredAntion
+	return canMint(Ephraim, 1, balances)
 }
 
 // CanCreateManasseh checks if the user has enough of each Silver Block coin to create a Manasseh
 func CanCreateManasseh(balances map[CoinType]float64) bool {
-	return balances[Senum] >= RequiredSenum &&
-		balances[Amnor] >= RequiredAmnor &&
-		balances[Ezrom] >= RequiredEzrom &&
-		balances[Onti] >= RequiredOnti &&
-		balances[Antion] >= RequiredAntion
+	return canMint(Manasseh, 1, balances)
 }
 
 // SpecialCoinStats tracks the creation of special coins
@@ -528,6 +738,16 @@ const (
 	MaxJosephSupply   = 3_000_000
 )
 
+// DefaultMaxSupply returns the coins whose total issuance is capped by
+// default, with their caps. Coins without an entry are uncapped.
+func DefaultMaxSupply() map[CoinType]float64 {
+	return map[CoinType]float64{
+		Ephraim:  MaxEphraimSupply,
+		Manasseh: MaxManassehSupply,
+		Joseph:   MaxJosephSupply,
+	}
+}
+
 // CreateJoseph creates a Joseph coin by combining 1 Ephraim and 1 Manasseh
 func CreateJoseph(balances map[CoinType]float64) (bool, error) {
 	// Check if we have enough Ephraim and Manasseh
@@ -563,11 +783,11 @@ func GetRemainingSupply(balances map[CoinType]float64) map[CoinType]float64 {
 }
 
 const (
-	// MaxBlockSize is the maximum size of a block in bytes
+	// MaxBlockSize is the default maximum virtual size of a block on either chain
 	MaxBlockSize = 1024 * 1024 // 1MB
 )
 
-// HasUTXO checks if a UTXO exists in the set
+// HasUTXO checks if a UTXO exists in the set. txID is hex encoded.
 func (utxoSet *UTXOSet) HasUTXO(txID string, outputIndex int) bool {
 	utxoSet.mu.RLock()
 	defer utxoSet.mu.RUnlock()
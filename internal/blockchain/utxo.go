@@ -16,6 +16,47 @@ type UTXO struct {
 	Spent         bool
 	Timestamp     int64
 	PublicKeyHash []byte
+	// IsCoinbase marks outputs created by a block reward
+	IsCoinbase bool
+	// BlockType is the chain of the block that created the output
+	BlockType BlockType
+	// Height is the height of the block that created the output on its chain
+	Height uint64
+}
+
+const (
+	// CoinbaseMaturity is the default number of confirmations a coinbase output needs before it can be spent
+	CoinbaseMaturity uint64 = 100
+	// TestnetCoinbaseMaturity is the testnet coinbase maturity, short enough
+	// to spend rewards within minutes of mining them
+	TestnetCoinbaseMaturity uint64 = 10
+	// RegtestCoinbaseMaturity is the regtest coinbase maturity, letting local
+	// test networks spend a reward from the block after it
+	RegtestCoinbaseMaturity uint64 = 1
+	// MinConfirmations is the default number of confirmations any output needs before it can be spent
+	MinConfirmations uint64 = 1
+)
+
+// Confirmations returns how many blocks, including its own, have confirmed the
+// output when the tip of its chain is at currentHeight
+func (u UTXO) Confirmations(currentHeight uint64) uint64 {
+	if currentHeight < u.Height {
+		return 0
+	}
+	return currentHeight - u.Height + 1
+}
+
+// BlocksUntilSpendable returns how many more blocks are needed under the
+// default consensus parameters before the output can be spent, or 0 if it
+// already can be
+func (u UTXO) BlocksUntilSpendable(currentHeight uint64) uint64 {
+	return DefaultConsensusParams().BlocksUntilSpendable(u, currentHeight)
+}
+
+// IsSpendable reports whether the output can be spent at currentHeight under
+// the default consensus parameters
+func (u UTXO) IsSpendable(currentHeight uint64) bool {
+	return DefaultConsensusParams().IsSpendable(u, currentHeight)
 }
 
 // UTXOSet manages the set of unspent transaction outputs
@@ -74,6 +115,36 @@ func (us *UTXOSet) GetBalance(address string, coinType CoinType) float64 {
 	return balance
 }
 
+// GetBalances returns the unspent balance of every coin type an address
+// holds, in a single pass over the set
+func (us *UTXOSet) GetBalances(address string) map[CoinType]float64 {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	balances := make(map[CoinType]float64)
+	for _, utxo := range us.utxos {
+		if utxo.Address == address && !utxo.Spent {
+			balances[utxo.CoinType] += utxo.Amount
+		}
+	}
+	return balances
+}
+
+// balancesByAddress returns the unspent balance of coinType held by each
+// address, in a single pass over the set
+func (us *UTXOSet) balancesByAddress(coinType CoinType) map[string]float64 {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	balances := make(map[string]float64)
+	for _, utxo := range us.utxos {
+		if utxo.CoinType == coinType && !utxo.Spent {
+			balances[utxo.Address] += utxo.Amount
+		}
+	}
+	return balances
+}
+
 // GetUTXOsForAddress returns all UTXOs for an address
 func (us *UTXOSet) GetUTXOsForAddress(address string, coinType CoinType) []UTXO {
 	us.mu.RLock()
@@ -139,6 +210,12 @@ func (utxoSet *UTXOSet) GetUTXOs(address string) ([]UTXO, error) {
 
 // Update updates the UTXO set with a new transaction
 func (utxoSet *UTXOSet) UpdateWithTransaction(tx *Transaction) error {
+	return utxoSet.UpdateWithTransactionAtHeight(tx, tx.Chain(), 0)
+}
+
+// UpdateWithTransactionAtHeight updates the UTXO set with a transaction
+// confirmed in the block at height on blockType's chain
+func (utxoSet *UTXOSet) UpdateWithTransactionAtHeight(tx *Transaction, blockType BlockType, height uint64) error {
 	utxoSet.mu.Lock()
 	defer utxoSet.mu.Unlock()
 
@@ -149,6 +226,22 @@ func (utxoSet *UTXOSet) UpdateWithTransaction(tx *Transaction) error {
 	}
 
 	// Add new UTXOs
+	utxoSet.addOutputs(tx, blockType, height)
+
+	return nil
+}
+
+// AddOutputs adds the outputs of tx at height on its own chain without
+// removing the outputs it spends, as when overlaying unconfirmed
+// transactions on a copy of the set
+func (utxoSet *UTXOSet) AddOutputs(tx *Transaction, height uint64) {
+	utxoSet.mu.Lock()
+	defer utxoSet.mu.Unlock()
+	utxoSet.addOutputs(tx, tx.Chain(), height)
+}
+
+// addOutputs adds the outputs of tx. Callers must hold utxoSet.mu.
+func (utxoSet *UTXOSet) addOutputs(tx *Transaction, blockType BlockType, height uint64) {
 	for i, output := range tx.Outputs {
 		utxo := UTXO{
 			TxID:          string(tx.ID),
@@ -158,12 +251,43 @@ func (utxoSet *UTXOSet) UpdateWithTransaction(tx *Transaction) error {
 			PublicKeyHash: output.PublicKeyHash,
 			CoinType:      output.CoinType,
 			Timestamp:     time.Now().Unix(),
+			IsCoinbase:    tx.IsCoinbase(),
+			BlockType:     blockType,
+			Height:        height,
 		}
 		key := fmt.Sprintf("%x:%d", tx.ID, i)
 		utxoSet.utxos[key] = utxo
 	}
+}
 
-	return nil
+// Clone returns an independent copy of the set
+func (utxoSet *UTXOSet) Clone() *UTXOSet {
+	utxoSet.mu.RLock()
+	defer utxoSet.mu.RUnlock()
+
+	clone := NewUTXOSet()
+	for key, utxo := range utxoSet.utxos {
+		clone.utxos[key] = utxo
+	}
+	return clone
+}
+
+// RevertTransaction undoes UpdateWithTransaction, removing the outputs tx
+// created and restoring spent, the outputs its inputs consumed in input order
+func (utxoSet *UTXOSet) RevertTransaction(tx *Transaction, spent []UTXO) {
+	utxoSet.mu.Lock()
+	defer utxoSet.mu.Unlock()
+
+	for i := range tx.Outputs {
+		delete(utxoSet.utxos, fmt.Sprintf("%x:%d", tx.ID, i))
+	}
+
+	for i, input := range tx.Inputs {
+		if i >= len(spent) || spent[i].TxID == "" {
+			continue
+		}
+		utxoSet.utxos[fmt.Sprintf("%x:%d", input.TxID, input.OutputIndex)] = spent[i]
+	}
 }
 
 // GetUTXO retrieves a UTXO by its transaction ID and output index
@@ -0,0 +1,113 @@
+package blockchain
+
+import "byc/internal/crypto"
+
+// WitnessScaleFactor is how many weight units a non-witness byte counts for
+const WitnessScaleFactor = 4
+
+// Room a signature and public key take in an input once it is signed
+const (
+	// maxSignatureSize is the largest DER-encoded ECDSA signature
+	maxSignatureSize = 72
+	// publicKeySize is the size of an uncompressed public key
+	publicKeySize = 65
+)
+
+// IsWitness reports whether the input's signature and public key are
+// segregated witness data. That follows from the type of output it spends,
+// which its key must match: a Taproot key-path spend signs with an x-only
+// key and a SegWit output is locked to a compressed key, while legacy
+// outputs are spent with a full public key.
+func (in TxInput) IsWitness() bool {
+	return in.IsSchnorr() || len(in.PublicKey) == crypto.CompressedPublicKeyLength
+}
+
+// SignaturePlaceholder returns a signature and public key as large as those
+// that spend an output paying address, for sizing a transaction before it is
+// signed: a BIP-340 signature and x-only key for a Taproot address, a DER
+// signature and compressed key for a SegWit address, and a DER signature and
+// uncompressed key for any other.
+func SignaturePlaceholder(address string) (signature, publicKey []byte) {
+	version, _, err := crypto.DecodeAddress(address)
+	switch {
+	case err == nil && version == 1:
+		return make([]byte, crypto.SchnorrSignatureLength), make([]byte, crypto.XOnlyPublicKeyLength)
+	case err == nil && version == 0:
+		return make([]byte, maxSignatureSize), make([]byte, crypto.CompressedPublicKeyLength)
+	default:
+		return make([]byte, maxSignatureSize), make([]byte, publicKeySize)
+	}
+}
+
+// baseSize returns the size in bytes of tx without segregated witness data
+func (tx *Transaction) baseSize() int {
+	size := len(tx.ID)
+	for _, input := range tx.Inputs {
+		size += len(input.TxID)
+		size += 8 // OutputIndex
+		size += 8 // Amount
+		size += len(input.Address)
+		if !input.IsWitness() {
+			size += len(input.Signature) + len(input.PublicKey)
+		}
+	}
+	for _, output := range tx.Outputs {
+		size += 8 // Value
+		size += len(output.CoinType)
+		size += len(output.PublicKeyHash)
+		size += len(output.Address)
+	}
+	return size
+}
+
+// witnessSize returns the size in bytes of the signatures and public keys of
+// witness inputs
+func (tx *Transaction) witnessSize() int {
+	var size int
+	for _, input := range tx.Inputs {
+		if input.IsWitness() {
+			size += len(input.Signature) + len(input.PublicKey)
+		}
+	}
+	return size
+}
+
+// Size returns the full serialized size of tx in bytes, witness data included
+func (tx *Transaction) Size() int {
+	return tx.baseSize() + tx.witnessSize()
+}
+
+// Weight returns the weight of tx: non-witness bytes count WitnessScaleFactor
+// units and witness bytes count one
+func (tx *Transaction) Weight() int {
+	return tx.baseSize()*WitnessScaleFactor + tx.witnessSize()
+}
+
+// signedVirtualSize returns the virtual size tx will have once each input is
+// signed, for a fee chosen before signing. Inputs are sized by the address
+// of the output they spend.
+func (tx *Transaction) signedVirtualSize() int {
+	signed := *tx
+	signed.Inputs = make([]TxInput, len(tx.Inputs))
+	for i, input := range tx.Inputs {
+		input.Signature, input.PublicKey = SignaturePlaceholder(input.Address)
+		signed.Inputs[i] = input
+	}
+	return signed.VirtualSize()
+}
+
+// VirtualSize returns the weight of tx divided by WitnessScaleFactor, rounded up.
+// For transactions without witness inputs it equals Size.
+func (tx *Transaction) VirtualSize() int {
+	return (tx.Weight() + WitnessScaleFactor - 1) / WitnessScaleFactor
+}
+
+// FeeRate returns the fee tx pays per virtual byte. Block assembly, fee
+// replacement and fee estimation all rank transactions by it.
+func (tx *Transaction) FeeRate() float64 {
+	vsize := tx.VirtualSize()
+	if vsize == 0 {
+		return 0
+	}
+	return tx.GetFee() / float64(vsize)
+}
@@ -0,0 +1,136 @@
+package coin
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"byc/internal/blockchain"
+)
+
+// maxUnits is the largest number of a coin's smallest units an amount may
+// hold. Below it float64 resolves amounts finer than the smallest unit, so
+// every amount survives a format and parse unchanged.
+const maxUnits = 1e15
+
+// Decimals returns the number of decimal places amounts of coinType are
+// written with. The special coins are only ever created whole; every other
+// coin is divisible down to 8 decimal places.
+func Decimals(coinType blockchain.CoinType) int {
+	switch coinType {
+	case blockchain.Ephraim, blockchain.Manasseh, blockchain.Joseph:
+		return 0
+	default:
+		return 8
+	}
+}
+
+// MaxAmount returns the largest amount of coinType FormatAmount and
+// ParseAmount round-trip exactly
+func MaxAmount(coinType blockchain.CoinType) float64 {
+	return maxUnits / math.Pow10(Decimals(coinType))
+}
+
+// FormatAmount writes value in coinType's denomination with thousands
+// separators, e.g. "1,234.50" Leah or "3" Ephraim. Trailing zeros past the
+// second decimal place are dropped.
+func FormatAmount(value float64, coinType blockchain.CoinType) string {
+	decimals := Decimals(coinType)
+	s := strconv.FormatFloat(math.Abs(value), 'f', decimals, 64)
+
+	whole, frac, _ := strings.Cut(s, ".")
+	for len(frac) > 2 && frac[len(frac)-1] == '0' {
+		frac = frac[:len(frac)-1]
+	}
+
+	var b strings.Builder
+	if value < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	if frac != "" {
+		b.WriteByte('.')
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// ParseAmount parses an amount of coinType written as FormatAmount writes
+// it. Thousands separators are optional but must group three digits; the
+// amount may not be negative, have more decimal places than the coin allows
+// or exceed MaxAmount.
+func ParseAmount(s string, coinType blockchain.CoinType) (float64, error) {
+	decimals := Decimals(coinType)
+	text := strings.TrimSpace(s)
+	whole, frac, hasPoint := strings.Cut(text, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if strings.HasPrefix(whole, "-") {
+		return 0, fmt.Errorf("amount %q must not be negative", s)
+	}
+
+	digits, err := stripSeparators(whole)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %v", s, err)
+	}
+	if hasPoint && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q: missing digits after the decimal point", s)
+	}
+	if !allDigits(frac) {
+		return 0, fmt.Errorf("invalid amount %q: unexpected character after the decimal point", s)
+	}
+	if len(frac) > decimals {
+		return 0, fmt.Errorf("invalid amount %q: %s allows at most %d decimal places", s, coinType, decimals)
+	}
+
+	// Count in whole units of the smallest denomination so the limit and
+	// the conversion to float64 are exact
+	units, err := strconv.ParseUint(digits+frac+strings.Repeat("0", decimals-len(frac)), 10, 64)
+	if err != nil || units > maxUnits {
+		return 0, fmt.Errorf("amount %q exceeds the maximum of %s %s",
+			s, FormatAmount(MaxAmount(coinType), coinType), coinType)
+	}
+	return float64(units) / math.Pow10(decimals), nil
+}
+
+// stripSeparators removes the thousands separators from the whole part of
+// an amount, checking they group the digits in threes
+func stripSeparators(whole string) (string, error) {
+	if whole == "" {
+		return "0", nil
+	}
+	if !strings.Contains(whole, ",") {
+		if !allDigits(whole) {
+			return "", fmt.Errorf("unexpected character")
+		}
+		return whole, nil
+	}
+
+	groups := strings.Split(whole, ",")
+	for i, group := range groups {
+		if !allDigits(group) || group == "" {
+			return "", fmt.Errorf("unexpected character")
+		}
+		if (i == 0 && len(group) > 3) || (i > 0 && len(group) != 3) {
+			return "", fmt.Errorf("thousands separators must group three digits")
+		}
+	}
+	return strings.Join(groups, ""), nil
+}
+
+// allDigits reports whether s holds only ASCII digits
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package coin
+
+import (
+	"testing"
+
+	"byc/internal/blockchain"
+)
+
+// TestAmountRoundTrip tests that formatted amounts parse back to the same
+// value and format the same way again
+func TestAmountRoundTrip(t *testing.T) {
+	tests := []struct {
+		value    float64
+		coinType blockchain.CoinType
+		want     string
+	}{
+		{0, blockchain.Leah, "0.00"},
+		{5, blockchain.Leah, "5.00"},
+		{0.5, blockchain.Shiblum, "0.50"},
+		{0.015625, blockchain.Onti, "0.015625"},
+		{0.00000001, blockchain.Senum, "0.00000001"},
+		{1234567.125, blockchain.Antion, "1,234,567.125"},
+		{999.99, blockchain.Shiblon, "999.99"},
+		{1000, blockchain.Amnor, "1,000.00"},
+		{MaxAmount(blockchain.Leah), blockchain.Leah, "10,000,000.00"},
+		{9999999.99999999, blockchain.Leah, "9,999,999.99999999"},
+		{0, blockchain.Ephraim, "0"},
+		{3, blockchain.Manasseh, "3"},
+		{1500000, blockchain.Joseph, "1,500,000"},
+		{MaxAmount(blockchain.Joseph), blockchain.Joseph, "1,000,000,000,000,000"},
+	}
+
+	for _, tt := range tests {
+		got := FormatAmount(tt.value, tt.coinType)
+		if got != tt.want {
+			t.Errorf("FormatAmount(%v, %s) = %q, want %q", tt.value, tt.coinType, got, tt.want)
+			continue
+		}
+
+		parsed, err := ParseAmount(got, tt.coinType)
+		if err != nil {
+			t.Errorf("ParseAmount(%q, %s) failed: %v", got, tt.coinType, err)
+			continue
+		}
+		if parsed != tt.value {
+			t.Errorf("ParseAmount(%q, %s) = %v, want %v", got, tt.coinType, parsed, tt.value)
+		}
+		if again := FormatAmount(parsed, tt.coinType); again != got {
+			t.Errorf("FormatAmount after round trip = %q, want %q", again, got)
+		}
+	}
+}
+
+// TestParseAmount tests the accepted spellings of an amount and the
+// rejected ones
+func TestParseAmount(t *testing.T) {
+	valid := []struct {
+		input    string
+		coinType blockchain.CoinType
+		want     float64
+	}{
+		{"12", blockchain.Leah, 12},
+		{" 12.5 ", blockchain.Leah, 12.5},
+		{".25", blockchain.Shiblon, 0.25},
+		{"1234567.5", blockchain.Leah, 1234567.5},
+		{"1,234,567.5", blockchain.Leah, 1234567.5},
+		{"0", blockchain.Ephraim, 0},
+		{"2,000", blockchain.Ephraim, 2000},
+	}
+	for _, tt := range valid {
+		got, err := ParseAmount(tt.input, tt.coinType)
+		if err != nil {
+			t.Errorf("ParseAmount(%q, %s) failed: %v", tt.input, tt.coinType, err)
+		} else if got != tt.want {
+			t.Errorf("ParseAmount(%q, %s) = %v, want %v", tt.input, tt.coinType, got, tt.want)
+		}
+	}
+
+	invalid := []struct {
+		input    string
+		coinType blockchain.CoinType
+	}{
+		{"", blockchain.Leah},
+		{".", blockchain.Leah},
+		{"abc", blockchain.Leah},
+		{"-1", blockchain.Leah},
+		{"1e5", blockchain.Leah},
+		{"12.", blockchain.Leah},
+		{"1.2.3", blockchain.Leah},
+		{"0.000000001", blockchain.Leah},
+		{"1.5", blockchain.Ephraim},
+		{"1,23", blockchain.Leah},
+		{"1234,567", blockchain.Leah},
+		{",123", blockchain.Leah},
+		{"1,234.5,0", blockchain.Leah},
+		{"10,000,000.00000001", blockchain.Leah},
+		{"1,000,000,000,000,001", blockchain.Joseph},
+		{"99999999999999999999999", blockchain.Leah},
+	}
+	for _, tt := range invalid {
+		if got, err := ParseAmount(tt.input, tt.coinType); err == nil {
+			t.Errorf("ParseAmount(%q, %s) = %v, want an error", tt.input, tt.coinType, got)
+		}
+	}
+}
+
+// TestFormatAmountNegative tests that negative amounts keep their sign and
+// that a negative zero does not
+func TestFormatAmountNegative(t *testing.T) {
+	if got := FormatAmount(-1234.5, blockchain.Leah); got != "-1,234.50" {
+		t.Errorf("FormatAmount(-1234.5) = %q, want %q", got, "-1,234.50")
+	}
+	if got := FormatAmount(-0.000000001, blockchain.Leah); got != "0.00" {
+		t.Errorf("FormatAmount of a negative amount rounding to zero = %q, want %q", got, "0.00")
+	}
+}
@@ -8,10 +8,16 @@ import (
 	"time"
 
 	"byc/internal/blockchain"
+	"byc/internal/logger"
+	"byc/internal/security"
 )
 
 // Config represents the complete configuration
 type Config struct {
+	// DataDir roots all node state: the node identity and mempool, plus the
+	// wallets, backups, db and logs subdirectories (see Paths)
+	DataDir string `json:"data_dir"`
+
 	API struct {
 		Address string `json:"address"`
 		CORS    struct {
@@ -29,17 +35,22 @@ type Config struct {
 	} `json:"api"`
 
 	P2P struct {
-		Address        string        `json:"address"`
-		BootstrapPeers []string      `json:"bootstrap_peers"`
-		MaxPeers       int           `json:"max_peers"`
-		PingInterval   time.Duration `json:"ping_interval"`
-		PingTimeout    time.Duration `json:"ping_timeout"`
+		Address             string        `json:"address"`
+		BootstrapPeers      []string      `json:"bootstrap_peers"`
+		MaxConnections      int           `json:"max_connections"`
+		MaxConnectionsPerIP int           `json:"max_connections_per_ip"`
+		PingInterval        time.Duration `json:"ping_interval"`
+		PingTimeout         time.Duration `json:"ping_timeout"`
 	} `json:"p2p"`
 
 	Logging struct {
-		Level  string `json:"level"`
-		Format string `json:"format"`
-		Output string `json:"output"`
+		Level      string `json:"level"`
+		Format     string `json:"format"`
+		Output     string `json:"output"`
+		MaxSize    int    `json:"max_size"`
+		MaxBackups int    `json:"max_backups"`
+		MaxAge     int    `json:"max_age"`
+		Compress   bool   `json:"compress"`
 	} `json:"logging"`
 
 	Blockchain struct {
@@ -61,6 +72,7 @@ type Config struct {
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
+		DataDir: "data",
 		API: struct {
 			Address string `json:"address"`
 			CORS    struct {
@@ -100,26 +112,35 @@ func DefaultConfig() *Config {
 			},
 		},
 		P2P: struct {
-			Address        string        `json:"address"`
-			BootstrapPeers []string      `json:"bootstrap_peers"`
-			MaxPeers       int           `json:"max_peers"`
-			PingInterval   time.Duration `json:"ping_interval"`
-			PingTimeout    time.Duration `json:"ping_timeout"`
+			Address             string        `json:"address"`
+			BootstrapPeers      []string      `json:"bootstrap_peers"`
+			MaxConnections      int           `json:"max_connections"`
+			MaxConnectionsPerIP int           `json:"max_connections_per_ip"`
+			PingInterval        time.Duration `json:"ping_interval"`
+			PingTimeout         time.Duration `json:"ping_timeout"`
 		}{
-			Address:        "localhost:3001",
-			BootstrapPeers: []string{},
-			MaxPeers:       100,
-			PingInterval:   30 * time.Second,
-			PingTimeout:    10 * time.Second,
+			Address:             "localhost:3001",
+			BootstrapPeers:      []string{},
+			MaxConnections:      security.DefaultMaxConnections,
+			MaxConnectionsPerIP: security.DefaultMaxConnectionsPerIP,
+			PingInterval:        30 * time.Second,
+			PingTimeout:         10 * time.Second,
 		},
 		Logging: struct {
-			Level  string `json:"level"`
-			Format string `json:"format"`
-			Output string `json:"output"`
+			Level      string `json:"level"`
+			Format     string `json:"format"`
+			Output     string `json:"output"`
+			MaxSize    int    `json:"max_size"`
+			MaxBackups int    `json:"max_backups"`
+			MaxAge     int    `json:"max_age"`
+			Compress   bool   `json:"compress"`
 		}{
-			Level:  "info",
-			Format: "json",
-			Output: "stdout",
+			Level:      "info",
+			Format:     "json",
+			Output:     "stdout",
+			MaxSize:    logger.DefaultMaxSize,
+			MaxBackups: logger.DefaultMaxBackups,
+			MaxAge:     logger.DefaultMaxAge,
 		},
 		Blockchain: struct {
 			BlockType    blockchain.BlockType `json:"block_type"`
@@ -140,7 +161,7 @@ func DefaultConfig() *Config {
 			TargetBlocksPerMinute int    `json:"target_blocks_per_minute"`
 		}{
 			Enabled:               true,
-			CoinType:              "BTC",
+			CoinType:              "LEAH",
 			AutoStart:             true,
 			MaxThreads:            4,
 			TargetBlocksPerMinute: 6,
@@ -148,7 +169,10 @@ func DefaultConfig() *Config {
 	}
 }
 
-// LoadConfig loads the configuration from a file
+// LoadConfig loads the configuration from a file. Settings the file leaves
+// out keep their DefaultConfig values, so a file written before a setting
+// existed still loads with a working value for it. The p2p "max_peers" key
+// of older files is read as "max_connections".
 func LoadConfig(path string) (*Config, error) {
 	// Read the config file
 	data, err := os.ReadFile(path)
@@ -156,13 +180,29 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	// Parse the config
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
+	// Parse the config over the defaults
+	config := DefaultConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	var legacy struct {
+		P2P struct {
+			MaxPeers       int  `json:"max_peers"`
+			MaxConnections *int `json:"max_connections"`
+		} `json:"p2p"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
+	if legacy.P2P.MaxConnections == nil && legacy.P2P.MaxPeers > 0 {
+		config.P2P.MaxConnections = legacy.P2P.MaxPeers
+		if config.P2P.MaxConnectionsPerIP > config.P2P.MaxConnections {
+			config.P2P.MaxConnectionsPerIP = config.P2P.MaxConnections
+		}
+	}
 
-	return &config, nil
+	return config, nil
 }
 
 // SaveConfig saves the configuration to a file
@@ -198,8 +238,12 @@ func (c *Config) Validate() error {
 	}
 
 	// Validate P2P config
-	if c.P2P.MaxPeers <= 0 || c.P2P.MaxPeers > 1000 {
-		return fmt.Errorf("invalid max peers: %d", c.P2P.MaxPeers)
+	if c.P2P.MaxConnections <= 0 || c.P2P.MaxConnections > 1000 {
+		return fmt.Errorf("invalid max connections: %d", c.P2P.MaxConnections)
+	}
+
+	if c.P2P.MaxConnectionsPerIP <= 0 || c.P2P.MaxConnectionsPerIP > c.P2P.MaxConnections {
+		return fmt.Errorf("invalid max connections per IP: %d", c.P2P.MaxConnectionsPerIP)
 	}
 
 	if c.P2P.PingInterval <= 0 {
@@ -210,6 +254,12 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid ping timeout: %v", c.P2P.PingTimeout)
 	}
 
+	// Validate logging config
+	if c.Logging.MaxSize < 0 || c.Logging.MaxBackups < 0 || c.Logging.MaxAge < 0 {
+		return fmt.Errorf("invalid log rotation: max size %d, max backups %d, max age %d",
+			c.Logging.MaxSize, c.Logging.MaxBackups, c.Logging.MaxAge)
+	}
+
 	// Validate Blockchain config
 	if c.Blockchain.Difficulty <= 0 {
 		return fmt.Errorf("invalid difficulty: %d", c.Blockchain.Difficulty)
@@ -231,6 +281,9 @@ func (c *Config) Validate() error {
 		if c.Mining.TargetBlocksPerMinute <= 0 {
 			return fmt.Errorf("invalid target blocks per minute: %d", c.Mining.TargetBlocksPerMinute)
 		}
+		if _, err := blockchain.ParseCoinType(c.Mining.CoinType); err != nil {
+			return fmt.Errorf("invalid mining coin type: %v", err)
+		}
 	}
 
 	return nil
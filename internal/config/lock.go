@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LockFile is the name of the lock file held in the data directory
+const LockFile = ".lock"
+
+// ErrDataDirInUse is returned when another process holds the data directory lock
+var ErrDataDirInUse = errors.New("data directory already in use")
+
+// DataDirLock is an exclusive lock on a data directory. The lock file is
+// left in place on release; only the lock on it guards the directory.
+type DataDirLock struct {
+	file *os.File
+}
+
+// LockDataDir acquires an exclusive lock on dataDir, failing fast with
+// ErrDataDirInUse if another process already holds it
+func LockDataDir(dataDir string) (*DataDirLock, error) {
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %v", err)
+	}
+
+	path := filepath.Join(dataDir, LockFile)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		if errors.Is(err, ErrDataDirInUse) {
+			return nil, fmt.Errorf("%w: %s", ErrDataDirInUse, dataDir)
+		}
+		return nil, fmt.Errorf("failed to lock data directory: %v", err)
+	}
+
+	// Record the holder to help diagnose a stale lock
+	file.Truncate(0)
+	fmt.Fprintf(file, "%d\n", os.Getpid())
+
+	return &DataDirLock{file: file}, nil
+}
+
+// Release releases the lock
+func (l *DataDirLock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	err := unlockFile(l.file)
+	l.file.Close()
+	l.file = nil
+	return err
+}
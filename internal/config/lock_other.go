@@ -0,0 +1,26 @@
+//go:build !linux && !darwin && !windows
+
+package config
+
+import (
+	"os"
+	"sync"
+)
+
+// heldLocks tracks lock files held by this process; without flock the lock
+// only guards against reuse within a process
+var heldLocks sync.Map
+
+// lockFile marks file as held
+func lockFile(file *os.File) error {
+	if _, held := heldLocks.LoadOrStore(file.Name(), true); held {
+		return ErrDataDirInUse
+	}
+	return nil
+}
+
+// unlockFile clears the held mark on file
+func unlockFile(file *os.File) error {
+	heldLocks.Delete(file.Name())
+	return nil
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockDataDir(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := LockDataDir(dir)
+	require.NoError(t, err)
+
+	// A second acquisition fails while the first holds the lock
+	_, err = LockDataDir(dir)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrDataDirInUse))
+	assert.Contains(t, err.Error(), "data directory already in use")
+
+	// A different data dir is unaffected
+	other, err := LockDataDir(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, other.Release())
+
+	// Released locks can be acquired again
+	require.NoError(t, lock.Release())
+	lock, err = LockDataDir(dir)
+	require.NoError(t, err)
+	require.NoError(t, lock.Release())
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Subdirectories of the data directory
+const (
+	WalletsSubdir = "wallets"
+	BackupsSubdir = "backups"
+	DBSubdir      = "db"
+	LogsSubdir    = "logs"
+)
+
+// Paths is the on-disk layout rooted at a data directory. Node-level files
+// such as the identity key and mempool live directly in Root.
+type Paths struct {
+	Root    string
+	Wallets string
+	Backups string
+	DB      string
+	Logs    string
+}
+
+// NewPaths returns the layout rooted at dataDir
+func NewPaths(dataDir string) Paths {
+	if dataDir == "" {
+		dataDir = "."
+	}
+	return Paths{
+		Root:    dataDir,
+		Wallets: filepath.Join(dataDir, WalletsSubdir),
+		Backups: filepath.Join(dataDir, BackupsSubdir),
+		DB:      filepath.Join(dataDir, DBSubdir),
+		Logs:    filepath.Join(dataDir, LogsSubdir),
+	}
+}
+
+// Ensure creates the data directory and its subdirectories
+func (p Paths) Ensure() error {
+	for _, dir := range []string{p.Root, p.Wallets, p.Backups, p.DB, p.Logs} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create data directory %s: %v", dir, err)
+		}
+	}
+	return nil
+}
+
+// Paths returns the layout rooted at the configured data directory
+func (c *Config) Paths() Paths {
+	return NewPaths(c.DataDir)
+}
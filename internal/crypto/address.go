@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// LegacyAddressVersion is the version DecodeAddress reports for the original
+// bare hex addresses, which carry no witness version
+const LegacyAddressVersion = -1
+
+// AddressHRP is the human-readable part of SegWit and Taproot addresses,
+// which are bech32 and bech32m strings starting "byc1q" and "byc1p"
+const AddressHRP = "byc"
+
+// EncodeAddress writes the 32 bytes an address commits to, a key hash or
+// Taproot output key, in the format of its witness version
+func EncodeAddress(version int, program []byte) string {
+	switch version {
+	case 0, 1:
+		return encodeWitnessAddress(AddressHRP, version, program)
+	default:
+		return hex.EncodeToString(program)
+	}
+}
+
+// DecodeAddress returns the witness version of an address, or
+// LegacyAddressVersion, and the 32 bytes it commits to. The bytes are what
+// outputs paying the address are locked to.
+func DecodeAddress(address string) (int, []byte, error) {
+	version, program := LegacyAddressVersion, []byte(nil)
+	if strings.HasPrefix(strings.ToLower(address), AddressHRP+"1") {
+		var err error
+		version, program, err = decodeWitnessAddress(AddressHRP, address)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid address %q: %v", address, err)
+		}
+		if version > 1 {
+			return 0, nil, fmt.Errorf("invalid address %q: unsupported witness version %d", address, version)
+		}
+	} else {
+		var err error
+		if program, err = hex.DecodeString(address); err != nil {
+			return 0, nil, fmt.Errorf("invalid address %q: %v", address, err)
+		}
+	}
+	if len(program) != sha256.Size {
+		return 0, nil, fmt.Errorf("invalid address %q: %d bytes, want %d", address, len(program), sha256.Size)
+	}
+	return version, program, nil
+}
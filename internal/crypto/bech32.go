@@ -0,0 +1,170 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// bech32Charset maps 5-bit values to the characters of a bech32 string.
+// Witness addresses are encoded as in BIP-173 and BIP-350: version 0 with
+// the bech32 checksum, later versions with bech32m.
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// Checksum constants the polymod of a valid string equals
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+// bech32MaxLength bounds an encoded string, as BIP-173 does
+const bech32MaxLength = 90
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// bech32HRPExpand spreads the human-readable part into the values the
+// checksum covers
+func bech32HRPExpand(hrp string) []byte {
+	values := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]>>5)
+	}
+	values = append(values, 0)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]&31)
+	}
+	return values
+}
+
+// bech32Encode writes hrp and the 5-bit values in data with the checksum
+// selected by constant
+func bech32Encode(hrp string, data []byte, constant uint32) string {
+	values := append(bech32HRPExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ constant
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range data {
+		sb.WriteByte(bech32Charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(bech32Charset[(polymod>>(5*(5-i)))&31])
+	}
+	return sb.String()
+}
+
+// bech32Decode splits s into its human-readable part and 5-bit data values,
+// and returns the checksum constant it was encoded with
+func bech32Decode(s string) (string, []byte, uint32, error) {
+	if len(s) > bech32MaxLength {
+		return "", nil, 0, errors.New("too long")
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, 0, errors.New("mixed case")
+	}
+	s = strings.ToLower(s)
+
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, 0, errors.New("missing separator or checksum")
+	}
+	hrp := s[:sep]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, 0, fmt.Errorf("invalid character %q", hrp[i])
+		}
+	}
+	data := make([]byte, 0, len(s)-sep-1)
+	for _, c := range s[sep+1:] {
+		v := strings.IndexRune(bech32Charset, c)
+		if v < 0 {
+			return "", nil, 0, fmt.Errorf("invalid character %q", c)
+		}
+		data = append(data, byte(v))
+	}
+
+	constant := bech32Polymod(append(bech32HRPExpand(hrp), data...))
+	if constant != bech32Const && constant != bech32mConst {
+		return "", nil, 0, errors.New("invalid checksum")
+	}
+	return hrp, data[:len(data)-6], constant, nil
+}
+
+// convertBits regroups data from fromBits-bit to toBits-bit values. Encoding
+// pads the last group with zeros; decoding rejects padding that is not.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxValue := uint32(1)<<toBits - 1
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, errors.New("invalid data value")
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxValue))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, errors.New("invalid padding")
+	}
+	return out, nil
+}
+
+// encodeWitnessAddress encodes a witness program under hrp
+func encodeWitnessAddress(hrp string, version int, program []byte) string {
+	data, _ := convertBits(program, 8, 5, true)
+	constant := uint32(bech32mConst)
+	if version == 0 {
+		constant = bech32Const
+	}
+	return bech32Encode(hrp, append([]byte{byte(version)}, data...), constant)
+}
+
+// decodeWitnessAddress decodes a witness address under hrp, checking the
+// checksum matches its version
+func decodeWitnessAddress(hrp, address string) (int, []byte, error) {
+	gotHRP, data, constant, err := bech32Decode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if gotHRP != hrp {
+		return 0, nil, fmt.Errorf("prefix %q, want %q", gotHRP, hrp)
+	}
+	if len(data) == 0 {
+		return 0, nil, errors.New("missing witness version")
+	}
+	version := int(data[0])
+	if version > 16 {
+		return 0, nil, fmt.Errorf("invalid witness version %d", version)
+	}
+	if (version == 0) != (constant == bech32Const) {
+		return 0, nil, fmt.Errorf("wrong checksum variant for witness version %d", version)
+	}
+	program, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	return version, program, nil
+}
@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -10,6 +11,10 @@ import (
 	"math/big"
 )
 
+// CompressedPublicKeyLength is the size of a public key written as its x
+// coordinate and the parity of y
+const CompressedPublicKeyLength = 33
+
 // PrivateKeyToBytes converts an ECDSA private key to bytes
 func PrivateKeyToBytes(privateKey *ecdsa.PrivateKey) []byte {
 	return privateKey.D.Bytes()
@@ -20,50 +25,64 @@ func PublicKeyToBytes(publicKey *ecdsa.PublicKey) []byte {
 	return elliptic.Marshal(publicKey.Curve, publicKey.X, publicKey.Y)
 }
 
-// BytesToPrivateKey converts bytes to an ECDSA private key
+// BytesToPrivateKey converts bytes to a secp256k1 private key
 func BytesToPrivateKey(privateKeyBytes []byte) (*ecdsa.PrivateKey, error) {
-	curve := elliptic.P256()
+	return privateKeyOn(S256(), privateKeyBytes), nil
+}
+
+// BytesToPrivateKeyFor converts bytes to the private key of publicKeyBytes,
+// on whichever curve the public key is on
+func BytesToPrivateKeyFor(privateKeyBytes, publicKeyBytes []byte) (*ecdsa.PrivateKey, error) {
+	curve, err := curveOf(publicKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := privateKeyOn(curve, privateKeyBytes)
+	if !bytes.Equal(PublicKeyToBytes(&privateKey.PublicKey), publicKeyBytes) {
+		return nil, errors.New("private key does not match public key")
+	}
+	return privateKey, nil
+}
+
+// privateKeyOn returns the private key with scalar privateKeyBytes on curve
+func privateKeyOn(curve elliptic.Curve, privateKeyBytes []byte) *ecdsa.PrivateKey {
 	privateKey := new(ecdsa.PrivateKey)
 	privateKey.Curve = curve
 	privateKey.D = new(big.Int).SetBytes(privateKeyBytes)
 
 	// Calculate public key
 	privateKey.PublicKey.X, privateKey.PublicKey.Y = curve.ScalarBaseMult(privateKey.D.Bytes())
-
-	return privateKey, nil
+	return privateKey
 }
 
-// BytesToPublicKey converts bytes to an ECDSA public key
+// BytesToPublicKey converts bytes, an uncompressed or compressed point, to
+// an ECDSA public key on the curve the point lies on
 func BytesToPublicKey(publicKeyBytes []byte) (*ecdsa.PublicKey, error) {
-	curve := elliptic.P256()
-	x, y := elliptic.Unmarshal(curve, publicKeyBytes)
-	if x == nil {
-		return nil, errors.New("invalid public key bytes")
-	}
-
-	return &ecdsa.PublicKey{
-		Curve: curve,
-		X:     x,
-		Y:     y,
-	}, nil
+	return parsePublicKey(publicKeyBytes)
 }
 
-// Sign signs a message using the private key
+// Sign signs a message using a secp256k1 private key. The signature is in
+// low-S form, see IsLowS.
 func Sign(message []byte, privateKeyBytes []byte) ([]byte, error) {
-	curve := elliptic.P256()
-	privateKey := new(ecdsa.PrivateKey)
-	privateKey.Curve = curve
-	privateKey.D = new(big.Int).SetBytes(privateKeyBytes)
-
-	// Calculate public key
-	privateKey.PublicKey.X, privateKey.PublicKey.Y = curve.ScalarBaseMult(privateKey.D.Bytes())
+	return SignKey(message, privateKeyOn(S256(), privateKeyBytes))
+}
 
+// SignKey signs a message with privateKey on the key's own curve. The
+// signature is in low-S form, see IsLowS.
+func SignKey(message []byte, privateKey *ecdsa.PrivateKey) ([]byte, error) {
 	// Sign the message
 	r, s, err := ecdsa.Sign(rand.Reader, privateKey, message)
 	if err != nil {
 		return nil, err
 	}
 
+	// (r, s) and (r, n-s) both verify; always giving the lower keeps the
+	// signature from being altered without the key
+	n := privateKey.Curve.Params().N
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s.Sub(n, s)
+	}
+
 	// Encode the signature
 	signature, err := asn1.Marshal(struct {
 		R, S *big.Int
@@ -96,6 +115,24 @@ func Verify(message []byte, signature []byte, publicKeyBytes []byte) bool {
 	return ecdsa.Verify(publicKey, message, sig.R, sig.S)
 }
 
+// IsLowS reports whether an ECDSA signature, as Sign encodes it, has an S
+// value no greater than half the order of the curve publicKeyBytes is on.
+// Anyone can turn a valid signature into a second valid one by replacing S
+// with n-S, so requiring the low form makes signatures non-malleable.
+func IsLowS(signature, publicKeyBytes []byte) bool {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(signature, &sig); err != nil || sig.S == nil {
+		return false
+	}
+	curve, err := curveOf(publicKeyBytes)
+	if err != nil {
+		return false
+	}
+	return sig.S.Cmp(new(big.Int).Rsh(curve.Params().N, 1)) <= 0
+}
+
 // SerializePublicKey serializes an ECDSA public key to bytes
 func SerializePublicKey(pub *ecdsa.PublicKey) []byte {
 	if pub == nil {
@@ -117,7 +154,7 @@ func HashPublicKey(pub *ecdsa.PublicKey) []byte {
 // GenerateKeyPair generates a new key pair
 func GenerateKeyPair() ([]byte, []byte, error) {
 	// Generate a new private key
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	privateKey, err := GenerateKey()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -130,3 +167,121 @@ func GenerateKeyPair() ([]byte, []byte, error) {
 
 	return privateKeyBytes, publicKeyBytes, nil
 }
+
+// RecoverableSignatureLength is the size of a recoverable signature: R and S
+// as 32-byte big-endian integers followed by a one-byte recovery ID
+const RecoverableSignatureLength = 65
+
+// SignRecoverable signs a message hash so that the signer's public key can be
+// recovered from the signature alone. The key must be on secp256k1.
+func SignRecoverable(hash []byte, privateKey *ecdsa.PrivateKey) ([]byte, error) {
+	if privateKey.Curve != S256() {
+		return nil, ErrNotSecp256k1
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, privateKey, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := make([]byte, RecoverableSignatureLength)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:64])
+
+	// Find the recovery ID that yields our own public key
+	for recID := byte(0); recID < 4; recID++ {
+		signature[64] = recID
+		pub, err := RecoverPublicKey(hash, signature)
+		if err == nil && pub.X.Cmp(privateKey.X) == 0 && pub.Y.Cmp(privateKey.Y) == 0 {
+			return signature, nil
+		}
+	}
+
+	return nil, errors.New("failed to compute signature recovery ID")
+}
+
+// RecoverPublicKey recovers the secp256k1 public key that produced a
+// signature from SignRecoverable over the given message hash
+func RecoverPublicKey(hash []byte, signature []byte) (*ecdsa.PublicKey, error) {
+	if len(signature) != RecoverableSignatureLength {
+		return nil, errors.New("invalid recoverable signature length")
+	}
+
+	curve := S256()
+	params := curve.Params()
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:64])
+	recID := signature[64]
+	if recID > 3 {
+		return nil, errors.New("invalid signature recovery ID")
+	}
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(params.N) >= 0 || s.Cmp(params.N) >= 0 {
+		return nil, errors.New("invalid signature values")
+	}
+
+	// Reconstruct R from its x coordinate and the parity of y
+	x := new(big.Int).Set(r)
+	if recID&2 != 0 {
+		x.Add(x, params.N)
+	}
+	if x.Cmp(params.P) >= 0 {
+		return nil, errors.New("invalid signature recovery ID")
+	}
+	y := curveY(curve, x)
+	if y == nil {
+		return nil, errors.New("signature does not correspond to a curve point")
+	}
+	if y.Bit(0) != uint(recID&1) {
+		y.Sub(params.P, y)
+	}
+
+	// Q = r^-1 (sR - eG)
+	e := hashToInt(hash, params.N)
+	rInv := new(big.Int).ModInverse(r, params.N)
+	u1 := new(big.Int).Mul(s, rInv)
+	u1.Mod(u1, params.N)
+	u2 := new(big.Int).Neg(e)
+	u2.Mul(u2, rInv)
+	u2.Mod(u2, params.N)
+
+	x1, y1 := curve.ScalarMult(x, y, u1.Bytes())
+	x2, y2 := curve.ScalarBaseMult(u2.Bytes())
+	qx, qy := curve.Add(x1, y1, x2, y2)
+	if qx.Sign() == 0 && qy.Sign() == 0 {
+		return nil, errors.New("recovered point at infinity")
+	}
+
+	pub := &ecdsa.PublicKey{Curve: curve, X: qx, Y: qy}
+	if !ecdsa.Verify(pub, hash, r, s) {
+		return nil, errors.New("signature verification failed for recovered key")
+	}
+	return pub, nil
+}
+
+// curveY returns a y coordinate for x on curve, or nil if x is not on it.
+// secp256k1 has a = 0 and the NIST curves a = -3.
+func curveY(curve elliptic.Curve, x *big.Int) *big.Int {
+	params := curve.Params()
+	// y² = x³ + ax + b
+	y2 := new(big.Int).Exp(x, big.NewInt(3), params.P)
+	if curve != S256() {
+		threeX := new(big.Int).Mul(x, big.NewInt(3))
+		y2.Sub(y2, threeX)
+	}
+	y2.Add(y2, params.B)
+	y2.Mod(y2, params.P)
+	return new(big.Int).ModSqrt(y2, params.P)
+}
+
+// hashToInt converts a hash to an integer the same way crypto/ecdsa does,
+// truncating it to the bit length of the curve order
+func hashToInt(hash []byte, n *big.Int) *big.Int {
+	orderBytes := (n.BitLen() + 7) / 8
+	if len(hash) > orderBytes {
+		hash = hash[:orderBytes]
+	}
+	e := new(big.Int).SetBytes(hash)
+	if excess := len(hash)*8 - n.BitLen(); excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+	return e
+}
@@ -0,0 +1,327 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Bad hex %q: %v", s, err)
+	}
+	return b
+}
+
+func TestSecp256k1Curve(t *testing.T) {
+	curve := S256()
+	params := curve.Params()
+	if !curve.IsOnCurve(params.Gx, params.Gy) {
+		t.Fatal("Generator is not on the curve")
+	}
+
+	x, y := curve.ScalarBaseMult([]byte{2})
+	if want := mustHex(t, "C6047F9441ED7D6D3045406E95C07CD85C778E4B8CEF3CA7ABAC09B95C709EE5"); !bytes.Equal(bytes32(x), want) {
+		t.Errorf("2G has x %X, want %X", bytes32(x), want)
+	}
+	if dx, dy := curve.Double(params.Gx, params.Gy); dx.Cmp(x) != 0 || dy.Cmp(y) != 0 {
+		t.Error("Double(G) differs from 2G")
+	}
+	if ax, ay := curve.Add(params.Gx, params.Gy, params.Gx, params.Gy); ax.Cmp(x) != 0 || ay.Cmp(y) != 0 {
+		t.Error("G + G differs from 2G")
+	}
+	if nx, ny := curve.ScalarBaseMult(params.N.Bytes()); nx.Sign() != 0 || ny.Sign() != 0 {
+		t.Error("nG is not the point at infinity")
+	}
+}
+
+func TestGeneratedKeysSignAcrossSchemes(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	if key.Curve != S256() {
+		t.Fatalf("Expected keys on secp256k1 by default, got %s", key.Curve.Params().Name)
+	}
+	hash := sha256.Sum256([]byte("same key, every scheme"))
+
+	// ECDSA through the byte-oriented helpers used for transactions
+	pub, err := BytesToPublicKey(PublicKeyToBytes(&key.PublicKey))
+	if err != nil {
+		t.Fatalf("BytesToPublicKey failed: %v", err)
+	}
+	if pub.X.Cmp(key.X) != 0 || pub.Y.Cmp(key.Y) != 0 {
+		t.Error("Public key did not round-trip")
+	}
+	signature, err := Sign(hash[:], PrivateKeyToBytes(key))
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if !Verify(hash[:], signature, PublicKeyToBytes(&key.PublicKey)) {
+		t.Error("ECDSA signature did not verify")
+	}
+
+	// Recoverable ECDSA
+	recoverable, err := SignRecoverable(hash[:], key)
+	if err != nil {
+		t.Fatalf("SignRecoverable failed: %v", err)
+	}
+	recovered, err := RecoverPublicKey(hash[:], recoverable)
+	if err != nil || recovered.X.Cmp(key.X) != 0 || recovered.Y.Cmp(key.Y) != 0 {
+		t.Errorf("RecoverPublicKey did not return the signer: %v", err)
+	}
+
+	// Schnorr
+	xOnly, err := SchnorrPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("SchnorrPublicKey failed: %v", err)
+	}
+	schnorr, err := SignSchnorr(hash[:], key)
+	if err != nil {
+		t.Fatalf("SignSchnorr failed: %v", err)
+	}
+	if !VerifySchnorr(hash[:], schnorr, xOnly) {
+		t.Error("Schnorr signature did not verify")
+	}
+
+	// Taproot key path, with and without a script tree
+	for _, merkleRoot := range [][]byte{nil, bytes.Repeat([]byte{0xab}, 32)} {
+		outputKey, err := TaprootOutputKey(&key.PublicKey, merkleRoot)
+		if err != nil {
+			t.Fatalf("TaprootOutputKey failed: %v", err)
+		}
+		taproot, err := SignTaproot(hash[:], key, merkleRoot)
+		if err != nil {
+			t.Fatalf("SignTaproot failed: %v", err)
+		}
+		if !VerifySchnorr(hash[:], taproot, outputKey) {
+			t.Error("Taproot signature did not verify against the output key")
+		}
+		if VerifySchnorr(hash[:], taproot, xOnly) {
+			t.Error("Taproot signature verified against the untweaked key")
+		}
+	}
+
+	other := sha256.Sum256([]byte("a different message"))
+	if VerifySchnorr(other[:], schnorr, xOnly) {
+		t.Error("Schnorr signature verified for a different message")
+	}
+}
+
+func TestVerifySchnorrVectors(t *testing.T) {
+	// Test vectors 0 and 1 from BIP-340
+	vectors := []struct {
+		publicKey, message, signature string
+	}{
+		{
+			"F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0",
+		},
+		{
+			"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			"243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			"6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A",
+		},
+	}
+	for i, v := range vectors {
+		if !VerifySchnorr(mustHex(t, v.message), mustHex(t, v.signature), mustHex(t, v.publicKey)) {
+			t.Errorf("Vector %d did not verify", i)
+		}
+	}
+}
+
+func TestLegacyP256Keys(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	if key.Curve != S256() {
+		t.Fatalf("Expected a secp256k1 key, got %s", key.Curve.Params().Name)
+	}
+
+	// Legacy P-256 keys are recognized by their point and still work for ECDSA
+	legacy, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	pub := PublicKeyToBytes(&legacy.PublicKey)
+	parsed, err := BytesToPublicKey(pub)
+	if err != nil || parsed.Curve != elliptic.P256() {
+		t.Fatalf("Expected the public key to parse on P-256, got %v", err)
+	}
+	restored, err := BytesToPrivateKeyFor(PrivateKeyToBytes(legacy), pub)
+	if err != nil || restored.Curve != elliptic.P256() {
+		t.Fatalf("Expected the private key to restore on P-256, got %v", err)
+	}
+	if _, err := BytesToPrivateKeyFor(PrivateKeyToBytes(key), pub); err == nil {
+		t.Error("Expected a private key not matching the public key to be rejected")
+	}
+	hash := sha256.Sum256([]byte("legacy"))
+	signature, err := SignKey(hash[:], restored)
+	if err != nil || !Verify(hash[:], signature, pub) || !IsLowS(signature, pub) {
+		t.Errorf("P-256 ECDSA round trip failed: %v", err)
+	}
+
+	// but cannot be used for Schnorr, Taproot or recoverable signatures
+	if _, err := SignSchnorr(hash[:], legacy); !errors.Is(err, ErrNotSecp256k1) {
+		t.Errorf("Expected ErrNotSecp256k1 signing Schnorr with P-256, got %v", err)
+	}
+	if _, err := TaprootOutputKey(&legacy.PublicKey, nil); !errors.Is(err, ErrNotSecp256k1) {
+		t.Errorf("Expected ErrNotSecp256k1 deriving a Taproot key from P-256, got %v", err)
+	}
+	if _, err := SignRecoverable(hash[:], legacy); !errors.Is(err, ErrNotSecp256k1) {
+		t.Errorf("Expected ErrNotSecp256k1 signing recoverably with P-256, got %v", err)
+	}
+
+	// and a signature by one key does not verify under the other's curve
+	if Verify(hash[:], signature, PublicKeyToBytes(&key.PublicKey)) {
+		t.Error("Expected a P-256 signature not to verify under a secp256k1 key")
+	}
+}
+
+// TestSignIsLowS tests that Sign always gives low-S signatures and that
+// IsLowS tells the two forms apart
+func TestSignIsLowS(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	pub := PublicKeyToBytes(&key.PublicKey)
+	n := S256().Params().N
+
+	for i := 0; i < 32; i++ {
+		hash := sha256.Sum256([]byte{byte(i)})
+		signature, err := Sign(hash[:], key.D.Bytes())
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		if !IsLowS(signature, pub) {
+			t.Fatalf("Sign gave a high-S signature %x", signature)
+		}
+
+		// The high-S twin verifies too, which is why it is ruled out
+		var sig struct {
+			R, S *big.Int
+		}
+		if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		high, err := asn1.Marshal(struct {
+			R, S *big.Int
+		}{sig.R, new(big.Int).Sub(n, sig.S)})
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if !Verify(hash[:], high, pub) {
+			t.Fatal("Expected the high-S signature to verify")
+		}
+		if IsLowS(high, pub) {
+			t.Fatal("Expected IsLowS to reject the high-S signature")
+		}
+	}
+	if IsLowS([]byte("not a signature"), pub) {
+		t.Error("Expected IsLowS to reject a malformed signature")
+	}
+}
+
+func TestAddressRoundTrip(t *testing.T) {
+	program := sha256.Sum256([]byte("address"))
+	for _, version := range []int{LegacyAddressVersion, 0, 1} {
+		address := EncodeAddress(version, program[:])
+		gotVersion, gotProgram, err := DecodeAddress(address)
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %v", address, err)
+		}
+		if gotVersion != version || !bytes.Equal(gotProgram, program[:]) {
+			t.Errorf("%s decoded to version %d program %x", address, gotVersion, gotProgram)
+		}
+	}
+
+	segwit, taproot := EncodeAddress(0, program[:]), EncodeAddress(1, program[:])
+	if !strings.HasPrefix(segwit, "byc1q") || !strings.HasPrefix(taproot, "byc1p") {
+		t.Errorf("Unexpected witness address prefixes: %s, %s", segwit, taproot)
+	}
+	if _, _, err := DecodeAddress(strings.ToUpper(segwit)); err != nil {
+		t.Errorf("Expected an upper-case address to decode: %v", err)
+	}
+
+	// One mistyped character breaks the checksum
+	typo := []byte(segwit)
+	if typo[10] == 'q' {
+		typo[10] = 'p'
+	} else {
+		typo[10] = 'q'
+	}
+
+	// A version 0 program under the bech32m checksum, or the reverse
+	data, _ := convertBits(program[:], 8, 5, true)
+	swapped := []string{
+		bech32Encode(AddressHRP, append([]byte{0}, data...), bech32mConst),
+		bech32Encode(AddressHRP, append([]byte{1}, data...), bech32Const),
+	}
+
+	for _, address := range append([]string{
+		"", "miner", "byc1q00", hex.EncodeToString(program[:16]),
+		"byc1q" + hex.EncodeToString(program[:]),
+		string(typo),
+		segwit[:8] + strings.ToUpper(segwit[8:]),
+		EncodeAddress(0, program[:16]),
+		encodeWitnessAddress("bc", 0, program[:]),
+		encodeWitnessAddress(AddressHRP, 2, program[:]),
+	}, swapped...) {
+		if _, _, err := DecodeAddress(address); err == nil {
+			t.Errorf("Expected %q not to decode", address)
+		}
+	}
+}
+
+// TestBech32Vectors checks the witness address codec against vectors from
+// BIP-173 and BIP-350
+func TestBech32Vectors(t *testing.T) {
+	for _, tt := range []struct {
+		address string
+		version int
+		program string
+	}{
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", 0, "751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3", 0, "1863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", 1, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	} {
+		version, program, err := decodeWitnessAddress("bc", tt.address)
+		if err != nil {
+			t.Errorf("Failed to decode %s: %v", tt.address, err)
+			continue
+		}
+		if version != tt.version || hex.EncodeToString(program) != tt.program {
+			t.Errorf("%s decoded to version %d program %x", tt.address, version, program)
+		}
+		if got := encodeWitnessAddress("bc", version, program); got != strings.ToLower(tt.address) {
+			t.Errorf("Re-encoded %s as %s", tt.address, got)
+		}
+	}
+
+	for _, address := range []string{
+		// bech32 checksums on version 1 and 16 programs, bech32m on version 0
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd",
+		"BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL",
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh",
+		// Invalid checksum, and more than four bits of padding
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5",
+		"bc1zw508d6qejxtdg4y5r3zarvaryvqyzf3du",
+	} {
+		if _, _, err := decodeWitnessAddress("bc", address); err == nil {
+			t.Errorf("Expected %q not to decode", address)
+		}
+	}
+}
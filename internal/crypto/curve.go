@@ -0,0 +1,60 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// On-chain keys are generated on secp256k1. Keys and addresses created
+// before secp256k1 became the standard are on P-256; their public keys are
+// still recognized by the curve their point lies on, so legacy funds can be
+// spent, and should then be moved to a new secp256k1 address. P-256 keys
+// cannot sign Schnorr, Taproot or recoverable signatures.
+
+// S256 returns the secp256k1 curve
+func S256() elliptic.Curve {
+	return secp256k1.S256()
+}
+
+// curves lists the curves a public key may be on, the standard one first
+var curves = []elliptic.Curve{S256(), elliptic.P256()}
+
+// GenerateKey generates a new secp256k1 private key
+func GenerateKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(S256(), rand.Reader)
+}
+
+// curveOf returns the curve a public key's point lies on
+func curveOf(publicKeyBytes []byte) (elliptic.Curve, error) {
+	publicKey, err := parsePublicKey(publicKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return publicKey.Curve, nil
+}
+
+// parsePublicKey decodes an uncompressed or compressed public key on the
+// first curve its point lies on
+func parsePublicKey(publicKeyBytes []byte) (*ecdsa.PublicKey, error) {
+	if len(publicKeyBytes) == CompressedPublicKeyLength {
+		// elliptic only decompresses points on curves with a = -3, which
+		// secp256k1 is not
+		if publicKey, err := secp256k1.ParsePubKey(publicKeyBytes); err == nil {
+			return publicKey.ToECDSA(), nil
+		}
+		if x, y := elliptic.UnmarshalCompressed(elliptic.P256(), publicKeyBytes); x != nil {
+			return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+		}
+		return nil, errors.New("invalid public key bytes")
+	}
+	for _, curve := range curves {
+		if x, y := elliptic.Unmarshal(curve, publicKeyBytes); x != nil {
+			return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+		}
+	}
+	return nil, errors.New("invalid public key bytes")
+}
@@ -0,0 +1,197 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// SchnorrSignatureLength is the size of a BIP-340 signature: the x coordinate
+// of R followed by s
+const SchnorrSignatureLength = 64
+
+// XOnlyPublicKeyLength is the size of a BIP-340 public key, its x coordinate
+const XOnlyPublicKeyLength = 32
+
+// ErrNotSecp256k1 is returned when a Schnorr, Taproot or recoverable
+// signature operation is given a key on another curve
+var ErrNotSecp256k1 = errors.New("key is not on secp256k1")
+
+// taggedHash is the BIP-340 tagged hash SHA256(SHA256(tag) || SHA256(tag) || data...)
+func taggedHash(tag string, data ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// bytes32 returns n as a 32-byte big-endian integer
+func bytes32(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, 32))
+}
+
+// liftX returns the point with x coordinate x and an even y, or false if x is
+// not on secp256k1
+func liftX(x *big.Int) (*big.Int, *big.Int, bool) {
+	p := S256().Params().P
+	if x.Cmp(p) >= 0 {
+		return nil, nil, false
+	}
+	y := curveY(S256(), x)
+	if y == nil {
+		return nil, nil, false
+	}
+	if y.Bit(0) == 1 {
+		y.Sub(p, y)
+	}
+	return x, y, true
+}
+
+// SchnorrPublicKey returns the 32-byte x-only form of a secp256k1 public key
+func SchnorrPublicKey(pub *ecdsa.PublicKey) ([]byte, error) {
+	if pub.Curve != S256() {
+		return nil, ErrNotSecp256k1
+	}
+	return bytes32(pub.X), nil
+}
+
+// SignSchnorr produces a BIP-340 signature over a 32-byte message hash
+func SignSchnorr(hash []byte, privateKey *ecdsa.PrivateKey) ([]byte, error) {
+	if privateKey.Curve != S256() {
+		return nil, ErrNotSecp256k1
+	}
+	return signSchnorr(hash, privateKey.D)
+}
+
+// signSchnorr signs with the secret scalar d
+func signSchnorr(hash []byte, d *big.Int) ([]byte, error) {
+	n := S256().Params().N
+	if d.Sign() == 0 || d.Cmp(n) >= 0 {
+		return nil, errors.New("invalid private key")
+	}
+
+	// Use the secret for the public key with an even y
+	px, py := S256().ScalarBaseMult(bytes32(d))
+	if py.Bit(0) == 1 {
+		d = new(big.Int).Sub(n, d)
+	}
+
+	aux := make([]byte, 32)
+	if _, err := rand.Read(aux); err != nil {
+		return nil, err
+	}
+	t := bytes32(d)
+	for i, b := range taggedHash("BIP0340/aux", aux) {
+		t[i] ^= b
+	}
+
+	k := new(big.Int).SetBytes(taggedHash("BIP0340/nonce", t, bytes32(px), hash))
+	k.Mod(k, n)
+	if k.Sign() == 0 {
+		return nil, errors.New("derived a zero nonce")
+	}
+	rx, ry := S256().ScalarBaseMult(bytes32(k))
+	if ry.Bit(0) == 1 {
+		k.Sub(n, k)
+	}
+
+	e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", bytes32(rx), bytes32(px), hash))
+	e.Mod(e, n)
+
+	s := new(big.Int).Mul(e, d)
+	s.Add(s, k)
+	s.Mod(s, n)
+
+	return append(bytes32(rx), bytes32(s)...), nil
+}
+
+// VerifySchnorr checks a BIP-340 signature over hash against a 32-byte x-only
+// public key
+func VerifySchnorr(hash, signature, publicKey []byte) bool {
+	if len(signature) != SchnorrSignatureLength || len(publicKey) != XOnlyPublicKeyLength {
+		return false
+	}
+	params := S256().Params()
+
+	px, py, ok := liftX(new(big.Int).SetBytes(publicKey))
+	if !ok {
+		return false
+	}
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if r.Cmp(params.P) >= 0 || s.Cmp(params.N) >= 0 {
+		return false
+	}
+
+	e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", signature[:32], publicKey, hash))
+	e.Mod(e, params.N)
+
+	// R = sG - eP
+	sx, sy := S256().ScalarBaseMult(bytes32(s))
+	ex, ey := S256().ScalarMult(px, py, bytes32(e))
+	ey.Sub(params.P, ey)
+	ey.Mod(ey, params.P)
+	rx, ry := S256().Add(sx, sy, ex, ey)
+	if rx.Sign() == 0 && ry.Sign() == 0 {
+		return false
+	}
+	return ry.Bit(0) == 0 && rx.Cmp(r) == 0
+}
+
+// taprootTweak returns the BIP-341 tweak of an x-only internal key committing
+// to merkleRoot, which is empty for a key-path-only output
+func taprootTweak(internalKey, merkleRoot []byte) (*big.Int, error) {
+	t := new(big.Int).SetBytes(taggedHash("TapTweak", internalKey, merkleRoot))
+	if t.Cmp(S256().Params().N) >= 0 {
+		return nil, errors.New("taproot tweak out of range")
+	}
+	return t, nil
+}
+
+// TaprootOutputKey returns the x-only output key Q = P + tG that a Taproot
+// output locks to, for the internal key P and script tree merkleRoot
+func TaprootOutputKey(internalKey *ecdsa.PublicKey, merkleRoot []byte) ([]byte, error) {
+	xOnly, err := SchnorrPublicKey(internalKey)
+	if err != nil {
+		return nil, err
+	}
+	px, py, _ := liftX(internalKey.X)
+	t, err := taprootTweak(xOnly, merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+	tx, ty := S256().ScalarBaseMult(bytes32(t))
+	qx, qy := S256().Add(px, py, tx, ty)
+	if qx.Sign() == 0 && qy.Sign() == 0 {
+		return nil, errors.New("taproot output key is the point at infinity")
+	}
+	return bytes32(qx), nil
+}
+
+// SignTaproot signs hash with the key-path secret of the Taproot output for
+// privateKey and merkleRoot. The signature verifies with VerifySchnorr against
+// TaprootOutputKey.
+func SignTaproot(hash []byte, privateKey *ecdsa.PrivateKey, merkleRoot []byte) ([]byte, error) {
+	if privateKey.Curve != S256() {
+		return nil, ErrNotSecp256k1
+	}
+	n := S256().Params().N
+
+	d := new(big.Int).Set(privateKey.D)
+	if privateKey.Y.Bit(0) == 1 {
+		d.Sub(n, d)
+	}
+	t, err := taprootTweak(bytes32(privateKey.X), merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+	d.Add(d, t)
+	d.Mod(d, n)
+	return signSchnorr(hash, d)
+}
@@ -62,6 +62,7 @@ type SystemHealth struct {
 // ComponentHealth represents health status of a component
 type ComponentHealth struct {
 	Status    string
+	Message   string
 	LastCheck time.Time
 	Error     error
 }
@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying id, which FromContext adds to
+// every log line so the lines of one request can be found together
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "" if there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext returns the logger tagged with the request ID carried by ctx
+func FromContext(ctx context.Context) *zap.Logger {
+	checkLogger()
+	if id := RequestID(ctx); id != "" {
+		return log.With(zap.String("request_id", id))
+	}
+	return log
+}
+
+// Replace swaps the logger for l and returns a function restoring the
+// previous one, for tests that inspect log output
+func Replace(l *zap.Logger) func() {
+	previous := log
+	log = l
+	return func() { log = previous }
+}
@@ -2,18 +2,64 @@ package logger
 
 import (
 	"fmt"
+	"os"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var log *zap.Logger
 
-// Init initializes the logger
+// DefaultFile is the name of the log file the node keeps in its logs directory
+const DefaultFile = "byc.log"
+
+// stderr is where log entries always go, whether or not a file is configured
+var stderr zapcore.WriteSyncer = zapcore.Lock(os.Stderr)
+
+// Config selects where log entries are written
+type Config struct {
+	// File receives a copy of every entry; empty logs to stderr only
+	File string
+	// MaxSize is the size in megabytes at which File is rotated; 0 never rotates
+	MaxSize int
+	// MaxBackups is how many rotated files are kept; 0 keeps them all
+	MaxBackups int
+	// MaxAge is how many days rotated files are kept; 0 keeps them regardless of age
+	MaxAge int
+	// Compress gzips rotated files
+	Compress bool
+}
+
+// Init initializes the logger writing to stderr
 func Init() error {
-	var err error
-	log, err = zap.NewProduction()
-	if err != nil {
-		return err
+	return InitWithConfig(Config{})
+}
+
+// InitWithConfig initializes the logger. A log file that can't be opened is
+// not an error: the logger falls back to stderr alone and logs a warning, so
+// an unwritable log directory doesn't keep the node from starting.
+func InitWithConfig(cfg Config) error {
+	sink := stderr
+	var fileErr error
+	if cfg.File != "" {
+		file, err := openRotatingFile(cfg.File, cfg)
+		if err != nil {
+			fileErr = err
+		} else {
+			sink = zapcore.NewMultiWriteSyncer(stderr, file)
+		}
+	}
+
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	log = zap.New(zapcore.NewCore(encoder, sink, zap.InfoLevel),
+		zap.AddCaller(),
+		zap.AddStacktrace(zap.ErrorLevel),
+	)
+
+	if fileErr != nil {
+		log.Warn("Failed to open log file, logging to stderr only",
+			zap.String("file", cfg.File),
+			zap.Error(fileErr))
 	}
 	return nil
 }
@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestInitFallsBackToStderr(t *testing.T) {
+	var buf bytes.Buffer
+	oldStderr := stderr
+	stderr = zapcore.AddSync(&buf)
+	defer func() { stderr = oldStderr }()
+
+	// A path under a regular file can never be created
+	blocker := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(blocker, nil, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := InitWithConfig(Config{File: filepath.Join(blocker, "byc.log")}); err != nil {
+		t.Fatalf("Expected an unwritable log file to fall back to stderr, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "logging to stderr only") {
+		t.Errorf("Expected a warning about the log file, got %q", buf.String())
+	}
+
+	Info("still logging")
+	if !strings.Contains(buf.String(), "still logging") {
+		t.Errorf("Expected entries to reach stderr, got %q", buf.String())
+	}
+}
+
+func TestInitWritesLogFile(t *testing.T) {
+	var buf bytes.Buffer
+	oldStderr := stderr
+	stderr = zapcore.AddSync(&buf)
+	defer func() { stderr = oldStderr }()
+
+	path := filepath.Join(t.TempDir(), "logs", "byc.log")
+	if err := InitWithConfig(Config{File: path}); err != nil {
+		t.Fatalf("InitWithConfig failed: %v", err)
+	}
+	Info("to both sinks")
+	Sync()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !strings.Contains(string(data), "to both sinks") || !strings.Contains(buf.String(), "to both sinks") {
+		t.Errorf("Expected the entry in the file and on stderr, got %q and %q", data, buf.String())
+	}
+}
+
+func TestLogFileRotation(t *testing.T) {
+	var buf bytes.Buffer
+	oldStderr := stderr
+	stderr = zapcore.AddSync(&buf)
+	defer func() { stderr = oldStderr }()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "byc.log")
+	if err := InitWithConfig(Config{File: path, MaxSize: 1, MaxBackups: 1, Compress: true}); err != nil {
+		t.Fatalf("InitWithConfig failed: %v", err)
+	}
+
+	// Write a little over three megabytes so the file rotates several times
+	filler := strings.Repeat("x", 1024)
+	for i := 0; i < 3*1024+16; i++ {
+		Info(filler)
+	}
+	Sync()
+
+	backups, err := filepath.Glob(filepath.Join(dir, "byc-*.log.gz"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("Expected one compressed backup to be kept, got %v", backups)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() > megabyte {
+		t.Errorf("Expected the live log to stay under the size limit, got %d bytes", info.Size())
+	}
+}
+
+func TestLogFileKeepsWritingAfterFailedRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "byc.log")
+	r, err := openRotatingFile(path, Config{MaxSize: 1})
+	if err != nil {
+		t.Fatalf("openRotatingFile failed: %v", err)
+	}
+
+	// With the live file gone, moving it to a backup fails
+	if _, err := r.Write([]byte("first\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	r.size = megabyte
+	if _, err := r.Write([]byte("rotates\n")); err == nil {
+		t.Error("Expected the failed rotation to be reported")
+	}
+
+	if _, err := r.Write([]byte("after\n")); err != nil {
+		t.Fatalf("Expected writes to go on after a failed rotation, got %v", err)
+	}
+	if err := r.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "after\n" {
+		t.Errorf("Expected the reopened file to hold the later write, got %q", data)
+	}
+}
@@ -0,0 +1,220 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxSize is the log file size in megabytes at which it is rotated
+	DefaultMaxSize = 100
+	// DefaultMaxBackups is how many rotated log files are kept
+	DefaultMaxBackups = 5
+	// DefaultMaxAge is how many days rotated log files are kept
+	DefaultMaxAge = 28
+)
+
+// backupTimeFormat names rotated files so they sort oldest first
+const backupTimeFormat = "2006-01-02T15-04-05.000000000"
+
+const megabyte = 1024 * 1024
+
+// rotatingFile is a log file that is moved aside to a timestamped backup once
+// it would grow past maxSize, keeping at most maxBackups backups no older
+// than maxAge. Backups are compressed and pruned in the background so
+// rotating does not hold up logging.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+	file       *os.File
+	size       int64
+
+	// mill tracks the background compression and pruning of backups
+	mill sync.WaitGroup
+	// millMu runs one backup's compression and pruning at a time and guards
+	// millErr, the last error doing so
+	millMu  sync.Mutex
+	millErr error
+}
+
+// openRotatingFile opens path for appending, creating its directory if needed
+func openRotatingFile(path string, cfg Config) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    int64(cfg.MaxSize) * megabyte,
+		maxBackups: cfg.MaxBackups,
+		maxAge:     time.Duration(cfg.MaxAge) * 24 * time.Hour,
+		compress:   cfg.Compress,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past maxSize. A
+// failed rotation is reported but leaves the file open, so later writes are
+// not lost with it.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, fmt.Errorf("failed to rotate log file: %v", err)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Sync flushes the file to disk and waits for rotated backups to be
+// compressed and pruned, returning the last error doing so
+func (r *rotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mill.Wait()
+	r.millMu.Lock()
+	err := r.millErr
+	r.millErr = nil
+	r.millMu.Unlock()
+
+	if r.file == nil {
+		return err
+	}
+	if syncErr := r.file.Sync(); syncErr != nil {
+		return syncErr
+	}
+	return err
+}
+
+// rotate moves the current file to a backup and starts a new one, handing
+// the backup to the background to compress and prune. If the file cannot be
+// moved it is reopened in place. Callers must hold r.mu.
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	ext := filepath.Ext(r.path)
+	backup := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.path, ext), time.Now().UTC().Format(backupTimeFormat), ext)
+	if err := os.Rename(r.path, backup); err != nil {
+		if openErr := r.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.mill.Add(1)
+	go r.millBackup(backup)
+	return nil
+}
+
+// millBackup compresses backup if configured and prunes old backups
+func (r *rotatingFile) millBackup(backup string) {
+	defer r.mill.Done()
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
+
+	if r.compress {
+		// A backup pruned before its turn came needs no compressing
+		if err := compressFile(backup); err != nil && !os.IsNotExist(err) {
+			r.millErr = err
+		}
+	}
+	if err := r.prune(); err != nil {
+		r.millErr = err
+	}
+}
+
+// prune removes backups beyond maxBackups and older than maxAge
+func (r *rotatingFile) prune() error {
+	ext := filepath.Ext(r.path)
+	backups, err := filepath.Glob(strings.TrimSuffix(r.path, ext) + "-*" + ext + "*")
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups)
+
+	for i, backup := range backups {
+		expired := r.maxBackups > 0 && i < len(backups)-r.maxBackups
+		if !expired && r.maxAge > 0 {
+			if info, err := os.Stat(backup); err == nil && time.Since(info.ModTime()) > r.maxAge {
+				expired = true
+			}
+		}
+		if expired {
+			if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compressFile replaces path with a gzipped copy at path.gz
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,47 @@
+package mining
+
+import "time"
+
+// HashRateInterval is the window over which the reported hash rate is averaged
+const HashRateInterval = 10 * time.Second
+
+// calculateHashRate returns the hashes per second for delta hashes computed
+// over interval
+func calculateHashRate(delta int64, interval time.Duration) int64 {
+	if delta <= 0 || interval <= 0 {
+		return 0
+	}
+	return int64(float64(delta) / interval.Seconds())
+}
+
+// hashRateSampler turns a monotonically increasing hash counter into a
+// rolling hash rate, recomputed once every HashRateInterval
+type hashRateSampler struct {
+	sampleTime   time.Time
+	sampleHashes int64
+	rate         int64
+}
+
+// reset starts a new sampling window at now with the given counter value
+func (s *hashRateSampler) reset(now time.Time, hashes int64) {
+	s.sampleTime = now
+	s.sampleHashes = hashes
+	s.rate = 0
+}
+
+// update records the counter value at now and returns the current hash rate.
+// The rate only changes once a full interval has elapsed since the last sample.
+func (s *hashRateSampler) update(now time.Time, hashes int64) int64 {
+	if s.sampleTime.IsZero() || hashes < s.sampleHashes {
+		s.reset(now, hashes)
+		return s.rate
+	}
+
+	elapsed := now.Sub(s.sampleTime)
+	if elapsed >= HashRateInterval {
+		s.rate = calculateHashRate(hashes-s.sampleHashes, elapsed)
+		s.sampleTime = now
+		s.sampleHashes = hashes
+	}
+	return s.rate
+}
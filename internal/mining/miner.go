@@ -3,6 +3,7 @@ package mining
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -24,6 +25,7 @@ type WalletInfo struct {
 // Status represents the current mining status
 type Status struct {
 	HashRate         int64
+	Hashes           int64
 	Shares           int64
 	BlocksFound      int64
 	Difficulty       int
@@ -33,6 +35,7 @@ type Status struct {
 	IsRunning        bool
 	StartTime        time.Time
 	EndTime          time.Time
+	Uptime           time.Duration
 	CurrentBlock     time.Time
 	CurrentReward    float64
 	TotalRewards     float64
@@ -50,18 +53,53 @@ type Miner struct {
 	stopChan   chan struct{}
 	wg         sync.WaitGroup
 	status     Status
+	hashRate   hashRateSampler
 	mu         sync.RWMutex
 	walletFile string
+	// rewardAddress, if set, is paid every block reward in place of the
+	// mining wallet
+	rewardAddress string
+	// rewardWallet, if set, is an HD wallet handing out a fresh receive
+	// address of rewardAccount for each block. rotatedAddress is the one
+	// derived for the block being mined, reused by every template until a
+	// block pays it.
+	rewardWallet   *wallet.Wallet
+	rewardAccount  uint32
+	rotatedAddress string
+	// coins, if set, are mined in turn, one block each; nextCoin indexes
+	// the coin the next block mines
+	coins    []blockchain.CoinType
+	nextCoin int
+	// coinbaseTag is stamped on the coinbase of every block mined
+	coinbaseTag string
+	// targetBits, if set, is the difficulty every block is mined at in
+	// place of the chain's
+	targetBits int
+	// template is the template being mined, nil before the first
+	template *blockchain.BlockTemplate
+	// refresh receives when a block joins or leaves the chain being mined,
+	// making the template stale
+	refresh chan struct{}
+	// retemplate is set when the last template went stale, so the next one
+	// mines the same coin rather than the next in turn
+	retemplate bool
 }
 
-// NewMiner creates a new miner
+// DefaultWalletsDir is the directory NewMiner keeps the mining wallet in
+const DefaultWalletsDir = "wallets"
+
+// NewMiner creates a new miner keeping its wallet in DefaultWalletsDir
 func NewMiner(bc *blockchain.Blockchain, blockType blockchain.BlockType, coinType blockchain.CoinType, address string) (*Miner, error) {
-	if !blockchain.IsMineable(coinType) {
-		return nil, fmt.Errorf("coin type %s is not mineable", coinType)
+	return NewMinerWithWalletsDir(DefaultWalletsDir, bc, blockType, coinType, address)
+}
+
+// NewMinerWithWalletsDir creates a new miner keeping its wallet in walletsDir
+func NewMinerWithWalletsDir(walletsDir string, bc *blockchain.Blockchain, blockType blockchain.BlockType, coinType blockchain.CoinType, address string) (*Miner, error) {
+	if err := blockchain.CheckMiningTarget(coinType, blockType); err != nil {
+		return nil, err
 	}
 
 	// Create wallets directory if it doesn't exist
-	walletsDir := "wallets"
 	if err := os.MkdirAll(walletsDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create wallets directory: %v", err)
 	}
@@ -89,6 +127,11 @@ func NewMiner(bc *blockchain.Blockchain, blockType blockchain.BlockType, coinTyp
 			rewards[blockchain.CoinType(coinType)] = amount
 		}
 
+		// Rewards paid to a malformed address could never be spent
+		if _, err := wallet.ParseAddress(walletInfo.Address); err != nil {
+			return nil, fmt.Errorf("mining wallet %s has an invalid reward address: %v", walletFile, err)
+		}
+
 		// Create wallet with existing address
 		miningWallet = &wallet.Wallet{Address: walletInfo.Address}
 	} else {
@@ -107,8 +150,9 @@ func NewMiner(bc *blockchain.Blockchain, blockType blockchain.BlockType, coinTyp
 		CoinType:   coinType,
 		Address:    address,
 		stopChan:   make(chan struct{}),
+		refresh:    make(chan struct{}, 1),
 		status: Status{
-			Difficulty:   bc.Difficulty * blockchain.MiningDifficulty(coinType),
+			Difficulty:   bc.CalculateDifficulty(blockType) * blockchain.MiningDifficulty(coinType),
 			MiningWallet: miningWallet,
 			Rewards:      rewards,
 		},
@@ -146,28 +190,15 @@ func (m *Miner) saveWallet() error {
 
 // calculateReward calculates the mining reward based on coin type and difficulty
 func (m *Miner) calculateReward() float64 {
-	baseReward := 1.0 // Base reward in the coin being mined
-
-	// Adjust reward based on coin type
-	switch m.CoinType {
-	case blockchain.Leah:
-		baseReward = 1.0
-	case blockchain.Shiblum:
-		baseReward = 0.5 // 1 Shiblum = 2 Leah
-	case blockchain.Shiblon:
-		baseReward = 0.25 // 1 Shiblon = 4 Leah
-	case blockchain.Senum:
-		baseReward = 0.125 // 1 Senum = 8 Leah
-	case blockchain.Amnor:
-		baseReward = 0.0625 // 1 Amnor = 16 Leah
-	case blockchain.Ezrom:
-		baseReward = 0.03125 // 1 Ezrom = 32 Leah
-	case blockchain.Onti:
-		baseReward = 0.015625 // 1 Onti = 64 Leah
-	}
-
-	// Adjust reward based on difficulty
-	difficultyMultiplier := float64(m.status.Difficulty) / float64(m.Blockchain.Difficulty)
+	// Base reward in the coin being mined
+	baseReward := blockchain.BlockSubsidy(m.CoinType)
+
+	// Adjust reward based on difficulty; coins without a difficulty multiplier
+	// are mined at the base difficulty
+	difficultyMultiplier := 1.0
+	if base := m.Blockchain.CalculateDifficulty(m.BlockType); m.status.Difficulty > 0 && base > 0 {
+		difficultyMultiplier = float64(m.status.Difficulty) / float64(base)
+	}
 	reward := baseReward / difficultyMultiplier
 
 	// Ensure minimum reward
@@ -178,34 +209,225 @@ func (m *Miner) calculateReward() float64 {
 	return reward
 }
 
-// mineBlock mines a new block
+// SetCoins makes the miner take turns between coins, mining one block of
+// each in order on the chain the coin is mined on, so one process can mine
+// several coins. The first block mines coins[0].
+func (m *Miner) SetCoins(coins ...blockchain.CoinType) error {
+	if len(coins) == 0 {
+		return errors.New("no coins to mine")
+	}
+	seen := make(map[blockchain.CoinType]bool)
+	for _, coinType := range coins {
+		if !blockchain.IsMineable(coinType) {
+			return fmt.Errorf("coin type %s is not mineable", coinType)
+		}
+		if seen[coinType] {
+			return fmt.Errorf("coin type %s is listed twice", coinType)
+		}
+		seen[coinType] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.coins = append([]blockchain.CoinType(nil), coins...)
+	m.nextCoin = 0
+	return nil
+}
+
+// rotateCoin switches the miner to the next coin set by SetCoins, if any
+func (m *Miner) rotateCoin() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.coins) == 0 {
+		return
+	}
+	coinType := m.coins[m.nextCoin]
+	m.nextCoin = (m.nextCoin + 1) % len(m.coins)
+
+	m.CoinType = coinType
+	m.BlockType = blockchain.GetBlockType(coinType)
+	m.status.Difficulty = m.Blockchain.CalculateDifficulty(m.BlockType) * blockchain.MiningDifficulty(coinType)
+	if m.targetBits > 0 {
+		m.status.Difficulty = m.targetBits
+	}
+}
+
+// SetTargetBits pins the difficulty, the leading zero bytes a block hash
+// needs, every later block is mined at, in place of the chain's retargeted
+// difficulty, for benchmarks. Only networks whose consensus accepts pinned
+// difficulties allow it. It is clamped to the chain's minimum and maximum
+// difficulty; 0 returns to the chain's difficulty.
+func (m *Miner) SetTargetBits(bits int) error {
+	if params := m.Blockchain.ConsensusParams(); bits > 0 && !params.AllowPinnedDifficulty {
+		return fmt.Errorf("the difficulty can only be pinned on a test network, not %s", params.Mode)
+	}
+	if bits > 0 {
+		minBits, maxBits := 1, 32
+		if config := m.Blockchain.MiningConfig; config != nil {
+			minBits, maxBits = config.MinDifficulty, config.MaxDifficulty
+		}
+		if bits < minBits {
+			bits = minBits
+		} else if bits > maxBits {
+			bits = maxBits
+		}
+		log.Printf("Mining difficulty override active: every block is mined at difficulty %d", bits)
+	} else {
+		bits = 0
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.targetBits = bits
+	m.status.Difficulty = m.Blockchain.CalculateDifficulty(m.BlockType) * blockchain.MiningDifficulty(m.CoinType)
+	if bits > 0 {
+		m.status.Difficulty = bits
+	}
+	return nil
+}
+
+// SetCoinbaseTag stamps tag, such as a pool name or software version, on
+// the coinbase of every later block. An empty tag leaves coinbases untagged.
+func (m *Miner) SetCoinbaseTag(tag string) error {
+	if err := blockchain.CheckCoinbaseTag(tag); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.coinbaseTag = tag
+	return nil
+}
+
+// SetRewardAddress pays every later block reward to address instead of the
+// mining wallet. The address must be well formed, or the rewards could never
+// be spent.
+func (m *Miner) SetRewardAddress(address string) error {
+	if _, err := wallet.ParseAddress(address); err != nil {
+		return fmt.Errorf("invalid reward address: %v", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rewardAddress = address
+	m.rewardWallet = nil
+	m.rotatedAddress = ""
+	return nil
+}
+
+// RotateRewardAddresses pays each later block reward to a fresh receive
+// address of account in the HD wallet w, so rewards are not linked by a
+// shared address. Mining fails if account does not exist.
+func (m *Miner) RotateRewardAddresses(w *wallet.Wallet, account uint32) error {
+	if w == nil || w.HDWallet == nil {
+		return wallet.ErrNotHDWallet
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rewardWallet = w
+	m.rewardAccount = account
+	m.rewardAddress = ""
+	m.rotatedAddress = ""
+	return nil
+}
+
+// nextRewardAddress returns the address the next block reward is paid to
+// and the hash its coinbase output is locked to. A rotating HD wallet
+// derives the address once per block found, not once per template.
+func (m *Miner) nextRewardAddress() (string, []byte, error) {
+	m.mu.Lock()
+	address, w, account := m.rewardAddress, m.rewardWallet, m.rewardAccount
+	miningWallet := m.status.MiningWallet
+	if w != nil && m.rotatedAddress == "" {
+		next, err := w.NewReceiveAddress(account)
+		if err != nil {
+			m.mu.Unlock()
+			return "", nil, fmt.Errorf("failed to derive reward address: %v", err)
+		}
+		m.rotatedAddress = next
+	}
+	rotated := m.rotatedAddress
+	m.mu.Unlock()
+
+	switch {
+	case w != nil:
+		address = rotated
+	case address == "" && miningWallet.PublicKey != nil:
+		return miningWallet.Address, crypto.HashPublicKey(miningWallet.PublicKey), nil
+	case address == "":
+		// A mining wallet loaded from disk has only its address
+		address = miningWallet.Address
+	}
+
+	parsed, err := wallet.ParseAddress(address)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid reward address: %v", err)
+	}
+	return address, parsed.Hash, nil
+}
+
+// blockTemplate assembles the next block around the miner's coinbase, the
+// same way the template API does
+func (m *Miner) blockTemplate() (*blockchain.BlockTemplate, error) {
+	address, hash, err := m.nextRewardAddress()
+	if err != nil {
+		return nil, err
+	}
+	coinbaseTx := blockchain.NewCoinbaseTransaction(
+		address,
+		hash,
+		m.calculateReward(),
+		m.CoinType,
+		m.BlockType,
+	)
+	m.mu.RLock()
+	coinbaseTx.CoinbaseTag = m.coinbaseTag
+	targetBits := m.targetBits
+	m.mu.RUnlock()
+	coinbaseTx.RecomputeID()
+	template, err := m.Blockchain.NewBlockTemplate(m.BlockType, m.CoinType, *coinbaseTx)
+	if err != nil {
+		return nil, err
+	}
+	if targetBits > 0 {
+		template.SetDifficulty(targetBits)
+	}
+	return template, nil
+}
+
+// mineBlock mines a new block. It gives up with ErrTemplateStale if a
+// block joins or leaves the chain before one is found.
 func (m *Miner) mineBlock() error {
-	// Get pending transactions
-	pendingTxs := m.Blockchain.GetPendingTransactions()
-
-	// Create coinbase transaction
-	coinbaseTx := blockchain.Transaction{
-		ID:        []byte("coinbase"),
-		Timestamp: time.Now(),
-		Inputs:    []blockchain.TxInput{},
-		Outputs: []blockchain.TxOutput{
-			{
-				Value:         m.calculateReward(),
-				CoinType:      m.CoinType,
-				PublicKeyHash: crypto.HashPublicKey(m.status.MiningWallet.PublicKey),
-				Address:       m.status.MiningWallet.Address,
-			},
-		},
-		BlockType: m.BlockType,
+	m.mu.Lock()
+	retry := m.retemplate
+	m.retemplate = false
+	m.mu.Unlock()
+	if !retry {
+		m.rotateCoin()
 	}
 
-	// Add coinbase transaction to pending transactions
-	pendingTxs = append([]blockchain.Transaction{coinbaseTx}, pendingTxs...)
+	// The template about to be built already reflects the chain as it is
+	select {
+	case <-m.refresh:
+	default:
+	}
+	template, err := m.blockTemplate()
+	if err != nil {
+		return fmt.Errorf("failed to mine block: %w", err)
+	}
+	coinbaseTx := template.Coinbase
+	m.mu.Lock()
+	m.template = template
+	m.mu.Unlock()
 
 	// Mine block
-	block, err := m.Blockchain.MineBlock(pendingTxs, m.BlockType, m.CoinType)
+	block, err := m.Blockchain.MineTemplateUntil(template, m.refresh)
+	if errors.Is(err, blockchain.ErrTemplateStale) {
+		m.mu.Lock()
+		m.retemplate = true
+		m.mu.Unlock()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to mine block: %v", err)
+		return fmt.Errorf("failed to mine block: %w", err)
 	}
 
 	// Add block to blockchain
@@ -213,27 +435,42 @@ func (m *Miner) mineBlock() error {
 		return fmt.Errorf("failed to add block: %v", err)
 	}
 
-	// Update mining wallet with rewards
+	// Update status; the nonce starts at zero, so it counts the hashes tried
+	m.mu.Lock()
+	m.status.Hashes += int64(block.Nonce) + 1
 	m.status.Rewards[m.CoinType] += coinbaseTx.Outputs[0].Value
+	m.status.BlocksFound++
+	if m.rotatedAddress == coinbaseTx.Outputs[0].Address {
+		m.rotatedAddress = ""
+	}
+	m.status.CurrentBlock = time.Unix(block.Timestamp, 0)
+	m.status.CurrentReward = coinbaseTx.Outputs[0].Value
+	m.status.TotalRewards += coinbaseTx.Outputs[0].Value
+	m.mu.Unlock()
 
 	// Save wallet
 	if err := m.saveWallet(); err != nil {
 		return fmt.Errorf("failed to save wallet: %v", err)
 	}
 
-	// Update status
-	m.status.BlocksFound++
-	m.status.CurrentBlock = time.Unix(block.Timestamp, 0)
-	m.status.CurrentReward = coinbaseTx.Outputs[0].Value
-	m.status.TotalRewards += coinbaseTx.Outputs[0].Value
-
 	return nil
 }
 
 // Start starts the mining process
 func (m *Miner) Start(ctx context.Context) {
+	m.ResetStats()
+
+	m.mu.Lock()
 	m.status.IsRunning = true
 	m.status.StartTime = time.Now()
+	m.status.EndTime = time.Time{}
+	m.hashRate.reset(m.status.StartTime, 0)
+	m.stopChan = make(chan struct{})
+	stopChan := m.stopChan
+	m.mu.Unlock()
+
+	events, unsubscribe := m.Blockchain.SubscribeBlocks()
+	go m.watchChain(events, unsubscribe, stopChan)
 
 	go func() {
 		for {
@@ -241,10 +478,27 @@ func (m *Miner) Start(ctx context.Context) {
 			case <-ctx.Done():
 				m.Stop()
 				return
-			case <-m.stopChan:
+			case <-stopChan:
 				return
 			default:
 				if err := m.mineBlock(); err != nil {
+					if errors.Is(err, blockchain.ErrTemplateStale) {
+						// Another block won the race; mine on top of it
+						continue
+					}
+					if errors.Is(err, blockchain.ErrMiningTimeout) {
+						// Start over on a fresh template rather than backing off
+						log.Printf("Mining attempt abandoned: %v; the difficulty may be too high for the mining timeout, which -mining-timeout raises", err)
+						continue
+					}
+					if errors.Is(err, blockchain.ErrTimestampAhead) {
+						// Blocks came faster than the clock; let it catch up
+						select {
+						case <-time.After(time.Second):
+						case <-stopChan:
+						}
+						continue
+					}
 					log.Printf("Mining error: %v", err)
 					time.Sleep(time.Second)
 					continue
@@ -256,24 +510,90 @@ func (m *Miner) Start(ctx context.Context) {
 
 // Stop stops the mining process
 func (m *Miner) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.status.IsRunning {
+		return
+	}
 	m.status.IsRunning = false
 	m.status.EndTime = time.Now()
 	close(m.stopChan)
+	// Abandon the template being mined rather than search it to the end
+	select {
+	case m.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// watchChain marks the template being mined stale whenever a block joins
+// or leaves the chain it is mined on, so the miner rebuilds it on the new
+// tip instead of mining a block that can no longer extend the chain. It
+// returns once stop is closed or the blockchain closes.
+func (m *Miner) watchChain(events <-chan blockchain.BlockEvent, unsubscribe func(), stop <-chan struct{}) {
+	defer unsubscribe()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			m.mu.RLock()
+			blockType := m.BlockType
+			m.mu.RUnlock()
+			if event.Block != nil && event.Block.BlockType != blockType {
+				continue
+			}
+			select {
+			case m.refresh <- struct{}{}:
+			default:
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// ResetStats clears the hash, share and block counters and restarts the
+// hash rate window. Rewards and the mining wallet are left untouched.
+func (m *Miner) ResetStats() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.status.Hashes = 0
+	m.status.HashRate = 0
+	m.status.Shares = 0
+	m.status.BlocksFound = 0
+	m.status.AverageBlockTime = 0
+	if m.status.IsRunning {
+		m.status.StartTime = now
+	}
+	m.hashRate.reset(now, 0)
 }
 
 // GetStatus returns the current mining status
 func (m *Miner) GetStatus() Status {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	// Uptime stops advancing once the miner has been stopped
+	switch {
+	case m.status.StartTime.IsZero():
+		m.status.Uptime = 0
+	case m.status.IsRunning || m.status.EndTime.IsZero():
+		m.status.Uptime = now.Sub(m.status.StartTime)
+	default:
+		m.status.Uptime = m.status.EndTime.Sub(m.status.StartTime)
+	}
 
-	// Calculate hash rate
+	// Calculate hash rate over the rolling interval
 	if m.status.StartTime.IsZero() {
 		m.status.HashRate = 0
-	} else {
-		elapsed := time.Since(m.status.StartTime).Seconds()
-		if elapsed > 0 {
-			m.status.HashRate = int64(float64(m.status.Shares) / elapsed)
-		}
+	} else if m.status.IsRunning {
+		m.status.HashRate = m.hashRate.update(now, m.status.Hashes)
 	}
 
 	// Calculate network hash rate (placeholder - should be implemented)
@@ -281,16 +601,25 @@ func (m *Miner) GetStatus() Status {
 
 	// Calculate average block time
 	if m.status.BlocksFound > 0 {
-		elapsed := time.Since(m.status.StartTime).Seconds()
-		m.status.AverageBlockTime = elapsed / float64(m.status.BlocksFound)
+		m.status.AverageBlockTime = m.status.Uptime.Seconds() / float64(m.status.BlocksFound)
 	}
 
-	return m.status
+	status := m.status
+	status.Rewards = make(map[blockchain.CoinType]float64, len(m.status.Rewards))
+	for coinType, amount := range m.status.Rewards {
+		status.Rewards[coinType] = amount
+	}
+	return status
 }
 
 // GetMiningDifficulty returns the current mining difficulty
 func (m *Miner) GetMiningDifficulty() int {
-	return m.Blockchain.Difficulty * blockchain.MiningDifficulty(m.CoinType)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.targetBits > 0 {
+		return m.targetBits
+	}
+	return m.Blockchain.CalculateDifficulty(m.BlockType) * blockchain.MiningDifficulty(m.CoinType)
 }
 
 // GetMiningStats returns current mining statistics
@@ -303,6 +632,7 @@ func (m *Miner) GetMiningStats() map[string]interface{} {
 		"coin_type":   m.CoinType,
 		"difficulty":  m.status.Difficulty,
 		"hash_rate":   m.status.HashRate,
+		"hashes":      m.status.Hashes,
 		"shares":      m.status.Shares,
 		"blocks":      m.status.BlocksFound,
 		"address":     m.Address,
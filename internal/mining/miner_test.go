@@ -2,12 +2,22 @@ package mining
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"byc/internal/blockchain"
+	"byc/internal/crypto"
+	"byc/internal/logger"
+	"byc/internal/wallet"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewMiner(t *testing.T) {
@@ -32,6 +42,25 @@ func TestNewMiner(t *testing.T) {
 	}
 }
 
+func TestNewMinerRejectsMismatchedChain(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+
+	// Leah is a golden-chain coin, so it can't be the reward of a silver block
+	_, err := NewMiner(bc, blockchain.SilverBlock, blockchain.Leah, "localhost:3000")
+	assert.Error(t, err)
+
+	_, err = bc.MineBlock(nil, blockchain.SilverBlock, blockchain.Leah)
+	assert.Error(t, err)
+
+	walletsDir := t.TempDir()
+	miner, err := NewMinerWithWalletsDir(walletsDir, bc, blockchain.SilverBlock, blockchain.Senum, "localhost:3000")
+	require.NoError(t, err)
+	require.NoError(t, miner.mineBlock())
+
+	block := bc.SilverBlocks[len(bc.SilverBlocks)-1]
+	assert.Equal(t, blockchain.Senum, block.Transactions[0].Outputs[0].CoinType)
+}
+
 func TestMinerStartStop(t *testing.T) {
 	bc := blockchain.NewBlockchain()
 	miner, _ := NewMiner(bc, blockchain.GoldenBlock, blockchain.Leah, "localhost:3000")
@@ -230,3 +259,383 @@ func TestMiningPool(t *testing.T) {
 	pool.RemoveMiner("test_miner")
 	assert.Equal(t, 0, len(pool.Miners), "Pool should be empty after removing miner")
 }
+
+func TestCalculateHashRate(t *testing.T) {
+	assert.Equal(t, int64(5000), calculateHashRate(50000, HashRateInterval))
+	assert.Equal(t, int64(0), calculateHashRate(0, HashRateInterval))
+	assert.Equal(t, int64(0), calculateHashRate(100, 0))
+}
+
+func TestHashRateSampler(t *testing.T) {
+	var s hashRateSampler
+	start := time.Unix(1000, 0)
+
+	s.reset(start, 0)
+	assert.Equal(t, int64(0), s.update(start.Add(5*time.Second), 40000), "rate should not change before a full interval")
+	assert.Equal(t, int64(10000), s.update(start.Add(10*time.Second), 100000))
+	assert.Equal(t, int64(10000), s.update(start.Add(15*time.Second), 120000), "rate should hold until the next interval")
+	assert.Equal(t, int64(3000), s.update(start.Add(20*time.Second), 130000))
+
+	// A counter that goes backwards (stats reset) starts a new window
+	assert.Equal(t, int64(0), s.update(start.Add(25*time.Second), 0))
+	assert.Equal(t, int64(2000), s.update(start.Add(35*time.Second), 20000))
+}
+
+func TestMinerResetStats(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	miner, err := NewMiner(bc, blockchain.GoldenBlock, blockchain.Leah, "localhost:3000")
+	assert.NoError(t, err)
+
+	miner.mu.Lock()
+	miner.status.Hashes = 500
+	miner.status.BlocksFound = 2
+	miner.status.HashRate = 50
+	miner.mu.Unlock()
+
+	miner.ResetStats()
+
+	status := miner.GetStatus()
+	assert.Equal(t, int64(0), status.Hashes)
+	assert.Equal(t, int64(0), status.BlocksFound)
+	assert.Equal(t, int64(0), status.HashRate)
+	assert.Equal(t, time.Duration(0), status.Uptime)
+}
+
+// pendingSpend funds key with a confirmed output and returns a signed
+// transaction spending it that pays fee
+func pendingSpend(t *testing.T, bc *blockchain.Blockchain, key *ecdsa.PrivateKey, id string, fee float64) blockchain.Transaction {
+	funding := &blockchain.Transaction{
+		ID:      []byte(id),
+		Outputs: []blockchain.TxOutput{{Value: 10, CoinType: blockchain.Leah, PublicKeyHash: crypto.HashPublicKey(&key.PublicKey), Address: id}},
+	}
+	require.NoError(t, bc.UTXOSet.UpdateWithTransaction(funding))
+	return signedSpend(t, key, funding, 10-fee)
+}
+
+// signedSpend spends the first output of parent, paying send back to key
+func signedSpend(t *testing.T, key *ecdsa.PrivateKey, parent *blockchain.Transaction, send float64) blockchain.Transaction {
+	tx := blockchain.Transaction{
+		Inputs: []blockchain.TxInput{{
+			TxID:      parent.ID,
+			Amount:    parent.Outputs[0].Value,
+			PublicKey: crypto.PublicKeyToBytes(&key.PublicKey),
+		}},
+		Outputs:   []blockchain.TxOutput{{Value: send, CoinType: blockchain.Leah, PublicKeyHash: crypto.HashPublicKey(&key.PublicKey), Address: "self"}},
+		Timestamp: time.Now(),
+		Nonce:     blockchain.NewTxNonce(),
+	}
+	tx.ID = tx.CalculateHash()
+	require.NoError(t, tx.Sign(key.D.Bytes()))
+	return tx
+}
+
+func TestMineBlockSelectsMempoolByFee(t *testing.T) {
+	logger.Init()
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	walletsDir := t.TempDir()
+	bc := blockchain.NewBlockchain()
+	require.NoError(t, bc.SetNetworkMode(blockchain.Regtest)) // relays zero-fee transactions
+	miner, err := NewMinerWithWalletsDir(walletsDir, bc, blockchain.GoldenBlock, blockchain.Leah, "localhost:3000")
+	require.NoError(t, err)
+
+	// A low-fee parent with a high-fee child, plus independent spends
+	low := pendingSpend(t, bc, key, "low", 0.1)
+	high := pendingSpend(t, bc, key, "high", 2)
+	mid := pendingSpend(t, bc, key, "mid", 1)
+	child := signedSpend(t, key, &low, 10-0.1-3)
+	free := pendingSpend(t, bc, key, "free", 0)
+	for _, tx := range []blockchain.Transaction{low, high, mid, child, free} {
+		require.NoError(t, bc.AddTransaction(tx))
+	}
+
+	require.NoError(t, miner.mineBlock())
+
+	block := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+	require.Len(t, block.Transactions, 5, "coinbase plus every fee-paying transaction")
+	assert.True(t, block.Transactions[0].IsCoinbase())
+	var got []string
+	for _, tx := range block.Transactions[1:] {
+		got = append(got, fmt.Sprintf("%x", tx.ID))
+	}
+	want := []string{fmt.Sprintf("%x", high.ID), fmt.Sprintf("%x", mid.ID), fmt.Sprintf("%x", low.ID), fmt.Sprintf("%x", child.ID)}
+	assert.Equal(t, want, got, "transactions should be ordered by fee rate with parents before children")
+	assert.Equal(t, blockchain.MerkleRoot(block.Transactions), block.MerkleRoot)
+
+	// The zero-fee transaction is left pending
+	pending := bc.GetPendingTransactions()
+	require.Len(t, pending, 1)
+	assert.Equal(t, free.ID, pending[0].ID)
+}
+
+func TestMiningPathsShareBlockAssembly(t *testing.T) {
+	logger.Init()
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	walletsDir := t.TempDir()
+	bc := blockchain.NewBlockchain()
+	for _, id := range []string{"first", "second", "third"} {
+		require.NoError(t, bc.AddTransaction(pendingSpend(t, bc, key, id, 1)))
+	}
+
+	solo, err := NewMinerWithWalletsDir(walletsDir, bc, blockchain.GoldenBlock, blockchain.Leah, "solo-miner")
+	require.NoError(t, err)
+	pool := NewMiningPool("pool_address")
+	member, err := NewMinerWithWalletsDir(walletsDir, bc, blockchain.GoldenBlock, blockchain.Leah, "pool-miner")
+	require.NoError(t, err)
+	pool.AddMiner(member)
+
+	soloTemplate, err := solo.blockTemplate()
+	require.NoError(t, err)
+	poolTemplate, err := pool.GetMiner("pool-miner").blockTemplate()
+	require.NoError(t, err)
+	apiTemplate, err := bc.GetBlockTemplate(blockchain.GoldenBlock, blockchain.Leah, "external-miner")
+	require.NoError(t, err)
+
+	// Only the coinbase's payee and extra nonce, and so the Merkle root,
+	// differ; the timestamp is read from the clock on each call
+	normalize := func(tmpl *blockchain.BlockTemplate) []byte {
+		n := *tmpl
+		coinbase := *n.Coinbase.Copy()
+		coinbase.Outputs[0].Address = ""
+		coinbase.Outputs[0].PublicKeyHash = nil
+		coinbase.Nonce = 0
+		coinbase.Timestamp = time.Time{}
+		coinbase.ID = nil
+		n.Coinbase = coinbase
+		n.MerkleRoot = nil
+		n.Timestamp = 0
+		data, err := json.Marshal(n)
+		require.NoError(t, err)
+		return data
+	}
+	require.Len(t, apiTemplate.Transactions, 3)
+	assert.Equal(t, string(normalize(apiTemplate)), string(normalize(soloTemplate)))
+	assert.Equal(t, string(normalize(apiTemplate)), string(normalize(poolTemplate)))
+
+	// Each template's Merkle root is the one its branch gives
+	for _, tmpl := range []*blockchain.BlockTemplate{soloTemplate, poolTemplate, apiTemplate} {
+		assert.Equal(t, tmpl.MerkleRoot, blockchain.MerkleRootFromBranch(tmpl.Coinbase.ID, tmpl.MerkleBranch))
+	}
+}
+
+func TestMinerRewardAddress(t *testing.T) {
+	logger.Init()
+	walletsDir := t.TempDir()
+	bc := blockchain.NewBlockchain()
+	miner, err := NewMinerWithWalletsDir(walletsDir, bc, blockchain.GoldenBlock, blockchain.Leah, "localhost:3000")
+	require.NoError(t, err)
+
+	// Malformed reward addresses are rejected before any block is mined
+	for _, address := range []string{"", "miner_address", "abcd"} {
+		assert.Error(t, miner.SetRewardAddress(address), "address %q", address)
+	}
+
+	w, err := wallet.NewHDWallet()
+	require.NoError(t, err)
+	assert.ErrorIs(t, miner.RotateRewardAddresses(&wallet.Wallet{}, wallet.DefaultAccount), wallet.ErrNotHDWallet)
+	require.NoError(t, miner.RotateRewardAddresses(w, wallet.DefaultAccount))
+
+	// Templates rebuilt before a block is found keep paying the same address
+	first, err := miner.blockTemplate()
+	require.NoError(t, err)
+	again, err := miner.blockTemplate()
+	require.NoError(t, err)
+	assert.Equal(t, first.Coinbase.Outputs[0].Address, again.Coinbase.Outputs[0].Address)
+
+	// Each block pays a fresh address of the HD wallet, locked to its hash
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, miner.mineBlock())
+		coinbase := bc.GoldenBlocks[len(bc.GoldenBlocks)-1].Transactions[0]
+		address := coinbase.Outputs[0].Address
+		parsed, err := wallet.ParseAddress(address)
+		require.NoError(t, err)
+		assert.Equal(t, parsed.Hash, coinbase.Outputs[0].PublicKeyHash)
+		assert.False(t, seen[address], "reward address %s was reused", address)
+		seen[address] = true
+	}
+
+	// A fixed reward address is paid every block
+	fixed, err := wallet.NewWallet()
+	require.NoError(t, err)
+	require.NoError(t, miner.SetRewardAddress(fixed.Address))
+	require.NoError(t, miner.mineBlock())
+	assert.Equal(t, fixed.Address, bc.GoldenBlocks[len(bc.GoldenBlocks)-1].Transactions[0].Outputs[0].Address)
+
+	// A saved mining wallet with a malformed address is refused at startup
+	data, err := json.Marshal(WalletInfo{Address: "miner_address"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(walletsDir, "mining_wallet.json"), data, 0644))
+	_, err = NewMinerWithWalletsDir(walletsDir, bc, blockchain.GoldenBlock, blockchain.Leah, "localhost:3000")
+	assert.Error(t, err)
+}
+
+func TestMinerRotatesCoins(t *testing.T) {
+	logger.Init()
+	walletsDir := t.TempDir()
+	bc := blockchain.NewBlockchain()
+	miner, err := NewMinerWithWalletsDir(walletsDir, bc, blockchain.GoldenBlock, blockchain.Leah, "localhost:3000")
+	require.NoError(t, err)
+
+	assert.Error(t, miner.SetCoins())
+	assert.Error(t, miner.SetCoins(blockchain.Leah, blockchain.Ephraim), "special coins are minted, not mined")
+	assert.Error(t, miner.SetCoins(blockchain.Leah, blockchain.Leah))
+	require.NoError(t, miner.SetCoins(blockchain.Leah, blockchain.Seon, blockchain.Senum))
+
+	// Each coin gets its turn, on the chain it is mined on
+	var mined []blockchain.CoinType
+	for i := 0; i < 6; i++ {
+		require.NoError(t, miner.mineBlock())
+		chain := bc.GoldenBlocks
+		if miner.BlockType == blockchain.SilverBlock {
+			chain = bc.SilverBlocks
+		}
+		block := chain[len(chain)-1]
+		assert.Equal(t, blockchain.GetBlockType(miner.CoinType), block.BlockType)
+		mined = append(mined, block.Transactions[0].Outputs[0].CoinType)
+	}
+	assert.Equal(t, []blockchain.CoinType{
+		blockchain.Leah, blockchain.Seon, blockchain.Senum,
+		blockchain.Leah, blockchain.Seon, blockchain.Senum,
+	}, mined)
+	assert.Len(t, bc.GoldenBlocks, 5, "genesis plus two Leah and two Seon blocks")
+	assert.Len(t, bc.SilverBlocks, 3, "genesis plus two Senum blocks")
+
+	status := miner.GetStatus()
+	assert.Equal(t, int64(6), status.BlocksFound)
+	for _, coinType := range []blockchain.CoinType{blockchain.Leah, blockchain.Seon, blockchain.Senum} {
+		assert.Greater(t, status.Rewards[coinType], 0.0, "no %s rewards recorded", coinType)
+	}
+}
+
+// TestMinerCoinbaseTag tests that the miner's tag is stamped on the coinbase
+// of the blocks it mines and shown in their explorer summary
+func TestMinerCoinbaseTag(t *testing.T) {
+	logger.Init()
+	walletsDir := t.TempDir()
+	bc := blockchain.NewBlockchain()
+	miner, err := NewMinerWithWalletsDir(walletsDir, bc, blockchain.GoldenBlock, blockchain.Leah, "localhost:3000")
+	require.NoError(t, err)
+
+	assert.Error(t, miner.SetCoinbaseTag(strings.Repeat("x", blockchain.MaxCoinbaseTagLength+1)))
+	require.NoError(t, miner.SetCoinbaseTag("example-pool/v1.2"))
+	require.NoError(t, miner.mineBlock())
+
+	block := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+	coinbase := block.Transactions[0]
+	require.True(t, coinbase.IsCoinbase())
+	assert.Equal(t, "example-pool/v1.2", coinbase.CoinbaseTag)
+	assert.NoError(t, coinbase.CheckID(), "the tag is part of the coinbase ID")
+
+	summary, err := bc.GetBlockSummary(block.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, "example-pool/v1.2", summary.CoinbaseTag)
+
+	// Clearing the tag leaves later coinbases untagged
+	require.NoError(t, miner.SetCoinbaseTag(""))
+	require.NoError(t, miner.mineBlock())
+	block = bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+	assert.Empty(t, block.Transactions[0].CoinbaseTag)
+}
+
+func TestMinerSetTargetBits(t *testing.T) {
+	logger.Init()
+	walletsDir := t.TempDir()
+	// Mainnet consensus only accepts the retargeted difficulty
+	mainnet, err := NewMinerWithWalletsDir(walletsDir, blockchain.NewBlockchain(), blockchain.GoldenBlock, blockchain.Leah, "localhost:3000")
+	require.NoError(t, err)
+	assert.Error(t, mainnet.SetTargetBits(1))
+	assert.NoError(t, mainnet.SetTargetBits(0))
+
+	// A regtest chain starting above the pinned difficulty
+	bc := blockchain.NewBlockchain()
+	require.NoError(t, bc.SetNetworkMode(blockchain.Regtest))
+	params := bc.ConsensusParams()
+	params.GenesisDifficulty = 2
+	bc.SetConsensusParams(params)
+	miner, err := NewMinerWithWalletsDir(walletsDir, bc, blockchain.GoldenBlock, blockchain.Leah, "localhost:3000")
+	require.NoError(t, err)
+	chainDifficulty := miner.GetMiningDifficulty()
+	require.Equal(t, 2, chainDifficulty)
+
+	// The override is clamped to the chain's difficulty bounds
+	require.NoError(t, miner.SetTargetBits(bc.MiningConfig.MaxDifficulty+10))
+	assert.Equal(t, bc.MiningConfig.MaxDifficulty, miner.GetMiningDifficulty())
+
+	require.NoError(t, miner.SetTargetBits(1))
+	assert.Equal(t, 1, miner.GetMiningDifficulty())
+	assert.Equal(t, 1, miner.GetStatus().Difficulty)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, miner.mineBlock())
+		block := bc.GoldenBlocks[len(bc.GoldenBlocks)-1]
+		assert.Equal(t, 1, block.Difficulty)
+		assert.Equal(t, byte(0), block.Hash[0], "the hash must meet the pinned target")
+	}
+
+	// Clearing the override returns to the chain's difficulty
+	require.NoError(t, miner.SetTargetBits(0))
+	assert.Equal(t, chainDifficulty, miner.GetMiningDifficulty())
+}
+
+func TestMinerEmptyMempool(t *testing.T) {
+	logger.Init()
+	walletsDir := t.TempDir()
+	bc := blockchain.NewBlockchain()
+	require.Empty(t, bc.GetPendingTransactions())
+	miner, err := NewMinerWithWalletsDir(walletsDir, bc, blockchain.GoldenBlock, blockchain.Leah, "localhost:3000")
+	require.NoError(t, err)
+
+	template, err := miner.blockTemplate()
+	require.NoError(t, err)
+	assert.True(t, template.Coinbase.IsCoinbase())
+	assert.Empty(t, template.Transactions)
+
+	// The block is added only if it validates
+	height := len(bc.GoldenBlocks)
+	require.NoError(t, miner.mineBlock())
+	require.Len(t, bc.GoldenBlocks, height+1)
+	block := bc.GoldenBlocks[height]
+	require.Len(t, block.Transactions, 1)
+	assert.True(t, block.Transactions[0].IsCoinbase())
+}
+
+func TestMinerRefreshesStaleTemplate(t *testing.T) {
+	logger.Init()
+	walletsDir := t.TempDir()
+	bc := blockchain.NewBlockchain()
+	require.NoError(t, bc.SetNetworkMode(blockchain.Regtest))
+	miner, err := NewMinerWithWalletsDir(walletsDir, bc, blockchain.GoldenBlock, blockchain.Leah, "localhost:3000")
+	require.NoError(t, err)
+
+	// The miner never finds a block at the highest difficulty, so it only
+	// moves to a new template when the chain changes under it
+	require.NoError(t, miner.SetTargetBits(bc.MiningConfig.MaxDifficulty))
+	currentTemplate := func() *blockchain.BlockTemplate {
+		miner.mu.RLock()
+		defer miner.mu.RUnlock()
+		return miner.template
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	miner.Start(ctx)
+	defer miner.Stop()
+
+	require.Eventually(t, func() bool { return currentTemplate() != nil }, 5*time.Second, 10*time.Millisecond)
+	stale := currentTemplate()
+	assert.Equal(t, bc.GoldenBlocks[len(bc.GoldenBlocks)-1].Hash, stale.PrevHash)
+
+	// Another node's block arrives
+	template, err := bc.GetBlockTemplate(blockchain.GoldenBlock, blockchain.Leah, miner.status.MiningWallet.Address)
+	require.NoError(t, err)
+	block, err := bc.MineTemplate(template)
+	require.NoError(t, err)
+	require.NoError(t, bc.AddBlock(block))
+
+	require.Eventually(t, func() bool {
+		return string(currentTemplate().PrevHash) == string(block.Hash)
+	}, 5*time.Second, 10*time.Millisecond, "the miner kept mining on the old tip")
+	refreshed := currentTemplate()
+	assert.Equal(t, stale.Height+1, refreshed.Height)
+	assert.Equal(t, bc.MiningConfig.MaxDifficulty, refreshed.Difficulty)
+}
@@ -61,8 +61,8 @@ func (a *AlertSystem) RegisterHandler(handler AlertHandler) {
 	a.handlers = append(a.handlers, handler)
 }
 
-// CreateAlert creates a new alert
-func (a *AlertSystem) CreateAlert(level AlertLevel, message, component string, details interface{}) {
+// CreateAlert creates a new alert and returns it
+func (a *AlertSystem) CreateAlert(level AlertLevel, message, component string, details interface{}) *Alert {
 	alert := &Alert{
 		ID:        fmt.Sprintf("%d", time.Now().UnixNano()),
 		Level:     level,
@@ -94,6 +94,7 @@ func (a *AlertSystem) CreateAlert(level AlertLevel, message, component string, d
 	if a.webhookURL != "" {
 		go a.sendWebhookNotification(alert)
 	}
+	return alert
 }
 
 // ResolveAlert resolves an alert
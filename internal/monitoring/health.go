@@ -18,9 +18,12 @@ type HealthStatus struct {
 	Timestamp time.Time `json:"timestamp"`
 	Details   struct {
 		Blockchain struct {
-			GoldenBlocks int  `json:"golden_blocks"`
-			SilverBlocks int  `json:"silver_blocks"`
-			IsSynced     bool `json:"is_synced"`
+			GoldenBlocks  int     `json:"golden_blocks"`
+			SilverBlocks  int     `json:"silver_blocks"`
+			IsSynced      bool    `json:"is_synced"`
+			CurrentHeight int64   `json:"current_height"`
+			BestHeight    int64   `json:"best_height"`
+			SyncProgress  float64 `json:"sync_progress_percent"`
 		} `json:"blockchain"`
 		Network struct {
 			Peers        int    `json:"peers"`
@@ -66,9 +69,14 @@ func (h *HealthCheck) checkHealth() HealthStatus {
 	}
 
 	// Check blockchain health
-	status.Details.Blockchain.GoldenBlocks = len(h.blockchain.GoldenBlocks)
-	status.Details.Blockchain.SilverBlocks = len(h.blockchain.SilverBlocks)
+	stats := h.blockchain.Stats()
+	status.Details.Blockchain.GoldenBlocks = stats.GoldenBlocks
+	status.Details.Blockchain.SilverBlocks = stats.SilverBlocks
 	status.Details.Blockchain.IsSynced = h.checkBlockchainSync()
+	current, best, pct := h.blockchain.SyncProgress()
+	status.Details.Blockchain.CurrentHeight = current
+	status.Details.Blockchain.BestHeight = best
+	status.Details.Blockchain.SyncProgress = pct
 
 	// Check network health
 	status.Details.Network.Peers = len(h.node.Peers)
@@ -93,11 +101,10 @@ func (h *HealthCheck) checkHealth() HealthStatus {
 	return status
 }
 
-// checkBlockchainSync checks if the blockchain is in sync
+// checkBlockchainSync checks if the blockchain has caught up with the
+// heights its peers advertise
 func (h *HealthCheck) checkBlockchainSync() bool {
-	// TODO: Implement actual blockchain sync check
-	// This would typically compare our blockchain with peers
-	return true
+	return h.blockchain.IsSynced()
 }
 
 // checkSystemHealth checks the health of the system
@@ -135,9 +142,12 @@ func (h *HealthCheck) GetStatus() map[string]interface{} {
 		"timestamp": status.Timestamp,
 		"details": map[string]interface{}{
 			"blockchain": map[string]interface{}{
-				"golden_blocks": status.Details.Blockchain.GoldenBlocks,
-				"silver_blocks": status.Details.Blockchain.SilverBlocks,
-				"is_synced":     status.Details.Blockchain.IsSynced,
+				"golden_blocks":         status.Details.Blockchain.GoldenBlocks,
+				"silver_blocks":         status.Details.Blockchain.SilverBlocks,
+				"is_synced":             status.Details.Blockchain.IsSynced,
+				"current_height":        status.Details.Blockchain.CurrentHeight,
+				"best_height":           status.Details.Blockchain.BestHeight,
+				"sync_progress_percent": status.Details.Blockchain.SyncProgress,
 			},
 			"network": map[string]interface{}{
 				"peers":          status.Details.Network.Peers,
@@ -265,6 +265,16 @@ func (m *Metrics) RecordBlockPropagation(blockHash []byte, duration time.Duratio
 	m.propagationTime.Observe(duration.Seconds())
 }
 
+// hasBlockPropagation reports whether the propagation of the block with
+// blockHash has been recorded
+func (m *Metrics) hasBlockPropagation(blockHash []byte) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.blockPropagation[fmt.Sprintf("%x", blockHash)]
+	return ok
+}
+
 // RecordTransaction records a new transaction
 func (m *Metrics) RecordTransaction(txHash []byte) {
 	m.mu.Lock()
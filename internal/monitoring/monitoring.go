@@ -15,6 +15,17 @@ import (
 	"go.uber.org/zap"
 )
 
+// DefaultPropagationAlertThreshold is the median block propagation delay
+// above which the monitor raises an alert
+const DefaultPropagationAlertThreshold = 30 * time.Second
+
+// propagationSource reports the propagation of the latest blocks; a
+// *network.Node is one
+type propagationSource interface {
+	BlockPropagations() []network.BlockPropagation
+	MedianPropagationDelay() (time.Duration, bool)
+}
+
 // Monitor represents the monitoring system
 // It composes Metrics, HealthCheck, and AlertSystem
 type Monitor struct {
@@ -24,24 +35,51 @@ type Monitor struct {
 	healthCheck *HealthCheck
 	alerts      *AlertSystem
 	mu          sync.RWMutex
+
+	// propagation reports block propagation delays, nil without a node
+	propagation propagationSource
+	// propagationThreshold is the median propagation delay that raises an alert
+	propagationThreshold time.Duration
+	// propagationAlert is the ID of the active slow propagation alert, if any
+	propagationAlert string
+
+	// stop is closed by Stop to end the periodic checks
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 // NewMonitor creates a new monitoring system
 func NewMonitor(bc *blockchain.Blockchain, node *network.Node, alertWebhook string) *Monitor {
 	metrics := NewMetrics(bc, node)
-	return &Monitor{
+	m := &Monitor{
 		metrics:     metrics,
 		blockchain:  bc,
 		node:        node,
 		healthCheck: NewHealthCheck(bc, node),
 		alerts:      NewAlertSystem(alertWebhook),
+
+		propagationThreshold: DefaultPropagationAlertThreshold,
+		stop:                 make(chan struct{}),
+	}
+	if node != nil {
+		m.propagation = node
 	}
+	return m
+}
+
+// SetPropagationAlertThreshold sets the median block propagation delay above
+// which an alert is raised
+func (m *Monitor) SetPropagationAlertThreshold(threshold time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.propagationThreshold = threshold
 }
 
 // Start starts the monitoring system
 func (m *Monitor) Start() error {
 	// Start metrics collection
 	m.metrics.Start()
+	go m.watchPropagation()
 
 	// Start health check and metrics server
 	http.Handle("/metrics", m.metrics)
@@ -57,6 +95,12 @@ func (m *Monitor) Start() error {
 	return nil
 }
 
+// Stop ends the periodic propagation checks. It is safe to call more than
+// once.
+func (m *Monitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
 // handleAlerts serves alerts as JSON
 func (m *Monitor) handleAlerts(w http.ResponseWriter, r *http.Request) {
 	alerts := m.alerts.GetAlerts()
@@ -64,6 +108,58 @@ func (m *Monitor) handleAlerts(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(alerts)
 }
 
+// watchPropagation checks block propagation periodically until Stop is
+// called
+func (m *Monitor) watchPropagation() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.checkPropagation()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// checkPropagation records the propagation of blocks the node has seen
+// since the last check and raises an alert while the median delay is over
+// the threshold, resolving it once the median falls back under
+func (m *Monitor) checkPropagation() {
+	if m.propagation == nil {
+		return
+	}
+	for _, p := range m.propagation.BlockPropagations() {
+		if !m.metrics.hasBlockPropagation(p.Hash) {
+			m.metrics.RecordBlockPropagation(p.Hash, p.Delay)
+		}
+	}
+
+	median, ok := m.propagation.MedianPropagationDelay()
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	slow := median > m.propagationThreshold
+	switch {
+	case slow && m.propagationAlert == "":
+		alert := m.alerts.CreateAlert(AlertLevelWarning,
+			fmt.Sprintf("Median block propagation delay %s exceeds %s", median, m.propagationThreshold),
+			"network", map[string]interface{}{
+				"median_delay": median.String(),
+				"threshold":    m.propagationThreshold.String(),
+			})
+		m.propagationAlert = alert.ID
+	case !slow && m.propagationAlert != "":
+		m.alerts.ResolveAlert(m.propagationAlert)
+		m.propagationAlert = ""
+	}
+}
+
 // RecordError records an error in the monitoring system
 func (m *Monitor) RecordError(err error) {
 	m.metrics.RecordNetworkError()
@@ -4,20 +4,33 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"sort"
 	"testing"
 	"time"
 
 	"byc/internal/blockchain"
+	"byc/internal/logger"
 	"byc/internal/network"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 )
 
+// TestMain initializes the logger the node logs through
+func TestMain(m *testing.M) {
+	if err := logger.Init(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
 func TestHealthCheck(t *testing.T) {
 	// Create test blockchain and node
 	bc := blockchain.NewBlockchain()
-	node, err := network.NewNode(&network.Config{})
+	node, err := network.NewNode(&network.Config{Address: "localhost:3000", BlockType: blockchain.GoldenBlock})
 	assert.NoError(t, err)
+	defer node.Stop()
 
 	// Create health check system
 	health := NewHealthCheck(bc, node)
@@ -32,9 +45,10 @@ func TestHealthCheck(t *testing.T) {
 	var status HealthStatus
 	err = json.NewDecoder(w.Body).Decode(&status)
 	assert.NoError(t, err)
-	assert.Equal(t, "healthy", status.Status)
-	assert.Equal(t, 0, status.Details.Blockchain.GoldenBlocks)
-	assert.Equal(t, 0, status.Details.Blockchain.SilverBlocks)
+	// A node without peers is degraded
+	assert.Equal(t, "degraded", status.Status)
+	assert.Equal(t, 1, status.Details.Blockchain.GoldenBlocks)
+	assert.Equal(t, 1, status.Details.Blockchain.SilverBlocks)
 	assert.True(t, status.Details.Blockchain.IsSynced)
 	assert.Equal(t, 0, status.Details.Network.Peers)
 	assert.False(t, status.Details.Network.IsConnected)
@@ -72,8 +86,9 @@ func TestAlertSystem(t *testing.T) {
 func TestMetrics(t *testing.T) {
 	// Create test blockchain and node
 	bc := blockchain.NewBlockchain()
-	node, err := network.NewNode(&network.Config{})
+	node, err := network.NewNode(&network.Config{Address: "localhost:3000", BlockType: blockchain.GoldenBlock})
 	assert.NoError(t, err)
+	defer node.Stop()
 
 	// Create metrics system
 	metrics := NewMetrics(bc, node)
@@ -86,11 +101,10 @@ func TestMetrics(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	// Test recording metrics
-	metrics.RecordPeerConnection()
-	metrics.RecordPeerDisconnection()
+	metrics.RecordPeerLatency("peer", 100*time.Millisecond)
 	metrics.RecordNetworkLatency(100 * time.Millisecond)
 	metrics.RecordNetworkError()
-	metrics.RecordTransaction()
+	metrics.RecordTransaction([]byte("tx"))
 
 	// Start metrics collection
 	metrics.Start()
@@ -98,3 +112,79 @@ func TestMetrics(t *testing.T) {
 	// Wait for metrics to be collected
 	time.Sleep(100 * time.Millisecond)
 }
+
+// stubPropagation reports a fixed set of block propagations
+type stubPropagation struct {
+	propagations []network.BlockPropagation
+}
+
+func (s *stubPropagation) BlockPropagations() []network.BlockPropagation {
+	return s.propagations
+}
+
+func (s *stubPropagation) MedianPropagationDelay() (time.Duration, bool) {
+	if len(s.propagations) == 0 {
+		return 0, false
+	}
+	delays := make([]time.Duration, len(s.propagations))
+	for i, p := range s.propagations {
+		delays[i] = p.Delay
+	}
+	sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+	return delays[len(delays)/2], true
+}
+
+// add records a block mined at mined and first seen at seen
+func (s *stubPropagation) add(hash string, mined, seen time.Time) {
+	s.propagations = append(s.propagations, network.BlockPropagation{
+		Hash:      []byte(hash),
+		Mined:     mined,
+		FirstSeen: seen,
+		Delay:     seen.Sub(mined),
+	})
+}
+
+func TestPropagationAlert(t *testing.T) {
+	// NewMetrics registers with the default registry, which a test binary
+	// can only do once
+	source := &stubPropagation{}
+	monitor := &Monitor{
+		metrics: &Metrics{
+			blockPropagation: make(map[string]time.Duration),
+			propagationTime:  prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_propagation_seconds"}),
+		},
+		alerts:      NewAlertSystem(""),
+		propagation: source,
+	}
+	monitor.SetPropagationAlertThreshold(10 * time.Second)
+
+	// No blocks, no alert
+	monitor.checkPropagation()
+	assert.Empty(t, monitor.alerts.GetActiveAlerts())
+
+	// Blocks seen well after they were mined push the median over
+	mined := time.Unix(1_700_000_000, 0)
+	source.add("a", mined, mined.Add(2*time.Second))
+	source.add("b", mined, mined.Add(20*time.Second))
+	source.add("c", mined, mined.Add(30*time.Second))
+	monitor.checkPropagation()
+	active := monitor.alerts.GetActiveAlerts()
+	if assert.Len(t, active, 1) {
+		assert.Equal(t, AlertLevelWarning, active[0].Level)
+		assert.Equal(t, "network", active[0].Component)
+	}
+	for _, hash := range []string{"a", "b", "c"} {
+		assert.True(t, monitor.metrics.hasBlockPropagation([]byte(hash)))
+	}
+
+	// A still slow median keeps the one alert
+	monitor.checkPropagation()
+	assert.Len(t, monitor.alerts.GetActiveAlerts(), 1)
+
+	// The median at exactly the threshold is not slow
+	source.propagations = nil
+	source.add("d", mined, mined.Add(10*time.Second))
+	monitor.checkPropagation()
+	assert.Empty(t, monitor.alerts.GetActiveAlerts())
+	assert.Len(t, monitor.alerts.GetAlerts(), 1)
+}
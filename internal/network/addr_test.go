@@ -0,0 +1,106 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+)
+
+// countingDialer records dialed addresses without connecting
+type countingDialer struct {
+	mu     sync.Mutex
+	dialed map[string]int
+}
+
+func (d *countingDialer) Dial(network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dialed[address]++
+	return nil, errors.New("test dialer does not connect")
+}
+
+func (d *countingDialer) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	total := 0
+	for _, n := range d.dialed {
+		total += n
+	}
+	return total
+}
+
+func addrMessage(t *testing.T, addrs []string) *NetworkMessage {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(addrs); err != nil {
+		t.Fatalf("Failed to encode addrs: %v", err)
+	}
+	return &NetworkMessage{Type: MessageTypeAddr, Payload: buf.Bytes()}
+}
+
+// waitForDials waits until want dials happened, then a little longer to catch extras
+func waitForDials(dialer *countingDialer, want int) int {
+	deadline := time.Now().Add(2 * time.Second)
+	for dialer.count() < want && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	return dialer.count()
+}
+
+func TestHandleAddrCapsOversizedMessage(t *testing.T) {
+	dialer := &countingDialer{dialed: make(map[string]int)}
+	node := &Node{
+		Config: &Config{Address: "localhost:3000", BlockType: blockchain.GoldenBlock},
+		Peers:  map[string]*Peer{"10.0.0.2:3000": {Address: "10.0.0.2:3000"}},
+		quit:   make(chan struct{}),
+		dial:   dialer.Dial,
+	}
+	peer := &Peer{Address: "10.0.0.1:3000"}
+
+	// Junk, duplicates, ourselves and a known peer come first and are skipped
+	addrs := []string{"not-an-address", "10.0.0.3:0", "localhost:3000", "10.0.0.2:3000", "10.1.0.0:3000", "10.1.0.0:3000"}
+	// Each in its own /16, so the outbound cap per network group is not reached
+	for i := 1; i < 5000; i++ {
+		addrs = append(addrs, fmt.Sprintf("%d.%d.0.1:3000", 11+i/256, i%256))
+	}
+
+	if err := node.handleAddr(peer, addrMessage(t, addrs)); err != nil {
+		t.Fatalf("handleAddr failed: %v", err)
+	}
+	if got := waitForDials(dialer, MaxAddrsPerMessage); got != MaxAddrsPerMessage {
+		t.Fatalf("Expected %d dials, got %d", MaxAddrsPerMessage, got)
+	}
+	for addr, n := range dialer.dialed {
+		if n != 1 {
+			t.Errorf("Address %s dialed %d times", addr, n)
+		}
+		if addr == "localhost:3000" || addr == "10.0.0.2:3000" {
+			t.Errorf("Dialed self or known peer %s", addr)
+		}
+	}
+
+	// The burst allows one more message, then the peer is rate limited
+	more := []string{"10.2.0.1:3000"}
+	if err := node.handleAddr(peer, addrMessage(t, more)); err != nil {
+		t.Fatalf("handleAddr failed: %v", err)
+	}
+	if got := waitForDials(dialer, MaxAddrsPerMessage+1); got != MaxAddrsPerMessage+1 {
+		t.Fatalf("Expected %d dials, got %d", MaxAddrsPerMessage+1, got)
+	}
+
+	flood := []string{"10.3.0.1:3000"}
+	if err := node.handleAddr(peer, addrMessage(t, flood)); err != nil {
+		t.Fatalf("handleAddr failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := dialer.count(); got != MaxAddrsPerMessage+1 {
+		t.Errorf("Expected rate-limited addr message to be ignored, got %d dials", got)
+	}
+}
@@ -0,0 +1,275 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+	"byc/internal/crypto"
+)
+
+// signedBroadcastTx funds key on every chain and returns a signed spend of the funds
+func signedBroadcastTx(t *testing.T, chains ...*blockchain.Blockchain) *blockchain.Transaction {
+	return signedBroadcastTxOf(t, blockchain.Leah, chains...)
+}
+
+// signedBroadcastTxOf is signedBroadcastTx spending coinType
+func signedBroadcastTxOf(t *testing.T, coinType blockchain.CoinType, chains ...*blockchain.Blockchain) *blockchain.Transaction {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	funding := &blockchain.Transaction{
+		ID: []byte("broadcast-funding"),
+		Outputs: []blockchain.TxOutput{{
+			Value:         10,
+			CoinType:      coinType,
+			PublicKeyHash: crypto.HashPublicKey(&key.PublicKey),
+			Address:       "broadcast-funding",
+		}},
+	}
+	for _, bc := range chains {
+		if err := bc.UTXOSet.UpdateWithTransaction(funding); err != nil {
+			t.Fatalf("Failed to fund key: %v", err)
+		}
+	}
+
+	tx := &blockchain.Transaction{
+		Inputs: []blockchain.TxInput{{
+			TxID:      funding.ID,
+			Amount:    10,
+			PublicKey: crypto.PublicKeyToBytes(&key.PublicKey),
+		}},
+		Outputs: []blockchain.TxOutput{{
+			Value:         9,
+			CoinType:      coinType,
+			PublicKeyHash: []byte("recipient"),
+			Address:       "recipient",
+		}},
+		Timestamp: time.Now(),
+		Nonce:     blockchain.NewTxNonce(),
+	}
+	tx.ID = tx.CalculateHash()
+	if err := tx.Sign(key.D.Bytes()); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	return tx
+}
+
+func TestBroadcastMessageRelaysTransaction(t *testing.T) {
+	sender := newHandshakeTestNode(t)
+	receiver := newHandshakeTestNode(t)
+	if err := receiver.ConnectToPeer(sender.GetAddress()); err != nil {
+		t.Fatalf("Failed to connect nodes: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sender.GetPeerAddresses()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Sender never registered the receiver as a peer")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Let the version exchange finish before relaying
+	time.Sleep(100 * time.Millisecond)
+
+	tx := signedBroadcastTx(t, sender.Blockchain, receiver.Blockchain)
+	payload, err := EncodePayload(tx)
+	if err != nil {
+		t.Fatalf("Failed to encode transaction: %v", err)
+	}
+	if err := sender.BroadcastMessage(*NewNetworkMessage(MessageTypeTx, sender.GetAddress(), "", payload)); err != nil {
+		t.Fatalf("BroadcastMessage failed: %v", err)
+	}
+
+	// The receiver decodes it through handleTx into its pending pool
+	for {
+		for _, pending := range receiver.Blockchain.GetPendingTransactions() {
+			if bytes.Equal(pending.ID, tx.ID) {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Receiver never accepted the broadcast transaction")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBroadcastMessageRejectsUndecodableTransaction(t *testing.T) {
+	node := newHandshakeTestNode(t)
+	msg := NewNetworkMessage(MessageTypeTx, node.GetAddress(), "", []byte(`{"id":"not gob"}`))
+	if err := node.BroadcastMessage(*msg); err == nil {
+		t.Error("Expected an error broadcasting a transaction peers cannot decode")
+	}
+}
+
+func TestExpectInv(t *testing.T) {
+	n := &Node{}
+
+	// An announcement arriving between registering and waiting is not missed
+	wait := n.ExpectInv("announced")
+	n.notifyInv([]string{"announced"})
+	if !wait(time.Second) {
+		t.Error("Expected an inv announced after ExpectInv to be seen")
+	}
+
+	// One arriving before anyone waits is gone
+	n.notifyInv([]string{"early"})
+	if n.WaitForInv("early", 20*time.Millisecond) {
+		t.Error("Expected an inv announced before WaitForInv to be missed")
+	}
+
+	// A waiter that times out is removed
+	if n.ExpectInv("never")(20 * time.Millisecond) {
+		t.Error("Expected an unannounced inv to time out")
+	}
+	n.invMu.Lock()
+	defer n.invMu.Unlock()
+	if len(n.invWaiters) != 0 {
+		t.Errorf("Expected timed out waiters to be removed, got %d", len(n.invWaiters))
+	}
+}
+
+func TestInvRequestsOnlyMissingItems(t *testing.T) {
+	node := newHandshakeTestNode(t)
+	tx := signedBroadcastTx(t, node.Blockchain)
+	if err := node.Blockchain.AddTransaction(*tx); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	peer := NewPeer("relay", "relay", 0)
+	peer.conn = local
+
+	received := make(chan NetworkMessage, 4)
+	go func() {
+		for {
+			var msg NetworkMessage
+			if err := readTestFrame(remote, &msg); err != nil {
+				return
+			}
+			received <- msg
+		}
+	}()
+	next := func() (NetworkMessage, bool) {
+		select {
+		case msg := <-received:
+			return msg, true
+		case <-time.After(200 * time.Millisecond):
+			return NetworkMessage{}, false
+		}
+	}
+
+	// The echo of a transaction already pending asks for nothing
+	inv, err := EncodePayload([]string{invID(tx.ID)})
+	if err != nil {
+		t.Fatalf("Failed to encode inventory: %v", err)
+	}
+	if err := node.handleInv(peer, NewNetworkMessage(MessageTypeInv, "relay", "", inv)); err != nil {
+		t.Fatalf("handleInv failed: %v", err)
+	}
+	if msg, ok := next(); ok {
+		t.Fatalf("Expected no request for a pending transaction, got %v", msg.Type)
+	}
+	if _, ok := node.propagation.announced[string(tx.ID)]; ok {
+		t.Error("Expected the echo of a pending transaction not to be timed as a block announcement")
+	}
+
+	// An unknown item is requested by the ID it was announced with
+	unknown := invID(bytes.Repeat([]byte{0xcd}, 32))
+	inv, err = EncodePayload([]string{invID(tx.ID), unknown})
+	if err != nil {
+		t.Fatalf("Failed to encode inventory: %v", err)
+	}
+	if err := node.handleInv(peer, NewNetworkMessage(MessageTypeInv, "relay", "", inv)); err != nil {
+		t.Fatalf("handleInv failed: %v", err)
+	}
+	msg, ok := next()
+	if !ok || msg.Type != MessageTypeGetData {
+		t.Fatalf("Expected a getdata for the unknown item, got %v", msg.Type)
+	}
+	var requested []string
+	if err := DecodePayload(msg.Payload, &requested); err != nil {
+		t.Fatalf("Failed to decode getdata: %v", err)
+	}
+	if len(requested) != 1 || requested[0] != unknown {
+		t.Errorf("Requested %v, want only %s", requested, unknown)
+	}
+	if _, ok := node.propagation.announced[string(bytes.Repeat([]byte{0xcd}, 32))]; !ok {
+		t.Error("Expected the unknown item's announcement to be timed")
+	}
+
+	// A getdata for the pending transaction is served from the mempool
+	getData, err := EncodePayload([]string{invID(tx.ID)})
+	if err != nil {
+		t.Fatalf("Failed to encode inventory: %v", err)
+	}
+	if err := node.handleGetData(peer, NewNetworkMessage(MessageTypeGetData, "relay", "", getData)); err != nil {
+		t.Fatalf("handleGetData failed: %v", err)
+	}
+	msg, ok = next()
+	if !ok || msg.Type != MessageTypeTx {
+		t.Fatalf("Expected the pending transaction to be served, got %v", msg.Type)
+	}
+	served, err := decodeTransaction(msg.Payload)
+	if err != nil {
+		t.Fatalf("Failed to decode served transaction: %v", err)
+	}
+	if !bytes.Equal(served.ID, tx.ID) {
+		t.Errorf("Served transaction %x, want %x", served.ID, tx.ID)
+	}
+}
+
+func TestInvIsTruncated(t *testing.T) {
+	node := newHandshakeTestNode(t)
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	peer := NewPeer("relay", "relay", 0)
+	peer.conn = local
+
+	received := make(chan NetworkMessage, 1)
+	go func() {
+		var msg NetworkMessage
+		if err := readTestFrame(remote, &msg); err == nil {
+			received <- msg
+		}
+	}()
+
+	// Only the first MaxInvPerMessage items of an oversized inv are requested
+	ids := make([]string, MaxInvPerMessage+5)
+	for i := range ids {
+		ids[i] = invID([]byte(fmt.Sprintf("item-%d", i)))
+	}
+	inv, err := EncodePayload(ids)
+	if err != nil {
+		t.Fatalf("Failed to encode inventory: %v", err)
+	}
+	if err := node.handleInv(peer, NewNetworkMessage(MessageTypeInv, "relay", "", inv)); err != nil {
+		t.Fatalf("handleInv failed: %v", err)
+	}
+
+	var msg NetworkMessage
+	select {
+	case msg = <-received:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a getdata for the announced items")
+	}
+	var requested []string
+	if err := DecodePayload(msg.Payload, &requested); err != nil {
+		t.Fatalf("Failed to decode getdata: %v", err)
+	}
+	if len(requested) != MaxInvPerMessage {
+		t.Fatalf("Expected %d items requested, got %d", MaxInvPerMessage, len(requested))
+	}
+	if requested[len(requested)-1] != ids[MaxInvPerMessage-1] {
+		t.Errorf("Expected the first %d items to be requested", MaxInvPerMessage)
+	}
+}
@@ -0,0 +1,113 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+)
+
+// flakyDialer refuses the first failures dials and then connects normally
+type flakyDialer struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+}
+
+func (d *flakyDialer) Dial(network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	d.attempts++
+	refuse := d.attempts <= d.failures
+	d.mu.Unlock()
+
+	if refuse {
+		return nil, errors.New("connection refused")
+	}
+	return net.Dial(network, address)
+}
+
+func newRetryTestNode(t *testing.T, dialer *flakyDialer) *Node {
+	node := &Node{
+		Config: &Config{
+			Address:   "localhost:0",
+			BlockType: blockchain.GoldenBlock,
+		},
+		Peers: make(map[string]*Peer),
+		quit:  make(chan struct{}),
+		dial:  dialer.Dial,
+	}
+	t.Cleanup(func() { node.Stop() })
+	return node
+}
+
+func startSinkPeer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestConnectToPeerWithRetry(t *testing.T) {
+	address := startSinkPeer(t)
+	dialer := &flakyDialer{failures: 3}
+	node := newRetryTestNode(t, dialer)
+
+	if err := node.ConnectToPeerWithRetry(address, 5, time.Millisecond); err != nil {
+		t.Fatalf("Expected eventual connection, got: %v", err)
+	}
+	if dialer.attempts != 4 {
+		t.Errorf("Expected 4 dial attempts, got %d", dialer.attempts)
+	}
+	if peers := node.GetPeerAddresses(); len(peers) != 1 || peers[0] != address {
+		t.Errorf("Expected peer %s to be registered, got %v", address, peers)
+	}
+}
+
+func TestConnectToPeerWithRetryGivesUp(t *testing.T) {
+	address := startSinkPeer(t)
+	dialer := &flakyDialer{failures: 10}
+	node := newRetryTestNode(t, dialer)
+
+	if err := node.ConnectToPeerWithRetry(address, 3, time.Millisecond); err == nil {
+		t.Fatal("Expected error after exhausting attempts")
+	}
+	if dialer.attempts != 3 {
+		t.Errorf("Expected 3 dial attempts, got %d", dialer.attempts)
+	}
+}
+
+func TestConnectToBootstrapPeers(t *testing.T) {
+	address := startSinkPeer(t)
+	dialer := &flakyDialer{failures: 2}
+	node := newRetryTestNode(t, dialer)
+	node.Config.BootstrapPeers = []string{address}
+	node.Config.ConnectRetries = 5
+	node.Config.ConnectBaseDelay = time.Millisecond
+
+	node.ConnectToBootstrapPeers()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if len(node.GetPeerAddresses()) == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Bootstrap peer was never connected")
+}
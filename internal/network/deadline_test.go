@@ -0,0 +1,80 @@
+package network
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryDisconnectsSilentPeer(t *testing.T) {
+	config := NewDiscoveryConfig()
+	config.ReadTimeout = 100 * time.Millisecond
+	dm := NewDiscoveryManager(nil, config)
+	defer dm.Stop()
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	done := make(chan struct{})
+	go func() {
+		dm.handleConnection(local)
+		close(done)
+	}()
+
+	// The peer connects and never sends anything
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The handler did not time out a silent peer")
+	}
+
+	dm.mu.RLock()
+	_, tracked := dm.peers["pipe"]
+	_, connected := dm.connections["pipe"]
+	dm.mu.RUnlock()
+	if tracked || connected {
+		t.Error("The silent peer was not disconnected")
+	}
+
+	remote.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := remote.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+}
+
+func TestDiscoveryReadDeadlineResetsOnMessage(t *testing.T) {
+	config := NewDiscoveryConfig()
+	config.ReadTimeout = 200 * time.Millisecond
+	dm := NewDiscoveryManager(nil, config)
+	defer dm.Stop()
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	done := make(chan struct{})
+	go func() {
+		dm.handleConnection(local)
+		close(done)
+	}()
+	go io.Copy(io.Discard, remote)
+
+	// Messages arriving within the timeout keep the peer connected well past it
+	ping := []byte(`{"type":"ping","payload":null}`)
+	frame := discoveryTestFrame(ping)
+	for i := 0; i < 5; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if _, err := remote.Write(frame); err != nil {
+			t.Fatalf("Failed to send ping %d: %v", i, err)
+		}
+	}
+	select {
+	case <-done:
+		t.Fatal("The handler disconnected a peer that kept sending")
+	default:
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The handler did not time out the peer once it went silent")
+	}
+}
@@ -8,13 +8,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"net"
+	"os"
 	"sync"
 	"time"
 
 	"byc/internal/blockchain"
 	"byc/internal/logger"
+	"byc/internal/security"
 
 	"go.uber.org/zap"
 )
@@ -27,12 +28,18 @@ type DiscoveryConfig struct {
 	PingInterval     time.Duration
 	PingTimeout      time.Duration
 	MaxPingLatency   time.Duration
-	MaxConnections   int
 	MaxInboundRate   int64
 	MaxOutboundRate  int64
 	CompressionLevel int
 	EnableTLS        bool
 	TLSConfig        *tls.Config
+	// ReadTimeout is how long a connection may go without a message before
+	// the peer is disconnected; WriteTimeout bounds each message sent.
+	// Zero disables either.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// Magic starts every message frame; zero uses the node's
+	Magic NetworkMagic
 }
 
 // PeerInfo represents information about a peer
@@ -48,6 +55,9 @@ type PeerInfo struct {
 	IsOutbound   bool
 	IsCompressed bool
 	IsTLS        bool
+	// BytesSent and BytesReceived count the framed messages exchanged
+	BytesSent     uint64
+	BytesReceived uint64
 }
 
 // BootstrapNode represents a known bootstrap node
@@ -71,7 +81,13 @@ type DiscoveryManager struct {
 	cancel         context.CancelFunc
 	bootstrapNodes map[string]*BootstrapNode
 	knownPeers     map[string]*Peer
-	node           *Node
+	// nodePeers are the connected peers of node, registered by the node
+	nodePeers map[string]*Peer
+	node      *Node
+	// limiter counts connections against the node's caps
+	limiter *security.PeerLimiter
+	// rand picks the peers GetRandomPeers returns
+	rand *peerRand
 }
 
 // NewDiscoveryConfig creates a new discovery configuration
@@ -83,19 +99,27 @@ func NewDiscoveryConfig() *DiscoveryConfig {
 		PingInterval:     30 * time.Second,
 		PingTimeout:      5 * time.Second,
 		MaxPingLatency:   1000 * time.Millisecond,
-		MaxConnections:   100,
 		MaxInboundRate:   1024 * 1024, // 1MB/s
 		MaxOutboundRate:  1024 * 1024, // 1MB/s
 		CompressionLevel: 6,
 		EnableTLS:        true,
 		TLSConfig:        &tls.Config{},
+		ReadTimeout:      90 * time.Second, // three missed pings
+		WriteTimeout:     30 * time.Second,
 	}
 }
 
 // NewDiscoveryManager creates a new discovery manager
 func NewDiscoveryManager(node *Node, config *DiscoveryConfig) *DiscoveryManager {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &DiscoveryManager{
+
+	// Share the node's limiter so discovery and the node draw on one budget
+	limiter := security.NewPeerLimiter(0, 0)
+	if node != nil {
+		limiter = node.Limiter()
+	}
+
+	dm := &DiscoveryManager{
 		config:         config,
 		blockchain:     nil,
 		peers:          make(map[string]*PeerInfo),
@@ -106,8 +130,21 @@ func NewDiscoveryManager(node *Node, config *DiscoveryConfig) *DiscoveryManager
 		cancel:         cancel,
 		bootstrapNodes: make(map[string]*BootstrapNode),
 		knownPeers:     make(map[string]*Peer),
+		nodePeers:      make(map[string]*Peer),
 		node:           node,
+		limiter:        limiter,
+		rand:           newPeerRand(),
+	}
+	if node != nil {
+		node.attachDiscovery(dm)
 	}
+	return dm
+}
+
+// SetRandSeed seeds the choice of peers returned by GetRandomPeers, so a
+// selection can be reproduced
+func (dm *DiscoveryManager) SetRandSeed(seed int64) {
+	dm.rand.seed(seed)
 }
 
 // Start starts the discovery manager
@@ -127,13 +164,15 @@ func (dm *DiscoveryManager) Stop() {
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
 
-	// Close all connections
-	for _, conn := range dm.connections {
+	// Close all connections and forget their peers; the node's peers stay
+	// registered until the node drops them
+	for addr, conn := range dm.connections {
 		conn.Close()
+		dm.limiter.Release(addr)
+		if _, ok := dm.nodePeers[addr]; !ok {
+			delete(dm.peers, addr)
+		}
 	}
-
-	// Clear peers and connections
-	dm.peers = make(map[string]*PeerInfo)
 	dm.connections = make(map[string]net.Conn)
 }
 
@@ -230,6 +269,10 @@ func (dm *DiscoveryManager) cleanupInactivePeers() {
 
 	now := time.Now()
 	for addr, peer := range dm.knownPeers {
+		// Connected node peers stay known until the node drops them
+		if _, connected := dm.nodePeers[addr]; connected {
+			continue
+		}
 		if now.Sub(peer.LastSeen) > 30*time.Minute {
 			delete(dm.knownPeers, addr)
 		}
@@ -244,7 +287,8 @@ func (dm *DiscoveryManager) AddPeer(peer *Peer) {
 	dm.knownPeers[peer.Address] = peer
 }
 
-// GetRandomPeers returns a random selection of peers
+// GetRandomPeers returns a random selection of peers, spread across as many
+// network groups as possible so peers from one subnet cannot crowd out the rest
 func (dm *DiscoveryManager) GetRandomPeers(count int) []*Peer {
 	dm.mu.RLock()
 	defer dm.mu.RUnlock()
@@ -254,11 +298,9 @@ func (dm *DiscoveryManager) GetRandomPeers(count int) []*Peer {
 		peers = append(peers, peer)
 	}
 
-	// Shuffle peers
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(peers), func(i, j int) {
-		peers[i], peers[j] = peers[j], peers[i]
-	})
+	// Shuffle peers, then take one from each network group in turn
+	dm.rand.shufflePeers(peers)
+	peers = spreadPeersByNetGroup(peers)
 
 	// Return requested number of peers
 	if count > len(peers) {
@@ -320,8 +362,8 @@ func (dm *DiscoveryManager) connectToPeer(addr string, isBootstrap bool) error {
 	}
 
 	// Check connection limit
-	if len(dm.connections) >= dm.config.MaxConnections {
-		return errors.New("connection limit reached")
+	if err := dm.limiter.AcquireOutbound(addr); err != nil {
+		return err
 	}
 
 	// Create connection
@@ -333,6 +375,7 @@ func (dm *DiscoveryManager) connectToPeer(addr string, isBootstrap bool) error {
 		conn, err = net.Dial("tcp", addr)
 	}
 	if err != nil {
+		dm.limiter.Release(addr)
 		return err
 	}
 
@@ -432,8 +475,9 @@ func (dm *DiscoveryManager) handleConnection(conn net.Conn) {
 
 	// Check connection limit
 	dm.mu.Lock()
-	if len(dm.connections) >= dm.config.MaxConnections {
+	if err := dm.limiter.Acquire(addr); err != nil {
 		dm.mu.Unlock()
+		logger.Warn("Refusing connection", zap.String("peer", addr), zap.Error(err))
 		return
 	}
 
@@ -459,13 +503,24 @@ func (dm *DiscoveryManager) handleConnection(conn net.Conn) {
 		case <-dm.ctx.Done():
 			return
 		default:
-			// Read message
+			// A peer that stops sending is dropped rather than holding the
+			// connection open; each message read restarts the clock
+			if dm.config.ReadTimeout > 0 {
+				conn.SetReadDeadline(time.Now().Add(dm.config.ReadTimeout))
+			}
 			msg, err := dm.readMessage(conn)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				logger.Info("Disconnecting idle peer",
+					zap.String("peer", addr), zap.Duration("read_timeout", dm.config.ReadTimeout))
+				dm.disconnectPeer(addr)
+				return
+			}
 			if err != nil {
 				fmt.Printf("Failed to read message from %s: %v\n", addr, err)
 				dm.disconnectPeer(addr)
 				return
 			}
+			dm.recordTraffic(addr, 0, MagicSize+messageLengthSize+len(msg))
 
 			// Handle message
 			if err := dm.handleMessage(addr, msg); err != nil {
@@ -477,8 +532,16 @@ func (dm *DiscoveryManager) handleConnection(conn net.Conn) {
 	}
 }
 
-// disconnectPeer disconnects from a peer
+// disconnectPeer disconnects from a peer, through the node if it is one of
+// the node's peers
 func (dm *DiscoveryManager) disconnectPeer(addr string) {
+	if peer, ok := dm.nodePeer(addr); ok {
+		dm.node.removePeer(peer)
+		if peer.conn != nil {
+			peer.conn.Close()
+		}
+	}
+
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
 
@@ -486,6 +549,7 @@ func (dm *DiscoveryManager) disconnectPeer(addr string) {
 	if conn, exists := dm.connections[addr]; exists {
 		conn.Close()
 		delete(dm.connections, addr)
+		dm.limiter.Release(addr)
 	}
 
 	// Remove peer
@@ -495,6 +559,9 @@ func (dm *DiscoveryManager) disconnectPeer(addr string) {
 
 // pingPeer pings a peer
 func (dm *DiscoveryManager) pingPeer(addr string) (time.Duration, error) {
+	if peer, ok := dm.nodePeer(addr); ok {
+		return dm.pingNodePeer(peer)
+	}
 	start := time.Now()
 
 	// Send ping message
@@ -525,12 +592,19 @@ func (dm *DiscoveryManager) requestPeerList(addr string) error {
 	return dm.sendMessage(addr, "getpeers", nil)
 }
 
+// messageLengthSize is the size of the length prefix framing discovery messages
+const messageLengthSize = 4
+
 // readMessage reads a message from a connection
 func (dm *DiscoveryManager) readMessage(conn net.Conn) ([]byte, error) {
-	// Read message length (4 bytes)
-	lenBuf := make([]byte, 4)
+	if err := readMagic(conn, dm.magic()); err != nil {
+		return nil, err
+	}
+
+	// Read message length
+	lenBuf := make([]byte, messageLengthSize)
 	if _, err := io.ReadFull(conn, lenBuf); err != nil {
-		return nil, fmt.Errorf("failed to read message length: %v", err)
+		return nil, fmt.Errorf("failed to read message length: %w", err)
 	}
 
 	// Parse message length
@@ -542,7 +616,7 @@ func (dm *DiscoveryManager) readMessage(conn net.Conn) ([]byte, error) {
 	// Read message payload
 	msgBuf := make([]byte, msgLen)
 	if _, err := io.ReadFull(conn, msgBuf); err != nil {
-		return nil, fmt.Errorf("failed to read message payload: %v", err)
+		return nil, fmt.Errorf("failed to read message payload: %w", err)
 	}
 
 	return msgBuf, nil
@@ -579,15 +653,25 @@ func (dm *DiscoveryManager) sendMessage(addr string, msgType string, payload int
 		return fmt.Errorf("failed to marshal message: %v", err)
 	}
 
-	// Send message length
-	lenBuf := make([]byte, 4)
-	binary.BigEndian.PutUint32(lenBuf, uint32(len(data)))
-	if _, err := conn.Write(lenBuf); err != nil {
+	if dm.config.WriteTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(dm.config.WriteTimeout))
+	}
+
+	// Send network magic and message length
+	magic := dm.magic()
+	header := make([]byte, MagicSize+messageLengthSize)
+	copy(header, magic[:])
+	binary.BigEndian.PutUint32(header[MagicSize:], uint32(len(data)))
+	n, err := conn.Write(header)
+	dm.recordTraffic(addr, n, 0)
+	if err != nil {
 		return fmt.Errorf("failed to write message length: %v", err)
 	}
 
 	// Send message payload
-	if _, err := conn.Write(data); err != nil {
+	n, err = conn.Write(data)
+	dm.recordTraffic(addr, n, 0)
+	if err != nil {
 		return fmt.Errorf("failed to write message payload: %v", err)
 	}
 
@@ -639,9 +723,15 @@ func (dm *DiscoveryManager) handleMessage(addr string, msg []byte) error {
 			return fmt.Errorf("failed to unmarshal peer list: %v", err)
 		}
 
-		// Connect to new peers
+		// Connect to new peers, through the node when there is one so they
+		// join its peer table and the registry together
 		for _, peerAddr := range peerAddrs {
-			if peerAddr != addr && !dm.isPeerConnected(peerAddr) {
+			if peerAddr == addr || dm.isPeerConnected(peerAddr) {
+				continue
+			}
+			if dm.node != nil {
+				go dm.node.connectToPeer(peerAddr)
+			} else {
 				go dm.connectToPeer(peerAddr, false)
 			}
 		}
@@ -0,0 +1,128 @@
+package network
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+	"byc/internal/crypto"
+	"byc/internal/security"
+)
+
+// dialVersion connects to node and sends a version message advertising protocolVersion
+func dialVersion(t *testing.T, node *Node, protocolVersion int32) net.Conn {
+	key, err := security.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+	return dialVersionWithKey(t, node, protocolVersion, crypto.PublicKeyToBytes(&key.PublicKey), []byte("test challenge"))
+}
+
+// dialVersionWithKey connects to node and sends a version message advertising
+// protocolVersion, identity key publicKey and challenge
+func dialVersionWithKey(t *testing.T, node *Node, protocolVersion int32, publicKey, challenge []byte) net.Conn {
+	conn, err := net.Dial("tcp", node.GetAddress())
+	if err != nil {
+		t.Fatalf("Failed to dial node: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	payload, err := json.Marshal(VersionPayload{
+		ProtocolVersion: protocolVersion,
+		UserAgent:       "/test-client:0.1/",
+		Address:         "localhost:0",
+		BlockType:       blockchain.GoldenBlock,
+		PublicKey:       publicKey,
+		Challenge:       challenge,
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode version: %v", err)
+	}
+	msg := NetworkMessage{Type: MessageTypeVersion, Payload: payload, Timestamp: time.Now()}
+	if err := writeTestFrame(conn, msg); err != nil {
+		t.Fatalf("Failed to send version: %v", err)
+	}
+	return conn
+}
+
+func newHandshakeTestNode(t *testing.T) *Node {
+	node, err := NewNode(&Config{
+		Address:            "localhost:3000",
+		BlockType:          blockchain.GoldenBlock,
+		MinProtocolVersion: 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	t.Cleanup(func() { node.Stop() })
+	return node
+}
+
+func TestHandshakeRejectsOldProtocolVersion(t *testing.T) {
+	node := newHandshakeTestNode(t)
+	conn := dialVersion(t, node, 1)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var msg NetworkMessage
+	if err := readTestFrame(conn, &msg); err != nil {
+		t.Fatalf("Expected reject message, got error: %v", err)
+	}
+	if msg.Type != MessageTypeReject {
+		t.Fatalf("Expected %s message, got %s", MessageTypeReject, msg.Type)
+	}
+
+	var reject RejectPayload
+	if err := json.Unmarshal(msg.Payload, &reject); err != nil {
+		t.Fatalf("Failed to decode reject: %v", err)
+	}
+	if !strings.Contains(reject.Reason, "protocol version 1 is below minimum 2") {
+		t.Errorf("Unexpected reject reason: %q", reject.Reason)
+	}
+
+	// The node hangs up after rejecting
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected connection to be closed after reject")
+	}
+	if len(node.GetPeers()) != 0 {
+		t.Errorf("Expected rejected peer to be removed, have %d peers", len(node.GetPeers()))
+	}
+}
+
+func TestHandshakeAcceptsCurrentProtocolVersion(t *testing.T) {
+	node := newHandshakeTestNode(t)
+	conn := dialVersion(t, node, ProtocolVersion)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var msg NetworkMessage
+	if err := readTestFrame(conn, &msg); err != nil {
+		t.Fatalf("Expected version reply, got error: %v", err)
+	}
+	if msg.Type != MessageTypeVersion {
+		t.Fatalf("Expected %s reply, got %s", MessageTypeVersion, msg.Type)
+	}
+
+	var version VersionPayload
+	if err := json.Unmarshal(msg.Payload, &version); err != nil {
+		t.Fatalf("Failed to decode version: %v", err)
+	}
+	if version.ProtocolVersion != ProtocolVersion || version.UserAgent != DefaultUserAgent {
+		t.Errorf("Unexpected version reply: %+v", version)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, peer := range node.GetPeers() {
+			peer.mu.RLock()
+			userAgent := peer.UserAgent
+			peer.mu.RUnlock()
+			if userAgent == "/test-client:0.1/" {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Peer user agent was not recorded")
+}
@@ -0,0 +1,334 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+	"byc/internal/security"
+)
+
+// testNetwork is a set of in-process nodes connected over in-memory pipes.
+// The links speak the nodes' real framing and handshake; each one can be
+// slowed down or cut to simulate latency and partitions.
+type testNetwork struct {
+	t     *testing.T
+	nodes []*Node
+	// index maps a node's address to its position in nodes
+	index map[string]int
+
+	mu      sync.Mutex
+	latency map[[2]int]time.Duration
+	cut     map[[2]int]bool
+}
+
+// newTestNetwork creates n unconnected nodes, each on its own chain and in
+// its own /16 so the connection caps never get in the way
+func newTestNetwork(t *testing.T, n int) *testNetwork {
+	tn := &testNetwork{
+		t:       t,
+		index:   make(map[string]int),
+		latency: make(map[[2]int]time.Duration),
+		cut:     make(map[[2]int]bool),
+	}
+	for i := 0; i < n; i++ {
+		identity, err := security.GenerateKeyPair()
+		if err != nil {
+			t.Fatalf("Failed to generate node %d identity: %v", i, err)
+		}
+		from := i
+		node := &Node{
+			Config: &Config{
+				Address:   fmt.Sprintf("10.%d.0.1:3000", i+1),
+				BlockType: blockchain.GoldenBlock,
+			},
+			Blockchain: blockchain.NewBlockchain(),
+			Peers:      make(map[string]*Peer),
+			quit:       make(chan struct{}),
+			identity:   identity,
+			dial: func(network, address string) (net.Conn, error) {
+				return tn.dial(from, address)
+			},
+		}
+		node.SetRandSeed(int64(i))
+		t.Cleanup(func() { node.Stop() })
+
+		tn.index[node.Config.Address] = i
+		tn.nodes = append(tn.nodes, node)
+	}
+	return tn
+}
+
+// newLineNetwork creates n nodes each connected to the next, so a message
+// from node 0 must be relayed by every node to reach node n-1
+func newLineNetwork(t *testing.T, n int) *testNetwork {
+	tn := newTestNetwork(t, n)
+	for i := 0; i+1 < n; i++ {
+		tn.connect(i, i+1)
+	}
+	return tn
+}
+
+// dial opens a pipe from node from to the node at address, which accepts
+// the other end as an inbound connection
+func (tn *testNetwork) dial(from int, address string) (net.Conn, error) {
+	to, ok := tn.index[address]
+	if !ok {
+		return nil, fmt.Errorf("no test node at %s", address)
+	}
+
+	local, remote := net.Pipe()
+	go tn.nodes[to].handleConnection(newTestLink(remote, tn, to, from, tn.nodes[from].Config.Address))
+	return newTestLink(local, tn, from, to, address), nil
+}
+
+// connect dials node j from node i and waits until both have authenticated
+// the other, so messages sent afterward are not dropped
+func (tn *testNetwork) connect(i, j int) {
+	tn.t.Helper()
+	if err := tn.nodes[i].ConnectToPeer(tn.nodes[j].Config.Address); err != nil {
+		tn.t.Fatalf("Failed to connect node %d to node %d: %v", i, j, err)
+	}
+	tn.waitFor(fmt.Sprintf("nodes %d and %d to authenticate each other", i, j), func() bool {
+		return tn.authenticated(i, j) && tn.authenticated(j, i)
+	})
+}
+
+// authenticated reports whether node i holds an authenticated peer for node j
+func (tn *testNetwork) authenticated(i, j int) bool {
+	address := tn.nodes[j].Config.Address
+	for _, peer := range tn.nodes[i].GetPeers() {
+		if peer.Address == address && peer.isAuthenticated() {
+			return true
+		}
+	}
+	return false
+}
+
+// setLatency delays every message between nodes i and j, in both directions
+func (tn *testNetwork) setLatency(i, j int, d time.Duration) {
+	tn.mu.Lock()
+	defer tn.mu.Unlock()
+	tn.latency[linkKey(i, j)] = d
+}
+
+// partition silently drops every message between nodes i and j, leaving
+// the connection open, until heal is called
+func (tn *testNetwork) partition(i, j int) {
+	tn.mu.Lock()
+	defer tn.mu.Unlock()
+	tn.cut[linkKey(i, j)] = true
+}
+
+// heal restores the link between nodes i and j
+func (tn *testNetwork) heal(i, j int) {
+	tn.mu.Lock()
+	defer tn.mu.Unlock()
+	delete(tn.cut, linkKey(i, j))
+}
+
+// link returns the latency of the link between nodes i and j and whether it is cut
+func (tn *testNetwork) link(i, j int) (time.Duration, bool) {
+	tn.mu.Lock()
+	defer tn.mu.Unlock()
+	key := linkKey(i, j)
+	return tn.latency[key], tn.cut[key]
+}
+
+// hasPending reports whether node i holds the transaction with id in its
+// pending pool
+func (tn *testNetwork) hasPending(i int, id []byte) bool {
+	for _, pending := range tn.nodes[i].Blockchain.GetPendingTransactions() {
+		if bytes.Equal(pending.ID, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitFor fails the test if cond does not hold within five seconds
+func (tn *testNetwork) waitFor(what string, cond func() bool) {
+	tn.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			tn.t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// chains returns every node's blockchain
+func (tn *testNetwork) chains() []*blockchain.Blockchain {
+	chains := make([]*blockchain.Blockchain, len(tn.nodes))
+	for i, node := range tn.nodes {
+		chains[i] = node.Blockchain
+	}
+	return chains
+}
+
+func linkKey(i, j int) [2]int {
+	if i > j {
+		i, j = j, i
+	}
+	return [2]int{i, j}
+}
+
+// testLink is one end of a pipe between two test nodes. Writes are queued
+// and delivered in order by their own goroutine, as a socket buffer would,
+// so two nodes writing to each other at once never deadlock on the
+// unbuffered pipe. Each write is one whole frame, so a cut link drops
+// messages without corrupting the stream.
+type testLink struct {
+	net.Conn
+	net      *testNetwork
+	from, to int
+	remote   net.Addr
+
+	frames    chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newTestLink(conn net.Conn, tn *testNetwork, from, to int, remote string) *testLink {
+	l := &testLink{
+		Conn:   conn,
+		net:    tn,
+		from:   from,
+		to:     to,
+		remote: testAddr(remote),
+		frames: make(chan []byte, 64),
+		done:   make(chan struct{}),
+	}
+	go l.deliver()
+	return l
+}
+
+func (l *testLink) Write(p []byte) (int, error) {
+	if _, cut := l.net.link(l.from, l.to); cut {
+		return len(p), nil
+	}
+	select {
+	case l.frames <- append([]byte(nil), p...):
+		return len(p), nil
+	case <-l.done:
+		return 0, net.ErrClosed
+	}
+}
+
+// deliver writes the queued frames to the pipe, each after the link's latency
+func (l *testLink) deliver() {
+	for {
+		select {
+		case frame := <-l.frames:
+			latency, _ := l.net.link(l.from, l.to)
+			time.Sleep(latency)
+			if _, err := l.Conn.Write(frame); err != nil {
+				return
+			}
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *testLink) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Conn.Close()
+}
+
+func (l *testLink) RemoteAddr() net.Addr { return l.remote }
+
+// testAddr is the address a test node is known by
+type testAddr string
+
+func (a testAddr) Network() string { return "pipe" }
+func (a testAddr) String() string  { return string(a) }
+
+func TestTransactionPropagatesAcrossNetwork(t *testing.T) {
+	const size = 4
+	tn := newLineNetwork(t, size)
+	tx := signedBroadcastTx(t, tn.chains()...)
+
+	payload, err := EncodePayload(tx)
+	if err != nil {
+		t.Fatalf("Failed to encode transaction: %v", err)
+	}
+	sender := tn.nodes[0]
+	if err := sender.BroadcastMessage(*NewNetworkMessage(MessageTypeTx, sender.GetAddress(), "", payload)); err != nil {
+		t.Fatalf("BroadcastMessage failed: %v", err)
+	}
+
+	tn.waitFor("the transaction to reach the last node", func() bool {
+		return tn.hasPending(size-1, tx.ID)
+	})
+	for i := range tn.nodes {
+		if !tn.hasPending(i, tx.ID) {
+			t.Errorf("Node %d never accepted the transaction", i)
+		}
+	}
+}
+
+func TestPartitionStopsPropagation(t *testing.T) {
+	tn := newLineNetwork(t, 3)
+	tn.setLatency(0, 1, 20*time.Millisecond)
+	tn.partition(1, 2)
+	tx := signedBroadcastTx(t, tn.chains()...)
+
+	payload, err := EncodePayload(tx)
+	if err != nil {
+		t.Fatalf("Failed to encode transaction: %v", err)
+	}
+	sender := tn.nodes[0]
+	if err := sender.BroadcastMessage(*NewNetworkMessage(MessageTypeTx, sender.GetAddress(), "", payload)); err != nil {
+		t.Fatalf("BroadcastMessage failed: %v", err)
+	}
+
+	// The transaction crosses the slow link but not the cut one
+	tn.waitFor("the transaction to reach node 1", func() bool {
+		return tn.hasPending(1, tx.ID)
+	})
+	time.Sleep(100 * time.Millisecond)
+	if tn.hasPending(2, tx.ID) {
+		t.Error("The transaction crossed a partitioned link")
+	}
+}
+
+func TestOrphanParentFetchedAndRelayed(t *testing.T) {
+	tn := newLineNetwork(t, 3)
+	parent := mineRequestTestBlock(t, tn.nodes[0].Blockchain)
+	child := mineRequestTestBlock(t, tn.nodes[0].Blockchain)
+
+	// Node 1 hears of the child first, so must fetch the parent from node 0
+	// and then relay both on to node 2
+	var peer *Peer
+	for _, p := range tn.nodes[0].GetPeers() {
+		if p.Address == tn.nodes[1].Config.Address {
+			peer = p
+		}
+	}
+	if peer == nil {
+		t.Fatal("Node 0 has no peer for node 1")
+	}
+	if err := tn.nodes[0].sendMessage(peer, MessageTypeBlock, child); err != nil {
+		t.Fatalf("Failed to send the child block: %v", err)
+	}
+
+	hasBlock := func(i int, hash []byte) bool {
+		_, err := tn.nodes[i].Blockchain.GetBlock(hash)
+		return err == nil
+	}
+	tn.waitFor("node 1 to connect the orphan", func() bool {
+		return hasBlock(1, parent.Hash) && hasBlock(1, child.Hash)
+	})
+	tn.waitFor("node 2 to receive both blocks", func() bool {
+		return hasBlock(2, parent.Hash) && hasBlock(2, child.Hash)
+	})
+	if n := tn.nodes[1].Blockchain.OrphanCount(); n != 0 {
+		t.Errorf("Expected node 1's orphan pool empty, got %d", n)
+	}
+}
@@ -0,0 +1,54 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+
+	"byc/internal/blockchain"
+)
+
+func TestNodeIdentityPersists(t *testing.T) {
+	dataDir := t.TempDir()
+
+	newNode := func() *Node {
+		node, err := NewNode(&Config{
+			Address:            "localhost:3000",
+			BlockType:          blockchain.GoldenBlock,
+			DataDir:            dataDir,
+			IdentityPassphrase: "secret",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+		return node
+	}
+
+	first := newNode()
+	firstKey := first.PublicKey()
+	first.Stop()
+
+	second := newNode()
+	defer second.Stop()
+
+	if len(firstKey) == 0 {
+		t.Fatal("Node has no identity key")
+	}
+	if !bytes.Equal(firstKey, second.PublicKey()) {
+		t.Error("Node identity changed across restarts")
+	}
+
+	// A different data dir gets a different identity
+	other, err := NewNode(&Config{Address: "localhost:3000", DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	defer other.Stop()
+	if bytes.Equal(firstKey, other.PublicKey()) {
+		t.Error("Separate data dirs share an identity")
+	}
+
+	// The wrong passphrase is rejected
+	if _, err := NewNode(&Config{Address: "localhost:3000", DataDir: dataDir, IdentityPassphrase: "wrong"}); err == nil {
+		t.Error("Expected error loading identity with the wrong passphrase")
+	}
+}
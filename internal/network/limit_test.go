@@ -0,0 +1,92 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+	"byc/internal/security"
+)
+
+func newLimitTestNode(t *testing.T, maxConnections, maxConnectionsPerIP int) *Node {
+	node, err := NewNode(&Config{
+		Address:             "localhost:3000",
+		BlockType:           blockchain.GoldenBlock,
+		MaxConnections:      maxConnections,
+		MaxConnectionsPerIP: maxConnectionsPerIP,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	t.Cleanup(func() { node.Stop() })
+	return node
+}
+
+// dialLimitTestNode opens a raw connection to node
+func dialLimitTestNode(t *testing.T, node *Node) net.Conn {
+	conn, err := net.Dial("tcp", node.GetAddress())
+	if err != nil {
+		t.Fatalf("Failed to dial node: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// waitForConnections waits until node holds want connection slots
+func waitForConnections(t *testing.T, node *Node, want int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for node.Limiter().Count() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d connections, have %d", want, node.Limiter().Count())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// expectRefused checks that the node hung up on conn without reading from it
+func expectRefused(t *testing.T, conn net.Conn) {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err := conn.Read(make([]byte, 1))
+	var netErr net.Error
+	if err == nil || (errors.As(err, &netErr) && netErr.Timeout()) {
+		t.Fatalf("Expected the connection over the limit to be closed, got %v", err)
+	}
+}
+
+func TestMaxConnectionsPerIPRefusesExtraConnections(t *testing.T) {
+	node := newLimitTestNode(t, 10, 2)
+
+	dialLimitTestNode(t, node)
+	dialLimitTestNode(t, node)
+	waitForConnections(t, node, 2)
+
+	expectRefused(t, dialLimitTestNode(t, node))
+	if count := node.Limiter().Count(); count != 2 {
+		t.Errorf("Expected the refused connection not to be counted, have %d", count)
+	}
+}
+
+func TestMaxConnectionsCapsInboundAndOutbound(t *testing.T) {
+	node := newLimitTestNode(t, 2, 5)
+	other := newLimitTestNode(t, 0, 0)
+
+	first := dialLimitTestNode(t, node)
+	if err := node.ConnectToPeer(other.GetAddress()); err != nil {
+		t.Fatalf("Failed to connect to peer: %v", err)
+	}
+	waitForConnections(t, node, 2)
+
+	// Both directions draw on the same budget
+	expectRefused(t, dialLimitTestNode(t, node))
+	if err := node.ConnectToPeer(other.GetAddress()); !errors.Is(err, security.ErrConnectionLimit) {
+		t.Fatalf("Expected an outbound connection over the limit to fail with %v, got %v", security.ErrConnectionLimit, err)
+	}
+
+	// Closing a connection frees its slot
+	first.Close()
+	waitForConnections(t, node, 1)
+	dialLimitTestNode(t, node)
+	waitForConnections(t, node, 2)
+}
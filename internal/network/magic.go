@@ -0,0 +1,82 @@
+package network
+
+import (
+	"fmt"
+	"io"
+
+	"byc/internal/blockchain"
+)
+
+// MagicSize is the length of the network magic that starts every frame
+const MagicSize = 4
+
+// NetworkMagic identifies the network a frame was sent on, so nodes of
+// different networks drop each other's messages instead of decoding them
+type NetworkMagic [MagicSize]byte
+
+var (
+	MainnetMagic = NetworkMagic{0xb7, 0xc0, 0x1e, 0xd1}
+	TestnetMagic = NetworkMagic{0xb7, 0xc0, 0x7e, 0x57}
+	RegtestMagic = NetworkMagic{0xb7, 0xc0, 0xda, 0xb0}
+)
+
+// MagicForMode returns the network magic of a network mode, the mainnet
+// magic for an unknown one
+func MagicForMode(mode blockchain.NetworkMode) NetworkMagic {
+	switch mode {
+	case blockchain.Testnet:
+		return TestnetMagic
+	case blockchain.Regtest:
+		return RegtestMagic
+	default:
+		return MainnetMagic
+	}
+}
+
+// IsZero reports whether the magic is unset
+func (m NetworkMagic) IsZero() bool {
+	return m == NetworkMagic{}
+}
+
+// readMagic reads the magic starting a frame from r and checks it is want,
+// so a frame from another network is rejected before its body is decoded
+func readMagic(r io.Reader, want NetworkMagic) error {
+	var got NetworkMagic
+	if _, err := io.ReadFull(r, got[:]); err != nil {
+		return fmt.Errorf("failed to read network magic: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("network magic mismatch: got %x, want %x", got, want)
+	}
+	return nil
+}
+
+// magic returns the network magic the node frames its messages with: the
+// configured one, or else the one of the network its chain runs
+func (n *Node) magic() NetworkMagic {
+	if n == nil {
+		return MainnetMagic
+	}
+	if n.Config != nil && !n.Config.Magic.IsZero() {
+		return n.Config.Magic
+	}
+	if n.Blockchain != nil {
+		return MagicForMode(n.Blockchain.ConsensusParams().Mode)
+	}
+	return MainnetMagic
+}
+
+// magic returns the network magic discovery frames its messages with: the
+// configured one, or else the node's
+func (dm *DiscoveryManager) magic() NetworkMagic {
+	if dm.config != nil && !dm.config.Magic.IsZero() {
+		return dm.config.Magic
+	}
+	if dm.node != nil {
+		return dm.node.magic()
+	}
+	if dm.blockchain != nil {
+		return MagicForMode(dm.blockchain.ConsensusParams().Mode)
+	}
+	return MainnetMagic
+}
@@ -0,0 +1,126 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+)
+
+// writeTestFrame sends msg to w framed as a node sends it on mainnet
+func writeTestFrame(w io.Writer, msg NetworkMessage) error {
+	var buf bytes.Buffer
+	buf.Write(MainnetMagic[:])
+	if err := gob.NewEncoder(&buf).Encode(msg); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readTestFrame reads a message framed as a node sends it on mainnet
+func readTestFrame(r io.Reader, msg *NetworkMessage) error {
+	if err := readMagic(r, MainnetMagic); err != nil {
+		return err
+	}
+	return gob.NewDecoder(r).Decode(msg)
+}
+
+// discoveryTestFrame frames payload as discovery sends it on mainnet
+func discoveryTestFrame(payload []byte) []byte {
+	frame := make([]byte, MagicSize+messageLengthSize, MagicSize+messageLengthSize+len(payload))
+	copy(frame, MainnetMagic[:])
+	binary.BigEndian.PutUint32(frame[MagicSize:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+func TestMagicForMode(t *testing.T) {
+	magics := map[NetworkMagic]blockchain.NetworkMode{}
+	for _, mode := range []blockchain.NetworkMode{blockchain.Mainnet, blockchain.Testnet, blockchain.Regtest} {
+		magic := MagicForMode(mode)
+		if other, ok := magics[magic]; ok {
+			t.Errorf("%s and %s share magic %x", mode, other, magic)
+		}
+		magics[magic] = mode
+	}
+
+	node := newRelayTestNode(t, 1)
+	node.Blockchain = blockchain.NewBlockchain()
+	params, err := blockchain.ParamsForMode(blockchain.Testnet)
+	if err != nil {
+		t.Fatalf("Failed to get testnet params: %v", err)
+	}
+	node.Blockchain.SetConsensusParams(params)
+	if got := node.magic(); got != TestnetMagic {
+		t.Errorf("Expected a testnet node to use %x, got %x", TestnetMagic, got)
+	}
+	node.Config.Magic = NetworkMagic{1, 2, 3, 4}
+	if got := node.magic(); got != node.Config.Magic {
+		t.Errorf("Expected the configured magic %x, got %x", node.Config.Magic, got)
+	}
+}
+
+func TestNodeRejectsWrongMagicBeforeDecoding(t *testing.T) {
+	node := newRelayTestNode(t, 1)
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	peer := NewPeer("sender", "sender", 0)
+	peer.conn = local
+
+	// A testnet frame whose body is not even a message: were the body
+	// decoded, the error would be a decode error
+	go remote.Write(append(TestnetMagic[:], "not a gob message"...))
+	_, err := node.receiveMessage(peer)
+	if err == nil || !strings.Contains(err.Error(), "network magic mismatch") {
+		t.Fatalf("Expected a network magic mismatch, got %v", err)
+	}
+
+	// A frame with the right magic is decoded
+	local, remote = net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	peer.conn = local
+	go writeTestFrame(remote, NetworkMessage{Type: MessageTypePing, Payload: []byte("ping")})
+	msg, err := node.receiveMessage(peer)
+	if err != nil {
+		t.Fatalf("Failed to receive a mainnet frame: %v", err)
+	}
+	if msg.Type != MessageTypePing || string(msg.Payload) != "ping" {
+		t.Errorf("Unexpected message %s %q", msg.Type, msg.Payload)
+	}
+}
+
+func TestDiscoveryRejectsWrongMagicBeforeDecoding(t *testing.T) {
+	dm := NewDiscoveryManager(nil, NewDiscoveryConfig())
+	defer dm.Stop()
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	// Only the magic is sent: reading on to the length would time out
+	go remote.Write(TestnetMagic[:])
+	local.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := dm.readMessage(local)
+	if err == nil || !strings.Contains(err.Error(), "network magic mismatch") {
+		t.Fatalf("Expected a network magic mismatch, got %v", err)
+	}
+
+	ping := []byte(`{"type":"ping","payload":null}`)
+	go remote.Write(discoveryTestFrame(ping))
+	msg, err := dm.readMessage(local)
+	if err != nil {
+		t.Fatalf("Failed to read a mainnet frame: %v", err)
+	}
+	if !bytes.Equal(msg, ping) {
+		t.Errorf("Expected %s, got %s", ping, msg)
+	}
+}
@@ -0,0 +1,59 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+	"byc/internal/crypto"
+)
+
+func TestNodeMinesCoinbaseOnlyBlockOnEmptyMempool(t *testing.T) {
+	node := newRelayTestNode(t, 1)
+	node.Blockchain = blockchain.NewBlockchain()
+	payee := bytes.Repeat([]byte{0xab}, 32)
+	node.Config.MiningAddress = crypto.EncodeAddress(0, payee)
+
+	genesis, _ := node.Blockchain.Tip()
+
+	if err := node.StartMining(blockchain.Leah); err != nil {
+		t.Fatalf("Failed to start mining: %v", err)
+	}
+	var mined *blockchain.Block
+	deadline := time.Now().Add(10 * time.Second)
+	for mined == nil && time.Now().Before(deadline) {
+		if golden, _ := node.Blockchain.Tip(); !bytes.Equal(golden.Hash, genesis.Hash) {
+			mined = golden
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	node.StopMining()
+	if mined == nil {
+		t.Fatal("The node mined no block on an empty mempool")
+	}
+
+	// The block was accepted by the chain, so it validated
+	if len(mined.Transactions) != 1 || !mined.Transactions[0].IsCoinbase() {
+		t.Fatalf("Expected a block holding only the coinbase, got %d transactions", len(mined.Transactions))
+	}
+	output := mined.Transactions[0].Outputs[0]
+	if output.Address != node.Config.MiningAddress {
+		t.Errorf("Coinbase pays %q, want %q", output.Address, node.Config.MiningAddress)
+	}
+	if !bytes.Equal(output.PublicKeyHash, payee) {
+		t.Errorf("Coinbase is locked to %x, want the mining address's hash %x", output.PublicKeyHash, payee)
+	}
+}
+
+func TestStartMiningRejectsInvalidAddress(t *testing.T) {
+	node := newRelayTestNode(t, 1)
+	node.Config.MiningAddress = "node-miner"
+	if err := node.StartMining(blockchain.Leah); err == nil {
+		node.StopMining()
+		t.Fatal("Expected mining to an address that does not decode to fail")
+	}
+	if node.IsMining() {
+		t.Error("The node is mining after failing to start")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -175,11 +176,12 @@ func (mc *MultiplexedConn) readLoop() {
 	for {
 		header := make([]byte, 9)
 		if _, err := io.ReadFull(mc.conn, header); err != nil {
-			if err == io.EOF {
-				return
+			// A failed read leaves the frames out of step and a closed
+			// connection fails every read after it, so stop either way
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) && err != io.ErrClosedPipe {
+				logger.Error("failed to read header", zap.Error(err))
 			}
-			logger.Error("failed to read header", zap.Error(err))
-			continue
+			return
 		}
 
 		streamID := binary.BigEndian.Uint32(header[0:4])
@@ -189,7 +191,7 @@ func (mc *MultiplexedConn) readLoop() {
 		data := make([]byte, length)
 		if _, err := io.ReadFull(mc.conn, data); err != nil {
 			logger.Error("failed to read data", zap.Error(err))
-			continue
+			return
 		}
 
 		mc.mu.RLock()
@@ -0,0 +1,124 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"byc/internal/blockchain"
+	"byc/internal/security"
+)
+
+// pipeDialer connects every dial to an in-memory peer that reads and
+// discards whatever it is sent
+func pipeDialer(t *testing.T) func(network, address string) (net.Conn, error) {
+	return func(network, address string) (net.Conn, error) {
+		local, remote := net.Pipe()
+		t.Cleanup(func() {
+			local.Close()
+			remote.Close()
+		})
+		go io.Copy(io.Discard, remote)
+		return local, nil
+	}
+}
+
+func TestNetGroup(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"10.1.2.3:3000", "10.1.0.0/16"},
+		{"10.1.200.7", "10.1.0.0/16"},
+		{"10.2.2.3:3000", "10.2.0.0/16"},
+		{"[2001:db8:1:2::1]:3000", "2001:db8::/32"},
+		{"[2001:db9::1]:3000", "2001:db9::/32"},
+		{"127.0.0.1:3000", ""},
+		{"[::1]:3000", ""},
+		{"localhost:3000", ""},
+		{"seed.example.org:3000", "seed.example.org"},
+	}
+	for _, tt := range tests {
+		if got := security.NetGroup(tt.addr); got != tt.want {
+			t.Errorf("NetGroup(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestOutboundConnectionsCappedPerNetGroup(t *testing.T) {
+	const perGroup = 3
+	node := &Node{
+		Config: &Config{
+			Address:                "localhost:3000",
+			BlockType:              blockchain.GoldenBlock,
+			MaxOutboundPerNetGroup: perGroup,
+		},
+		Peers: make(map[string]*Peer),
+		quit:  make(chan struct{}),
+		dial:  pipeDialer(t),
+	}
+
+	// An attacker advertises many addresses in one /16
+	connected := 0
+	for i := 0; i < 20; i++ {
+		err := node.ConnectToPeer(fmt.Sprintf("10.1.%d.%d:3000", i/10, i%10+1))
+		switch {
+		case err == nil:
+			connected++
+		case !errors.Is(err, security.ErrConnectionLimitPerNetGroup):
+			t.Fatalf("Expected the network group limit, got %v", err)
+		}
+	}
+	if connected != perGroup {
+		t.Errorf("Expected %d connections into the flooded /16, got %d", perGroup, connected)
+	}
+
+	// Other networks are still reachable
+	if err := node.ConnectToPeer("10.2.0.1:3000"); err != nil {
+		t.Errorf("Expected a connection into another /16, got %v", err)
+	}
+	if count := node.Limiter().Count(); count != perGroup+1 {
+		t.Errorf("Expected %d connection slots in use, have %d", perGroup+1, count)
+	}
+
+	// Dropping a connection frees its group's slot
+	node.mu.RLock()
+	var dropped *Peer
+	for addr, peer := range node.Peers {
+		if security.NetGroup(addr) == "10.1.0.0/16" {
+			dropped = peer
+			break
+		}
+	}
+	node.mu.RUnlock()
+	if dropped == nil {
+		t.Fatal("No peer in the flooded /16")
+	}
+	node.removePeer(dropped)
+	if err := node.ConnectToPeer("10.1.9.9:3000"); err != nil {
+		t.Errorf("Expected a freed group slot to be reusable, got %v", err)
+	}
+}
+
+func TestGetRandomPeersSpreadsNetGroups(t *testing.T) {
+	dm := NewDiscoveryManager(nil, NewDiscoveryConfig())
+	for i := 0; i < 50; i++ {
+		dm.AddPeer(&Peer{Address: fmt.Sprintf("10.1.0.%d:3000", i+1)})
+	}
+	for i := 0; i < 3; i++ {
+		dm.AddPeer(&Peer{Address: fmt.Sprintf("10.%d.0.1:3000", i+2)})
+	}
+
+	for seed := int64(0); seed < 10; seed++ {
+		dm.SetRandSeed(seed)
+		groups := make(map[string]int)
+		for _, peer := range dm.GetRandomPeers(4) {
+			groups[security.NetGroup(peer.Address)]++
+		}
+		if len(groups) != 4 {
+			t.Errorf("Seed %d: expected 4 peers from 4 network groups, got %v", seed, groups)
+		}
+	}
+}
@@ -2,15 +2,20 @@ package network
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"encoding/gob"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"time"
 
 	"byc/internal/blockchain"
+	"byc/internal/crypto"
 	"byc/internal/logger"
+	"byc/internal/security"
 	"byc/internal/utils"
 
 	"github.com/google/uuid"
@@ -26,11 +31,27 @@ func NewNode(config *Config) (*Node, error) {
 	}
 	config.Address = p2pAddress
 
-	bc := blockchain.NewBlockchain()
+	// Load the node identity so it survives restarts
+	var identity *ecdsa.PrivateKey
+	if config.DataDir != "" {
+		identity, err = security.LoadOrCreateNodeKey(config.DataDir, config.IdentityPassphrase)
+	} else {
+		identity, err = security.GenerateKeyPair()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load node identity: %v", err)
+	}
+
+	bc := config.Blockchain
+	if bc == nil {
+		bc = blockchain.NewBlockchain()
+	}
 	node := &Node{
 		Config:     config,
 		Blockchain: bc,
 		Peers:      make(map[string]*Peer),
+		quit:       make(chan struct{}),
+		identity:   identity,
 	}
 
 	// Start listening for connections
@@ -66,6 +87,14 @@ func NewNode(config *Config) (*Node, error) {
 
 // Stop stops the node and closes all connections
 func (n *Node) Stop() error {
+	if n.quit != nil {
+		select {
+		case <-n.quit:
+		default:
+			close(n.quit)
+		}
+	}
+
 	if n.server != nil {
 		if err := n.server.Close(); err != nil {
 			logger.Error("Error closing server", zap.Error(err))
@@ -75,31 +104,72 @@ func (n *Node) Stop() error {
 
 	// Close all peer connections
 	n.mu.Lock()
-	defer n.mu.Unlock()
-	for _, peer := range n.Peers {
+	peers := n.Peers
+	for _, peer := range peers {
 		if peer.conn != nil {
 			peer.conn.Close()
 		}
 	}
 	n.Peers = make(map[string]*Peer)
+	n.mu.Unlock()
 
+	for _, peer := range peers {
+		n.dropPeer(peer)
+	}
 	return nil
 }
 
+// Limiter returns the connection limiter shared by everything that opens or
+// accepts peer connections for this node
+func (n *Node) Limiter() *security.PeerLimiter {
+	n.limitOnce.Do(func() {
+		if n.limiter == nil {
+			n.limiter = security.NewPeerLimiter(n.Config.MaxConnections, n.Config.MaxConnectionsPerIP)
+			n.limiter.SetMaxOutboundPerNetGroup(n.Config.MaxOutboundPerNetGroup)
+		}
+	})
+	return n.limiter
+}
+
+// releaseConnection frees the connection slot held by peer, if any
+func (n *Node) releaseConnection(peer *Peer) {
+	peer.mu.Lock()
+	addr := peer.limitAddr
+	peer.limitAddr = ""
+	peer.mu.Unlock()
+
+	if addr != "" {
+		n.Limiter().Release(addr)
+	}
+}
+
 // handleConnection handles a new connection
 func (n *Node) handleConnection(conn net.Conn) {
-	defer conn.Close()
+	addr := conn.RemoteAddr().String()
+	if !n.startWorker() {
+		conn.Close()
+		return
+	}
+	if err := n.Limiter().Acquire(addr); err != nil {
+		logger.Warn("Refusing connection", zap.String("peer", addr), zap.Error(err))
+		conn.Close()
+		n.workers.Done()
+		return
+	}
 
-	peer := NewPeer(uuid.New().String(), conn.RemoteAddr().String(), 0)
+	// The connection is closed by handleMessages when the peer goes away
+	peer := NewPeer(uuid.New().String(), addr, 0)
 	peer.conn = conn
 	peer.Node = n
 	peer.handlers = make(map[MessageType]MessageHandler)
+	peer.limitAddr = addr
 
-	n.mu.Lock()
-	n.Peers[peer.ID] = peer
-	n.mu.Unlock()
+	n.addPeer(peer.ID, peer)
 
-	go peer.handleMessages()
+	go func() {
+		defer n.workers.Done()
+		peer.handleMessages()
+	}()
 	go func() {
 		ticker := time.NewTicker(30 * time.Second)
 		defer ticker.Stop()
@@ -114,51 +184,92 @@ func (n *Node) handleConnection(conn net.Conn) {
 
 // connectToPeer connects to a peer
 func (n *Node) connectToPeer(address string) {
-	conn, err := net.Dial("tcp", address)
+	dial := n.dial
+	if dial == nil {
+		dial = net.Dial
+	}
+
+	if !n.startWorker() {
+		return
+	}
+	if err := n.Limiter().AcquireOutbound(address); err != nil {
+		logger.Error("Failed to connect to peer", zap.String("address", address), zap.Error(err))
+		n.workers.Done()
+		return
+	}
+	conn, err := dial("tcp", address)
 	if err != nil {
+		n.Limiter().Release(address)
 		logger.Error("Failed to connect to peer", zap.String("address", address), zap.Error(err))
+		n.workers.Done()
 		return
 	}
 
 	peer := &Peer{
-		Address:  address,
-		LastSeen: time.Now(),
-		conn:     conn,
-		Node:     n,
-		handlers: make(map[MessageType]MessageHandler),
+		ID:        uuid.New().String(),
+		Address:   address,
+		LastSeen:  time.Now(),
+		conn:      conn,
+		Node:      n,
+		handlers:  make(map[MessageType]MessageHandler),
+		limitAddr: address,
+		outbound:  true,
 	}
-
-	n.mu.Lock()
-	n.Peers[address] = peer
-	n.mu.Unlock()
+	n.addPeer(address, peer)
 
 	// Start handling messages
-	go peer.handleMessages()
+	go func() {
+		defer n.workers.Done()
+		peer.handleMessages()
+	}()
 
 	// Send version message
 	peer.sendVersion()
 }
 
+// EncodePayload encodes a message payload the way peers decode it. Every
+// payload except the version handshake uses this encoding.
+func EncodePayload(payload interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(payload); err != nil {
+		return nil, fmt.Errorf("failed to encode message: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodePayload decodes a payload produced by EncodePayload into v
+func DecodePayload(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
 // sendMessage sends a message to a peer
 func (n *Node) sendMessage(peer *Peer, msgType MessageType, payload interface{}) error {
-	var buf bytes.Buffer
-	encoder := gob.NewEncoder(&buf)
-	if err := encoder.Encode(payload); err != nil {
-		return fmt.Errorf("failed to encode message: %v", err)
+	data, err := EncodePayload(payload)
+	if err != nil {
+		return err
 	}
+	return n.sendEncoded(peer, msgType, data)
+}
+
+// sendEncoded sends a message whose payload is already encoded
+func (n *Node) sendEncoded(peer *Peer, msgType MessageType, data []byte) error {
 	msg := NetworkMessage{
-		Type:    msgType,
-		From:    n.Config.Address,
-		To:      peer.Address,
-		Payload: buf.Bytes(),
+		Type:      msgType,
+		From:      n.Config.Address,
+		To:        peer.Address,
+		Payload:   data,
+		Timestamp: time.Now(),
 	}
-	return gob.NewEncoder(peer.conn).Encode(msg)
+	return peer.writeMessage(msg)
 }
 
 // receiveMessage receives a message from a peer
 func (n *Node) receiveMessage(peer *Peer) (*NetworkMessage, error) {
+	if err := readMagic(peer.reader(), n.magic()); err != nil {
+		return nil, err
+	}
 	var msg NetworkMessage
-	if err := gob.NewDecoder(peer.conn).Decode(&msg); err != nil {
+	if err := gob.NewDecoder(peer.reader()).Decode(&msg); err != nil {
 		return nil, fmt.Errorf("failed to decode message: %v", err)
 	}
 	return &msg, nil
@@ -187,6 +298,10 @@ func (n *Node) handleMessage(peer *Peer, msg *NetworkMessage) error {
 		return n.handleAddr(peer, msg)
 	case MessageTypeGetAddr:
 		return n.handleGetAddr(peer, msg)
+	case MessageTypeVersion:
+		return n.handleVersion(peer, msg)
+	case MessageTypeReject:
+		return n.handleReject(peer, msg)
 	default:
 		return fmt.Errorf("unknown message type: %v", msg.Type)
 	}
@@ -194,18 +309,180 @@ func (n *Node) handleMessage(peer *Peer, msg *NetworkMessage) error {
 
 // Message handlers
 func (n *Node) handleVersion(peer *Peer, msg *NetworkMessage) error {
-	var version int32
-	if err := gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&version); err != nil {
-		return fmt.Errorf("failed to decode version: %v", err)
+	var version VersionPayload
+	if err := json.Unmarshal(msg.Payload, &version); err != nil {
+		return n.rejectPeer(peer, MessageTypeVersion, fmt.Sprintf("malformed version message: %v", err))
+	}
+
+	minVersion := n.Config.MinProtocolVersion
+	if minVersion == 0 {
+		minVersion = DefaultMinProtocolVersion
+	}
+	if version.ProtocolVersion < minVersion {
+		return n.rejectPeer(peer, MessageTypeVersion, fmt.Sprintf(
+			"protocol version %d is below minimum %d (user agent %q)",
+			version.ProtocolVersion, minVersion, version.UserAgent))
+	}
+
+	if len(version.PublicKey) == 0 || len(version.Challenge) == 0 {
+		return n.rejectPeer(peer, MessageTypeVersion, "version message must carry an identity key and challenge")
+	}
+
+	// An authenticated peer sends a fresh version when the chain it serves
+	// changes, which updates what it is relayed
+	if peer.isAuthenticated() {
+		peer.mu.Lock()
+		defer peer.mu.Unlock()
+		if !bytes.Equal(version.PublicKey, peer.PublicKey) {
+			return fmt.Errorf("peer %s changed its identity key", peer.Address)
+		}
+		peer.BlockType = version.BlockType
+		return nil
+	}
+
+	peer.mu.Lock()
+	peer.ProtocolVersion = version.ProtocolVersion
+	peer.UserAgent = version.UserAgent
+	peer.Version = version.UserAgent
+	peer.BlockType = version.BlockType
+	peer.Height = version.Height
+	peer.PublicKey = version.PublicKey
+	peer.peerChallenge = version.Challenge
+	replied := peer.versionSent
+	peer.mu.Unlock()
+	if n.Blockchain != nil {
+		n.Blockchain.SetPeerHeight(peer.Address, version.Height)
+	}
+
+	logger.Info("Peer handshake",
+		zap.String("peer", peer.Address),
+		zap.String("user_agent", version.UserAgent),
+		zap.Int32("protocol_version", version.ProtocolVersion))
+
+	// Inbound peers speak first, so answer with our own version, which
+	// signs their challenge
+	if !replied {
+		if err := peer.sendVersion(); err != nil {
+			return err
+		}
+		return peer.sendMessage(NetworkMessage{
+			Type:      MessageTypeVerAck,
+			From:      n.Config.Address,
+			Timestamp: time.Now(),
+		})
+	}
+
+	// This is the reply to our version, so it must answer our challenge
+	if err := n.authenticatePeer(peer, version.Signature); err != nil {
+		return n.rejectPeer(peer, MessageTypeVersion, fmt.Sprintf("authentication failed: %v", err))
 	}
+	signature, err := n.signChallenge(version.PublicKey, version.Challenge)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(VerAckPayload{Signature: signature})
+	if err != nil {
+		return err
+	}
+	return peer.sendMessage(NetworkMessage{
+		Type:      MessageTypeVerAck,
+		From:      n.Config.Address,
+		Payload:   payload,
+		Timestamp: time.Now(),
+	})
+}
 
-	return n.sendMessage(peer, MessageTypeVerAck, nil)
+// handleReject logs why a peer refused us. A refused block or transaction is
+// only logged; any other rejection ends the connection.
+func (n *Node) handleReject(peer *Peer, msg *NetworkMessage) error {
+	var reject RejectPayload
+	if err := json.Unmarshal(msg.Payload, &reject); err != nil {
+		return fmt.Errorf("peer %s rejected connection", peer.Address)
+	}
+	if len(reject.Hash) > 0 {
+		logger.Warn("Peer rejected data",
+			zap.String("peer", peer.Address),
+			zap.String("type", string(reject.Message)),
+			zap.String("hash", hex.EncodeToString(reject.Hash)),
+			zap.String("code", string(reject.Code)),
+			zap.String("reason", reject.Reason))
+		return nil
+	}
+	return fmt.Errorf("peer %s rejected %s: %s", peer.Address, reject.Message, reject.Reason)
 }
 
-func (n *Node) handleVerAck(peer *Peer, msg *NetworkMessage) error {
+// rejectPeer tells a peer why it is being disconnected and returns that reason
+// as an error so the read loop closes the connection
+func (n *Node) rejectPeer(peer *Peer, message MessageType, reason string) error {
+	payload, err := json.Marshal(RejectPayload{Message: message, Reason: reason})
+	if err == nil {
+		peer.sendMessage(NetworkMessage{
+			Type:      MessageTypeReject,
+			From:      n.Config.Address,
+			Payload:   payload,
+			Timestamp: time.Now(),
+		})
+	}
+	logger.Warn("Rejecting peer", zap.String("peer", peer.Address), zap.String("reason", reason))
+	return fmt.Errorf("rejected peer %s: %s", peer.Address, reason)
+}
+
+// rejectData tells a peer that the block or transaction hash it sent in a
+// message of type message was refused, and why
+func (n *Node) rejectData(peer *Peer, message MessageType, hash []byte, code blockchain.RejectReason, err error) {
+	payload, merr := json.Marshal(RejectPayload{Message: message, Reason: err.Error(), Hash: hash, Code: code})
+	if merr != nil {
+		return
+	}
+	if serr := peer.sendMessage(NetworkMessage{
+		Type:      MessageTypeReject,
+		From:      n.Config.Address,
+		Payload:   payload,
+		Timestamp: time.Now(),
+	}); serr != nil {
+		logger.Debug("Failed to send reject", zap.String("peer", peer.Address), zap.Error(serr))
+	}
+}
+
+// handleHandshake processes version, verack and reject messages, which every
+// read loop must understand regardless of the peer's registered handlers. Any
+// other message from a peer that has not authenticated is dropped.
+func (n *Node) handleHandshake(peer *Peer, msg *NetworkMessage) (bool, error) {
+	switch msg.Type {
+	case MessageTypeVersion:
+		return true, n.handleVersion(peer, msg)
+	case MessageTypeVerAck:
+		return true, n.handleVerAckAuth(peer, msg)
+	case MessageTypeReject:
+		return true, n.handleReject(peer, msg)
+	}
+
+	if !peer.isAuthenticated() {
+		logger.Debug("Dropping message from unauthenticated peer",
+			zap.String("peer", peer.Address),
+			zap.String("type", string(msg.Type)))
+		return true, nil
+	}
+	return false, nil
+}
+
+// removePeer drops a peer from the peer table, whichever key it was stored under
+func (n *Node) removePeer(peer *Peer) {
+	n.peerAuth().Forget(peer.ID)
+	n.releaseConnection(peer)
+
 	n.mu.Lock()
-	n.Peers[peer.Address] = peer
+	for key, p := range n.Peers {
+		if p == peer {
+			delete(n.Peers, key)
+		}
+	}
 	n.mu.Unlock()
+	n.dropPeer(peer)
+}
+
+func (n *Node) handleVerAck(peer *Peer, msg *NetworkMessage) error {
+	n.addPeer(peer.Address, peer)
 
 	// Request blocks
 	return n.sendMessage(peer, MessageTypeGetBlocks, nil)
@@ -213,45 +490,79 @@ func (n *Node) handleVerAck(peer *Peer, msg *NetworkMessage) error {
 
 func (n *Node) handleGetBlocks(peer *Peer, msg *NetworkMessage) error {
 	var blocks []*blockchain.Block
-	if n.Config.BlockType == blockchain.GoldenBlock {
-		for _, block := range n.Blockchain.GoldenBlocks {
-			blocks = append(blocks, &block)
-		}
-	} else {
-		for _, block := range n.Blockchain.SilverBlocks {
-			blocks = append(blocks, &block)
-		}
+	served := n.servedChain()
+	if served != blockchain.SilverBlock {
+		blocks = append(blocks, n.Blockchain.ChainBlocks(blockchain.GoldenBlock)...)
+	}
+	if served != blockchain.GoldenBlock {
+		blocks = append(blocks, n.Blockchain.ChainBlocks(blockchain.SilverBlock)...)
 	}
 
-	return n.sendMessage(peer, MessageTypeBlocks, blocks)
+	return n.serveBlocks(peer, blocks)
 }
 
 func (n *Node) handleBlocks(peer *Peer, msg *NetworkMessage) error {
 	var blocks []*blockchain.Block
-	if err := gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&blocks); err != nil {
+	if err := DecodePayload(msg.Payload, &blocks); err != nil {
 		return fmt.Errorf("failed to decode blocks: %v", err)
 	}
 
 	for _, block := range blocks {
-		if err := n.Blockchain.AddBlock(*block); err != nil {
+		if err := n.Blockchain.AddBlock(*block); errors.Is(err, blockchain.ErrOrphanBlock) {
+			logger.Debug("Holding orphan block until its parent arrives", zap.String("hash", fmt.Sprintf("%x", block.Hash)))
+			if err := n.sendMessage(peer, MessageTypeGetData, []string{invID(block.PrevHash)}); err != nil {
+				return err
+			}
+		} else if err != nil {
+			peer.recordBlock(false)
 			logger.Error("Failed to add block", zap.Error(err))
+		} else {
+			peer.recordBlock(true)
+			n.Blockchain.ConfirmPeerHeight(peer.Address, n.Blockchain.GetCurrentHeight())
 		}
 	}
 
 	return nil
 }
 
-func (n *Node) handleGetData(peer *Peer, msg *NetworkMessage) error {
+// invID returns the ID inv and getdata messages name the block or
+// transaction with hash by
+func invID(hash []byte) string {
+	return hex.EncodeToString(hash)
+}
+
+// decodeInv decodes the item IDs of an inv or getdata message, keeping at
+// most MaxInvPerMessage of them
+func decodeInv(msg *NetworkMessage) ([]string, error) {
 	var inv []string
-	if err := gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&inv); err != nil {
-		return fmt.Errorf("failed to decode inventory: %v", err)
+	if err := DecodePayload(msg.Payload, &inv); err != nil {
+		return nil, fmt.Errorf("failed to decode inventory: %v", err)
+	}
+	if len(inv) > MaxInvPerMessage {
+		logger.Warn("Truncating inventory message", zap.String("type", string(msg.Type)), zap.Int("received", len(inv)), zap.Int("max", MaxInvPerMessage))
+		inv = inv[:MaxInvPerMessage]
+	}
+	return inv, nil
+}
+
+func (n *Node) handleGetData(peer *Peer, msg *NetworkMessage) error {
+	inv, err := decodeInv(msg)
+	if err != nil {
+		return err
 	}
 
-	for _, hash := range inv {
-		if block, err := n.Blockchain.GetBlock([]byte(hash)); err == nil {
+	for _, id := range inv {
+		hash, err := hex.DecodeString(id)
+		if err != nil {
+			continue
+		}
+		if block, err := n.Blockchain.GetBlock(hash); err == nil {
 			return n.sendMessage(peer, MessageTypeBlock, block)
 		}
-		if tx, err := n.Blockchain.GetTransaction([]byte(hash)); err == nil {
+		if tx, err := n.Blockchain.GetTransaction(hash); err == nil {
+			return n.sendMessage(peer, MessageTypeTx, tx)
+		}
+		if tx, err := n.Blockchain.GetPendingTransaction(hash); err == nil {
 			return n.sendMessage(peer, MessageTypeTx, tx)
 		}
 	}
@@ -259,58 +570,350 @@ func (n *Node) handleGetData(peer *Peer, msg *NetworkMessage) error {
 	return nil
 }
 
+// hasInventory reports whether the node already holds the block or
+// transaction with hash, on its chain or pending in its mempool
+func (n *Node) hasInventory(hash []byte) bool {
+	if n.Blockchain.HasBlock(hash) || n.Blockchain.HasTransaction(hash) {
+		return true
+	}
+	_, err := n.Blockchain.GetPendingTransaction(hash)
+	return err == nil
+}
+
 func (n *Node) handleInv(peer *Peer, msg *NetworkMessage) error {
-	var inv []string
-	if err := gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&inv); err != nil {
-		return fmt.Errorf("failed to decode inventory: %v", err)
+	inv, err := decodeInv(msg)
+	if err != nil {
+		return err
+	}
+
+	// Wake anyone waiting for these items to propagate
+	n.notifyInv(inv)
+
+	// Ask only for what the node lacks; a transaction it relayed is
+	// announced straight back and is already pending. Only a missing item
+	// can be a block still to connect, so only those announcements are
+	// timed; one that turns out to be a transaction is forgotten when it
+	// arrives.
+	now := time.Now()
+	var missing []string
+	for _, id := range inv {
+		hash, err := hex.DecodeString(id)
+		if err != nil {
+			continue
+		}
+		if !n.hasInventory(hash) {
+			n.propagation.announce(string(hash), now)
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return n.sendMessage(peer, MessageTypeGetData, missing)
+}
+
+// WaitForInv blocks until a peer announces hash in an inv message or the
+// timeout elapses. Only announcements after the call are seen; use ExpectInv
+// to wait for the reply to a message not yet sent.
+func (n *Node) WaitForInv(hash string, timeout time.Duration) bool {
+	return n.ExpectInv(hash)(timeout)
+}
+
+// ExpectInv registers interest in a peer announcing hash in an inv message
+// and returns a function that blocks until one has or the timeout elapses.
+// Registering before sending what prompts the announcement ensures a fast
+// reply is not missed.
+func (n *Node) ExpectInv(hash string) func(timeout time.Duration) bool {
+	ch := make(chan struct{})
+
+	n.invMu.Lock()
+	if n.invWaiters == nil {
+		n.invWaiters = make(map[string][]chan struct{})
+	}
+	n.invWaiters[hash] = append(n.invWaiters[hash], ch)
+	n.invMu.Unlock()
+
+	return func(timeout time.Duration) bool {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-ch:
+			return true
+		case <-timer.C:
+			n.invMu.Lock()
+			defer n.invMu.Unlock()
+			select {
+			case <-ch:
+				// Announced as the timer fired
+				return true
+			default:
+			}
+			waiters := n.invWaiters[hash]
+			for i, w := range waiters {
+				if w == ch {
+					n.invWaiters[hash] = append(waiters[:i], waiters[i+1:]...)
+					break
+				}
+			}
+			if len(n.invWaiters[hash]) == 0 {
+				delete(n.invWaiters, hash)
+			}
+			return false
+		}
+	}
+}
+
+// notifyInv releases all waiters for the announced hashes
+func (n *Node) notifyInv(hashes []string) {
+	n.invMu.Lock()
+	defer n.invMu.Unlock()
+
+	for _, hash := range hashes {
+		for _, ch := range n.invWaiters[hash] {
+			close(ch)
+		}
+		delete(n.invWaiters, hash)
+	}
+}
+
+// BlockRequestTimeout is how long RequestBlock waits for the requested block
+var BlockRequestTimeout = 30 * time.Second
+
+// RequestBlock asks peer for the block with hash in a getdata message and
+// waits up to BlockRequestTimeout for it. The block is handled like any other
+// the peer sends, so once returned it is on the chain or held as an orphan.
+func (n *Node) RequestBlock(peer *Peer, hash []byte) (*blockchain.Block, error) {
+	key := string(hash)
+	ch := make(chan *blockchain.Block, 1)
+
+	n.blockMu.Lock()
+	if n.blockWaiters == nil {
+		n.blockWaiters = make(map[string][]chan *blockchain.Block)
+	}
+	n.blockWaiters[key] = append(n.blockWaiters[key], ch)
+	n.blockMu.Unlock()
+	defer n.removeBlockWaiter(key, ch)
+
+	if err := n.sendMessage(peer, MessageTypeGetData, []string{invID(hash)}); err != nil {
+		return nil, fmt.Errorf("failed to request block %x: %v", hash, err)
+	}
+
+	timer := time.NewTimer(BlockRequestTimeout)
+	defer timer.Stop()
+
+	select {
+	case block := <-ch:
+		return block, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("timed out waiting for block %x from %s", hash, peer.Address)
 	}
+}
+
+// removeBlockWaiter stops delivering the block with key to ch
+func (n *Node) removeBlockWaiter(key string, ch chan *blockchain.Block) {
+	n.blockMu.Lock()
+	defer n.blockMu.Unlock()
+
+	waiters := n.blockWaiters[key]
+	for i, w := range waiters {
+		if w == ch {
+			n.blockWaiters[key] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(n.blockWaiters[key]) == 0 {
+		delete(n.blockWaiters, key)
+	}
+}
+
+// notifyBlock hands block to the RequestBlock calls awaiting it
+func (n *Node) notifyBlock(block *blockchain.Block) {
+	n.blockMu.Lock()
+	defer n.blockMu.Unlock()
 
-	return n.sendMessage(peer, MessageTypeGetData, inv)
+	key := string(block.Hash)
+	for _, ch := range n.blockWaiters[key] {
+		ch <- block
+	}
+	delete(n.blockWaiters, key)
 }
 
 func (n *Node) handleTx(peer *Peer, msg *NetworkMessage) error {
+	tx, err := decodeTransaction(msg.Payload)
+	if err != nil {
+		return err
+	}
+	n.propagation.forget(string(tx.ID))
+	err = n.relayTransaction(peer, tx)
+
+	// Tell the sender why its transaction was refused. Duplicates are
+	// expected when a transaction is relayed back, so they pass silently.
+	var rejected *blockchain.RejectError
+	if errors.As(err, &rejected) && rejected.Reason != blockchain.RejectDuplicate {
+		n.rejectData(peer, MessageTypeTx, tx.ID, rejected.Reason, rejected)
+	}
+	return err
+}
+
+// decodeTransaction decodes the payload of a transaction message
+func decodeTransaction(payload []byte) (*blockchain.Transaction, error) {
 	var tx *blockchain.Transaction
-	if err := gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&tx); err != nil {
-		return fmt.Errorf("failed to decode transaction: %v", err)
+	if err := DecodePayload(payload, &tx); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %v", err)
 	}
+	if tx == nil {
+		return nil, errors.New("empty transaction message")
+	}
+	return tx, nil
+}
 
+// relayTransaction adds tx to the pending pool and passes it on to every peer
+// except from, the peer it came from if any. It fails if the transaction is
+// rejected or reaches no peer.
+func (n *Node) relayTransaction(from *Peer, tx *blockchain.Transaction) error {
 	if err := n.Blockchain.AddTransaction(*tx); err != nil {
-		return fmt.Errorf("failed to add transaction: %v", err)
+		return fmt.Errorf("failed to add transaction: %w", err)
 	}
 
-	// Broadcast transaction to other peers
-	n.broadcastMessage(MessageTypeTx, tx)
-	return nil
+	// Announce the transaction so the sender can confirm propagation
+	if from != nil {
+		if err := n.sendMessage(from, MessageTypeInv, []string{invID(tx.ID)}); err != nil {
+			logger.Error("Failed to announce transaction", zap.String("peer", from.Address), zap.Error(err))
+		}
+	}
+
+	// Relay the transaction to other peers, up to the fan-out
+	data, err := EncodePayload(tx)
+	if err != nil {
+		return err
+	}
+	return n.sendToPeers(n.txRelayPeers(from, tx.Chain()), MessageTypeTx, data)
+}
+
+// handleRelay passes relayed transactions, blocks, announcements and data
+// requests to the node, whichever read loop received them. Errors are not
+// fatal to the connection: a transaction relayed back to its sender is
+// rejected as a duplicate.
+func (n *Node) handleRelay(peer *Peer, msg *NetworkMessage) bool {
+	var err error
+	switch msg.Type {
+	case MessageTypeTx:
+		err = n.handleTx(peer, msg)
+	case MessageTypeBlock:
+		err = n.handleBlock(peer, msg)
+	case MessageTypeInv:
+		err = n.handleInv(peer, msg)
+	case MessageTypeGetData:
+		err = n.handleGetData(peer, msg)
+	default:
+		return false
+	}
+	if err != nil {
+		logger.Debug("Ignoring relayed message", zap.String("peer", peer.Address), zap.String("type", string(msg.Type)), zap.Error(err))
+	}
+	return true
 }
 
 func (n *Node) handleBlock(peer *Peer, msg *NetworkMessage) error {
 	var block *blockchain.Block
-	if err := gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&block); err != nil {
+	if err := DecodePayload(msg.Payload, &block); err != nil {
 		return fmt.Errorf("failed to decode block: %v", err)
 	}
-
-	if err := n.Blockchain.AddBlock(*block); err != nil {
+	n.propagation.announce(string(block.Hash), time.Now())
+
+	orphans, err := n.Blockchain.ProcessBlock(*block)
+	if errors.Is(err, blockchain.ErrOrphanBlock) {
+		// Relay it once it connects, not while its parent is missing, and
+		// ask the sender for the parent so it can
+		logger.Debug("Holding orphan block until its parent arrives", zap.String("hash", fmt.Sprintf("%x", block.Hash)))
+		n.notifyBlock(block)
+		return n.sendMessage(peer, MessageTypeGetData, []string{invID(block.PrevHash)})
+	} else if err != nil {
+		peer.recordBlock(false)
+		n.rejectData(peer, MessageTypeBlock, block.Hash, blockchain.RejectInvalid, err)
 		return fmt.Errorf("failed to add block: %v", err)
 	}
-
-	// Broadcast block to other peers
-	n.broadcastMessage(MessageTypeBlock, block)
+	peer.recordBlock(true)
+	n.Blockchain.ConfirmPeerHeight(peer.Address, n.Blockchain.GetCurrentHeight())
+	n.recordPropagation(block, time.Now())
+	n.notifyBlock(block)
+
+	// Broadcast block to other peers, best first, followed by the orphans
+	// it connected; peers without the parent would hold those as orphans too
+	if err := n.relayBlock(block, peer); err != nil {
+		return err
+	}
+	for _, orphan := range orphans {
+		if err := n.relayBlock(orphan, nil); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (n *Node) handleAddr(peer *Peer, msg *NetworkMessage) error {
 	var addrs []string
-	if err := gob.NewDecoder(bytes.NewReader(msg.Payload)).Decode(&addrs); err != nil {
+	if err := DecodePayload(msg.Payload, &addrs); err != nil {
 		return fmt.Errorf("failed to decode addresses: %v", err)
 	}
 
-	for _, addr := range addrs {
+	if !peer.allowAddr() {
+		logger.Warn("Ignoring addr message from peer over rate limit", zap.String("peer", peer.Address))
+		return nil
+	}
+
+	// Dial across network groups first; the limiter refuses more outbound
+	// connections into one group than MaxOutboundPerNetGroup
+	for _, addr := range spreadAddrsByNetGroup(n.filterAddrs(addrs)) {
 		go n.connectToPeer(addr)
 	}
 
 	return nil
 }
 
+// allowAddr reports whether the peer is within its addr message rate limit
+func (p *Peer) allowAddr() bool {
+	p.mu.Lock()
+	if p.addrLimiter == nil {
+		p.addrLimiter = NewTokenBucket(AddrMessageRate, AddrMessageBurst)
+	}
+	limiter := p.addrLimiter
+	p.mu.Unlock()
+	return limiter.Allow()
+}
+
+// filterAddrs validates and dedupes advertised addresses, dropping ourselves
+// and peers we already have, and returns at most MaxAddrsPerMessage of them
+func (n *Node) filterAddrs(addrs []string) []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var valid []string
+	for _, addr := range addrs {
+		if len(valid) >= MaxAddrsPerMessage {
+			logger.Warn("Truncating addr message", zap.Int("received", len(addrs)), zap.Int("max", MaxAddrsPerMessage))
+			break
+		}
+
+		host, _, err := utils.ParseAddress(addr)
+		if err != nil || host == "" {
+			continue
+		}
+		if seen[addr] || addr == n.Config.Address {
+			continue
+		}
+		if _, exists := n.Peers[addr]; exists {
+			continue
+		}
+		seen[addr] = true
+		valid = append(valid, addr)
+	}
+	return valid
+}
+
 func (n *Node) handleGetAddr(peer *Peer, msg *NetworkMessage) error {
 	var addrs []string
 	n.mu.RLock()
@@ -331,16 +934,48 @@ func (n *Node) handlePong(peer *Peer, msg *NetworkMessage) error {
 	return nil
 }
 
-// broadcastMessage broadcasts a message to all peers
-func (n *Node) broadcastMessage(msgType MessageType, payload interface{}) {
-	n.mu.RLock()
-	defer n.mu.RUnlock()
+// broadcastMessage broadcasts a message to all peers. It only fails if the
+// message could not be delivered to any peer.
+func (n *Node) broadcastMessage(msgType MessageType, payload interface{}) error {
+	data, err := EncodePayload(payload)
+	if err != nil {
+		return err
+	}
+	return n.broadcastEncoded(msgType, data, nil)
+}
 
+// broadcastEncoded sends an already encoded payload to all peers but except
+func (n *Node) broadcastEncoded(msgType MessageType, data []byte, except *Peer) error {
+	n.mu.RLock()
+	peers := make([]*Peer, 0, len(n.Peers))
 	for _, peer := range n.Peers {
-		if err := n.sendMessage(peer, msgType, payload); err != nil {
-			logger.Error("Failed to broadcast message", zap.Error(err))
+		if peer != except {
+			peers = append(peers, peer)
+		}
+	}
+	n.mu.RUnlock()
+
+	return n.sendToPeers(peers, msgType, data)
+}
+
+// sendToPeers sends an encoded payload to each of peers. It only fails if
+// the message could not be delivered to any of them.
+func (n *Node) sendToPeers(peers []*Peer, msgType MessageType, data []byte) error {
+	var lastErr error
+	sent := 0
+	for _, peer := range peers {
+		if err := n.sendEncoded(peer, msgType, data); err != nil {
+			logger.Error("Failed to send message to peer", zap.String("peer", peer.Address), zap.Error(err))
+			lastErr = fmt.Errorf("failed to send message to peer %s: %v", peer.Address, err)
+			continue
 		}
+		sent++
+	}
+
+	if sent == 0 && lastErr != nil {
+		return lastErr
 	}
+	return nil
 }
 
 // StartMining starts mining
@@ -351,39 +986,100 @@ func (n *Node) StartMining(coinType blockchain.CoinType) error {
 	if n.isMining {
 		return fmt.Errorf("already mining")
 	}
+	if n.Config.MiningAddress != "" {
+		if _, _, err := crypto.DecodeAddress(n.Config.MiningAddress); err != nil {
+			return fmt.Errorf("invalid mining address: %v", err)
+		}
+	}
 
 	n.isMining = true
-	n.Config.BlockType = blockchain.GetBlockType(coinType)
+	n.miningChain = blockchain.GetBlockType(coinType)
+	n.miningStop = make(chan struct{})
+	n.miningDone = make(chan struct{})
 
 	// Start mining in a goroutine
-	go n.mineBlocks()
+	go n.mineBlocks(n.miningStop, n.miningDone)
 
 	return nil
 }
 
-// StopMining stops mining
+// StopMining stops mining and waits for the mining loop to return, so no
+// block is added or relayed once it has. The block being mined is
+// abandoned.
 func (n *Node) StopMining() {
 	n.mu.Lock()
-	defer n.mu.Unlock()
-
 	n.isMining = false
-	n.Config.BlockType = ""
+	n.miningChain = ""
+	stop, done := n.miningStop, n.miningDone
+	n.miningStop, n.miningDone = nil, nil
+	n.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// IsMining reports whether the node is currently mining
+func (n *Node) IsMining() bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.isMining
+}
+
+// MiningChain returns the chain the node is mining, or empty when it is not
+func (n *Node) MiningChain() blockchain.BlockType {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.miningChain
+}
+
+// servedChain returns the chain the node serves to peers, empty for both
+func (n *Node) servedChain() blockchain.BlockType {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.Config.BlockType
+}
+
+// SetBlockType changes the chain the node serves to peers, empty for both,
+// and sends every authenticated peer a fresh version advertising it
+func (n *Node) SetBlockType(blockType blockchain.BlockType) {
+	n.mu.Lock()
+	changed := n.Config.BlockType != blockType
+	n.Config.BlockType = blockType
+	n.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	for _, peer := range n.GetPeers() {
+		if !peer.isAuthenticated() {
+			continue
+		}
+		if err := peer.sendVersion(); err != nil {
+			logger.Warn("Failed to advertise served chain", zap.String("peer", peer.Address), zap.Error(err))
+		}
+	}
 }
 
-// mineBlocks continuously mines new blocks
-func (n *Node) mineBlocks() {
+// mineBlocks continuously mines new blocks until stop is closed, then
+// closes done
+func (n *Node) mineBlocks(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
 	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
 		n.mu.RLock()
 		if !n.isMining {
 			n.mu.RUnlock()
 			return
 		}
-		blockType := n.Config.BlockType
+		blockType := n.miningChain
 		n.mu.RUnlock()
 
-		// Get pending transactions
-		pendingTxs := n.Blockchain.PendingTxs
-
 		// Determine coin type based on block type
 		var coinType blockchain.CoinType
 		if blockType == blockchain.GoldenBlock {
@@ -392,8 +1088,31 @@ func (n *Node) mineBlocks() {
 			coinType = blockchain.Senum
 		}
 
-		// Mine the block
-		block, err := n.Blockchain.MineBlock(pendingTxs, blockType, coinType)
+		// The template always leads with the coinbase, so a block mined on
+		// an empty mempool still holds the one transaction blocks need
+		address, hash, err := n.miningPayee()
+		if err != nil {
+			logger.Error("Failed to pick the block reward address", zap.Error(err))
+			return
+		}
+		coinbase := blockchain.NewCoinbaseTransaction(address, hash, blockchain.BlockSubsidy(coinType), coinType, blockType)
+		template, err := n.Blockchain.NewBlockTemplate(blockType, coinType, *coinbase)
+		if errors.Is(err, blockchain.ErrTimestampAhead) {
+			// Blocks came faster than the clock; let it catch up
+			select {
+			case <-time.After(time.Second):
+			case <-stop:
+			}
+			continue
+		}
+		if err != nil {
+			logger.Error("Failed to build block template", zap.Error(err))
+			continue
+		}
+		block, err := n.Blockchain.MineTemplateUntil(template, stop)
+		if errors.Is(err, blockchain.ErrTemplateStale) {
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to mine block", zap.Error(err))
 			continue
@@ -405,9 +1124,40 @@ func (n *Node) mineBlocks() {
 			continue
 		}
 
-		// Broadcast the new block to peers
-		n.broadcastMessage(MessageTypeBlock, &block)
+		// Broadcast the new block to peers. The relay serves peers rather
+		// than the miner, so a peer slow to read holds up neither the next
+		// block nor StopMining; Shutdown waits for it with the handlers.
+		if !n.startWorker() {
+			continue
+		}
+		go func(block blockchain.Block) {
+			defer n.workers.Done()
+			if err := n.relayBlock(&block, nil); err != nil {
+				logger.Error("Failed to relay mined block", zap.Error(err))
+			}
+		}(block)
+	}
+}
+
+// miningPayee returns the address block rewards are paid to and the hash
+// their coinbase output is locked to: Config.MiningAddress, or the node's
+// identity key
+func (n *Node) miningPayee() (string, []byte, error) {
+	n.mu.RLock()
+	address := n.Config.MiningAddress
+	n.mu.RUnlock()
+	if address != "" {
+		_, hash, err := crypto.DecodeAddress(address)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid mining address: %v", err)
+		}
+		return address, hash, nil
+	}
+	if n.identity == nil {
+		return "", nil, nil
 	}
+	hash := crypto.HashPublicKey(&n.identity.PublicKey)
+	return hex.EncodeToString(hash), hash, nil
 }
 
 // GetPeers returns the list of connected peers
@@ -455,7 +1205,12 @@ func (p *Peer) registerHandlers() {
 
 // handleMessages handles incoming messages
 func (p *Peer) handleMessages() {
-	defer p.conn.Close()
+	defer func() {
+		p.conn.Close()
+		if p.Node != nil {
+			p.Node.removePeer(p)
+		}
+	}()
 	for {
 		message, err := p.receiveMessage()
 		if err != nil {
@@ -467,6 +1222,20 @@ func (p *Peer) handleMessages() {
 			return
 		}
 
+		if p.Node != nil {
+			if handled, err := p.Node.handleHandshake(p, message); handled {
+				if err != nil {
+					logger.Error("Handshake failed", zap.Error(err))
+					return
+				}
+				continue
+			}
+		}
+
+		if p.Node != nil && p.Node.handleRelay(p, message) {
+			continue
+		}
+
 		handler, ok := p.handlers[message.Type]
 		if !ok {
 			logger.Error("Unknown message type", zap.String("type", string(message.Type)))
@@ -482,13 +1251,52 @@ func (p *Peer) handleMessages() {
 
 // sendVersion sends a version message
 func (p *Peer) sendVersion() error {
-	payload, err := json.Marshal(p.Node.Config)
+	config := p.Node.Config
+	userAgent := config.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	challenge, err := p.Node.peerAuth().Challenge(p.ID)
+	if err != nil {
+		return err
+	}
+	p.mu.RLock()
+	peerKey, peerChallenge := p.PublicKey, p.peerChallenge
+	p.mu.RUnlock()
+	var signature []byte
+	if peerChallenge != nil {
+		if signature, err = p.Node.signChallenge(peerKey, peerChallenge); err != nil {
+			return err
+		}
+	}
+
+	var height int64
+	if p.Node.Blockchain != nil {
+		height = p.Node.Blockchain.GetCurrentHeight()
+	}
+
+	payload, err := json.Marshal(VersionPayload{
+		ProtocolVersion: ProtocolVersion,
+		UserAgent:       userAgent,
+		Address:         config.Address,
+		BlockType:       p.Node.servedChain(),
+		Height:          height,
+		PublicKey:       p.Node.PublicKey(),
+		Challenge:       challenge,
+		Signature:       signature,
+	})
 	if err != nil {
 		return err
 	}
 
+	p.mu.Lock()
+	p.versionSent = true
+	p.mu.Unlock()
+
 	msg := NetworkMessage{
 		Type:      MessageTypeVersion,
+		From:      config.Address,
 		Payload:   payload,
 		Timestamp: time.Now(),
 	}
@@ -497,8 +1305,11 @@ func (p *Peer) sendVersion() error {
 
 // receiveMessage receives a message from the peer
 func (p *Peer) receiveMessage() (*NetworkMessage, error) {
+	if err := readMagic(p.reader(), p.Node.magic()); err != nil {
+		return nil, err
+	}
 	var msg NetworkMessage
-	if err := gob.NewDecoder(p.conn).Decode(&msg); err != nil {
+	if err := gob.NewDecoder(p.reader()).Decode(&msg); err != nil {
 		return nil, fmt.Errorf("failed to decode message: %v", err)
 	}
 	return &msg, nil
@@ -506,7 +1317,27 @@ func (p *Peer) receiveMessage() (*NetworkMessage, error) {
 
 // sendMessage sends a message to the peer
 func (p *Peer) sendMessage(msg NetworkMessage) error {
-	return gob.NewEncoder(p.conn).Encode(msg)
+	return p.writeMessage(msg)
+}
+
+// writeMessage encodes msg and writes it to the peer in a single write. A gob
+// encoder writes a message in several pieces, so messages sent to one peer
+// from different goroutines would otherwise interleave and corrupt the stream.
+func (p *Peer) writeMessage(msg NetworkMessage) error {
+	if p.conn == nil {
+		return fmt.Errorf("peer %s has no connection", p.Address)
+	}
+	magic := p.Node.magic()
+	var buf bytes.Buffer
+	buf.Write(magic[:])
+	if err := gob.NewEncoder(&buf).Encode(msg); err != nil {
+		return fmt.Errorf("failed to encode message: %v", err)
+	}
+
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, err := p.writer().Write(buf.Bytes())
+	return err
 }
 
 func handlePing(p *Peer, payload []byte) error      { return nil }
@@ -521,41 +1352,123 @@ func handleVerAck(p *Peer, payload []byte) error    { return nil }
 
 // ConnectToPeer connects to a peer at the given address
 func (n *Node) ConnectToPeer(address string) error {
-	conn, err := net.Dial("tcp", address)
+	dial := n.dial
+	if dial == nil {
+		dial = net.Dial
+	}
+
+	if !n.startWorker() {
+		return fmt.Errorf("failed to connect to peer: %w", ErrShuttingDown)
+	}
+	if err := n.Limiter().AcquireOutbound(address); err != nil {
+		n.workers.Done()
+		return fmt.Errorf("failed to connect to peer: %w", err)
+	}
+	conn, err := dial("tcp", address)
 	if err != nil {
+		n.Limiter().Release(address)
+		n.workers.Done()
 		return fmt.Errorf("failed to connect to peer: %v", err)
 	}
 
 	peer := &Peer{
-		Address:  address,
-		LastSeen: time.Now(),
-		conn:     conn,
-		Node:     n,
-		handlers: make(map[MessageType]MessageHandler),
+		ID:        uuid.New().String(),
+		Address:   address,
+		LastSeen:  time.Now(),
+		conn:      conn,
+		Node:      n,
+		handlers:  make(map[MessageType]MessageHandler),
+		limitAddr: address,
+		outbound:  true,
 	}
-
-	n.mu.Lock()
-	n.Peers[address] = peer
-	n.mu.Unlock()
+	n.addPeer(address, peer)
 
 	// Start handling messages from this peer
-	go n.handlePeer(peer)
+	go func() {
+		defer n.workers.Done()
+		n.handlePeer(peer)
+	}()
 
 	// Send version message
 	return peer.sendVersion()
 }
 
-// BroadcastMessage broadcasts a message to all connected peers
-func (n *Node) BroadcastMessage(msg NetworkMessage) error {
-	n.mu.RLock()
-	defer n.mu.RUnlock()
+// ConnectToPeerWithRetry connects to a peer, retrying failed attempts with exponential backoff
+func (n *Node) ConnectToPeerWithRetry(address string, attempts int, baseDelay time.Duration) error {
+	if attempts <= 0 {
+		attempts = DefaultConnectRetries
+	}
+	if baseDelay <= 0 {
+		baseDelay = DefaultConnectBaseDelay
+	}
 
-	for _, peer := range n.Peers {
-		if err := n.sendMessage(peer, msg.Type, msg.Payload); err != nil {
-			return fmt.Errorf("failed to send message to peer %s: %v", peer.Address, err)
+	var err error
+	delay := baseDelay
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = n.ConnectToPeer(address); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		logger.Warn("Failed to connect to peer, retrying",
+			zap.String("address", address),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+
+		select {
+		case <-time.After(delay):
+		case <-n.quit:
+			return fmt.Errorf("node stopped while connecting to %s", address)
+		}
+
+		delay *= 2
+		if delay > MaxConnectDelay {
+			delay = MaxConnectDelay
 		}
 	}
-	return nil
+
+	return fmt.Errorf("failed to connect to peer %s after %d attempts: %v", address, attempts, err)
+}
+
+// ConnectToBootstrapPeers keeps trying to connect to every configured bootstrap
+// peer in the background until it succeeds or the node is stopped
+func (n *Node) ConnectToBootstrapPeers() {
+	for _, address := range n.Config.BootstrapPeers {
+		go func(address string) {
+			for {
+				err := n.ConnectToPeerWithRetry(address, n.Config.ConnectRetries, n.Config.ConnectBaseDelay)
+				if err == nil {
+					logger.Info("Connected to bootstrap peer", zap.String("address", address))
+					return
+				}
+				logger.Error("Failed to connect to bootstrap peer", zap.String("address", address), zap.Error(err))
+
+				select {
+				case <-time.After(MaxConnectDelay):
+				case <-n.quit:
+					return
+				}
+			}
+		}(address)
+	}
+}
+
+// BroadcastMessage broadcasts a message to all connected peers. The payload
+// must already be encoded with EncodePayload. Transactions take the same path
+// as those relayed by peers, so one the node rejects is not sent.
+// It only fails if the message could not be delivered to any peer.
+func (n *Node) BroadcastMessage(msg NetworkMessage) error {
+	if msg.Type == MessageTypeTx {
+		tx, err := decodeTransaction(msg.Payload)
+		if err != nil {
+			return err
+		}
+		return n.relayTransaction(nil, tx)
+	}
+	return n.broadcastEncoded(msg.Type, msg.Payload, nil)
 }
 
 // handlePeer handles messages from a peer
@@ -564,9 +1477,12 @@ func (n *Node) handlePeer(peer *Peer) {
 		if peer.conn != nil {
 			peer.conn.Close()
 		}
+		n.peerAuth().Forget(peer.ID)
+		n.releaseConnection(peer)
 		n.mu.Lock()
 		delete(n.Peers, peer.Address)
 		n.mu.Unlock()
+		n.dropPeer(peer)
 	}()
 
 	for {
@@ -576,6 +1492,18 @@ func (n *Node) handlePeer(peer *Peer) {
 			return
 		}
 
+		if handled, err := n.handleHandshake(peer, msg); handled {
+			if err != nil {
+				logger.Error("Handshake failed", zap.Error(err))
+				return
+			}
+			continue
+		}
+
+		if n.handleRelay(peer, msg) {
+			continue
+		}
+
 		if handler, ok := peer.handlers[msg.Type]; ok {
 			if err := handler(peer, msg.Payload); err != nil {
 				logger.Error("Failed to handle message", zap.Error(err))
@@ -594,6 +1522,14 @@ func (p *Peer) sendPing() error {
 	return p.sendMessage(msg)
 }
 
+// PublicKey returns the node's identity public key
+func (n *Node) PublicKey() []byte {
+	if n.identity == nil {
+		return nil
+	}
+	return crypto.PublicKeyToBytes(&n.identity.PublicKey)
+}
+
 // GetAddress returns the node's address
 func (n *Node) GetAddress() string {
 	return n.Config.Address
@@ -614,10 +1550,9 @@ func (n *Node) GetPeerAddresses() []string {
 // DisconnectPeer disconnects from a peer
 func (n *Node) DisconnectPeer(address string) error {
 	n.mu.Lock()
-	defer n.mu.Unlock()
-
 	peer, exists := n.Peers[address]
 	if !exists {
+		n.mu.Unlock()
 		return fmt.Errorf("peer %s not found", address)
 	}
 
@@ -625,5 +1560,7 @@ func (n *Node) DisconnectPeer(address string) error {
 		peer.conn.Close()
 	}
 	delete(n.Peers, address)
+	n.mu.Unlock()
+	n.dropPeer(peer)
 	return nil
 }
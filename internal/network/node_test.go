@@ -1,12 +1,22 @@
 package network
 
 import (
+	"os"
 	"testing"
 	"time"
 
 	"byc/internal/blockchain"
+	"byc/internal/logger"
 )
 
+// TestMain initializes the logger the node logs through
+func TestMain(m *testing.M) {
+	if err := logger.Init(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
 func getPeerAddresses(n *Node) []string {
 	peers := make([]string, 0, len(n.Peers))
 	for addr := range n.Peers {
@@ -38,6 +48,17 @@ func TestNewNode(t *testing.T) {
 	if node.Peers == nil {
 		t.Error("Peers map not initialized")
 	}
+
+	// A node given a chain runs on it rather than a fresh one
+	bc := blockchain.NewBlockchain()
+	shared, err := NewNode(&Config{Address: "localhost:8000", Blockchain: bc})
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	defer shared.Close()
+	if shared.Blockchain != bc {
+		t.Error("Node did not use the blockchain it was given")
+	}
 }
 
 func TestNodeStartStop(t *testing.T) {
@@ -87,7 +108,7 @@ func TestNodeConnect(t *testing.T) {
 	defer node2.Stop()
 
 	// Connect node2 to node1
-	err = node2.ConnectToPeer("localhost:8002")
+	err = node2.ConnectToPeer(node1.Config.Address)
 	if err != nil {
 		t.Fatalf("Failed to connect nodes: %v", err)
 	}
@@ -95,11 +116,11 @@ func TestNodeConnect(t *testing.T) {
 	// Wait for connection to be established
 	time.Sleep(100 * time.Millisecond)
 
-	if len(node2.Peers) != 1 {
+	if len(node2.GetPeers()) != 1 {
 		t.Error("Node2 should have one peer")
 	}
 
-	if len(node1.Peers) != 1 {
+	if len(node1.GetPeers()) != 1 {
 		t.Error("Node1 should have one peer")
 	}
 }
@@ -130,7 +151,7 @@ func TestNodeBroadcast(t *testing.T) {
 	defer node2.Stop()
 
 	// Connect node2 to node1
-	err = node2.ConnectToPeer("localhost:8004")
+	err = node2.ConnectToPeer(node1.Config.Address)
 	if err != nil {
 		t.Fatalf("Failed to connect nodes: %v", err)
 	}
@@ -139,12 +160,9 @@ func TestNodeBroadcast(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 
 	// Broadcast a message
-	msg := &Message{
-		Type:    PingMsg,
-		Payload: []byte("test"),
-	}
+	msg := NewNetworkMessage(MessageTypePing, node1.GetAddress(), "", []byte("test"))
 
-	err = node1.BroadcastMessage(msg)
+	err = node1.BroadcastMessage(*msg)
 	if err != nil {
 		t.Fatalf("Failed to broadcast message: %v", err)
 	}
@@ -179,22 +197,29 @@ func TestPeerDisconnect(t *testing.T) {
 	defer node2.Stop()
 
 	// Connect node2 to node1
-	err = node2.ConnectToPeer("localhost:8006")
+	err = node2.ConnectToPeer(node1.Config.Address)
 	if err != nil {
 		t.Fatalf("Failed to connect nodes: %v", err)
 	}
 
 	// Wait for connection to be established
-	time.Sleep(100 * time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(node1.GetPeers()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Node1 never saw node2 connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
 
 	// Disconnect node2
 	node2.Stop()
 
 	// Wait for disconnect to be detected
-	time.Sleep(100 * time.Millisecond)
-
-	if len(node1.Peers) != 0 {
-		t.Error("Node1 should have no peers after disconnect")
+	for len(node1.GetPeers()) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Node1 should have no peers after disconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
 }
 
@@ -246,7 +271,10 @@ func TestMultiplePeerConnections(t *testing.T) {
 	}
 	time.Sleep(100 * time.Millisecond)
 
-	for _, addr := range peerAddrs {
+	for _, p := range peers {
+		defer p.Stop()
+		// NewNode moves to a free port when the one asked for is taken
+		addr := p.Config.Address
 		err := node.ConnectToPeer(addr)
 		if err != nil {
 			t.Errorf("Failed to connect to peer %s: %v", addr, err)
@@ -254,17 +282,17 @@ func TestMultiplePeerConnections(t *testing.T) {
 	}
 	time.Sleep(100 * time.Millisecond)
 
-	if len(node.Peers) != len(peerAddrs) {
-		t.Errorf("Expected %d peers, got %d", len(peerAddrs), len(node.Peers))
+	if len(node.GetPeers()) != len(peerAddrs) {
+		t.Errorf("Expected %d peers, got %d", len(peerAddrs), len(node.GetPeers()))
 	}
 
 	// Clean up
-	for _, peer := range node.Peers {
-		peer.Conn.Close()
+	for _, peer := range node.GetPeers() {
+		peer.conn.Close()
 	}
 	for _, p := range peers {
-		for _, peer := range p.Peers {
-			peer.Conn.Close()
+		for _, peer := range p.GetPeers() {
+			peer.conn.Close()
 		}
 	}
 }
@@ -0,0 +1,86 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"byc/internal/logger"
+	"byc/internal/security"
+
+	"go.uber.org/zap"
+)
+
+// The version exchange doubles as a challenge-response proving each side holds
+// the identity key it advertises. Each signature covers the challenge and both
+// sides' identity keys, so it only verifies for the peer that issued it:
+//
+//  1. the outbound side sends its version with its key and a challenge
+//  2. the inbound side replies with its own key, a challenge, and a signature
+//     over the first challenge, followed by a verack
+//  3. the outbound side checks that signature and sends a verack signing the
+//     inbound side's challenge, which the inbound side checks in turn
+//
+// Until a peer has answered our challenge only handshake messages are read.
+
+// peerAuth returns the node's challenge tracker
+func (n *Node) peerAuth() *security.PeerAuth {
+	n.authOnce.Do(func() {
+		if n.auth == nil {
+			n.auth = security.NewPeerAuth()
+		}
+	})
+	return n.auth
+}
+
+// signChallenge answers the challenge of the peer advertising peerKey with
+// the node identity
+func (n *Node) signChallenge(peerKey, nonce []byte) ([]byte, error) {
+	if n.identity == nil {
+		return nil, errors.New("node has no identity key")
+	}
+	return security.SignChallenge(n.identity, peerKey, nonce)
+}
+
+// authenticatePeer checks a peer's answer to our challenge against the key it
+// advertised and, if it matches, starts accepting its messages
+func (n *Node) authenticatePeer(peer *Peer, signature []byte) error {
+	peer.mu.RLock()
+	publicKey := peer.PublicKey
+	peer.mu.RUnlock()
+
+	if err := n.peerAuth().Verify(peer.ID, publicKey, n.PublicKey(), signature); err != nil {
+		return err
+	}
+
+	peer.mu.Lock()
+	peer.authenticated = true
+	peer.mu.Unlock()
+	logger.Debug("Peer authenticated", zap.String("peer", peer.Address))
+	return nil
+}
+
+// handleVerAckAuth checks the challenge answer carried by an outbound peer's
+// verack. The verack that follows an inbound peer's version carries nothing
+// and is ignored once that peer is authenticated.
+func (n *Node) handleVerAckAuth(peer *Peer, msg *NetworkMessage) error {
+	if peer.isAuthenticated() {
+		return nil
+	}
+
+	var verack VerAckPayload
+	if err := json.Unmarshal(msg.Payload, &verack); err != nil {
+		return n.rejectPeer(peer, MessageTypeVerAck, fmt.Sprintf("malformed verack message: %v", err))
+	}
+	if err := n.authenticatePeer(peer, verack.Signature); err != nil {
+		return n.rejectPeer(peer, MessageTypeVerAck, fmt.Sprintf("authentication failed: %v", err))
+	}
+	return nil
+}
+
+// isAuthenticated reports whether the peer has proved it holds its advertised key
+func (p *Peer) isAuthenticated() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.authenticated
+}
@@ -0,0 +1,185 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"byc/internal/crypto"
+	"byc/internal/security"
+)
+
+// authTestClient speaks the handshake to a node over a raw connection
+type authTestClient struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	challenge []byte
+}
+
+// dialAuthTestClient sends a version for a new identity key and returns the
+// client, the key, and the node's version reply
+func dialAuthTestClient(t *testing.T, node *Node) (*authTestClient, *ecdsa.PrivateKey, VersionPayload) {
+	key, err := security.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+	client := &authTestClient{challenge: []byte("client challenge")}
+	client.conn = dialVersionWithKey(t, node, ProtocolVersion, crypto.PublicKeyToBytes(&key.PublicKey), client.challenge)
+	client.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	// A shared buffered reader lets each message get its own decoder, as the
+	// node uses a new encoder per message
+	client.reader = bufio.NewReader(client.conn)
+
+	msg := client.receive(t)
+	if msg.Type != MessageTypeVersion {
+		t.Fatalf("Expected %s reply, got %s", MessageTypeVersion, msg.Type)
+	}
+	var version VersionPayload
+	if err := json.Unmarshal(msg.Payload, &version); err != nil {
+		t.Fatalf("Failed to decode version: %v", err)
+	}
+	if err := security.VerifyChallenge(version.PublicKey, crypto.PublicKeyToBytes(&key.PublicKey), client.challenge, version.Signature); err != nil {
+		t.Fatalf("Node did not answer the client challenge: %v", err)
+	}
+	if !bytes.Equal(version.PublicKey, node.PublicKey()) {
+		t.Fatal("Node advertised a key other than its identity")
+	}
+	if msg := client.receive(t); msg.Type != MessageTypeVerAck {
+		t.Fatalf("Expected %s, got %s", MessageTypeVerAck, msg.Type)
+	}
+	return client, key, version
+}
+
+func (c *authTestClient) receive(t *testing.T) NetworkMessage {
+	var msg NetworkMessage
+	if err := readTestFrame(c.reader, &msg); err != nil {
+		t.Fatalf("Failed to receive message: %v", err)
+	}
+	return msg
+}
+
+func (c *authTestClient) send(t *testing.T, msgType MessageType, payload []byte) {
+	msg := NetworkMessage{Type: msgType, Payload: payload, Timestamp: time.Now()}
+	if err := writeTestFrame(c.conn, msg); err != nil {
+		t.Fatalf("Failed to send %s: %v", msgType, err)
+	}
+}
+
+// answer sends a verack carrying sign's answer to the node's challenge
+func (c *authTestClient) answer(t *testing.T, version VersionPayload, sign func(nonce []byte) ([]byte, error)) {
+	signature, err := sign(version.Challenge)
+	if err != nil {
+		t.Fatalf("Failed to sign challenge: %v", err)
+	}
+	payload, err := json.Marshal(VerAckPayload{Signature: signature})
+	if err != nil {
+		t.Fatalf("Failed to encode verack: %v", err)
+	}
+	c.send(t, MessageTypeVerAck, payload)
+}
+
+func TestPeerAuthRejectsWrongKey(t *testing.T) {
+	node := newHandshakeTestNode(t)
+	client, _, version := dialAuthTestClient(t, node)
+
+	// Sign with a key other than the one advertised in the version
+	other, err := security.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	client.answer(t, version, func(nonce []byte) ([]byte, error) {
+		return security.SignChallenge(other, version.PublicKey, nonce)
+	})
+
+	msg := client.receive(t)
+	if msg.Type != MessageTypeReject {
+		t.Fatalf("Expected %s message, got %s", MessageTypeReject, msg.Type)
+	}
+	var reject RejectPayload
+	if err := json.Unmarshal(msg.Payload, &reject); err != nil {
+		t.Fatalf("Failed to decode reject: %v", err)
+	}
+	if !strings.Contains(reject.Reason, "authentication failed") {
+		t.Errorf("Unexpected reject reason: %q", reject.Reason)
+	}
+
+	if _, err := client.reader.ReadByte(); err == nil {
+		t.Error("Expected connection to be closed after reject")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(node.GetPeers()) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected rejected peer to be removed, have %d peers", len(node.GetPeers()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPeerAuthRejectsRelayedAnswer(t *testing.T) {
+	node := newHandshakeTestNode(t)
+	client, key, version := dialAuthTestClient(t, node)
+
+	// An answer the advertised key made for another challenger, as a man in
+	// the middle relaying the node's challenge to the key owner would get
+	other, err := security.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	client.answer(t, version, func(nonce []byte) ([]byte, error) {
+		return security.SignChallenge(key, crypto.PublicKeyToBytes(&other.PublicKey), nonce)
+	})
+
+	msg := client.receive(t)
+	if msg.Type != MessageTypeReject {
+		t.Fatalf("Expected %s message, got %s", MessageTypeReject, msg.Type)
+	}
+	var reject RejectPayload
+	if err := json.Unmarshal(msg.Payload, &reject); err != nil {
+		t.Fatalf("Failed to decode reject: %v", err)
+	}
+	if !strings.Contains(reject.Reason, "authentication failed") {
+		t.Errorf("Unexpected reject reason: %q", reject.Reason)
+	}
+}
+
+func TestPeerAuthAcceptsKeyOwner(t *testing.T) {
+	node := newHandshakeTestNode(t)
+	client, key, version := dialAuthTestClient(t, node)
+
+	// Messages sent before answering the challenge are dropped
+	tx := signedBroadcastTx(t, node.Blockchain)
+	payload, err := EncodePayload(tx)
+	if err != nil {
+		t.Fatalf("Failed to encode transaction: %v", err)
+	}
+	client.send(t, MessageTypeTx, payload)
+	time.Sleep(100 * time.Millisecond)
+
+	client.answer(t, version, func(nonce []byte) ([]byte, error) {
+		return security.SignChallenge(key, version.PublicKey, nonce)
+	})
+	time.Sleep(100 * time.Millisecond)
+	if len(node.Blockchain.GetPendingTransactions()) != 0 {
+		t.Fatal("Node accepted a transaction from an unauthenticated peer")
+	}
+
+	// Once authenticated the same transaction is accepted
+	client.send(t, MessageTypeTx, payload)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		for _, pending := range node.Blockchain.GetPendingTransactions() {
+			if bytes.Equal(pending.ID, tx.ID) {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Node never accepted the authenticated peer's transaction")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,192 @@
+package network
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"byc/internal/blockchain"
+)
+
+// PropagationWindow is how many of the latest blocks the median propagation
+// delay is taken over
+const PropagationWindow = 100
+
+// AnnouncementTTL is how long the first announcement of a block is kept
+// while waiting for the block to connect
+const AnnouncementTTL = 10 * time.Minute
+
+// MaxAnnouncements caps how many announcements of blocks not yet connected
+// are kept; past it the oldest is dropped
+const MaxAnnouncements = 4 * PropagationWindow
+
+// BlockPropagation is how long a block took to reach the node: from the
+// time its miner stamped in the header to the first peer announcing it, in
+// an inv or by sending the block. The header time has one-second resolution
+// and comes from the miner's clock, so a delay that would be negative is
+// counted as none.
+type BlockPropagation struct {
+	Hash      []byte
+	Mined     time.Time
+	FirstSeen time.Time
+	Delay     time.Duration
+}
+
+// announcement is a hash announced at a time, queued in announcement order
+type announcement struct {
+	hash string
+	at   time.Time
+}
+
+// propagationTracker keeps the propagation of the latest blocks. The zero
+// value is ready to use.
+type propagationTracker struct {
+	mu        sync.Mutex
+	announced map[string]time.Time
+	order     []announcement
+	seen      map[string]bool
+	recent    []BlockPropagation
+}
+
+// announce notes that a peer announced the block with hash at now, unless
+// it was announced before. Announcements older than AnnouncementTTL are
+// dropped, oldest first, so hashes that never connect do not pile up, and
+// at most MaxAnnouncements are kept.
+func (t *propagationTracker) announce(hash string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.announced == nil {
+		t.announced = make(map[string]time.Time)
+	}
+	if _, ok := t.announced[hash]; ok {
+		return
+	}
+	for len(t.order) > 0 && (len(t.order) >= MaxAnnouncements || now.Sub(t.order[0].at) > AnnouncementTTL) {
+		t.dropOldest()
+	}
+	t.announced[hash] = now
+	t.order = append(t.order, announcement{hash: hash, at: now})
+}
+
+// dropOldest removes the oldest queued announcement. One already consumed
+// by record or forget only leaves the queue; one announced again since is
+// kept. The caller holds t.mu.
+func (t *propagationTracker) dropOldest() {
+	oldest := t.order[0]
+	t.order[0] = announcement{}
+	t.order = t.order[1:]
+	if at, ok := t.announced[oldest.hash]; ok && at.Equal(oldest.at) {
+		delete(t.announced, oldest.hash)
+	}
+}
+
+// forget drops the announcement of hash, which turned out not to be a block
+func (t *propagationTracker) forget(hash string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.announced, hash)
+}
+
+// record notes that block connected at now and returns its propagation,
+// measured from its mined time to its first announcement. A block never
+// announced counts as announced at now. A block already in the window was
+// recorded before and is not recorded again.
+func (t *propagationTracker) record(block *blockchain.Block, now time.Time) (BlockPropagation, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := string(block.Hash)
+	if t.seen[key] {
+		return BlockPropagation{}, false
+	}
+	if t.seen == nil {
+		t.seen = make(map[string]bool)
+	}
+
+	first, ok := t.announced[key]
+	if !ok || first.After(now) {
+		first = now
+	}
+	delete(t.announced, key)
+
+	mined := time.Unix(block.Timestamp, 0)
+	delay := first.Sub(mined)
+	if delay < 0 {
+		delay = 0
+	}
+	p := BlockPropagation{
+		Hash:      append([]byte(nil), block.Hash...),
+		Mined:     mined,
+		FirstSeen: first,
+		Delay:     delay,
+	}
+
+	t.seen[key] = true
+	t.recent = append(t.recent, p)
+	if len(t.recent) > PropagationWindow {
+		delete(t.seen, string(t.recent[0].Hash))
+		t.recent = t.recent[1:]
+	}
+	return p, true
+}
+
+// snapshot returns the recorded propagations, oldest first
+func (t *propagationTracker) snapshot() []BlockPropagation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]BlockPropagation(nil), t.recent...)
+}
+
+// median returns the median delay of the recorded propagations, false if
+// there are none
+func (t *propagationTracker) median() (time.Duration, bool) {
+	recent := t.snapshot()
+	if len(recent) == 0 {
+		return 0, false
+	}
+	delays := make([]time.Duration, len(recent))
+	for i, p := range recent {
+		delays[i] = p.Delay
+	}
+	sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+
+	mid := len(delays) / 2
+	if len(delays)%2 == 0 {
+		return (delays[mid-1] + delays[mid]) / 2, true
+	}
+	return delays[mid], true
+}
+
+// BlockPropagations returns how long each of the latest PropagationWindow
+// blocks relayed to the node near the tip took to be first announced after
+// they were mined, oldest first
+func (n *Node) BlockPropagations() []BlockPropagation {
+	return n.propagation.snapshot()
+}
+
+// MedianPropagationDelay returns the median delay between the latest relayed
+// blocks being mined and first announced, false before any block has been
+// relayed near the tip
+func (n *Node) MedianPropagationDelay() (time.Duration, bool) {
+	return n.propagation.median()
+}
+
+// recordPropagation records the propagation of block, just connected, if it
+// extends the tip of its chain while the node is synced. Blocks fetched
+// during initial sync or connecting below the tip were mined long ago
+// and would only skew the median.
+func (n *Node) recordPropagation(block *blockchain.Block, now time.Time) {
+	if !n.Blockchain.IsSynced() {
+		return
+	}
+	tip, silver := n.Blockchain.Tip()
+	if block.BlockType == blockchain.SilverBlock {
+		tip = silver
+	}
+	if tip == nil || !bytes.Equal(tip.Hash, block.Hash) {
+		return
+	}
+	n.propagation.record(block, now)
+}
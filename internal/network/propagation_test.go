@@ -0,0 +1,156 @@
+package network
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+)
+
+func TestBlockPropagationDelay(t *testing.T) {
+	node := &Node{}
+	if _, ok := node.MedianPropagationDelay(); ok {
+		t.Error("Expected no median before any block was seen")
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	blocks := []struct {
+		mined     time.Duration
+		announced time.Duration
+		delay     time.Duration
+	}{
+		{mined: -5 * time.Second, announced: -3 * time.Second, delay: 2 * time.Second},
+		{mined: -10 * time.Second, announced: -1 * time.Second, delay: 9 * time.Second},
+		{mined: -4 * time.Second, announced: 0, delay: 4 * time.Second},
+		// A miner whose clock runs ahead stamps a block after it is seen
+		{mined: 3 * time.Second, announced: -time.Second, delay: 0},
+	}
+	for i, b := range blocks {
+		block := &blockchain.Block{
+			Hash:      []byte(fmt.Sprintf("block-%d", i)),
+			Timestamp: now.Add(b.mined).Unix(),
+		}
+		node.propagation.announce(string(block.Hash), now.Add(b.announced))
+		// Later announcements keep the first
+		node.propagation.announce(string(block.Hash), now.Add(b.announced+time.Second))
+
+		// Validation time after the first announcement plays no part
+		p, ok := node.propagation.record(block, now.Add(time.Minute))
+		if !ok {
+			t.Fatalf("Block %d was not recorded", i)
+		}
+		if p.Delay != b.delay {
+			t.Errorf("Block %d: expected a delay of %s, got %s", i, b.delay, p.Delay)
+		}
+		if !p.FirstSeen.Equal(now.Add(b.announced)) || !p.Mined.Equal(now.Add(b.mined)) {
+			t.Errorf("Block %d: unexpected times %v and %v", i, p.Mined, p.FirstSeen)
+		}
+	}
+
+	// A block never announced before it connected is first seen then
+	unannounced := &blockchain.Block{Hash: []byte("unannounced"), Timestamp: now.Add(-6 * time.Second).Unix()}
+	p, ok := node.propagation.record(unannounced, now)
+	if !ok || p.Delay != 6*time.Second {
+		t.Errorf("Expected an unannounced block to be recorded with a delay of 6s, got %s", p.Delay)
+	}
+
+	// A block seen again keeps its first sighting
+	if _, ok := node.propagation.record(&blockchain.Block{Hash: []byte("block-0")}, now.Add(time.Minute)); ok {
+		t.Error("A block seen twice was recorded twice")
+	}
+	if got := len(node.BlockPropagations()); got != len(blocks)+1 {
+		t.Errorf("Expected %d propagations, got %d", len(blocks)+1, got)
+	}
+
+	// The median of 0s, 2s, 4s, 6s and 9s
+	median, ok := node.MedianPropagationDelay()
+	if !ok || median != 4*time.Second {
+		t.Errorf("Expected a median of 4s, got %s", median)
+	}
+
+	// Only the latest window counts
+	for i := 0; i < PropagationWindow; i++ {
+		block := &blockchain.Block{
+			Hash:      []byte(fmt.Sprintf("late-%d", i)),
+			Timestamp: now.Add(-time.Minute).Unix(),
+		}
+		node.propagation.announce(string(block.Hash), now)
+		node.propagation.record(block, now)
+	}
+	if got := len(node.BlockPropagations()); got != PropagationWindow {
+		t.Errorf("Expected the window to hold %d propagations, got %d", PropagationWindow, got)
+	}
+	if median, _ := node.MedianPropagationDelay(); median != time.Minute {
+		t.Errorf("Expected a median of 1m once the early blocks left the window, got %s", median)
+	}
+
+	// Announcements of blocks that never connect expire
+	node.propagation.announce("stale", now)
+	node.propagation.announce("fresh", now.Add(AnnouncementTTL+time.Second))
+	if _, ok := node.propagation.announced["stale"]; ok {
+		t.Error("An announcement older than AnnouncementTTL was kept")
+	}
+}
+
+func TestAnnouncementsAreBounded(t *testing.T) {
+	var tracker propagationTracker
+	now := time.Now()
+
+	for i := 0; i < MaxAnnouncements+10; i++ {
+		tracker.announce(fmt.Sprintf("block-%d", i), now.Add(time.Duration(i)*time.Millisecond))
+	}
+	if got := len(tracker.announced); got != MaxAnnouncements {
+		t.Errorf("Expected %d announcements to be kept, got %d", MaxAnnouncements, got)
+	}
+	if got := len(tracker.order); got != MaxAnnouncements {
+		t.Errorf("Expected %d queued announcements, got %d", MaxAnnouncements, got)
+	}
+	// The oldest were dropped first
+	if _, ok := tracker.announced["block-9"]; ok {
+		t.Error("Expected the oldest announcements to be dropped")
+	}
+	if _, ok := tracker.announced["block-10"]; !ok {
+		t.Error("Expected the newest announcements to be kept")
+	}
+
+	// A forgotten announcement is gone, and its queued entry leaving later
+	// does not drop a newer announcement of the same hash
+	tracker.forget("block-20")
+	if _, ok := tracker.announced["block-20"]; ok {
+		t.Error("Expected a forgotten announcement to be dropped")
+	}
+	later := now.Add(time.Minute)
+	tracker.announce("block-20", later)
+	for i := 0; i < 20; i++ {
+		tracker.announce(fmt.Sprintf("extra-%d", i), later)
+	}
+	if at, ok := tracker.announced["block-20"]; !ok || !at.Equal(later) {
+		t.Error("Expected a hash announced again to keep its new announcement")
+	}
+}
+
+func TestRecordPropagationOnlyNearTip(t *testing.T) {
+	node := &Node{Blockchain: blockchain.NewBlockchain()}
+	now := time.Now()
+
+	// A block that is not the tip of its chain connected below it
+	node.recordPropagation(&blockchain.Block{Hash: []byte("side"), BlockType: blockchain.SilverBlock}, now)
+	if got := len(node.BlockPropagations()); got != 0 {
+		t.Errorf("Expected a block below the tip to be skipped, got %d propagations", got)
+	}
+
+	// While a peer is far ahead the node is syncing historic blocks
+	golden, _ := node.Blockchain.Tip()
+	node.Blockchain.SetPeerHeight("peer", 50)
+	node.recordPropagation(golden, now)
+	if got := len(node.BlockPropagations()); got != 0 {
+		t.Errorf("Expected a block fetched during sync to be skipped, got %d propagations", got)
+	}
+
+	node.Blockchain.RemovePeerHeight("peer")
+	node.recordPropagation(golden, now)
+	if got := len(node.BlockPropagations()); got != 1 {
+		t.Errorf("Expected the new tip to be recorded once synced, got %d propagations", got)
+	}
+}
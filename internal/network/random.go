@@ -0,0 +1,89 @@
+package network
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"byc/internal/security"
+)
+
+// peerRand is the source of a component's random peer choices. It is safe
+// for concurrent use and can be reseeded so tests reproduce a selection.
+type peerRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func newPeerRand() *peerRand {
+	return &peerRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// seed restarts the sequence of choices from seed
+func (pr *peerRand) seed(seed int64) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.r = rand.New(rand.NewSource(seed))
+}
+
+// shufflePeers shuffles peers in place. Peers gathered from a map are sorted
+// by address first, so the result depends only on the seed.
+func (pr *peerRand) shufflePeers(peers []*Peer) {
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Address < peers[j].Address })
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.r.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+}
+
+// netGroupOrder returns the indexes of addrs taking one address from each
+// network group in turn, keeping the order within a group, so a selection
+// cut short still spans as many groups as it can. Addresses without a group
+// count as their own.
+func netGroupOrder(addrs []string) []int {
+	var groups []string
+	members := make(map[string][]int)
+	for i, addr := range addrs {
+		group := security.NetGroup(addr)
+		if group == "" {
+			group = addr
+		}
+		if _, ok := members[group]; !ok {
+			groups = append(groups, group)
+		}
+		members[group] = append(members[group], i)
+	}
+
+	order := make([]int, 0, len(addrs))
+	for round := 0; len(order) < len(addrs); round++ {
+		for _, group := range groups {
+			if round < len(members[group]) {
+				order = append(order, members[group][round])
+			}
+		}
+	}
+	return order
+}
+
+// spreadPeersByNetGroup reorders peers as netGroupOrder does
+func spreadPeersByNetGroup(peers []*Peer) []*Peer {
+	addrs := make([]string, len(peers))
+	for i, peer := range peers {
+		addrs[i] = peer.Address
+	}
+	spread := make([]*Peer, 0, len(peers))
+	for _, i := range netGroupOrder(addrs) {
+		spread = append(spread, peers[i])
+	}
+	return spread
+}
+
+// spreadAddrsByNetGroup reorders addrs as netGroupOrder does
+func spreadAddrsByNetGroup(addrs []string) []string {
+	spread := make([]string, 0, len(addrs))
+	for _, i := range netGroupOrder(addrs) {
+		spread = append(spread, addrs[i])
+	}
+	return spread
+}
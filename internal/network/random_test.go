@@ -0,0 +1,60 @@
+package network
+
+import (
+	"fmt"
+	"testing"
+)
+
+func peerAddresses(peers []*Peer) []string {
+	addresses := make([]string, len(peers))
+	for i, peer := range peers {
+		addresses[i] = peer.Address
+	}
+	return addresses
+}
+
+func TestGetRandomPeersSeeded(t *testing.T) {
+	selection := func(seed int64, reversed bool) []string {
+		dm := NewDiscoveryManager(nil, NewDiscoveryConfig())
+		for i := 0; i < 10; i++ {
+			n := i
+			if reversed {
+				n = 9 - i
+			}
+			dm.AddPeer(&Peer{Address: fmt.Sprintf("10.0.0.%d:3000", n)})
+		}
+		dm.SetRandSeed(seed)
+		return peerAddresses(dm.GetRandomPeers(4))
+	}
+
+	first := selection(42, false)
+	if len(first) != 4 {
+		t.Fatalf("Expected 4 peers, got %v", first)
+	}
+	if second := selection(42, true); fmt.Sprint(second) != fmt.Sprint(first) {
+		t.Errorf("Expected seed 42 to select %v again, got %v", first, second)
+	}
+
+	// Another seed should order ten peers differently
+	if other := selection(7, false); fmt.Sprint(other) == fmt.Sprint(first) {
+		t.Errorf("Expected seeds 42 and 7 to select differently, both gave %v", first)
+	}
+}
+
+func TestTxRelayPeersSeeded(t *testing.T) {
+	selection := func() []string {
+		node := newRelayTestNode(t, 0)
+		node.Config.TxRelayFanout = 3
+		log := &relayLog{}
+		for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+			addRelayPeer(node, log, name, 0, 0, 0, 0)
+		}
+		node.SetRandSeed(1)
+		return peerAddresses(node.txRelayPeers(nil, ""))
+	}
+
+	first := selection()
+	if second := selection(); fmt.Sprint(second) != fmt.Sprint(first) {
+		t.Errorf("Expected the same seed to relay to %v again, got %v", first, second)
+	}
+}
@@ -42,6 +42,32 @@ func (tb *TokenBucket) Allow() bool {
 	return false
 }
 
+// Wait blocks until a request is allowed under the rate limit, returning
+// false if done is closed first
+func (tb *TokenBucket) Wait(done <-chan struct{}) bool {
+	for {
+		tb.mu.Lock()
+		now := time.Now()
+		tb.tokens = min(float64(tb.burst), tb.tokens+now.Sub(tb.lastUpdate).Seconds()*tb.rate)
+		tb.lastUpdate = now
+		if tb.tokens >= 1 {
+			tb.tokens--
+			tb.mu.Unlock()
+			return true
+		}
+		wait := time.Duration((1 - tb.tokens) / tb.rate * float64(time.Second))
+		tb.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-done:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
 // RateLimitMiddleware wraps an HTTP handler with rate limiting
 type RateLimitMiddleware struct {
 	limiter *TokenBucket
@@ -0,0 +1,135 @@
+package network
+
+import (
+	"errors"
+	"time"
+)
+
+// The discovery manager's peer table is the one registry of peers: its own
+// connections and, once it is attached to a node, every peer the node
+// connects to or accepts. The node reports its peers through addPeer and
+// dropPeer, so discovery, health monitoring and broadcasting all see the
+// same set.
+
+// attachDiscovery makes dm the registry for n's peers and registers the
+// peers n already has
+func (n *Node) attachDiscovery(dm *DiscoveryManager) {
+	n.mu.Lock()
+	n.discovery = dm
+	peers := make([]*Peer, 0, len(n.Peers))
+	for _, peer := range n.Peers {
+		peers = append(peers, peer)
+	}
+	n.mu.Unlock()
+
+	for _, peer := range peers {
+		dm.trackPeer(peer)
+	}
+}
+
+// addPeer stores peer in the node's peer table under key and registers it
+// with discovery
+func (n *Node) addPeer(key string, peer *Peer) {
+	n.mu.Lock()
+	n.Peers[key] = peer
+	dm := n.discovery
+	n.mu.Unlock()
+
+	if dm != nil {
+		dm.trackPeer(peer)
+	}
+}
+
+// dropPeer unregisters peer from discovery, and forgets the height it
+// advertised, once the node no longer holds it under any key
+func (n *Node) dropPeer(peer *Peer) {
+	n.mu.RLock()
+	dm := n.discovery
+	for _, p := range n.Peers {
+		if p == peer {
+			n.mu.RUnlock()
+			return
+		}
+	}
+	n.mu.RUnlock()
+
+	if dm != nil {
+		dm.untrackPeer(peer)
+	}
+	if n.Blockchain != nil {
+		n.Blockchain.RemovePeerHeight(peer.Address)
+	}
+}
+
+// trackPeer registers a node peer, keeping what is already known about it
+func (dm *DiscoveryManager) trackPeer(peer *Peer) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if _, exists := dm.peers[peer.Address]; !exists {
+		dm.peers[peer.Address] = &PeerInfo{
+			Address:    peer.Address,
+			LastSeen:   time.Now(),
+			IsOutbound: peer.outbound,
+		}
+	}
+	dm.knownPeers[peer.Address] = peer
+	dm.nodePeers[peer.Address] = peer
+}
+
+// untrackPeer removes a node peer from the registry
+func (dm *DiscoveryManager) untrackPeer(peer *Peer) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if dm.nodePeers[peer.Address] != peer {
+		return
+	}
+	delete(dm.nodePeers, peer.Address)
+	delete(dm.knownPeers, peer.Address)
+	if _, exists := dm.connections[peer.Address]; !exists {
+		delete(dm.peers, peer.Address)
+	}
+}
+
+// Peers returns a snapshot of every registered peer: the discovery manager's
+// own connections and the peers of the node it is attached to
+func (dm *DiscoveryManager) Peers() []PeerInfo {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+
+	peers := make([]PeerInfo, 0, len(dm.peers))
+	for addr, info := range dm.peers {
+		snapshot := *info
+		if peer, ok := dm.nodePeers[addr]; ok {
+			status := peer.Status()
+			snapshot.LastSeen = status.LastSeen
+			snapshot.BlockHeight = status.Height
+			snapshot.Latency = status.Latency
+			snapshot.BytesSent = status.BytesSent
+			snapshot.BytesReceived = status.BytesReceived
+		}
+		peers = append(peers, snapshot)
+	}
+	return peers
+}
+
+// nodePeer returns the node peer registered at addr, if any
+func (dm *DiscoveryManager) nodePeer(addr string) (*Peer, bool) {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+	peer, ok := dm.nodePeers[addr]
+	return peer, ok
+}
+
+// pingNodePeer pings a node peer over the node's protocol
+func (dm *DiscoveryManager) pingNodePeer(peer *Peer) (time.Duration, error) {
+	if dm.node == nil {
+		return 0, errors.New("peer not found")
+	}
+	start := time.Now()
+	if err := dm.node.sendMessage(peer, MessageTypePing, []byte("ping")); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
@@ -0,0 +1,80 @@
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNodePeersAreRegisteredWithDiscovery(t *testing.T) {
+	address := startSinkPeer(t)
+	node := newRetryTestNode(t, &flakyDialer{})
+	dm := NewDiscoveryManager(node, NewDiscoveryConfig())
+	defer dm.Stop()
+
+	if err := node.ConnectToPeer(address); err != nil {
+		t.Fatalf("Failed to connect to peer: %v", err)
+	}
+
+	var info *PeerInfo
+	for _, peer := range dm.Peers() {
+		if peer.Address == address {
+			peer := peer
+			info = &peer
+		}
+	}
+	if info == nil {
+		t.Fatalf("Peer %s connected through the node is missing from the discovery peer list", address)
+	}
+	if !info.IsOutbound {
+		t.Error("Peer dialed by the node is not marked outbound")
+	}
+	if info.BytesSent == 0 {
+		t.Error("Registered peer does not report the node's traffic with it")
+	}
+
+	// The node's peer is a discovery candidate too
+	var candidate bool
+	for _, peer := range dm.GetRandomPeers(10) {
+		if peer.Address == address {
+			candidate = true
+		}
+	}
+	if !candidate {
+		t.Error("Node peer is not offered by GetRandomPeers")
+	}
+
+	// Disconnecting through the node removes it from the registry
+	if err := node.DisconnectPeer(address); err != nil {
+		t.Fatalf("Failed to disconnect peer: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for dm.isPeerConnected(address) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if dm.isPeerConnected(address) {
+		t.Error("Peer disconnected by the node is still registered with discovery")
+	}
+}
+
+func TestDiscoveryDisconnectsNodePeers(t *testing.T) {
+	address := startSinkPeer(t)
+	node := newRetryTestNode(t, &flakyDialer{})
+	if err := node.ConnectToPeer(address); err != nil {
+		t.Fatalf("Failed to connect to peer: %v", err)
+	}
+
+	// Peers the node had before discovery was attached are registered too
+	dm := NewDiscoveryManager(node, NewDiscoveryConfig())
+	defer dm.Stop()
+	if !dm.isPeerConnected(address) {
+		t.Fatalf("Existing node peer %s was not registered", address)
+	}
+
+	dm.disconnectPeer(address)
+	if len(node.GetPeers()) != 0 {
+		t.Errorf("Node still has %d peers after discovery disconnected it", len(node.GetPeers()))
+	}
+	if dm.isPeerConnected(address) {
+		t.Error("Disconnected peer is still registered")
+	}
+}
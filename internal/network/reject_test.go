@@ -0,0 +1,70 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+)
+
+func TestInvalidTransactionIsRejected(t *testing.T) {
+	node := newHandshakeTestNode(t)
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	peer := NewPeer("sender", "sender", 0)
+	peer.conn = local
+
+	// Only blocks may carry coinbase transactions
+	tx := blockchain.NewCoinbaseTransaction("miner", nil, 1, blockchain.Leah, blockchain.GoldenBlock)
+	payload, err := EncodePayload(tx)
+	if err != nil {
+		t.Fatalf("Failed to encode transaction: %v", err)
+	}
+
+	received := make(chan NetworkMessage, 1)
+	go func() {
+		var msg NetworkMessage
+		if err := readTestFrame(remote, &msg); err == nil {
+			received <- msg
+		}
+	}()
+	if err := node.handleTx(peer, NewNetworkMessage(MessageTypeTx, "sender", "", payload)); err == nil {
+		t.Fatal("Expected the coinbase transaction to be refused")
+	}
+
+	var msg NetworkMessage
+	select {
+	case msg = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("No reject message was sent")
+	}
+	if msg.Type != MessageTypeReject {
+		t.Fatalf("Sent message type %v, want %v", msg.Type, MessageTypeReject)
+	}
+	var reject RejectPayload
+	if err := json.Unmarshal(msg.Payload, &reject); err != nil {
+		t.Fatalf("Failed to decode reject payload: %v", err)
+	}
+	if reject.Code != blockchain.RejectCoinbase {
+		t.Errorf("Reject code = %q, want %q", reject.Code, blockchain.RejectCoinbase)
+	}
+	if reject.Message != MessageTypeTx {
+		t.Errorf("Rejected message type = %v, want %v", reject.Message, MessageTypeTx)
+	}
+	if !bytes.Equal(reject.Hash, tx.ID) {
+		t.Errorf("Rejected hash = %x, want %x", reject.Hash, tx.ID)
+	}
+	if reject.Reason == "" {
+		t.Error("Reject message has no reason")
+	}
+
+	// A data rejection is logged without ending the connection
+	if err := node.handleReject(peer, &msg); err != nil {
+		t.Errorf("handleReject of a refused transaction returned %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package network
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"byc/internal/blockchain"
+	"byc/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// DefaultRelayConcurrency is how many peers a block is sent to at once
+const DefaultRelayConcurrency = 4
+
+// referenceLatency is the round trip at which latency halves a peer's score
+const referenceLatency = 100 * time.Millisecond
+
+// Score rates how useful the peer is to relay blocks to first, between 0 and
+// 1. It combines the share of valid blocks the peer has sent, starting from
+// an even prior, with its latency. A peer whose latency was never measured is
+// scored as if it had the reference latency.
+func (p *Peer) Score() float64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	validity := float64(p.validBlocks+1) / float64(p.validBlocks+p.invalidBlocks+2)
+	latency := p.Latency
+	if latency <= 0 {
+		latency = referenceLatency
+	}
+	return validity / (1 + float64(latency)/float64(referenceLatency))
+}
+
+// recordBlock counts a block the peer sent towards its score
+func (p *Peer) recordBlock(valid bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if valid {
+		p.validBlocks++
+	} else {
+		p.invalidBlocks++
+	}
+}
+
+// servesChain reports whether the peer serves the blockType chain. Peers that
+// did not say which chain they serve, and data for no chain, count as served.
+func (p *Peer) servesChain(blockType blockchain.BlockType) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.BlockType == "" || blockType == "" || p.BlockType == blockType
+}
+
+// relayBlock sends block to every peer serving its chain except from, highest
+// scored first and at most Config.RelayConcurrency at a time. It only fails
+// if the block could not be delivered to any peer.
+func (n *Node) relayBlock(block *blockchain.Block, from *Peer) error {
+	data, err := EncodePayload(block)
+	if err != nil {
+		return err
+	}
+
+	n.mu.RLock()
+	peers := make([]*Peer, 0, len(n.Peers))
+	for _, peer := range n.Peers {
+		if peer != from && peer.servesChain(block.BlockType) {
+			peers = append(peers, peer)
+		}
+	}
+	limit := n.Config.RelayConcurrency
+	n.mu.RUnlock()
+	if len(peers) == 0 {
+		return nil
+	}
+	if limit <= 0 {
+		limit = DefaultRelayConcurrency
+	}
+
+	scores := make(map[*Peer]float64, len(peers))
+	for _, peer := range peers {
+		scores[peer] = peer.Score()
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		return scores[peers[i]] > scores[peers[j]]
+	})
+
+	// Sends start in score order; the semaphore keeps slow peers from
+	// holding up more than limit sends
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		sent    int
+		lastErr error
+	)
+	sem := make(chan struct{}, limit)
+	for _, peer := range peers {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(peer *Peer) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			err := n.sendEncoded(peer, MessageTypeBlock, data)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logger.Error("Failed to relay block to peer", zap.String("peer", peer.Address), zap.Error(err))
+				lastErr = fmt.Errorf("failed to send block to peer %s: %v", peer.Address, err)
+				return
+			}
+			sent++
+		}(peer)
+	}
+	wg.Wait()
+
+	if sent == 0 && lastErr != nil {
+		return lastErr
+	}
+	return nil
+}
+
+// peerRand returns the node's source of random peer choices
+func (n *Node) peerRand() *peerRand {
+	n.randOnce.Do(func() {
+		if n.rand == nil {
+			n.rand = newPeerRand()
+		}
+	})
+	return n.rand
+}
+
+// SetRandSeed seeds the node's random peer choices, such as the peers a
+// transaction is relayed to, so they can be reproduced
+func (n *Node) SetRandSeed(seed int64) {
+	n.peerRand().seed(seed)
+}
+
+// txRelayPeers returns the peers a transaction for the chain is relayed to:
+// every peer serving it except from, or a random Config.TxRelayFanout of them
+// when that is smaller. Blocks ignore the fan-out and always go to every
+// peer serving their chain.
+func (n *Node) txRelayPeers(from *Peer, chain blockchain.BlockType) []*Peer {
+	n.mu.RLock()
+	peers := make([]*Peer, 0, len(n.Peers))
+	for _, peer := range n.Peers {
+		if peer != from && peer.servesChain(chain) {
+			peers = append(peers, peer)
+		}
+	}
+	n.mu.RUnlock()
+
+	if fanout := n.Config.TxRelayFanout; fanout > 0 && fanout < len(peers) {
+		n.peerRand().shufflePeers(peers)
+		peers = peers[:fanout]
+	}
+	return peers
+}
@@ -0,0 +1,230 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+	"byc/internal/crypto"
+)
+
+// relayLog records which peers a relay wrote to, in order, and how many
+// writes were in flight at once
+type relayLog struct {
+	mu          sync.Mutex
+	order       []string
+	inFlight    int
+	maxInFlight int
+}
+
+// relayConn is a peer connection that records writes to a relayLog. A
+// message may take several writes; only the first is added to the order.
+type relayConn struct {
+	net.Conn
+	name    string
+	log     *relayLog
+	delay   time.Duration
+	written bool
+}
+
+func (c *relayConn) Write(p []byte) (int, error) {
+	c.log.mu.Lock()
+	if !c.written {
+		c.written = true
+		c.log.order = append(c.log.order, c.name)
+	}
+	c.log.inFlight++
+	if c.log.inFlight > c.log.maxInFlight {
+		c.log.maxInFlight = c.log.inFlight
+	}
+	c.log.mu.Unlock()
+
+	time.Sleep(c.delay)
+
+	c.log.mu.Lock()
+	c.log.inFlight--
+	c.log.mu.Unlock()
+	return len(p), nil
+}
+
+func (c *relayConn) Close() error { return nil }
+
+func newRelayTestNode(t *testing.T, concurrency int) *Node {
+	return &Node{
+		Config: &Config{
+			Address:          "localhost:0",
+			BlockType:        blockchain.GoldenBlock,
+			RelayConcurrency: concurrency,
+		},
+		Peers: make(map[string]*Peer),
+		quit:  make(chan struct{}),
+	}
+}
+
+func addRelayPeer(node *Node, log *relayLog, name string, latency time.Duration, valid, invalid int, delay time.Duration) *Peer {
+	peer := NewPeer(name, name, 0)
+	peer.conn = &relayConn{name: name, log: log, delay: delay}
+	peer.Latency = latency
+	peer.validBlocks = valid
+	peer.invalidBlocks = invalid
+	node.Peers[name] = peer
+	return peer
+}
+
+func TestRelayBlockOrdersPeersByScore(t *testing.T) {
+	node := newRelayTestNode(t, 1)
+	log := &relayLog{}
+	addRelayPeer(node, log, "slow", 800*time.Millisecond, 5, 0, 0)
+	addRelayPeer(node, log, "fast", 10*time.Millisecond, 5, 0, 0)
+	addRelayPeer(node, log, "misbehaving", 10*time.Millisecond, 0, 5, 0)
+	addRelayPeer(node, log, "unmeasured", 0, 0, 0, 0)
+	source := addRelayPeer(node, log, "source", time.Millisecond, 10, 0, 0)
+
+	if !(node.Peers["fast"].Score() > node.Peers["slow"].Score()) {
+		t.Fatal("Expected lower latency to score higher")
+	}
+	if !(node.Peers["fast"].Score() > node.Peers["misbehaving"].Score()) {
+		t.Fatal("Expected invalid blocks to lower the score")
+	}
+
+	if err := node.relayBlock(&blockchain.Block{Hash: []byte("relayed")}, source); err != nil {
+		t.Fatalf("relayBlock failed: %v", err)
+	}
+
+	want := []string{"fast", "unmeasured", "misbehaving", "slow"}
+	if len(log.order) != len(want) {
+		t.Fatalf("Expected the block sent to %v, got %v", want, log.order)
+	}
+	for i := range want {
+		if log.order[i] != want[i] {
+			t.Fatalf("Expected relay order %v, got %v", want, log.order)
+		}
+	}
+}
+
+func TestRelayBlockCapsConcurrentSends(t *testing.T) {
+	node := newRelayTestNode(t, 2)
+	log := &relayLog{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		addRelayPeer(node, log, name, 0, 0, 0, 20*time.Millisecond)
+	}
+
+	if err := node.relayBlock(&blockchain.Block{Hash: []byte("relayed")}, nil); err != nil {
+		t.Fatalf("relayBlock failed: %v", err)
+	}
+	if len(log.order) != 6 {
+		t.Errorf("Expected the block sent to all 6 peers, got %v", log.order)
+	}
+	if log.maxInFlight > 2 {
+		t.Errorf("Expected at most 2 concurrent sends, got %d", log.maxInFlight)
+	}
+}
+
+func TestRelayTransactionFanout(t *testing.T) {
+	for _, tt := range []struct {
+		fanout int
+		want   int
+	}{
+		{fanout: 2, want: 2},
+		{fanout: 5, want: 5},
+		{fanout: 8, want: 5},
+		{fanout: 0, want: 5},
+	} {
+		node := newRelayTestNode(t, 0)
+		node.Config.TxRelayFanout = tt.fanout
+		node.Blockchain = blockchain.NewBlockchain()
+		log := &relayLog{}
+		for _, name := range []string{"a", "b", "c", "d", "e"} {
+			addRelayPeer(node, log, name, 0, 0, 0, 0)
+		}
+
+		if err := node.relayTransaction(nil, signedBroadcastTx(t, node.Blockchain)); err != nil {
+			t.Fatalf("relayTransaction failed: %v", err)
+		}
+		if len(log.order) != tt.want {
+			t.Errorf("Fan-out %d: expected the transaction relayed to %d of 5 peers, got %v", tt.fanout, tt.want, log.order)
+		}
+	}
+}
+
+func TestRelayOnlyToPeersServingTheChain(t *testing.T) {
+	node := newHandshakeTestNode(t)
+	log := &relayLog{}
+	addRelayPeer(node, log, "golden", 0, 0, 0, 0).BlockType = blockchain.GoldenBlock
+	addRelayPeer(node, log, "silver", 0, 0, 0, 0).BlockType = blockchain.SilverBlock
+	addRelayPeer(node, log, "both", 0, 0, 0, 0)
+
+	// relayed returns the peers written to since the last call
+	relayed := func() []string {
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		got := log.order
+		sort.Strings(got)
+		log.order = nil
+		for _, peer := range node.Peers {
+			peer.conn.(*relayConn).written = false
+		}
+		return got
+	}
+
+	tx := signedBroadcastTxOf(t, blockchain.Senum, node.Blockchain)
+	if err := node.relayTransaction(nil, tx); err != nil {
+		t.Fatalf("relayTransaction failed: %v", err)
+	}
+	if got := relayed(); len(got) != 2 || got[0] != "both" || got[1] != "silver" {
+		t.Errorf("Silver transaction relayed to %v, want [both silver]", got)
+	}
+
+	if err := node.relayBlock(&blockchain.Block{Hash: []byte("golden"), BlockType: blockchain.GoldenBlock}, nil); err != nil {
+		t.Fatalf("relayBlock failed: %v", err)
+	}
+	if got := relayed(); len(got) != 2 || got[0] != "both" || got[1] != "golden" {
+		t.Errorf("Golden block relayed to %v, want [both golden]", got)
+	}
+}
+
+func TestServedChainAdvertised(t *testing.T) {
+	tn := newTestNetwork(t, 2)
+	tn.connect(0, 1)
+
+	// advertised returns the chain node 0 has on record for node 1
+	advertised := func() blockchain.BlockType {
+		for _, peer := range tn.nodes[0].GetPeers() {
+			if peer.Address == tn.nodes[1].Config.Address {
+				peer.mu.RLock()
+				defer peer.mu.RUnlock()
+				return peer.BlockType
+			}
+		}
+		return "missing"
+	}
+	if got := advertised(); got != blockchain.GoldenBlock {
+		t.Fatalf("Node 1 advertised %q in its handshake, want %q", got, blockchain.GoldenBlock)
+	}
+
+	// Connected peers hear of a change to the served chain
+	for _, blockType := range []blockchain.BlockType{blockchain.SilverBlock, ""} {
+		tn.nodes[1].SetBlockType(blockType)
+		tn.waitFor(fmt.Sprintf("node 0 to see node 1 serve %q", blockType), func() bool {
+			return advertised() == blockType
+		})
+	}
+
+	// Mining one chain does not change what the node serves
+	tn.nodes[1].Config.MiningAddress = crypto.EncodeAddress(crypto.LegacyAddressVersion, bytes.Repeat([]byte{1}, 32))
+	if err := tn.nodes[1].StartMining(blockchain.Leah); err != nil {
+		t.Fatalf("Failed to start mining: %v", err)
+	}
+	defer tn.nodes[1].StopMining()
+	if got := tn.nodes[1].MiningChain(); got != blockchain.GoldenBlock {
+		t.Errorf("Mining chain is %q, want %q", got, blockchain.GoldenBlock)
+	}
+	if got := tn.nodes[1].servedChain(); got != "" {
+		t.Errorf("Mining changed the served chain to %q", got)
+	}
+}
@@ -0,0 +1,83 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+)
+
+// mineRequestTestBlock mines the next golden block on bc and adds it
+func mineRequestTestBlock(t *testing.T, bc *blockchain.Blockchain) blockchain.Block {
+	template, err := bc.GetBlockTemplate(blockchain.GoldenBlock, blockchain.Leah, "request-miner")
+	if err != nil {
+		t.Fatalf("GetBlockTemplate failed: %v", err)
+	}
+	var block blockchain.Block
+	for nonce := uint64(0); ; nonce++ {
+		if block = template.Assemble(nonce); bytes.Compare(block.Hash, template.Target) <= 0 {
+			break
+		}
+	}
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("AddBlock failed: %v", err)
+	}
+	return block
+}
+
+func TestRequestBlock(t *testing.T) {
+	provider := newHandshakeTestNode(t)
+	requester := newHandshakeTestNode(t)
+	if err := requester.ConnectToPeer(provider.GetAddress()); err != nil {
+		t.Fatalf("Failed to connect nodes: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(requester.GetPeers()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Requester never registered the provider as a peer")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Let the version exchange finish before requesting
+	peer := requester.GetPeers()[0]
+	for !peer.isAuthenticated() {
+		if time.Now().After(deadline) {
+			t.Fatal("Requester never authenticated the provider")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	saved := BlockRequestTimeout
+	BlockRequestTimeout = time.Second
+	defer func() { BlockRequestTimeout = saved }()
+
+	// A block the peer has is fetched and added to the chain
+	want := mineRequestTestBlock(t, provider.Blockchain)
+	block, err := requester.RequestBlock(peer, want.Hash)
+	if err != nil {
+		t.Fatalf("RequestBlock failed: %v", err)
+	}
+	if !bytes.Equal(block.Hash, want.Hash) {
+		t.Errorf("Expected block %x, got %x", want.Hash, block.Hash)
+	}
+	if _, err := requester.Blockchain.GetBlock(want.Hash); err != nil {
+		t.Errorf("Expected the fetched block on the requester's chain: %v", err)
+	}
+
+	// A block the peer lacks times out
+	start := time.Now()
+	if _, err := requester.RequestBlock(peer, bytes.Repeat([]byte{0xab}, 32)); err == nil {
+		t.Error("Expected a request for an unknown block to time out")
+	}
+	if elapsed := time.Since(start); elapsed < BlockRequestTimeout {
+		t.Errorf("Expected the request to wait %s, returned after %s", BlockRequestTimeout, elapsed)
+	}
+	requester.blockMu.Lock()
+	waiting := len(requester.blockWaiters)
+	requester.blockMu.Unlock()
+	if waiting != 0 {
+		t.Errorf("Expected no waiters left after the timeout, got %d", waiting)
+	}
+}
@@ -0,0 +1,59 @@
+package network
+
+import (
+	"errors"
+
+	"byc/internal/blockchain"
+)
+
+const (
+	// DefaultBlockServeChunk is how many blocks are sent per message when serving the chain
+	DefaultBlockServeChunk = 100
+	// DefaultBlockServeRate is how many chunks per second are served to one peer
+	DefaultBlockServeRate = 10
+	// BlockServeBurst is how many chunks may be sent to a peer back to back
+	BlockServeBurst = 2
+)
+
+// errServeStopped is returned when the node stops while serving blocks
+var errServeStopped = errors.New("node stopped while serving blocks")
+
+// serveBlocks sends blocks to peer in chunks of Config.BlockServeChunk,
+// waiting between chunks so the peer receives at most Config.BlockServeRate
+// chunks a second. Only the goroutine serving this peer waits, so a slow sync
+// never holds up other peers.
+func (n *Node) serveBlocks(peer *Peer, blocks []*blockchain.Block) error {
+	chunk := n.Config.BlockServeChunk
+	if chunk <= 0 {
+		chunk = DefaultBlockServeChunk
+	}
+	limiter := peer.blockServeLimiter(n.Config.BlockServeRate)
+
+	// An empty chain still gets one, empty, reply
+	for start := 0; start < len(blocks) || start == 0; start += chunk {
+		end := start + chunk
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+		if !limiter.Wait(n.quit) {
+			return errServeStopped
+		}
+		if err := n.sendMessage(peer, MessageTypeBlocks, blocks[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockServeLimiter returns the limiter pacing the chunks served to the peer
+func (p *Peer) blockServeLimiter(rate float64) *TokenBucket {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.serveLimiter == nil {
+		if rate <= 0 {
+			rate = DefaultBlockServeRate
+		}
+		p.serveLimiter = NewTokenBucket(rate, BlockServeBurst)
+	}
+	return p.serveLimiter
+}
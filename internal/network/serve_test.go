@@ -0,0 +1,149 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+)
+
+func TestServeBlocksIsPaced(t *testing.T) {
+	node := newRelayTestNode(t, 1)
+	node.Config.BlockServeChunk = 1
+	node.Config.BlockServeRate = 20
+
+	const count = 11
+	node.Blockchain = blockchain.NewBlockchain()
+	node.Blockchain.GoldenBlocks = nil
+	for i := 0; i < count; i++ {
+		node.Blockchain.GoldenBlocks = append(node.Blockchain.GoldenBlocks, blockchain.Block{Nonce: uint64(i)})
+	}
+
+	slowConn, slowRemote := net.Pipe()
+	defer slowConn.Close()
+	defer slowRemote.Close()
+	slow := NewPeer("slow", "slow", 0)
+	slow.conn = slowConn
+
+	fastConn, fastRemote := net.Pipe()
+	defer fastConn.Close()
+	defer fastRemote.Close()
+	fast := NewPeer("fast", "fast", 0)
+	fast.conn = fastConn
+
+	// Each message is read as the node reads them
+	reader := NewPeer("reader", "reader", 0)
+	reader.conn = slowRemote
+	received := make(chan []*blockchain.Block, count)
+	go func() {
+		for {
+			msg, err := node.receiveMessage(reader)
+			if err != nil {
+				close(received)
+				return
+			}
+			var blocks []*blockchain.Block
+			if err := DecodePayload(msg.Payload, &blocks); err == nil {
+				received <- blocks
+			}
+		}
+	}()
+
+	start := time.Now()
+	served := make(chan error, 1)
+	go func() { served <- node.handleGetBlocks(slow, &NetworkMessage{Type: MessageTypeGetBlocks}) }()
+
+	// The first chunks arrive in a burst
+	for i := 0; i < BlockServeBurst; i++ {
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatalf("Chunk %d never arrived", i)
+		}
+	}
+
+	// Another peer is served at once while the slow peer is still syncing
+	go func() {
+		var msg NetworkMessage
+		readTestFrame(fastRemote, &msg)
+	}()
+	sent := make(chan error, 1)
+	go func() { sent <- node.sendMessage(fast, MessageTypePing, []byte("ping")) }()
+	select {
+	case err := <-sent:
+		if err != nil {
+			t.Errorf("Failed to message another peer: %v", err)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Another peer was blocked by the block serve")
+	}
+
+	nonces := []uint64{0, 1}
+	for blocks := range received {
+		for _, block := range blocks {
+			nonces = append(nonces, block.Nonce)
+		}
+		if len(nonces) == count {
+			break
+		}
+	}
+	elapsed := time.Since(start)
+	if err := <-served; err != nil {
+		t.Fatalf("handleGetBlocks failed: %v", err)
+	}
+
+	for i, nonce := range nonces {
+		if nonce != uint64(i) {
+			t.Fatalf("Blocks arrived out of order: %v", nonces)
+		}
+	}
+	// Nine chunks beyond the burst at 20 a second take at least 450ms
+	if min := 400 * time.Millisecond; elapsed < min {
+		t.Errorf("Served %d chunks in %v, want at least %v", count, elapsed, min)
+	}
+}
+
+func TestServeBlocksWhileBlocksAreAdded(t *testing.T) {
+	node := newRelayTestNode(t, 1)
+	node.Config.BlockServeChunk = 1
+	node.Config.BlockServeRate = 10000
+	node.Blockchain = blockchain.NewBlockchain()
+
+	conn, remote := net.Pipe()
+	defer conn.Close()
+	defer remote.Close()
+	peer := NewPeer("syncing", "syncing", 0)
+	peer.conn = conn
+	go func() {
+		var msg NetworkMessage
+		for readTestFrame(remote, &msg) == nil {
+		}
+	}()
+
+	// Serving reads the chains while blocks are added; run with -race
+	done := make(chan struct{})
+	served := make(chan error, 1)
+	go func() {
+		defer close(served)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := node.handleGetBlocks(peer, &NetworkMessage{Type: MessageTypeGetBlocks}); err != nil {
+				served <- err
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		mineRequestTestBlock(t, node.Blockchain)
+	}
+	close(done)
+	if err := <-served; err != nil {
+		t.Fatalf("handleGetBlocks failed: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrShuttingDown is returned for peer connections opened once the node has
+// begun shutting down
+var ErrShuttingDown = errors.New("node is shutting down")
+
+// Shutdown stops the node in an order that leaves nothing running against
+// its blockchain. Mining stops first, waiting for the block being mined to
+// be abandoned or added. The node then stops accepting and dialing peers,
+// closes every peer connection and waits for the handlers and relays
+// serving them to return, or for ctx to end. The blockchain is left open for
+// the caller to flush and close once the rest of the process has stopped
+// using it.
+func (n *Node) Shutdown(ctx context.Context) error {
+	n.StopMining()
+
+	n.workerMu.Lock()
+	n.closing = true
+	n.workerMu.Unlock()
+	if err := n.Stop(); err != nil {
+		return err
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		n.workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("peer handlers still running at shutdown: %w", ctx.Err())
+	}
+}
+
+// startWorker counts a goroutine about to serve peers, for Shutdown to wait
+// on. It returns false once shutdown has begun, when no new connection may
+// be served; otherwise the caller must call n.workers.Done when the
+// goroutine returns.
+func (n *Node) startWorker() bool {
+	n.workerMu.Lock()
+	defer n.workerMu.Unlock()
+	if n.closing {
+		return false
+	}
+	n.workers.Add(1)
+	return true
+}
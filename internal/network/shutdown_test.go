@@ -0,0 +1,72 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"byc/internal/blockchain"
+	"byc/internal/crypto"
+)
+
+func TestShutdownWhileMiningAndServing(t *testing.T) {
+	tn := newLineNetwork(t, 3)
+	genesis, _ := tn.nodes[1].Blockchain.Tip()
+
+	// The nodes at either end mine, relaying their blocks through the middle
+	for _, i := range []int{0, 2} {
+		tn.nodes[i].Config.MiningAddress = crypto.EncodeAddress(crypto.LegacyAddressVersion, bytes.Repeat([]byte{byte(i)}, 32))
+		if err := tn.nodes[i].StartMining(blockchain.Leah); err != nil {
+			t.Fatalf("Failed to start mining on node %d: %v", i, err)
+		}
+	}
+	tn.waitFor("a mined block to be relayed to node 1", func() bool {
+		tip, _ := tn.nodes[1].Blockchain.Tip()
+		return !bytes.Equal(tip.Hash, genesis.Hash)
+	})
+
+	// Shut every node down at once, mid-mining and mid-relay
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	errs := make([]error, len(tn.nodes))
+	for i, node := range tn.nodes {
+		wg.Add(1)
+		go func(i int, node *Node) {
+			defer wg.Done()
+			errs[i] = node.Shutdown(ctx)
+		}(i, node)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Node %d failed to shut down: %v", i, err)
+		}
+	}
+
+	// Nothing touches the chains once Shutdown has returned
+	heights := make([]int, len(tn.nodes))
+	for i, node := range tn.nodes {
+		if node.IsMining() {
+			t.Errorf("Node %d is still mining", i)
+		}
+		if peers := node.GetPeers(); len(peers) != 0 {
+			t.Errorf("Node %d still has %d peers", i, len(peers))
+		}
+		heights[i] = node.Blockchain.Height()
+	}
+	time.Sleep(100 * time.Millisecond)
+	for i, node := range tn.nodes {
+		if got := node.Blockchain.Height(); got != heights[i] {
+			t.Errorf("Node %d's chain grew from %d to %d after shutdown", i, heights[i], got)
+		}
+	}
+
+	// A node that has shut down takes no new peers
+	if err := tn.nodes[0].ConnectToPeer(tn.nodes[1].Config.Address); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Expected ErrShuttingDown connecting after shutdown, got %v", err)
+	}
+}
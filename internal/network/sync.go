@@ -153,6 +153,7 @@ func (sm *SyncManager) HandleHeight(peer *Peer, height uint64) {
 
 	// Update peer's height
 	peer.Height = int64(height)
+	sm.blockchain.SetPeerHeight(peer.Address, int64(height))
 
 	// If peer has more blocks than us, request them
 	ourHeight := sm.blockchain.GetCurrentHeight()
@@ -0,0 +1,105 @@
+package network
+
+import (
+	"bufio"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// peerTraffic counts the bytes exchanged with a peer
+type peerTraffic struct {
+	sent     atomic.Uint64
+	received atomic.Uint64
+}
+
+// countingWriter adds the bytes written through it to a counter
+type countingWriter struct {
+	w     io.Writer
+	count *atomic.Uint64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.count.Add(uint64(n))
+	return n, err
+}
+
+// countingReader adds the bytes read through it to a counter
+type countingReader struct {
+	r     io.Reader
+	count *atomic.Uint64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.count.Add(uint64(n))
+	return n, err
+}
+
+// writer returns the peer's connection, counting the bytes sent
+func (p *Peer) writer() io.Writer {
+	return countingWriter{w: p.conn, count: &p.traffic.sent}
+}
+
+// reader returns a buffered reader over the peer's connection, counting the
+// bytes received. The reader lasts as long as the connection: gob reads from
+// a ByteReader only up to the end of each message, so the bytes of a message
+// that arrived with the one before stay buffered for the next decoder instead
+// of being lost with the decoder that read them.
+func (p *Peer) reader() io.Reader {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.in == nil || p.inConn != p.conn {
+		p.in = bufio.NewReader(countingReader{r: p.conn, count: &p.traffic.received})
+		p.inConn = p.conn
+	}
+	return p.in
+}
+
+// PeerStatus is a snapshot of a connected peer for monitoring
+type PeerStatus struct {
+	ID            string
+	Address       string
+	Height        int64
+	Latency       time.Duration
+	LastSeen      time.Time
+	BytesSent     uint64
+	BytesReceived uint64
+}
+
+// Status returns a snapshot of the peer
+func (p *Peer) Status() PeerStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return PeerStatus{
+		ID:            p.ID,
+		Address:       p.Address,
+		Height:        p.Height,
+		Latency:       p.Latency,
+		LastSeen:      p.LastSeen,
+		BytesSent:     p.traffic.sent.Load(),
+		BytesReceived: p.traffic.received.Load(),
+	}
+}
+
+// PeerStatus returns a snapshot of every connected peer, including the bytes
+// sent to and received from each
+func (n *Node) PeerStatus() []PeerStatus {
+	peers := n.GetPeers()
+	statuses := make([]PeerStatus, 0, len(peers))
+	for _, peer := range peers {
+		statuses = append(statuses, peer.Status())
+	}
+	return statuses
+}
+
+// recordTraffic adds bytes exchanged with a discovery peer to its PeerInfo
+func (dm *DiscoveryManager) recordTraffic(addr string, sent, received int) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	if peer, exists := dm.peers[addr]; exists {
+		peer.BytesSent += uint64(sent)
+		peer.BytesReceived += uint64(received)
+	}
+}
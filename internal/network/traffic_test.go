@@ -0,0 +1,120 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPeerStatusCountsNodeTraffic(t *testing.T) {
+	node := newRelayTestNode(t, 1)
+
+	// Capture exactly the bytes the node writes for one message
+	a, b := net.Pipe()
+	sender := NewPeer("sender", "sender", 0)
+	sender.conn = a
+	node.Peers["sender"] = sender
+
+	var wire bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(&wire, b)
+		close(copied)
+	}()
+	if err := node.sendMessage(sender, MessageTypePing, []byte("ping payload")); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	a.Close()
+	<-copied
+
+	if wire.Len() == 0 {
+		t.Fatal("No bytes were written")
+	}
+	if got := sender.Status().BytesSent; got != uint64(wire.Len()) {
+		t.Errorf("BytesSent = %d, want %d", got, wire.Len())
+	}
+	if got := sender.Status().BytesReceived; got != 0 {
+		t.Errorf("BytesReceived = %d, want 0", got)
+	}
+
+	// Feed the same bytes back in to a receiving peer
+	c, d := net.Pipe()
+	defer c.Close()
+	receiver := NewPeer("receiver", "receiver", 0)
+	receiver.conn = c
+	go func() {
+		d.Write(wire.Bytes())
+		d.Close()
+	}()
+	msg, err := node.receiveMessage(receiver)
+	if err != nil {
+		t.Fatalf("Failed to receive message: %v", err)
+	}
+	if msg.Type != MessageTypePing {
+		t.Errorf("Received message type %v, want %v", msg.Type, MessageTypePing)
+	}
+	if got := receiver.Status().BytesReceived; got != uint64(wire.Len()) {
+		t.Errorf("BytesReceived = %d, want %d", got, wire.Len())
+	}
+
+	var found bool
+	for _, status := range node.PeerStatus() {
+		if status.ID == "sender" {
+			found = true
+			if status.BytesSent != uint64(wire.Len()) {
+				t.Errorf("PeerStatus BytesSent = %d, want %d", status.BytesSent, wire.Len())
+			}
+		}
+	}
+	if !found {
+		t.Error("PeerStatus is missing the sender")
+	}
+}
+
+func TestDiscoveryCountsTraffic(t *testing.T) {
+	dm := NewDiscoveryManager(nil, NewDiscoveryConfig())
+	defer dm.Stop()
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	go dm.handleConnection(local)
+
+	ping := []byte(`{"type":"ping","payload":null}`)
+	if _, err := remote.Write(discoveryTestFrame(ping)); err != nil {
+		t.Fatalf("Failed to send ping: %v", err)
+	}
+
+	header := make([]byte, MagicSize+messageLengthSize)
+	if _, err := io.ReadFull(remote, header); err != nil {
+		t.Fatalf("Failed to read pong length: %v", err)
+	}
+	pong := make([]byte, binary.BigEndian.Uint32(header[MagicSize:]))
+	if _, err := io.ReadFull(remote, pong); err != nil {
+		t.Fatalf("Failed to read pong: %v", err)
+	}
+
+	wantSent := uint64(MagicSize + messageLengthSize + len(pong))
+	wantReceived := uint64(MagicSize + messageLengthSize + len(ping))
+	var sent, received uint64
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		dm.mu.RLock()
+		if peer, ok := dm.peers["pipe"]; ok {
+			sent, received = peer.BytesSent, peer.BytesReceived
+		}
+		dm.mu.RUnlock()
+		if sent == wantSent && received == wantReceived {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if sent != wantSent {
+		t.Errorf("BytesSent = %d, want %d", sent, wantSent)
+	}
+	if received != wantReceived {
+		t.Errorf("BytesReceived = %d, want %d", received, wantReceived)
+	}
+}
@@ -1,11 +1,14 @@
 package network
 
 import (
+	"bufio"
+	"crypto/ecdsa"
 	"net"
 	"sync"
 	"time"
 
 	"byc/internal/blockchain"
+	"byc/internal/security"
 )
 
 // MessageType represents the type of network message
@@ -25,8 +28,67 @@ const (
 	MessageTypeVerAck    MessageType = "VERACK"
 	MessageTypeVersion   MessageType = "VERSION"
 	MessageTypeGetHeight MessageType = "GET_HEIGHT"
+	MessageTypeReject    MessageType = "REJECT"
 )
 
+const (
+	// ProtocolVersion is the peer protocol version spoken by this node
+	ProtocolVersion int32 = 2
+	// DefaultMinProtocolVersion is the oldest peer protocol version accepted by default
+	DefaultMinProtocolVersion int32 = 2
+	// DefaultUserAgent identifies this node's software to peers
+	DefaultUserAgent = "/byc:1.0.0/"
+)
+
+const (
+	// MaxAddrsPerMessage caps how many addresses from one addr message are dialed
+	MaxAddrsPerMessage = 100
+	// AddrMessageRate is how many addr messages per second a peer may send
+	AddrMessageRate = 0.1
+	// AddrMessageBurst is how many addr messages a peer may send back to back
+	AddrMessageBurst = 2
+	// MaxInvPerMessage caps how many items of one inv or getdata message are
+	// looked up
+	MaxInvPerMessage = 1000
+)
+
+// VersionPayload is exchanged in the version handshake
+type VersionPayload struct {
+	ProtocolVersion int32
+	UserAgent       string
+	Address         string
+	BlockType       blockchain.BlockType
+	// Height is the sender's chain height, which tells the receiver
+	// whether it has caught up with the network
+	Height int64
+	// PublicKey is the sender's node identity key
+	PublicKey []byte
+	// Challenge is a nonce the receiver must sign to prove it holds its key
+	Challenge []byte
+	// Signature answers the receiver's challenge when the sender already
+	// knows it, which is the case for the reply to an inbound version
+	Signature []byte
+}
+
+// VerAckPayload carries the outbound side's answer to the challenge in the
+// reply to its version
+type VerAckPayload struct {
+	Signature []byte
+}
+
+// RejectPayload explains why a peer is being disconnected or why a block or
+// transaction it sent was refused
+type RejectPayload struct {
+	// Message is the type of the message refused
+	Message MessageType
+	// Reason is a human readable explanation
+	Reason string
+	// Hash is the refused block or transaction, and Code why it was refused;
+	// both are empty for handshake rejections
+	Hash []byte
+	Code blockchain.RejectReason
+}
+
 // Message represents a network message
 type Message struct {
 	Type    MessageType
@@ -64,31 +126,141 @@ type Node struct {
 	server     net.Listener
 	mu         sync.RWMutex
 	isMining   bool
+	invWaiters map[string][]chan struct{}
+	invMu      sync.Mutex
+	quit       chan struct{}
+	dial       func(network, address string) (net.Conn, error)
+	identity   *ecdsa.PrivateKey
+	auth       *security.PeerAuth
+	authOnce   sync.Once
+	limiter    *security.PeerLimiter
+	limitOnce  sync.Once
+	// discovery is the peer registry the node reports its peers to
+	discovery *DiscoveryManager
+	rand      *peerRand
+	randOnce  sync.Once
+	// blockWaiters are the RequestBlock calls awaiting each block hash
+	blockWaiters map[string][]chan *blockchain.Block
+	blockMu      sync.Mutex
+	// propagation times the blocks relayed to the node
+	propagation propagationTracker
+	// miningChain is the chain the mining loop extends while mining
+	miningChain blockchain.BlockType
+	// miningStop is closed by StopMining to abandon the block being mined;
+	// miningDone is closed once the mining loop has returned
+	miningStop chan struct{}
+	miningDone chan struct{}
+	// workers counts the goroutines serving peer connections, for Shutdown
+	// to drain; once closing is set no new ones start
+	workers  sync.WaitGroup
+	workerMu sync.Mutex
+	closing  bool
 }
 
 // Peer represents a network peer
 type Peer struct {
-	ID          string
-	Address     string
-	LastSeen    time.Time
-	Latency     time.Duration
-	Version     string
-	IsActive    bool
-	IsBootstrap bool
-	conn        net.Conn
-	Node        *Node
-	handlers    map[MessageType]MessageHandler
-	Height      int64
-	mu          sync.RWMutex
+	ID              string
+	Address         string
+	LastSeen        time.Time
+	Latency         time.Duration
+	Version         string
+	UserAgent       string
+	ProtocolVersion int32
+	IsActive        bool
+	IsBootstrap     bool
+	// BlockType is the chain the peer said it serves in its version; empty
+	// when it serves both or has not said
+	BlockType     blockchain.BlockType
+	conn          net.Conn
+	Node          *Node
+	handlers      map[MessageType]MessageHandler
+	Height        int64
+	versionSent   bool
+	addrLimiter   *TokenBucket
+	serveLimiter  *TokenBucket
+	validBlocks   int
+	invalidBlocks int
+	// PublicKey is the identity key the peer advertised; messages other than
+	// the handshake are only accepted once it has proved it holds the key
+	PublicKey     []byte
+	authenticated bool
+	peerChallenge []byte
+	// limitAddr is the address holding a connection slot, empty once released
+	limitAddr string
+	// outbound is true for peers the node dialed
+	outbound bool
+	traffic  peerTraffic
+	// in buffers reads from inConn across messages; see reader
+	in     *bufio.Reader
+	inConn net.Conn
+	// writeMu serializes writes to conn so messages are never interleaved
+	writeMu sync.Mutex
+	mu      sync.RWMutex
 }
 
 // Config represents the node configuration
 type Config struct {
-	Address        string
+	Address string
+	// BlockType is the chain the node serves to peers and advertises in its
+	// version; empty serves both. Change it on a running node with
+	// SetBlockType, which tells connected peers.
 	BlockType      blockchain.BlockType
 	BootstrapPeers []string
+
+	// Blockchain is the chain the node serves and extends; nil starts the
+	// node on a fresh chain
+	Blockchain *blockchain.Blockchain
+
+	// ConnectRetries is the number of dial attempts per peer; 0 uses DefaultConnectRetries
+	ConnectRetries int
+	// ConnectBaseDelay is the wait before the first retry, doubled on each attempt; 0 uses DefaultConnectBaseDelay
+	ConnectBaseDelay time.Duration
+
+	// DataDir holds the persisted node identity; when empty a throwaway identity is used
+	DataDir string
+	// IdentityPassphrase encrypts the node identity on disk
+	IdentityPassphrase string
+
+	// UserAgent is advertised to peers; empty uses DefaultUserAgent
+	UserAgent string
+	// MinProtocolVersion rejects peers advertising an older version; 0 uses DefaultMinProtocolVersion
+	MinProtocolVersion int32
+
+	// RelayConcurrency caps how many peers a block is sent to at once; 0 uses DefaultRelayConcurrency
+	RelayConcurrency int
+	// TxRelayFanout is how many randomly chosen peers a transaction is relayed to; 0 relays to all
+	TxRelayFanout int
+	// BlockServeChunk is how many blocks are sent per message when serving the chain; 0 uses DefaultBlockServeChunk
+	BlockServeChunk int
+	// BlockServeRate is how many chunks per second are served to one peer; 0 uses DefaultBlockServeRate
+	BlockServeRate float64
+
+	// MaxConnections caps inbound and outbound peer connections together; 0 uses security.DefaultMaxConnections
+	MaxConnections int
+	// MaxConnectionsPerIP caps the connections to or from one address; 0 uses security.DefaultMaxConnectionsPerIP
+	MaxConnectionsPerIP int
+	// MaxOutboundPerNetGroup caps the outbound connections into one /16
+	// (IPv4) or /32 (IPv6) network; 0 uses security.DefaultMaxOutboundPerNetGroup
+	MaxOutboundPerNetGroup int
+
+	// MiningAddress is paid the reward of each block the node mines; empty
+	// pays the node's identity key, which only persists with a DataDir
+	MiningAddress string
+
+	// Magic starts every message frame; peers sending another magic are
+	// dropped. Zero uses the magic of the chain's network mode
+	Magic NetworkMagic
 }
 
+const (
+	// DefaultConnectRetries is the default number of dial attempts per peer
+	DefaultConnectRetries = 5
+	// DefaultConnectBaseDelay is the default wait before the first dial retry
+	DefaultConnectBaseDelay = time.Second
+	// MaxConnectDelay caps the backoff between dial attempts
+	MaxConnectDelay = time.Minute
+)
+
 // MessageHandler is a function that handles a message
 type MessageHandler func(*Peer, []byte) error
 
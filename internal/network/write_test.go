@@ -0,0 +1,103 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSendsToOnePeerDoNotInterleave(t *testing.T) {
+	node := newRelayTestNode(t, 1)
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	peer := NewPeer("receiver", "receiver", 0)
+	peer.conn = local
+	node.Peers["receiver"] = peer
+
+	const senders, perSender = 8, 50
+	want := make(map[string]bool, senders*perSender)
+	for s := 0; s < senders; s++ {
+		for i := 0; i < perSender; i++ {
+			want[fmt.Sprintf("sender %d message %d", s, i)] = true
+		}
+	}
+
+	var wg sync.WaitGroup
+	for s := 0; s < senders; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			for i := 0; i < perSender; i++ {
+				payload := []byte(fmt.Sprintf("sender %d message %d", s, i))
+				var err error
+				if i%2 == 0 {
+					err = node.sendEncoded(peer, MessageTypeTx, payload)
+				} else {
+					err = peer.sendMessage(NetworkMessage{Type: MessageTypeTx, Payload: payload})
+				}
+				if err != nil {
+					t.Errorf("Sender %d failed to send: %v", s, err)
+					return
+				}
+			}
+		}(s)
+	}
+
+	reader := NewPeer("sender", "sender", 0)
+	reader.conn = remote
+	for received := 0; received < senders*perSender; received++ {
+		msg, err := node.receiveMessage(reader)
+		if err != nil {
+			t.Fatalf("Failed to decode message %d: %v", received, err)
+		}
+		payload := string(msg.Payload)
+		if !want[payload] {
+			t.Fatalf("Received unexpected or duplicate payload %q", payload)
+		}
+		delete(want, payload)
+	}
+	wg.Wait()
+
+	if len(want) != 0 {
+		t.Errorf("%d messages were not received", len(want))
+	}
+}
+
+func TestBackToBackMessagesDecodeSeparately(t *testing.T) {
+	node := newRelayTestNode(t, 1)
+
+	// Two messages arriving in a single read
+	var wire bytes.Buffer
+	for _, payload := range []string{"first", "second"} {
+		msg := NetworkMessage{Type: MessageTypePing, Payload: []byte(payload)}
+		if err := writeTestFrame(&wire, msg); err != nil {
+			t.Fatalf("Failed to encode message: %v", err)
+		}
+	}
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	go func() {
+		remote.Write(wire.Bytes())
+		remote.Close()
+	}()
+
+	peer := NewPeer("sender", "sender", 0)
+	peer.conn = local
+	for _, want := range []string{"first", "second"} {
+		msg, err := node.receiveMessage(peer)
+		if err != nil {
+			t.Fatalf("Failed to decode %q message: %v", want, err)
+		}
+		if string(msg.Payload) != want {
+			t.Errorf("Decoded payload %q, want %q", msg.Payload, want)
+		}
+	}
+	if got := peer.Status().BytesReceived; got != uint64(wire.Len()) {
+		t.Errorf("BytesReceived = %d, want %d", got, wire.Len())
+	}
+}
@@ -0,0 +1,133 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// NodeKeyFile is the name of the persisted node identity inside a data directory
+const NodeKeyFile = "node_key.json"
+
+// encryptedNodeKey is the on-disk format of a node identity key
+type encryptedNodeKey struct {
+	Salt       []byte `json:"salt"`
+	Ciphertext []byte `json:"ciphertext"`
+	PublicKey  []byte `json:"public_key"`
+}
+
+// LoadOrCreateNodeKey loads the node identity from dataDir, generating and
+// saving a new one on first run
+func LoadOrCreateNodeKey(dataDir, passphrase string) (*ecdsa.PrivateKey, error) {
+	path := filepath.Join(dataDir, NodeKeyFile)
+
+	key, err := LoadNodeKey(path, passphrase)
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key, err = GenerateKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate node key: %v", err)
+	}
+	if err := SaveNodeKey(path, key, passphrase); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// SaveNodeKey encrypts the node identity with passphrase and writes it to path
+func SaveNodeKey(path string, key *ecdsa.PrivateKey, passphrase string) error {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal node key: %v", err)
+	}
+
+	salt := make([]byte, SaltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	gcm, err := newNodeKeyCipher(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	data, err := json.MarshalIndent(encryptedNodeKey{
+		Salt:       salt,
+		Ciphertext: gcm.Seal(nonce, nonce, der, nil),
+		PublicKey:  elliptic.Marshal(key.Curve, key.X, key.Y),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode node key: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write node key: %v", err)
+	}
+	return nil
+}
+
+// LoadNodeKey reads and decrypts a node identity written by SaveNodeKey
+func LoadNodeKey(path, passphrase string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored encryptedNodeKey
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode node key: %v", err)
+	}
+
+	gcm, err := newNodeKeyCipher(passphrase, stored.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored.Ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("node key ciphertext too short")
+	}
+	nonce := stored.Ciphertext[:gcm.NonceSize()]
+	der, err := gcm.Open(nil, nonce, stored.Ciphertext[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt node key: wrong passphrase or corrupt file")
+	}
+
+	key, err := x509.ParseECPrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse node key: %v", err)
+	}
+	return key, nil
+}
+
+// newNodeKeyCipher derives the AES-GCM cipher protecting the node key
+func newNodeKeyCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(DeriveKey(passphrase, salt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %v", err)
+	}
+	return gcm, nil
+}
@@ -0,0 +1,109 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ChallengeSize is the length of the random nonce a peer must sign
+const ChallengeSize = 32
+
+// challengeDomain separates peer authentication signatures from any other
+// use of the node key
+const challengeDomain = "byc-peer-auth:"
+
+// PeerAuth issues challenges to peers and checks that each one is answered
+// with a signature from the private key of the identity the peer advertises.
+// The signature covers the nonce and both peers' identity keys, so an answer
+// relayed by a man in the middle does not verify for the peer it was relayed to.
+type PeerAuth struct {
+	mu      sync.Mutex
+	pending map[string][]byte
+}
+
+// NewPeerAuth creates a new peer authenticator
+func NewPeerAuth() *PeerAuth {
+	return &PeerAuth{pending: make(map[string][]byte)}
+}
+
+// Challenge returns a fresh nonce for peerID to sign, replacing any
+// outstanding one
+func (pa *PeerAuth) Challenge(peerID string) ([]byte, error) {
+	nonce := make([]byte, ChallengeSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %v", err)
+	}
+
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	pa.pending[peerID] = nonce
+	return nonce, nil
+}
+
+// Verify checks that signature answers the challenge issued to peerID and was
+// made by the key whose public half is publicKey, for the challenger whose
+// identity key is localKey. A challenge can only be answered once, whether or
+// not the answer is valid.
+func (pa *PeerAuth) Verify(peerID string, publicKey, localKey, signature []byte) error {
+	pa.mu.Lock()
+	nonce, ok := pa.pending[peerID]
+	delete(pa.pending, peerID)
+	pa.mu.Unlock()
+
+	if !ok {
+		return errors.New("no challenge outstanding for peer")
+	}
+	return VerifyChallenge(publicKey, localKey, nonce, signature)
+}
+
+// Forget drops the outstanding challenge of a disconnected peer
+func (pa *PeerAuth) Forget(peerID string) {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	delete(pa.pending, peerID)
+}
+
+// SignChallenge answers a challenge from the peer whose identity key is
+// challengerKey with the node key
+func SignChallenge(key *ecdsa.PrivateKey, challengerKey, nonce []byte) ([]byte, error) {
+	hash := challengeHash(elliptic.Marshal(elliptic.P256(), key.X, key.Y), challengerKey, nonce)
+	return ecdsa.SignASN1(rand.Reader, key, hash[:])
+}
+
+// VerifyChallenge checks that signature answers nonce, issued by the peer
+// whose identity key is challengerKey, and was made with the key whose
+// uncompressed P-256 public half is publicKey
+func VerifyChallenge(publicKey, challengerKey, nonce, signature []byte) error {
+	x, y := elliptic.Unmarshal(elliptic.P256(), publicKey)
+	if x == nil {
+		return errors.New("invalid node public key")
+	}
+	hash := challengeHash(publicKey, challengerKey, nonce)
+	if !ecdsa.VerifyASN1(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, hash[:], signature) {
+		return errors.New("challenge signature does not match the advertised key")
+	}
+	return nil
+}
+
+// challengeHash hashes the domain, then the signer's key, the challenger's key
+// and the nonce, each prefixed with its length so no two inputs collide
+func challengeHash(signerKey, challengerKey, nonce []byte) [32]byte {
+	h := sha256.New()
+	h.Write([]byte(challengeDomain))
+	for _, field := range [][]byte{signerKey, challengerKey, nonce} {
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(field)))
+		h.Write(size[:])
+		h.Write(field)
+	}
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
@@ -0,0 +1,180 @@
+package security
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+const (
+	// DefaultMaxConnections caps the peer connections a node holds at once
+	DefaultMaxConnections = 100
+	// DefaultMaxConnectionsPerIP caps the peer connections from one address
+	DefaultMaxConnectionsPerIP = 10
+	// DefaultMaxOutboundPerNetGroup caps the outbound connections into one
+	// network group; see NetGroup
+	DefaultMaxOutboundPerNetGroup = 2
+)
+
+var (
+	// ErrConnectionLimit is returned when the node already holds MaxConnections peers
+	ErrConnectionLimit = errors.New("connection limit reached")
+	// ErrConnectionLimitPerIP is returned when an address already holds MaxConnectionsPerIP connections
+	ErrConnectionLimitPerIP = errors.New("per-IP connection limit reached")
+	// ErrConnectionLimitPerNetGroup is returned when a network group already holds MaxOutboundPerNetGroup outbound connections
+	ErrConnectionLimitPerNetGroup = errors.New("per-network-group outbound connection limit reached")
+)
+
+// PeerLimiter is the single count of a node's peer connections, inbound and
+// outbound, checked against a total and a per-IP cap. Outbound connections
+// are also capped per network group, so an attacker advertising many
+// addresses in one subnet cannot take every outbound slot and eclipse the
+// node.
+type PeerLimiter struct {
+	mu          sync.Mutex
+	maxTotal    int
+	maxPerIP    int
+	maxPerGroup int
+	total       int
+	perIP       map[string]int
+	perGroup    map[string]int
+	// outbound counts the slots each address holds through AcquireOutbound
+	outbound map[string]int
+}
+
+// NewPeerLimiter creates a limiter; a cap of 0 or less uses its default
+func NewPeerLimiter(maxConnections, maxConnectionsPerIP int) *PeerLimiter {
+	if maxConnections <= 0 {
+		maxConnections = DefaultMaxConnections
+	}
+	if maxConnectionsPerIP <= 0 {
+		maxConnectionsPerIP = DefaultMaxConnectionsPerIP
+	}
+	return &PeerLimiter{
+		maxTotal:    maxConnections,
+		maxPerIP:    maxConnectionsPerIP,
+		maxPerGroup: DefaultMaxOutboundPerNetGroup,
+		perIP:       make(map[string]int),
+		perGroup:    make(map[string]int),
+		outbound:    make(map[string]int),
+	}
+}
+
+// SetMaxOutboundPerNetGroup changes the cap on outbound connections into one
+// network group; 0 or less uses DefaultMaxOutboundPerNetGroup
+func (l *PeerLimiter) SetMaxOutboundPerNetGroup(n int) {
+	if n <= 0 {
+		n = DefaultMaxOutboundPerNetGroup
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxPerGroup = n
+}
+
+// Acquire reserves a connection slot for addr, a host:port or bare host. Every
+// successful call must be matched by a Release of the same address.
+func (l *PeerLimiter) Acquire(addr string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.acquire(hostOf(addr))
+}
+
+// AcquireOutbound reserves a slot for a connection the node opens to addr,
+// which must also fit within its network group's outbound cap. The slot is
+// freed by Release like any other.
+func (l *PeerLimiter) AcquireOutbound(addr string) error {
+	group := NetGroup(addr)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if group != "" && l.perGroup[group] >= l.maxPerGroup {
+		return ErrConnectionLimitPerNetGroup
+	}
+	if err := l.acquire(hostOf(addr)); err != nil {
+		return err
+	}
+	if group != "" {
+		l.perGroup[group]++
+	}
+	l.outbound[addr]++
+	return nil
+}
+
+// acquire takes a slot for ip within the total and per-IP caps. Callers
+// must hold l.mu.
+func (l *PeerLimiter) acquire(ip string) error {
+	if l.total >= l.maxTotal {
+		return ErrConnectionLimit
+	}
+	if l.perIP[ip] >= l.maxPerIP {
+		return ErrConnectionLimitPerIP
+	}
+	l.total++
+	l.perIP[ip]++
+	return nil
+}
+
+// Release frees a slot taken by Acquire
+func (l *PeerLimiter) Release(addr string) {
+	ip := hostOf(addr)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.perIP[ip] == 0 {
+		return
+	}
+	l.total--
+	if l.perIP[ip]--; l.perIP[ip] == 0 {
+		delete(l.perIP, ip)
+	}
+	if l.outbound[addr] > 0 {
+		if l.outbound[addr]--; l.outbound[addr] == 0 {
+			delete(l.outbound, addr)
+		}
+		if group := NetGroup(addr); group != "" {
+			if l.perGroup[group]--; l.perGroup[group] <= 0 {
+				delete(l.perGroup, group)
+			}
+		}
+	}
+}
+
+// Count returns the number of connection slots in use
+func (l *PeerLimiter) Count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total
+}
+
+// Limits returns the total and per-IP caps
+func (l *PeerLimiter) Limits() (maxConnections, maxConnectionsPerIP int) {
+	return l.maxTotal, l.maxPerIP
+}
+
+// NetGroup returns the network group of addr, a host:port or bare host:
+// the /16 of an IPv4 address or the /32 of an IPv6 one. Addresses in one
+// group are likely run by one operator. Hostnames are each their own group,
+// and loopback addresses are in none, so local test networks are not capped.
+func NetGroup(addr string) string {
+	host := hostOf(addr)
+	ip := net.ParseIP(host)
+	switch {
+	case host == "localhost" || (ip != nil && ip.IsLoopback()):
+		return ""
+	case ip == nil:
+		return host
+	case ip.To4() != nil:
+		return ip.Mask(net.CIDRMask(16, 32)).String() + "/16"
+	default:
+		return ip.Mask(net.CIDRMask(32, 128)).String() + "/32"
+	}
+}
+
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -19,7 +19,7 @@ type SecurityManager struct {
 	rateLimitMu      sync.RWMutex
 
 	// Connection limits
-	maxPeers        int
+	peerLimiter     *PeerLimiter
 	peerConnections map[string]time.Time // peer address -> connection time
 	connectionMu    sync.RWMutex
 
@@ -45,7 +45,7 @@ func NewSecurityManager() *SecurityManager {
 		apiRateLimiters:         make(map[string]*rate.Limiter),
 		peerRateLimiters:        make(map[string]*rate.Limiter),
 		peerConnections:         make(map[string]time.Time),
-		maxPeers:                50,          // Default max peers
+		peerLimiter:             NewPeerLimiter(DefaultMaxConnections, DefaultMaxConnectionsPerIP),
 		maxBlockSize:            1024 * 1024, // 1MB default
 		maxTransactionsPerBlock: 1000,
 		minTransactionFee:       1000.0,
@@ -116,21 +116,31 @@ func (s *SecurityManager) CheckPeerRateLimit(peerAddr string) error {
 
 // Connection Limits
 
+// SetPeerLimiter makes peer connections count against limiter, so the
+// manager enforces the same caps as the node sharing it. It must be called
+// before any connection is checked.
+func (s *SecurityManager) SetPeerLimiter(limiter *PeerLimiter) {
+	s.connectionMu.Lock()
+	defer s.connectionMu.Unlock()
+
+	s.peerLimiter = limiter
+}
+
 // CheckPeerConnection checks if a new peer connection is allowed
 func (s *SecurityManager) CheckPeerConnection(peerAddr string) error {
 	s.connectionMu.Lock()
 	defer s.connectionMu.Unlock()
 
-	// Check if we've reached the maximum number of peers
-	if len(s.peerConnections) >= s.maxPeers {
-		return fmt.Errorf("maximum number of peers reached")
-	}
-
 	// Check if this peer is already connected
 	if _, exists := s.peerConnections[peerAddr]; exists {
 		return fmt.Errorf("peer already connected: %s", peerAddr)
 	}
 
+	// Check the total and per-IP connection limits
+	if err := s.peerLimiter.Acquire(peerAddr); err != nil {
+		return err
+	}
+
 	// Add the new peer connection
 	s.peerConnections[peerAddr] = time.Now()
 	return nil
@@ -141,7 +151,10 @@ func (s *SecurityManager) RemovePeerConnection(peerAddr string) {
 	s.connectionMu.Lock()
 	defer s.connectionMu.Unlock()
 
-	delete(s.peerConnections, peerAddr)
+	if _, exists := s.peerConnections[peerAddr]; exists {
+		s.peerLimiter.Release(peerAddr)
+		delete(s.peerConnections, peerAddr)
+	}
 }
 
 // Block Validation
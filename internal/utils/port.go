@@ -3,6 +3,8 @@ package utils
 import (
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 )
 
 // FindAvailablePort finds an available port starting from the given port
@@ -21,19 +23,87 @@ func FindAvailablePort(startPort int) (int, error) {
 // ParseAddress extracts the host and port from an address string
 func ParseAddress(address string) (string, int, error) {
 	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid address %q: %v", address, err)
+	}
+
+	port, err := parsePort(portStr)
 	if err != nil {
 		return "", 0, err
 	}
 
-	port := 0
-	fmt.Sscanf(portStr, "%d", &port)
-	if port == 0 {
-		return "", 0, fmt.Errorf("invalid port number: %s", portStr)
+	return host, port, nil
+}
+
+// ParseAddressWithDefaults parses a host:port address, filling in defaultHost
+// and defaultPort for whatever part is missing. It accepts "host:port",
+// "[ipv6]:port", a bare host or IPv6 literal, and a bare or ":"-prefixed port.
+func ParseAddressWithDefaults(address, defaultHost string, defaultPort int) (string, int, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return defaultHost, defaultPort, nil
+	}
+
+	// Port only, e.g. "3000"
+	if _, err := strconv.Atoi(address); err == nil {
+		port, err := parsePort(address)
+		if err != nil {
+			return "", 0, err
+		}
+		return defaultHost, port, nil
+	}
+
+	// Bare IPv6 literal, with or without brackets, e.g. "::1" or "[::1]"
+	bare := address
+	if strings.HasPrefix(bare, "[") && strings.HasSuffix(bare, "]") {
+		bare = bare[1 : len(bare)-1]
+	}
+	if ip := net.ParseIP(bare); ip != nil && ip.To4() == nil {
+		return bare, defaultPort, nil
+	}
+
+	// Bare host name or IPv4 address
+	if !strings.Contains(address, ":") {
+		return address, defaultPort, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid address %q: %v", address, err)
+	}
+	if portStr == "" {
+		return "", 0, fmt.Errorf("invalid address %q: missing port after ':'", address)
+	}
+	port, err := parsePort(portStr)
+	if err != nil {
+		return "", 0, err
+	}
+	if host == "" {
+		host = defaultHost
 	}
 
 	return host, port, nil
 }
 
+// NormalizeAddress parses address with ParseAddressWithDefaults and returns it
+// in canonical host:port form, bracketing IPv6 hosts.
+func NormalizeAddress(address, defaultHost string, defaultPort int) (string, error) {
+	host, port, err := ParseAddressWithDefaults(address, defaultHost, defaultPort)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// parsePort converts a port string to a number in the range 1-65535
+func parsePort(portStr string) (int, error) {
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port number: %s", portStr)
+	}
+	return port, nil
+}
+
 // FindAvailableAddress finds an available address by trying ports starting from the given address
 func FindAvailableAddress(address string) (string, error) {
 	host, port, err := ParseAddress(address)
@@ -56,5 +126,5 @@ func FindAvailableAddress(address string) (string, error) {
 		return "", err
 	}
 
-	return fmt.Sprintf("%s:%d", host, availablePort), nil
+	return net.JoinHostPort(host, strconv.Itoa(availablePort)), nil
 }
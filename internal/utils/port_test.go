@@ -0,0 +1,73 @@
+package utils
+
+import "testing"
+
+func TestParseAddressWithDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		host    string
+		port    int
+	}{
+		{"ipv4 with port", "127.0.0.1:3001", "127.0.0.1", 3001},
+		{"hostname with port", "node.example.com:4000", "node.example.com", 4000},
+		{"ipv6 with port", "[::1]:3002", "::1", 3002},
+		{"bare hostname", "node.example.com", "node.example.com", 3000},
+		{"bare ipv4", "10.0.0.1", "10.0.0.1", 3000},
+		{"bare ipv6", "::1", "::1", 3000},
+		{"bracketed ipv6", "[fe80::1]", "fe80::1", 3000},
+		{"port only", "3005", "localhost", 3005},
+		{"colon port", ":3006", "localhost", 3006},
+		{"empty", "", "localhost", 3000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port, err := ParseAddressWithDefaults(tt.address, "localhost", 3000)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if host != tt.host || port != tt.port {
+				t.Errorf("got %s %d, want %s %d", host, port, tt.host, tt.port)
+			}
+		})
+	}
+}
+
+func TestParseAddressWithDefaultsInvalid(t *testing.T) {
+	invalid := []string{
+		"localhost:",
+		"localhost:abc",
+		"localhost:70000",
+		"0",
+		"[::1]:",
+		"[::1",
+		"a:b:c",
+	}
+
+	for _, address := range invalid {
+		if _, _, err := ParseAddressWithDefaults(address, "localhost", 3000); err == nil {
+			t.Errorf("expected error for %q", address)
+		}
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := map[string]string{
+		"3000":           "localhost:3000",
+		"::1":            "[::1]:3000",
+		"[::1]:3001":     "[::1]:3001",
+		"example.com":    "example.com:3000",
+		"127.0.0.1:3002": "127.0.0.1:3002",
+	}
+
+	for address, want := range tests {
+		got, err := NormalizeAddress(address, "localhost", 3000)
+		if err != nil {
+			t.Fatalf("NormalizeAddress(%q): %v", address, err)
+		}
+		if got != want {
+			t.Errorf("NormalizeAddress(%q) = %s, want %s", address, got, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
+
+	"byc/internal/crypto"
+)
+
+// AddressType selects how an address commits to its key
+type AddressType int
+
+const (
+	// AddressTypeP2PKH hashes the uncompressed public key; it is the
+	// wallet's original format and is written as bare hex
+	AddressTypeP2PKH AddressType = iota
+	// AddressTypeP2WPKH hashes the compressed public key, for outputs spent
+	// by SegWit inputs
+	AddressTypeP2WPKH
+	// AddressTypeP2TR is a key-path Taproot output key
+	AddressTypeP2TR
+)
+
+// String returns the name of the address type
+func (t AddressType) String() string {
+	switch t {
+	case AddressTypeP2PKH:
+		return "p2pkh"
+	case AddressTypeP2WPKH:
+		return "p2wpkh"
+	case AddressTypeP2TR:
+		return "p2tr"
+	default:
+		return "unknown"
+	}
+}
+
+// witnessVersion returns the witness version addresses of type t are
+// encoded with
+func (t AddressType) witnessVersion() int {
+	switch t {
+	case AddressTypeP2WPKH:
+		return 0
+	case AddressTypeP2TR:
+		return 1
+	default:
+		return crypto.LegacyAddressVersion
+	}
+}
+
+// Address is a decoded address: its type and the 32 bytes it commits to
+type Address struct {
+	Type AddressType
+	Hash []byte
+}
+
+// String encodes the address
+func (a *Address) String() string {
+	return crypto.EncodeAddress(a.Type.witnessVersion(), a.Hash)
+}
+
+// newAddress returns the address of type t for publicKey
+func newAddress(publicKey *ecdsa.PublicKey, t AddressType) (*Address, error) {
+	switch t {
+	case AddressTypeP2PKH:
+		hash := sha256.Sum256(elliptic.Marshal(publicKey.Curve, publicKey.X, publicKey.Y))
+		return &Address{Type: t, Hash: hash[:]}, nil
+	case AddressTypeP2WPKH:
+		hash := sha256.Sum256(elliptic.MarshalCompressed(publicKey.Curve, publicKey.X, publicKey.Y))
+		return &Address{Type: t, Hash: hash[:]}, nil
+	case AddressTypeP2TR:
+		outputKey, err := crypto.TaprootOutputKey(publicKey, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &Address{Type: t, Hash: outputKey}, nil
+	default:
+		return nil, fmt.Errorf("unknown address type %d", t)
+	}
+}
+
+// ParseAddress parses an address of any type, rejecting strings that are
+// not a well-formed address
+func ParseAddress(s string) (*Address, error) {
+	return decodeAddress(s)
+}
+
+// decodeAddress parses an address of any type
+func decodeAddress(s string) (*Address, error) {
+	version, hash, err := crypto.DecodeAddress(s)
+	if err != nil {
+		return nil, err
+	}
+	switch version {
+	case 0:
+		return &Address{Type: AddressTypeP2WPKH, Hash: hash}, nil
+	case 1:
+		return &Address{Type: AddressTypeP2TR, Hash: hash}, nil
+	default:
+		return &Address{Type: AddressTypeP2PKH, Hash: hash}, nil
+	}
+}
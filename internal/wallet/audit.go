@@ -0,0 +1,124 @@
+package wallet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Audited operations
+const (
+	AuditGenerateKey    = "generate_key"
+	AuditDeriveKey      = "derive_key"
+	AuditSign           = "sign"
+	AuditSignTx         = "sign_transaction"
+	AuditVerify         = "verify"
+	AuditEncryptWallet  = "encrypt_wallet"
+	AuditDecryptWallet  = "decrypt_wallet"
+	AuditCloseWallet    = "close_wallet"
+	auditOutcomeSuccess = "success"
+	auditOutcomeFailure = "failure"
+)
+
+// AuditEntry records one signing, verification or key operation for
+// security review. It names the account and address involved, never a key.
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Operation string    `json:"operation"`
+	Account   uint32    `json:"account"`
+	Address   string    `json:"address,omitempty"`
+	// Outcome is "success" or "failure"; Error says why a failure failed
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AuditSink receives audit entries. Record is called for every audited
+// operation, from any goroutine, and must only ever append.
+type AuditSink interface {
+	Record(entry AuditEntry) error
+}
+
+var (
+	auditMu   sync.RWMutex
+	auditSink AuditSink
+)
+
+// SetAuditSink sends the audit entries of every wallet to sink; nil, the
+// default, stops auditing
+func SetAuditSink(sink AuditSink) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditSink = sink
+}
+
+// audit records the outcome of op on account, err being nil on success. A
+// sink failing to record is reported on stderr rather than failing op.
+func audit(op string, account uint32, address string, err error) {
+	auditMu.RLock()
+	sink := auditSink
+	auditMu.RUnlock()
+	if sink == nil {
+		return
+	}
+
+	entry := AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Operation: op,
+		Account:   account,
+		Address:   address,
+		Outcome:   auditOutcomeSuccess,
+	}
+	if err != nil {
+		entry.Outcome = auditOutcomeFailure
+		entry.Error = err.Error()
+	}
+	if err := sink.Record(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to record %s audit entry: %v\n", op, err)
+	}
+}
+
+// JSONAuditLog is an AuditSink appending each entry as one line of JSON
+type JSONAuditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONAuditLog returns a sink writing entries to w
+func NewJSONAuditLog(w io.Writer) *JSONAuditLog {
+	return &JSONAuditLog{w: w}
+}
+
+// OpenAuditLog returns a sink appending entries to the file at path,
+// creating it readable only by its owner
+func OpenAuditLog(path string) (*JSONAuditLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	return NewJSONAuditLog(f), nil
+}
+
+// Record appends entry to the log
+func (l *JSONAuditLog) Record(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(data, '\n'))
+	return err
+}
+
+// Close closes the file an OpenAuditLog sink writes to
+func (l *JSONAuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
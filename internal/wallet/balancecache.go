@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"sync"
+
+	"byc/internal/blockchain"
+)
+
+// balanceCache holds the balances last computed from a chain until the chain
+// reports a block connected or disconnected. Changes made to the chain without
+// connecting a block, such as writing the UTXO set directly, are not seen
+// until the next block event.
+type balanceCache struct {
+	mu          sync.Mutex
+	chain       *blockchain.Blockchain
+	events      <-chan blockchain.BlockEvent
+	unsubscribe func()
+	// generation advances on every invalidation so a balance computed
+	// across one is not stored
+	generation uint64
+	byCoin     map[blockchain.CoinType]float64
+	all        map[blockchain.CoinType]float64
+}
+
+// sync attaches the cache to bc, subscribing to its block events, and drops
+// the cached balances if any event arrived. Callers must hold c.mu.
+func (c *balanceCache) sync(bc *blockchain.Blockchain) {
+	if c.chain != bc {
+		if c.unsubscribe != nil {
+			c.unsubscribe()
+		}
+		c.chain = bc
+		c.events, c.unsubscribe = bc.SubscribeBlocks()
+		c.invalidate()
+		return
+	}
+
+	for {
+		select {
+		case <-c.events:
+			c.invalidate()
+		default:
+			return
+		}
+	}
+}
+
+// invalidate drops the cached balances. Callers must hold c.mu.
+func (c *balanceCache) invalidate() {
+	c.generation++
+	c.byCoin = make(map[blockchain.CoinType]float64)
+	c.all = nil
+}
+
+// reset drops the cached balances, for when the wallet's addresses change
+func (c *balanceCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidate()
+}
+
+// balance returns the cached balance of coinType on bc, or the generation to
+// pass to storeBalance once it has been computed
+func (c *balanceCache) balance(bc *blockchain.Blockchain, coinType blockchain.CoinType) (float64, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sync(bc)
+	balance, ok := c.byCoin[coinType]
+	return balance, c.generation, ok
+}
+
+// storeBalance caches a balance computed at generation
+func (c *balanceCache) storeBalance(generation uint64, coinType blockchain.CoinType, balance float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.byCoin[coinType] = balance
+	}
+}
+
+// allBalances returns a copy of the cached balances of every coin on bc, or
+// the generation to pass to storeAllBalances once they have been computed
+func (c *balanceCache) allBalances(bc *blockchain.Blockchain) (map[blockchain.CoinType]float64, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sync(bc)
+	if c.all == nil {
+		return nil, c.generation, false
+	}
+	return copyBalances(c.all), c.generation, true
+}
+
+// storeAllBalances caches the balances of every coin computed at generation
+func (c *balanceCache) storeAllBalances(generation uint64, balances map[blockchain.CoinType]float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.all = copyBalances(balances)
+	}
+}
+
+func copyBalances(balances map[blockchain.CoinType]float64) map[blockchain.CoinType]float64 {
+	copied := make(map[blockchain.CoinType]float64, len(balances))
+	for coinType, amount := range balances {
+		copied[coinType] = amount
+	}
+	return copied
+}
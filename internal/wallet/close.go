@@ -0,0 +1,72 @@
+package wallet
+
+import (
+	"errors"
+	"math/big"
+)
+
+// ErrWalletClosed is returned by operations needing a private key once the
+// wallet has been closed
+var ErrWalletClosed = errors.New("wallet is closed")
+
+// Close wipes the wallet's private key material from memory rather than
+// leaving it for the garbage collector: the primary private key, the key
+// encrypted at rest, and the HD mnemonic, seed, master key and child keys.
+// The wallet can no longer sign or derive keys afterward; those operations
+// return ErrWalletClosed. Closing a closed wallet does nothing.
+func (w *Wallet) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed.Swap(true) {
+		return nil
+	}
+	if w.PrivateKey != nil {
+		wipeInt(w.PrivateKey.D)
+		w.PrivateKey = nil
+	}
+	wipeBytes(w.EncryptedKey)
+	w.EncryptedKey = nil
+
+	if hd := w.HDWallet; hd != nil {
+		hd.mu.Lock()
+		wipeBytes(hd.Seed)
+		wipeBytes(hd.MasterKey)
+		for _, key := range hd.ChildKeys {
+			wipeBytes(key)
+		}
+		// Strings are immutable, so the mnemonic can only be dropped
+		hd.Mnemonic = ""
+		hd.Seed, hd.MasterKey, hd.ChildKeys = nil, nil, nil
+		hd.mu.Unlock()
+	}
+	audit(AuditCloseWallet, DefaultAccount, w.Address, nil)
+	return nil
+}
+
+// checkOpen returns ErrWalletClosed once the wallet has been closed
+func (w *Wallet) checkOpen() error {
+	if w.closed.Load() {
+		return ErrWalletClosed
+	}
+	return nil
+}
+
+// wipeBytes zeroes b in place
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// wipeInt zeroes the words backing n in place, then sets n to zero
+func wipeInt(n *big.Int) {
+	if n == nil {
+		return
+	}
+	words := n.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	n.SetInt64(0)
+}
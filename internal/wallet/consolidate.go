@@ -0,0 +1,170 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"byc/internal/blockchain"
+
+	"go.uber.org/zap"
+)
+
+// ConsolidateUTXOs builds a transaction sweeping up to maxInputs of the
+// account's smallest spendable outputs into one output back to the account,
+// so later payments need fewer inputs. Outputs of different coins cannot be
+// merged, so it sweeps the coin with the most spendable outputs. The fee is
+// feeRate per virtual byte, or the chain's minimum relay fee rate when
+// feeRate is 0; run it while fees are low. The transaction is signed but not
+// broadcast.
+func (w *Wallet) ConsolidateUTXOs(bc *blockchain.Blockchain, account uint32, maxInputs int, feeRate float64) (*blockchain.Transaction, error) {
+	if err := w.rateLimiter.CheckRateLimit("create_transaction"); err != nil {
+		return nil, err
+	}
+	if maxInputs < 2 {
+		return nil, &ValidationError{
+			Field:  "max_inputs",
+			Reason: "consolidation needs at least 2 inputs",
+		}
+	}
+	if feeRate < 0 {
+		return nil, &ValidationError{
+			Field:  "fee_rate",
+			Reason: "fee rate must not be negative",
+		}
+	}
+	params := bc.ConsensusParams()
+	if feeRate == 0 {
+		feeRate = params.MinRelayFeeRate
+	}
+
+	owned, err := w.accountKeys(account)
+	if err != nil {
+		return nil, &TransactionError{
+			Operation: "derive_addresses",
+			Reason:    err.Error(),
+		}
+	}
+	keys := make(map[string]*ecdsa.PrivateKey, len(owned))
+	for _, o := range owned {
+		if o.key != nil {
+			keys[o.address] = o.key
+		}
+	}
+	policy := w.signingPolicy()
+
+	// Group the spendable outputs the wallet can sign for by coin
+	byCoin := make(map[blockchain.CoinType][]*UnspentOutput)
+	for _, utxo := range w.ListUnspent(bc, account, 0, nil) {
+		if keys[utxo.Address] != nil {
+			byCoin[utxo.CoinType] = append(byCoin[utxo.CoinType], utxo)
+		}
+	}
+	var coinType blockchain.CoinType
+	for _, c := range blockchain.AllCoinTypes {
+		if len(byCoin[c]) > len(byCoin[coinType]) {
+			coinType = c
+		}
+	}
+	candidates := byCoin[coinType]
+	if len(candidates) < 2 {
+		return nil, &ValidationError{
+			Field:  "utxos",
+			Reason: fmt.Sprintf("account %d has fewer than 2 spendable outputs of any coin", account),
+		}
+	}
+
+	// Sweep the smallest outputs first
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Amount < candidates[j].Amount
+	})
+	if len(candidates) > maxInputs {
+		candidates = candidates[:maxInputs]
+	}
+
+	var inputs []blockchain.TxInput
+	var spent []string
+	var total float64
+	for _, utxo := range candidates {
+		inputs = append(inputs, blockchain.TxInput{
+			TxID:        []byte(utxo.TxID),
+			OutputIndex: utxo.Index,
+			Amount:      utxo.Amount,
+			PublicKey:   policy.inputPublicKey(keys[utxo.Address], utxo.Address),
+		})
+		spent = append(spent, utxo.Address)
+		total += utxo.Amount
+	}
+
+	fee := float64(estimateVirtualSize(spent, 1, coinType)) * feeRate
+	if total <= fee {
+		return nil, &InsufficientFundsError{
+			Required:  fee,
+			Available: total,
+			CoinType:  coinType.String(),
+		}
+	}
+
+	to, err := w.consolidationAddress(account)
+	if err != nil {
+		return nil, &TransactionError{
+			Operation: "change_address",
+			Reason:    err.Error(),
+		}
+	}
+	// Lock the output to the hash the address commits to, so the account
+	// can spend it again
+	hash := []byte(to)
+	if address, err := decodeAddress(to); err == nil {
+		hash = address.Hash
+	}
+	outputs := []blockchain.TxOutput{{
+		Value:         total - fee,
+		CoinType:      coinType,
+		PublicKeyHash: hash,
+		Address:       to,
+	}}
+	// The coins stay on their chain, so unlike NewTransaction this leaves the
+	// block type that marks cross-block transfers unset
+	tx := &blockchain.Transaction{
+		Inputs:    inputs,
+		Outputs:   outputs,
+		Timestamp: time.Now(),
+		Nonce:     blockchain.NewTxNonce(),
+	}
+	tx.RecomputeID()
+
+	err = tx.SignWith(func(hash []byte, index int) ([]byte, error) {
+		address := candidates[index].Address
+		return policy.sign(hash, keys[address], address)
+	})
+	audit(AuditSignTx, account, w.Address, err)
+	if err != nil {
+		return nil, &TransactionError{
+			Operation: "sign_transaction",
+			Reason:    err.Error(),
+			TxID:      hex.EncodeToString(tx.ID),
+		}
+	}
+
+	w.logger.Info("Consolidation transaction created",
+		zap.String("tx_id", hex.EncodeToString(tx.ID)),
+		zap.Int("inputs", len(inputs)),
+		zap.Float64("amount", total-fee),
+		zap.Float64("fee", fee),
+		zap.String("coin_type", coinType.String()),
+	)
+	return tx, nil
+}
+
+// consolidationAddress returns where an account's consolidated outputs go:
+// the next internal chain address of an HD account, or the primary address
+// for the default account of a wallet without one
+func (w *Wallet) consolidationAddress(account uint32) (string, error) {
+	if account == DefaultAccount {
+		return w.changeAddress()
+	}
+	return w.NewChangeAddress(account)
+}
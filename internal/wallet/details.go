@@ -0,0 +1,228 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"byc/internal/blockchain"
+)
+
+// Counterparty is an address on the other side of a wallet transaction
+type Counterparty struct {
+	Address string
+	// Label is the address book name of the address, empty if it has none
+	Label string
+	// Amount is what the address was paid, for a send, or spent, for a receive
+	Amount float64
+}
+
+// TxDetails is the full view of one wallet transaction: its history record
+// merged with what the chain knows of it
+type TxDetails struct {
+	TransactionRecord
+	// Confirmations counts the blocks confirming the transaction: 0 while
+	// pending and -1 once its block was reorganized out and it did not
+	// return to the pending pool
+	Confirmations int
+	// BlockHash is the hex hash of the block holding the transaction, empty
+	// while pending
+	BlockHash string
+	// Fee is the input value not paid to any output
+	Fee float64
+	// NetAmount is the change the transaction made to the wallet's balance:
+	// the outputs paying the wallet less the wallet's inputs it spent
+	NetAmount float64
+	// Counterparties are the addresses paid by a send or spending into a
+	// receive, in transaction order
+	Counterparties []Counterparty
+}
+
+// GetTransactionDetails returns the details of the transaction with the
+// hex-encoded ID txid. The wallet's history record is merged with the
+// transaction as found in a block of bc or, failing that, its pending pool;
+// a transaction known to neither the history nor bc is an error.
+func (w *Wallet) GetTransactionDetails(txid string, bc *blockchain.Blockchain) (*TxDetails, error) {
+	id, err := hex.DecodeString(txid)
+	if err != nil {
+		return nil, &TransactionError{
+			Operation: "get_transaction_details",
+			Reason:    "invalid transaction ID: " + err.Error(),
+			TxID:      txid,
+		}
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	details := &TxDetails{}
+	found := false
+	for _, record := range w.Transactions {
+		if record.TxID == txid {
+			details.TransactionRecord = record
+			found = true
+			break
+		}
+	}
+
+	var tx *blockchain.Transaction
+	pending := bc.GetPendingTransactions()
+	if block, height, err := bc.GetTransactionBlock(id); err == nil {
+		for i := range block.Transactions {
+			if bytes.Equal(block.Transactions[i].ID, id) {
+				tx = &block.Transactions[i]
+				break
+			}
+		}
+		details.BlockHash = hex.EncodeToString(block.Hash)
+		details.BlockHeight = int64(height)
+		details.Status = "confirmed"
+		if details.Confirmations, err = bc.Confirmations(block.Hash); err != nil {
+			details.Confirmations = 0
+		}
+	} else {
+		for i := range pending {
+			if bytes.Equal(pending[i].ID, id) {
+				tx = &pending[i]
+				break
+			}
+		}
+	}
+	if tx == nil && found && (details.Status == "confirmed" || details.Status == "invalid") {
+		// A block once confirmed it, but it is now in neither a block nor
+		// the pending pool
+		details.Confirmations = -1
+	}
+
+	if tx == nil {
+		if !found {
+			return nil, &TransactionError{
+				Operation: "get_transaction_details",
+				Reason:    "transaction not found",
+				TxID:      txid,
+			}
+		}
+		return details, nil
+	}
+
+	owned := w.ownedAddresses()
+	var spent, received, paid float64
+	var inputCounterparties []Counterparty
+	for _, input := range tx.Inputs {
+		if tx.IsCoinbase() {
+			break
+		}
+		address, amount := spentOutput(bc, pending, input)
+		if owned[address] {
+			spent += amount
+		} else if address != "" {
+			inputCounterparties = append(inputCounterparties, w.counterparty(address, amount))
+		}
+	}
+	var outputCounterparties []Counterparty
+	for _, output := range tx.Outputs {
+		if owned[output.Address] {
+			received += output.Value
+		} else {
+			paid += output.Value
+			outputCounterparties = append(outputCounterparties, w.counterparty(output.Address, output.Value))
+		}
+	}
+	if !found && spent == 0 && received == 0 {
+		return nil, &TransactionError{
+			Operation: "get_transaction_details",
+			Reason:    "transaction does not involve the wallet",
+			TxID:      txid,
+		}
+	}
+
+	details.TxID = txid
+	details.NetAmount = received - spent
+	if !tx.IsCoinbase() {
+		details.Fee = tx.GetFee()
+	}
+	if spent > 0 {
+		details.Counterparties = outputCounterparties
+	} else {
+		details.Counterparties = inputCounterparties
+	}
+	if !found {
+		details.Type = "receive"
+		details.Amount = received
+		if spent > 0 {
+			details.Type = "send"
+			details.Amount = paid
+		}
+		details.Timestamp = tx.Timestamp
+		if len(tx.Outputs) > 0 {
+			details.CoinType = tx.Outputs[0].CoinType
+		}
+		if details.Status == "" {
+			details.Status = "pending"
+		}
+	}
+	return details, nil
+}
+
+// spentOutput returns the address and value of the output input spends,
+// found in a block of bc, its pending pool or its unspent outputs. An output
+// bc does not know falls back to what the input declares.
+func spentOutput(bc *blockchain.Blockchain, pending []blockchain.Transaction, input blockchain.TxInput) (string, float64) {
+	outputOf := func(tx *blockchain.Transaction) (string, float64, bool) {
+		if input.OutputIndex < 0 || input.OutputIndex >= len(tx.Outputs) {
+			return "", 0, false
+		}
+		output := tx.Outputs[input.OutputIndex]
+		return output.Address, output.Value, true
+	}
+
+	if tx, err := bc.GetTransaction(input.TxID); err == nil {
+		if address, value, ok := outputOf(tx); ok {
+			return address, value
+		}
+	}
+	for i := range pending {
+		if bytes.Equal(pending[i].ID, input.TxID) {
+			if address, value, ok := outputOf(&pending[i]); ok {
+				return address, value
+			}
+		}
+	}
+	if utxo, ok := bc.UTXOSet.Get(fmt.Sprintf("%x:%d", input.TxID, input.OutputIndex)); ok {
+		return utxo.Address, utxo.Amount
+	}
+	return input.Address, input.Amount
+}
+
+// ownedAddresses returns the set of the wallet's addresses: the primary
+// address and those derived for its HD accounts. Callers must hold w.mu.
+func (w *Wallet) ownedAddresses() map[string]bool {
+	owned := map[string]bool{w.Address: true}
+
+	accounts := []uint32{DefaultAccount}
+	if hd := w.HDWallet; hd != nil {
+		hd.mu.RLock()
+		for index := range hd.Accounts {
+			if index != DefaultAccount {
+				accounts = append(accounts, index)
+			}
+		}
+		hd.mu.RUnlock()
+	}
+	for _, account := range accounts {
+		for _, address := range w.accountAddresses(account) {
+			owned[address] = true
+		}
+	}
+	return owned
+}
+
+// counterparty describes address, labelled from the address book. Callers
+// must hold w.mu.
+func (w *Wallet) counterparty(address string, amount float64) Counterparty {
+	c := Counterparty{Address: address, Amount: amount}
+	if entry, ok := w.AddressBook[address]; ok {
+		c.Label = entry.Name
+	}
+	return c
+}
@@ -0,0 +1,99 @@
+package wallet
+
+import (
+	"byc/internal/blockchain"
+)
+
+// GapLimit is how many unused addresses in a row end the search along an
+// address chain
+const GapLimit = 20
+
+// receiveAddressTypes are the types a receive address may have been handed
+// out as, tried in order during discovery
+var receiveAddressTypes = []AddressType{AddressTypeP2PKH, AddressTypeP2WPKH, AddressTypeP2TR}
+
+// discoverAddresses advances the chains of every HD account past the
+// addresses paid by outputs in blocks, searching each chain until GapLimit
+// addresses in a row are unused. Accounts are added after the last while the
+// one added before them has been used. Receive addresses found as a type
+// other than P2PKH are recorded as that type. It reports whether any address
+// was found.
+func (w *Wallet) discoverAddresses(blocks []blockchain.Block) (bool, error) {
+	if w.HDWallet == nil {
+		return false, nil
+	}
+
+	paid := make(map[string]bool)
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			for _, output := range tx.Outputs {
+				paid[output.Address] = true
+			}
+		}
+	}
+
+	hd := w.HDWallet
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	found := false
+	for index := uint32(0); ; index++ {
+		a, existing := hd.Accounts[index]
+		if !existing {
+			a = hd.createAccount()
+		}
+
+		used := false
+		for _, chain := range []uint32{ExternalChain, InternalChain} {
+			advanced, err := hd.discoverChain(a, chain, paid)
+			if err != nil {
+				return found, err
+			}
+			used = used || advanced
+		}
+		found = found || used
+
+		if !existing && !used {
+			delete(hd.Accounts, a.Index)
+			return found, nil
+		}
+	}
+}
+
+// discoverChain advances the account's next index on chain past the last
+// address paid, reporting whether it advanced. Callers must hold hd.mu.
+func (hd *HDWallet) discoverChain(a *Account, chain uint32, paid map[string]bool) (bool, error) {
+	next := &a.NextExternal
+	types := receiveAddressTypes
+	if chain == InternalChain {
+		next = &a.NextInternal
+		types = []AddressType{AddressTypeP2PKH}
+	}
+
+	advanced := false
+	for index, gap := *next, 0; gap < GapLimit; index++ {
+		key, err := hd.addressKey(a.Index, chain, index)
+		if err != nil {
+			return advanced, err
+		}
+
+		gap++
+		for _, t := range types {
+			addr, err := newAddress(&key.PublicKey, t)
+			if err != nil || !paid[addr.String()] {
+				continue
+			}
+			if t != AddressTypeP2PKH {
+				if a.AddressTypes == nil {
+					a.AddressTypes = make(map[uint32]AddressType)
+				}
+				a.AddressTypes[index] = t
+			}
+			*next = index + 1
+			advanced = true
+			gap = 0
+			break
+		}
+	}
+	return advanced, nil
+}
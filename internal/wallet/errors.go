@@ -42,9 +42,10 @@ type (
 
 	// BackupError occurs during wallet backup/restore
 	BackupError struct {
-		Path    string
-		Reason  string
-		Details map[string]interface{}
+		Operation string
+		Path      string
+		Reason    string
+		Details   map[string]interface{}
 	}
 
 	// RateLimitError occurs when operation rate limit is exceeded
@@ -171,6 +172,11 @@ func (e *InsufficientFundsError) Recovery() string {
 	return ErrInsufficientFundsRecovery
 }
 
+// Is matches ErrInsufficientFunds, so callers can test for it with errors.Is
+func (e *InsufficientFundsError) Is(target error) bool {
+	return target == ErrInsufficientFunds
+}
+
 func (e *InvalidAddressError) Error() string {
 	return fmt.Sprintf(ErrInvalidAddressMsg, e.Address, e.Reason)
 }
@@ -179,6 +185,11 @@ func (e *InvalidAddressError) Recovery() string {
 	return ErrInvalidAddressRecovery
 }
 
+// Is matches ErrInvalidAddress, so callers can test for it with errors.Is
+func (e *InvalidAddressError) Is(target error) bool {
+	return target == ErrInvalidAddress
+}
+
 func (e *InvalidAmountError) Error() string {
 	return fmt.Sprintf(ErrInvalidAmountMsg, e.Amount, e.Reason)
 }
@@ -187,6 +198,11 @@ func (e *InvalidAmountError) Recovery() string {
 	return ErrInvalidAmountRecovery
 }
 
+// Is matches ErrInvalidAmount, so callers can test for it with errors.Is
+func (e *InvalidAmountError) Is(target error) bool {
+	return target == ErrInvalidAmount
+}
+
 func (e *EncryptionError) Error() string {
 	return fmt.Sprintf(ErrEncryptionMsg, e.Operation, e.Reason)
 }
@@ -204,7 +220,7 @@ func (e *TransactionError) Recovery() string {
 }
 
 func (e *BackupError) Error() string {
-	return e.Reason
+	return fmt.Sprintf(ErrBackupMsg, e.Operation, e.Path, e.Reason)
 }
 
 func (e *BackupError) Recovery() string {
@@ -370,7 +386,8 @@ func NewErrorMonitor() *ErrorMonitor {
 	}
 }
 
-// RecordError records an error and checks if it exceeds the threshold
+// RecordError records an error and alerts if errors of its type have passed
+// their threshold
 func (em *ErrorMonitor) RecordError(err error) {
 	em.mu.Lock()
 	defer em.mu.Unlock()
@@ -399,10 +416,22 @@ func (em *ErrorMonitor) RecordError(err error) {
 	// Record error
 	em.errors[errorType] = append(em.errors[errorType], err)
 
-	// Check threshold
-	if threshold, exists := em.thresholds[errorType]; exists {
-		if len(em.errors[errorType]) >= threshold {
-			em.alerts <- err
+	// Alert once the errors of a type pass its threshold, then again every
+	// half threshold while they keep coming, rather than on every error
+	threshold, exists := em.thresholds[errorType]
+	if !exists {
+		return
+	}
+	over := len(em.errors[errorType]) - threshold
+	interval := threshold / 2
+	if interval < 1 {
+		interval = 1
+	}
+	if over > 0 && (over-1)%interval == 0 {
+		// Alerts nobody reads are dropped rather than block recording
+		select {
+		case em.alerts <- err:
+		default:
 		}
 	}
 }
@@ -0,0 +1,434 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"byc/internal/crypto"
+)
+
+// HardenedKeyStart is the first hardened child index
+const HardenedKeyStart uint32 = 0x80000000
+
+// Address chains of an HD account, the fourth level of m/44'/0'/account'/chain/index
+const (
+	// ExternalChain holds receive addresses handed out to payers
+	ExternalChain uint32 = 0
+	// InternalChain holds change addresses the wallet pays itself
+	InternalChain uint32 = 1
+)
+
+// bip44Purpose and bip44CoinType are the first two levels of account paths
+const (
+	bip44Purpose  = 44
+	bip44CoinType = 0
+)
+
+// ErrNotHDWallet is returned by account operations on a wallet without a seed
+var ErrNotHDWallet = errors.New("not an HD wallet")
+
+// Account is an HD account with separate receive and change address chains.
+// The next indices count the addresses handed out on each chain, so the
+// addresses of a chain are exactly those at indices below its next index.
+type Account struct {
+	Index        uint32
+	NextExternal uint32
+	NextInternal uint32
+	// AddressTypes records the receive addresses handed out as a type other
+	// than P2PKH, by index
+	AddressTypes map[uint32]AddressType
+}
+
+// address returns the address an account hands out for key at chain/index
+func (a *Account) address(chain, index uint32, key *ecdsa.PrivateKey) (string, error) {
+	t := AddressTypeP2PKH
+	if chain == ExternalChain {
+		if recorded, ok := a.AddressTypes[index]; ok {
+			t = recorded
+		}
+	}
+	addr, err := newAddress(&key.PublicKey, t)
+	if err != nil {
+		return "", err
+	}
+	return addr.String(), nil
+}
+
+// extendedKey is a BIP32 private key with its chain code
+type extendedKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+// newMasterKey derives the BIP32 master key from a seed
+func newMasterKey(seed []byte) (*extendedKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	k := new(big.Int).SetBytes(sum[:32])
+	if k.Sign() == 0 || k.Cmp(crypto.S256().Params().N) >= 0 {
+		return nil, errors.New("seed produces an invalid master key")
+	}
+	return &extendedKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// child derives the private child key at index; indices from
+// HardenedKeyStart up are hardened
+func (k *extendedKey) child(index uint32) (*extendedKey, error) {
+	curve := crypto.S256()
+	mac := hmac.New(sha512.New, k.chainCode)
+	if index >= HardenedKeyStart {
+		mac.Write([]byte{0})
+		mac.Write(k.key)
+	} else {
+		x, y := curve.ScalarBaseMult(k.key)
+		mac.Write(elliptic.MarshalCompressed(curve, x, y))
+	}
+	var indexBytes [4]byte
+	binary.BigEndian.PutUint32(indexBytes[:], index)
+	mac.Write(indexBytes[:])
+	sum := mac.Sum(nil)
+
+	n := curve.Params().N
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(n) >= 0 {
+		return nil, fmt.Errorf("invalid child key at index %d", index)
+	}
+	childKey := tweak.Add(tweak, new(big.Int).SetBytes(k.key))
+	childKey.Mod(childKey, n)
+	if childKey.Sign() == 0 {
+		return nil, fmt.Errorf("invalid child key at index %d", index)
+	}
+	return &extendedKey{key: childKey.FillBytes(make([]byte, 32)), chainCode: sum[32:]}, nil
+}
+
+// privateKey returns the key as an ECDSA private key on secp256k1
+func (k *extendedKey) privateKey() *ecdsa.PrivateKey {
+	curve := crypto.S256()
+	priv := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(k.key)}
+	priv.PublicKey.Curve = curve
+	priv.PublicKey.X, priv.PublicKey.Y = curve.ScalarBaseMult(k.key)
+	return priv
+}
+
+// derivePath derives the key at path from the wallet seed
+func (hd *HDWallet) derivePath(path ...uint32) (*extendedKey, error) {
+	// Close wipes the seed, and deriving from no seed would give other keys
+	if len(hd.Seed) == 0 {
+		return nil, ErrWalletClosed
+	}
+	key, err := newMasterKey(hd.Seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range path {
+		if key, err = key.child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// ParseDerivationPath parses a BIP32 path such as m/84'/0'/0'/0/5 into its
+// child indices. A trailing ' or h marks a hardened index.
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q: must start with m", path)
+	}
+
+	indices := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || index >= uint64(HardenedKeyStart) {
+			return nil, fmt.Errorf("invalid derivation path %q: bad index %q", path, part)
+		}
+		if hardened {
+			index += uint64(HardenedKeyStart)
+		}
+		indices = append(indices, uint32(index))
+	}
+	return indices, nil
+}
+
+// DeriveAddressAtPath derives the key at a BIP32 path from the wallet seed,
+// such as one used by another wallet, and returns its address of type t. The
+// address is not added to any account.
+func (w *Wallet) DeriveAddressAtPath(path string, t AddressType) (*Address, *ecdsa.PrivateKey, error) {
+	if w.HDWallet == nil {
+		return nil, nil, ErrNotHDWallet
+	}
+	indices, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hd := w.HDWallet
+	hd.mu.RLock()
+	key, err := hd.derivePath(indices...)
+	hd.mu.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	priv := key.privateKey()
+	addr, err := newAddress(&priv.PublicKey, t)
+	if err != nil {
+		return nil, nil, err
+	}
+	return addr, priv, nil
+}
+
+// addressKey derives the key of an account address, m/44'/0'/account'/chain/index
+func (hd *HDWallet) addressKey(account, chain, index uint32) (*ecdsa.PrivateKey, error) {
+	key, err := hd.derivePath(
+		HardenedKeyStart+bip44Purpose,
+		HardenedKeyStart+bip44CoinType,
+		HardenedKeyStart+account,
+		chain,
+		index,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return key.privateKey(), nil
+}
+
+// CreateAccount adds the next HD account
+func (w *Wallet) CreateAccount() (*Account, error) {
+	if w.HDWallet == nil {
+		return nil, ErrNotHDWallet
+	}
+	hd := w.HDWallet
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+	return hd.createAccount(), nil
+}
+
+// createAccount adds the next HD account. Callers must hold hd.mu.
+func (hd *HDWallet) createAccount() *Account {
+	if hd.Accounts == nil {
+		hd.Accounts = make(map[uint32]*Account)
+	}
+	account := &Account{Index: uint32(len(hd.Accounts))}
+	hd.Accounts[account.Index] = account
+	return account
+}
+
+// GetAccount returns a copy of an HD account
+func (w *Wallet) GetAccount(index uint32) (Account, error) {
+	if w.HDWallet == nil {
+		return Account{}, ErrNotHDWallet
+	}
+	hd := w.HDWallet
+	hd.mu.RLock()
+	defer hd.mu.RUnlock()
+
+	account, ok := hd.Accounts[index]
+	if !ok {
+		return Account{}, fmt.Errorf("account %d not found", index)
+	}
+	copied := *account
+	if account.AddressTypes != nil {
+		copied.AddressTypes = make(map[uint32]AddressType, len(account.AddressTypes))
+		for i, t := range account.AddressTypes {
+			copied.AddressTypes[i] = t
+		}
+	}
+	return copied, nil
+}
+
+// NewReceiveAddress hands out the next address on the account's external chain
+func (w *Wallet) NewReceiveAddress(account uint32) (string, error) {
+	address, err := w.nextAddress(account, ExternalChain)
+	audit(AuditDeriveKey, account, address, err)
+	return address, err
+}
+
+// NewChangeAddress hands out the next address on the account's internal chain
+func (w *Wallet) NewChangeAddress(account uint32) (string, error) {
+	address, err := w.nextAddress(account, InternalChain)
+	audit(AuditDeriveKey, account, address, err)
+	return address, err
+}
+
+func (w *Wallet) nextAddress(account, chain uint32) (string, error) {
+	if w.HDWallet == nil {
+		return "", ErrNotHDWallet
+	}
+	hd := w.HDWallet
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	a, ok := hd.Accounts[account]
+	if !ok {
+		return "", fmt.Errorf("account %d not found", account)
+	}
+	next := &a.NextExternal
+	if chain == InternalChain {
+		next = &a.NextInternal
+	}
+
+	key, err := hd.addressKey(account, chain, *next)
+	if err != nil {
+		return "", err
+	}
+	*next++
+	return generateAddress(&key.PublicKey), nil
+}
+
+// GetNewAddress hands out the next receive address of the account as an
+// address of type t
+func (w *Wallet) GetNewAddress(account uint32, t AddressType) (*Address, error) {
+	addr, err := w.nextTypedAddress(account, t)
+	if err != nil {
+		audit(AuditDeriveKey, account, "", err)
+		return nil, err
+	}
+	audit(AuditDeriveKey, account, addr.String(), nil)
+	return addr, nil
+}
+
+func (w *Wallet) nextTypedAddress(account uint32, t AddressType) (*Address, error) {
+	if w.HDWallet == nil {
+		return nil, ErrNotHDWallet
+	}
+	hd := w.HDWallet
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	a, ok := hd.Accounts[account]
+	if !ok {
+		return nil, fmt.Errorf("account %d not found", account)
+	}
+	key, err := hd.addressKey(account, ExternalChain, a.NextExternal)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := newAddress(&key.PublicKey, t)
+	if err != nil {
+		return nil, err
+	}
+
+	if t != AddressTypeP2PKH {
+		if a.AddressTypes == nil {
+			a.AddressTypes = make(map[uint32]AddressType)
+		}
+		a.AddressTypes[a.NextExternal] = t
+	}
+	a.NextExternal++
+	return addr, nil
+}
+
+// AccountAddresses returns the addresses handed out on one of an account's
+// chains, in index order
+func (w *Wallet) AccountAddresses(account, chain uint32) ([]string, error) {
+	if w.HDWallet == nil {
+		return nil, ErrNotHDWallet
+	}
+	if chain != ExternalChain && chain != InternalChain {
+		return nil, fmt.Errorf("unknown address chain %d", chain)
+	}
+	hd := w.HDWallet
+	hd.mu.RLock()
+	defer hd.mu.RUnlock()
+
+	a, ok := hd.Accounts[account]
+	if !ok {
+		return nil, fmt.Errorf("account %d not found", account)
+	}
+	count := a.NextExternal
+	if chain == InternalChain {
+		count = a.NextInternal
+	}
+
+	addresses := make([]string, 0, count)
+	for index := uint32(0); index < count; index++ {
+		key, err := hd.addressKey(account, chain, index)
+		if err != nil {
+			return nil, err
+		}
+		address, err := a.address(chain, index, key)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+// ownedAddress is an address of the wallet with the key that spends from it,
+// which is nil for a watch-only wallet's primary address
+type ownedAddress struct {
+	address string
+	key     *ecdsa.PrivateKey
+}
+
+// accountKeys returns the addresses of the account, the wallet's primary
+// address first for the default account, then its receive and change
+// addresses in index order
+func (w *Wallet) accountKeys(account uint32) ([]ownedAddress, error) {
+	if err := w.checkOpen(); err != nil {
+		return nil, err
+	}
+	var owned []ownedAddress
+	if account == DefaultAccount {
+		owned = append(owned, ownedAddress{address: w.Address, key: w.PrivateKey})
+	}
+	if w.HDWallet == nil {
+		return owned, nil
+	}
+
+	hd := w.HDWallet
+	hd.mu.RLock()
+	defer hd.mu.RUnlock()
+
+	a, ok := hd.Accounts[account]
+	if !ok {
+		return owned, nil
+	}
+	for _, chain := range []struct {
+		chain uint32
+		count uint32
+	}{{ExternalChain, a.NextExternal}, {InternalChain, a.NextInternal}} {
+		for index := uint32(0); index < chain.count; index++ {
+			key, err := hd.addressKey(account, chain.chain, index)
+			if err != nil {
+				return nil, err
+			}
+			address, err := a.address(chain.chain, index, key)
+			if err != nil {
+				return nil, err
+			}
+			owned = append(owned, ownedAddress{address: address, key: key})
+		}
+	}
+	return owned, nil
+}
+
+// changeAddress returns where the default account's change goes: the next
+// internal chain address for HD wallets, or the primary address otherwise
+func (w *Wallet) changeAddress() (string, error) {
+	if w.HDWallet != nil {
+		w.HDWallet.mu.RLock()
+		_, ok := w.HDWallet.Accounts[DefaultAccount]
+		w.HDWallet.mu.RUnlock()
+		if ok {
+			return w.NewChangeAddress(DefaultAccount)
+		}
+	}
+	return w.Address, nil
+}
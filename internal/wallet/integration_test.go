@@ -2,9 +2,12 @@ package wallet
 
 import (
 	"encoding/hex"
+	"errors"
+	"path/filepath"
 	"testing"
 
 	"byc/internal/blockchain"
+	"byc/internal/crypto"
 	"byc/internal/network"
 
 	"github.com/stretchr/testify/assert"
@@ -29,7 +32,7 @@ func TestWalletBlockchainIntegration(t *testing.T) {
 
 	// Test transaction creation and broadcasting
 	tx, err := sender.CreateTransaction(recipient.Address, 1, blockchain.Leah, bc)
-	if err != nil && err != ErrInsufficientFunds {
+	if err != nil && !errors.Is(err, ErrInsufficientFunds) {
 		require.NoError(t, err)
 
 		// Create network node
@@ -53,7 +56,7 @@ func TestWalletEncryptionIntegration(t *testing.T) {
 	require.NoError(t, err)
 
 	// Add some data
-	wallet.AddToAddressBook("Test", "test-address", "Test address")
+	require.NoError(t, wallet.AddToAddressBook("Test", testAddress(t), "Test address"))
 	wallet.balances[blockchain.Leah] = 100
 
 	// Test encryption
@@ -67,7 +70,7 @@ func TestWalletEncryptionIntegration(t *testing.T) {
 	assert.NotEmpty(t, wallet.EncryptedKey)
 
 	// Test backup and restore
-	backupPath := "test.backup"
+	backupPath := filepath.Join(t.TempDir(), "test.backup")
 	err = wallet.Backup(backupPath)
 	require.NoError(t, err)
 
@@ -108,8 +111,8 @@ func TestHDWalletIntegration(t *testing.T) {
 
 	// Test transaction creation with restored wallet
 	bc := blockchain.NewBlockchain()
-	tx, err := restoredWallet.CreateTransaction("recipient", 1, blockchain.Leah, bc)
-	if err != nil && err != ErrInsufficientFunds {
+	tx, err := restoredWallet.CreateTransaction(testAddress(t), 1, blockchain.Leah, bc)
+	if err != nil && !errors.Is(err, ErrInsufficientFunds) {
 		require.NoError(t, err)
 		assert.NotNil(t, tx)
 	}
@@ -142,7 +145,7 @@ func TestMultiSigWalletIntegration(t *testing.T) {
 	// Test transaction signing
 	bc := blockchain.NewBlockchain()
 	tx, err := wallet1.CreateTransaction(multiSigWallet.Address, 1, blockchain.Leah, bc)
-	if err != nil && err != ErrInsufficientFunds {
+	if err != nil && !errors.Is(err, ErrInsufficientFunds) {
 		require.NoError(t, err)
 
 		// Sign with first wallet
@@ -175,12 +178,13 @@ func TestWatchOnlyWalletIntegration(t *testing.T) {
 	assert.Equal(t, 0.0, balance)
 
 	// Test address book
-	err = watchOnlyWallet.AddToAddressBook("Test", "test-address", "Test address")
+	contact := testAddress(t)
+	err = watchOnlyWallet.AddToAddressBook("Test", contact, "Test address")
 	require.NoError(t, err)
 
 	book := watchOnlyWallet.GetAddressBook()
 	assert.NotEmpty(t, book)
-	assert.Equal(t, "Test", book["test-address"].Name)
+	assert.Equal(t, "Test", book[contact].Name)
 }
 
 // TestSpecialCoinsIntegration tests special coin conversion integration
@@ -194,17 +198,17 @@ func TestSpecialCoinsIntegration(t *testing.T) {
 
 	// Test coin conversion
 	err = wallet.CreateEphraimCoin(bc)
-	if err != nil && err != ErrInsufficientFunds {
+	if err != nil && !errors.Is(err, ErrInsufficientFunds) {
 		require.NoError(t, err)
 	}
 
 	err = wallet.CreateManassehCoin(bc)
-	if err != nil && err != ErrInsufficientFunds {
+	if err != nil && !errors.Is(err, ErrInsufficientFunds) {
 		require.NoError(t, err)
 	}
 
 	err = wallet.CreateJosephCoin(bc)
-	if err != nil && err != ErrInsufficientFunds {
+	if err != nil && !errors.Is(err, ErrInsufficientFunds) {
 		require.NoError(t, err)
 	}
 }
@@ -223,7 +227,7 @@ func TestTransactionHistoryIntegration(t *testing.T) {
 
 	// Create and broadcast transaction
 	tx, err := sender.CreateTransaction(recipient.Address, 1, blockchain.Leah, bc)
-	if err != nil && err != ErrInsufficientFunds {
+	if err != nil && !errors.Is(err, ErrInsufficientFunds) {
 		require.NoError(t, err)
 
 		node := &network.Node{}
@@ -248,17 +252,18 @@ func TestAddressBookIntegration(t *testing.T) {
 	require.NoError(t, err)
 
 	// Add addresses
-	err = wallet.AddToAddressBook("Alice", "alice-address", "Alice's address")
+	alice, bob := testAddress(t), testAddress(t)
+	err = wallet.AddToAddressBook("Alice", alice, "Alice's address")
 	require.NoError(t, err)
 
-	err = wallet.AddToAddressBook("Bob", "bob-address", "Bob's address")
+	err = wallet.AddToAddressBook("Bob", bob, "Bob's address")
 	require.NoError(t, err)
 
 	// Get address book
 	book := wallet.GetAddressBook()
 	assert.Len(t, book, 2)
-	assert.Equal(t, "Alice", book["alice-address"].Name)
-	assert.Equal(t, "Bob", book["bob-address"].Name)
+	assert.Equal(t, "Alice", book[alice].Name)
+	assert.Equal(t, "Bob", book[bob].Name)
 
 	// Test invalid address
 	err = wallet.AddToAddressBook("Invalid", "invalid-address", "Invalid address")
@@ -267,17 +272,24 @@ func TestAddressBookIntegration(t *testing.T) {
 
 // TestFeeEstimationIntegration tests transaction fee estimation integration
 func TestFeeEstimationIntegration(t *testing.T) {
-	// Create wallet
+	// Create wallet funded with several outputs
 	wallet, err := NewWallet()
 	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+	for i := 0; i < 12; i++ {
+		require.NoError(t, bc.UTXOSet.UpdateWithTransaction(blockchain.NewTransaction("", wallet.Address, 10, blockchain.Leah, nil, []blockchain.TxOutput{
+			{Value: 10, CoinType: blockchain.Leah, Address: wallet.Address, PublicKeyHash: crypto.HashPublicKey(wallet.PublicKey)},
+		})))
+	}
+	bc.GoldenBlocks = append(bc.GoldenBlocks, blockchain.Block{})
 
 	// Test fee estimation
-	fee := wallet.EstimateTransactionFee(10.0, blockchain.Leah)
+	fee := wallet.EstimateTransactionFee(10.0, blockchain.Leah, bc)
 	assert.Greater(t, fee, 0.0)
 
-	// Test different amounts
-	fee1 := wallet.EstimateTransactionFee(1.0, blockchain.Leah)
-	fee2 := wallet.EstimateTransactionFee(100.0, blockchain.Leah)
+	// Larger amounts spend more inputs
+	fee1 := wallet.EstimateTransactionFee(1.0, blockchain.Leah, bc)
+	fee2 := wallet.EstimateTransactionFee(100.0, blockchain.Leah, bc)
 	assert.Greater(t, fee2, fee1)
 }
 
@@ -303,7 +315,7 @@ func TestRecoveryIntegration(t *testing.T) {
 	assert.Equal(t, wallet.Address, restoredWallet.Address)
 
 	// Test backup and restore
-	backupPath := "test.backup"
+	backupPath := filepath.Join(t.TempDir(), "test.backup")
 	err = wallet.Backup(backupPath)
 	require.NoError(t, err)
 
@@ -0,0 +1,84 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"byc/internal/blockchain"
+
+	"go.uber.org/zap"
+)
+
+// LegacyMiningWallet is the {Address, Rewards} file the miner wrote to
+// wallets/mining_wallet.json before it kept a full Wallet
+type LegacyMiningWallet struct {
+	Address string
+	Rewards map[string]float64
+}
+
+// LoadLegacyMiningWallet reads a legacy mining wallet file
+func LoadLegacyMiningWallet(path string) (*LegacyMiningWallet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &BackupError{Operation: "read", Path: path, Reason: err.Error()}
+	}
+
+	var legacy LegacyMiningWallet
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, &BackupError{Operation: "parse", Path: path, Reason: fmt.Sprintf("failed to parse legacy wallet: %v", err)}
+	}
+	if !isValidAddress(legacy.Address) {
+		return nil, &InvalidAddressError{Address: legacy.Address, Reason: "legacy wallet address is malformed"}
+	}
+	return &legacy, nil
+}
+
+// ImportLegacyMiningWallet migrates the legacy mining wallet at path into a
+// Wallet whose default account is the legacy address. The rewards are rebuilt
+// as tracked UTXOs by rescanning bc from genesis, so the result depends only on
+// the file and the chain. The legacy file never stored a key: pass the key the
+// address was generated from to make the outputs spendable, or nil to import
+// the address watch-only. Rewards the chain does not back are logged, not
+// credited.
+func ImportLegacyMiningWallet(path string, key *ecdsa.PrivateKey, bc *blockchain.Blockchain) (*Wallet, error) {
+	legacy, err := LoadLegacyMiningWallet(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Wallet{
+		Address:         legacy.Address,
+		balances:        make(map[blockchain.CoinType]float64),
+		Transactions:    make([]TransactionRecord, 0),
+		MultiSigWallets: make(map[string]*MultiSigWallet),
+		AddressBook:     make(map[string]*AddressBookEntry),
+		WatchOnly:       key == nil,
+		logger:          zap.NewNop(),
+		rateLimiter:     NewRateLimiter(),
+	}
+	if key != nil {
+		if generateAddress(&key.PublicKey) != legacy.Address {
+			return nil, &SecurityError{Operation: "migrate", Reason: "key does not match the legacy address"}
+		}
+		w.PrivateKey = key
+		w.PublicKey = &key.PublicKey
+	}
+
+	if err := w.Rescan(bc, 0, -1); err != nil {
+		return nil, err
+	}
+
+	for coin, reward := range legacy.Rewards {
+		balance := w.balances[blockchain.CoinType(coin)]
+		if math.Abs(balance-reward) > 1e-9 {
+			w.logger.Warn("Legacy reward not backed by the chain",
+				zap.String("coin", coin),
+				zap.Float64("legacy", reward),
+				zap.Float64("chain", balance))
+		}
+	}
+	return w, nil
+}
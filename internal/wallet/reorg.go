@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"byc/internal/blockchain"
+
+	"go.uber.org/zap"
+)
+
+// WatchReorgs keeps the wallet's history and rescanned balances in step with
+// reorganizations of bc. When a block is disconnected, the confirmed records
+// of its transactions go back to "pending" if the transaction returned to
+// the pending pool, or to "invalid" if the new branch conflicts with it; a
+// wallet that has been rescanned then rebuilds its unspent outputs and
+// balances from the new chain. Records of transactions a connected block
+// confirms are marked confirmed at its height. Events more than a
+// subscription's buffer behind are missed, as for any block subscriber. The
+// returned function ends the subscription.
+func (w *Wallet) WatchReorgs(bc *blockchain.Blockchain) func() {
+	events, unsubscribe := bc.SubscribeBlocks()
+	go func() {
+		for event := range events {
+			w.applyBlockEvent(bc, event)
+		}
+	}()
+	return unsubscribe
+}
+
+// applyBlockEvent updates the records of the transactions in the event's block
+func (w *Wallet) applyBlockEvent(bc *blockchain.Blockchain, event blockchain.BlockEvent) {
+	if event.Block == nil {
+		return
+	}
+
+	// Work out where each transaction stands before taking the wallet lock.
+	// The chain may have moved on since the event, so its current state wins.
+	type placement struct {
+		status string
+		height int64
+	}
+	placements := make(map[string]placement, len(event.Block.Transactions))
+	for _, tx := range event.Block.Transactions {
+		placements[hex.EncodeToString(tx.ID)] = placement{}
+	}
+
+	w.mu.RLock()
+	var affected bool
+	for _, record := range w.Transactions {
+		if _, ok := placements[record.TxID]; ok {
+			affected = true
+			break
+		}
+	}
+	rescanned := w.utxos != nil
+	w.mu.RUnlock()
+	if !affected && !(rescanned && event.Type == blockchain.BlockDisconnected) {
+		return
+	}
+
+	pending := bc.GetPendingTransactions()
+	for txid := range placements {
+		id, _ := hex.DecodeString(txid)
+		if _, height, err := bc.GetTransactionBlock(id); err == nil {
+			placements[txid] = placement{status: "confirmed", height: int64(height)}
+			continue
+		}
+		status := "invalid"
+		for _, tx := range pending {
+			if bytes.Equal(tx.ID, id) {
+				status = "pending"
+				break
+			}
+		}
+		placements[txid] = placement{status: status}
+	}
+
+	w.mu.Lock()
+	for i := range w.Transactions {
+		record := &w.Transactions[i]
+		p, ok := placements[record.TxID]
+		if !ok {
+			continue
+		}
+		switch {
+		case p.status == "confirmed":
+			record.Status = p.status
+			record.BlockHeight = p.height
+		case record.Status == "confirmed":
+			// Only records the chain had confirmed are rolled back; a
+			// broadcast that never made it into a block keeps its status
+			w.logger.Info("Transaction reorganized out of the chain",
+				zap.String("tx_id", record.TxID),
+				zap.Int64("height", record.BlockHeight),
+				zap.String("status", p.status))
+			record.Status = p.status
+			record.BlockHeight = 0
+		}
+	}
+	w.mu.Unlock()
+
+	if rescanned && event.Type == blockchain.BlockDisconnected {
+		if err := w.Rescan(bc, 0, -1); err != nil {
+			w.logger.Error("Failed to rescan after reorganization", zap.Error(err))
+		}
+	}
+}
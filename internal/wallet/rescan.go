@@ -0,0 +1,221 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"byc/internal/blockchain"
+	"byc/internal/crypto"
+
+	"go.uber.org/zap"
+)
+
+// UTXOs returns the unspent outputs found for the wallet by the last rescan
+func (w *Wallet) UTXOs() []blockchain.UTXO {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	utxos := make([]blockchain.UTXO, 0, len(w.utxos))
+	for _, utxo := range w.utxos {
+		utxos = append(utxos, utxo)
+	}
+	return utxos
+}
+
+// Rescan walks the blocks of both chains at heights fromHeight through
+// toHeight on their chain (negative for each tip) and rebuilds the wallet's
+// unspent outputs, balances and confirmed history from the outputs paying
+// any of its addresses and the inputs spending them. Confirmed history
+// records in the range are replaced; other records are kept. Outputs known
+// from earlier rescans stay unless spent in the range, so a partial range
+// updates the last rescan rather than replacing it; a full rebuild should
+// start at height 0. HD wallets first discover the addresses paid in the
+// range, up to GapLimit past the last one used on each chain.
+func (w *Wallet) Rescan(bc *blockchain.Blockchain, fromHeight, toHeight int64) error {
+	return w.RescanWithProgress(bc, fromHeight, toHeight, nil)
+}
+
+// RescanWithProgress is Rescan reporting progress after each block scanned
+func (w *Wallet) RescanWithProgress(bc *blockchain.Blockchain, fromHeight, toHeight int64, progress blockchain.ProgressFunc) error {
+	scanned, err := bc.ChainBlocksInRange(fromHeight, toHeight)
+	if err != nil {
+		return &TransactionError{
+			Operation: "rescan",
+			Reason:    err.Error(),
+		}
+	}
+	blocks := make([]blockchain.Block, len(scanned))
+	for i, b := range scanned {
+		blocks[i] = b.Block
+	}
+
+	found, err := w.discoverAddresses(blocks)
+	if err != nil {
+		return &TransactionError{
+			Operation: "rescan",
+			Reason:    fmt.Sprintf("address discovery: %v", err),
+		}
+	}
+	if found {
+		w.cache.reset()
+	}
+
+	tips := bc.TipHeights()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	owned := w.ownedAddresses()
+	var pubKeyHash []byte
+	if w.PublicKey != nil {
+		pubKeyHash = crypto.HashPublicKey(w.PublicKey)
+	}
+	owns := func(output blockchain.TxOutput) bool {
+		return owned[output.Address] || (pubKeyHash != nil && bytes.Equal(output.PublicKeyHash, pubKeyHash))
+	}
+	// signs reports whether the wallet signed input, for spends of outputs
+	// found before the range
+	signs := func(input blockchain.TxInput) bool {
+		return owned[input.Address] || (w.PublicKey != nil && bytes.Equal(input.PublicKey, crypto.PublicKeyToBytes(w.PublicKey)))
+	}
+	inRange := func(height int64) bool {
+		return height >= fromHeight && (toHeight < 0 || height <= toHeight)
+	}
+
+	// Outputs created in the range are rebuilt by the scan; the rest of
+	// those already known stay unless the range spends them
+	utxos := make(map[string]blockchain.UTXO, len(w.utxos))
+	for key, utxo := range w.utxos {
+		if !inRange(int64(utxo.Height)) {
+			utxos[key] = utxo
+		}
+	}
+	var records []TransactionRecord
+	rescanned := make(map[string]bool)
+	created := make(map[string]bool)
+	for i, block := range scanned {
+		for _, tx := range block.Transactions {
+			var spent float64
+			var from string
+			for _, input := range tx.Inputs {
+				key := fmt.Sprintf("%x:%d", input.TxID, input.OutputIndex)
+				if utxo, ok := utxos[key]; ok {
+					spent += utxo.Amount
+					if from == "" {
+						from = utxo.Address
+					}
+					delete(utxos, key)
+				} else if !tx.IsCoinbase() && signs(input) {
+					spent += input.Amount
+					if from == "" {
+						from = input.Address
+					}
+				}
+			}
+
+			var received, paid float64
+			var coinType blockchain.CoinType
+			var to, receiver string
+			for j, output := range tx.Outputs {
+				if owns(output) {
+					received += output.Value
+					if receiver == "" {
+						receiver = output.Address
+					}
+					key := fmt.Sprintf("%x:%d", tx.ID, j)
+					utxos[key] = blockchain.UTXO{
+						TxID:          string(tx.ID),
+						Index:         j,
+						Amount:        output.Value,
+						Address:       output.Address,
+						CoinType:      output.CoinType,
+						Timestamp:     tx.Timestamp.Unix(),
+						PublicKeyHash: output.PublicKeyHash,
+						IsCoinbase:    tx.IsCoinbase(),
+						BlockType:     block.BlockType,
+						Height:        uint64(block.Height),
+					}
+					created[key] = true
+				} else {
+					paid += output.Value
+					if to == "" {
+						to = output.Address
+					}
+				}
+				if coinType == "" {
+					coinType = output.CoinType
+				}
+			}
+
+			if spent == 0 && received == 0 {
+				continue
+			}
+
+			record := TransactionRecord{
+				TxID:        hex.EncodeToString(tx.ID),
+				Type:        "receive",
+				Amount:      received,
+				CoinType:    coinType,
+				To:          receiver,
+				Timestamp:   tx.Timestamp,
+				BlockHeight: block.Height,
+				Status:      "confirmed",
+			}
+			if spent > 0 {
+				record.Type = "send"
+				record.Amount = paid
+				record.From = from
+				record.To = to
+			}
+			records = append(records, record)
+			rescanned[record.TxID] = true
+		}
+		if progress != nil {
+			progress(int64(i+1), int64(len(scanned)))
+		}
+	}
+
+	// A range ending before the tip of a chain cannot see later spends of
+	// the outputs it found there, so those the chain has since spent are
+	// dropped
+	if toHeight >= 0 {
+		for key := range created {
+			utxo, ok := utxos[key]
+			if ok && uint64(toHeight) < tips.Of(utxo) && bc.UTXOSet.GetUTXO([]byte(utxo.TxID), utxo.Index).TxID == "" {
+				delete(utxos, key)
+			}
+		}
+	}
+
+	// Keep records outside the range that the rescan did not rebuild
+	history := make([]TransactionRecord, 0, len(w.Transactions)+len(records))
+	for _, record := range w.Transactions {
+		replaced := record.Status == "confirmed" && inRange(record.BlockHeight)
+		if !replaced && !rescanned[record.TxID] {
+			history = append(history, record)
+		}
+	}
+	w.Transactions = append(history, records...)
+
+	w.utxos = utxos
+	w.balances = make(map[blockchain.CoinType]float64)
+	for _, utxo := range utxos {
+		w.balances[utxo.CoinType] += utxo.Amount
+	}
+
+	w.logger.Info("Wallet rescan complete",
+		zap.Int64("from_height", fromHeight),
+		zap.Int("blocks", len(scanned)),
+		zap.Int("transactions", len(records)),
+		zap.Int("utxos", len(utxos)),
+	)
+	return nil
+}
+
+// RescannedBalance returns the balance of coinType found by the last rescan
+func (w *Wallet) RescannedBalance(coinType blockchain.CoinType) float64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.balances[coinType]
+}
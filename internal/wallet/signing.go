@@ -0,0 +1,83 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+
+	"byc/internal/crypto"
+)
+
+// SignatureScheme is the signature algorithm an input is signed with
+type SignatureScheme int
+
+const (
+	// SignatureECDSA is a DER-encoded ECDSA signature checked against the
+	// input's full public key
+	SignatureECDSA SignatureScheme = iota
+	// SignatureSchnorr is a BIP-340 key-path Taproot signature checked
+	// against the x-only output key the address commits to
+	SignatureSchnorr
+)
+
+func (s SignatureScheme) String() string {
+	switch s {
+	case SignatureECDSA:
+		return "ecdsa"
+	case SignatureSchnorr:
+		return "schnorr"
+	default:
+		return "unknown"
+	}
+}
+
+// SigningPolicy chooses the scheme an input is signed with from the type of
+// the address it spends. Address types it leaves out sign with ECDSA.
+type SigningPolicy map[AddressType]SignatureScheme
+
+// DefaultSigningPolicy signs inputs spending Taproot addresses with Schnorr
+// and all others with ECDSA
+func DefaultSigningPolicy() SigningPolicy {
+	return SigningPolicy{AddressTypeP2TR: SignatureSchnorr}
+}
+
+// Scheme returns the scheme an input spending address is signed with.
+// Addresses that do not decode are legacy and sign with ECDSA.
+func (p SigningPolicy) Scheme(address string) SignatureScheme {
+	decoded, err := decodeAddress(address)
+	if err != nil {
+		return SignatureECDSA
+	}
+	return p[decoded.Type]
+}
+
+// inputPublicKey returns the key an input spending address with key carries,
+// for its signature to be checked against: the Taproot output key for a
+// Schnorr signature, the compressed key a SegWit address commits to, and the
+// full public key otherwise
+func (p SigningPolicy) inputPublicKey(key *ecdsa.PrivateKey, address string) []byte {
+	decoded, err := decodeAddress(address)
+	switch {
+	case err != nil:
+	case p.Scheme(address) == SignatureSchnorr:
+		return decoded.Hash
+	case decoded.Type == AddressTypeP2WPKH:
+		return elliptic.MarshalCompressed(key.Curve, key.X, key.Y)
+	}
+	return crypto.PublicKeyToBytes(&key.PublicKey)
+}
+
+// sign signs hash for an input spending address with key
+func (p SigningPolicy) sign(hash []byte, key *ecdsa.PrivateKey, address string) ([]byte, error) {
+	if p.Scheme(address) == SignatureSchnorr {
+		return crypto.SignTaproot(hash, key, nil)
+	}
+	return crypto.SignKey(hash, key)
+}
+
+// signingPolicy returns the wallet's signing policy, the default if none is set
+func (w *Wallet) signingPolicy() SigningPolicy {
+	if w.SigningPolicy == nil {
+		return DefaultSigningPolicy()
+	}
+	return w.SigningPolicy
+}
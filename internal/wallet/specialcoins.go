@@ -0,0 +1,276 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"byc/internal/blockchain"
+	"byc/internal/interfaces"
+
+	"go.uber.org/zap"
+)
+
+// mintSpecialCoin mints one minted coin to the wallet and adds the minting
+// transaction to the pending pool. It spends the coin's mint requirements
+// from the default account, with change back to the account. The fee, at
+// the chain's minimum relay fee rate, is paid in the first required coin,
+// except that special coins are only held whole, so Joseph's fee is paid in
+// Leah.
+func (w *Wallet) mintSpecialCoin(bc *blockchain.Blockchain, minted blockchain.CoinType) (*blockchain.Transaction, error) {
+	if err := w.rateLimiter.CheckRateLimit("create_transaction"); err != nil {
+		return nil, err
+	}
+	requirements := blockchain.MintRequirements(minted)
+	if len(requirements) == 0 {
+		return nil, fmt.Errorf("coin type %s cannot be minted", minted)
+	}
+	feeCoin := requirements[0].CoinType
+	if minted == blockchain.Joseph {
+		feeCoin = blockchain.Leah
+	}
+
+	owned, err := w.accountKeys(DefaultAccount)
+	if err != nil {
+		return nil, &TransactionError{
+			Operation: "derive_addresses",
+			Reason:    err.Error(),
+		}
+	}
+	keys := make(map[string]*ecdsa.PrivateKey, len(owned))
+	for _, o := range owned {
+		if o.key != nil {
+			keys[o.address] = o.key
+		}
+	}
+	policy := w.signingPolicy()
+
+	// Outputs already spent by a pending mint or payment would conflict
+	pendingSpent := make(map[string]bool)
+	for _, tx := range bc.GetPendingTransactions() {
+		for _, input := range tx.Inputs {
+			pendingSpent[fmt.Sprintf("%x:%d", input.TxID, input.OutputIndex)] = true
+		}
+	}
+	byCoin := make(map[blockchain.CoinType][]*UnspentOutput)
+	for _, utxo := range w.ListUnspent(bc, DefaultAccount, 0, nil) {
+		if pendingSpent[fmt.Sprintf("%x:%d", []byte(utxo.TxID), utxo.Index)] {
+			continue
+		}
+		if keys[utxo.Address] != nil {
+			byCoin[utxo.CoinType] = append(byCoin[utxo.CoinType], utxo)
+		}
+	}
+
+	// Fund the fee coin last, once the inputs the fee must cover are known
+	coins := make([]blockchain.CoinType, 0, len(requirements)+1)
+	for _, r := range requirements {
+		if r.CoinType != feeCoin {
+			coins = append(coins, r.CoinType)
+		}
+	}
+	coins = append(coins, feeCoin)
+
+	outputCount := 1 + len(coins)
+	feeRate := bc.ConsensusParams().MinRelayFeeRate
+	var inputs []blockchain.TxInput
+	var spent []*UnspentOutput
+	totals := make(map[blockchain.CoinType]float64)
+	fee := func() float64 {
+		spentFrom := make([]string, len(spent))
+		for i, utxo := range spent {
+			spentFrom[i] = utxo.Address
+		}
+		return float64(estimateVirtualSize(spentFrom, outputCount, minted)) * feeRate
+	}
+	required := func(c blockchain.CoinType) float64 {
+		var amount float64
+		for _, r := range requirements {
+			if r.CoinType == c {
+				amount = r.Amount
+			}
+		}
+		if c == feeCoin {
+			amount += fee()
+		}
+		return amount
+	}
+
+	for _, c := range coins {
+		// Spend the largest outputs first so few inputs are needed
+		candidates := byCoin[c]
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Amount > candidates[j].Amount
+		})
+		for _, utxo := range candidates {
+			if totals[c] >= required(c) {
+				break
+			}
+			inputs = append(inputs, blockchain.TxInput{
+				TxID:        []byte(utxo.TxID),
+				OutputIndex: utxo.Index,
+				Amount:      utxo.Amount,
+				PublicKey:   policy.inputPublicKey(keys[utxo.Address], utxo.Address),
+			})
+			spent = append(spent, utxo)
+			totals[c] += utxo.Amount
+		}
+		if totals[c] < required(c) {
+			return nil, fmt.Errorf("%w: minting %s needs %f %s, available %f",
+				ErrInsufficientFunds, minted, required(c), c, totals[c])
+		}
+	}
+
+	outputs := []blockchain.TxOutput{w.mintOutput(1, minted, w.Address)}
+	change, err := w.changeAddress()
+	if err != nil {
+		return nil, &TransactionError{
+			Operation: "change_address",
+			Reason:    err.Error(),
+		}
+	}
+	// The fee was estimated with every change output, so it is final
+	txFee := fee()
+	for _, c := range coins {
+		if left := totals[c] - required(c); left > 0 {
+			outputs = append(outputs, w.mintOutput(left, c, change))
+		}
+	}
+
+	// Minting stays on the chain of the coins spent, so this leaves the
+	// block type that marks cross-block transfers unset
+	tx := &blockchain.Transaction{
+		Inputs:    inputs,
+		Outputs:   outputs,
+		Timestamp: time.Now(),
+		Nonce:     blockchain.NewTxNonce(),
+	}
+	tx.RecomputeID()
+
+	err = tx.SignWith(func(hash []byte, index int) ([]byte, error) {
+		address := spent[index].Address
+		return policy.sign(hash, keys[address], address)
+	})
+	audit(AuditSignTx, DefaultAccount, w.Address, err)
+	if err != nil {
+		return nil, &TransactionError{
+			Operation: "sign_transaction",
+			Reason:    err.Error(),
+			TxID:      hex.EncodeToString(tx.ID),
+		}
+	}
+
+	if err := bc.AddTransaction(*tx); err != nil {
+		return nil, fmt.Errorf("failed to add minting transaction: %v", err)
+	}
+
+	w.mu.Lock()
+	w.Transactions = append(w.Transactions, TransactionRecord{
+		TxID:      hex.EncodeToString(tx.ID),
+		Type:      "convert",
+		Amount:    1,
+		CoinType:  minted,
+		From:      w.Address,
+		To:        w.Address,
+		Timestamp: time.Now(),
+		Status:    "pending",
+	})
+	w.mu.Unlock()
+
+	w.logger.Info("Special coin minted",
+		zap.String("tx_id", hex.EncodeToString(tx.ID)),
+		zap.String("coin_type", minted.String()),
+		zap.Float64("fee", txFee),
+	)
+	return tx, nil
+}
+
+// mintOutput returns an output paying value of coinType to one of the
+// wallet's addresses, locked to the hash the address commits to
+func (w *Wallet) mintOutput(value float64, coinType blockchain.CoinType, to string) blockchain.TxOutput {
+	hash := []byte(to)
+	if address, err := decodeAddress(to); err == nil {
+		hash = address.Hash
+	}
+	return blockchain.TxOutput{
+		Value:         value,
+		CoinType:      coinType,
+		PublicKeyHash: hash,
+		Address:       to,
+	}
+}
+
+// specialCoinWallet backs interfaces.Wallet with a loaded wallet, so the
+// chain's special coin methods mint against its keys and balances
+type specialCoinWallet struct {
+	w *Wallet
+}
+
+// AsInterface returns w as the interfaces.Wallet taken by the chain's
+// special coin methods
+func (w *Wallet) AsInterface() interfaces.Wallet {
+	return specialCoinWallet{w}
+}
+
+// chainOf returns bc as a chain, as the interfaces package cannot name the type
+func chainOf(bc interface{}) (*blockchain.Blockchain, error) {
+	chain, ok := bc.(*blockchain.Blockchain)
+	if !ok || chain == nil {
+		return nil, fmt.Errorf("expected a *blockchain.Blockchain, got %T", bc)
+	}
+	return chain, nil
+}
+
+// CreateEphraimCoin mints an Ephraim coin on bc
+func (s specialCoinWallet) CreateEphraimCoin(bc interface{}) error {
+	chain, err := chainOf(bc)
+	if err != nil {
+		return err
+	}
+	return s.w.CreateEphraimCoin(chain)
+}
+
+// CreateManassehCoin mints a Manasseh coin on bc
+func (s specialCoinWallet) CreateManassehCoin(bc interface{}) error {
+	chain, err := chainOf(bc)
+	if err != nil {
+		return err
+	}
+	return s.w.CreateManassehCoin(chain)
+}
+
+// CreateJosephCoin mints a Joseph coin on bc
+func (s specialCoinWallet) CreateJosephCoin(bc interface{}) error {
+	chain, err := chainOf(bc)
+	if err != nil {
+		return err
+	}
+	return s.w.CreateJosephCoin(chain)
+}
+
+// GetSpecialCoins returns the special coins held by the wallet's default
+// account in the UTXO set
+func (s specialCoinWallet) GetSpecialCoins(bc interface{}) []interfaces.SpecialCoin {
+	chain, err := chainOf(bc)
+	if err != nil {
+		return nil
+	}
+	s.w.mu.RLock()
+	addresses := s.w.accountAddresses(DefaultAccount)
+	s.w.mu.RUnlock()
+
+	var coins []interfaces.SpecialCoin
+	for _, address := range addresses {
+		held := chain.GetSpecialCoins(address)
+		if coins == nil {
+			coins = held
+			continue
+		}
+		for i := range held {
+			coins[i].Amount += held[i].Amount
+		}
+	}
+	return coins
+}
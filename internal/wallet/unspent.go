@@ -0,0 +1,99 @@
+package wallet
+
+import (
+	"sort"
+
+	"byc/internal/blockchain"
+
+	"go.uber.org/zap"
+)
+
+// DefaultAccount is the account holding the wallet's primary address
+const DefaultAccount uint32 = 0
+
+// UnspentOutput is a spendable output along with its confirmation count
+type UnspentOutput struct {
+	blockchain.UTXO
+	Confirmations uint64
+}
+
+// accountAddresses returns the addresses belonging to account: the primary
+// address for the default account, plus the receive and change addresses of
+// the HD account with the same index
+func (w *Wallet) accountAddresses(account uint32) []string {
+	owned, err := w.accountKeys(account)
+	if err != nil {
+		w.logger.Error("Failed to derive account addresses", zap.Uint32("account", account), zap.Error(err))
+	}
+	addresses := make([]string, len(owned))
+	for i, o := range owned {
+		addresses[i] = o.address
+	}
+	return addresses
+}
+
+// inputAccount returns the account owning the address input spends from,
+// falling back to the default account for addresses no account derives
+func (w *Wallet) inputAccount(input blockchain.TxInput) uint32 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	accounts := []uint32{DefaultAccount}
+	if w.HDWallet != nil {
+		w.HDWallet.mu.RLock()
+		for account := range w.HDWallet.Accounts {
+			if account != DefaultAccount {
+				accounts = append(accounts, account)
+			}
+		}
+		w.HDWallet.mu.RUnlock()
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i] < accounts[j] })
+
+	for _, account := range accounts {
+		for _, address := range w.accountAddresses(account) {
+			if address == input.Address {
+				return account
+			}
+		}
+	}
+	return DefaultAccount
+}
+
+// ListUnspent returns the account's outputs that can be spent now and have at
+// least minConf confirmations, restricted to coinType when it is non-nil. The
+// result is ordered by confirmations, most confirmed first.
+func (w *Wallet) ListUnspent(bc *blockchain.Blockchain, account uint32, minConf uint64, coinType *blockchain.CoinType) []*UnspentOutput {
+	w.mu.RLock()
+	addresses := w.accountAddresses(account)
+	w.mu.RUnlock()
+
+	heights := bc.TipHeights()
+	params := bc.ConsensusParams()
+
+	var unspent []*UnspentOutput
+	for _, address := range addresses {
+		utxos, _ := bc.UTXOSet.GetUTXOs(address)
+		for _, utxo := range utxos {
+			if coinType != nil && utxo.CoinType != *coinType {
+				continue
+			}
+			if !params.IsSpendable(utxo, heights.Of(utxo)) {
+				continue
+			}
+			confirmations := utxo.Confirmations(heights.Of(utxo))
+			if confirmations < minConf {
+				continue
+			}
+			unspent = append(unspent, &UnspentOutput{UTXO: utxo, Confirmations: confirmations})
+		}
+	}
+
+	sort.Slice(unspent, func(i, j int) bool {
+		if unspent[i].Confirmations != unspent[j].Confirmations {
+			return unspent[i].Confirmations > unspent[j].Confirmations
+		}
+		return unspent[i].TxID < unspent[j].TxID
+	})
+	return unspent
+}
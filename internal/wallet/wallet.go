@@ -13,7 +13,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"byc/internal/blockchain"
@@ -26,7 +28,7 @@ import (
 )
 
 var (
-	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInsufficientFunds = blockchain.ErrInsufficientFunds
 	ErrInvalidAmount     = errors.New("invalid amount")
 	ErrInvalidAddress    = errors.New("invalid address")
 	ErrInvalidBackup     = errors.New("invalid backup data")
@@ -47,7 +49,7 @@ type TransactionRecord struct {
 	To          string
 	Timestamp   time.Time
 	BlockHeight int64
-	Status      string // "pending", "confirmed", "failed"
+	Status      string // "pending", "broadcast", "confirmed", "failed", "invalid"
 }
 
 // MultiSigWallet represents a multi-signature wallet
@@ -65,6 +67,7 @@ type HDWallet struct {
 	Seed      []byte
 	MasterKey []byte
 	ChildKeys map[uint32][]byte
+	Accounts  map[uint32]*Account
 	mu        sync.RWMutex
 }
 
@@ -90,6 +93,7 @@ type Wallet struct {
 	PublicKey  *ecdsa.PublicKey
 	Address    string
 	balances   map[blockchain.CoinType]float64
+	utxos      map[string]blockchain.UTXO
 	mu         sync.RWMutex
 	logger     *zap.Logger
 
@@ -104,6 +108,12 @@ type Wallet struct {
 	IV              []byte
 	EncryptedKey    []byte
 	rateLimiter     *RateLimiter
+	cache           balanceCache
+	// SigningPolicy chooses ECDSA or Schnorr for each input from the type
+	// of address it spends; nil uses DefaultSigningPolicy
+	SigningPolicy SigningPolicy
+	// closed is set by Close once the key material has been wiped
+	closed atomic.Bool
 
 	// Wallet metadata
 	BackupTime    int64
@@ -121,18 +131,29 @@ type WalletBackup struct {
 	AddressBook     map[string]*AddressBookEntry
 	Salt            []byte
 	IV              []byte
+	Balances        map[blockchain.CoinType]float64
+	Encrypted       bool
+	EncryptedKey    []byte
 }
 
 // NewWallet creates a new wallet
 func NewWallet() (*Wallet, error) {
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	privateKey, err := crypto.GenerateKey()
 	if err != nil {
+		audit(AuditGenerateKey, DefaultAccount, "", err)
 		return nil, &SecurityError{
 			Operation: "generate_key",
 			Reason:    err.Error(),
 		}
 	}
+	w := NewWalletFromKey(privateKey)
+	audit(AuditGenerateKey, DefaultAccount, w.Address, nil)
+	return w, nil
+}
 
+// NewWalletFromKey creates a wallet around an existing private key, such as
+// one loaded from disk
+func NewWalletFromKey(privateKey *ecdsa.PrivateKey) *Wallet {
 	publicKey := &privateKey.PublicKey
 	address := generateAddress(publicKey)
 
@@ -146,7 +167,7 @@ func NewWallet() (*Wallet, error) {
 		AddressBook:     make(map[string]*AddressBookEntry),
 		logger:          zap.NewNop(),
 		rateLimiter:     NewRateLimiter(),
-	}, nil
+	}
 }
 
 // NewHDWallet creates a new HD wallet
@@ -162,24 +183,34 @@ func NewHDWallet() (*Wallet, error) {
 		return nil, fmt.Errorf("failed to generate mnemonic: %v", err)
 	}
 
+	return newHDWalletFromMnemonic(mnemonic)
+}
+
+// newHDWalletFromMnemonic builds the HD wallet of mnemonic. Its primary key
+// is the BIP32 master key of the seed, so the same mnemonic always restores
+// the same primary address.
+func newHDWalletFromMnemonic(mnemonic string) (*Wallet, error) {
 	// Generate seed from mnemonic
 	seed := bip39.NewSeed(mnemonic, "")
 
 	// Create master key
 	masterKey := sha256.Sum256(seed)
-
-	// Create wallet
-	wallet, err := NewWallet()
+	primary, err := newMasterKey(seed)
 	if err != nil {
 		return nil, err
 	}
 
+	// Create wallet
+	wallet := NewWalletFromKey(primary.privateKey())
+	audit(AuditGenerateKey, DefaultAccount, wallet.Address, nil)
+
 	wallet.HDWallet = &HDWallet{
 		Mnemonic:  mnemonic,
 		Seed:      seed,
 		MasterKey: masterKey[:],
 		ChildKeys: make(map[uint32][]byte),
 	}
+	wallet.HDWallet.createAccount()
 
 	return wallet, nil
 }
@@ -269,18 +300,104 @@ func (w *Wallet) SignMultiSigTransaction(txID string, tx *blockchain.Transaction
 	return nil
 }
 
-// EstimateTransactionFee estimates the fee for a transaction
-func (w *Wallet) EstimateTransactionFee(amount float64, coinType blockchain.CoinType) float64 {
-	// Base fee
-	baseFee := 0.001
+// SignTransactionWith signs every input of tx through signer, which is given
+// the hash the input's signature must cover and the account owning the input.
+// The key never has to be loaded into the wallet, so signing can be delegated
+// to a hardware device or remote service, and watch-only wallets can sign.
+// Signatures for inputs carrying a public key are checked before returning.
+func (w *Wallet) SignTransactionWith(tx *blockchain.Transaction, signer func(hash []byte, account uint32) ([]byte, error)) error {
+	err := tx.SignWith(func(hash []byte, index int) ([]byte, error) {
+		account := w.inputAccount(tx.Inputs[index])
+		sig, err := signer(hash, account)
+		audit(AuditSignTx, account, "", err)
+		return sig, err
+	})
+	if err != nil {
+		return &TransactionError{
+			Operation: "sign_transaction",
+			Reason:    err.Error(),
+			TxID:      hex.EncodeToString(tx.ID),
+		}
+	}
 
-	// Size-based fee
-	sizeFee := float64(len(w.Address)) * 0.0001
+	for i, input := range tx.Inputs {
+		if len(input.PublicKey) == 0 {
+			continue
+		}
+		if !tx.VerifyInput(i) {
+			return &ValidationError{
+				Field:  "signature",
+				Reason: fmt.Sprintf("signer returned an invalid signature for input %d", i),
+			}
+		}
+	}
+	return nil
+}
 
-	// Priority fee based on amount
-	priorityFee := amount * 0.01
+// EstimateTransactionFee estimates the fee for sending amount of coinType: the
+// virtual size of a transaction spending the inputs CreateTransaction would
+// select, plus a change output when one is needed, at the chain's minimum
+// relay fee rate. One input is assumed when the wallet cannot fund amount.
+func (w *Wallet) EstimateTransactionFee(amount float64, coinType blockchain.CoinType, bc *blockchain.Blockchain) float64 {
+	params := bc.ConsensusParams()
+	utxos, _ := bc.UTXOSet.GetUTXOs(w.Address)
+	selected, total := blockchain.SelectUTXOs(utxos, amount, coinType, params, bc.TipHeights())
+
+	spent, outputs := utxoAddresses(selected), 1
+	if len(spent) == 0 {
+		spent = []string{w.Address}
+	}
+	if total > amount {
+		outputs = 2
+	}
+	return float64(estimateVirtualSize(spent, outputs, coinType)) * params.MinRelayFeeRate
+}
+
+// BumpFeeEstimate returns the total fee a replace-by-fee replacement of tx,
+// which pays oldFee, needs to reach targetRate per virtual byte. The
+// replacement is assumed to be the size of tx. Since a replacement must pay
+// more than the fee it evicts, a tx already at the target rate is bumped by
+// the default minimum relay fee rate instead.
+func BumpFeeEstimate(oldFee, targetRate float64, tx *blockchain.Transaction) float64 {
+	vsize := float64(tx.VirtualSize())
+	if fee := targetRate * vsize; fee > oldFee {
+		return fee
+	}
+	return oldFee + blockchain.DefaultMinRelayFeeRate*vsize
+}
+
+// estimateVirtualSize returns the virtual size of a signed transaction with
+// one input spending an output paying each of the spent addresses and the
+// given number of outputs. Each input is sized for the type of address it
+// spends, so inputs spending SegWit and Taproot outputs count at the witness
+// discount.
+func estimateVirtualSize(spent []string, outputs int, coinType blockchain.CoinType) int {
+	tx := blockchain.Transaction{ID: make([]byte, sha256.Size)}
+	for _, address := range spent {
+		signature, publicKey := blockchain.SignaturePlaceholder(address)
+		tx.Inputs = append(tx.Inputs, blockchain.TxInput{
+			TxID:      make([]byte, sha256.Size),
+			Signature: signature,
+			PublicKey: publicKey,
+		})
+	}
+	for i := 0; i < outputs; i++ {
+		tx.Outputs = append(tx.Outputs, blockchain.TxOutput{
+			CoinType:      coinType,
+			PublicKeyHash: make([]byte, sha256.Size),
+			Address:       strings.Repeat("0", 2*sha256.Size),
+		})
+	}
+	return tx.VirtualSize()
+}
 
-	return baseFee + sizeFee + priorityFee
+// utxoAddresses returns the address each of utxos pays
+func utxoAddresses(utxos []blockchain.UTXO) []string {
+	addresses := make([]string, len(utxos))
+	for i, utxo := range utxos {
+		addresses[i] = utxo.Address
+	}
+	return addresses
 }
 
 // AddToAddressBook adds an address to the address book
@@ -296,7 +413,9 @@ func (w *Wallet) AddToAddressBook(name, address, description string) error {
 		Name:        name,
 		Address:     address,
 		Description: description,
-		CreatedAt:   time.Now(),
+		// Stored in UTC without a monotonic reading so the entry
+		// round-trips through a backup unchanged.
+		CreatedAt: time.Now().UTC().Round(0),
 	}
 
 	return nil
@@ -315,15 +434,49 @@ func (w *Wallet) GetAddressBook() map[string]*AddressBookEntry {
 	return book
 }
 
-// BroadcastTransaction broadcasts a transaction to the network
-func (w *Wallet) BroadcastTransaction(tx *blockchain.Transaction, node *network.Node) error {
+// TestAccept checks whether the pending pool would accept tx without adding it,
+// so callers get immediate feedback before broadcasting
+func (w *Wallet) TestAccept(tx *blockchain.Transaction, bc *blockchain.Blockchain) error {
+	if tx == nil {
+		return &ValidationError{
+			Field:  "transaction",
+			Reason: "transaction is nil",
+		}
+	}
+
+	if err := bc.CheckTransaction(*tx); err != nil {
+		return &TransactionError{
+			Operation: "test_accept",
+			Reason:    err.Error(),
+			TxID:      hex.EncodeToString(tx.ID),
+		}
+	}
+
+	return nil
+}
+
+// BroadcastConfirmTimeout is how long BroadcastTransaction waits for a peer to
+// announce the transaction back before marking it failed
+var BroadcastConfirmTimeout = 30 * time.Second
+
+// TxBroadcaster is the part of a network node used to relay transactions
+type TxBroadcaster interface {
+	BroadcastMessage(msg network.NetworkMessage) error
+	ExpectInv(hash string) func(timeout time.Duration) bool
+}
+
+// BroadcastTransaction broadcasts a transaction to all connected peers and
+// waits for at least one of them to announce it back in an inv message
+func (w *Wallet) BroadcastTransaction(tx *blockchain.Transaction, node TxBroadcaster) error {
+	txID := hex.EncodeToString(tx.ID)
+
 	// Add transaction to history
 	w.AddTransactionToHistory(tx, "pending")
 
 	// Serialize transaction
-	txBytes, err := json.Marshal(tx)
+	txBytes, err := network.EncodePayload(tx)
 	if err != nil {
-		w.AddTransactionToHistory(tx, "failed")
+		w.updateTransactionStatus(txID, "failed")
 		return fmt.Errorf("failed to serialize transaction: %v", err)
 	}
 
@@ -335,16 +488,109 @@ func (w *Wallet) BroadcastTransaction(tx *blockchain.Transaction, node *network.
 		Payload:   txBytes,
 		Timestamp: time.Now(),
 	}
+	// Listen for the echo before sending, so a peer answering at once is heard
+	echoed := node.ExpectInv(txID)
 	if err := node.BroadcastMessage(*msg); err != nil {
-		w.AddTransactionToHistory(tx, "failed")
+		w.updateTransactionStatus(txID, "failed")
 		return fmt.Errorf("failed to broadcast transaction: %v", err)
 	}
 
+	// Wait for a peer to echo the transaction
+	if !echoed(BroadcastConfirmTimeout) {
+		w.updateTransactionStatus(txID, "failed")
+		return &NetworkError{
+			Operation: "broadcast",
+			Reason:    fmt.Sprintf("no peer announced transaction %s within %v", txID, BroadcastConfirmTimeout),
+		}
+	}
+
+	w.updateTransactionStatus(txID, "broadcast")
 	return nil
 }
 
-// EncryptWallet encrypts the wallet with a password
+// SendResult describes a transaction built and broadcast by Send
+type SendResult struct {
+	// TxID is the hex-encoded transaction ID
+	TxID string
+	// Fee is the total input value not paid to any output
+	Fee float64
+	// Change is the value paid back to the wallet
+	Change float64
+	// Inputs are the outputs selected to fund the transaction
+	Inputs []blockchain.TxInput
+	// Outputs are the payment followed by any change
+	Outputs []blockchain.TxOutput
+}
+
+// Send builds and signs a transaction paying amount of coinType to to, then
+// broadcasts it. If the broadcast fails the result describing the built
+// transaction is returned along with the error.
+func (w *Wallet) Send(to string, amount float64, coinType blockchain.CoinType, bc *blockchain.Blockchain, node TxBroadcaster) (*SendResult, error) {
+	tx, err := w.CreateTransaction(to, amount, coinType, bc)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SendResult{
+		TxID:    hex.EncodeToString(tx.ID),
+		Fee:     tx.GetFee(),
+		Inputs:  tx.Inputs,
+		Outputs: tx.Outputs,
+	}
+	own := make(map[string]bool)
+	for _, address := range w.accountAddresses(DefaultAccount) {
+		own[address] = true
+	}
+	for _, output := range tx.Outputs {
+		if own[output.Address] {
+			result.Change += output.Value
+		}
+	}
+
+	if err := w.BroadcastTransaction(tx, node); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// updateTransactionStatus sets the status of the most recent history record for txID
+func (w *Wallet) updateTransactionStatus(txID, status string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := len(w.Transactions) - 1; i >= 0; i-- {
+		if w.Transactions[i].TxID == txID {
+			w.Transactions[i].Status = status
+			return
+		}
+	}
+}
+
+// EncryptWallet encrypts the wallet with a password, which must not be
+// empty. An encrypted wallet must be decrypted before it is encrypted again.
 func (w *Wallet) EncryptWallet(password string) error {
+	err := w.encryptWallet(password)
+	audit(AuditEncryptWallet, DefaultAccount, w.Address, err)
+	return err
+}
+
+func (w *Wallet) encryptWallet(password string) error {
+	if err := w.checkOpen(); err != nil {
+		return err
+	}
+	if password == "" {
+		return &EncryptionError{
+			Operation: "derive_key",
+			Reason:    "password must not be empty",
+		}
+	}
+	// The key is already cleared; encrypting again would lose it
+	if w.Encrypted {
+		return &EncryptionError{
+			Operation: "encrypt",
+			Reason:    "wallet is already encrypted",
+		}
+	}
 	// Check rate limit
 	if err := w.rateLimiter.CheckRateLimit("encrypt_wallet"); err != nil {
 		return err
@@ -415,10 +661,22 @@ func (w *Wallet) EncryptWallet(password string) error {
 	return nil
 }
 
-// DecryptWallet decrypts the wallet with a password
+// DecryptWallet decrypts the wallet with a password. It returns
+// ErrInvalidPassword if password does not open the wallet, or if the wallet
+// is not encrypted.
 func (w *Wallet) DecryptWallet(password string) error {
+	err := w.decryptWallet(password)
+	audit(AuditDecryptWallet, DefaultAccount, w.Address, err)
+	return err
+}
+
+func (w *Wallet) decryptWallet(password string) error {
+	if err := w.checkOpen(); err != nil {
+		return err
+	}
+	// An unencrypted wallet has no password for any to match
 	if !w.Encrypted {
-		return nil
+		return ErrInvalidPassword
 	}
 
 	// Generate key from password
@@ -438,8 +696,12 @@ func (w *Wallet) DecryptWallet(password string) error {
 	privateKeyBytes := make([]byte, len(w.EncryptedKey))
 	stream.XORKeyStream(privateKeyBytes, w.EncryptedKey)
 
-	// Restore private key
+	// Restore private key, on the curve of the wallet's public key so
+	// legacy P-256 wallets still open
 	privateKey, err := crypto.BytesToPrivateKey(privateKeyBytes)
+	if w.PublicKey != nil {
+		privateKey, err = crypto.BytesToPrivateKeyFor(privateKeyBytes, crypto.PublicKeyToBytes(w.PublicKey))
+	}
 	if err != nil {
 		return ErrInvalidPassword
 	}
@@ -452,9 +714,14 @@ func (w *Wallet) DecryptWallet(password string) error {
 
 // GetMnemonic returns the wallet's mnemonic phrase
 func (w *Wallet) GetMnemonic() (string, error) {
+	if err := w.checkOpen(); err != nil {
+		return "", err
+	}
 	if w.HDWallet == nil {
 		return "", fmt.Errorf("not an HD wallet")
 	}
+	w.HDWallet.mu.RLock()
+	defer w.HDWallet.mu.RUnlock()
 	return w.HDWallet.Mnemonic, nil
 }
 
@@ -463,26 +730,20 @@ func RestoreFromMnemonic(mnemonic string) (*Wallet, error) {
 	if !bip39.IsMnemonicValid(mnemonic) {
 		return nil, ErrInvalidMnemonic
 	}
+	return newHDWalletFromMnemonic(mnemonic)
+}
 
-	// Generate seed from mnemonic
-	seed := bip39.NewSeed(mnemonic, "")
-
-	// Create master key
-	masterKey := sha256.Sum256(seed)
-
-	// Create wallet
-	wallet, err := NewWallet()
+// RestoreFromMnemonicOnChain restores a wallet from a mnemonic phrase and
+// rescans bc from genesis, discovering the accounts and addresses the wallet
+// has used
+func RestoreFromMnemonicOnChain(mnemonic string, bc *blockchain.Blockchain) (*Wallet, error) {
+	wallet, err := RestoreFromMnemonic(mnemonic)
 	if err != nil {
 		return nil, err
 	}
-
-	wallet.HDWallet = &HDWallet{
-		Mnemonic:  mnemonic,
-		Seed:      seed,
-		MasterKey: masterKey[:],
-		ChildKeys: make(map[uint32][]byte),
+	if err := wallet.Rescan(bc, 0, -1); err != nil {
+		return nil, err
 	}
-
 	return wallet, nil
 }
 
@@ -513,24 +774,118 @@ func generateMultiSigAddress(publicKeys [][]byte) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// GetBalance returns the balance for a specific coin type
+// GetBalance returns the unspent balance of a specific coin type across the
+// addresses of the default account
 func (w *Wallet) GetBalance(coinType blockchain.CoinType, bc *blockchain.Blockchain) float64 {
-	w.mu.RLock()
-	defer w.mu.RUnlock()
+	balance, generation, ok := w.cache.balance(bc, coinType)
+	if ok {
+		return balance
+	}
 
-	// Update balance from blockchain
-	balance := bc.GetBalance(w.Address, coinType)
-	w.balances[coinType] = balance
+	balance = 0
+	for _, address := range w.accountAddresses(DefaultAccount) {
+		balance += bc.UTXOSet.GetBalance(address, coinType)
+	}
+	w.cache.storeBalance(generation, coinType, balance)
+	return balance
+}
 
+// BalanceDetail splits a balance into confirmed funds and pending mempool activity
+type BalanceDetail struct {
+	// Confirmed is the unspent on-chain balance
+	Confirmed float64
+	// PendingIncoming is paid to the wallet by transactions still in the mempool
+	PendingIncoming float64
+	// PendingOutgoing is confirmed funds locked by the wallet's own pending spends
+	PendingOutgoing float64
+	// Immature is confirmed funds that cannot be spent yet, such as young coinbase outputs
+	Immature float64
+}
+
+// Available returns the confirmed balance not locked by pending spends or maturity
+func (b BalanceDetail) Available() float64 {
+	return b.Confirmed - b.PendingOutgoing - b.Immature
+}
+
+// GetSpendableBalance returns the confirmed balance of the default account
+// that can be spent now, excluding coinbase outputs that have not reached
+// maturity
+func (w *Wallet) GetSpendableBalance(coinType blockchain.CoinType, bc *blockchain.Blockchain) float64 {
+	heights := bc.TipHeights()
+	params := bc.ConsensusParams()
+
+	var balance float64
+	for _, address := range w.accountAddresses(DefaultAccount) {
+		utxos, _ := bc.UTXOSet.GetUTXOs(address)
+		for _, utxo := range utxos {
+			if canSpend(utxo, coinType, params, heights) {
+				balance += utxo.Amount
+			}
+		}
+	}
 	return balance
 }
 
-// GetAllBalances returns balances for all coin types
+// canSpend reports whether utxo can fund an output of coinType now: it must
+// be of that coin and mature
+func canSpend(utxo blockchain.UTXO, coinType blockchain.CoinType, params blockchain.ConsensusParams, heights blockchain.ChainHeights) bool {
+	return utxo.CoinType == coinType && params.IsSpendable(utxo, heights.Of(utxo))
+}
+
+// GetBalanceDetailed returns the confirmed balance of the default account
+// along with pending incoming and outgoing amounts from the blockchain's
+// pending transactions
+func (w *Wallet) GetBalanceDetailed(coinType blockchain.CoinType, bc *blockchain.Blockchain) BalanceDetail {
+	addresses := w.accountAddresses(DefaultAccount)
+	own := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		own[address] = true
+	}
+
+	var detail BalanceDetail
+	heights := bc.TipHeights()
+	params := bc.ConsensusParams()
+	for _, address := range addresses {
+		detail.Confirmed += bc.UTXOSet.GetBalance(address, coinType)
+		utxos, _ := bc.UTXOSet.GetUTXOs(address)
+		for _, utxo := range utxos {
+			if utxo.CoinType == coinType && !utxo.Spent && !params.IsSpendable(utxo, heights.Of(utxo)) {
+				detail.Immature += utxo.Amount
+			}
+		}
+	}
+
+	for _, tx := range bc.GetPendingTransactions() {
+		for _, input := range tx.Inputs {
+			utxo := bc.UTXOSet.GetUTXO(input.TxID, input.OutputIndex)
+			if own[utxo.Address] && utxo.CoinType == coinType {
+				detail.PendingOutgoing += utxo.Amount
+			}
+		}
+		for _, output := range tx.Outputs {
+			if own[output.Address] && output.CoinType == coinType {
+				detail.PendingIncoming += output.Value
+			}
+		}
+	}
+
+	return detail
+}
+
+// GetAllBalances returns the default account's balances for all coin types
 func (w *Wallet) GetAllBalances(bc *blockchain.Blockchain) map[blockchain.CoinType]float64 {
-	w.mu.RLock()
-	defer w.mu.RUnlock()
+	balances, generation, ok := w.cache.allBalances(bc)
+	if ok {
+		return balances
+	}
 
-	balances := make(map[blockchain.CoinType]float64)
+	balances = make(map[blockchain.CoinType]float64)
+	held := make(map[blockchain.CoinType]float64)
+	for _, address := range w.accountAddresses(DefaultAccount) {
+		for coinType, amount := range bc.GetAddressBalances(address) {
+			held[coinType] += amount
+		}
+	}
 
 	// Update balances for all coin types
 	for _, coinType := range []blockchain.CoinType{
@@ -539,14 +894,16 @@ func (w *Wallet) GetAllBalances(bc *blockchain.Blockchain) map[blockchain.CoinTy
 		blockchain.Limnah, blockchain.Antion, blockchain.Senum,
 		blockchain.Amnor, blockchain.Ezrom, blockchain.Onti,
 	} {
-		balances[coinType] = bc.GetBalance(w.Address, coinType)
+		balances[coinType] = held[coinType]
 	}
 
-	w.balances = balances
+	w.cache.storeAllBalances(generation, balances)
 	return balances
 }
 
-// CreateTransaction creates a new transaction
+// CreateTransaction creates and signs a transaction paying amount of coinType
+// to an address from the default account. It pays the minimum relay fee for
+// its size out of the change.
 func (w *Wallet) CreateTransaction(to string, amount float64, coinType blockchain.CoinType, bc *blockchain.Blockchain) (*blockchain.Transaction, error) {
 	// Check rate limit
 	if err := w.rateLimiter.CheckRateLimit("create_transaction"); err != nil {
@@ -568,68 +925,112 @@ func (w *Wallet) CreateTransaction(to string, amount float64, coinType blockchai
 		}
 	}
 
-	// Get UTXOs for the sender
-	utxos, err := bc.UTXOSet.GetUTXOs(w.Address)
+	// Get UTXOs of every address of the default account
+	owned, err := w.accountKeys(DefaultAccount)
 	if err != nil {
 		return nil, &TransactionError{
-			Operation: "get_utxos",
+			Operation: "derive_addresses",
 			Reason:    err.Error(),
 		}
 	}
-
-	// Find UTXOs with the specified coin type
-	var inputs []blockchain.TxInput
-	var totalInput float64
-	for _, utxo := range utxos {
-		if utxo.CoinType == coinType {
-			input := blockchain.TxInput{
-				TxID:        []byte(utxo.TxID),
-				OutputIndex: utxo.Index,
-				Amount:      utxo.Amount,
-				PublicKey:   []byte(w.Address),
-			}
-			inputs = append(inputs, input)
-			totalInput += utxo.Amount
-
-			if totalInput >= amount {
-				break
+	keys := make(map[string]*ecdsa.PrivateKey, len(owned))
+	var utxos []blockchain.UTXO
+	for _, o := range owned {
+		if o.key == nil {
+			continue
+		}
+		keys[o.address] = o.key
+		addressUTXOs, err := bc.UTXOSet.GetUTXOs(o.address)
+		if err != nil {
+			return nil, &TransactionError{
+				Operation: "get_utxos",
+				Reason:    err.Error(),
 			}
 		}
+		utxos = append(utxos, addressUTXOs...)
+	}
+
+	// Find spendable UTXOs that can fund the amount and the fee, at the
+	// chain's minimum relay fee rate, for the inputs selected and a change
+	// output. Each input selected adds to the fee, so select again until the
+	// fee is covered.
+	params, heights := bc.ConsensusParams(), bc.TipHeights()
+	var selected []blockchain.UTXO
+	var totalInput, fee float64
+	for {
+		selected, totalInput = blockchain.SelectUTXOs(utxos, amount+fee, coinType, params, heights)
+		needed := float64(estimateVirtualSize(utxoAddresses(selected), 2, coinType)) * params.MinRelayFeeRate
+		if needed <= fee || totalInput < amount+fee {
+			break
+		}
+		fee = needed
 	}
-
-	if totalInput < amount {
+	if totalInput < amount+fee {
 		return nil, &InsufficientFundsError{
-			Required:  amount,
+			Required:  amount + fee,
 			Available: totalInput,
 			CoinType:  coinType.String(),
 		}
 	}
 
-	// Create outputs
+	policy := w.signingPolicy()
+	var inputs []blockchain.TxInput
+	spentFrom := make([]string, 0, len(selected))
+	for _, utxo := range selected {
+		inputs = append(inputs, blockchain.TxInput{
+			TxID:        []byte(utxo.TxID),
+			OutputIndex: utxo.Index,
+			Amount:      utxo.Amount,
+			PublicKey:   policy.inputPublicKey(keys[utxo.Address], utxo.Address),
+		})
+		spentFrom = append(spentFrom, utxo.Address)
+	}
+
+	// Create outputs, locked to the hash each address commits to
+	recipient, _ := decodeAddress(to)
 	outputs := []blockchain.TxOutput{
 		{
 			Value:         amount,
 			CoinType:      coinType,
-			PublicKeyHash: []byte(to),
+			PublicKeyHash: recipient.Hash,
 			Address:       to,
 		},
 	}
 
-	// Add change output if needed
-	if totalInput > amount {
+	// Add change output if needed, on the internal chain for HD wallets. The
+	// fee comes out of the change.
+	if change := totalInput - amount - fee; change > 0 {
+		changeAddress, err := w.changeAddress()
+		if err != nil {
+			return nil, &TransactionError{
+				Operation: "change_address",
+				Reason:    err.Error(),
+			}
+		}
+		changeHash, err := decodeAddress(changeAddress)
+		if err != nil {
+			return nil, &TransactionError{
+				Operation: "change_address",
+				Reason:    err.Error(),
+			}
+		}
 		outputs = append(outputs, blockchain.TxOutput{
-			Value:         totalInput - amount,
+			Value:         change,
 			CoinType:      coinType,
-			PublicKeyHash: []byte(w.Address),
-			Address:       w.Address,
+			PublicKeyHash: changeHash.Hash,
+			Address:       changeAddress,
 		})
 	}
 
 	// Create transaction
 	tx := blockchain.NewTransaction(w.Address, to, amount, coinType, inputs, outputs)
 
-	// Sign transaction
-	if err := tx.Sign(w.PrivateKey.D.Bytes()); err != nil {
+	// Sign each input with the key of the address it spends from
+	err = tx.SignWith(func(hash []byte, index int) ([]byte, error) {
+		return policy.sign(hash, keys[spentFrom[index]], spentFrom[index])
+	})
+	audit(AuditSignTx, DefaultAccount, w.Address, err)
+	if err != nil {
 		return nil, &TransactionError{
 			Operation: "sign_transaction",
 			Reason:    err.Error(),
@@ -650,14 +1051,9 @@ func (w *Wallet) CreateTransaction(to string, amount float64, coinType blockchai
 
 // isValidAddress validates a wallet address
 func isValidAddress(address string) bool {
-	// Check if the address is a valid hex string
-	_, err := hex.DecodeString(address)
-	if err != nil {
-		return false
-	}
-
-	// Check if the address has the correct length (32 bytes = 64 hex characters)
-	return len(address) == 64
+	// Any address type committing to 32 bytes
+	_, err := decodeAddress(address)
+	return err == nil
 }
 
 // BackupWallet creates a backup of the wallet with improved error handling
@@ -665,8 +1061,9 @@ func (w *Wallet) BackupWallet(path string) error {
 	// Validate path
 	if path == "" {
 		return &BackupError{
-			Path:   path,
-			Reason: "empty backup path",
+			Operation: "validate_path",
+			Path:      path,
+			Reason:    "empty backup path",
 		}
 	}
 
@@ -674,8 +1071,9 @@ func (w *Wallet) BackupWallet(path string) error {
 	backupDir := filepath.Dir(path)
 	if err := os.MkdirAll(backupDir, 0755); err != nil {
 		return &BackupError{
-			Path:   path,
-			Reason: fmt.Sprintf("failed to create backup directory: %v", err),
+			Operation: "create_directory",
+			Path:      path,
+			Reason:    fmt.Sprintf("failed to create backup directory: %v", err),
 			Details: map[string]interface{}{
 				"directory": backupDir,
 				"error":     err.Error(),
@@ -688,8 +1086,9 @@ func (w *Wallet) BackupWallet(path string) error {
 	tempFile, err := os.Create(tempPath)
 	if err != nil {
 		return &BackupError{
-			Path:   path,
-			Reason: fmt.Sprintf("failed to create temporary file: %v", err),
+			Operation: "create_file",
+			Path:      path,
+			Reason:    fmt.Sprintf("failed to create temporary file: %v", err),
 			Details: map[string]interface{}{
 				"temp_path": tempPath,
 				"error":     err.Error(),
@@ -702,8 +1101,9 @@ func (w *Wallet) BackupWallet(path string) error {
 	encryptedData, err := w.encryptWalletData()
 	if err != nil {
 		return &BackupError{
-			Path:   path,
-			Reason: fmt.Sprintf("failed to encrypt wallet data: %v", err),
+			Operation: "encrypt",
+			Path:      path,
+			Reason:    fmt.Sprintf("failed to encrypt wallet data: %v", err),
 			Details: map[string]interface{}{
 				"error": err.Error(),
 			},
@@ -713,8 +1113,9 @@ func (w *Wallet) BackupWallet(path string) error {
 	// Write encrypted data to temporary file
 	if _, err := tempFile.Write(encryptedData); err != nil {
 		return &BackupError{
-			Path:   path,
-			Reason: fmt.Sprintf("failed to write wallet data: %v", err),
+			Operation: "write",
+			Path:      path,
+			Reason:    fmt.Sprintf("failed to write wallet data: %v", err),
 			Details: map[string]interface{}{
 				"error": err.Error(),
 			},
@@ -724,8 +1125,9 @@ func (w *Wallet) BackupWallet(path string) error {
 	// Close temporary file
 	if err := tempFile.Close(); err != nil {
 		return &BackupError{
-			Path:   path,
-			Reason: fmt.Sprintf("failed to close temporary file: %v", err),
+			Operation: "close_file",
+			Path:      path,
+			Reason:    fmt.Sprintf("failed to close temporary file: %v", err),
 			Details: map[string]interface{}{
 				"error": err.Error(),
 			},
@@ -735,8 +1137,9 @@ func (w *Wallet) BackupWallet(path string) error {
 	// Atomically rename temporary file to final path
 	if err := os.Rename(tempPath, path); err != nil {
 		return &BackupError{
-			Path:   path,
-			Reason: fmt.Sprintf("failed to finalize backup: %v", err),
+			Operation: "rename",
+			Path:      path,
+			Reason:    fmt.Sprintf("failed to finalize backup: %v", err),
 			Details: map[string]interface{}{
 				"temp_path": tempPath,
 				"error":     err.Error(),
@@ -798,8 +1201,8 @@ func (w *Wallet) RestoreWallet(path string) error {
 		return ErrInvalidBackup
 	}
 
-	// Restore private key
-	privateKey, err := crypto.BytesToPrivateKey(backup.PrivateKey)
+	// Restore private key, on the curve of the backed up public key
+	privateKey, err := crypto.BytesToPrivateKeyFor(backup.PrivateKey, backup.PublicKey)
 	if err != nil {
 		return fmt.Errorf("failed to restore private key: %v", err)
 	}
@@ -833,6 +1236,109 @@ func (w *Wallet) RestoreWallet(path string) error {
 	return nil
 }
 
+// Backup writes the wallet to path. An encrypted wallet's key stays
+// encrypted under its password; an unencrypted wallet's backup holds its
+// private key and must be kept as safe as the wallet itself.
+func (w *Wallet) Backup(path string) error {
+	if err := w.checkOpen(); err != nil {
+		return err
+	}
+	w.mu.RLock()
+	backup := WalletBackup{
+		Address:         w.Address,
+		Transactions:    w.Transactions,
+		MultiSigWallets: w.MultiSigWallets,
+		HDWallet:        w.HDWallet,
+		AddressBook:     w.AddressBook,
+		Salt:            w.Salt,
+		IV:              w.IV,
+		Balances:        w.balances,
+		Encrypted:       w.Encrypted,
+		EncryptedKey:    w.EncryptedKey,
+	}
+	// An HD wallet that lost its keys restores them from its seed
+	if w.PublicKey != nil {
+		backup.PublicKey = crypto.PublicKeyToBytes(w.PublicKey)
+	}
+	if !w.Encrypted && w.PrivateKey != nil {
+		backup.PrivateKey = crypto.PrivateKeyToBytes(w.PrivateKey)
+	}
+	if backup.PublicKey == nil && (w.HDWallet == nil || len(w.HDWallet.Seed) == 0) {
+		w.mu.RUnlock()
+		return &BackupError{Operation: "validate_keys", Path: path, Reason: "wallet has no keys to back up"}
+	}
+	data, err := json.Marshal(backup)
+	w.mu.RUnlock()
+	if err != nil {
+		return &BackupError{Operation: "serialize", Path: path, Reason: err.Error()}
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return &BackupError{Operation: "write", Path: path, Reason: err.Error()}
+	}
+	return nil
+}
+
+// Restore loads a wallet written by Backup. An encrypted wallet comes back
+// encrypted, to be opened with DecryptWallet. It returns ErrInvalidBackup if
+// path does not hold a backup of a wallet.
+func Restore(path string) (*Wallet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &RestoreError{Path: path, Reason: fmt.Sprintf("failed to read backup file: %v", err)}
+	}
+	var backup WalletBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, ErrInvalidBackup
+	}
+
+	var privateKey *ecdsa.PrivateKey
+	var publicKey *ecdsa.PublicKey
+	switch {
+	case backup.PublicKey == nil && backup.HDWallet != nil && len(backup.HDWallet.Seed) > 0:
+		primary, err := newMasterKey(backup.HDWallet.Seed)
+		if err != nil {
+			return nil, ErrInvalidBackup
+		}
+		privateKey = primary.privateKey()
+		publicKey = &privateKey.PublicKey
+	default:
+		var err error
+		if publicKey, err = crypto.BytesToPublicKey(backup.PublicKey); err != nil {
+			return nil, ErrInvalidBackup
+		}
+		if !backup.Encrypted {
+			if privateKey, err = crypto.BytesToPrivateKeyFor(backup.PrivateKey, backup.PublicKey); err != nil {
+				return nil, ErrInvalidBackup
+			}
+		}
+	}
+	if backup.Address != generateAddress(publicKey) {
+		return nil, ErrInvalidBackup
+	}
+
+	w := &Wallet{
+		PrivateKey:      privateKey,
+		PublicKey:       publicKey,
+		Address:         backup.Address,
+		balances:        backup.Balances,
+		Transactions:    backup.Transactions,
+		MultiSigWallets: backup.MultiSigWallets,
+		HDWallet:        backup.HDWallet,
+		AddressBook:     backup.AddressBook,
+		Encrypted:       backup.Encrypted,
+		Salt:            backup.Salt,
+		IV:              backup.IV,
+		EncryptedKey:    backup.EncryptedKey,
+		logger:          zap.NewNop(),
+		rateLimiter:     NewRateLimiter(),
+	}
+	if w.balances == nil {
+		w.balances = make(map[blockchain.CoinType]float64)
+	}
+	return w, nil
+}
+
 // ExportPublicKey returns the wallet's public key in bytes
 func (w *Wallet) ExportPublicKey() []byte {
 	w.mu.RLock()
@@ -847,80 +1353,101 @@ func ImportPublicKey(publicKeyBytes []byte) (*ecdsa.PublicKey, error) {
 
 // SignMessage signs a message with the wallet's private key
 func (w *Wallet) SignMessage(message []byte) ([]byte, error) {
+	if err := w.checkOpen(); err != nil {
+		audit(AuditSign, DefaultAccount, w.Address, err)
+		return nil, err
+	}
 	hash := sha256.Sum256(message)
-	return crypto.Sign(hash[:], w.PrivateKey.D.Bytes())
+	sig, err := crypto.SignKey(hash[:], w.PrivateKey)
+	audit(AuditSign, DefaultAccount, w.Address, err)
+	return sig, err
 }
 
 // VerifyMessage verifies a message signature
 func (w *Wallet) VerifyMessage(message, signature []byte) bool {
 	hash := sha256.Sum256(message)
-	return crypto.Verify(hash[:], signature, crypto.PublicKeyToBytes(w.PublicKey))
+	valid := crypto.Verify(hash[:], signature, crypto.PublicKeyToBytes(w.PublicKey))
+	if valid {
+		audit(AuditVerify, DefaultAccount, w.Address, nil)
+	} else {
+		audit(AuditVerify, DefaultAccount, w.Address, ErrInvalidSignature)
+	}
+	return valid
 }
 
-// CreateEphraimCoin creates an Ephraim coin from Golden Block coins
-func (w *Wallet) CreateEphraimCoin(bc *blockchain.Blockchain) error {
-	// Check if we have enough coins to create an Ephraim coin
-	balances := w.GetAllBalances(bc)
-	if balances[blockchain.Limnah] < 1 {
-		return fmt.Errorf("insufficient Limnah coins to create Ephraim coin")
+// SignMessageRecoverable signs a message so the signer's address can be
+// recovered from the signature with RecoverAddress
+func (w *Wallet) SignMessageRecoverable(message []byte) ([]byte, error) {
+	if err := w.checkOpen(); err != nil {
+		audit(AuditSign, DefaultAccount, w.Address, err)
+		return nil, err
 	}
+	hash := sha256.Sum256(message)
+	sig, err := crypto.SignRecoverable(hash[:], w.PrivateKey)
+	audit(AuditSign, DefaultAccount, w.Address, err)
+	return sig, err
+}
 
-	// Create a transaction to convert Limnah to Ephraim
-	tx, err := w.CreateTransaction(w.Address, 1, blockchain.Ephraim, bc)
+// RecoverAddress returns the address of the wallet that produced a
+// recoverable signature over message
+func RecoverAddress(message, signature []byte) (string, error) {
+	hash := sha256.Sum256(message)
+	publicKey, err := crypto.RecoverPublicKey(hash[:], signature)
 	if err != nil {
-		return fmt.Errorf("failed to create conversion transaction: %v", err)
-	}
-
-	// Add transaction to the blockchain
-	if err := bc.AddTransaction(*tx); err != nil {
-		return fmt.Errorf("failed to add conversion transaction: %v", err)
+		return "", &ValidationError{
+			Field:  "signature",
+			Reason: err.Error(),
+		}
 	}
-
-	return nil
+	return generateAddress(publicKey), nil
 }
 
-// CreateManassehCoin creates a Manasseh coin from Silver Block coins
-func (w *Wallet) CreateManassehCoin(bc *blockchain.Blockchain) error {
-	// Check if we have enough coins to create a Manasseh coin
-	balances := w.GetAllBalances(bc)
-	if balances[blockchain.Onti] < 1 {
-		return fmt.Errorf("insufficient Onti coins to create Manasseh coin")
+// VerifyMessageByAddress checks that a recoverable signature over message was
+// produced by the wallet owning address: the key recovered from the
+// signature must derive address, of whatever address type it is
+func VerifyMessageByAddress(message, signature []byte, address string) bool {
+	claimed, err := decodeAddress(address)
+	if err != nil {
+		return false
 	}
-
-	// Create a transaction to convert Onti to Manasseh
-	tx, err := w.CreateTransaction(w.Address, 1, blockchain.Manasseh, bc)
+	hash := sha256.Sum256(message)
+	publicKey, err := crypto.RecoverPublicKey(hash[:], signature)
 	if err != nil {
-		return fmt.Errorf("failed to create conversion transaction: %v", err)
+		return false
 	}
-
-	// Add transaction to the blockchain
-	if err := bc.AddTransaction(*tx); err != nil {
-		return fmt.Errorf("failed to add conversion transaction: %v", err)
+	derived, err := newAddress(publicKey, claimed.Type)
+	if err != nil {
+		return false
 	}
-
-	return nil
+	return derived.String() == claimed.String()
 }
 
-// CreateJosephCoin creates a Joseph coin from Ephraim and Manasseh coins
-func (w *Wallet) CreateJosephCoin(bc *blockchain.Blockchain) error {
-	// Check if we have both Ephraim and Manasseh coins
-	balances := w.GetAllBalances(bc)
-	if balances[blockchain.Ephraim] < 1 || balances[blockchain.Manasseh] < 1 {
-		return fmt.Errorf("insufficient Ephraim or Manasseh coins to create Joseph coin")
-	}
+// VerifyMessageFrom checks a third party's recoverable signature over message
+// against the address it claims to come from. Unlike VerifyMessage it does
+// not involve the wallet's own key.
+func VerifyMessageFrom(message, signature []byte, address string) bool {
+	return VerifyMessageByAddress(message, signature, address)
+}
 
-	// Create a transaction to combine Ephraim and Manasseh into Joseph
-	tx, err := w.CreateTransaction(w.Address, 1, blockchain.Joseph, bc)
-	if err != nil {
-		return fmt.Errorf("failed to create conversion transaction: %v", err)
-	}
+// CreateEphraimCoin mints an Ephraim coin from the wallet's Golden Block
+// coins, as blockchain.MintRequirements lists
+func (w *Wallet) CreateEphraimCoin(bc *blockchain.Blockchain) error {
+	_, err := w.mintSpecialCoin(bc, blockchain.Ephraim)
+	return err
+}
 
-	// Add transaction to the blockchain
-	if err := bc.AddTransaction(*tx); err != nil {
-		return fmt.Errorf("failed to add conversion transaction: %v", err)
-	}
+// CreateManassehCoin mints a Manasseh coin from the wallet's Silver Block
+// coins and Antion, as blockchain.MintRequirements lists
+func (w *Wallet) CreateManassehCoin(bc *blockchain.Blockchain) error {
+	_, err := w.mintSpecialCoin(bc, blockchain.Manasseh)
+	return err
+}
 
-	return nil
+// CreateJosephCoin mints a Joseph coin from one of the wallet's Ephraim and
+// one of its Manasseh
+func (w *Wallet) CreateJosephCoin(bc *blockchain.Blockchain) error {
+	_, err := w.mintSpecialCoin(bc, blockchain.Joseph)
+	return err
 }
 
 // Serialize converts the wallet to a byte array
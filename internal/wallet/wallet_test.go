@@ -1,15 +1,21 @@
 package wallet
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"byc/internal/blockchain"
 	"byc/internal/crypto"
+	"byc/internal/network"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -85,7 +91,7 @@ func TestBackupAndRestore(t *testing.T) {
 	require.NoError(t, err)
 
 	// Add some test data
-	wallet.AddToAddressBook("Test", "test-address", "Test address")
+	require.NoError(t, wallet.AddToAddressBook("Test", testAddress(t), "Test address"))
 	wallet.balances[blockchain.Leah] = 100
 
 	// Create backup
@@ -115,17 +121,19 @@ func TestTransactionCreation(t *testing.T) {
 	// Create a test blockchain
 	bc := blockchain.NewBlockchain()
 
+	recipient := testAddress(t)
+
 	// Test invalid amount
-	_, err = wallet.CreateTransaction("recipient", -1, blockchain.Leah, bc)
-	assert.Equal(t, ErrInvalidAmount, err)
+	_, err = wallet.CreateTransaction(recipient, -1, blockchain.Leah, bc)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
 
 	// Test invalid address
 	_, err = wallet.CreateTransaction("invalid-address", 1, blockchain.Leah, bc)
-	assert.Equal(t, ErrInvalidAddress, err)
+	assert.ErrorIs(t, err, ErrInvalidAddress)
 
 	// Test insufficient funds
-	_, err = wallet.CreateTransaction("recipient", 1000, blockchain.Leah, bc)
-	assert.Equal(t, ErrInsufficientFunds, err)
+	_, err = wallet.CreateTransaction(recipient, 1000, blockchain.Leah, bc)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
 }
 
 // TestMultiSigWallet tests multi-signature wallet functionality
@@ -136,7 +144,7 @@ func TestMultiSigWallet(t *testing.T) {
 	// Generate test public keys
 	var publicKeys [][]byte
 	for i := 0; i < 3; i++ {
-		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		privateKey, err := crypto.GenerateKey()
 		require.NoError(t, err)
 		publicKeys = append(publicKeys, crypto.PublicKeyToBytes(&privateKey.PublicKey))
 	}
@@ -159,7 +167,8 @@ func TestAddressBook(t *testing.T) {
 	require.NoError(t, err)
 
 	// Test adding address
-	err = wallet.AddToAddressBook("Test", "test-address", "Test address")
+	contact := testAddress(t)
+	err = wallet.AddToAddressBook("Test", contact, "Test address")
 	assert.NoError(t, err)
 
 	// Test invalid address
@@ -169,7 +178,7 @@ func TestAddressBook(t *testing.T) {
 	// Test getting address book
 	book := wallet.GetAddressBook()
 	assert.NotEmpty(t, book)
-	assert.Equal(t, "Test", book["test-address"].Name)
+	assert.Equal(t, "Test", book[contact].Name)
 }
 
 // TestSpecialCoins tests special coin conversion functionality
@@ -215,10 +224,12 @@ func BenchmarkTransactionCreation(b *testing.B) {
 	require.NoError(b, err)
 	bc := blockchain.NewBlockchain()
 
+	recipient := testAddress(b)
+
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, err := wallet.CreateTransaction("recipient", 1, blockchain.Leah, bc)
-		if err != nil && err != ErrInsufficientFunds {
+		_, err := wallet.CreateTransaction(recipient, 1, blockchain.Leah, bc)
+		if err != nil && !errors.Is(err, ErrInsufficientFunds) {
 			require.NoError(b, err)
 		}
 	}
@@ -271,3 +282,1466 @@ func TestRecoveryMechanisms(t *testing.T) {
 	assert.NotNil(t, restoredHDWallet.PrivateKey)
 	assert.NotNil(t, restoredHDWallet.PublicKey)
 }
+
+// stubBroadcaster is a node that echoes an inv for every transaction it
+// relays. Like a real node, it only delivers an echo to a waiter already
+// registered when the echo arrives.
+type stubBroadcaster struct {
+	echo    bool
+	mu      sync.Mutex
+	waiters map[string]chan struct{}
+}
+
+func newStubBroadcaster(echo bool) *stubBroadcaster {
+	return &stubBroadcaster{echo: echo, waiters: make(map[string]chan struct{})}
+}
+
+func (s *stubBroadcaster) BroadcastMessage(msg network.NetworkMessage) error {
+	var tx blockchain.Transaction
+	if err := network.DecodePayload(msg.Payload, &tx); err != nil {
+		return err
+	}
+	if s.echo {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if ch, ok := s.waiters[hex.EncodeToString(tx.ID)]; ok {
+			close(ch)
+			delete(s.waiters, hex.EncodeToString(tx.ID))
+		}
+	}
+	return nil
+}
+
+func (s *stubBroadcaster) ExpectInv(hash string) func(timeout time.Duration) bool {
+	ch := make(chan struct{})
+	s.mu.Lock()
+	s.waiters[hash] = ch
+	s.mu.Unlock()
+
+	return func(timeout time.Duration) bool {
+		select {
+		case <-ch:
+			return true
+		case <-time.After(timeout):
+			return false
+		}
+	}
+}
+
+// mempoolBroadcaster relays transactions into a chain's mempool, echoing
+// only the ones it accepts
+type mempoolBroadcaster struct {
+	*stubBroadcaster
+	bc *blockchain.Blockchain
+}
+
+func newMempoolBroadcaster(bc *blockchain.Blockchain) *mempoolBroadcaster {
+	return &mempoolBroadcaster{stubBroadcaster: newStubBroadcaster(true), bc: bc}
+}
+
+func (m *mempoolBroadcaster) BroadcastMessage(msg network.NetworkMessage) error {
+	var tx blockchain.Transaction
+	if err := network.DecodePayload(msg.Payload, &tx); err != nil {
+		return err
+	}
+	if err := m.bc.AddTransaction(tx); err != nil {
+		return err
+	}
+	return m.stubBroadcaster.BroadcastMessage(msg)
+}
+
+// TestBroadcastTransaction tests that the history record follows propagation
+func TestBroadcastTransaction(t *testing.T) {
+	wallet, err := NewWallet()
+	require.NoError(t, err)
+
+	tx := &blockchain.Transaction{
+		ID:      []byte("test-tx"),
+		Inputs:  []blockchain.TxInput{{Address: wallet.Address, Amount: 2}},
+		Outputs: []blockchain.TxOutput{{Value: 1, CoinType: blockchain.Leah, Address: "recipient"}},
+	}
+
+	// Echoed by a peer
+	err = wallet.BroadcastTransaction(tx, newStubBroadcaster(true))
+	require.NoError(t, err)
+	history := wallet.GetTransactionHistory()
+	require.Len(t, history, 1)
+	assert.Equal(t, "broadcast", history[0].Status)
+
+	// Never echoed
+	oldTimeout := BroadcastConfirmTimeout
+	BroadcastConfirmTimeout = 50 * time.Millisecond
+	defer func() { BroadcastConfirmTimeout = oldTimeout }()
+
+	tx.ID = []byte("test-tx-2")
+	err = wallet.BroadcastTransaction(tx, newStubBroadcaster(false))
+	assert.Error(t, err)
+	history = wallet.GetTransactionHistory()
+	require.Len(t, history, 2)
+	assert.Equal(t, "failed", history[1].Status)
+}
+
+// testAddress returns the address of a fresh wallet, for tests that need a
+// valid address to pay or list
+func testAddress(t testing.TB) string {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	return w.Address
+}
+
+// fundWallet gives the wallet a spendable UTXO and returns the funding transaction
+func fundWallet(t *testing.T, w *Wallet, bc *blockchain.Blockchain, amount float64) *blockchain.Transaction {
+	funding := &blockchain.Transaction{
+		ID: []byte("funding-tx"),
+		Outputs: []blockchain.TxOutput{{
+			Value:         amount,
+			CoinType:      blockchain.Leah,
+			PublicKeyHash: crypto.HashPublicKey(w.PublicKey),
+			Address:       w.Address,
+		}},
+	}
+	require.NoError(t, bc.UTXOSet.UpdateWithTransaction(funding))
+	return funding
+}
+
+// signedSpend builds a signed transaction spending the funding output
+func signedSpend(t *testing.T, w *Wallet, funding *blockchain.Transaction, declared, send float64) *blockchain.Transaction {
+	tx := &blockchain.Transaction{
+		Inputs: []blockchain.TxInput{{
+			TxID:        funding.ID,
+			OutputIndex: 0,
+			Amount:      declared,
+			PublicKey:   crypto.PublicKeyToBytes(w.PublicKey),
+			Address:     w.Address,
+		}},
+		Outputs: []blockchain.TxOutput{{
+			Value:         send,
+			CoinType:      blockchain.Leah,
+			PublicKeyHash: []byte("recipient"),
+			Address:       "recipient",
+		}},
+		Timestamp: time.Now(),
+	}
+	tx.ID = tx.CalculateHash()
+	require.NoError(t, tx.Sign(w.PrivateKey.D.Bytes()))
+	return tx
+}
+
+// TestTestAccept tests the local pending-pool acceptance check
+func TestTestAccept(t *testing.T) {
+	wallet, err := NewWallet()
+	require.NoError(t, err)
+
+	bc := blockchain.NewBlockchain()
+	funding := fundWallet(t, wallet, bc, 10)
+
+	// Valid transaction
+	tx := signedSpend(t, wallet, funding, 10, 9)
+	assert.NoError(t, wallet.TestAccept(tx, bc))
+	assert.Empty(t, bc.GetPendingTransactions())
+
+	// Inputs must claim the values of the outputs they spend
+	tx = signedSpend(t, wallet, funding, 1, 5)
+	err = wallet.TestAccept(tx, bc)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match the spent output")
+}
+
+// replaceable marks a transaction as opted into replace-by-fee and re-signs it
+func replaceable(t *testing.T, w *Wallet, tx *blockchain.Transaction) *blockchain.Transaction {
+	tx.Replaceable = true
+	tx.Inputs[0].Signature = nil
+	tx.ID = tx.CalculateHash()
+	require.NoError(t, tx.Sign(w.PrivateKey.D.Bytes()))
+	return tx
+}
+
+// TestReplaceByFee tests that only opted-in pending transactions can be replaced
+func TestReplaceByFee(t *testing.T) {
+	wallet, err := NewWallet()
+	require.NoError(t, err)
+
+	// A non-replaceable transaction rejects a higher-fee conflict
+	bc := blockchain.NewBlockchain()
+	funding := fundWallet(t, wallet, bc, 10)
+	original := signedSpend(t, wallet, funding, 10, 9)
+	require.NoError(t, bc.AddTransaction(*original))
+
+	bump := signedSpend(t, wallet, funding, 10, 8)
+	err = bc.AddTransaction(*bump)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "double spend")
+	assert.Len(t, bc.GetPendingTransactions(), 1)
+
+	// A replaceable transaction is replaced by a conflict paying a higher fee
+	bc = blockchain.NewBlockchain()
+	funding = fundWallet(t, wallet, bc, 10)
+	original = replaceable(t, wallet, signedSpend(t, wallet, funding, 10, 9))
+	require.NoError(t, bc.AddTransaction(*original))
+
+	lowFee := signedSpend(t, wallet, funding, 10, 9.5)
+	err = bc.AddTransaction(*lowFee)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "replacement fee")
+
+	bump = signedSpend(t, wallet, funding, 10, 8)
+	require.NoError(t, bc.AddTransaction(*bump))
+
+	pending := bc.GetPendingTransactions()
+	require.Len(t, pending, 1)
+	assert.Equal(t, bump.ID, pending[0].ID)
+}
+
+// TestRecoverAddress tests signing a message and recovering the signer's address
+func TestRecoverAddress(t *testing.T) {
+	wallet, err := NewWallet()
+	require.NoError(t, err)
+	other, err := NewWallet()
+	require.NoError(t, err)
+
+	message := []byte("sign in to byc at 2026-01-01T00:00:00Z")
+	signature, err := wallet.SignMessageRecoverable(message)
+	require.NoError(t, err)
+
+	address, err := RecoverAddress(message, signature)
+	require.NoError(t, err)
+	assert.Equal(t, wallet.Address, address)
+
+	assert.True(t, VerifyMessageByAddress(message, signature, wallet.Address))
+	assert.False(t, VerifyMessageByAddress(message, signature, other.Address))
+
+	// A tampered message recovers a different key or none at all
+	tampered := []byte("sign in to byc at 2026-01-01T00:00:01Z")
+	assert.False(t, VerifyMessageByAddress(tampered, signature, wallet.Address))
+
+	// Malformed signatures are rejected
+	_, err = RecoverAddress(message, signature[:64])
+	assert.Error(t, err)
+	bad := append([]byte(nil), signature...)
+	bad[64] = 7
+	_, err = RecoverAddress(message, bad)
+	assert.Error(t, err)
+}
+
+// TestVerifyMessageFrom tests verifying another wallet's signature against its address
+func TestVerifyMessageFrom(t *testing.T) {
+	verifier, err := NewWallet()
+	require.NoError(t, err)
+	signer, err := NewHDWallet()
+	require.NoError(t, err)
+
+	message := []byte("pay 3 Leah to the bearer")
+	signature, err := signer.SignMessageRecoverable(message)
+	require.NoError(t, err)
+
+	// The signer's address passes whatever wallet does the checking
+	assert.True(t, VerifyMessageFrom(message, signature, signer.Address))
+	assert.False(t, verifier.VerifyMessage(message, signature))
+
+	// So does the same key written as another address type
+	segwit, err := newAddress(signer.PublicKey, AddressTypeP2WPKH)
+	require.NoError(t, err)
+	assert.True(t, VerifyMessageFrom(message, signature, segwit.String()))
+
+	// Any other address fails, as does a malformed one
+	assert.False(t, VerifyMessageFrom(message, signature, verifier.Address))
+	other, err := signer.GetNewAddress(DefaultAccount, AddressTypeP2PKH)
+	require.NoError(t, err)
+	assert.False(t, VerifyMessageFrom(message, signature, other.String()))
+	assert.False(t, VerifyMessageFrom(message, signature, "not an address"))
+}
+
+// TestGetBalanceDetailed tests that mempool activity shows up as pending, not confirmed
+func TestGetBalanceDetailed(t *testing.T) {
+	sender, err := NewWallet()
+	require.NoError(t, err)
+	receiver, err := NewWallet()
+	require.NoError(t, err)
+
+	bc := blockchain.NewBlockchain()
+	funding := fundWallet(t, sender, bc, 10)
+
+	tx := &blockchain.Transaction{
+		Inputs: []blockchain.TxInput{{
+			TxID:        funding.ID,
+			OutputIndex: 0,
+			Amount:      10,
+			PublicKey:   crypto.PublicKeyToBytes(sender.PublicKey),
+			Address:     sender.Address,
+		}},
+		Outputs: []blockchain.TxOutput{
+			{Value: 6, CoinType: blockchain.Leah, PublicKeyHash: crypto.HashPublicKey(receiver.PublicKey), Address: receiver.Address},
+			{Value: 3.5, CoinType: blockchain.Leah, PublicKeyHash: crypto.HashPublicKey(sender.PublicKey), Address: sender.Address},
+		},
+		Timestamp: time.Now(),
+	}
+	tx.ID = tx.CalculateHash()
+	require.NoError(t, tx.Sign(sender.PrivateKey.D.Bytes()))
+	require.NoError(t, bc.AddTransaction(*tx))
+
+	incoming := receiver.GetBalanceDetailed(blockchain.Leah, bc)
+	assert.Equal(t, 0.0, incoming.Confirmed)
+	assert.Equal(t, 6.0, incoming.PendingIncoming)
+	assert.Equal(t, 0.0, incoming.PendingOutgoing)
+
+	outgoing := sender.GetBalanceDetailed(blockchain.Leah, bc)
+	assert.Equal(t, 10.0, outgoing.Confirmed)
+	assert.Equal(t, 3.5, outgoing.PendingIncoming)
+	assert.Equal(t, 10.0, outgoing.PendingOutgoing)
+	assert.Equal(t, 0.0, outgoing.Available())
+
+	// Other coin types are unaffected
+	assert.Equal(t, BalanceDetail{}, receiver.GetBalanceDetailed(blockchain.Shiblum, bc))
+}
+
+func TestImmatureCoinbaseNotSpendable(t *testing.T) {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+
+	// A coinbase confirmed in the current tip block has a single confirmation
+	tip := bc.TipHeights()[blockchain.GoldenBlock]
+	coinbase := blockchain.NewCoinbaseTransaction(w.Address, crypto.HashPublicKey(w.PublicKey), 50, blockchain.Leah, blockchain.GoldenBlock)
+	require.NoError(t, bc.UTXOSet.UpdateWithTransactionAtHeight(coinbase, blockchain.GoldenBlock, tip))
+
+	utxos, err := bc.UTXOSet.GetUTXOs(w.Address)
+	require.NoError(t, err)
+	require.Len(t, utxos, 1)
+	assert.True(t, utxos[0].IsCoinbase)
+	assert.False(t, utxos[0].IsSpendable(tip))
+	assert.Equal(t, blockchain.CoinbaseMaturity-1, utxos[0].BlocksUntilSpendable(tip))
+
+	assert.Equal(t, 0.0, w.GetSpendableBalance(blockchain.Leah, bc))
+	detail := w.GetBalanceDetailed(blockchain.Leah, bc)
+	assert.Equal(t, 50.0, detail.Confirmed)
+	assert.Equal(t, 50.0, detail.Immature)
+	assert.Equal(t, 0.0, detail.Available())
+
+	_, err = w.CreateTransaction(hex.EncodeToString(make([]byte, 32)), 10, blockchain.Leah, bc)
+	var insufficient *InsufficientFundsError
+	assert.ErrorAs(t, err, &insufficient)
+
+	// Silver blocks do not confirm a golden reward
+	for i := uint64(0); i < blockchain.CoinbaseMaturity; i++ {
+		bc.SilverBlocks = append(bc.SilverBlocks, blockchain.Block{})
+	}
+	assert.Equal(t, 0.0, w.GetSpendableBalance(blockchain.Leah, bc))
+
+	// One block short of maturity it is still locked
+	for i := uint64(0); i < blockchain.CoinbaseMaturity-2; i++ {
+		bc.GoldenBlocks = append(bc.GoldenBlocks, blockchain.Block{})
+	}
+	assert.Equal(t, 0.0, w.GetSpendableBalance(blockchain.Leah, bc))
+
+	bc.GoldenBlocks = append(bc.GoldenBlocks, blockchain.Block{})
+	assert.Equal(t, 50.0, w.GetSpendableBalance(blockchain.Leah, bc))
+	assert.Equal(t, 0.0, w.GetBalanceDetailed(blockchain.Leah, bc).Immature)
+}
+
+// appendTestBlock appends a golden block holding txs without validation
+func appendTestBlock(bc *blockchain.Blockchain, txs ...blockchain.Transaction) {
+	block := blockchain.Block{BlockType: blockchain.GoldenBlock, Transactions: txs}
+	bc.GoldenBlocks = append(bc.GoldenBlocks, block)
+	bc.Blocks = append(bc.Blocks, &block)
+}
+
+func TestRescan(t *testing.T) {
+	w, err := NewHDWallet()
+	require.NoError(t, err)
+	other := hex.EncodeToString(make([]byte, 32))
+	bc := blockchain.NewBlockchain()
+
+	coinbase := blockchain.NewCoinbaseTransaction(w.Address, crypto.HashPublicKey(w.PublicKey), 50, blockchain.Leah, blockchain.GoldenBlock)
+	appendTestBlock(bc, *coinbase)
+
+	payment := blockchain.Transaction{
+		ID:      []byte("payment"),
+		Inputs:  []blockchain.TxInput{{TxID: []byte("elsewhere"), Amount: 10, Address: other}},
+		Outputs: []blockchain.TxOutput{{Value: 10, CoinType: blockchain.Leah, Address: w.Address}},
+	}
+	appendTestBlock(bc, payment)
+
+	spend := blockchain.Transaction{
+		ID:     []byte("spend"),
+		Inputs: []blockchain.TxInput{{TxID: coinbase.ID, OutputIndex: 0, Amount: 50, Address: w.Address}},
+		Outputs: []blockchain.TxOutput{
+			{Value: 20, CoinType: blockchain.Leah, Address: other},
+			{Value: 30, CoinType: blockchain.Leah, Address: w.Address},
+		},
+	}
+	appendTestBlock(bc, spend)
+
+	// A stale pending record is replaced by the confirmed one
+	w.AddTransactionToHistory(&spend, "pending")
+
+	require.NoError(t, w.Rescan(bc, 0, -1))
+
+	assert.Equal(t, 40.0, w.RescannedBalance(blockchain.Leah))
+	assert.Len(t, w.UTXOs(), 2)
+
+	history := w.GetTransactionHistory()
+	require.Len(t, history, 3)
+	assert.Equal(t, "receive", history[0].Type)
+	assert.Equal(t, 50.0, history[0].Amount)
+	assert.Equal(t, int64(1), history[0].BlockHeight, "heights are counted along the golden chain")
+	assert.Equal(t, "receive", history[1].Type)
+	assert.Equal(t, 10.0, history[1].Amount)
+	assert.Equal(t, "send", history[2].Type)
+	assert.Equal(t, 20.0, history[2].Amount)
+	assert.Equal(t, other, history[2].To)
+	for _, record := range history {
+		assert.Equal(t, "confirmed", record.Status)
+	}
+
+	// Rescanning again is idempotent
+	require.NoError(t, w.Rescan(bc, 0, -1))
+	assert.Len(t, w.GetTransactionHistory(), 3)
+
+	// A partial range updates the last rescan rather than replacing it
+	require.NoError(t, w.Rescan(bc, 3, -1))
+	assert.Equal(t, 40.0, w.RescannedBalance(blockchain.Leah))
+	assert.Len(t, w.UTXOs(), 2)
+	history = w.GetTransactionHistory()
+	require.Len(t, history, 3)
+	assert.Equal(t, "send", history[2].Type)
+	assert.Equal(t, w.Address, history[2].From)
+
+	// Payments to any of the wallet's addresses are found
+	receive, err := w.NewReceiveAddress(DefaultAccount)
+	require.NoError(t, err)
+	appendTestBlock(bc, blockchain.Transaction{
+		ID:      []byte("to-receive-address"),
+		Outputs: []blockchain.TxOutput{{Value: 5, CoinType: blockchain.Leah, Address: receive}},
+	})
+	require.NoError(t, w.Rescan(bc, 4, 4))
+	assert.Equal(t, 45.0, w.RescannedBalance(blockchain.Leah))
+	history = w.GetTransactionHistory()
+	require.Len(t, history, 4)
+	assert.Equal(t, receive, history[3].To)
+
+	assert.Error(t, w.Rescan(bc, 10, 5))
+}
+
+func TestRescanProgress(t *testing.T) {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+	for i := 0; i < 3; i++ {
+		appendTestBlock(bc)
+	}
+
+	var calls [][2]int64
+	require.NoError(t, w.RescanWithProgress(bc, 0, -1, func(done, total int64) {
+		calls = append(calls, [2]int64{done, total})
+	}))
+
+	require.Len(t, calls, 5)
+	for i, call := range calls {
+		assert.Equal(t, int64(i+1), call[0])
+		assert.Equal(t, int64(5), call[1])
+	}
+}
+
+func TestCreateTransactionRespectsChain(t *testing.T) {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+
+	// Fund the wallet with a golden-only coin and a silver coin
+	leah := blockchain.NewTransaction("", w.Address, 50, blockchain.Leah, nil, []blockchain.TxOutput{
+		{Value: 50, CoinType: blockchain.Leah, Address: w.Address, PublicKeyHash: crypto.HashPublicKey(w.PublicKey)},
+	})
+	senum := blockchain.NewTransaction("", w.Address, 5, blockchain.Senum, nil, []blockchain.TxOutput{
+		{Value: 5, CoinType: blockchain.Senum, Address: w.Address, PublicKeyHash: crypto.HashPublicKey(w.PublicKey)},
+	})
+	require.NoError(t, bc.UTXOSet.UpdateWithTransaction(leah))
+	require.NoError(t, bc.UTXOSet.UpdateWithTransaction(senum))
+	bc.GoldenBlocks = append(bc.GoldenBlocks, blockchain.Block{})
+
+	to := hex.EncodeToString(make([]byte, 32))
+	tx, err := w.CreateTransaction(to, 3, blockchain.Senum, bc)
+	require.NoError(t, err)
+	require.Len(t, tx.Inputs, 1)
+	assert.Equal(t, senum.ID, tx.Inputs[0].TxID, "a Senum output must not be funded with Leah")
+	assert.Equal(t, crypto.PublicKeyToBytes(w.PublicKey), tx.Inputs[0].PublicKey, "inputs must carry the key that signed them")
+	require.Len(t, tx.Outputs, 2)
+	assert.Less(t, tx.Outputs[0].Value+tx.Outputs[1].Value, 5.0, "the change must leave room for the fee")
+	assert.NoError(t, tx.Validate(bc.UTXOSet))
+
+	// More Senum than the wallet holds can't be made up with Leah
+	_, err = w.CreateTransaction(to, 10, blockchain.Senum, bc)
+	var insufficient *InsufficientFundsError
+	require.ErrorAs(t, err, &insufficient)
+	assert.Equal(t, 5.0, insufficient.Available)
+	assert.Equal(t, 5.0, w.GetSpendableBalance(blockchain.Senum, bc))
+
+}
+
+func TestSend(t *testing.T) {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+
+	funding := blockchain.NewTransaction("", w.Address, 8, blockchain.Leah, nil, []blockchain.TxOutput{
+		{Value: 8, CoinType: blockchain.Leah, Address: w.Address, PublicKeyHash: crypto.HashPublicKey(w.PublicKey)},
+	})
+	require.NoError(t, bc.UTXOSet.UpdateWithTransaction(funding))
+	bc.GoldenBlocks = append(bc.GoldenBlocks, blockchain.Block{})
+
+	to := hex.EncodeToString(make([]byte, 32))
+	result, err := w.Send(to, 3, blockchain.Leah, bc, newMempoolBroadcaster(bc))
+	require.NoError(t, err, "a sent transaction must be accepted into the mempool")
+
+	pending := bc.GetPendingTransactions()
+	require.Len(t, pending, 1)
+	assert.Equal(t, result.TxID, hex.EncodeToString(pending[0].ID))
+	assert.InDelta(t, pending[0].GetFee(), result.Fee, 1e-9)
+
+	history := w.GetTransactionHistory()
+	require.Len(t, history, 1)
+	assert.Equal(t, result.TxID, history[0].TxID)
+	assert.Equal(t, "broadcast", history[0].Status)
+
+	require.Len(t, result.Inputs, 1)
+	assert.Equal(t, funding.ID, result.Inputs[0].TxID)
+	require.Len(t, result.Outputs, 2)
+	assert.Equal(t, to, result.Outputs[0].Address)
+	assert.Equal(t, 3.0, result.Outputs[0].Value)
+	assert.Greater(t, result.Fee, 0.0, "a sent transaction must pay the relay fee")
+	assert.InDelta(t, 8.0-3.0-result.Fee, result.Change, 1e-9)
+
+	// A failed broadcast still describes the built transaction
+	oldTimeout := BroadcastConfirmTimeout
+	BroadcastConfirmTimeout = 50 * time.Millisecond
+	defer func() { BroadcastConfirmTimeout = oldTimeout }()
+	result, err = w.Send(to, 1, blockchain.Leah, bc, newStubBroadcaster(false))
+	assert.Error(t, err)
+	require.NotNil(t, result)
+	assert.NotEmpty(t, result.TxID)
+}
+
+func TestListUnspent(t *testing.T) {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+	for i := 0; i < 5; i++ {
+		bc.GoldenBlocks = append(bc.GoldenBlocks, blockchain.Block{})
+	}
+	tip := bc.TipHeights()[blockchain.GoldenBlock]
+
+	fund := func(coinType blockchain.CoinType, value float64, height uint64) *blockchain.Transaction {
+		tx := blockchain.NewTransaction("", w.Address, value, coinType, nil, []blockchain.TxOutput{
+			{Value: value, CoinType: coinType, Address: w.Address, PublicKeyHash: crypto.HashPublicKey(w.PublicKey)},
+		})
+		require.NoError(t, bc.UTXOSet.UpdateWithTransactionAtHeight(tx, blockchain.GoldenBlock, height))
+		return tx
+	}
+	oldLeah := fund(blockchain.Leah, 10, tip-5)
+	oldSenum := fund(blockchain.Senum, 2, tip-4)
+	recentLeah := fund(blockchain.Leah, 3, tip)
+
+	all := w.ListUnspent(bc, DefaultAccount, 0, nil)
+	require.Len(t, all, 3)
+	assert.Equal(t, uint64(6), all[0].Confirmations)
+	assert.Equal(t, string(oldLeah.ID), all[0].TxID)
+
+	// A minimum confirmation count excludes the recent output
+	confirmed := w.ListUnspent(bc, DefaultAccount, 3, nil)
+	require.Len(t, confirmed, 2)
+	for _, utxo := range confirmed {
+		assert.NotEqual(t, string(recentLeah.ID), utxo.TxID)
+		assert.GreaterOrEqual(t, utxo.Confirmations, uint64(3))
+	}
+
+	// The coin type filter restricts results
+	senum := blockchain.Senum
+	silver := w.ListUnspent(bc, DefaultAccount, 0, &senum)
+	require.Len(t, silver, 1)
+	assert.Equal(t, string(oldSenum.ID), silver[0].TxID)
+	assert.Equal(t, 2.0, silver[0].Amount)
+
+	assert.Empty(t, w.ListUnspent(bc, 1, 0, nil), "unknown accounts have no outputs")
+}
+
+func TestImportLegacyMiningWallet(t *testing.T) {
+	miner, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+	pubKeyHash := crypto.HashPublicKey(miner.PublicKey)
+	appendTestBlock(bc, *blockchain.NewCoinbaseTransaction(miner.Address, pubKeyHash, 50, blockchain.Leah, blockchain.GoldenBlock))
+	appendTestBlock(bc, *blockchain.NewCoinbaseTransaction(miner.Address, pubKeyHash, 25, blockchain.Leah, blockchain.GoldenBlock))
+	appendTestBlock(bc, *blockchain.NewCoinbaseTransaction(miner.Address, pubKeyHash, 2, blockchain.Senum, blockchain.GoldenBlock))
+
+	path := filepath.Join(t.TempDir(), "mining_wallet.json")
+	data, err := json.Marshal(LegacyMiningWallet{
+		Address: miner.Address,
+		Rewards: map[string]float64{"LEAH": 75, "SENUM": 2},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	// With the key the rewards become spendable outputs of the wallet
+	w, err := ImportLegacyMiningWallet(path, miner.PrivateKey, bc)
+	require.NoError(t, err)
+	assert.Equal(t, miner.Address, w.Address)
+	assert.False(t, w.WatchOnly)
+	assert.Equal(t, []string{miner.Address}, w.accountAddresses(DefaultAccount))
+	assert.Len(t, w.UTXOs(), 3)
+	assert.Equal(t, 75.0, w.balances[blockchain.Leah])
+	assert.Equal(t, 2.0, w.balances[blockchain.Senum])
+
+	// Without it the address is imported watch-only with the same balances
+	watch, err := ImportLegacyMiningWallet(path, nil, bc)
+	require.NoError(t, err)
+	assert.True(t, watch.WatchOnly)
+	assert.Equal(t, 75.0, watch.balances[blockchain.Leah])
+
+	other, err := NewWallet()
+	require.NoError(t, err)
+	_, err = ImportLegacyMiningWallet(path, other.PrivateKey, bc)
+	assert.Error(t, err)
+}
+
+func TestEstimateTransactionFeeScalesWithInputs(t *testing.T) {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, bc.UTXOSet.UpdateWithTransaction(blockchain.NewTransaction("", w.Address, 10, blockchain.Leah, nil, []blockchain.TxOutput{
+			{Value: 10, CoinType: blockchain.Leah, Address: w.Address, PublicKeyHash: crypto.HashPublicKey(w.PublicKey)},
+		})))
+	}
+	bc.GoldenBlocks = append(bc.GoldenBlocks, blockchain.Block{})
+	rate := bc.ConsensusParams().MinRelayFeeRate
+
+	// Each extra input adds its size at the relay fee rate
+	oneInput := w.EstimateTransactionFee(5, blockchain.Leah, bc)
+	threeInputs := w.EstimateTransactionFee(25, blockchain.Leah, bc)
+	assert.InDelta(t, float64(estimateVirtualSize(repeatAddress(w.Address, 1), 2, blockchain.Leah))*rate, oneInput, 1e-12)
+	assert.InDelta(t, float64(estimateVirtualSize(repeatAddress(w.Address, 3), 2, blockchain.Leah))*rate, threeInputs, 1e-12)
+	assert.Greater(t, threeInputs, oneInput)
+
+	// An exact amount needs no change output
+	exact := w.EstimateTransactionFee(20, blockchain.Leah, bc)
+	assert.InDelta(t, float64(estimateVirtualSize(repeatAddress(w.Address, 2), 1, blockchain.Leah))*rate, exact, 1e-12)
+
+	// The address length no longer affects the estimate
+	long := &Wallet{Address: strings.Repeat("f", 128)}
+	short := &Wallet{Address: "f"}
+	assert.Equal(t, long.EstimateTransactionFee(5, blockchain.Leah, bc), short.EstimateTransactionFee(5, blockchain.Leah, bc))
+}
+
+func TestBalanceCacheConcurrentAccess(t *testing.T) {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+	pubKeyHash := crypto.HashPublicKey(w.PublicKey)
+	const blocks = 10
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			var last float64
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				balance := w.GetBalance(blockchain.Leah, bc)
+				assert.GreaterOrEqual(t, balance, last)
+				last = balance
+				assert.GreaterOrEqual(t, w.GetAllBalances(bc)[blockchain.Leah], 0.0)
+			}
+		}()
+	}
+
+	// Every connected block must be reflected by the next read
+	for i := 0; i < blocks; i++ {
+		coinbase := blockchain.NewCoinbaseTransaction(w.Address, pubKeyHash, 10, blockchain.Leah, blockchain.GoldenBlock)
+		block, err := bc.MineBlock([]blockchain.Transaction{*coinbase}, blockchain.GoldenBlock, blockchain.Leah)
+		require.NoError(t, err)
+		require.NoError(t, bc.AddBlock(block))
+		assert.Equal(t, float64(i+1)*10, w.GetBalance(blockchain.Leah, bc))
+	}
+	close(done)
+	readers.Wait()
+
+	assert.Equal(t, blocks*10.0, w.GetBalance(blockchain.Leah, bc))
+	assert.Equal(t, blocks*10.0, w.GetAllBalances(bc)[blockchain.Leah])
+}
+
+// TestSignTransactionWithCallback tests signing through an external signer
+func TestSignTransactionWithCallback(t *testing.T) {
+	// The key lives outside the wallet, as it would on a hardware device
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	wallet := NewWatchOnlyWallet(&key.PublicKey)
+
+	publicKey := crypto.PublicKeyToBytes(&key.PublicKey)
+	inputs := []blockchain.TxInput{
+		{TxID: []byte("funding-1"), OutputIndex: 0, Amount: 6, PublicKey: publicKey},
+		{TxID: []byte("funding-2"), OutputIndex: 1, Amount: 4, PublicKey: publicKey},
+	}
+	outputs := []blockchain.TxOutput{{Value: 10, CoinType: blockchain.Leah, Address: "recipient"}}
+	tx := blockchain.NewTransaction(wallet.Address, "recipient", 10, blockchain.Leah, inputs, outputs)
+
+	calls := 0
+	err = wallet.SignTransactionWith(tx, func(hash []byte, account uint32) ([]byte, error) {
+		calls++
+		assert.Equal(t, DefaultAccount, account)
+		return crypto.Sign(hash, key.D.Bytes())
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.True(t, tx.Verify())
+
+	// A signer answering with the wrong key is caught
+	other, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	err = wallet.SignTransactionWith(tx, func(hash []byte, account uint32) ([]byte, error) {
+		return crypto.Sign(hash, other.D.Bytes())
+	})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+// TestSignTransactionWithTaprootCallback tests that an external signer's
+// Schnorr signatures on Taproot inputs are checked as Schnorr
+func TestSignTransactionWithTaprootCallback(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	wallet := NewWatchOnlyWallet(&key.PublicKey)
+
+	// A Taproot input carries the x-only output key its address commits to
+	outputKey, err := crypto.TaprootOutputKey(&key.PublicKey, nil)
+	require.NoError(t, err)
+	inputs := []blockchain.TxInput{
+		{TxID: []byte("funding-1"), OutputIndex: 0, Amount: 10, PublicKey: outputKey},
+	}
+	outputs := []blockchain.TxOutput{{Value: 10, CoinType: blockchain.Leah, Address: "recipient"}}
+	tx := blockchain.NewTransaction(wallet.Address, "recipient", 10, blockchain.Leah, inputs, outputs)
+
+	err = wallet.SignTransactionWith(tx, func(hash []byte, account uint32) ([]byte, error) {
+		return crypto.SignTaproot(hash, key, nil)
+	})
+	require.NoError(t, err)
+	assert.True(t, tx.Inputs[0].IsSchnorr())
+	assert.True(t, tx.Verify())
+
+	// A signer answering with the wrong key is caught
+	other, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	err = wallet.SignTransactionWith(tx, func(hash []byte, account uint32) ([]byte, error) {
+		return crypto.SignTaproot(hash, other, nil)
+	})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+// TestSignTransactionWithAccounts tests that each input is signed for the
+// account owning the address it spends from
+func TestSignTransactionWithAccounts(t *testing.T) {
+	w, err := NewHDWallet()
+	require.NoError(t, err)
+	_, err = w.CreateAccount()
+	require.NoError(t, err)
+	second, err := w.CreateAccount()
+	require.NoError(t, err)
+
+	primary, err := w.NewReceiveAddress(DefaultAccount)
+	require.NoError(t, err)
+	change, err := w.NewChangeAddress(second.Index)
+	require.NoError(t, err)
+
+	inputs := []blockchain.TxInput{
+		{TxID: []byte("funding-1"), Amount: 6, Address: primary},
+		{TxID: []byte("funding-2"), Amount: 4, Address: change},
+		{TxID: []byte("funding-3"), Amount: 1, Address: w.Address},
+		{TxID: []byte("funding-4"), Amount: 1, Address: "unknown"},
+	}
+	outputs := []blockchain.TxOutput{{Value: 12, CoinType: blockchain.Leah, Address: "recipient"}}
+	tx := blockchain.NewTransaction(w.Address, "recipient", 12, blockchain.Leah, inputs, outputs)
+
+	var accounts []uint32
+	err = w.SignTransactionWith(tx, func(hash []byte, account uint32) ([]byte, error) {
+		accounts = append(accounts, account)
+		return []byte("signature"), nil
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, DefaultAccount, second.Index)
+	assert.Equal(t, []uint32{DefaultAccount, second.Index, DefaultAccount, DefaultAccount}, accounts)
+}
+
+func TestHDAccountChains(t *testing.T) {
+	w, err := NewHDWallet()
+	require.NoError(t, err)
+
+	receive, err := w.NewReceiveAddress(DefaultAccount)
+	require.NoError(t, err)
+	change, err := w.NewChangeAddress(DefaultAccount)
+	require.NoError(t, err)
+	secondReceive, err := w.NewReceiveAddress(DefaultAccount)
+	require.NoError(t, err)
+
+	// Each address comes from its own chain of m/44'/0'/0'
+	for _, tc := range []struct {
+		address string
+		chain   uint32
+		index   uint32
+	}{{receive, ExternalChain, 0}, {secondReceive, ExternalChain, 1}, {change, InternalChain, 0}} {
+		key, err := w.HDWallet.addressKey(DefaultAccount, tc.chain, tc.index)
+		require.NoError(t, err)
+		assert.Equal(t, generateAddress(&key.PublicKey), tc.address)
+	}
+	assert.NotEqual(t, receive, change)
+	assert.NotEqual(t, receive, secondReceive)
+
+	external, err := w.AccountAddresses(DefaultAccount, ExternalChain)
+	require.NoError(t, err)
+	assert.Equal(t, []string{receive, secondReceive}, external)
+	internal, err := w.AccountAddresses(DefaultAccount, InternalChain)
+	require.NoError(t, err)
+	assert.Equal(t, []string{change}, internal)
+
+	// Funds on a receive address are spent, with change going to the internal chain
+	bc := blockchain.NewBlockchain()
+	funding := blockchain.NewTransaction("", receive, 8, blockchain.Leah, nil, []blockchain.TxOutput{
+		{Value: 8, CoinType: blockchain.Leah, Address: receive, PublicKeyHash: []byte(receive)},
+	})
+	require.NoError(t, bc.UTXOSet.UpdateWithTransaction(funding))
+	bc.GoldenBlocks = append(bc.GoldenBlocks, blockchain.Block{})
+
+	to := hex.EncodeToString(make([]byte, 32))
+	tx, err := w.CreateTransaction(to, 3, blockchain.Leah, bc)
+	require.NoError(t, err)
+	require.Len(t, tx.Outputs, 2)
+	internal, err = w.AccountAddresses(DefaultAccount, InternalChain)
+	require.NoError(t, err)
+	require.Len(t, internal, 2)
+	assert.Equal(t, internal[1], tx.Outputs[1].Address)
+	assert.NotContains(t, external, tx.Outputs[1].Address)
+}
+
+func TestRestoreDiscoversAddresses(t *testing.T) {
+	w, err := NewHDWallet()
+	require.NoError(t, err)
+
+	var receive, change string
+	for i := 0; i < 6; i++ {
+		receive, err = w.NewReceiveAddress(DefaultAccount)
+		require.NoError(t, err)
+	}
+	segwit, err := w.GetNewAddress(DefaultAccount, AddressTypeP2WPKH)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		change, err = w.NewChangeAddress(DefaultAccount)
+		require.NoError(t, err)
+	}
+	_, err = w.CreateAccount()
+	require.NoError(t, err)
+	savings, err := w.NewReceiveAddress(1)
+	require.NoError(t, err)
+
+	// Only the last address handed out on each chain is ever paid
+	bc := blockchain.NewBlockchain()
+	for i, payment := range []struct {
+		address string
+		value   float64
+	}{{receive, 5}, {segwit.String(), 2}, {change, 1}, {savings, 4}} {
+		tx := blockchain.Transaction{
+			ID:      []byte(fmt.Sprintf("payment-%d", i)),
+			Outputs: []blockchain.TxOutput{{Value: payment.value, CoinType: blockchain.Leah, Address: payment.address}},
+		}
+		require.NoError(t, bc.UTXOSet.UpdateWithTransaction(&tx))
+		appendTestBlock(bc, tx)
+	}
+
+	mnemonic, err := w.GetMnemonic()
+	require.NoError(t, err)
+	restored, err := RestoreFromMnemonicOnChain(mnemonic, bc)
+	require.NoError(t, err)
+
+	for _, account := range []uint32{DefaultAccount, 1} {
+		for _, chain := range []uint32{ExternalChain, InternalChain} {
+			want, err := w.AccountAddresses(account, chain)
+			require.NoError(t, err)
+			got, err := restored.AccountAddresses(account, chain)
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "account %d chain %d", account, chain)
+		}
+	}
+	_, err = restored.GetAccount(2)
+	assert.Error(t, err, "an unused account must not be kept")
+
+	// Balances cover every address of the default account
+	assert.Equal(t, 8.0, restored.GetBalance(blockchain.Leah, bc))
+	assert.Equal(t, 8.0, restored.GetSpendableBalance(blockchain.Leah, bc))
+	assert.Equal(t, 8.0, restored.GetAllBalances(bc)[blockchain.Leah])
+	assert.Equal(t, 8.0, restored.GetBalanceDetailed(blockchain.Leah, bc).Confirmed)
+}
+
+func TestBumpFeeEstimate(t *testing.T) {
+	wallet, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+	funding := fundWallet(t, wallet, bc, 10)
+
+	// An underpaying transaction is raised to exactly the target rate
+	tx := signedSpend(t, wallet, funding, 10, 9.9999)
+	vsize := float64(tx.VirtualSize())
+	target := 10 * tx.GetFee() / vsize
+	newFee := BumpFeeEstimate(tx.GetFee(), target, tx)
+	assert.InDelta(t, target, newFee/vsize, 1e-12)
+
+	// One already paying the target still has to pay more to replace it
+	newFee = BumpFeeEstimate(tx.GetFee(), tx.GetFee()/vsize, tx)
+	assert.Greater(t, newFee, tx.GetFee())
+}
+
+func TestGetNewAddressTypes(t *testing.T) {
+	w, err := NewHDWallet()
+	require.NoError(t, err)
+
+	var handedOut []string
+	for index, addrType := range []AddressType{AddressTypeP2PKH, AddressTypeP2WPKH, AddressTypeP2TR} {
+		addr, err := w.GetNewAddress(DefaultAccount, addrType)
+		require.NoError(t, err)
+		assert.Equal(t, addrType, addr.Type)
+		assert.Len(t, addr.Hash, 32)
+
+		decoded, err := decodeAddress(addr.String())
+		require.NoError(t, err)
+		assert.Equal(t, addr, decoded)
+		assert.True(t, isValidAddress(addr.String()))
+
+		// The address commits to the next receive key
+		key, err := w.HDWallet.addressKey(DefaultAccount, ExternalChain, uint32(index))
+		require.NoError(t, err)
+		expected, err := newAddress(&key.PublicKey, addrType)
+		require.NoError(t, err)
+		assert.Equal(t, expected, addr)
+		if addrType == AddressTypeP2PKH {
+			assert.Equal(t, generateAddress(&key.PublicKey), addr.String())
+		}
+		handedOut = append(handedOut, addr.String())
+	}
+
+	// The account remembers each address's type
+	external, err := w.AccountAddresses(DefaultAccount, ExternalChain)
+	require.NoError(t, err)
+	assert.Equal(t, handedOut, external)
+
+	_, err = decodeAddress("byc1q" + hex.EncodeToString(make([]byte, 20)))
+	assert.Error(t, err)
+	legacy, err := NewWallet()
+	require.NoError(t, err)
+	_, err = legacy.GetNewAddress(DefaultAccount, AddressTypeP2WPKH)
+	assert.ErrorIs(t, err, ErrNotHDWallet)
+}
+
+func TestDeriveAddressAtPath(t *testing.T) {
+	const mnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	w, err := RestoreFromMnemonic(mnemonic)
+	require.NoError(t, err)
+
+	// The same path always derives the same key, in any wallet with the seed
+	addr, key, err := w.DeriveAddressAtPath("m/84'/0'/0'/0/5", AddressTypeP2WPKH)
+	require.NoError(t, err)
+	assert.Equal(t, AddressTypeP2WPKH, addr.Type)
+	expected, err := newAddress(&key.PublicKey, AddressTypeP2WPKH)
+	require.NoError(t, err)
+	assert.Equal(t, expected, addr)
+
+	restored, err := RestoreFromMnemonic(mnemonic)
+	require.NoError(t, err)
+	again, againKey, err := restored.DeriveAddressAtPath("m/84h/0h/0h/0/5", AddressTypeP2WPKH)
+	require.NoError(t, err)
+	assert.Equal(t, addr, again)
+	assert.Equal(t, key.D, againKey.D)
+
+	other, _, err := w.DeriveAddressAtPath("m/84'/0'/0'/0/6", AddressTypeP2WPKH)
+	require.NoError(t, err)
+	assert.NotEqual(t, addr, other)
+
+	// Account paths match the keys the wallet hands out itself
+	accountKey, err := w.HDWallet.addressKey(DefaultAccount, ExternalChain, 3)
+	require.NoError(t, err)
+	_, derived, err := w.DeriveAddressAtPath("m/44'/0'/0'/0/3", AddressTypeP2PKH)
+	require.NoError(t, err)
+	assert.Equal(t, accountKey.D, derived.D)
+
+	indices, err := ParseDerivationPath("m/84'/0'/0'/0/5")
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{HardenedKeyStart + 84, HardenedKeyStart, HardenedKeyStart, 0, 5}, indices)
+
+	for _, path := range []string{
+		"",
+		"84'/0'/0'/0/5",
+		"m/84'/0'//0/5",
+		"m/84''/0",
+		"m/-1",
+		"m/abc",
+		"m/2147483648",
+		"m/0/",
+	} {
+		_, _, err := w.DeriveAddressAtPath(path, AddressTypeP2PKH)
+		assert.Error(t, err, path)
+	}
+
+	legacy, err := NewWallet()
+	require.NoError(t, err)
+	_, _, err = legacy.DeriveAddressAtPath("m/0", AddressTypeP2PKH)
+	assert.ErrorIs(t, err, ErrNotHDWallet)
+}
+
+func TestConsolidateUTXOs(t *testing.T) {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+
+	fund := func(value float64) *blockchain.Transaction {
+		tx := blockchain.NewTransaction("", w.Address, value, blockchain.Leah, nil, []blockchain.TxOutput{
+			{Value: value, CoinType: blockchain.Leah, Address: w.Address, PublicKeyHash: crypto.HashPublicKey(w.PublicKey)},
+		})
+		require.NoError(t, bc.UTXOSet.UpdateWithTransaction(tx))
+		return tx
+	}
+	small := make(map[string]bool)
+	for i := 0; i < 12; i++ {
+		small[string(fund(0.5).ID)] = true
+	}
+	large := fund(100)
+	bc.GoldenBlocks = append(bc.GoldenBlocks, blockchain.Block{})
+
+	tx, err := w.ConsolidateUTXOs(bc, DefaultAccount, 10, 0)
+	require.NoError(t, err)
+
+	// The ten smallest outputs are swept into one output back to the wallet
+	require.Len(t, tx.Inputs, 10)
+	for _, input := range tx.Inputs {
+		assert.True(t, small[string(input.TxID)], "consolidation spent a large output")
+		assert.NotEqual(t, large.ID, input.TxID)
+	}
+	require.Len(t, tx.Outputs, 1)
+	assert.Equal(t, w.Address, tx.Outputs[0].Address)
+	assert.Equal(t, crypto.HashPublicKey(w.PublicKey), tx.Outputs[0].PublicKeyHash)
+
+	fee := float64(estimateVirtualSize(repeatAddress(w.Address, 10), 1, blockchain.Leah)) * bc.ConsensusParams().MinRelayFeeRate
+	assert.InDelta(t, 5.0-fee, tx.Outputs[0].Value, 1e-9)
+	assert.InDelta(t, fee, tx.GetFee(), 1e-9)
+	assert.NoError(t, bc.CheckTransaction(*tx), "the consolidation should be accepted as built")
+
+	// A higher fee rate pays more
+	pricier, err := w.ConsolidateUTXOs(bc, DefaultAccount, 10, 10*bc.ConsensusParams().MinRelayFeeRate)
+	require.NoError(t, err)
+	assert.Less(t, pricier.Outputs[0].Value, tx.Outputs[0].Value)
+
+	// Fewer than two inputs is nothing to consolidate
+	_, err = w.ConsolidateUTXOs(bc, DefaultAccount, 1, 0)
+	assert.Error(t, err)
+	_, err = w.ConsolidateUTXOs(bc, 7, 10, 0)
+	assert.Error(t, err)
+}
+
+// TestGetSpecialCoins tests that the special coins reported for a wallet
+// are its balances in the UTXO set
+func TestGetSpecialCoins(t *testing.T) {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	other, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+
+	fund := func(owner *Wallet, value float64, coinType blockchain.CoinType) {
+		tx := &blockchain.Transaction{
+			Outputs: []blockchain.TxOutput{
+				{Value: value, CoinType: coinType, Address: owner.Address, PublicKeyHash: crypto.HashPublicKey(owner.PublicKey)},
+			},
+			Timestamp: time.Now(),
+			Nonce:     blockchain.NewTxNonce(),
+		}
+		tx.RecomputeID()
+		require.NoError(t, bc.UTXOSet.UpdateWithTransaction(tx))
+	}
+	fund(w, 2, blockchain.Ephraim)
+	fund(w, 1, blockchain.Ephraim)
+	fund(w, 4, blockchain.Joseph)
+	fund(w, 10, blockchain.Leah)
+	fund(other, 5, blockchain.Manasseh)
+
+	coins := bc.GetSpecialCoins(w.Address)
+	require.Len(t, coins, len(blockchain.SpecialCoinTypes))
+	for i, coinType := range blockchain.SpecialCoinTypes {
+		assert.Equal(t, coinType.String(), coins[i].Type)
+		assert.Equal(t, int64(bc.UTXOSet.GetBalance(w.Address, coinType)), coins[i].Amount, coinType.String())
+	}
+	assert.Equal(t, int64(3), coins[0].Amount)
+	assert.Equal(t, int64(0), coins[1].Amount, "another address's coins were counted")
+	assert.Equal(t, int64(4), coins[2].Amount)
+
+	// The wallet reports the same holdings
+	assert.Equal(t, coins, w.AsInterface().GetSpecialCoins(bc))
+}
+
+// TestWalletClose tests that closing a wallet zeroes its key material and
+// makes every signing and key derivation fail
+func TestWalletClose(t *testing.T) {
+	w, err := NewHDWallet()
+	require.NoError(t, err)
+	_, err = w.NewReceiveAddress(DefaultAccount)
+	require.NoError(t, err)
+	_, err = w.SignMessage([]byte("before close"))
+	require.NoError(t, err)
+
+	d := w.PrivateKey.D
+	seed := w.HDWallet.Seed
+	masterKey := w.HDWallet.MasterKey
+	require.NoError(t, w.Close())
+
+	assert.Zero(t, d.Sign(), "private key was not zeroed")
+	for _, word := range d.Bits()[:cap(d.Bits())] {
+		assert.Zero(t, word, "private key words were not zeroed")
+	}
+	assert.Equal(t, make([]byte, len(seed)), seed, "seed was not zeroed")
+	assert.Equal(t, make([]byte, len(masterKey)), masterKey, "master key was not zeroed")
+	assert.Nil(t, w.PrivateKey)
+	assert.Empty(t, w.HDWallet.Mnemonic)
+
+	_, err = w.SignMessage([]byte("after close"))
+	assert.ErrorIs(t, err, ErrWalletClosed)
+	_, err = w.SignMessageRecoverable([]byte("after close"))
+	assert.ErrorIs(t, err, ErrWalletClosed)
+	_, err = w.accountKeys(DefaultAccount)
+	assert.ErrorIs(t, err, ErrWalletClosed)
+	_, _, err = w.DeriveAddressAtPath("m/44'/0'/0'/0/0", AddressTypeP2PKH)
+	assert.ErrorIs(t, err, ErrWalletClosed)
+	_, err = w.CreateTransaction(w.Address, 1, blockchain.Leah, blockchain.NewBlockchain())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), ErrWalletClosed.Error())
+	}
+	assert.ErrorIs(t, w.EncryptWallet("password"), ErrWalletClosed)
+	_, err = w.GetMnemonic()
+	assert.ErrorIs(t, err, ErrWalletClosed)
+
+	assert.NoError(t, w.Close(), "closing twice")
+}
+
+// TestAuditLog tests that signing is recorded in the audit log and that the
+// log never holds private key material
+func TestAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	SetAuditSink(NewJSONAuditLog(&buf))
+	t.Cleanup(func() { SetAuditSink(nil) })
+
+	w, err := NewWallet()
+	require.NoError(t, err)
+	key := w.PrivateKey.D.Bytes()
+	_, err = w.SignMessage([]byte("audited"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	var entries []AuditEntry
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry AuditEntry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	require.Len(t, entries, 3)
+	assert.Equal(t, AuditGenerateKey, entries[0].Operation)
+	assert.Equal(t, AuditSign, entries[1].Operation)
+	assert.Equal(t, DefaultAccount, entries[1].Account)
+	assert.Equal(t, w.Address, entries[1].Address)
+	assert.Equal(t, "success", entries[1].Outcome)
+	assert.False(t, entries[1].Timestamp.IsZero())
+	assert.Equal(t, AuditCloseWallet, entries[2].Operation)
+
+	_, err = w.SignMessage([]byte("closed"))
+	require.ErrorIs(t, err, ErrWalletClosed)
+	assert.Contains(t, buf.String(), `"outcome":"failure","error":"wallet is closed"`)
+
+	log := buf.String()
+	assert.NotContains(t, log, hex.EncodeToString(key))
+	assert.NotContains(t, log, string(key))
+}
+
+func TestGetTransactionDetails(t *testing.T) {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+	to := hex.EncodeToString(make([]byte, 32))
+	require.NoError(t, w.AddToAddressBook("alice", to, ""))
+
+	// Spend a 10 Leah output: 6 to alice, 3 back as change and 1 in fees
+	funding := fundWallet(t, w, bc, 10)
+	spend := &blockchain.Transaction{
+		Inputs: []blockchain.TxInput{{
+			TxID:      funding.ID,
+			Amount:    10,
+			PublicKey: crypto.PublicKeyToBytes(w.PublicKey),
+			Address:   w.Address,
+		}},
+		Outputs: []blockchain.TxOutput{
+			{Value: 6, CoinType: blockchain.Leah, PublicKeyHash: []byte(to), Address: to},
+			{Value: 3, CoinType: blockchain.Leah, PublicKeyHash: crypto.HashPublicKey(w.PublicKey), Address: w.Address},
+		},
+		Timestamp: time.Now(),
+	}
+	spend.ID = spend.CalculateHash()
+	require.NoError(t, spend.Sign(w.PrivateKey.D.Bytes()))
+	w.AddTransactionToHistory(spend, "broadcast")
+	txid := hex.EncodeToString(spend.ID)
+
+	require.NoError(t, bc.AddTransaction(*spend))
+	details, err := w.GetTransactionDetails(txid, bc)
+	require.NoError(t, err)
+	assert.Equal(t, 0, details.Confirmations)
+	assert.Empty(t, details.BlockHash)
+
+	// Confirm it, then bury it under another block
+	coinbase := blockchain.NewCoinbaseTransaction(w.Address, crypto.HashPublicKey(w.PublicKey), 1, blockchain.Leah, blockchain.GoldenBlock)
+	block, err := bc.MineBlock([]blockchain.Transaction{*coinbase, *spend}, blockchain.GoldenBlock, blockchain.Leah)
+	require.NoError(t, err)
+	require.NoError(t, bc.AddBlock(block))
+	coinbase = blockchain.NewCoinbaseTransaction(w.Address, crypto.HashPublicKey(w.PublicKey), 1, blockchain.Leah, blockchain.GoldenBlock)
+	next, err := bc.MineBlock([]blockchain.Transaction{*coinbase}, blockchain.GoldenBlock, blockchain.Leah)
+	require.NoError(t, err)
+	require.NoError(t, bc.AddBlock(next))
+
+	details, err = w.GetTransactionDetails(txid, bc)
+	require.NoError(t, err)
+	assert.Equal(t, "send", details.Type)
+	assert.Equal(t, "confirmed", details.Status)
+	assert.Equal(t, 2, details.Confirmations)
+	assert.Equal(t, hex.EncodeToString(block.Hash), details.BlockHash)
+	assert.InDelta(t, 1.0, details.Fee, 1e-9)
+	assert.InDelta(t, -7.0, details.NetAmount, 1e-9)
+	require.Len(t, details.Counterparties, 1)
+	assert.Equal(t, Counterparty{Address: to, Label: "alice", Amount: 6}, details.Counterparties[0])
+
+	_, err = w.GetTransactionDetails(hex.EncodeToString([]byte("unknown")), bc)
+	assert.Error(t, err)
+	_, err = w.GetTransactionDetails("not hex", bc)
+	assert.Error(t, err)
+}
+
+func TestGetTransactionDetailsResolvesSpentOutputs(t *testing.T) {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+	to := hex.EncodeToString(make([]byte, 32))
+
+	// The input names no address; the output it spends says it is the wallet's
+	funding := fundWallet(t, w, bc, 10)
+	spend := &blockchain.Transaction{
+		Inputs: []blockchain.TxInput{{
+			TxID:      funding.ID,
+			Amount:    10,
+			PublicKey: crypto.PublicKeyToBytes(w.PublicKey),
+		}},
+		Outputs: []blockchain.TxOutput{
+			{Value: 9, CoinType: blockchain.Leah, PublicKeyHash: []byte(to), Address: to},
+		},
+		Timestamp: time.Now(),
+	}
+	spend.ID = spend.CalculateHash()
+	require.NoError(t, spend.Sign(w.PrivateKey.D.Bytes()))
+	require.NoError(t, bc.AddTransaction(*spend))
+
+	details, err := w.GetTransactionDetails(hex.EncodeToString(spend.ID), bc)
+	require.NoError(t, err)
+	assert.Equal(t, "send", details.Type)
+	assert.InDelta(t, -10.0, details.NetAmount, 1e-9)
+	assert.Equal(t, []Counterparty{{Address: to, Amount: 9}}, details.Counterparties)
+
+	// A record the chain once confirmed that is now nowhere was reorganized out
+	gone := &blockchain.Transaction{ID: []byte("reorganized-out"), Inputs: spend.Inputs, Outputs: spend.Outputs}
+	w.AddTransactionToHistory(gone, "confirmed")
+	details, err = w.GetTransactionDetails(hex.EncodeToString(gone.ID), bc)
+	require.NoError(t, err)
+	assert.Equal(t, -1, details.Confirmations)
+}
+
+func TestWatchReorgsUnconfirmsReceivedTransaction(t *testing.T) {
+	w, err := NewWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+	stop := w.WatchReorgs(bc)
+	defer stop()
+
+	// Another key pays the wallet 5 Leah in block 1
+	payer, err := NewWallet()
+	require.NoError(t, err)
+	funding := fundWallet(t, payer, bc, 10)
+	payment := &blockchain.Transaction{
+		Inputs: []blockchain.TxInput{{
+			TxID:      funding.ID,
+			Amount:    10,
+			PublicKey: crypto.PublicKeyToBytes(payer.PublicKey),
+			Address:   payer.Address,
+		}},
+		Outputs: []blockchain.TxOutput{
+			{Value: 5, CoinType: blockchain.Leah, PublicKeyHash: crypto.HashPublicKey(w.PublicKey), Address: w.Address},
+			{Value: 4, CoinType: blockchain.Leah, PublicKeyHash: crypto.HashPublicKey(payer.PublicKey), Address: payer.Address},
+		},
+		Timestamp: time.Now(),
+	}
+	payment.ID = payment.CalculateHash()
+	require.NoError(t, payment.Sign(payer.PrivateKey.D.Bytes()))
+	require.NoError(t, bc.AddTransaction(*payment))
+
+	miner := hex.EncodeToString(make([]byte, 32))
+	coinbase := blockchain.NewCoinbaseTransaction(miner, []byte(miner), 1, blockchain.Leah, blockchain.GoldenBlock)
+	block, err := bc.MineBlock([]blockchain.Transaction{*coinbase, *payment}, blockchain.GoldenBlock, blockchain.Leah)
+	require.NoError(t, err)
+	require.NoError(t, bc.AddBlock(block))
+
+	require.NoError(t, w.Rescan(bc, 0, -1))
+	require.Equal(t, 5.0, w.RescannedBalance(blockchain.Leah))
+	require.Equal(t, 5.0, w.GetBalance(blockchain.Leah, bc))
+	txid := hex.EncodeToString(payment.ID)
+	status := func() string {
+		for _, record := range w.GetTransactionHistory() {
+			if record.TxID == txid {
+				return record.Status
+			}
+		}
+		return ""
+	}
+	require.Equal(t, "confirmed", status())
+
+	// A longer branch from genesis that leaves the payment out replaces block 1
+	fork := blockchain.NewBlockchain()
+	for i := 0; i < 2; i++ {
+		coinbase := blockchain.NewCoinbaseTransaction(miner, []byte(miner), 1, blockchain.Leah, blockchain.GoldenBlock)
+		b, err := fork.MineBlock([]blockchain.Transaction{*coinbase}, blockchain.GoldenBlock, blockchain.Leah)
+		require.NoError(t, err)
+		require.NoError(t, fork.AddBlock(b))
+	}
+	require.NoError(t, bc.Reorganize(blockchain.GoldenBlock, 0, fork.GoldenBlocks[1:]))
+
+	// The payment went back to the pending pool, so it is pending again
+	require.Eventually(t, func() bool { return status() == "pending" }, 2*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return w.RescannedBalance(blockchain.Leah) == 0 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0.0, w.GetBalance(blockchain.Leah, bc))
+	assert.Empty(t, w.UTXOs())
+}
+
+// TestWitnessInputsByAddressType tests that inputs spending SegWit outputs
+// carry the compressed key the address commits to and weigh less, and that
+// fee estimates size each input by the address it spends
+func TestWitnessInputsByAddressType(t *testing.T) {
+	w, err := NewHDWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+
+	segwit, err := w.GetNewAddress(DefaultAccount, AddressTypeP2WPKH)
+	require.NoError(t, err)
+	legacy, err := w.GetNewAddress(DefaultAccount, AddressTypeP2PKH)
+	require.NoError(t, err)
+	taproot, err := w.GetNewAddress(DefaultAccount, AddressTypeP2TR)
+	require.NoError(t, err)
+
+	legacySize := estimateVirtualSize([]string{legacy.String()}, 1, blockchain.Leah)
+	segwitSize := estimateVirtualSize([]string{segwit.String()}, 1, blockchain.Leah)
+	taprootSize := estimateVirtualSize([]string{taproot.String()}, 1, blockchain.Leah)
+	assert.Less(t, segwitSize, legacySize)
+	assert.Less(t, taprootSize, legacySize)
+
+	funded := make(map[string]string)
+	for _, addr := range []*Address{segwit, legacy} {
+		tx := blockchain.NewTransaction("", addr.String(), 1, blockchain.Leah, nil, []blockchain.TxOutput{
+			{Value: 1, CoinType: blockchain.Leah, Address: addr.String(), PublicKeyHash: addr.Hash},
+		})
+		require.NoError(t, bc.UTXOSet.UpdateWithTransaction(tx))
+		funded[string(tx.ID)] = addr.String()
+	}
+	bc.GoldenBlocks = append(bc.GoldenBlocks, blockchain.Block{})
+
+	tx, err := w.ConsolidateUTXOs(bc, DefaultAccount, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, tx.Inputs, 2)
+	assert.NoError(t, tx.Validate(bc.UTXOSet), "the compressed key should own the SegWit output")
+	require.NoError(t, bc.AddTransaction(*tx), "the pool should accept a spend of a SegWit output")
+
+	for _, input := range tx.Inputs {
+		switch funded[string(input.TxID)] {
+		case segwit.String():
+			assert.Len(t, input.PublicKey, crypto.CompressedPublicKeyLength)
+			assert.True(t, input.IsWitness())
+		case legacy.String():
+			assert.False(t, input.IsWitness(), "a legacy spend gets no witness discount")
+		}
+	}
+	assert.Less(t, tx.VirtualSize(), tx.Size())
+	assert.LessOrEqual(t, tx.VirtualSize(), estimateVirtualSize([]string{segwit.String(), legacy.String()}, 1, blockchain.Leah))
+}
+
+// repeatAddress returns address n times, the addresses n inputs spend
+func repeatAddress(address string, n int) []string {
+	addresses := make([]string, n)
+	for i := range addresses {
+		addresses[i] = address
+	}
+	return addresses
+}
+
+// TestSigningPolicy tests that inputs spending Taproot outputs are signed
+// with Schnorr and inputs spending legacy outputs with ECDSA
+func TestSigningPolicy(t *testing.T) {
+	w, err := NewHDWallet()
+	require.NoError(t, err)
+	bc := blockchain.NewBlockchain()
+
+	taproot, err := w.GetNewAddress(DefaultAccount, AddressTypeP2TR)
+	require.NoError(t, err)
+	legacy, err := w.GetNewAddress(DefaultAccount, AddressTypeP2PKH)
+	require.NoError(t, err)
+	assert.Equal(t, SignatureSchnorr, w.signingPolicy().Scheme(taproot.String()))
+	assert.Equal(t, SignatureECDSA, w.signingPolicy().Scheme(legacy.String()))
+
+	funded := make(map[string]string)
+	for _, addr := range []*Address{taproot, legacy} {
+		tx := blockchain.NewTransaction("", addr.String(), 1, blockchain.Leah, nil, []blockchain.TxOutput{
+			{Value: 1, CoinType: blockchain.Leah, Address: addr.String(), PublicKeyHash: addr.Hash},
+		})
+		require.NoError(t, bc.UTXOSet.UpdateWithTransaction(tx))
+		funded[string(tx.ID)] = addr.String()
+	}
+	bc.GoldenBlocks = append(bc.GoldenBlocks, blockchain.Block{})
+
+	tx, err := w.ConsolidateUTXOs(bc, DefaultAccount, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, tx.Inputs, 2)
+	assert.True(t, tx.Verify(), "both signatures should verify on chain")
+	assert.NoError(t, tx.Validate(bc.UTXOSet), "the Taproot input's output key should own its output")
+	require.NoError(t, bc.AddTransaction(*tx), "the pool should accept a spend of a Taproot output")
+	assert.Len(t, bc.GetPendingTransactions(), 1)
+
+	for i, input := range tx.Inputs {
+		hash := tx.TrimmedCopy()
+		hash.Inputs[i].PublicKey = input.PublicKey
+		digest := hash.CalculateHash()
+
+		switch funded[string(input.TxID)] {
+		case taproot.String():
+			// A Taproot input carries the output key and a BIP-340 signature
+			assert.Equal(t, taproot.Hash, input.PublicKey)
+			assert.Len(t, input.Signature, crypto.SchnorrSignatureLength)
+			assert.True(t, crypto.VerifySchnorr(digest, input.Signature, input.PublicKey))
+		case legacy.String():
+			assert.NotEqual(t, crypto.SchnorrSignatureLength, len(input.Signature))
+			assert.True(t, crypto.Verify(digest, input.Signature, input.PublicKey))
+			assert.False(t, crypto.VerifySchnorr(digest, input.Signature, input.PublicKey))
+		default:
+			t.Fatalf("input %d spends an unfunded output", i)
+		}
+	}
+
+	// A policy can sign Taproot inputs with ECDSA instead
+	w.SigningPolicy = SigningPolicy{}
+	assert.Equal(t, SignatureECDSA, w.signingPolicy().Scheme(taproot.String()))
+}